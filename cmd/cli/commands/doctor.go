@@ -0,0 +1,233 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// DoctorFlags holds flags for the doctor command
+type DoctorFlags struct {
+	URL       string
+	OutputDir string
+	Timeout   time.Duration
+}
+
+// NewDoctorCommand creates a new doctor command
+func NewDoctorCommand() *cobra.Command {
+	flags := &DoctorFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the CLI configuration and connectivity before running imports",
+		Long: `Check that the CLI is configured to run imports.
+
+The doctor command will:
+1. Print the effective configuration: config file, service URL and directories
+2. Check that the service answers its /health endpoint
+3. Check that the output directory for result files is writable
+4. Check that the configured file processing directories are writable
+
+Every check is reported as PASS or FAIL, and the command exits with a non-zero
+code if any check fails.`,
+		Example: `  # Check the configuration and the service
+  portfolio-cli doctor
+
+  # Check a remote service and a custom output directory
+  portfolio-cli doctor --url http://accounting:8087 --output-dir /data/results`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctorCommand(cmd.Context(), flags)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVar(&flags.URL, "url", "", "service URL (default from --service-url or config)")
+	cmd.Flags().StringVarP(&flags.OutputDir, "output-dir", "o", ".", "output directory for result files")
+	cmd.Flags().DurationVar(&flags.Timeout, "timeout", 10*time.Second, "service health check timeout")
+
+	return cmd
+}
+
+// runDoctorCommand executes the doctor command
+func runDoctorCommand(ctx context.Context, flags *DoctorFlags) error {
+	logger := GetGlobalLogger()
+	config := GetGlobalConfig()
+
+	if logger == nil {
+		return fmt.Errorf("logger not initialized")
+	}
+
+	if config == nil {
+		return fmt.Errorf("configuration not loaded")
+	}
+
+	// Determine service URL
+	serviceURL := resolveServiceURL(config)
+	if flags.URL != "" {
+		validated, err := ValidateServiceURL(flags.URL)
+		if err != nil {
+			return err
+		}
+		serviceURL = validated
+	}
+
+	logger.Info("Running configuration checks",
+		zap.String("url", serviceURL),
+		zap.String("output_dir", flags.OutputDir),
+	)
+
+	printDoctorConfig(os.Stdout, viper.ConfigFileUsed(), serviceURL, flags.OutputDir, config)
+
+	doctor := NewDoctor(flags.Timeout)
+	checks := doctor.RunChecks(ctx, serviceURL, flags.OutputDir, config)
+	failed := printDoctorChecks(os.Stdout, checks)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// DoctorCheck is the outcome of a single doctor check
+type DoctorCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// Doctor runs the configuration and connectivity checks of the doctor command
+type Doctor struct {
+	client *http.Client
+}
+
+// NewDoctor creates a doctor whose service check times out after timeout
+func NewDoctor(timeout time.Duration) *Doctor {
+	return &Doctor{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// RunChecks checks the service and the directories the CLI and the file processor write to.
+// Unset file processing directories are not checked.
+func (d *Doctor) RunChecks(ctx context.Context, serviceURL, outputDir string, cfg *config.Config) []DoctorCheck {
+	checks := []DoctorCheck{
+		d.CheckService(ctx, serviceURL),
+		CheckWritableDirectory("output directory", outputDir),
+	}
+
+	if cfg != nil {
+		if dir := cfg.FileProcessing.WorkingDirectory; dir != "" {
+			checks = append(checks, CheckWritableDirectory("working directory", dir))
+		}
+		if dir := cfg.FileProcessing.ErrorDirectory; dir != "" {
+			checks = append(checks, CheckWritableDirectory("error directory", dir))
+		}
+	}
+
+	return checks
+}
+
+// CheckService checks that the service's /health endpoint answers 200
+func (d *Doctor) CheckService(ctx context.Context, serviceURL string) DoctorCheck {
+	check := DoctorCheck{Name: "service"}
+	healthURL := serviceURL + "/health"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL, nil)
+	if err != nil {
+		check.Detail = fmt.Sprintf("failed to create request: %v", err)
+		return check
+	}
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	if err != nil {
+		check.Detail = fmt.Sprintf("GET %s failed: %v", healthURL, err)
+		return check
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	check.Detail = fmt.Sprintf("GET %s returned %d in %s", healthURL, resp.StatusCode, time.Since(start).Round(time.Millisecond))
+	check.Passed = resp.StatusCode == http.StatusOK
+	return check
+}
+
+// CheckWritableDirectory checks that dir exists, is a directory and accepts new files
+func CheckWritableDirectory(name, dir string) DoctorCheck {
+	check := DoctorCheck{Name: name}
+
+	absolute, err := filepath.Abs(dir)
+	if err != nil {
+		check.Detail = fmt.Sprintf("invalid path %q: %v", dir, err)
+		return check
+	}
+
+	info, err := os.Stat(absolute)
+	if err != nil {
+		check.Detail = fmt.Sprintf("%s is not accessible: %v", absolute, err)
+		return check
+	}
+	if !info.IsDir() {
+		check.Detail = fmt.Sprintf("%s is not a directory", absolute)
+		return check
+	}
+
+	file, err := os.CreateTemp(absolute, ".portfolio-cli-check-*")
+	if err != nil {
+		check.Detail = fmt.Sprintf("%s is not writable: %v", absolute, err)
+		return check
+	}
+	file.Close()
+	os.Remove(file.Name())
+
+	check.Passed = true
+	check.Detail = absolute + " is writable"
+	return check
+}
+
+// printDoctorConfig prints the effective configuration the checks run against
+func printDoctorConfig(out io.Writer, configFile, serviceURL, outputDir string, cfg *config.Config) {
+	if configFile == "" {
+		configFile = "(none, defaults and environment)"
+	}
+
+	fmt.Fprintf(out, "\n=== Effective Configuration ===\n")
+	fmt.Fprintf(out, "Config file: %s\n", configFile)
+	fmt.Fprintf(out, "Service URL: %s\n", serviceURL)
+	fmt.Fprintf(out, "Output directory: %s\n", outputDir)
+	fmt.Fprintf(out, "Working directory: %s\n", valueOrUnset(cfg.FileProcessing.WorkingDirectory))
+	fmt.Fprintf(out, "Error directory: %s\n", valueOrUnset(cfg.FileProcessing.ErrorDirectory))
+}
+
+// printDoctorChecks prints a line per check and returns the number of failed checks
+func printDoctorChecks(out io.Writer, checks []DoctorCheck) int {
+	failed := 0
+	fmt.Fprintf(out, "\n=== Checks ===\n")
+	for _, check := range checks {
+		result := "✅ PASS"
+		if !check.Passed {
+			result = "❌ FAIL"
+			failed++
+		}
+		fmt.Fprintf(out, "%s %s: %s\n", result, check.Name, check.Detail)
+	}
+	fmt.Fprintf(out, "\n")
+	return failed
+}
+
+// valueOrUnset returns value, or a placeholder when it is empty
+func valueOrUnset(value string) string {
+	if value == "" {
+		return "(not set)"
+	}
+	return value
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/config"
+)
+
+func TestDoctor_CheckService(t *testing.T) {
+	ctx := context.Background()
+	doctor := NewDoctor(time.Second)
+
+	t.Run("Healthy service passes", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/health", r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		check := doctor.CheckService(ctx, server.URL)
+		assert.True(t, check.Passed)
+		assert.Contains(t, check.Detail, "returned 200")
+	})
+
+	t.Run("Unhealthy service fails", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		check := doctor.CheckService(ctx, server.URL)
+		assert.False(t, check.Passed)
+		assert.Contains(t, check.Detail, "returned 503")
+	})
+
+	t.Run("Unreachable service fails", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		serviceURL := server.URL
+		server.Close()
+
+		check := doctor.CheckService(ctx, serviceURL)
+		assert.False(t, check.Passed)
+		assert.Contains(t, check.Detail, "failed")
+	})
+}
+
+func TestCheckWritableDirectory(t *testing.T) {
+	t.Run("Writable directory passes and is left clean", func(t *testing.T) {
+		dir := t.TempDir()
+
+		check := CheckWritableDirectory("output directory", dir)
+		assert.True(t, check.Passed, check.Detail)
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Missing directory fails", func(t *testing.T) {
+		check := CheckWritableDirectory("output directory", filepath.Join(t.TempDir(), "missing"))
+		assert.False(t, check.Passed)
+		assert.Contains(t, check.Detail, "not accessible")
+	})
+
+	t.Run("File instead of a directory fails", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "file.csv")
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+
+		check := CheckWritableDirectory("output directory", path)
+		assert.False(t, check.Passed)
+		assert.Contains(t, check.Detail, "not a directory")
+	})
+
+	t.Run("Read-only directory fails", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root can write to read-only directories")
+		}
+		dir := t.TempDir()
+		require.NoError(t, os.Chmod(dir, 0o555))
+		t.Cleanup(func() { _ = os.Chmod(dir, 0o755) })
+
+		check := CheckWritableDirectory("output directory", dir)
+		assert.False(t, check.Passed)
+		assert.Contains(t, check.Detail, "not writable")
+	})
+}
+
+func TestDoctor_RunChecks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{FileProcessing: config.FileProcessingConfig{
+		WorkingDirectory: t.TempDir(),
+		ErrorDirectory:   filepath.Join(t.TempDir(), "missing"),
+	}}
+
+	checks := NewDoctor(time.Second).RunChecks(context.Background(), server.URL, t.TempDir(), cfg)
+	require.Len(t, checks, 4)
+	passed := make(map[string]bool)
+	for _, check := range checks {
+		passed[check.Name] = check.Passed
+	}
+	assert.Equal(t, map[string]bool{
+		"service":           true,
+		"output directory":  true,
+		"working directory": true,
+		"error directory":   false,
+	}, passed)
+
+	var out bytes.Buffer
+	assert.Equal(t, 1, printDoctorChecks(&out, checks))
+	assert.Contains(t, out.String(), "❌ FAIL error directory")
+
+	// Unset file processing directories are not checked
+	checks = NewDoctor(time.Second).RunChecks(context.Background(), server.URL, t.TempDir(), &config.Config{})
+	assert.Len(t, checks, 2)
+}
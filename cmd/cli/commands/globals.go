@@ -7,8 +7,9 @@ import (
 
 // Global variables for shared configuration and logger
 var (
-	globalConfig *config.Config
-	globalLogger logger.Logger
+	globalConfig     *config.Config
+	globalLogger     logger.Logger
+	globalServiceURL string
 )
 
 // SetGlobalConfig sets the global configuration for all commands
@@ -30,3 +31,13 @@ func GetGlobalConfig() *config.Config {
 func GetGlobalLogger() logger.Logger {
 	return globalLogger
 }
+
+// SetGlobalServiceURL sets the service URL that overrides the configured server address
+func SetGlobalServiceURL(serviceURL string) {
+	globalServiceURL = serviceURL
+}
+
+// GetGlobalServiceURL returns the service URL override, or an empty string if none was given
+func GetGlobalServiceURL() string {
+	return globalServiceURL
+}
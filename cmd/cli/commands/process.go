@@ -12,6 +12,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/mappers"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/config"
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
@@ -66,7 +68,7 @@ Input file format: CSV with columns:
   # Skip sorting step (if file is already sorted)
   portfolio-cli process --file transactions.csv --skip-sort
 
-  # Force processing even if validation warnings exist
+  # Process a file again that was already processed with the same content
   portfolio-cli process --file transactions.csv --force`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runProcessCommand(cmd.Context(), flags)
@@ -80,7 +82,7 @@ Input file format: CSV with columns:
 	cmd.Flags().IntVar(&flags.Workers, "workers", 1, "number of concurrent workers")
 	cmd.Flags().DurationVar(&flags.Timeout, "timeout", 5*time.Minute, "timeout for processing operations")
 	cmd.Flags().BoolVar(&flags.SkipSort, "skip-sort", false, "skip sorting step (assumes file is already sorted)")
-	cmd.Flags().BoolVar(&flags.Force, "force", false, "force processing even with validation warnings")
+	cmd.Flags().BoolVar(&flags.Force, "force", false, "process the file even if a run with the same name and content already completed")
 
 	// Mark required flags
 	cmd.MarkFlagRequired("file")
@@ -158,22 +160,41 @@ type ProcessingResult struct {
 	Duration         time.Duration
 	Batches          int
 	SkippedRecords   int
+	// FailedBatches counts the batches that could not be submitted or whose answer could not
+	// be read
+	FailedBatches int
 }
 
 // FileProcessor handles transaction file processing
 type FileProcessor struct {
-	config *config.Config
-	logger logger.Logger
+	config    *config.Config
+	logger    logger.Logger
+	processed *services.ProcessedFiles
 }
 
-// NewFileProcessor creates a new file processor
+// NewFileProcessor creates a new file processor. Completed runs are recorded in the service's
+// progress directory, so a file processed by either the CLI or the service is detected by both.
 func NewFileProcessor(cfg *config.Config, lg logger.Logger) *FileProcessor {
 	return &FileProcessor{
-		config: cfg,
-		logger: lg,
+		config:    cfg,
+		logger:    lg,
+		processed: services.NewProcessedFiles(progressDirectory(cfg), lg),
 	}
 }
 
+// progressDirectory returns the file processing progress directory, defaulting the same way as
+// the service
+func progressDirectory(cfg *config.Config) string {
+	if cfg.FileProcessing.ProgressDirectory != "" {
+		return cfg.FileProcessing.ProgressDirectory
+	}
+	workingDirectory := cfg.FileProcessing.WorkingDirectory
+	if workingDirectory == "" {
+		workingDirectory = "./data"
+	}
+	return filepath.Join(workingDirectory, ".progress")
+}
+
 // ProcessFile processes a transaction file
 func (p *FileProcessor) ProcessFile(ctx context.Context, filePath string, options ProcessingOptions) (*ProcessingResult, error) {
 	start := time.Now()
@@ -188,6 +209,12 @@ func (p *FileProcessor) ProcessFile(ctx context.Context, filePath string, option
 		return nil, fmt.Errorf("file validation failed: %w", err)
 	}
 
+	// A file with the same name and content as a completed run would submit its transactions twice
+	contentHash, err := p.processed.Check(filePath, options.Force)
+	if err != nil {
+		return nil, fmt.Errorf("%w; use --force to process it again", err)
+	}
+
 	// Create output directory if it doesn't exist
 	if err := os.MkdirAll(options.OutputDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create output directory: %w", err)
@@ -222,6 +249,23 @@ func (p *FileProcessor) ProcessFile(ctx context.Context, filePath string, option
 	result.InputFile = filePath
 	result.Duration = time.Since(start)
 
+	// A run with failed batches is not recorded, so it can be repeated without --force
+	if result.FailedBatches == 0 {
+		completedAt := time.Now()
+		if err := p.processed.Record(&dto.FileProcessingStatus{
+			Filename:         filepath.Base(filePath),
+			Status:           services.FileStatusCompleted,
+			StartedAt:        start,
+			CompletedAt:      &completedAt,
+			TotalRecords:     result.TotalRecords,
+			ProcessedRecords: result.SuccessRecords,
+			FailedRecords:    result.ErrorRecords,
+			ContentHash:      contentHash,
+		}); err != nil {
+			p.logger.Warn("Failed to save completed file processing record", zap.String("file", filePath), zap.Error(err))
+		}
+	}
+
 	p.logger.Info("File processing completed",
 		zap.String("file", filePath),
 		zap.Duration("duration", result.Duration),
@@ -280,7 +324,11 @@ func (p *FileProcessor) sortFile(inputFile, outputDir string) (string, error) {
 
 // processBatches processes the file in batches grouped by portfolio
 func (p *FileProcessor) processBatches(ctx context.Context, filePath string, options ProcessingOptions) (*ProcessingResult, error) {
-	csvProc := services.NewCSVProcessor(p.logger)
+	csvProc := services.NewCSVProcessor(p.logger).WithDecimalFormat(services.DecimalFormat{
+		DecimalSeparator:   p.config.FileProcessing.DecimalSeparator,
+		ThousandsSeparator: p.config.FileProcessing.ThousandsSeparator,
+	}).WithTransactionMapper(mappers.NewTransactionMapper().
+		WithTransactionTypeAliases(p.config.Validation.TransactionTypeAliases))
 	records, err := csvProc.ReadCSVFile(ctx, filePath, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read/validate CSV: %w", err)
@@ -344,7 +392,7 @@ func (p *FileProcessor) processBatches(ctx context.Context, filePath string, opt
 				"sourceId":        dto.SourceID,
 				"transactionType": dto.TransactionType,
 				"quantity":        dto.Quantity.String(),
-				"price":           dto.Price.String(),
+				"price":           dto.PriceString(),
 				"transactionDate": dto.TransactionDate,
 			}
 			if dto.SecurityID != nil {
@@ -429,35 +477,31 @@ func (p *FileProcessor) processBatches(ctx context.Context, filePath string, opt
 	}
 	result.Batches = batches
 	result.SkippedRecords = skipped
+	result.FailedBatches = len(errors)
 
 	if len(errorRecords) > 0 {
 		header := []string{"portfolio_id", "security_id", "source_id", "transaction_type", "quantity", "price", "transaction_date", "error_message"}
 		errorFile := filepath.Join(options.OutputDir, filepath.Base(filePath)+".errors.csv")
-		f, err := os.Create(errorFile)
-		if err == nil {
+		err := services.WriteFileAtomic(errorFile, func(f io.Writer) error {
 			w := csv.NewWriter(f)
-			_ = w.Write(header)
-			_ = w.WriteAll(errorRecords)
-			w.Flush()
-			f.Close()
+			if err := w.Write(header); err != nil {
+				return err
+			}
+			return w.WriteAll(errorRecords)
+		})
+		if err == nil {
 			result.ErrorFile = errorFile
+		} else {
+			p.logger.Error("Failed to write error file", zap.String("file", errorFile), zap.Error(err))
 		}
 	}
 
 	return result, nil
 }
 
-// getServiceURL builds the base URL for the backend service
+// getServiceURL returns the base URL for the backend service
 func (p *FileProcessor) getServiceURL() string {
-	host := p.config.Server.Host
-	port := p.config.Server.Port
-	if host == "" {
-		host = "localhost"
-	}
-	if port == 0 {
-		port = 8087
-	}
-	return fmt.Sprintf("http://%s:%d", host, port)
+	return resolveServiceURL(p.config)
 }
 
 // printProcessingResults prints the processing results to stdout
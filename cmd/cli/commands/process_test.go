@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/config"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+func TestFileProcessor_DetectsAlreadyProcessedFile(t *testing.T) {
+	submitted := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		submitted++
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"successful":[{}],"failed":[]}`))
+	}))
+	t.Cleanup(server.Close)
+	SetGlobalServiceURL(server.URL)
+	t.Cleanup(func() { SetGlobalServiceURL("") })
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "transactions.csv")
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,DEP-1,DEP,1000,1,20240102\n"
+	require.NoError(t, os.WriteFile(file, []byte(csv), 0644))
+
+	cfg := &config.Config{FileProcessing: config.FileProcessingConfig{WorkingDirectory: dir}}
+	processor := NewFileProcessor(cfg, logger.NewNoop())
+	options := ProcessingOptions{BatchSize: 10, Timeout: time.Second, SkipSort: true, OutputDir: dir}
+	ctx := context.Background()
+
+	_, err := processor.ProcessFile(ctx, file, options)
+	require.NoError(t, err)
+	assert.Equal(t, 1, submitted)
+
+	// The same file again is rejected before anything is submitted
+	_, err = processor.ProcessFile(ctx, file, options)
+	var processed *services.FileAlreadyProcessedError
+	require.ErrorAs(t, err, &processed)
+	assert.Contains(t, err.Error(), "--force")
+	assert.Equal(t, 1, submitted)
+
+	// --force submits it again
+	options.Force = true
+	_, err = processor.ProcessFile(ctx, file, options)
+	require.NoError(t, err)
+	assert.Equal(t, 2, submitted)
+}
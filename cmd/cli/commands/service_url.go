@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/config"
+)
+
+// ServiceURLEnvVar is the environment variable read when the --service-url flag is not set
+const ServiceURLEnvVar = "GLOBECO_PA_SERVICE_URL"
+
+// ValidateServiceURL checks that raw is an absolute http or https URL and returns it without
+// a trailing slash, ready to have API paths appended
+func ValidateServiceURL(raw string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("invalid service URL %q: %w", raw, err)
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("invalid service URL %q: scheme must be http or https", raw)
+	}
+
+	if parsed.Host == "" {
+		return "", fmt.Errorf("invalid service URL %q: host is required", raw)
+	}
+
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", fmt.Errorf("invalid service URL %q: query and fragment are not allowed", raw)
+	}
+
+	return strings.TrimRight(parsed.String(), "/"), nil
+}
+
+// resolveServiceURL returns the base URL of the backend service. The --service-url override
+// takes precedence; otherwise the URL is built from the configured server host and port.
+func resolveServiceURL(cfg *config.Config) string {
+	if override := GetGlobalServiceURL(); override != "" {
+		return override
+	}
+
+	host := "localhost"
+	port := 8087
+	if cfg != nil {
+		if cfg.Server.Host != "" {
+			host = cfg.Server.Host
+		}
+		if cfg.Server.Port != 0 {
+			port = cfg.Server.Port
+		}
+	}
+	return fmt.Sprintf("http://%s:%d", host, port)
+}
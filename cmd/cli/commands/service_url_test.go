@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/config"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+func TestFileProcessor_ServiceURLOverrideTakesPrecedence(t *testing.T) {
+	cfg := &config.Config{Server: config.ServerConfig{Host: "accounting.internal", Port: 9000}}
+	processor := NewFileProcessor(cfg, logger.NewNoop())
+
+	SetGlobalServiceURL("")
+	assert.Equal(t, "http://accounting.internal:9000", processor.getServiceURL())
+
+	override, err := ValidateServiceURL("https://localhost:18087/")
+	require.NoError(t, err)
+	SetGlobalServiceURL(override)
+	t.Cleanup(func() { SetGlobalServiceURL("") })
+
+	assert.Equal(t, "https://localhost:18087", processor.getServiceURL())
+}
+
+func TestValidateServiceURL(t *testing.T) {
+	valid, err := ValidateServiceURL(" http://10.0.0.5:8087/accounting/ ")
+	require.NoError(t, err)
+	assert.Equal(t, "http://10.0.0.5:8087/accounting", valid)
+
+	for _, raw := range []string{"localhost:8087", "ftp://host", "http://", "http://host?x=1", "://bad"} {
+		_, err := ValidateServiceURL(raw)
+		assert.Error(t, err, raw)
+	}
+}
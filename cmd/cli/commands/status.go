@@ -54,7 +54,7 @@ This is useful for monitoring and troubleshooting the service.`,
 	}
 
 	// Add flags
-	cmd.Flags().StringVar(&flags.URL, "url", "", "service URL (default from config)")
+	cmd.Flags().StringVar(&flags.URL, "url", "", "service URL (default from --service-url or config)")
 	cmd.Flags().DurationVar(&flags.Timeout, "timeout", 10*time.Second, "request timeout")
 	cmd.Flags().BoolVarP(&flags.Verbose, "verbose", "v", false, "verbose output")
 
@@ -75,9 +75,13 @@ func runStatusCommand(ctx context.Context, flags *StatusFlags) error {
 	}
 
 	// Determine service URL
-	serviceURL := flags.URL
-	if serviceURL == "" {
-		serviceURL = fmt.Sprintf("http://%s:%d", config.Server.Host, config.Server.Port)
+	serviceURL := resolveServiceURL(config)
+	if flags.URL != "" {
+		validated, err := ValidateServiceURL(flags.URL)
+		if err != nil {
+			return err
+		}
+		serviceURL = validated
 	}
 
 	logger.Info("Checking service status",
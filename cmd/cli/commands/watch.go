@@ -0,0 +1,303 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// progressBarWidth is the number of characters of the rendered progress bar
+const progressBarWidth = 30
+
+// WatchFlags holds flags for the watch command
+type WatchFlags struct {
+	URL     string
+	Timeout time.Duration
+}
+
+// NewWatchCommand creates a new watch command
+func NewWatchCommand() *cobra.Command {
+	flags := &WatchFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "watch <filename>",
+		Short: "Follow the progress of a file being processed by the service",
+		Long: `Follow the progress of a transaction file the service is processing.
+
+The watch command subscribes to the service's progress stream for the file
+(GET /api/v1/files/{filename}/progress) and renders a live progress bar with the
+processing rate and an ETA. It exits when processing finishes: with a non-zero
+exit code if the job failed, stopped early, or rejected any records.`,
+		Example: `  # Follow a file started with POST /api/v1/files/{filename}/process
+  portfolio-cli watch transactions.csv
+
+  # Follow a file on another service instance, giving up after an hour
+  portfolio-cli watch transactions.csv --url http://accounting:8087 --timeout 1h`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWatchCommand(cmd.Context(), args[0], flags)
+		},
+	}
+
+	// Add flags
+	cmd.Flags().StringVar(&flags.URL, "url", "", "service URL (default from --service-url or config)")
+	cmd.Flags().DurationVar(&flags.Timeout, "timeout", 0, "stop watching after this long (0 watches until processing finishes)")
+
+	return cmd
+}
+
+// runWatchCommand executes the watch command
+func runWatchCommand(ctx context.Context, filename string, flags *WatchFlags) error {
+	logger := GetGlobalLogger()
+	config := GetGlobalConfig()
+
+	if logger == nil {
+		return fmt.Errorf("logger not initialized")
+	}
+
+	if config == nil {
+		return fmt.Errorf("configuration not loaded")
+	}
+
+	// Determine service URL
+	serviceURL := resolveServiceURL(config)
+	if flags.URL != "" {
+		validated, err := ValidateServiceURL(flags.URL)
+		if err != nil {
+			return err
+		}
+		serviceURL = validated
+	}
+
+	if flags.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, flags.Timeout)
+		defer cancel()
+	}
+
+	logger.Info("Watching file processing",
+		zap.String("url", serviceURL),
+		zap.String("filename", filename),
+	)
+
+	watcher := NewProgressWatcher(logger, os.Stdout)
+	status, err := watcher.Watch(ctx, serviceURL, filename)
+	if err != nil {
+		return err
+	}
+
+	return watchResultError(status)
+}
+
+// watchResultError returns the error the watch command exits with for a finished job, or nil
+// when every record was processed
+func watchResultError(status *dto.FileProcessingStatus) error {
+	switch {
+	case status.Status == services.FileStatusFailed:
+		return fmt.Errorf("processing of %s failed", status.Filename)
+	case status.Status == services.FileStatusStopped:
+		return fmt.Errorf("processing of %s stopped before the end of the file", status.Filename)
+	case status.FailedRecords > 0:
+		return fmt.Errorf("processing of %s finished with %d failed records", status.Filename, status.FailedRecords)
+	}
+	return nil
+}
+
+// ProgressWatcher follows a file processing job through the service's progress stream
+type ProgressWatcher struct {
+	logger logger.Logger
+	out    io.Writer
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewProgressWatcher creates a progress watcher rendering to out. The stream stays open for
+// the whole job, so the client has no timeout; cancel the context instead.
+func NewProgressWatcher(lg logger.Logger, out io.Writer) *ProgressWatcher {
+	return &ProgressWatcher{
+		logger: lg,
+		out:    out,
+		client: &http.Client{},
+		now:    time.Now,
+	}
+}
+
+// Watch renders the job's progress until it reaches a final status, which it returns
+func (w *ProgressWatcher) Watch(ctx context.Context, serviceURL, filename string) (*dto.FileProcessingStatus, error) {
+	progressURL := fmt.Sprintf("%s/api/v1/files/%s/progress", serviceURL, url.PathEscape(filename))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, progressURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("no processing job found for file %s", filename)
+	default:
+		return nil, fmt.Errorf("progress endpoint returned status %d", resp.StatusCode)
+	}
+
+	renderer := newProgressRenderer(w.out, w.now)
+	var last *dto.FileProcessingStatus
+
+	err = readServerSentEvents(resp.Body, func(event, data string) error {
+		var status dto.FileProcessingStatus
+		if err := json.Unmarshal([]byte(data), &status); err != nil {
+			return fmt.Errorf("failed to decode %s event: %w", event, err)
+		}
+		last = &status
+		renderer.render(status)
+		if services.IsTerminalFileStatus(status.Status) {
+			return errStreamDone
+		}
+		return nil
+	})
+	renderer.finish()
+
+	if errors.Is(err, errStreamDone) {
+		w.logger.Info("File processing finished",
+			zap.String("filename", filename),
+			zap.String("status", last.Status),
+			zap.Int("processed", last.ProcessedRecords),
+			zap.Int("failed", last.FailedRecords),
+		)
+		return last, nil
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("stopped watching %s: %w", filename, ctxErr)
+		}
+		return nil, fmt.Errorf("failed to read progress stream: %w", err)
+	}
+	return nil, fmt.Errorf("progress stream for %s ended before processing finished", filename)
+}
+
+// errStreamDone stops reading a stream once the job has finished
+var errStreamDone = errors.New("stream done")
+
+// readServerSentEvents calls handle for every event with data in an SSE stream until the
+// stream ends or handle returns an error. Comment lines, such as keep-alives, are skipped.
+func readServerSentEvents(body io.Reader, handle func(event, data string) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	event := "message"
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				if err := handle(event, strings.Join(data, "\n")); err != nil {
+					return err
+				}
+			}
+			event, data = "message", nil
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	return scanner.Err()
+}
+
+// progressRenderer redraws a single progress line. The rate is measured from the first status
+// seen, so a watch attached to a running or resumed job does not count earlier records.
+type progressRenderer struct {
+	out       io.Writer
+	now       func() time.Time
+	started   time.Time
+	startDone int
+	rendered  bool
+}
+
+func newProgressRenderer(out io.Writer, now func() time.Time) *progressRenderer {
+	return &progressRenderer{out: out, now: now}
+}
+
+// render redraws the progress line for status
+func (r *progressRenderer) render(status dto.FileProcessingStatus) {
+	done := status.ProcessedRecords + status.FailedRecords
+	if !r.rendered {
+		r.started = r.now()
+		r.startDone = done
+	}
+	r.rendered = true
+
+	fmt.Fprintf(r.out, "\r%s", formatProgressLine(status, r.rate(done)))
+}
+
+// rate returns the records handled per second since the first status
+func (r *progressRenderer) rate(done int) float64 {
+	elapsed := r.now().Sub(r.started).Seconds()
+	if elapsed <= 0 || done <= r.startDone {
+		return 0
+	}
+	return float64(done-r.startDone) / elapsed
+}
+
+// finish ends the progress line
+func (r *progressRenderer) finish() {
+	if r.rendered {
+		fmt.Fprintln(r.out)
+	}
+}
+
+// formatProgressLine renders the bar, counts, rate and ETA of a status. Without a known total
+// only the counts and rate are shown.
+func formatProgressLine(status dto.FileProcessingStatus, rate float64) string {
+	done := status.ProcessedRecords + status.FailedRecords
+
+	var line strings.Builder
+	if status.TotalRecords > 0 {
+		fraction := float64(done) / float64(status.TotalRecords)
+		if fraction > 1 {
+			fraction = 1
+		}
+		filled := int(fraction * progressBarWidth)
+		fmt.Fprintf(&line, "[%s%s] %5.1f%% %d/%d records",
+			strings.Repeat("#", filled), strings.Repeat(".", progressBarWidth-filled),
+			fraction*100, done, status.TotalRecords)
+	} else {
+		fmt.Fprintf(&line, "%d records", done)
+	}
+
+	fmt.Fprintf(&line, ", %d failed, %.1f rec/s", status.FailedRecords, rate)
+
+	switch {
+	case services.IsTerminalFileStatus(status.Status):
+		fmt.Fprintf(&line, ", %s", status.Status)
+	case status.TotalRecords > done && rate > 0:
+		remaining := time.Duration(float64(status.TotalRecords-done) / rate * float64(time.Second))
+		fmt.Fprintf(&line, ", ETA %s", remaining.Round(time.Second))
+	default:
+		line.WriteString(", ETA --")
+	}
+
+	return line.String()
+}
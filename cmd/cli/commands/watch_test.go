@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// progressStreamServer serves the given statuses as the progress stream of trades.csv, with a
+// keep-alive comment before the first one
+func progressStreamServer(t *testing.T, statuses ...dto.FileProcessingStatus) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/files/trades.csv/progress" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		for _, status := range statuses {
+			data, err := json.Marshal(status)
+			require.NoError(t, err)
+			event := "progress"
+			if services.IsTerminalFileStatus(status.Status) {
+				event = "complete"
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func progressStatus(status string, processed, failed int) dto.FileProcessingStatus {
+	return dto.FileProcessingStatus{
+		Filename:         "trades.csv",
+		Status:           status,
+		TotalRecords:     100,
+		ProcessedRecords: processed,
+		FailedRecords:    failed,
+	}
+}
+
+func TestProgressWatcher_Watch(t *testing.T) {
+	ctx := context.Background()
+
+	// The clock advances half a second every time it is read
+	newWatcher := func(out *bytes.Buffer) *ProgressWatcher {
+		watcher := NewProgressWatcher(logger.NewNoop(), out)
+		clock := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+		watcher.now = func() time.Time {
+			clock = clock.Add(500 * time.Millisecond)
+			return clock
+		}
+		return watcher
+	}
+
+	t.Run("Renders progress until the job completes", func(t *testing.T) {
+		server := progressStreamServer(t,
+			progressStatus(services.FileStatusProcessing, 0, 0),
+			progressStatus(services.FileStatusProcessing, 20, 0),
+			progressStatus(services.FileStatusCompleted, 100, 0),
+			progressStatus(services.FileStatusProcessing, 1, 0), // never read
+		)
+		var out bytes.Buffer
+
+		status, err := newWatcher(&out).Watch(ctx, server.URL, "trades.csv")
+		require.NoError(t, err)
+		assert.Equal(t, services.FileStatusCompleted, status.Status)
+		assert.NoError(t, watchResultError(status))
+
+		assert.Equal(t,
+			"\r[..............................]   0.0% 0/100 records, 0 failed, 0.0 rec/s, ETA --"+
+				"\r[######........................]  20.0% 20/100 records, 0 failed, 20.0 rec/s, ETA 4s"+
+				"\r[##############################] 100.0% 100/100 records, 0 failed, 66.7 rec/s, COMPLETED\n",
+			out.String())
+	})
+
+	t.Run("Failed records fail the command", func(t *testing.T) {
+		server := progressStreamServer(t, progressStatus(services.FileStatusCompleted, 97, 3))
+		var out bytes.Buffer
+
+		status, err := newWatcher(&out).Watch(ctx, server.URL, "trades.csv")
+		require.NoError(t, err)
+		err = watchResultError(status)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "3 failed records")
+	})
+
+	t.Run("Failed and stopped jobs fail the command", func(t *testing.T) {
+		for _, final := range []string{services.FileStatusFailed, services.FileStatusStopped} {
+			server := progressStreamServer(t, progressStatus(final, 10, 0))
+			var out bytes.Buffer
+
+			status, err := newWatcher(&out).Watch(ctx, server.URL, "trades.csv")
+			require.NoError(t, err)
+			assert.Error(t, watchResultError(status), final)
+		}
+	})
+
+	t.Run("Stream ending before a final status", func(t *testing.T) {
+		server := progressStreamServer(t, progressStatus(services.FileStatusProcessing, 10, 0))
+		var out bytes.Buffer
+
+		_, err := newWatcher(&out).Watch(ctx, server.URL, "trades.csv")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "ended before processing finished")
+	})
+
+	t.Run("Unknown file", func(t *testing.T) {
+		server := progressStreamServer(t)
+		var out bytes.Buffer
+
+		_, err := newWatcher(&out).Watch(ctx, server.URL, "other.csv")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no processing job found")
+		assert.Empty(t, out.String())
+	})
+}
+
+func TestFormatProgressLine_UnknownTotal(t *testing.T) {
+	status := dto.FileProcessingStatus{Status: services.FileStatusProcessing, ProcessedRecords: 40, FailedRecords: 2}
+	assert.Equal(t, "42 records, 2 failed, 12.5 rec/s, ETA --", formatProgressLine(status, 12.5))
+}
@@ -31,6 +31,7 @@ var (
 	dryRun     bool
 	logLevel   string
 	logFormat  string
+	serviceURL string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -62,6 +63,8 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "perform a dry run without making changes")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "json", "log format (json, console)")
+	rootCmd.PersistentFlags().StringVar(&serviceURL, "service-url", "",
+		fmt.Sprintf("base URL of the service, overriding the configured host and port (env %s)", commands.ServiceURLEnvVar))
 
 	// Add subcommands
 	addCommands()
@@ -81,6 +84,14 @@ func addCommands() {
 	statusCmd := commands.NewStatusCommand()
 	rootCmd.AddCommand(statusCmd)
 
+	// Add watch command
+	watchCmd := commands.NewWatchCommand()
+	rootCmd.AddCommand(watchCmd)
+
+	// Add doctor command
+	doctorCmd := commands.NewDoctorCommand()
+	rootCmd.AddCommand(doctorCmd)
+
 	// Add version command
 	versionCmd := &cobra.Command{
 		Use:   "version",
@@ -106,9 +117,23 @@ func initializeGlobals() error {
 		return fmt.Errorf("failed to initialize logger: %w", err)
 	}
 
+	// Resolve the service URL override; the flag wins over the environment
+	override := serviceURL
+	if override == "" {
+		override = os.Getenv(commands.ServiceURLEnvVar)
+	}
+	if override != "" {
+		validated, err := commands.ValidateServiceURL(override)
+		if err != nil {
+			return err
+		}
+		override = validated
+	}
+
 	// Set global configuration and logger for commands
 	commands.SetGlobalConfig(cfg)
 	commands.SetGlobalLogger(appLogger)
+	commands.SetGlobalServiceURL(override)
 
 	if verbose {
 		appLogger.Info("CLI initialized",
@@ -116,6 +141,7 @@ func initializeGlobals() error {
 			zap.String("config_file", configFile),
 			zap.Bool("dry_run", dryRun),
 			zap.String("log_level", logLevel),
+			zap.String("service_url", override),
 		)
 	}
 
@@ -172,6 +198,8 @@ Available Commands:
   process     Process transaction files
   validate    Validate transaction files without processing
   status      Check service status and health
+  watch       Follow the progress of a file being processed by the service
+  doctor      Check the CLI configuration and connectivity
   version     Print version information
 
 Flags:
@@ -180,6 +208,7 @@ Flags:
       --dry-run            perform a dry run without making changes
       --log-level string   log level (debug, info, warn, error) (default "info")
       --log-format string  log format (json, console) (default "json")
+      --service-url string base URL of the service, overriding the configured host and port
   -h, --help               help for %s
 
 Use "%s [command] --help" for more information about a command.
@@ -197,11 +226,14 @@ Examples:
   # Use custom configuration
   %s process --config /path/to/config.yaml --file transactions.csv
 
+  # Target a remote or port-forwarded service
+  %s process --service-url https://accounting.example.com --file transactions.csv
+
   # Enable verbose logging
   %s process --file transactions.csv --verbose --log-level debug
 
 For more information, visit: https://github.com/kasbench/globeco-portfolio-accounting-service
-`, cliDescription, cliVersion, cliName, cliName, cliName, cliName, cliName, cliName, cliName, cliName)
+`, cliDescription, cliVersion, cliName, cliName, cliName, cliName, cliName, cliName, cliName, cliName, cliName)
 }
 
 // validateArgs validates command line arguments
@@ -245,23 +277,3 @@ func setupSignalHandling(logger logger.Logger) {
 	// TODO: Add signal handling for graceful shutdown during file processing
 	// This will be useful for long-running file processing operations
 }
-
-// validateEnvironment validates the runtime environment
-func validateEnvironment() error {
-	// Check if required directories exist or can be created
-	workingDir, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get working directory: %w", err)
-	}
-
-	// Check write permissions
-	tempFile := fmt.Sprintf("%s/.portfolio-cli-test", workingDir)
-	file, err := os.Create(tempFile)
-	if err != nil {
-		return fmt.Errorf("no write permission in current directory: %w", err)
-	}
-	file.Close()
-	os.Remove(tempFile)
-
-	return nil
-}
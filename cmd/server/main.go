@@ -64,9 +64,6 @@ const (
 	// serviceName is the name of the service
 	serviceName = "globeco-portfolio-accounting-service"
 
-	// serviceVersion is the version of the service
-	serviceVersion = "1.0.0"
-
 	// gracefulShutdownTimeout is the default timeout for graceful shutdown
 	gracefulShutdownTimeout = 30 * time.Second
 )
@@ -77,6 +74,9 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	if err := validateConfiguration(cfg); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Initialize logger
 	appLogger, err := initializeLogger(cfg.Logging)
@@ -91,7 +91,7 @@ func main() {
 
 	appLogger.Info("Starting GlobeCo Portfolio Accounting Service",
 		zap.String("service", serviceName),
-		zap.String("version", serviceVersion),
+		zap.String("version", api.ServiceVersion()),
 		zap.String("host", cfg.Server.Host),
 		zap.Int("port", cfg.Server.Port),
 	)
@@ -239,7 +239,7 @@ func printStartupBanner() {
     ╔══════════════════════════════════════════════════════════════╗
     ║                                                              ║
     ║   GlobeCo Portfolio Accounting Service                       ║
-    ║   Version: ` + serviceVersion + `                                           ║
+    ║   Version: ` + fmt.Sprintf("%-50s", api.ServiceVersion()) + `║
     ║                                                              ║
     ║   A microservice for processing financial transactions       ║
     ║   and maintaining portfolio balances.                        ║
@@ -249,25 +249,10 @@ func printStartupBanner() {
 	fmt.Print(banner)
 }
 
-// validateConfiguration validates the loaded configuration
+// validateConfiguration validates the loaded configuration, so that invalid or contradictory
+// settings stop the service before it connects to anything
 func validateConfiguration(cfg *config.Config) error {
-	if cfg.Server.Port <= 0 || cfg.Server.Port > 65535 {
-		return fmt.Errorf("invalid server port: %d", cfg.Server.Port)
-	}
-
-	if cfg.Server.Host == "" {
-		return fmt.Errorf("server host cannot be empty")
-	}
-
-	if cfg.Database.Host == "" {
-		return fmt.Errorf("database host cannot be empty")
-	}
-
-	if cfg.Database.Database == "" {
-		return fmt.Errorf("database name cannot be empty")
-	}
-
-	return nil
+	return cfg.Validate()
 }
 
 // handlePanic recovers from panics and logs them appropriately
@@ -292,13 +277,10 @@ func printConfiguration(cfg *config.Config, logger logger.Logger) {
 		zap.Int("database.port", cfg.Database.Port),
 		zap.String("database.database", cfg.Database.Database),
 		zap.String("database.ssl_mode", cfg.Database.SSLMode),
-		zap.Bool("cache.enabled", cfg.Cache.Enabled),
 		zap.String("cache.address", cfg.Cache.Address),
-		zap.Bool("kafka.enabled", cfg.Kafka.Enabled),
 		zap.String("logging.level", cfg.Logging.Level),
 		zap.String("logging.format", cfg.Logging.Format),
-		zap.Bool("metrics.enabled", cfg.Metrics.Enabled),
-		zap.Bool("tracing.enabled", cfg.Tracing.Enabled),
+		zap.Any("features", cfg.Flags()),
 	)
 }
 
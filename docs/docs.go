@@ -822,7 +822,6 @@ const docTemplate = `{
             "type": "object",
             "required": [
                 "portfolioId",
-                "price",
                 "quantity",
                 "sourceId",
                 "transactionDate",
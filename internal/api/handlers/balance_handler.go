@@ -2,15 +2,20 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/api/middleware"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
@@ -36,25 +41,40 @@ func NewBalanceHandler(balanceService services.BalanceService, logger logger.Log
 // @Produce json
 // @Param portfolio_id query string false "Filter by portfolio ID (24 characters)"
 // @Param security_id query string false "Filter by security ID (24 characters). Use 'null' for cash balances"
+// @Param scope query string false "Balance scope" Enums(ALL,CASH_ONLY,SECURITIES_ONLY)
+// @Param cash_only query bool false "Legacy form of scope=CASH_ONLY; rejected if it contradicts scope"
+// @Param min_notional query number false "Only security positions whose quantityLong times reference price (latest processed price) is at least this value; positions without a price are excluded and listed in unpricedSecurityIds"
+// @Param max_notional query number false "Only security positions whose quantityLong times reference price is at most this value"
+// @Param min_abs_long query number false "Only balances whose absolute quantityLong is at least this value"
+// @Param min_abs_short query number false "Only balances whose absolute quantityShort is at least this value"
 // @Param offset query int false "Pagination offset (default: 0)" minimum(0)
-// @Param limit query int false "Number of records to return (default: 50, max: 1000)" minimum(1) maximum(1000)
-// @Param sortby query string false "Sort fields (comma-separated): portfolio_id,security_id"
+// @Param limit query int false "Number of records to return (default: 50, max: 1000); a larger limit is clamped and reported in pagination.limitClamped" minimum(1)
+// @Param sortby query string false "Sort fields (comma-separated); GET /balances/schema lists the supported fields"
+// @Param fields query string false "Sparse fieldset (comma-separated), e.g. portfolioId,quantityLong"
 // @Success 200 {object} dto.BalanceListResponse "Successfully retrieved balances"
 // @Failure 400 {object} dto.ErrorResponse "Invalid request parameters"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Failure 504 {object} dto.ErrorResponse "Request timed out before the query completed"
 // @Security ApiKeyAuth
 // @Router /balances [get]
 func (h *BalanceHandler) GetBalances(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	// Parse query parameters
-	filter, err := h.parseBalanceFilter(r)
+	filter, err := parseBalanceFilter(r)
 	if err != nil {
 		h.logger.Error("Failed to parse balance filter", zap.Error(err))
 		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_FILTER", err.Error())
 		return
 	}
 
+	// Parse sparse fieldset
+	fields, err := parseFields(r, balanceFields)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_FIELDS", err.Error())
+		return
+	}
+
 	// Log the request
 	h.logger.Info("GET /api/v1/balances",
 		zap.Any("filter", filter),
@@ -64,16 +84,37 @@ func (h *BalanceHandler) GetBalances(w http.ResponseWriter, r *http.Request) {
 	// Get balances from service
 	result, err := h.balanceService.GetBalances(ctx, *filter)
 	if err != nil {
+		if status, code, ok := queryCanceledStatus(err); ok {
+			h.logger.Debug("Balance query canceled", zap.Error(err))
+			h.writeErrorResponse(w, status, code, "Request ended before the balances were retrieved")
+			return
+		}
 		h.logger.Error("Failed to get balances", zap.Error(err), zap.Any("filter", filter))
 		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve balances")
 		return
 	}
 
+	// Project to the requested fields
+	var body interface{} = result
+	if fields != nil {
+		projected, err := projectFields(result.Balances, fields)
+		if err != nil {
+			h.logger.Error("Failed to project response fields", zap.Error(err))
+			h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve balances")
+			return
+		}
+		body = struct {
+			Balances            []map[string]json.RawMessage `json:"balances"`
+			Pagination          dto.PaginationResponse       `json:"pagination"`
+			UnpricedSecurityIDs []string                     `json:"unpricedSecurityIds,omitempty"`
+		}{Balances: projected, Pagination: result.Pagination, UnpricedSecurityIDs: result.UnpricedSecurityIDs}
+	}
+
 	// Write successful response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	if err := json.NewEncoder(w).Encode(result); err != nil {
+	if err := json.NewEncoder(w).Encode(body); err != nil {
 		h.logger.Error("Failed to encode response", zap.Error(err))
 		return
 	}
@@ -85,6 +126,88 @@ func (h *BalanceHandler) GetBalances(w http.ResponseWriter, r *http.Request) {
 		zap.Int("limit", result.Pagination.Limit))
 }
 
+// CountBalances counts the balances matching a filter
+// @Summary Count balances
+// @Description Count the balances matching the filters of GET /balances without retrieving them. Pagination and sorting parameters are ignored.
+// @Tags Balances
+// @Accept json
+// @Produce json
+// @Param portfolio_id query string false "Filter by portfolio ID (24 characters)"
+// @Param security_id query string false "Filter by security ID (24 characters). Use 'null' for cash balances"
+// @Param scope query string false "Balance scope" Enums(ALL,CASH_ONLY,SECURITIES_ONLY)
+// @Param cash_only query bool false "Legacy form of scope=CASH_ONLY; rejected if it contradicts scope"
+// @Param min_notional query number false "Only security positions whose quantityLong times reference price is at least this value"
+// @Param max_notional query number false "Only security positions whose quantityLong times reference price is at most this value"
+// @Param min_abs_long query number false "Only balances whose absolute quantityLong is at least this value"
+// @Param min_abs_short query number false "Only balances whose absolute quantityShort is at least this value"
+// @Success 200 {object} dto.CountResponse "Successfully counted balances"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request parameters"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Failure 504 {object} dto.ErrorResponse "Request timed out before the query completed"
+// @Security ApiKeyAuth
+// @Router /balances/count [get]
+func (h *BalanceHandler) CountBalances(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Parse query parameters
+	filter, err := parseBalanceFilter(r)
+	if err != nil {
+		h.logger.Error("Failed to parse balance filter", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_FILTER", err.Error())
+		return
+	}
+
+	// Log the request
+	h.logger.Info("GET /api/v1/balances/count",
+		zap.Any("filter", filter),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	count, err := h.balanceService.CountBalances(ctx, *filter)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid filter") {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_FILTER", err.Error())
+			return
+		}
+		if status, code, ok := queryCanceledStatus(err); ok {
+			h.logger.Debug("Balance count canceled", zap.Error(err))
+			h.writeErrorResponse(w, status, code, "Request ended before the balances were counted")
+			return
+		}
+		h.logger.Error("Failed to count balances", zap.Error(err), zap.Any("filter", filter))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to count balances")
+		return
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(dto.CountResponse{Count: count}); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully counted balances", zap.Int64("count", count))
+}
+
+// GetBalanceQuerySchema returns the filter and sort fields of the balance list
+// @Summary Get balance query schema
+// @Description List the filter and sort fields GET /balances accepts. Requests are validated against the same allowlist, so a sort field not listed here is rejected.
+// @Tags Balances
+// @Produce json
+// @Success 200 {object} dto.QuerySchemaDTO "Supported filter and sort fields"
+// @Security ApiKeyAuth
+// @Router /balances/schema [get]
+func (h *BalanceHandler) GetBalanceQuerySchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(dto.BalanceQuerySchema()); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
 // GetBalanceByID retrieves a specific balance by its ID
 // @Summary Get balance by ID
 // @Description Retrieve a specific balance record using its unique ID
@@ -101,19 +224,7 @@ func (h *BalanceHandler) GetBalances(w http.ResponseWriter, r *http.Request) {
 func (h *BalanceHandler) GetBalanceByID(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Parse balance ID from URL
-	idStr := chi.URLParam(r, "id")
-	if idStr == "" {
-		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_ID", "Balance ID is required")
-		return
-	}
-
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		h.logger.Error("Invalid balance ID", zap.String("id", idStr), zap.Error(err))
-		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Balance ID must be a valid integer")
-		return
-	}
+	id := middleware.PathID(r)
 
 	// Log the request
 	h.logger.Info("GET /api/v1/balance/{id}",
@@ -137,6 +248,7 @@ func (h *BalanceHandler) GetBalanceByID(w http.ResponseWriter, r *http.Request)
 
 	// Write successful response
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", balanceETag(balance.Version))
 	w.WriteHeader(http.StatusOK)
 
 	if err := json.NewEncoder(w).Encode(balance); err != nil {
@@ -147,16 +259,140 @@ func (h *BalanceHandler) GetBalanceByID(w http.ResponseWriter, r *http.Request)
 	h.logger.Info("Successfully retrieved balance", zap.Int64("id", id))
 }
 
+// UpdateBalance sets the quantities of a balance if it is still at the version the client read
+// @Summary Update a balance
+// @Description Set the long and/or short quantity of a balance. The update applies only if the balance is still at the expected version: send the ETag from GET /balance/{id} in If-Match (or If-Match: * for any version), or the version in the body. A stale version returns 412 with If-Match and 409 with a body version. The response carries the new ETag. The optional reason is logged with the update for audit and is required when balances.require_adjustment_reason is set.
+// @Tags Balances
+// @Accept json
+// @Produce json
+// @Param id path int true "Balance ID" minimum(1)
+// @Param If-Match header string false "ETag of the balance version the update is based on"
+// @Param update body dto.BalanceUpdateRequest true "New quantities; version may be omitted when If-Match is sent"
+// @Success 200 {object} dto.BalanceUpdateResponse "Balance updated"
+// @Failure 400 {object} dto.ErrorResponse "Invalid ID, JSON or validation failure"
+// @Failure 404 {object} dto.ErrorResponse "Balance not found"
+// @Failure 409 {object} dto.ErrorResponse "Body version is stale"
+// @Failure 412 {object} dto.ErrorResponse "If-Match does not match the current version"
+// @Failure 428 {object} dto.ErrorResponse "Neither If-Match nor a body version was sent"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /balance/{id} [put]
+func (h *BalanceHandler) UpdateBalance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := middleware.PathID(r)
+
+	// Log the request
+	h.logger.Info("PUT /api/v1/balance/{id}",
+		zap.Int64("id", id),
+		zap.String("if_match", r.Header.Get("If-Match")),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	var request dto.BalanceUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON format")
+		return
+	}
+
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch {
+	case ifMatch == "*":
+		// Any current version matches, so the update is unconditional
+		current, err := h.balanceService.GetBalance(ctx, id)
+		if err != nil {
+			h.writeBalanceUpdateError(w, id, err, false)
+			return
+		}
+		request.Version = current.Version
+	case ifMatch != "":
+		version, ok := parseBalanceETag(ifMatch)
+		if !ok {
+			h.writeErrorResponse(w, http.StatusPreconditionFailed, "PRECONDITION_FAILED", "If-Match does not match the current balance version")
+			return
+		}
+		if request.Version != 0 && request.Version != version {
+			h.writeErrorResponse(w, http.StatusBadRequest, "VERSION_MISMATCH", "Body version contradicts If-Match")
+			return
+		}
+		request.Version = version
+	case request.Version == 0:
+		h.writeErrorResponse(w, http.StatusPreconditionRequired, "PRECONDITION_REQUIRED", "Send If-Match with the balance ETag or the version in the body")
+		return
+	}
+
+	result, err := h.balanceService.UpdateBalance(ctx, id, request)
+	if err != nil {
+		h.writeBalanceUpdateError(w, id, err, ifMatch != "")
+		return
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", balanceETag(result.Balance.Version))
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully updated balance",
+		zap.Int64("id", id),
+		zap.Int("version", result.Balance.Version))
+}
+
+// writeBalanceUpdateError maps a balance update failure to its response. A stale version is a
+// failed precondition when the client sent If-Match and a conflict otherwise.
+func (h *BalanceHandler) writeBalanceUpdateError(w http.ResponseWriter, id int64, err error, conditional bool) {
+	var validationErr *services.BalanceUpdateValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		h.writeValidationErrorResponse(w, validationErr.Errors)
+	case repositories.IsNotFoundError(err) || strings.Contains(err.Error(), "not found"):
+		h.writeErrorResponse(w, http.StatusNotFound, "NOT_FOUND", "Balance not found")
+	case repositories.IsOptimisticLockError(err) && conditional:
+		h.writeErrorResponse(w, http.StatusPreconditionFailed, "PRECONDITION_FAILED", "If-Match does not match the current balance version")
+	case repositories.IsOptimisticLockError(err):
+		h.writeErrorResponse(w, http.StatusConflict, "VERSION_CONFLICT", "Balance was modified concurrently; reload and retry")
+	default:
+		h.logger.Error("Failed to update balance", zap.Error(err), zap.Int64("id", id))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to update balance")
+	}
+}
+
+// balanceETag returns the strong entity tag of a balance version
+func balanceETag(version int) string {
+	return fmt.Sprintf("%q", strconv.Itoa(version))
+}
+
+// parseBalanceETag returns the version of a strong balance entity tag. Weak tags never match
+// If-Match, which requires strong comparison.
+func parseBalanceETag(tag string) (int, bool) {
+	unquoted, err := strconv.Unquote(tag)
+	if err != nil || !strings.HasPrefix(tag, `"`) {
+		return 0, false
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
 // GetPortfolioSummary retrieves a comprehensive portfolio summary
 // @Summary Get portfolio summary
-// @Description Get a comprehensive summary of a portfolio including cash balance and all security positions with market values and statistics
+// @Description Get a comprehensive summary of a portfolio including cash balance and security positions with market values and statistics. Totals always cover the whole portfolio; the security positions are paginated. A portfolio without balances returns a zeroed summary unless balances.empty_summary_not_found is set.
 // @Tags Balances
 // @Accept json
 // @Produce json
 // @Param portfolioId path string true "Portfolio ID (24 characters)"
+// @Param offset query int false "Offset into the security positions (default: 0)" minimum(0)
+// @Param limit query int false "Number of security positions to return (default and maximum: balances.max_summary_securities)" minimum(1)
 // @Success 200 {object} dto.PortfolioSummaryDTO "Successfully retrieved portfolio summary"
-// @Failure 400 {object} dto.ErrorResponse "Invalid portfolio ID"
-// @Failure 404 {object} dto.ErrorResponse "Portfolio not found"
+// @Failure 400 {object} dto.ErrorResponse "Invalid portfolio ID or pagination parameters"
+// @Failure 404 {object} dto.ErrorResponse "Portfolio has no balances (only when balances.empty_summary_not_found is set)"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Security ApiKeyAuth
 // @Router /portfolios/{portfolioId}/summary [get]
@@ -170,14 +406,20 @@ func (h *BalanceHandler) GetPortfolioSummary(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// Parse pagination over the security positions
+	var pagination dto.PaginationRequest
+	pagination.Offset, pagination.Limit = middleware.Pagination(r)
+
 	// Log the request
 	h.logger.Info("GET /api/v1/portfolios/{portfolioId}/summary",
 		zap.String("portfolioId", portfolioID),
+		zap.Int("limit", pagination.Limit),
+		zap.Int("offset", pagination.Offset),
 		zap.String("user_agent", r.Header.Get("User-Agent")),
 		zap.String("remote_addr", r.RemoteAddr))
 
 	// Get portfolio summary from service
-	summary, err := h.balanceService.GetPortfolioSummary(ctx, portfolioID)
+	summary, err := h.balanceService.GetPortfolioSummary(ctx, portfolioID, pagination)
 	if err != nil {
 		// Check if portfolio not found
 		if strings.Contains(err.Error(), "not found") {
@@ -202,8 +444,495 @@ func (h *BalanceHandler) GetPortfolioSummary(w http.ResponseWriter, r *http.Requ
 	h.logger.Info("Successfully retrieved portfolio summary", zap.String("portfolioId", portfolioID))
 }
 
+// GetPortfolioSummaries retrieves the summaries of several portfolios in one request
+// @Summary Get portfolio summaries
+// @Description Get the summaries of the portfolios named in portfolio_ids, in the order given, or of a page of all portfolios ordered by ID when none are named. At most balances.max_summary_portfolios portfolios may be requested; each summary lists up to balances.max_summary_securities security positions. Named portfolios without balances get a zeroed summary unless balances.empty_summary_not_found is set, in which case they are left out.
+// @Tags Balances
+// @Accept json
+// @Produce json
+// @Param portfolio_ids query string false "Comma-separated portfolio IDs"
+// @Param offset query int false "Offset into all portfolios when portfolio_ids is not given (default: 0)" minimum(0)
+// @Param limit query int false "Number of portfolios when portfolio_ids is not given (default and maximum: balances.max_summary_portfolios)" minimum(1)
+// @Success 200 {object} dto.PortfolioSummariesResponse "Successfully retrieved portfolio summaries"
+// @Failure 400 {object} dto.ErrorResponse "Too many portfolios or invalid pagination parameters"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /portfolios/summaries [get]
+func (h *BalanceHandler) GetPortfolioSummaries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var filter dto.PortfolioSummaryFilter
+	if portfolioIDs := r.URL.Query().Get("portfolio_ids"); portfolioIDs != "" {
+		for _, portfolioID := range strings.Split(portfolioIDs, ",") {
+			if portfolioID = strings.TrimSpace(portfolioID); portfolioID != "" {
+				filter.PortfolioIDs = append(filter.PortfolioIDs, portfolioID)
+			}
+		}
+	}
+
+	filter.Pagination.Offset, filter.Pagination.Limit = middleware.Pagination(r)
+
+	h.logger.Info("GET /api/v1/portfolios/summaries",
+		zap.Int("portfolios", len(filter.PortfolioIDs)),
+		zap.Int("limit", filter.Pagination.Limit),
+		zap.Int("offset", filter.Pagination.Offset),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	summaries, err := h.balanceService.GetPortfolioSummaries(ctx, filter)
+	if err != nil {
+		var tooLarge *services.SummaryBatchTooLargeError
+		if errors.As(err, &tooLarge) {
+			h.writeErrorResponse(w, http.StatusBadRequest, "TOO_MANY_PORTFOLIOS",
+				fmt.Sprintf("At most %d portfolios may be summarized per request, %d were requested", tooLarge.Max, tooLarge.Requested))
+			return
+		}
+		h.logger.Error("Failed to get portfolio summaries", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve portfolio summaries")
+		return
+	}
+
+	response := dto.PortfolioSummariesResponse{
+		Summaries: summaries,
+		Count:     len(summaries),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully retrieved portfolio summaries", zap.Int("count", response.Count))
+}
+
+// maxPortfolioBalanceSecurities is the most securities one portfolio balances request may name
+const maxPortfolioBalanceSecurities = 1000
+
+// GetPortfolioBalances retrieves a portfolio's balances in a set of securities
+// @Summary Get portfolio balances by security
+// @Description Get the balances of one portfolio in the securities named in securityIds, ordered by security ID. Zero positions are included; securities the portfolio holds no balance in are left out, as is cash.
+// @Tags Balances
+// @Accept json
+// @Produce json
+// @Param portfolioId path string true "Portfolio ID (24 characters)"
+// @Param securityIds query string true "Comma-separated security IDs (at most 1000)"
+// @Success 200 {object} dto.BalanceListResponse "Successfully retrieved balances"
+// @Failure 400 {object} dto.ErrorResponse "Missing or too many security IDs"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Failure 504 {object} dto.ErrorResponse "Request timed out before the query completed"
+// @Security ApiKeyAuth
+// @Router /portfolios/{portfolioId}/balances [get]
+func (h *BalanceHandler) GetPortfolioBalances(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Parse portfolio ID from URL
+	portfolioID := chi.URLParam(r, "portfolioId")
+	if portfolioID == "" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_PORTFOLIO_ID", "Portfolio ID is required")
+		return
+	}
+
+	// Parse the securities, dropping blanks and repeats
+	var securityIDs []string
+	seen := make(map[string]bool)
+	for _, securityID := range strings.Split(r.URL.Query().Get("securityIds"), ",") {
+		if securityID = strings.TrimSpace(securityID); securityID != "" && !seen[securityID] {
+			seen[securityID] = true
+			securityIDs = append(securityIDs, securityID)
+		}
+	}
+	if len(securityIDs) == 0 {
+		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_SECURITY_IDS", "securityIds must name at least one security")
+		return
+	}
+	if len(securityIDs) > maxPortfolioBalanceSecurities {
+		h.writeErrorResponse(w, http.StatusBadRequest, "TOO_MANY_SECURITIES",
+			fmt.Sprintf("At most %d securities may be requested, %d were named", maxPortfolioBalanceSecurities, len(securityIDs)))
+		return
+	}
+
+	h.logger.Info("GET /api/v1/portfolios/{portfolioId}/balances",
+		zap.String("portfolioId", portfolioID),
+		zap.Int("securities", len(securityIDs)),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	// A portfolio has at most one balance per security, so one page holds every match
+	filter := dto.BalanceFilter{
+		PortfolioID: &portfolioID,
+		SecurityIDs: securityIDs,
+		Pagination:  dto.PaginationRequest{Limit: len(securityIDs)},
+		SortBy:      []dto.SortRequest{{Field: "security_id", Direction: "asc"}},
+	}
+
+	result, err := h.balanceService.GetBalances(ctx, filter)
+	if err != nil {
+		if message, ok := listFilterTooLargeMessage(err); ok {
+			h.writeErrorResponse(w, http.StatusBadRequest, "FILTER_LIST_TOO_LARGE", message)
+			return
+		}
+		if status, code, ok := queryCanceledStatus(err); ok {
+			h.logger.Debug("Portfolio balance query canceled", zap.Error(err))
+			h.writeErrorResponse(w, status, code, "Request ended before the balances were retrieved")
+			return
+		}
+		h.logger.Error("Failed to get portfolio balances", zap.Error(err), zap.String("portfolioId", portfolioID))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve balances")
+		return
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully retrieved portfolio balances",
+		zap.String("portfolioId", portfolioID),
+		zap.Int("count", len(result.Balances)))
+}
+
+// GetPortfolioSecurityBalance retrieves a portfolio's balance in one security
+// @Summary Get portfolio security balance
+// @Description Get the balance of one portfolio in one security. A portfolio without a balance in the security gets a zeroed balance with ID 0 unless balances.missing_balance_not_found is set.
+// @Tags Balances
+// @Accept json
+// @Produce json
+// @Param portfolioId path string true "Portfolio ID (24 characters)"
+// @Param securityId path string true "Security ID (24 characters)"
+// @Success 200 {object} dto.BalanceDTO "Successfully retrieved balance"
+// @Failure 400 {object} dto.ErrorResponse "Missing or invalid portfolio or security ID"
+// @Failure 404 {object} dto.ErrorResponse "Balance not found (only when balances.missing_balance_not_found is set)"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /portfolios/{portfolioId}/securities/{securityId}/balance [get]
+func (h *BalanceHandler) GetPortfolioSecurityBalance(w http.ResponseWriter, r *http.Request) {
+	securityID := chi.URLParam(r, "securityId")
+	if securityID == "" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_SECURITY_ID", "Security ID is required")
+		return
+	}
+	h.writePortfolioBalance(w, r, &securityID)
+}
+
+// GetPortfolioCashBalance retrieves a portfolio's cash balance
+// @Summary Get portfolio cash balance
+// @Description Get the cash balance of one portfolio. A portfolio without a cash balance gets a zeroed balance with ID 0 unless balances.missing_balance_not_found is set.
+// @Tags Balances
+// @Accept json
+// @Produce json
+// @Param portfolioId path string true "Portfolio ID (24 characters)"
+// @Success 200 {object} dto.BalanceDTO "Successfully retrieved balance"
+// @Failure 400 {object} dto.ErrorResponse "Missing or invalid portfolio ID"
+// @Failure 404 {object} dto.ErrorResponse "Balance not found (only when balances.missing_balance_not_found is set)"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /portfolios/{portfolioId}/cash/balance [get]
+func (h *BalanceHandler) GetPortfolioCashBalance(w http.ResponseWriter, r *http.Request) {
+	h.writePortfolioBalance(w, r, nil)
+}
+
+// writePortfolioBalance writes the portfolio's balance in a security, or its cash balance when
+// securityID is nil
+func (h *BalanceHandler) writePortfolioBalance(w http.ResponseWriter, r *http.Request, securityID *string) {
+	ctx := r.Context()
+
+	// Parse portfolio ID from URL
+	portfolioID := chi.URLParam(r, "portfolioId")
+	if portfolioID == "" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_PORTFOLIO_ID", "Portfolio ID is required")
+		return
+	}
+
+	// Log the request
+	h.logger.Info("GET "+r.URL.Path,
+		zap.String("portfolioId", portfolioID),
+		zap.Bool("cash", securityID == nil),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	balance, err := h.balanceService.GetPortfolioBalance(ctx, portfolioID, securityID)
+	if err != nil {
+		var invalidID *services.InvalidBalanceIDError
+		if errors.As(err, &invalidID) {
+			if invalidID.Field == "securityId" {
+				h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_SECURITY_ID", "Security ID must be exactly 24 characters")
+			} else {
+				h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_PORTFOLIO_ID", "Portfolio ID must be exactly 24 characters")
+			}
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			h.writeErrorResponse(w, http.StatusNotFound, "NOT_FOUND", "Balance not found")
+			return
+		}
+		h.logger.Error("Failed to get portfolio balance", zap.Error(err), zap.String("portfolioId", portfolioID))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve balance")
+		return
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(balance); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+}
+
+// GetPortfolioExposure retrieves the aggregate long/short exposure of a portfolio
+// @Summary Get portfolio exposure
+// @Description Get total long and short quantities with gross (long+short) and net (long-short) exposure over a portfolio's security positions. Cash is excluded. Value terms use the latest processed transaction price of each security and are omitted when no position can be priced.
+// @Tags Balances
+// @Accept json
+// @Produce json
+// @Param portfolioId path string true "Portfolio ID (24 characters)"
+// @Success 200 {object} dto.PortfolioExposureDTO "Successfully calculated portfolio exposure"
+// @Failure 400 {object} dto.ErrorResponse "Invalid portfolio ID"
+// @Failure 404 {object} dto.ErrorResponse "Portfolio not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /portfolios/{portfolioId}/exposure [get]
+func (h *BalanceHandler) GetPortfolioExposure(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Parse portfolio ID from URL
+	portfolioID := chi.URLParam(r, "portfolioId")
+	if portfolioID == "" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_PORTFOLIO_ID", "Portfolio ID is required")
+		return
+	}
+
+	// Log the request
+	h.logger.Info("GET /api/v1/portfolios/{portfolioId}/exposure",
+		zap.String("portfolioId", portfolioID),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	exposure, err := h.balanceService.GetPortfolioExposure(ctx, portfolioID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			h.logger.Warn("Portfolio not found", zap.String("portfolioId", portfolioID))
+			h.writeErrorResponse(w, http.StatusNotFound, "NOT_FOUND", "Portfolio not found")
+			return
+		}
+		h.logger.Error("Failed to get portfolio exposure", zap.Error(err), zap.String("portfolioId", portfolioID))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to calculate portfolio exposure")
+		return
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(exposure); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully calculated portfolio exposure", zap.String("portfolioId", portfolioID))
+}
+
+const (
+	defaultTopPositionsLimit = 20
+	maxTopPositionsLimit     = 1000
+)
+
+// GetTopPositions retrieves the largest security positions across all portfolios
+// @Summary Get top positions
+// @Description Get the largest security positions across all portfolios, ordered descending by long quantity, short quantity or absolute net quantity (|long - short|), with ties broken by balance ID. Cash balances are excluded.
+// @Tags Balances
+// @Accept json
+// @Produce json
+// @Param by query string false "Ranking quantity (default: long)" Enums(long,short,absolute)
+// @Param limit query int false "Number of positions to return (default: 20, max: 1000)" minimum(1) maximum(1000)
+// @Success 200 {object} dto.TopPositionsResponse "Successfully retrieved top positions"
+// @Failure 400 {object} dto.ErrorResponse "Invalid ranking or limit"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /positions/top [get]
+func (h *BalanceHandler) GetTopPositions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Log the request
+	h.logger.Info("GET /api/v1/positions/top",
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	by := repositories.PositionRankingLong
+	if byStr := r.URL.Query().Get("by"); byStr != "" {
+		by = repositories.PositionRanking(strings.ToLower(byStr))
+		if !by.IsValid() {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_RANKING", "by must be one of long, short or absolute")
+			return
+		}
+	}
+
+	limit := defaultTopPositionsLimit
+	if parsed, ok := middleware.IntParam(r, "limit"); ok {
+		limit = int(parsed)
+	}
+
+	result, err := h.balanceService.GetTopPositions(ctx, by, limit)
+	if err != nil {
+		h.logger.Error("Failed to get top positions", zap.Error(err), zap.String("by", by.String()))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve top positions")
+		return
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully retrieved top positions",
+		zap.String("by", by.String()),
+		zap.Int("count", len(result.Positions)))
+}
+
+// GetZeroBalances lists the zero balances that are candidates for compaction
+// @Summary Get zero balance compaction candidates
+// @Description Review the security balances whose long and short quantities are both zero before they are compacted, oldest lastUpdated first. With olderThan only balances left unchanged for at least that long are listed; set it to compaction.minimum_age to see what the next compaction pass may delete. Cash balances are never compacted and are not listed.
+// @Tags Balances
+// @Accept json
+// @Produce json
+// @Param olderThan query string false "Minimum time since the balance was last updated, as a duration such as 720h (default: any age)"
+// @Param offset query int false "Pagination offset (default: 0)" minimum(0)
+// @Param limit query int false "Number of records to return (default: 50, max: 1000); a larger limit is clamped and reported in pagination.limitClamped" minimum(1)
+// @Success 200 {object} dto.BalanceListResponse "Successfully retrieved zero balances"
+// @Failure 400 {object} dto.ErrorResponse "Invalid age or pagination parameters"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /balances/zero [get]
+func (h *BalanceHandler) GetZeroBalances(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var olderThan time.Duration
+	if olderThanStr := r.URL.Query().Get("olderThan"); olderThanStr != "" {
+		parsed, err := time.ParseDuration(olderThanStr)
+		if err != nil || parsed < 0 {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_PARAMETER", "olderThan must be a non-negative duration such as 720h")
+			return
+		}
+		olderThan = parsed
+	}
+
+	var pagination dto.PaginationRequest
+	pagination.Offset, pagination.Limit = middleware.Pagination(r)
+
+	// Log the request
+	h.logger.Info("GET /api/v1/balances/zero",
+		zap.Duration("olderThan", olderThan),
+		zap.Int("limit", pagination.Limit),
+		zap.Int("offset", pagination.Offset),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	result, err := h.balanceService.GetZeroBalances(ctx, olderThan, pagination)
+	if err != nil {
+		if status, code, ok := queryCanceledStatus(err); ok {
+			h.logger.Debug("Zero balance query canceled", zap.Error(err))
+			h.writeErrorResponse(w, status, code, "Request ended before the zero balances were retrieved")
+			return
+		}
+		h.logger.Error("Failed to get zero balances", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve zero balances")
+		return
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully retrieved zero balances",
+		zap.Int("count", len(result.Balances)),
+		zap.Int64("total", result.Pagination.Total))
+}
+
+// AdjustBalance applies a manual balance adjustment
+// @Summary Adjust a balance
+// @Description Record a manual long/short adjustment with its reason and operator in the adjustment ledger and apply it to the portfolio/security balance in one database transaction. Omit securityId to adjust cash. Requests are idempotent on adjustmentKey: replaying a recorded key returns the stored adjustment without changing the balance again. When balances.adjustment_key_max_age is set, a request carrying a key older than that is applied as a new adjustment, and a background job releases such keys. When expectedVersion is set it must match the current balance version (0 if the balance does not exist yet).
+// @Tags Balances
+// @Accept json
+// @Produce json
+// @Param adjustment body dto.BalanceAdjustmentRequest true "Balance adjustment"
+// @Success 201 {object} dto.BalanceAdjustmentResponse "Adjustment applied"
+// @Success 200 {object} dto.BalanceAdjustmentResponse "Adjustment key already recorded; nothing changed"
+// @Failure 400 {object} dto.ErrorResponse "Invalid JSON or validation failure"
+// @Failure 409 {object} dto.ErrorResponse "Adjustment key reused for a different adjustment, or balance version conflict"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /balances/adjustments [post]
+func (h *BalanceHandler) AdjustBalance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Log the request
+	h.logger.Info("POST /api/v1/balances/adjustments",
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	var request dto.BalanceAdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON format")
+		return
+	}
+
+	result, err := h.balanceService.AdjustBalance(ctx, request)
+	if err != nil {
+		var validationErr *services.AdjustmentValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			h.writeValidationErrorResponse(w, validationErr.Errors)
+		case errors.Is(err, services.ErrAdjustmentKeyConflict):
+			h.writeErrorResponse(w, http.StatusConflict, "ADJUSTMENT_KEY_CONFLICT", "Adjustment key was already used for a different adjustment")
+		case repositories.IsOptimisticLockError(err):
+			h.writeErrorResponse(w, http.StatusConflict, "VERSION_CONFLICT", "Balance was modified concurrently; reload and retry")
+		default:
+			h.logger.Error("Failed to adjust balance", zap.Error(err), zap.String("adjustmentKey", request.AdjustmentKey))
+			h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to adjust balance")
+		}
+		return
+	}
+
+	status := http.StatusCreated
+	if !result.Applied {
+		status = http.StatusOK
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Balance adjustment handled",
+		zap.String("adjustmentKey", request.AdjustmentKey),
+		zap.Int64("balanceId", result.Balance.ID),
+		zap.Bool("applied", result.Applied))
+}
+
 // parseBalanceFilter parses query parameters into BalanceFilter
-func (h *BalanceHandler) parseBalanceFilter(r *http.Request) (*dto.BalanceFilter, error) {
+func parseBalanceFilter(r *http.Request) (*dto.BalanceFilter, error) {
 	filter := &dto.BalanceFilter{}
 
 	// Portfolio ID
@@ -211,16 +940,70 @@ func (h *BalanceHandler) parseBalanceFilter(r *http.Request) (*dto.BalanceFilter
 		filter.PortfolioID = &portfolioID
 	}
 
-	// Security ID
+	// Security ID ("null" selects cash balances)
 	if securityID := r.URL.Query().Get("security_id"); securityID != "" {
-		filter.SecurityID = &securityID
+		if strings.EqualFold(securityID, "null") {
+			cashOnly := true
+			filter.CashOnly = &cashOnly
+		} else {
+			filter.SecurityID = &securityID
+		}
 	}
 
-	// Cash only filter
+	// Cash vs security scope
+	if scope := r.URL.Query().Get("scope"); scope != "" {
+		filter.Scope = dto.BalanceScope(strings.ToUpper(scope))
+	}
+
+	// Legacy cash only filter
 	if cashOnlyStr := r.URL.Query().Get("cash_only"); cashOnlyStr != "" {
-		if cashOnly, err := strconv.ParseBool(cashOnlyStr); err == nil {
-			filter.CashOnly = &cashOnly
+		cashOnly, err := strconv.ParseBool(cashOnlyStr)
+		if err != nil {
+			return nil, fmt.Errorf("cash_only must be a boolean")
+		}
+		if filter.CashOnly != nil && *filter.CashOnly != cashOnly {
+			return nil, fmt.Errorf("cash_only=%t contradicts security_id=null", cashOnly)
 		}
+		filter.CashOnly = &cashOnly
+	}
+
+	// Notional thresholds
+	if minNotionalStr := r.URL.Query().Get("min_notional"); minNotionalStr != "" {
+		minNotional, err := decimal.NewFromString(minNotionalStr)
+		if err != nil {
+			return nil, fmt.Errorf("min_notional must be a decimal number")
+		}
+		filter.MinNotional = &minNotional
+	}
+	if maxNotionalStr := r.URL.Query().Get("max_notional"); maxNotionalStr != "" {
+		maxNotional, err := decimal.NewFromString(maxNotionalStr)
+		if err != nil {
+			return nil, fmt.Errorf("max_notional must be a decimal number")
+		}
+		filter.MaxNotional = &maxNotional
+	}
+	if filter.MinNotional != nil && filter.MaxNotional != nil && filter.MinNotional.GreaterThan(*filter.MaxNotional) {
+		return nil, fmt.Errorf("min_notional must not exceed max_notional")
+	}
+
+	// Magnitude thresholds
+	if minAbsLongStr := r.URL.Query().Get("min_abs_long"); minAbsLongStr != "" {
+		minAbsLong, err := decimal.NewFromString(minAbsLongStr)
+		if err != nil || minAbsLong.IsNegative() {
+			return nil, fmt.Errorf("min_abs_long must be a non-negative decimal number")
+		}
+		filter.MinAbsQuantityLong = &minAbsLong
+	}
+	if minAbsShortStr := r.URL.Query().Get("min_abs_short"); minAbsShortStr != "" {
+		minAbsShort, err := decimal.NewFromString(minAbsShortStr)
+		if err != nil || minAbsShort.IsNegative() {
+			return nil, fmt.Errorf("min_abs_short must be a non-negative decimal number")
+		}
+		filter.MinAbsQuantityShort = &minAbsShort
+	}
+
+	if _, err := filter.ResolveScope(); err != nil {
+		return nil, err
 	}
 
 	// Zero balances filter
@@ -249,17 +1032,7 @@ func (h *BalanceHandler) parseBalanceFilter(r *http.Request) (*dto.BalanceFilter
 	}
 
 	// Pagination
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
-		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
-			filter.Pagination.Offset = offset
-		}
-	}
-
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 && limit <= 1000 {
-			filter.Pagination.Limit = limit
-		}
-	}
+	filter.Pagination.Offset, filter.Pagination.Limit = middleware.Pagination(r)
 
 	// Default pagination values
 	if filter.Pagination.Limit == 0 {
@@ -267,21 +1040,14 @@ func (h *BalanceHandler) parseBalanceFilter(r *http.Request) (*dto.BalanceFilter
 	}
 
 	// Sort fields
+	schema := dto.BalanceQuerySchema()
 	if sortBy := r.URL.Query().Get("sortby"); sortBy != "" {
 		// Parse comma-separated sort fields
 		fields := strings.Split(sortBy, ",")
 
-		validSortFields := map[string]bool{
-			"portfolio_id":   true,
-			"security_id":    true,
-			"last_updated":   true,
-			"quantity_long":  true,
-			"quantity_short": true,
-		}
-
 		for _, field := range fields {
 			field = strings.TrimSpace(field)
-			if validSortFields[field] {
+			if schema.IsSortable(field) {
 				filter.SortBy = append(filter.SortBy, dto.SortRequest{
 					Field:     field,
 					Direction: "asc", // Default direction
@@ -292,15 +1058,31 @@ func (h *BalanceHandler) parseBalanceFilter(r *http.Request) (*dto.BalanceFilter
 
 	// Default sort if none specified
 	if len(filter.SortBy) == 0 {
-		filter.SortBy = []dto.SortRequest{
-			{Field: "portfolio_id", Direction: "asc"},
-			{Field: "security_id", Direction: "asc"},
-		}
+		filter.SortBy = schema.DefaultSort
 	}
 
 	return filter, nil
 }
 
+// writeValidationErrorResponse writes a validation failure with the individual field errors as details
+func (h *BalanceHandler) writeValidationErrorResponse(w http.ResponseWriter, validationErrors []dto.ValidationError) {
+	errorResp := dto.ErrorResponse{
+		Error: dto.ErrorDetail{
+			Code:      "VALIDATION_FAILED",
+			Message:   "Balance request validation failed",
+			Details:   map[string]interface{}{"errors": validationErrors},
+			Timestamp: time.Now(),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		h.logger.Error("Failed to write error response", zap.Error(err))
+	}
+}
+
 // writeErrorResponse writes a standardized error response
 func (h *BalanceHandler) writeErrorResponse(w http.ResponseWriter, statusCode int, errorCode, message string) {
 	errorResp := dto.ErrorResponse{
@@ -0,0 +1,781 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/api/middleware"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// withParams serves a handler behind the parameter validation its route registers, which hands
+// the handler its validated IDs and pagination
+func withParams(handler http.HandlerFunc, rules ...middleware.ParamRule) http.Handler {
+	return middleware.ValidateParams(rules...)(handler)
+}
+
+// stubTopPositionsService records the requested ranking and returns positions in the repository's order
+type stubTopPositionsService struct {
+	services.BalanceService
+	by    repositories.PositionRanking
+	limit int
+	calls int
+}
+
+func (s *stubTopPositionsService) GetTopPositions(ctx context.Context, by repositories.PositionRanking, limit int) (*dto.TopPositionsResponse, error) {
+	s.calls++
+	s.by = by
+	s.limit = limit
+
+	securityA := "SECURITYA000000000000000"
+	securityB := "SECURITYB000000000000000"
+	return &dto.TopPositionsResponse{
+		By:    by.String(),
+		Limit: limit,
+		Positions: []dto.BalanceDTO{
+			{ID: 2, PortfolioID: "PORTFOLIO123456789012345", SecurityID: &securityB, QuantityLong: decimal.NewFromInt(900)},
+			{ID: 1, PortfolioID: "PORTFOLIO123456789012345", SecurityID: &securityA, QuantityLong: decimal.NewFromInt(400)},
+		},
+	}, nil
+}
+
+func TestBalanceHandler_GetTopPositions(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedCode  int
+		expectedBy    repositories.PositionRanking
+		expectedLimit int
+	}{
+		{name: "Defaults", query: "", expectedCode: http.StatusOK, expectedBy: repositories.PositionRankingLong, expectedLimit: 20},
+		{name: "Long", query: "?by=long&limit=5", expectedCode: http.StatusOK, expectedBy: repositories.PositionRankingLong, expectedLimit: 5},
+		{name: "Short", query: "?by=short", expectedCode: http.StatusOK, expectedBy: repositories.PositionRankingShort, expectedLimit: 20},
+		{name: "Absolute, case-insensitive", query: "?by=ABSOLUTE&limit=1000", expectedCode: http.StatusOK, expectedBy: repositories.PositionRankingAbsolute, expectedLimit: 1000},
+		{name: "Unknown ranking", query: "?by=gross", expectedCode: http.StatusBadRequest},
+		{name: "Limit too large", query: "?limit=1001", expectedCode: http.StatusBadRequest},
+		{name: "Limit not a number", query: "?limit=many", expectedCode: http.StatusBadRequest},
+		{name: "Zero limit", query: "?limit=0", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubTopPositionsService{}
+			handler := NewBalanceHandler(svc, logger.NewNoop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/positions/top"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			withParams(handler.GetTopPositions, middleware.QueryInt("limit", 1, maxTopPositionsLimit)).ServeHTTP(rec, req)
+
+			require.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode != http.StatusOK {
+				assert.Zero(t, svc.calls, "invalid requests must not reach the service")
+				return
+			}
+
+			assert.Equal(t, tt.expectedBy, svc.by)
+			assert.Equal(t, tt.expectedLimit, svc.limit)
+
+			var body dto.TopPositionsResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tt.expectedBy.String(), body.By)
+			require.Len(t, body.Positions, 2)
+			assert.Equal(t, int64(2), body.Positions[0].ID, "positions keep the ranked order")
+			assert.Equal(t, int64(1), body.Positions[1].ID)
+		})
+	}
+}
+
+func TestBalanceHandler_GetBalances_NotionalParams(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+	}{
+		{name: "Both bounds", query: "?min_notional=1000.50&max_notional=5000", expectedCode: http.StatusOK},
+		{name: "Not a number", query: "?min_notional=lots", expectedCode: http.StatusBadRequest},
+		{name: "Inverted bounds", query: "?min_notional=5000&max_notional=1000", expectedCode: http.StatusBadRequest},
+		{name: "Cash-only scope", query: "?min_notional=1000&scope=CASH_ONLY", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubBalanceService{}
+			handler := NewBalanceHandler(svc, logger.NewNoop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/balances"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			handler.GetBalances(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode != http.StatusOK {
+				assert.Zero(t, svc.calls)
+			}
+		})
+	}
+}
+
+func TestBalanceHandler_GetBalances_AbsoluteQuantityParams(t *testing.T) {
+	tests := []struct {
+		name             string
+		query            string
+		expectedCode     int
+		expectedMinLong  string
+		expectedMinShort string
+	}{
+		{name: "No thresholds", query: "", expectedCode: http.StatusOK},
+		{name: "Long threshold", query: "?min_abs_long=0.01", expectedCode: http.StatusOK, expectedMinLong: "0.01"},
+		{name: "Both thresholds", query: "?min_abs_long=100&min_abs_short=5", expectedCode: http.StatusOK, expectedMinLong: "100", expectedMinShort: "5"},
+		{name: "Not a number", query: "?min_abs_long=dust", expectedCode: http.StatusBadRequest},
+		{name: "Negative", query: "?min_abs_short=-1", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubBalanceService{}
+			handler := NewBalanceHandler(svc, logger.NewNoop())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/balances"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			handler.GetBalances(rec, req)
+
+			require.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedCode != http.StatusOK {
+				assert.Zero(t, svc.calls)
+				assert.Contains(t, rec.Body.String(), "non-negative decimal number")
+				return
+			}
+
+			if tt.expectedMinLong == "" {
+				assert.Nil(t, svc.filter.MinAbsQuantityLong)
+			} else {
+				require.NotNil(t, svc.filter.MinAbsQuantityLong)
+				assert.Equal(t, tt.expectedMinLong, svc.filter.MinAbsQuantityLong.String())
+			}
+			if tt.expectedMinShort == "" {
+				assert.Nil(t, svc.filter.MinAbsQuantityShort)
+			} else {
+				require.NotNil(t, svc.filter.MinAbsQuantityShort)
+				assert.Equal(t, tt.expectedMinShort, svc.filter.MinAbsQuantityShort.String())
+			}
+		})
+	}
+}
+
+func TestBalanceHandler_GetBalances_ScopeParams(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedScope dto.BalanceScope
+		expectError   bool
+	}{
+		{name: "No scope", query: "", expectedScope: dto.BalanceScopeAll},
+		{name: "All", query: "?scope=all", expectedScope: dto.BalanceScopeAll},
+		{name: "Cash only", query: "?scope=cash_only", expectedScope: dto.BalanceScopeCashOnly},
+		{name: "Securities only", query: "?scope=SECURITIES_ONLY", expectedScope: dto.BalanceScopeSecuritiesOnly},
+		{name: "Legacy cash_only", query: "?cash_only=true", expectedScope: dto.BalanceScopeCashOnly},
+		{name: "Legacy null security", query: "?security_id=null", expectedScope: dto.BalanceScopeCashOnly},
+		{name: "Agreeing legacy flag", query: "?scope=securities_only&cash_only=false", expectedScope: dto.BalanceScopeSecuritiesOnly},
+		{name: "Unknown scope", query: "?scope=cash", expectError: true},
+		{name: "cash_only=true with securities only", query: "?scope=securities_only&cash_only=true", expectError: true},
+		{name: "cash_only=false with cash only", query: "?scope=cash_only&cash_only=false", expectError: true},
+		{name: "Null security with cash_only=false", query: "?security_id=null&cash_only=false", expectError: true},
+		{name: "Security with cash only", query: "?scope=cash_only&security_id=SECURITY1234567890123456", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubBalanceService{}
+			handler := NewBalanceHandler(svc, logger.NewNoop())
+
+			rec := httptest.NewRecorder()
+			handler.GetBalances(rec, httptest.NewRequest(http.MethodGet, "/api/v1/balances"+tt.query, nil))
+
+			if tt.expectError {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Zero(t, svc.calls, "a contradictory filter never reaches the query")
+				return
+			}
+			require.Equal(t, http.StatusOK, rec.Code)
+			scope, err := svc.filter.ResolveScope()
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedScope, scope)
+		})
+	}
+}
+
+func TestBalanceHandler_GetBalances_LimitParam(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedLimit int
+	}{
+		{name: "Default", query: "", expectedLimit: 50},
+		{name: "Within the maximum", query: "?limit=1000", expectedLimit: 1000},
+		{name: "Over the maximum is left to the service to clamp", query: "?limit=5000", expectedLimit: 5000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubBalanceService{}
+			handler := NewBalanceHandler(svc, logger.NewNoop())
+
+			rec := httptest.NewRecorder()
+			withParams(handler.GetBalances, middleware.PaginationParams(0)...).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/balances"+tt.query, nil))
+
+			require.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.expectedLimit, svc.filter.Pagination.Limit)
+		})
+	}
+}
+
+// stubSummariesService enforces a portfolio cap and echoes one zeroed summary per requested portfolio
+type stubSummariesService struct {
+	services.BalanceService
+	maxPortfolios int
+	filter        dto.PortfolioSummaryFilter
+}
+
+func (s *stubSummariesService) GetPortfolioSummaries(ctx context.Context, filter dto.PortfolioSummaryFilter) ([]dto.PortfolioSummaryDTO, error) {
+	s.filter = filter
+	if len(filter.PortfolioIDs) > s.maxPortfolios {
+		return nil, &services.SummaryBatchTooLargeError{Requested: len(filter.PortfolioIDs), Max: s.maxPortfolios}
+	}
+
+	summaries := make([]dto.PortfolioSummaryDTO, 0, len(filter.PortfolioIDs))
+	for _, portfolioID := range filter.PortfolioIDs {
+		summaries = append(summaries, dto.PortfolioSummaryDTO{PortfolioID: portfolioID, Securities: []dto.SecurityPositionDTO{}})
+	}
+	return summaries, nil
+}
+
+func TestBalanceHandler_GetPortfolioSummaries(t *testing.T) {
+	t.Run("Returns the requested summaries", func(t *testing.T) {
+		svc := &stubSummariesService{maxPortfolios: 2}
+		handler := NewBalanceHandler(svc, logger.NewNoop())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/portfolios/summaries?portfolio_ids=PORTFOLIO000000000000001,%20PORTFOLIO000000000000002,", nil)
+		rec := httptest.NewRecorder()
+		handler.GetPortfolioSummaries(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"PORTFOLIO000000000000001", "PORTFOLIO000000000000002"}, svc.filter.PortfolioIDs)
+
+		var body dto.PortfolioSummariesResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, 2, body.Count)
+		require.Len(t, body.Summaries, 2)
+		assert.Equal(t, "PORTFOLIO000000000000001", body.Summaries[0].PortfolioID)
+	})
+
+	t.Run("Rejects too many portfolios", func(t *testing.T) {
+		svc := &stubSummariesService{maxPortfolios: 2}
+		handler := NewBalanceHandler(svc, logger.NewNoop())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/portfolios/summaries?portfolio_ids=PORTFOLIO000000000000001,PORTFOLIO000000000000002,PORTFOLIO000000000000003", nil)
+		rec := httptest.NewRecorder()
+		handler.GetPortfolioSummaries(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		var body dto.ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "TOO_MANY_PORTFOLIOS", body.Error.Code)
+	})
+
+	t.Run("Rejects invalid pagination", func(t *testing.T) {
+		handler := NewBalanceHandler(&stubSummariesService{maxPortfolios: 2}, logger.NewNoop())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/portfolios/summaries?limit=0", nil)
+		rec := httptest.NewRecorder()
+		withParams(handler.GetPortfolioSummaries, middleware.PaginationParams(0)...).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+// portfolioBalancesService serves GetBalances from fixed balances, applying the portfolio and
+// security filters
+type portfolioBalancesService struct {
+	services.BalanceService
+	balances []dto.BalanceDTO
+	filter   dto.BalanceFilter
+	err      error
+}
+
+func (s *portfolioBalancesService) GetBalances(ctx context.Context, filter dto.BalanceFilter) (*dto.BalanceListResponse, error) {
+	s.filter = filter
+	if s.err != nil {
+		return nil, s.err
+	}
+	var matching []dto.BalanceDTO
+	for _, balance := range s.balances {
+		if filter.PortfolioID != nil && balance.PortfolioID != *filter.PortfolioID {
+			continue
+		}
+		if balance.SecurityID == nil || !containsID(filter.SecurityIDs, *balance.SecurityID) {
+			continue
+		}
+		matching = append(matching, balance)
+	}
+	return &dto.BalanceListResponse{
+		Balances:   matching,
+		Pagination: dto.NewPaginationResponse(filter.Pagination.Limit, 0, int64(len(matching))),
+	}, nil
+}
+
+func containsID(ids []string, id string) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBalanceHandler_GetPortfolioBalances(t *testing.T) {
+	const portfolioID = "PORTFOLIO000000000000001"
+	securityA, securityB, securityC := "SECURITY0000000000000001", "SECURITY0000000000000002", "SECURITY0000000000000003"
+	balances := []dto.BalanceDTO{
+		{ID: 1, PortfolioID: portfolioID, QuantityLong: decimal.NewFromInt(5000)},
+		{ID: 2, PortfolioID: portfolioID, SecurityID: &securityA, QuantityLong: decimal.NewFromInt(100)},
+		{ID: 3, PortfolioID: portfolioID, SecurityID: &securityB, QuantityLong: decimal.Zero},
+		{ID: 4, PortfolioID: portfolioID, SecurityID: &securityC, QuantityLong: decimal.NewFromInt(300)},
+		{ID: 5, PortfolioID: "PORTFOLIO000000000000002", SecurityID: &securityA, QuantityLong: decimal.NewFromInt(700)},
+	}
+	serve := func(svc *portfolioBalancesService, target string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Get("/api/v1/portfolios/{portfolioId}/balances", NewBalanceHandler(svc, logger.NewNoop()).GetPortfolioBalances)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	t.Run("Returns only the requested securities of the portfolio", func(t *testing.T) {
+		svc := &portfolioBalancesService{balances: balances}
+		rec := serve(svc, "/api/v1/portfolios/"+portfolioID+"/balances?securityIds="+securityB+",%20"+securityA+","+securityA)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NotNil(t, svc.filter.PortfolioID)
+		assert.Equal(t, portfolioID, *svc.filter.PortfolioID)
+		assert.Equal(t, []string{securityB, securityA}, svc.filter.SecurityIDs)
+		assert.Equal(t, 2, svc.filter.Pagination.Limit)
+		assert.Equal(t, []dto.SortRequest{{Field: "security_id", Direction: "asc"}}, svc.filter.SortBy)
+
+		var body dto.BalanceListResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		ids := make([]int64, len(body.Balances))
+		for i, balance := range body.Balances {
+			ids[i] = balance.ID
+		}
+		assert.Equal(t, []int64{2, 3}, ids, "the zero position is included, cash and other portfolios are not")
+	})
+
+	t.Run("Requires security IDs", func(t *testing.T) {
+		svc := &portfolioBalancesService{balances: balances}
+		rec := serve(svc, "/api/v1/portfolios/"+portfolioID+"/balances?securityIds=,")
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		var body dto.ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "MISSING_SECURITY_IDS", body.Error.Code)
+		assert.Nil(t, svc.filter.SecurityIDs)
+	})
+
+	t.Run("Rejects too many security IDs", func(t *testing.T) {
+		ids := make([]string, maxPortfolioBalanceSecurities+1)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("SECURITY%016d", i)
+		}
+		rec := serve(&portfolioBalancesService{}, "/api/v1/portfolios/"+portfolioID+"/balances?securityIds="+strings.Join(ids, ","))
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		var body dto.ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "TOO_MANY_SECURITIES", body.Error.Code)
+	})
+
+	t.Run("Security IDs beyond the database list filter size", func(t *testing.T) {
+		svc := &portfolioBalancesService{err: fmt.Errorf("failed to list balances: %w",
+			repositories.NewListFilterTooLargeError("balance", "security_ids", 3, 2))}
+		rec := serve(svc, "/api/v1/portfolios/"+portfolioID+"/balances?securityIds="+securityA+","+securityB+","+securityC)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		var body dto.ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "FILTER_LIST_TOO_LARGE", body.Error.Code)
+		assert.Equal(t, "Filter security_ids has 3 values, at most 2 are allowed", body.Error.Message)
+	})
+}
+
+// singleBalanceService serves GetPortfolioBalance from fixed balances, keyed by portfolio and
+// security with cash under an empty security ID, and reports the others as not found
+type singleBalanceService struct {
+	services.BalanceService
+	balances map[string]dto.BalanceDTO
+	err      error
+
+	securityID *string
+}
+
+func (s *singleBalanceService) GetPortfolioBalance(ctx context.Context, portfolioID string, securityID *string) (*dto.BalanceDTO, error) {
+	s.securityID = securityID
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	key := portfolioID + "/"
+	if securityID != nil {
+		key += *securityID
+	}
+	balance, ok := s.balances[key]
+	if !ok {
+		return nil, fmt.Errorf("balance not found for portfolio %s", portfolioID)
+	}
+	return &balance, nil
+}
+
+func TestBalanceHandler_GetPortfolioBalance(t *testing.T) {
+	const portfolioID = "PORTFOLIO000000000000001"
+	securityID := "SECURITY0000000000000001"
+	svc := &singleBalanceService{balances: map[string]dto.BalanceDTO{
+		portfolioID + "/":              {ID: 1, PortfolioID: portfolioID, QuantityLong: decimal.NewFromInt(5000)},
+		portfolioID + "/" + securityID: {ID: 2, PortfolioID: portfolioID, SecurityID: &securityID, QuantityLong: decimal.NewFromInt(100)},
+	}}
+	serve := func(svc *singleBalanceService, target string) *httptest.ResponseRecorder {
+		handler := NewBalanceHandler(svc, logger.NewNoop())
+		router := chi.NewRouter()
+		router.Get("/api/v1/portfolios/{portfolioId}/securities/{securityId}/balance", handler.GetPortfolioSecurityBalance)
+		router.Get("/api/v1/portfolios/{portfolioId}/cash/balance", handler.GetPortfolioCashBalance)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	t.Run("Security balance", func(t *testing.T) {
+		rec := serve(svc, "/api/v1/portfolios/"+portfolioID+"/securities/"+securityID+"/balance")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NotNil(t, svc.securityID)
+		assert.Equal(t, securityID, *svc.securityID)
+
+		var body dto.BalanceDTO
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, int64(2), body.ID)
+		assert.True(t, decimal.NewFromInt(100).Equal(body.QuantityLong))
+	})
+
+	t.Run("Cash balance", func(t *testing.T) {
+		rec := serve(svc, "/api/v1/portfolios/"+portfolioID+"/cash/balance")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Nil(t, svc.securityID)
+
+		var body dto.BalanceDTO
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, int64(1), body.ID)
+		assert.Nil(t, body.SecurityID)
+		assert.True(t, decimal.NewFromInt(5000).Equal(body.QuantityLong))
+	})
+
+	t.Run("Missing balance", func(t *testing.T) {
+		rec := serve(svc, "/api/v1/portfolios/PORTFOLIO000000000000002/cash/balance")
+
+		require.Equal(t, http.StatusNotFound, rec.Code)
+		var body dto.ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "NOT_FOUND", body.Error.Code)
+	})
+
+	t.Run("Invalid IDs", func(t *testing.T) {
+		for _, tc := range []struct {
+			target string
+			field  string
+			code   string
+		}{
+			{"/api/v1/portfolios/PORTFOLIO1/cash/balance", "portfolioId", "INVALID_PORTFOLIO_ID"},
+			{"/api/v1/portfolios/" + portfolioID + "/securities/SECURITY1/balance", "securityId", "INVALID_SECURITY_ID"},
+		} {
+			rec := serve(&singleBalanceService{err: &services.InvalidBalanceIDError{Field: tc.field}}, tc.target)
+
+			require.Equal(t, http.StatusBadRequest, rec.Code, tc.target)
+			var body dto.ErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tc.code, body.Error.Code)
+		}
+	})
+
+	t.Run("Service failure", func(t *testing.T) {
+		rec := serve(&singleBalanceService{err: errors.New("connection refused")}, "/api/v1/portfolios/"+portfolioID+"/securities/"+securityID+"/balance")
+
+		require.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+// zeroBalanceService records the age and pagination of zero balance queries
+type zeroBalanceService struct {
+	services.BalanceService
+	olderThan  time.Duration
+	pagination dto.PaginationRequest
+}
+
+func (s *zeroBalanceService) GetZeroBalances(ctx context.Context, olderThan time.Duration, pagination dto.PaginationRequest) (*dto.BalanceListResponse, error) {
+	s.olderThan = olderThan
+	s.pagination = pagination
+	securityID := "SECURITY0000000000000001"
+	return &dto.BalanceListResponse{
+		Balances:   []dto.BalanceDTO{{ID: 7, SecurityID: &securityID, LastUpdated: "2024-01-02T00:00:00Z"}},
+		Pagination: dto.NewPaginationResponse(50, pagination.Offset, 1),
+	}, nil
+}
+
+func TestBalanceHandler_GetZeroBalances(t *testing.T) {
+	serve := func(svc *zeroBalanceService, query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		withParams(NewBalanceHandler(svc, logger.NewNoop()).GetZeroBalances, middleware.PaginationParams(0)...).
+			ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/balances/zero"+query, nil))
+		return rec
+	}
+
+	t.Run("Age and pagination are passed on", func(t *testing.T) {
+		svc := &zeroBalanceService{}
+		rec := serve(svc, "?olderThan=720h&offset=10&limit=5")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 720*time.Hour, svc.olderThan)
+		assert.Equal(t, dto.PaginationRequest{Offset: 10, Limit: 5}, svc.pagination)
+
+		var body dto.BalanceListResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Balances, 1)
+		assert.Equal(t, "2024-01-02T00:00:00Z", body.Balances[0].LastUpdated)
+	})
+
+	t.Run("Any age by default", func(t *testing.T) {
+		svc := &zeroBalanceService{}
+		rec := serve(svc, "")
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Zero(t, svc.olderThan)
+		assert.Equal(t, dto.PaginationRequest{}, svc.pagination)
+	})
+
+	invalid := []struct {
+		query string
+		code  string
+	}{
+		{query: "?olderThan=30days", code: "INVALID_PARAMETER"},
+		{query: "?olderThan=-1h", code: "INVALID_PARAMETER"},
+		{query: "?offset=-1", code: "INVALID_PARAMETERS"},
+		{query: "?limit=0", code: "INVALID_PARAMETERS"},
+	}
+	for _, tt := range invalid {
+		t.Run("Invalid "+tt.query, func(t *testing.T) {
+			rec := serve(&zeroBalanceService{}, tt.query)
+
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			var body dto.ErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tt.code, body.Error.Code)
+		})
+	}
+}
+
+// versionedBalanceService holds one balance and applies updates only at its current version
+type versionedBalanceService struct {
+	services.BalanceService
+	balance dto.BalanceDTO
+	updates int
+}
+
+func (s *versionedBalanceService) GetBalance(ctx context.Context, id int64) (*dto.BalanceDTO, error) {
+	if id != s.balance.ID {
+		return nil, fmt.Errorf("balance not found: %d", id)
+	}
+	balance := s.balance
+	return &balance, nil
+}
+
+func (s *versionedBalanceService) UpdateBalance(ctx context.Context, id int64, request dto.BalanceUpdateRequest) (*dto.BalanceUpdateResponse, error) {
+	if id != s.balance.ID {
+		return nil, fmt.Errorf("failed to get current balance: %w", repositories.NewNotFoundError("balance", id))
+	}
+	if request.Version != s.balance.Version {
+		return nil, repositories.NewOptimisticLockError("balance", id, request.Version, s.balance.Version)
+	}
+
+	previous := s.balance
+	if request.QuantityLong != nil {
+		s.balance.QuantityLong = *request.QuantityLong
+	}
+	s.balance.Version++
+	s.updates++
+	return &dto.BalanceUpdateResponse{Balance: s.balance, Updated: true, PreviousValue: previous}, nil
+}
+
+func TestBalanceHandler_UpdateBalance_IfMatch(t *testing.T) {
+	newRouter := func() (*versionedBalanceService, chi.Router) {
+		svc := &versionedBalanceService{balance: dto.BalanceDTO{
+			ID:           7,
+			PortfolioID:  "PORTFOLIO123456789012345",
+			QuantityLong: decimal.NewFromInt(100),
+			Version:      3,
+		}}
+		handler := NewBalanceHandler(svc, logger.NewNoop())
+
+		r := chi.NewRouter()
+		r.With(middleware.ValidateParams(middleware.PathIDParam("id"))).Get("/api/v1/balance/{id}", handler.GetBalanceByID)
+		r.With(middleware.ValidateParams(middleware.PathIDParam("id"))).Put("/api/v1/balance/{id}", handler.UpdateBalance)
+		return svc, r
+	}
+	put := func(r chi.Router, path, ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	errorCode := func(t *testing.T, rec *httptest.ResponseRecorder) string {
+		var body dto.ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body.Error.Code
+	}
+
+	t.Run("Matching ETag from GET applies the update", func(t *testing.T) {
+		svc, r := newRouter()
+
+		getRec := httptest.NewRecorder()
+		r.ServeHTTP(getRec, httptest.NewRequest(http.MethodGet, "/api/v1/balance/7", nil))
+		require.Equal(t, http.StatusOK, getRec.Code)
+		etag := getRec.Header().Get("ETag")
+		assert.Equal(t, `"3"`, etag)
+
+		rec := put(r, "/api/v1/balance/7", etag, `{"quantityLong":"250"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"4"`, rec.Header().Get("ETag"))
+
+		var body dto.BalanceUpdateResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.True(t, decimal.NewFromInt(250).Equal(body.Balance.QuantityLong))
+		assert.Equal(t, 1, svc.updates)
+	})
+
+	t.Run("Stale ETag fails the precondition", func(t *testing.T) {
+		svc, r := newRouter()
+
+		rec := put(r, "/api/v1/balance/7", `"2"`, `{"quantityLong":"250"}`)
+		require.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		assert.Equal(t, "PRECONDITION_FAILED", errorCode(t, rec))
+		assert.Zero(t, svc.updates)
+	})
+
+	t.Run("Weak or malformed ETags never match", func(t *testing.T) {
+		svc, r := newRouter()
+
+		for _, tag := range []string{`W/"3"`, `3`, `"three"`} {
+			rec := put(r, "/api/v1/balance/7", tag, `{"quantityLong":"250"}`)
+			assert.Equal(t, http.StatusPreconditionFailed, rec.Code, tag)
+		}
+		assert.Zero(t, svc.updates)
+	})
+
+	t.Run("Wildcard matches any version", func(t *testing.T) {
+		svc, r := newRouter()
+
+		rec := put(r, "/api/v1/balance/7", "*", `{"quantityLong":"250"}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, svc.updates)
+	})
+
+	t.Run("Missing If-Match and body version is rejected", func(t *testing.T) {
+		svc, r := newRouter()
+
+		rec := put(r, "/api/v1/balance/7", "", `{"quantityLong":"250"}`)
+		require.Equal(t, http.StatusPreconditionRequired, rec.Code)
+		assert.Equal(t, "PRECONDITION_REQUIRED", errorCode(t, rec))
+		assert.Zero(t, svc.updates)
+	})
+
+	t.Run("Missing If-Match falls back to the body version", func(t *testing.T) {
+		svc, r := newRouter()
+
+		rec := put(r, "/api/v1/balance/7", "", `{"quantityLong":"250","version":3}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, svc.updates)
+
+		rec = put(r, "/api/v1/balance/7", "", `{"quantityLong":"300","version":3}`)
+		require.Equal(t, http.StatusConflict, rec.Code)
+		assert.Equal(t, "VERSION_CONFLICT", errorCode(t, rec))
+	})
+
+	t.Run("Body version contradicting If-Match", func(t *testing.T) {
+		svc, r := newRouter()
+
+		rec := put(r, "/api/v1/balance/7", `"3"`, `{"quantityLong":"250","version":2}`)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Zero(t, svc.updates)
+	})
+
+	t.Run("Unknown balance", func(t *testing.T) {
+		_, r := newRouter()
+
+		rec := put(r, "/api/v1/balance/8", `"3"`, `{"quantityLong":"250"}`)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+// stubCountBalanceService counts one balance per filtered portfolio, or ten without a portfolio filter
+type stubCountBalanceService struct {
+	services.BalanceService
+	filter *dto.BalanceFilter
+}
+
+func (s *stubCountBalanceService) CountBalances(ctx context.Context, filter dto.BalanceFilter) (int64, error) {
+	s.filter = &filter
+	if filter.PortfolioID != nil {
+		return 1, nil
+	}
+	return 10, nil
+}
+
+func TestBalanceHandler_CountBalances(t *testing.T) {
+	t.Run("Returns only the count", func(t *testing.T) {
+		svc := &stubCountBalanceService{}
+		handler := NewBalanceHandler(svc, logger.NewNoop())
+
+		rec := httptest.NewRecorder()
+		handler.CountBalances(rec, httptest.NewRequest(http.MethodGet, "/api/v1/balances/count?portfolio_id=PORTFOLIO123456789012345", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"count":1}`, rec.Body.String())
+		require.NotNil(t, svc.filter)
+		assert.Equal(t, "PORTFOLIO123456789012345", *svc.filter.PortfolioID)
+	})
+
+	t.Run("Invalid filter", func(t *testing.T) {
+		svc := &stubCountBalanceService{}
+		handler := NewBalanceHandler(svc, logger.NewNoop())
+
+		rec := httptest.NewRecorder()
+		handler.CountBalances(rec, httptest.NewRequest(http.MethodGet, "/api/v1/balances/count?min_notional=lots", nil))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Nil(t, svc.filter)
+	})
+}
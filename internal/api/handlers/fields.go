@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// fieldsQueryParam is the query parameter selecting a sparse fieldset for list responses
+const fieldsQueryParam = "fields"
+
+// transactionFields lists the fields of dto.TransactionResponseDTO that can be selected
+var transactionFields = []string{
+	"id", "portfolioId", "securityId", "sourceId", "status", "transactionType",
+	"quantity", "price", "transactionDate", "settlementDate", "reprocessingAttempts", "version",
+	"errorMessage",
+}
+
+// balanceFields lists the fields of dto.BalanceDTO that can be selected
+var balanceFields = []string{
+	"id", "portfolioId", "securityId", "quantityLong", "quantityShort", "lastUpdated", "version",
+}
+
+// parseFields parses the comma-separated fields parameter and validates each name against
+// the allowlist. A nil result means the full representation was requested.
+func parseFields(r *http.Request, allowed []string) ([]string, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get(fieldsQueryParam))
+	if raw == "" {
+		return nil, nil
+	}
+
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, field := range allowed {
+		allowedSet[field] = true
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !allowedSet[field] {
+			return nil, fmt.Errorf("invalid field %q; allowed fields: %s", field, strings.Join(allowed, ","))
+		}
+		if !seen[field] {
+			seen[field] = true
+			fields = append(fields, field)
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("fields parameter must name at least one field")
+	}
+
+	return fields, nil
+}
+
+// projectFields reduces each item to the requested JSON fields. Fields omitted from an
+// item's JSON representation (e.g. a nil securityId) stay omitted.
+func projectFields[T any](items []T, fields []string) ([]map[string]json.RawMessage, error) {
+	projected := make([]map[string]json.RawMessage, 0, len(items))
+
+	for _, item := range items {
+		encoded, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+
+		var full map[string]json.RawMessage
+		if err := json.Unmarshal(encoded, &full); err != nil {
+			return nil, err
+		}
+
+		sparse := make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			if value, ok := full[field]; ok {
+				sparse[field] = value
+			}
+		}
+		projected = append(projected, sparse)
+	}
+
+	return projected, nil
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// stubBalanceService returns a fixed balance list and records the last filter
+type stubBalanceService struct {
+	services.BalanceService
+	calls  int
+	filter dto.BalanceFilter
+}
+
+func (s *stubBalanceService) GetBalances(ctx context.Context, filter dto.BalanceFilter) (*dto.BalanceListResponse, error) {
+	s.calls++
+	s.filter = filter
+	securityID := "SECURITY1234567890123456"
+	return &dto.BalanceListResponse{
+		Balances: []dto.BalanceDTO{
+			{
+				ID:            1,
+				PortfolioID:   "PORTFOLIO123456789012345",
+				SecurityID:    &securityID,
+				QuantityLong:  decimal.NewFromInt(100),
+				QuantityShort: decimal.NewFromInt(5),
+				LastUpdated:   "2024-01-02T00:00:00Z",
+				Version:       3,
+			},
+		},
+		Pagination: dto.PaginationResponse{Limit: 50, Total: 1, Page: 1, TotalPages: 1},
+	}, nil
+}
+
+// stubTransactionService returns a fixed transaction list
+type stubTransactionService struct {
+	services.TransactionService
+}
+
+func (s *stubTransactionService) GetTransactions(ctx context.Context, filter dto.TransactionFilter) (*dto.TransactionListResponse, error) {
+	settlementDate := "20240104"
+	return &dto.TransactionListResponse{
+		Transactions: []dto.TransactionResponseDTO{
+			{
+				ID:              7,
+				PortfolioID:     "PORTFOLIO123456789012345",
+				SourceID:        "SRC-7",
+				Status:          "PROC",
+				TransactionType: "DEP",
+				Quantity:        decimal.NewFromInt(1000),
+				Price:           decimal.NewFromInt(1),
+				TransactionDate: "20240102",
+				SettlementDate:  &settlementDate,
+				Version:         1,
+			},
+		},
+		Pagination: dto.PaginationResponse{Limit: 50, Total: 1, Page: 1, TotalPages: 1},
+	}, nil
+}
+
+func TestGetBalances_Fields(t *testing.T) {
+	t.Run("Valid field subset", func(t *testing.T) {
+		handler := NewBalanceHandler(&stubBalanceService{}, logger.NewNoop())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/balances?fields=portfolioId,quantityLong", nil)
+		rec := httptest.NewRecorder()
+		handler.GetBalances(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Balances   []map[string]interface{} `json:"balances"`
+			Pagination dto.PaginationResponse   `json:"pagination"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Balances, 1)
+		assert.Equal(t, map[string]interface{}{
+			"portfolioId":  "PORTFOLIO123456789012345",
+			"quantityLong": "100",
+		}, body.Balances[0])
+		assert.Equal(t, int64(1), body.Pagination.Total)
+	})
+
+	t.Run("Invalid field name", func(t *testing.T) {
+		service := &stubBalanceService{}
+		handler := NewBalanceHandler(service, logger.NewNoop())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/balances?fields=portfolioId,password", nil)
+		rec := httptest.NewRecorder()
+		handler.GetBalances(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+
+		var errResp dto.ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errResp))
+		assert.Equal(t, "INVALID_FIELDS", errResp.Error.Code)
+		assert.Contains(t, errResp.Error.Message, `"password"`)
+		assert.Zero(t, service.calls)
+	})
+
+	t.Run("No fields returns full representation", func(t *testing.T) {
+		handler := NewBalanceHandler(&stubBalanceService{}, logger.NewNoop())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/balances", nil)
+		rec := httptest.NewRecorder()
+		handler.GetBalances(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body dto.BalanceListResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Balances, 1)
+		assert.Equal(t, 3, body.Balances[0].Version)
+	})
+}
+
+func TestGetTransactions_Fields(t *testing.T) {
+	t.Run("Valid field subset omits absent optional fields", func(t *testing.T) {
+		handler := NewTransactionHandler(&stubTransactionService{}, logger.NewNoop())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/transactions?fields=id,securityId,status", nil)
+		rec := httptest.NewRecorder()
+		handler.GetTransactions(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Transactions []map[string]interface{} `json:"transactions"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Transactions, 1)
+		assert.Equal(t, map[string]interface{}{
+			"id":     float64(7),
+			"status": "PROC",
+		}, body.Transactions[0])
+	})
+
+	t.Run("Settlement date can be selected", func(t *testing.T) {
+		handler := NewTransactionHandler(&stubTransactionService{}, logger.NewNoop())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/transactions?fields=sourceId,settlementDate", nil)
+		rec := httptest.NewRecorder()
+		handler.GetTransactions(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Transactions []map[string]interface{} `json:"transactions"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Transactions, 1)
+		assert.Equal(t, map[string]interface{}{
+			"sourceId":       "SRC-7",
+			"settlementDate": "20240104",
+		}, body.Transactions[0])
+	})
+
+	t.Run("Invalid field name", func(t *testing.T) {
+		handler := NewTransactionHandler(&stubTransactionService{}, logger.NewNoop())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/transactions?fields=quantityLong", nil)
+		rec := httptest.NewRecorder()
+		handler.GetTransactions(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+		wantErr  bool
+	}{
+		{name: "absent", query: "", expected: nil},
+		{name: "trims and deduplicates", query: "fields=+id+,portfolioId,id", expected: []string{"id", "portfolioId"}},
+		{name: "case sensitive", query: "fields=PortfolioId", wantErr: true},
+		{name: "only separators", query: "fields=,,", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/balances?"+tt.query, nil)
+
+			fields, err := parseFields(req, balanceFields)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, fields)
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// sseKeepAliveInterval is how often an idle progress stream sends a comment so proxies keep
+// the connection open
+const sseKeepAliveInterval = 15 * time.Second
+
+// FileHandler handles HTTP requests for transaction file processing
+type FileHandler struct {
+	fileService services.FileProcessorService
+	logger      logger.Logger
+}
+
+// NewFileHandler creates a new file handler
+func NewFileHandler(fileService services.FileProcessorService, logger logger.Logger) *FileHandler {
+	return &FileHandler{
+		fileService: fileService,
+		logger:      logger,
+	}
+}
+
+// StartFileProcessing starts processing a transaction file in the background
+// @Summary Start processing a transaction file
+// @Description Start processing a CSV transaction file from the service's working directory in the background. Follow the job with GET /files/{filename}/progress. A file with the same name and content as a completed run is rejected unless force is set.
+// @Tags Files
+// @Produce json
+// @Param filename path string true "Name of the file in the working directory"
+// @Param force query bool false "Process the file even if an identical file was already processed"
+// @Success 202 {object} dto.FileProcessingStatus "Processing started"
+// @Failure 400 {object} dto.ErrorResponse "Invalid filename or force parameter"
+// @Failure 409 {object} dto.ErrorResponse "File is already being processed, or was already processed with the same content"
+// @Failure 413 {object} dto.ErrorResponse "File is larger than file_processing.max_file_size"
+// @Security ApiKeyAuth
+// @Router /files/{filename}/process [post]
+func (h *FileHandler) StartFileProcessing(w http.ResponseWriter, r *http.Request) {
+	filename, ok := h.filenameParam(w, r)
+	if !ok {
+		return
+	}
+
+	force := false
+	if forceStr := r.URL.Query().Get("force"); forceStr != "" {
+		var err error
+		if force, err = strconv.ParseBool(forceStr); err != nil {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_PARAMETER", "force must be a boolean")
+			return
+		}
+	}
+
+	status, err := h.fileService.StartTransactionFile(r.Context(), filename, force)
+	if err != nil {
+		var tooLarge *services.FileTooLargeError
+		var processed *services.FileAlreadyProcessedError
+		if errors.As(err, &processed) {
+			h.logger.Warn("Rejected already processed transaction file",
+				zap.String("filename", filename),
+				zap.String("contentHash", processed.Previous.ContentHash))
+			h.writeErrorResponse(w, http.StatusConflict, "FILE_ALREADY_PROCESSED", err.Error()+"; set force=true to process it again")
+			return
+		}
+		if errors.As(err, &tooLarge) {
+			h.logger.Warn("Rejected oversized transaction file",
+				zap.String("filename", filename),
+				zap.Int64("size", tooLarge.Size),
+				zap.Int64("limit", tooLarge.Limit))
+			h.writeErrorResponse(w, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", err.Error())
+			return
+		}
+		h.logger.Warn("Failed to start file processing", zap.String("filename", filename), zap.Error(err))
+		h.writeErrorResponse(w, http.StatusConflict, "FILE_PROCESSING_IN_PROGRESS", err.Error())
+		return
+	}
+
+	h.logger.Info("File processing started", zap.String("filename", filename))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/v1/files/"+url.PathEscape(filename)+"/progress")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// StreamFileProgress streams the progress of a file processing job as Server-Sent Events
+// @Summary Stream file processing progress
+// @Description Stream the status of a file processing job as Server-Sent Events. Each "progress" event carries the current FileProcessingStatus (records processed/failed); the stream ends with a "complete", "failed" or "stopped" event carrying the final status.
+// @Tags Files
+// @Produce text/event-stream
+// @Param filename path string true "Name of the file being processed"
+// @Success 200 {object} dto.FileProcessingStatus "Stream of status events"
+// @Failure 400 {object} dto.ErrorResponse "Invalid filename"
+// @Failure 404 {object} dto.ErrorResponse "No processing job for the file"
+// @Security ApiKeyAuth
+// @Router /files/{filename}/progress [get]
+func (h *FileHandler) StreamFileProgress(w http.ResponseWriter, r *http.Request) {
+	filename, ok := h.filenameParam(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	updates, err := h.fileService.WatchFileProcessing(ctx, filename)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		return
+	}
+
+	// Long imports outlive the server write timeout
+	controller := http.NewResponseController(w)
+	_ = controller.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_ = controller.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	events := 0
+	for {
+		select {
+		case <-ctx.Done():
+			// The client went away; the watch ends with the request context
+			h.logger.Debug("File progress client disconnected",
+				zap.String("filename", filename),
+				zap.Int("events", events))
+			return
+
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			_ = controller.Flush()
+
+		case status, open := <-updates:
+			if !open {
+				return
+			}
+			if err := writeFileProgressEvent(w, status); err != nil {
+				h.logger.Debug("Failed to write file progress event",
+					zap.String("filename", filename),
+					zap.Error(err))
+				return
+			}
+			_ = controller.Flush()
+			events++
+		}
+	}
+}
+
+// writeFileProgressEvent writes a status as an SSE event named after the job's state
+func writeFileProgressEvent(w http.ResponseWriter, status dto.FileProcessingStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	event := "progress"
+	switch status.Status {
+	case services.FileStatusCompleted:
+		event = "complete"
+	case services.FileStatusFailed:
+		event = "failed"
+	case services.FileStatusStopped:
+		event = "stopped"
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// filenameParam reads the filename path parameter, which must name a file directly inside the
+// working directory
+func (h *FileHandler) filenameParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	filename, err := url.PathUnescape(chi.URLParam(r, "filename"))
+	if err != nil || filename == "" || filename == "." || filename == ".." ||
+		filepath.Base(filename) != filename || strings.ContainsAny(filename, `/\`) {
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_FILENAME", "Filename must name a file in the working directory")
+		return "", false
+	}
+	return filename, true
+}
+
+// writeErrorResponse writes a standardized error response
+func (h *FileHandler) writeErrorResponse(w http.ResponseWriter, statusCode int, errorCode, message string) {
+	errorResp := dto.ErrorResponse{
+		Error: dto.ErrorDetail{
+			Code:      errorCode,
+			Message:   message,
+			Timestamp: time.Now(),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		h.logger.Error("Failed to write error response", zap.Error(err))
+	}
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+const progressCSV = "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+	"PORTFOLIO000000000000001,,DEP-1,DEP,1000,1,20240102\n" +
+	"PORTFOLIO000000000000001,,FAIL-2,DEP,1000,1,20240103\n" +
+	"PORTFOLIO000000000000001,,DEP-3,DEP,1000,1,20240104\n"
+
+// gatedTransactionService holds every batch until release is closed
+type gatedTransactionService struct {
+	stubCreateTransactionService
+	release chan struct{}
+}
+
+func (s *gatedTransactionService) CreateTransactions(ctx context.Context, transactionDTOs []dto.TransactionPostDTO, options services.BatchOptions) (*dto.TransactionBatchResponse, error) {
+	<-s.release
+	return s.stubCreateTransactionService.CreateTransactions(ctx, transactionDTOs, options)
+}
+
+type sseEvent struct {
+	name   string
+	status dto.FileProcessingStatus
+}
+
+func newFileTestRouter(t *testing.T, transactionService services.TransactionService) http.Handler {
+	t.Helper()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(progressCSV), 0644))
+
+	fileService := services.NewFileProcessorService(transactionService, services.FileProcessorConfig{
+		WorkingDirectory:   dir,
+		ErrorFileDirectory: filepath.Join(dir, "errors"),
+		MaxRecordsPerBatch: 1,
+	}, logger.NewNoop())
+	handler := NewFileHandler(fileService, logger.NewNoop())
+
+	r := chi.NewRouter()
+	r.Post("/api/v1/files/{filename}/process", handler.StartFileProcessing)
+	r.Get("/api/v1/files/{filename}/progress", handler.StreamFileProgress)
+
+	return r
+}
+
+func newFileTestServer(t *testing.T, transactionService services.TransactionService) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(newFileTestRouter(t, transactionService))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// readSSEEvents reads events from an SSE stream until it ends
+func readSSEEvents(t *testing.T, resp *http.Response) []sseEvent {
+	t.Helper()
+
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &current.status))
+		case line == "" && current.name != "":
+			events = append(events, current)
+			current = sseEvent{}
+		}
+	}
+	return events
+}
+
+func TestStreamFileProgress(t *testing.T) {
+	t.Run("Streams progress until the job completes", func(t *testing.T) {
+		transactionService := &gatedTransactionService{release: make(chan struct{})}
+		server := newFileTestServer(t, transactionService)
+
+		resp, err := http.Post(server.URL+"/api/v1/files/transactions.csv/process", "", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+		resp, err = http.Get(server.URL + "/api/v1/files/transactions.csv/progress")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		// The job cannot finish until the stream is open
+		close(transactionService.release)
+		events := readSSEEvents(t, resp)
+
+		require.GreaterOrEqual(t, len(events), 2)
+		assert.Equal(t, "progress", events[0].name)
+		assert.Equal(t, services.FileStatusProcessing, events[0].status.Status)
+
+		last := events[len(events)-1]
+		assert.Equal(t, "complete", last.name)
+		assert.Equal(t, services.FileStatusCompleted, last.status.Status)
+		assert.Equal(t, 2, last.status.ProcessedRecords)
+		assert.Equal(t, 1, last.status.FailedRecords)
+		for _, event := range events[:len(events)-1] {
+			assert.Equal(t, "progress", event.name)
+		}
+	})
+
+	t.Run("Client disconnect ends the stream", func(t *testing.T) {
+		transactionService := &gatedTransactionService{release: make(chan struct{})}
+		router := newFileTestRouter(t, transactionService)
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/files/transactions.csv/process", nil))
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		defer func() {
+			// Let the job finish before the working directory is removed
+			close(transactionService.release)
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/files/transactions.csv/progress", nil))
+		}()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/files/transactions.csv/progress", nil).WithContext(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+
+		// The job is still running, so only a disconnect can end the stream
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream did not end after the client disconnected")
+		}
+	})
+
+	t.Run("Unknown file returns 404", func(t *testing.T) {
+		server := newFileTestServer(t, &stubCreateTransactionService{})
+
+		resp, err := http.Get(server.URL + "/api/v1/files/missing.csv/progress")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Path traversal is rejected", func(t *testing.T) {
+		server := newFileTestServer(t, &stubCreateTransactionService{})
+
+		resp, err := http.Post(server.URL+"/api/v1/files/..%2Fsecret.csv/process", "", nil)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestStartFileProcessing_FileTooLarge(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(progressCSV), 0644))
+
+	fileService := services.NewFileProcessorService(&stubCreateTransactionService{}, services.FileProcessorConfig{
+		WorkingDirectory:   dir,
+		ErrorFileDirectory: filepath.Join(dir, "errors"),
+		MaxFileSize:        int64(len(progressCSV) - 1),
+	}, logger.NewNoop())
+	handler := NewFileHandler(fileService, logger.NewNoop())
+
+	r := chi.NewRouter()
+	r.Post("/api/v1/files/{filename}/process", handler.StartFileProcessing)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/files/transactions.csv/process", nil))
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	var body dto.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "FILE_TOO_LARGE", body.Error.Code)
+
+	_, err := fileService.GetFileProcessingStatus(context.Background(), "transactions.csv")
+	assert.Error(t, err, "no job is registered for a rejected file")
+}
+
+func TestStartFileProcessing_AlreadyProcessed(t *testing.T) {
+	server := newFileTestServer(t, &stubCreateTransactionService{})
+
+	start := func(query string) *http.Response {
+		resp, err := http.Post(server.URL+"/api/v1/files/transactions.csv/process"+query, "", nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	waitForCompletion := func() {
+		resp, err := http.Get(server.URL + "/api/v1/files/transactions.csv/progress")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		events := readSSEEvents(t, resp)
+		require.NotEmpty(t, events)
+		require.Equal(t, "complete", events[len(events)-1].name)
+	}
+
+	require.Equal(t, http.StatusAccepted, start("").StatusCode)
+	waitForCompletion()
+
+	resp := start("")
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+	var body dto.ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "FILE_ALREADY_PROCESSED", body.Error.Code)
+	assert.Contains(t, body.Error.Message, "force=true")
+
+	assert.Equal(t, http.StatusBadRequest, start("?force=maybe").StatusCode)
+
+	require.Equal(t, http.StatusAccepted, start("?force=true").StatusCode)
+	waitForCompletion()
+}
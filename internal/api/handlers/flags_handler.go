@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/config"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// FlagsHandler handles HTTP requests for the service's feature flags
+type FlagsHandler struct {
+	flags  config.FeatureFlags
+	logger logger.Logger
+}
+
+// NewFlagsHandler creates a new feature flags handler
+func NewFlagsHandler(flags config.FeatureFlags, logger logger.Logger) *FlagsHandler {
+	return &FlagsHandler{
+		flags:  flags,
+		logger: logger,
+	}
+}
+
+// GetFlags returns the feature flags the service is running with
+// @Summary Get feature flags
+// @Description Get the feature flags the service was started with, derived from its configuration
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} config.FeatureFlags "Current feature flags"
+// @Security ApiKeyAuth
+// @Router /admin/flags [get]
+func (h *FlagsHandler) GetFlags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(h.flags); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/external"
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
 	"go.uber.org/zap"
@@ -18,6 +24,32 @@ type HealthHandler struct {
 	logger          logger.Logger
 	version         string
 	environment     string
+
+	// fileService reports the last successful file processing run and whether the file
+	// directories are writable; nil omits both
+	fileService services.FileProcessorService
+
+	// writeTransactionStats reports the usage of the database write transaction limit; nil omits it
+	writeTransactionStats func() database.TransactionLimiterStats
+
+	// Dependency check results are reused for checkCacheTTL to spare dependencies from frequent probes
+	checkCacheTTL time.Duration
+	checkCacheMu  sync.Mutex
+	checkCache    map[string]dependencyCheck
+
+	// checkTimeout bounds the concurrent dependency checks of the detailed health check; zero
+	// leaves them to the request context
+	checkTimeout time.Duration
+
+	// draining is set once shutdown has begun; readiness then fails without checking dependencies
+	draining atomic.Bool
+}
+
+// dependencyCheck is the outcome of a single dependency health check
+type dependencyCheck struct {
+	err       error
+	checkedAt time.Time
+	latency   time.Duration
 }
 
 // NewHealthHandler creates a new health handler
@@ -34,9 +66,115 @@ func NewHealthHandler(
 		logger:          logger,
 		version:         version,
 		environment:     environment,
+		checkCache:      make(map[string]dependencyCheck),
 	}
 }
 
+// WithCheckCacheTTL reuses dependency check results younger than ttl. Zero disables caching.
+func (h *HealthHandler) WithCheckCacheTTL(ttl time.Duration) *HealthHandler {
+	h.checkCacheTTL = ttl
+	return h
+}
+
+// WithCheckTimeout gives the dependency checks of the detailed health check a shared timeout.
+// Zero waits as long as the request allows.
+func (h *HealthHandler) WithCheckTimeout(timeout time.Duration) *HealthHandler {
+	h.checkTimeout = timeout
+	return h
+}
+
+// WithFileProcessor reports the last successful file processing run in the detailed health check
+// and makes readiness depend on the file directories being writable
+func (h *HealthHandler) WithFileProcessor(fileService services.FileProcessorService) *HealthHandler {
+	h.fileService = fileService
+	return h
+}
+
+// WithWriteTransactionStats reports the usage of the database write transaction limit in the
+// detailed health check
+func (h *HealthHandler) WithWriteTransactionStats(stats func() database.TransactionLimiterStats) *HealthHandler {
+	h.writeTransactionStats = stats
+	return h
+}
+
+// StartDraining makes the readiness probe fail from now on, so load balancers stop routing new
+// requests to an instance that is shutting down
+func (h *HealthHandler) StartDraining() {
+	h.draining.Store(true)
+}
+
+// checkDependency runs the health check for a dependency unless a result within the cache TTL exists
+func (h *HealthHandler) checkDependency(ctx context.Context, name string, check func(context.Context) error) dependencyCheck {
+	if h.checkCacheTTL > 0 {
+		h.checkCacheMu.Lock()
+		cached, ok := h.checkCache[name]
+		h.checkCacheMu.Unlock()
+
+		if ok && time.Since(cached.checkedAt) < h.checkCacheTTL {
+			return cached
+		}
+	}
+
+	started := time.Now()
+	err := check(ctx)
+	result := dependencyCheck{err: err, checkedAt: time.Now()}
+	result.latency = result.checkedAt.Sub(started)
+
+	// A check cut short by the caller says nothing about the dependency, so it is not cached
+	if h.checkCacheTTL > 0 && ctx.Err() == nil {
+		h.checkCacheMu.Lock()
+		if h.checkCache == nil {
+			h.checkCache = make(map[string]dependencyCheck)
+		}
+		h.checkCache[name] = result
+		h.checkCacheMu.Unlock()
+	}
+
+	return result
+}
+
+// checkDependenciesConcurrently runs the checks of several dependencies at the same time under
+// the shared check timeout. Checks still running when it expires are reported as failed.
+func (h *HealthHandler) checkDependenciesConcurrently(ctx context.Context, checks map[string]func(context.Context) error) map[string]dependencyCheck {
+	if h.checkTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.checkTimeout)
+		defer cancel()
+	}
+
+	type namedCheck struct {
+		name   string
+		result dependencyCheck
+	}
+	started := time.Now()
+	completed := make(chan namedCheck, len(checks))
+	for name, check := range checks {
+		go func(name string, check func(context.Context) error) {
+			completed <- namedCheck{name: name, result: h.checkDependency(ctx, name, check)}
+		}(name, check)
+	}
+
+	results := make(map[string]dependencyCheck, len(checks))
+	for len(results) < len(checks) {
+		select {
+		case done := <-completed:
+			results[done.name] = done.result
+		case <-ctx.Done():
+			now := time.Now()
+			for name := range checks {
+				if _, ok := results[name]; !ok {
+					results[name] = dependencyCheck{
+						err:       fmt.Errorf("health check did not complete: %w", ctx.Err()),
+						checkedAt: now,
+						latency:   now.Sub(started),
+					}
+				}
+			}
+		}
+	}
+	return results
+}
+
 // GetHealth performs a basic health check
 // @Summary Basic health check
 // @Description Returns basic service health status
@@ -102,12 +240,12 @@ func (h *HealthHandler) GetLiveness(w http.ResponseWriter, r *http.Request) {
 
 // GetReadiness performs a Kubernetes readiness probe check
 // @Summary Kubernetes readiness probe
-// @Description Returns readiness status for Kubernetes traffic routing. Checks external service connectivity (portfolio and security services).
+// @Description Returns readiness status for Kubernetes traffic routing. Checks external service connectivity (portfolio and security services) and that the file processor's working and error directories are writable.
 // @Tags Health
 // @Accept json
 // @Produce json
 // @Success 200 {object} dto.HealthResponse "Service is ready to receive traffic"
-// @Failure 503 {object} dto.ErrorResponse "Service is not ready (external services unavailable)"
+// @Failure 503 {object} dto.ErrorResponse "Service is not ready (external services unavailable, or draining during shutdown)"
 // @Router /health/ready [get]
 func (h *HealthHandler) GetReadiness(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -116,12 +254,17 @@ func (h *HealthHandler) GetReadiness(w http.ResponseWriter, r *http.Request) {
 		zap.String("user_agent", r.Header.Get("User-Agent")),
 		zap.String("remote_addr", r.RemoteAddr))
 
+	if h.draining.Load() {
+		h.writeDraining(w)
+		return
+	}
+
 	checks := make(map[string]interface{})
 	allHealthy := true
 
 	// Check portfolio service health (handle nil service gracefully)
 	if h.portfolioClient != nil {
-		if err := h.portfolioClient.Health(ctx); err != nil {
+		if err := h.checkDependency(ctx, "portfolio_service", h.portfolioClient.Health).err; err != nil {
 			h.logger.Warn("Portfolio service health check failed", zap.Error(err))
 			checks["portfolio_service"] = map[string]interface{}{
 				"status": "unhealthy",
@@ -143,7 +286,7 @@ func (h *HealthHandler) GetReadiness(w http.ResponseWriter, r *http.Request) {
 
 	// Check security service health (handle nil service gracefully)
 	if h.securityClient != nil {
-		if err := h.securityClient.Health(ctx); err != nil {
+		if err := h.checkDependency(ctx, "security_service", h.securityClient.Health).err; err != nil {
 			h.logger.Warn("Security service health check failed", zap.Error(err))
 			checks["security_service"] = map[string]interface{}{
 				"status": "unhealthy",
@@ -163,6 +306,17 @@ func (h *HealthHandler) GetReadiness(w http.ResponseWriter, r *http.Request) {
 		allHealthy = false
 	}
 
+	// An unwritable file directory, e.g. a volume mounted read-only, fails readiness rather
+	// than the first file upload
+	if h.fileService != nil {
+		check, writable := h.fileProcessorCheck(ctx)
+		checks["file_processor"] = check
+		if !writable {
+			h.logger.Warn("File processor directories are not writable", zap.Any("check", check))
+			allHealthy = false
+		}
+	}
+
 	status := "ready"
 	statusCode := http.StatusOK
 
@@ -194,9 +348,32 @@ func (h *HealthHandler) GetReadiness(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// writeDraining answers the readiness probe of a server that is shutting down
+func (h *HealthHandler) writeDraining(w http.ResponseWriter) {
+	response := dto.HealthResponse{
+		Status:      "draining",
+		Timestamp:   time.Now(),
+		Version:     h.version,
+		Environment: h.environment,
+		Checks: map[string]interface{}{
+			"shutdown": map[string]interface{}{
+				"status":  "draining",
+				"message": "Service is shutting down",
+			},
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode readiness response", zap.Error(err))
+	}
+}
+
 // GetDetailedHealth performs comprehensive health checks with detailed status
 // @Summary Detailed health check with dependencies
-// @Description Returns comprehensive health status including external services (portfolio and security services) connectivity and response times
+// @Description Returns comprehensive health status including external services (portfolio and security services) connectivity and response times. The external services are checked concurrently under server.health_check_timeout; a check still running at the timeout is reported unhealthy. file_processor reports whether the file working and error directories are writable.
 // @Tags Health
 // @Accept json
 // @Produce json
@@ -213,21 +390,10 @@ func (h *HealthHandler) GetDetailedHealth(w http.ResponseWriter, r *http.Request
 	checks := make(map[string]interface{})
 	allHealthy := true
 
-	// Check portfolio service health (handle nil service gracefully)
+	// Check the external services concurrently (handle nil services gracefully)
+	dependencies := make(map[string]func(context.Context) error)
 	if h.portfolioClient != nil {
-		if err := h.portfolioClient.Health(ctx); err != nil {
-			checks["portfolio_service"] = map[string]interface{}{
-				"status":     "unhealthy",
-				"error":      err.Error(),
-				"checked_at": time.Now(),
-			}
-			allHealthy = false
-		} else {
-			checks["portfolio_service"] = map[string]interface{}{
-				"status":     "healthy",
-				"checked_at": time.Now(),
-			}
-		}
+		dependencies["portfolio_service"] = h.portfolioClient.Health
 	} else {
 		checks["portfolio_service"] = map[string]interface{}{
 			"status":     "not_initialized",
@@ -236,22 +402,8 @@ func (h *HealthHandler) GetDetailedHealth(w http.ResponseWriter, r *http.Request
 		}
 		allHealthy = false
 	}
-
-	// Check security service health (handle nil service gracefully)
 	if h.securityClient != nil {
-		if err := h.securityClient.Health(ctx); err != nil {
-			checks["security_service"] = map[string]interface{}{
-				"status":     "unhealthy",
-				"error":      err.Error(),
-				"checked_at": time.Now(),
-			}
-			allHealthy = false
-		} else {
-			checks["security_service"] = map[string]interface{}{
-				"status":     "healthy",
-				"checked_at": time.Now(),
-			}
-		}
+		dependencies["security_service"] = h.securityClient.Health
 	} else {
 		checks["security_service"] = map[string]interface{}{
 			"status":     "not_initialized",
@@ -261,6 +413,40 @@ func (h *HealthHandler) GetDetailedHealth(w http.ResponseWriter, r *http.Request
 		allHealthy = false
 	}
 
+	for name, result := range h.checkDependenciesConcurrently(ctx, dependencies) {
+		check := map[string]interface{}{
+			"status":     "healthy",
+			"checked_at": result.checkedAt,
+			"latency_ms": result.latency.Milliseconds(),
+		}
+		if result.err != nil {
+			check["status"] = "unhealthy"
+			check["error"] = result.err.Error()
+			allHealthy = false
+		}
+		checks[name] = check
+	}
+
+	// Report the last successful file processing run; how old it may get is left to monitoring,
+	// so it never affects the overall status
+	if h.fileService != nil {
+		checks["file_processing"] = h.fileProcessingCheck(ctx)
+
+		check, writable := h.fileProcessorCheck(ctx)
+		checks["file_processor"] = check
+		if !writable {
+			allHealthy = false
+		}
+	}
+
+	// Report write transactions waiting for a slot; a full limit is expected under load, so it
+	// never affects the overall status
+	if h.writeTransactionStats != nil {
+		if stats := h.writeTransactionStats(); stats.Limit > 0 {
+			checks["database_write_transactions"] = writeTransactionsCheck(stats)
+		}
+	}
+
 	overallStatus := "healthy"
 	if !allHealthy {
 		overallStatus = "degraded"
@@ -293,3 +479,70 @@ func (h *HealthHandler) GetDetailedHealth(w http.ResponseWriter, r *http.Request
 		h.logger.Warn("Detailed health check shows degraded status", zap.Any("checks", checks))
 	}
 }
+
+// fileProcessingCheck describes the last successful file processing run for the detailed health check
+func (h *HealthHandler) fileProcessingCheck(ctx context.Context) map[string]interface{} {
+	lastSuccess, err := h.fileService.GetLastSuccessfulProcessing(ctx)
+	if err != nil {
+		return map[string]interface{}{
+			"status": "unknown",
+			"error":  err.Error(),
+		}
+	}
+	if lastSuccess == nil {
+		return map[string]interface{}{
+			"status":  "no_successful_run",
+			"message": "No file has been processed successfully yet",
+		}
+	}
+	return map[string]interface{}{
+		"status":                     "healthy",
+		"last_success_at":            lastSuccess.CompletedAt,
+		"last_success_file":          lastSuccess.Filename,
+		"last_success_records":       lastSuccess.ProcessedRecords,
+		"last_success_failed":        lastSuccess.FailedRecords,
+		"seconds_since_last_success": int64(time.Since(lastSuccess.CompletedAt).Seconds()),
+	}
+}
+
+// fileProcessorCheck describes whether the file processor's working and error directories are
+// writable, and reports false when one is not
+func (h *HealthHandler) fileProcessorCheck(ctx context.Context) (map[string]interface{}, bool) {
+	directories := h.fileService.CheckDirectories(ctx)
+	check := map[string]interface{}{
+		"status":            "healthy",
+		"working_directory": directoryCheck(directories.WorkingDirectory),
+		"error_directory":   directoryCheck(directories.ErrorDirectory),
+	}
+	if !directories.Writable() {
+		check["status"] = "unhealthy"
+		return check, false
+	}
+	return check, true
+}
+
+// writeTransactionsCheck describes the usage of the database write transaction limit
+func writeTransactionsCheck(stats database.TransactionLimiterStats) map[string]interface{} {
+	status := "healthy"
+	if stats.Waiting > 0 {
+		status = "saturated"
+	}
+	return map[string]interface{}{
+		"status":  status,
+		"limit":   stats.Limit,
+		"in_use":  stats.InUse,
+		"waiting": stats.Waiting,
+	}
+}
+
+// directoryCheck describes a single file processing directory
+func directoryCheck(directory dto.FileDirectoryHealthDTO) map[string]interface{} {
+	check := map[string]interface{}{
+		"path":     directory.Path,
+		"writable": directory.Writable,
+	}
+	if directory.Error != "" {
+		check["error"] = directory.Error
+	}
+	return check
+}
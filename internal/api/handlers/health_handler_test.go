@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/external"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// countingPortfolioClient counts health pings
+type countingPortfolioClient struct {
+	external.PortfolioClient
+	pings int
+	err   error
+}
+
+func (c *countingPortfolioClient) Health(ctx context.Context) error {
+	c.pings++
+	return c.err
+}
+
+// countingSecurityClient counts health pings
+type countingSecurityClient struct {
+	external.SecurityClient
+	pings int
+	err   error
+}
+
+func (c *countingSecurityClient) Health(ctx context.Context) error {
+	c.pings++
+	return c.err
+}
+
+func probe(handler http.HandlerFunc, path string) int {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code
+}
+
+func TestHealthHandler_CheckCache(t *testing.T) {
+	t.Run("Second probe within TTL does not re-ping dependencies", func(t *testing.T) {
+		portfolio := &countingPortfolioClient{}
+		security := &countingSecurityClient{}
+		handler := NewHealthHandler(portfolio, security, logger.NewNoop(), "test", "test").
+			WithCheckCacheTTL(time.Minute)
+
+		assert.Equal(t, http.StatusOK, probe(handler.GetReadiness, "/health/ready"))
+		assert.Equal(t, http.StatusOK, probe(handler.GetReadiness, "/health/ready"))
+		assert.Equal(t, http.StatusOK, probe(handler.GetDetailedHealth, "/health/detailed"))
+
+		assert.Equal(t, 1, portfolio.pings)
+		assert.Equal(t, 1, security.pings)
+	})
+
+	t.Run("Cached failure is reported until TTL expires", func(t *testing.T) {
+		portfolio := &countingPortfolioClient{err: errors.New("connection refused")}
+		security := &countingSecurityClient{}
+		handler := NewHealthHandler(portfolio, security, logger.NewNoop(), "test", "test").
+			WithCheckCacheTTL(time.Minute)
+
+		assert.Equal(t, http.StatusServiceUnavailable, probe(handler.GetReadiness, "/health/ready"))
+		portfolio.err = nil
+		assert.Equal(t, http.StatusServiceUnavailable, probe(handler.GetReadiness, "/health/ready"))
+		assert.Equal(t, 1, portfolio.pings)
+	})
+
+	t.Run("Expired result is re-checked", func(t *testing.T) {
+		portfolio := &countingPortfolioClient{}
+		security := &countingSecurityClient{}
+		handler := NewHealthHandler(portfolio, security, logger.NewNoop(), "test", "test").
+			WithCheckCacheTTL(10 * time.Millisecond)
+
+		probe(handler.GetReadiness, "/health/ready")
+		time.Sleep(20 * time.Millisecond)
+		portfolio.err = errors.New("connection refused")
+
+		assert.Equal(t, http.StatusServiceUnavailable, probe(handler.GetReadiness, "/health/ready"))
+		assert.Equal(t, 2, portfolio.pings)
+	})
+
+	t.Run("Zero TTL pings on every probe", func(t *testing.T) {
+		portfolio := &countingPortfolioClient{}
+		security := &countingSecurityClient{}
+		handler := NewHealthHandler(portfolio, security, logger.NewNoop(), "test", "test")
+
+		probe(handler.GetReadiness, "/health/ready")
+		probe(handler.GetReadiness, "/health/ready")
+
+		assert.Equal(t, 2, portfolio.pings)
+		assert.Equal(t, 2, security.pings)
+	})
+}
+
+// slowPortfolioClient answers health pings after a delay, or when the context is done
+type slowPortfolioClient struct {
+	external.PortfolioClient
+	delay atomic.Int64 // nanoseconds
+}
+
+func newSlowPortfolioClient(delay time.Duration) *slowPortfolioClient {
+	client := &slowPortfolioClient{}
+	client.delay.Store(int64(delay))
+	return client
+}
+
+func (c *slowPortfolioClient) Health(ctx context.Context) error {
+	select {
+	case <-time.After(time.Duration(c.delay.Load())):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestHealthHandler_DetailedChecksRunConcurrently(t *testing.T) {
+	handler := NewHealthHandler(newSlowPortfolioClient(5*time.Second), &countingSecurityClient{}, logger.NewNoop(), "test", "test").
+		WithCheckTimeout(100 * time.Millisecond)
+
+	started := time.Now()
+	rec := httptest.NewRecorder()
+	handler.GetDetailedHealth(rec, httptest.NewRequest(http.MethodGet, "/health/detailed", nil))
+	elapsed := time.Since(started)
+
+	assert.Less(t, elapsed, time.Second, "the slow dependency does not hold up the response")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	var body struct {
+		Status string                            `json:"status"`
+		Checks map[string]map[string]interface{} `json:"checks"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "degraded", body.Status)
+
+	portfolio := body.Checks["portfolio_service"]
+	assert.Equal(t, "unhealthy", portfolio["status"])
+	assert.Contains(t, portfolio["error"], "did not complete")
+	assert.GreaterOrEqual(t, portfolio["latency_ms"], float64(100))
+
+	security := body.Checks["security_service"]
+	assert.Equal(t, "healthy", security["status"])
+	assert.Contains(t, security, "latency_ms")
+}
+
+func TestHealthHandler_DetailedCheckTimeoutIsNotCached(t *testing.T) {
+	portfolio := newSlowPortfolioClient(5 * time.Second)
+	handler := NewHealthHandler(portfolio, &countingSecurityClient{}, logger.NewNoop(), "test", "test").
+		WithCheckCacheTTL(time.Minute).
+		WithCheckTimeout(20 * time.Millisecond)
+
+	assert.Equal(t, http.StatusServiceUnavailable, probe(handler.GetDetailedHealth, "/health/detailed"))
+
+	// Once the dependency recovers the next probe checks it again
+	portfolio.delay.Store(0)
+	assert.Eventually(t, func() bool {
+		return probe(handler.GetDetailedHealth, "/health/detailed") == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+}
+
+// stubLastSuccessFileService reports a fixed last successful file processing run
+type stubLastSuccessFileService struct {
+	services.FileProcessorService
+	lastSuccess *dto.FileProcessingSuccessDTO
+}
+
+func (s *stubLastSuccessFileService) GetLastSuccessfulProcessing(ctx context.Context) (*dto.FileProcessingSuccessDTO, error) {
+	return s.lastSuccess, nil
+}
+
+func (s *stubLastSuccessFileService) CheckDirectories(ctx context.Context) dto.FileDirectoriesHealthDTO {
+	return dto.FileDirectoriesHealthDTO{
+		WorkingDirectory: dto.FileDirectoryHealthDTO{Path: "/data/files", Writable: true},
+		ErrorDirectory:   dto.FileDirectoryHealthDTO{Path: "/data/errors", Writable: true},
+	}
+}
+
+func TestHealthHandler_FileProcessing(t *testing.T) {
+	detailedChecks := func(t *testing.T, fileService services.FileProcessorService) map[string]interface{} {
+		handler := NewHealthHandler(&countingPortfolioClient{}, &countingSecurityClient{}, logger.NewNoop(), "test", "test").
+			WithFileProcessor(fileService)
+
+		rec := httptest.NewRecorder()
+		handler.GetDetailedHealth(rec, httptest.NewRequest(http.MethodGet, "/health/detailed", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response struct {
+			Checks map[string]map[string]interface{} `json:"checks"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Contains(t, response.Checks, "file_processing")
+		return response.Checks["file_processing"]
+	}
+
+	t.Run("Reports the last successful run", func(t *testing.T) {
+		completedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		check := detailedChecks(t, &stubLastSuccessFileService{lastSuccess: &dto.FileProcessingSuccessDTO{
+			Filename: "transactions.csv", CompletedAt: completedAt, ProcessedRecords: 250, FailedRecords: 3,
+		}})
+
+		assert.Equal(t, "healthy", check["status"])
+		assert.Equal(t, "2024-03-01T12:00:00Z", check["last_success_at"])
+		assert.Equal(t, "transactions.csv", check["last_success_file"])
+		assert.Equal(t, float64(250), check["last_success_records"])
+	})
+
+	t.Run("No run yet does not degrade health", func(t *testing.T) {
+		check := detailedChecks(t, &stubLastSuccessFileService{})
+		assert.Equal(t, "no_successful_run", check["status"])
+	})
+}
+
+func TestHealthHandler_FileProcessorDirectories(t *testing.T) {
+	newHandler := func(workingDirectory, errorDirectory string) *HealthHandler {
+		fileService := services.NewFileProcessorService(nil, services.FileProcessorConfig{
+			WorkingDirectory:   workingDirectory,
+			ErrorFileDirectory: errorDirectory,
+			ProgressDirectory:  filepath.Join(t.TempDir(), "progress"),
+		}, logger.NewNoop())
+		return NewHealthHandler(&countingPortfolioClient{}, &countingSecurityClient{}, logger.NewNoop(), "test", "test").
+			WithFileProcessor(fileService)
+	}
+	fileProcessorCheck := func(t *testing.T, handler http.HandlerFunc, path string, expectedCode int) map[string]interface{} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, expectedCode, rec.Code)
+
+		var response struct {
+			Checks map[string]map[string]interface{} `json:"checks"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Contains(t, response.Checks, "file_processor")
+		return response.Checks["file_processor"]
+	}
+
+	t.Run("Writable directories", func(t *testing.T) {
+		dir := t.TempDir()
+		handler := newHandler(filepath.Join(dir, "files"), filepath.Join(dir, "errors"))
+
+		check := fileProcessorCheck(t, handler.GetReadiness, "/health/ready", http.StatusOK)
+		assert.Equal(t, "healthy", check["status"])
+		assert.Equal(t, true, check["working_directory"].(map[string]interface{})["writable"])
+		assert.Equal(t, true, check["error_directory"].(map[string]interface{})["writable"])
+
+		fileProcessorCheck(t, handler.GetDetailedHealth, "/health/detailed", http.StatusOK)
+	})
+
+	t.Run("Read-only error directory", func(t *testing.T) {
+		dir := t.TempDir()
+		errorDirectory := filepath.Join(dir, "errors")
+		handler := newHandler(filepath.Join(dir, "files"), errorDirectory)
+		require.NoError(t, os.Chmod(errorDirectory, 0555))
+		t.Cleanup(func() { os.Chmod(errorDirectory, 0755) })
+		if probeFile, err := os.Create(filepath.Join(errorDirectory, "probe")); err == nil {
+			probeFile.Close()
+			t.Skip("directory permissions are not enforced for this user")
+		}
+
+		check := fileProcessorCheck(t, handler.GetReadiness, "/health/ready", http.StatusServiceUnavailable)
+		assert.Equal(t, "unhealthy", check["status"])
+		assert.Equal(t, true, check["working_directory"].(map[string]interface{})["writable"])
+		errorCheck := check["error_directory"].(map[string]interface{})
+		assert.Equal(t, false, errorCheck["writable"])
+		assert.Equal(t, errorDirectory, errorCheck["path"])
+		assert.Contains(t, errorCheck["error"], "not writable")
+
+		check = fileProcessorCheck(t, handler.GetDetailedHealth, "/health/detailed", http.StatusServiceUnavailable)
+		assert.Equal(t, "unhealthy", check["status"])
+	})
+
+	t.Run("Working directory is a file", func(t *testing.T) {
+		dir := t.TempDir()
+		workingDirectory := filepath.Join(dir, "files")
+		require.NoError(t, os.WriteFile(workingDirectory, []byte("not a directory"), 0644))
+		handler := newHandler(workingDirectory, filepath.Join(dir, "errors"))
+
+		check := fileProcessorCheck(t, handler.GetReadiness, "/health/ready", http.StatusServiceUnavailable)
+		workingCheck := check["working_directory"].(map[string]interface{})
+		assert.Equal(t, false, workingCheck["writable"])
+		assert.Contains(t, workingCheck["error"], "not a directory")
+	})
+}
+
+func TestHealthHandler_WriteTransactions(t *testing.T) {
+	detailedChecks := func(t *testing.T, stats database.TransactionLimiterStats) map[string]map[string]interface{} {
+		handler := NewHealthHandler(&countingPortfolioClient{}, &countingSecurityClient{}, logger.NewNoop(), "test", "test").
+			WithWriteTransactionStats(func() database.TransactionLimiterStats { return stats })
+
+		rec := httptest.NewRecorder()
+		handler.GetDetailedHealth(rec, httptest.NewRequest(http.MethodGet, "/health/detailed", nil))
+		require.Equal(t, http.StatusOK, rec.Code, "a full limit does not degrade health")
+
+		var response struct {
+			Checks map[string]map[string]interface{} `json:"checks"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response.Checks
+	}
+
+	t.Run("Reports the usage of the limit", func(t *testing.T) {
+		checks := detailedChecks(t, database.TransactionLimiterStats{Limit: 12, InUse: 12, Waiting: 3})
+		require.Contains(t, checks, "database_write_transactions")
+
+		check := checks["database_write_transactions"]
+		assert.Equal(t, "saturated", check["status"])
+		assert.Equal(t, float64(12), check["limit"])
+		assert.Equal(t, float64(12), check["in_use"])
+		assert.Equal(t, float64(3), check["waiting"])
+	})
+
+	t.Run("Unlimited write transactions are not reported", func(t *testing.T) {
+		checks := detailedChecks(t, database.TransactionLimiterStats{})
+		assert.NotContains(t, checks, "database_write_transactions")
+	})
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+)
+
+// listFilterTooLargeMessage describes a query rejected because a list filter held more values
+// than the configured maximum. ok is false for any other error.
+func listFilterTooLargeMessage(err error) (message string, ok bool) {
+	if !repositories.IsListFilterTooLargeError(err) {
+		return "", false
+	}
+	var repoErr *repositories.RepositoryError
+	if errors.As(err, &repoErr) && repoErr.Context["field"] != nil {
+		return fmt.Sprintf("Filter %v has %v values, at most %v are allowed",
+			repoErr.Context["field"], repoErr.Context["size"], repoErr.Context["max"]), true
+	}
+	return "List filter has too many values", true
+}
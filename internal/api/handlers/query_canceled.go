@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+)
+
+// statusClientClosedRequest is the non-standard status, introduced by nginx, recorded for
+// requests whose client disconnected before the response was written
+const statusClientClosedRequest = 499
+
+// queryCanceledStatus maps a query abandoned because the request's context ended to a response
+// status and error code: 499 when the client went away and 504 when the request timed out. ok
+// is false for any other error.
+func queryCanceledStatus(err error) (status int, code string, ok bool) {
+	if !repositories.IsQueryCanceledError(err) {
+		return 0, "", false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout, "QUERY_TIMEOUT", true
+	}
+	return statusClientClosedRequest, "CLIENT_CLOSED_REQUEST", true
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// RetentionHandler handles HTTP requests for deleting transactions past their retention window
+type RetentionHandler struct {
+	retentionService services.TransactionRetentionService
+	logger           logger.Logger
+}
+
+// NewRetentionHandler creates a new retention handler
+func NewRetentionHandler(retentionService services.TransactionRetentionService, logger logger.Logger) *RetentionHandler {
+	return &RetentionHandler{
+		retentionService: retentionService,
+		logger:           logger,
+	}
+}
+
+// CountExpiredTransactions reports how many transactions a retention delete would remove
+// @Summary Count transactions past retention
+// @Description Dry run of the retention delete: count the transactions dated before the cutoff, limited to the given final statuses (all of PROC, FATAL and DEAD by default). Nothing is deleted.
+// @Tags Admin
+// @Produce json
+// @Param before query string true "Cutoff date (YYYY-MM-DD format); must be at least retention.minimum_age_days in the past"
+// @Param statuses query string false "Comma-separated final statuses: PROC, FATAL, DEAD"
+// @Success 200 {object} dto.TransactionRetentionResponse "Matching transactions counted"
+// @Failure 400 {object} dto.ErrorResponse "Invalid cutoff or statuses"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/retention/transactions [get]
+func (h *RetentionHandler) CountExpiredTransactions(w http.ResponseWriter, r *http.Request) {
+	h.purge(w, r, true)
+}
+
+// DeleteExpiredTransactions deletes transactions past their retention window
+// @Summary Delete transactions past retention
+// @Description Delete the transactions dated before the cutoff, limited to the given final statuses (all of PROC, FATAL and DEAD by default), in bounded batches together with their audit history. Balances are not recomputed: they already reflect the deleted transactions, so recompute and consistency checks of affected portfolios no longer match the stored balances. Requires confirm=true.
+// @Tags Admin
+// @Produce json
+// @Param before query string true "Cutoff date (YYYY-MM-DD format); must be at least retention.minimum_age_days in the past"
+// @Param statuses query string false "Comma-separated final statuses: PROC, FATAL, DEAD"
+// @Param confirm query bool true "Must be true to delete"
+// @Success 200 {object} dto.TransactionRetentionResponse "Transactions deleted"
+// @Failure 400 {object} dto.ErrorResponse "Invalid cutoff or statuses, or missing confirmation"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/retention/transactions [delete]
+func (h *RetentionHandler) DeleteExpiredTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("confirm") != "true" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "CONFIRMATION_REQUIRED", "confirm=true is required to delete transactions")
+		return
+	}
+	h.purge(w, r, false)
+}
+
+// purge parses the retention query and runs it, deleting unless dryRun is set
+func (h *RetentionHandler) purge(w http.ResponseWriter, r *http.Request, dryRun bool) {
+	ctx := r.Context()
+
+	h.logger.Info(r.Method+" /api/v1/admin/retention/transactions",
+		zap.String("query", r.URL.RawQuery),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	before := r.URL.Query().Get("before")
+	if before == "" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_BEFORE", "before query parameter is required")
+		return
+	}
+	cutoff, err := time.Parse("2006-01-02", before)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_BEFORE", "before must be in YYYY-MM-DD format")
+		return
+	}
+
+	request := dto.TransactionRetentionRequest{Before: cutoff, DryRun: dryRun}
+	if statuses := r.URL.Query().Get("statuses"); statuses != "" {
+		for _, status := range strings.Split(statuses, ",") {
+			request.Statuses = append(request.Statuses, strings.TrimSpace(status))
+		}
+	}
+
+	result, err := h.retentionService.PurgeTransactions(ctx, request)
+	if err != nil {
+		var validationErr *services.RetentionValidationError
+		if errors.As(err, &validationErr) {
+			h.writeValidationErrorResponse(w, validationErr.Errors)
+			return
+		}
+		h.logger.Error("Failed to apply transaction retention", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to apply transaction retention")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// writeValidationErrorResponse writes a 400 response listing the retention validation errors
+func (h *RetentionHandler) writeValidationErrorResponse(w http.ResponseWriter, validationErrors []dto.ValidationError) {
+	errorResp := dto.ErrorResponse{
+		Error: dto.ErrorDetail{
+			Code:      "VALIDATION_FAILED",
+			Message:   "Retention request validation failed",
+			Details:   map[string]interface{}{"errors": validationErrors},
+			Timestamp: time.Now(),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		h.logger.Error("Failed to write error response", zap.Error(err))
+	}
+}
+
+// writeErrorResponse writes a standardized error response
+func (h *RetentionHandler) writeErrorResponse(w http.ResponseWriter, statusCode int, errorCode, message string) {
+	errorResp := dto.ErrorResponse{
+		Error: dto.ErrorDetail{
+			Code:      errorCode,
+			Message:   message,
+			Timestamp: time.Now(),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		h.logger.Error("Failed to write error response", zap.Error(err))
+	}
+}
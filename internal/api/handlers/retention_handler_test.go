@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// recordingRetentionService records retention requests and reports one match per request
+type recordingRetentionService struct {
+	requests []dto.TransactionRetentionRequest
+}
+
+func (s *recordingRetentionService) PurgeTransactions(ctx context.Context, request dto.TransactionRetentionRequest) (*dto.TransactionRetentionResponse, error) {
+	s.requests = append(s.requests, request)
+
+	response := &dto.TransactionRetentionResponse{
+		Before:   request.Before.Format("2006-01-02"),
+		Statuses: request.Statuses,
+		DryRun:   request.DryRun,
+		Matched:  1,
+	}
+	if !request.DryRun {
+		response.Deleted = 1
+	}
+	return response, nil
+}
+
+func TestRetentionHandler(t *testing.T) {
+	setup := func() (*recordingRetentionService, http.Handler) {
+		service := &recordingRetentionService{}
+		handler := NewRetentionHandler(service, logger.NewNoop())
+
+		r := chi.NewRouter()
+		r.Get("/api/v1/admin/retention/transactions", handler.CountExpiredTransactions)
+		r.Delete("/api/v1/admin/retention/transactions", handler.DeleteExpiredTransactions)
+		return service, r
+	}
+
+	t.Run("GET is a dry run", func(t *testing.T) {
+		service, router := setup()
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/retention/transactions?before=2023-01-01&statuses=PROC,FATAL", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		require.Len(t, service.requests, 1)
+		assert.True(t, service.requests[0].DryRun)
+		assert.Equal(t, []string{"PROC", "FATAL"}, service.requests[0].Statuses)
+
+		var response dto.TransactionRetentionResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, int64(1), response.Matched)
+		assert.Equal(t, int64(0), response.Deleted)
+	})
+
+	t.Run("DELETE requires confirmation", func(t *testing.T) {
+		service, router := setup()
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/admin/retention/transactions?before=2023-01-01", nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "CONFIRMATION_REQUIRED")
+		assert.Empty(t, service.requests)
+
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/admin/retention/transactions?before=2023-01-01&confirm=true", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, service.requests, 1)
+		assert.False(t, service.requests[0].DryRun)
+	})
+
+	t.Run("Invalid cutoff is rejected", func(t *testing.T) {
+		service, router := setup()
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/retention/transactions?before=20230101", nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, service.requests)
+	})
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/cache"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Names of the cached stats responses, used in cache keys and lookup metrics
+const (
+	transactionStatsResponse = "transaction_stats"
+	balanceStatsResponse     = "balance_stats"
+	combinedStatsResponse    = "stats"
+)
+
+// StatsHandler handles HTTP requests for transaction and balance statistics. The statistics are
+// expensive to compute and change slowly, so responses are served from a short-lived response
+// cache when one is configured.
+type StatsHandler struct {
+	transactionService services.TransactionService
+	balanceService     services.BalanceService
+	responseCache      *cache.ResponseCache
+	logger             logger.Logger
+}
+
+// NewStatsHandler creates a new stats handler. A nil responseCache computes every response.
+func NewStatsHandler(
+	transactionService services.TransactionService,
+	balanceService services.BalanceService,
+	responseCache *cache.ResponseCache,
+	logger logger.Logger,
+) *StatsHandler {
+	return &StatsHandler{
+		transactionService: transactionService,
+		balanceService:     balanceService,
+		responseCache:      responseCache,
+		logger:             logger,
+	}
+}
+
+// GetTransactionStats returns transaction statistics
+// @Summary Get transaction statistics
+// @Description Counts of all transactions by status and type. Responses are cached for cache.stats_ttl; refresh=true recomputes and replaces the cached response.
+// @Tags Transactions
+// @Produce json
+// @Param refresh query bool false "Bypass the cached response"
+// @Success 200 {object} dto.TransactionStatsDTO "Transaction statistics"
+// @Failure 400 {object} dto.ErrorResponse "Invalid refresh value"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Failure 504 {object} dto.ErrorResponse "Request timed out before the query completed"
+// @Security ApiKeyAuth
+// @Router /transactions/stats [get]
+func (h *StatsHandler) GetTransactionStats(w http.ResponseWriter, r *http.Request) {
+	h.serveStats(w, r, transactionStatsResponse, "", func() (interface{}, error) {
+		return h.transactionService.GetTransactionStats(r.Context(), dto.TransactionFilter{})
+	})
+}
+
+// GetBalanceStats returns balance statistics
+// @Summary Get balance statistics
+// @Description Counts of the balances matching the filter, of their portfolios and securities, and of cash and zero balances. Responses are cached per filter for cache.stats_ttl; refresh=true recomputes and replaces the cached response.
+// @Tags Balances
+// @Produce json
+// @Param portfolio_id query string false "Filter by portfolio ID (24 characters)"
+// @Param security_id query string false "Filter by security ID (24 characters). Use 'null' for cash balances"
+// @Param scope query string false "Balance scope" Enums(ALL,CASH_ONLY,SECURITIES_ONLY)
+// @Param refresh query bool false "Bypass the cached response"
+// @Success 200 {object} dto.BalanceStatsDTO "Balance statistics"
+// @Failure 400 {object} dto.ErrorResponse "Invalid filter or refresh value"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Failure 504 {object} dto.ErrorResponse "Request timed out before the query completed"
+// @Security ApiKeyAuth
+// @Router /balances/stats [get]
+func (h *StatsHandler) GetBalanceStats(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseBalanceFilter(r)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_FILTER", err.Error())
+		return
+	}
+
+	h.serveStats(w, r, balanceStatsResponse, filterVariant(filter), func() (interface{}, error) {
+		return h.balanceService.GetBalanceStats(r.Context(), *filter)
+	})
+}
+
+// GetStats returns transaction and balance statistics together
+// @Summary Get combined statistics
+// @Description Transaction statistics and the statistics of the balances matching the filter in one response. Responses are cached per filter for cache.stats_ttl; refresh=true recomputes and replaces the cached response.
+// @Tags Statistics
+// @Produce json
+// @Param portfolio_id query string false "Filter balances by portfolio ID (24 characters)"
+// @Param security_id query string false "Filter balances by security ID (24 characters). Use 'null' for cash balances"
+// @Param scope query string false "Balance scope" Enums(ALL,CASH_ONLY,SECURITIES_ONLY)
+// @Param refresh query bool false "Bypass the cached response"
+// @Success 200 {object} dto.StatsResponse "Combined statistics"
+// @Failure 400 {object} dto.ErrorResponse "Invalid filter or refresh value"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Failure 504 {object} dto.ErrorResponse "Request timed out before the query completed"
+// @Security ApiKeyAuth
+// @Router /stats [get]
+func (h *StatsHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseBalanceFilter(r)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_FILTER", err.Error())
+		return
+	}
+
+	h.serveStats(w, r, combinedStatsResponse, filterVariant(filter), func() (interface{}, error) {
+		transactionStats, err := h.transactionService.GetTransactionStats(r.Context(), dto.TransactionFilter{})
+		if err != nil {
+			return nil, err
+		}
+		balanceStats, err := h.balanceService.GetBalanceStats(r.Context(), *filter)
+		if err != nil {
+			return nil, err
+		}
+		return dto.StatsResponse{Transactions: transactionStats, Balances: balanceStats}, nil
+	})
+}
+
+// serveStats writes the cached response of name for variant, or computes, caches and writes it.
+// X-Cache reports whether the response came from the cache.
+func (h *StatsHandler) serveStats(w http.ResponseWriter, r *http.Request, name, variant string, compute func() (interface{}, error)) {
+	ctx := r.Context()
+
+	refresh := false
+	if refreshStr := r.URL.Query().Get("refresh"); refreshStr != "" {
+		parsed, err := strconv.ParseBool(refreshStr)
+		if err != nil {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_PARAMETER", "refresh must be a boolean")
+			return
+		}
+		refresh = parsed
+	}
+
+	h.logger.Info("GET "+r.URL.Path,
+		zap.Bool("refresh", refresh),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	if h.responseCache != nil {
+		if body, ok := h.responseCache.Get(ctx, name, variant, refresh); ok {
+			h.writeStats(w, body, "HIT")
+			return
+		}
+	}
+
+	result, err := compute()
+	if err != nil {
+		if status, code, ok := queryCanceledStatus(err); ok {
+			h.logger.Debug("Stats query canceled", zap.Error(err))
+			h.writeErrorResponse(w, status, code, "Request ended before the statistics were computed")
+			return
+		}
+		h.logger.Error("Failed to compute statistics", zap.Error(err), zap.String("response", name))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve statistics")
+		return
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		h.logger.Error("Failed to encode statistics", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to encode statistics")
+		return
+	}
+
+	if h.responseCache != nil {
+		h.responseCache.Set(ctx, name, variant, body)
+	}
+	h.writeStats(w, body, "MISS")
+}
+
+// writeStats writes an encoded statistics response
+func (h *StatsHandler) writeStats(w http.ResponseWriter, body []byte, cacheStatus string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Cache", cacheStatus)
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		h.logger.Error("Failed to write response", zap.Error(err))
+	}
+}
+
+// filterVariant identifies a filter in cache keys. Encoding the parsed filter rather than the
+// query string makes equivalent queries share an entry.
+func filterVariant(filter interface{}) string {
+	encoded, err := json.Marshal(filter)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8])
+}
+
+// writeErrorResponse writes a standardized error response
+func (h *StatsHandler) writeErrorResponse(w http.ResponseWriter, statusCode int, errorCode, message string) {
+	errorResp := dto.ErrorResponse{
+		Error: dto.ErrorDetail{
+			Code:      errorCode,
+			Message:   message,
+			Timestamp: time.Now(),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		h.logger.Error("Failed to write error response", zap.Error(err))
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/cache"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// countingStatsTransactionService counts the statistics queries that reach it
+type countingStatsTransactionService struct {
+	services.TransactionService
+	calls int
+}
+
+func (s *countingStatsTransactionService) GetTransactionStats(ctx context.Context, filter dto.TransactionFilter) (*dto.TransactionStatsDTO, error) {
+	s.calls++
+	return &dto.TransactionStatsDTO{TotalCount: int64(10 * s.calls)}, nil
+}
+
+// countingStatsBalanceService counts the statistics queries that reach it and records their filters
+type countingStatsBalanceService struct {
+	services.BalanceService
+	filters []dto.BalanceFilter
+}
+
+func (s *countingStatsBalanceService) GetBalanceStats(ctx context.Context, filter dto.BalanceFilter) (*dto.BalanceStatsDTO, error) {
+	s.filters = append(s.filters, filter)
+	return &dto.BalanceStatsDTO{TotalBalances: int64(len(s.filters))}, nil
+}
+
+func TestStatsHandler(t *testing.T) {
+	const portfolioID = "PORTFOLIO123456789012345"
+
+	setup := func(t *testing.T, ttl time.Duration) (*StatsHandler, *countingStatsTransactionService, *countingStatsBalanceService, *sdkmetric.ManualReader) {
+		memory := cache.NewMemoryCache(cache.MemoryCacheOptions{MaxEntries: 100, Logger: logger.NewNoop()})
+		t.Cleanup(func() { _ = memory.Close() })
+
+		reader := sdkmetric.NewManualReader()
+		responseCache := cache.NewResponseCache(memory, "test", ttl, cache.OperationOptions{
+			MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		}, logger.NewNoop())
+
+		transactionService := &countingStatsTransactionService{}
+		balanceService := &countingStatsBalanceService{}
+		return NewStatsHandler(transactionService, balanceService, responseCache, logger.NewNoop()),
+			transactionService, balanceService, reader
+	}
+
+	get := func(handle http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handle(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	lookups := func(t *testing.T, reader *sdkmetric.ManualReader) map[string]int64 {
+		var collected metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &collected))
+		counts := make(map[string]int64)
+		for _, scope := range collected.ScopeMetrics {
+			for _, m := range scope.Metrics {
+				if m.Name != "response_cache_lookups_total" {
+					continue
+				}
+				sum, ok := m.Data.(metricdata.Sum[int64])
+				require.True(t, ok)
+				for _, point := range sum.DataPoints {
+					result, _ := point.Attributes.Value("result")
+					counts[result.AsString()] += point.Value
+				}
+			}
+		}
+		return counts
+	}
+
+	t.Run("Second call within the TTL is served from the cache", func(t *testing.T) {
+		handler, transactionService, _, reader := setup(t, time.Minute)
+
+		first := get(handler.GetTransactionStats, "/api/v1/transactions/stats")
+		require.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, "MISS", first.Header().Get("X-Cache"))
+
+		second := get(handler.GetTransactionStats, "/api/v1/transactions/stats")
+		require.Equal(t, http.StatusOK, second.Code)
+		assert.Equal(t, "HIT", second.Header().Get("X-Cache"))
+		assert.JSONEq(t, first.Body.String(), second.Body.String())
+
+		assert.Equal(t, 1, transactionService.calls, "the cached response must not query again")
+		assert.Equal(t, map[string]int64{"miss": 1, "hit": 1}, lookups(t, reader))
+	})
+
+	t.Run("Refresh bypasses and replaces the cached response", func(t *testing.T) {
+		handler, transactionService, _, reader := setup(t, time.Minute)
+
+		get(handler.GetTransactionStats, "/api/v1/transactions/stats")
+		rec := get(handler.GetTransactionStats, "/api/v1/transactions/stats?refresh=true")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+		assert.Equal(t, 2, transactionService.calls)
+
+		var refreshed dto.TransactionStatsDTO
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &refreshed))
+		assert.Equal(t, int64(20), refreshed.TotalCount)
+
+		// The next plain call sees the refreshed response
+		rec = get(handler.GetTransactionStats, "/api/v1/transactions/stats")
+		assert.Equal(t, "HIT", rec.Header().Get("X-Cache"))
+		assert.Contains(t, rec.Body.String(), `"totalCount":20`)
+		assert.Equal(t, 2, transactionService.calls)
+		assert.Equal(t, map[string]int64{"miss": 1, "refresh": 1, "hit": 1}, lookups(t, reader))
+	})
+
+	t.Run("Balance stats are cached per filter", func(t *testing.T) {
+		handler, _, balanceService, _ := setup(t, time.Minute)
+
+		get(handler.GetBalanceStats, "/api/v1/balances/stats?portfolio_id="+portfolioID)
+		get(handler.GetBalanceStats, "/api/v1/balances/stats?portfolio_id="+portfolioID)
+		rec := get(handler.GetBalanceStats, "/api/v1/balances/stats?security_id=null")
+		assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+
+		require.Len(t, balanceService.filters, 2)
+		require.NotNil(t, balanceService.filters[0].PortfolioID)
+		assert.Equal(t, portfolioID, *balanceService.filters[0].PortfolioID)
+		require.NotNil(t, balanceService.filters[1].CashOnly)
+	})
+
+	t.Run("Combined stats", func(t *testing.T) {
+		handler, transactionService, balanceService, _ := setup(t, time.Minute)
+
+		rec := get(handler.GetStats, "/api/v1/stats")
+		require.Equal(t, http.StatusOK, rec.Code)
+		var response dto.StatsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.NotNil(t, response.Transactions)
+		require.NotNil(t, response.Balances)
+		assert.Equal(t, int64(10), response.Transactions.TotalCount)
+
+		get(handler.GetStats, "/api/v1/stats")
+		assert.Equal(t, 1, transactionService.calls)
+		assert.Len(t, balanceService.filters, 1)
+	})
+
+	t.Run("Expired response is recomputed", func(t *testing.T) {
+		handler, transactionService, _, _ := setup(t, 20*time.Millisecond)
+
+		get(handler.GetTransactionStats, "/api/v1/transactions/stats")
+		time.Sleep(40 * time.Millisecond)
+		rec := get(handler.GetTransactionStats, "/api/v1/transactions/stats")
+		assert.Equal(t, "MISS", rec.Header().Get("X-Cache"))
+		assert.Equal(t, 2, transactionService.calls)
+	})
+
+	t.Run("Without a cache every call is computed", func(t *testing.T) {
+		transactionService := &countingStatsTransactionService{}
+		handler := NewStatsHandler(transactionService, &countingStatsBalanceService{}, nil, logger.NewNoop())
+
+		get(handler.GetTransactionStats, "/api/v1/transactions/stats")
+		get(handler.GetTransactionStats, "/api/v1/transactions/stats")
+		assert.Equal(t, 2, transactionService.calls)
+	})
+
+	t.Run("Invalid refresh value", func(t *testing.T) {
+		handler, transactionService, _, _ := setup(t, time.Minute)
+
+		rec := get(handler.GetTransactionStats, "/api/v1/transactions/stats?refresh=maybe")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Zero(t, transactionService.calls)
+	})
+}
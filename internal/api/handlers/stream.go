@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ndjsonContentType is the media type of newline-delimited JSON streams
+	ndjsonContentType = "application/x-ndjson"
+
+	// jsonContentType is the media type of streamed JSON arrays
+	jsonContentType = "application/json"
+
+	// streamQueryParam is the query parameter requesting a streamed JSON array
+	streamQueryParam = "stream"
+
+	// streamFlushInterval is how many streamed records are written between flushes
+	streamFlushInterval = 100
+)
+
+// streamFormat returns the content type a list should be streamed as: NDJSON when the client
+// accepts it, a JSON array when stream=true, or an empty string for a regular paginated response
+func streamFormat(r *http.Request) (string, error) {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(accepted, ";", 2)[0])
+		if strings.EqualFold(mediaType, ndjsonContentType) {
+			return ndjsonContentType, nil
+		}
+	}
+
+	raw := r.URL.Query().Get(streamQueryParam)
+	if raw == "" {
+		return "", nil
+	}
+
+	stream, err := strconv.ParseBool(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s parameter %q: must be true or false", streamQueryParam, raw)
+	}
+	if stream {
+		return jsonContentType, nil
+	}
+	return "", nil
+}
@@ -2,14 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/api/middleware"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	domainServices "github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/services"
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
 	"go.uber.org/zap"
 )
@@ -30,7 +35,7 @@ func NewTransactionHandler(transactionService services.TransactionService, logge
 
 // GetTransactions retrieves transactions with optional filtering, pagination and sorting
 // @Summary Get transactions with filtering
-// @Description Retrieve a list of transactions with optional filtering by portfolio, security, date range, transaction type, and status. Supports pagination and sorting.
+// @Description Retrieve a list of transactions with optional filtering by portfolio, security, date range, transaction type, and status. Supports pagination and sorting, or streaming of the full result as a JSON array (stream=true) or NDJSON (Accept: application/x-ndjson).
 // @Tags Transactions
 // @Accept json
 // @Produce json
@@ -38,13 +43,17 @@ func NewTransactionHandler(transactionService services.TransactionService, logge
 // @Param security_id query string false "Filter by security ID (24 characters). Use 'null' for cash transactions"
 // @Param transaction_date query string false "Filter by transaction date (YYYYMMDD format)"
 // @Param transaction_type query string false "Filter by transaction type" Enums(BUY,SELL,SHORT,COVER,DEP,WD,IN,OUT)
-// @Param status query string false "Filter by transaction status" Enums(NEW,PROC,FATAL,ERROR)
+// @Param status query string false "Filter by transaction status" Enums(NEW,PROC,FATAL,ERROR,DEAD,QUAR)
 // @Param offset query int false "Pagination offset (default: 0)" minimum(0)
-// @Param limit query int false "Number of records to return (default: 50, max: 1000)" minimum(1) maximum(1000)
-// @Param sortby query string false "Sort fields (comma-separated): portfolio_id,security_id,transaction_date,transaction_type,status"
+// @Param limit query int false "Number of records to return (default: 50, max: 1000); a larger limit is clamped and reported in pagination.limitClamped" minimum(1)
+// @Param sortby query string false "Sort fields (comma-separated); GET /transactions/schema lists the supported fields"
+// @Param fields query string false "Sparse fieldset (comma-separated), e.g. id,portfolioId,quantity"
+// @Param stream query bool false "Stream every matching transaction as a JSON array in ID order; offset, limit and sortby are ignored"
+// @Param Accept header string false "application/x-ndjson streams every matching transaction as one JSON object per line"
 // @Success 200 {object} dto.TransactionListResponse "Successfully retrieved transactions"
 // @Failure 400 {object} dto.ErrorResponse "Invalid request parameters"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Failure 504 {object} dto.ErrorResponse "Request timed out before the query completed"
 // @Security ApiKeyAuth
 // @Router /transactions [get]
 func (h *TransactionHandler) GetTransactions(w http.ResponseWriter, r *http.Request) {
@@ -58,17 +67,830 @@ func (h *TransactionHandler) GetTransactions(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// Parse sparse fieldset
+	fields, err := parseFields(r, transactionFields)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_FIELDS", err.Error())
+		return
+	}
+
+	format, err := streamFormat(r)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_STREAM", err.Error())
+		return
+	}
+
+	// Log the request
+	h.logger.Info("GET /api/v1/transactions",
+		zap.Any("filter", filter),
+		zap.String("stream", format),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	if format != "" {
+		h.streamTransactions(w, r, filter, fields, format)
+		return
+	}
+
+	// Get transactions from service
+	result, err := h.transactionService.GetTransactions(ctx, *filter)
+	if err != nil {
+		if status, code, ok := queryCanceledStatus(err); ok {
+			h.logger.Debug("Transaction query canceled", zap.Error(err))
+			h.writeErrorResponse(w, status, code, "Request ended before the transactions were retrieved")
+			return
+		}
+		h.logger.Error("Failed to get transactions", zap.Error(err), zap.Any("filter", filter))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve transactions")
+		return
+	}
+
+	// Project to the requested fields
+	var body interface{} = result
+	if fields != nil {
+		projected, err := projectFields(result.Transactions, fields)
+		if err != nil {
+			h.logger.Error("Failed to project response fields", zap.Error(err))
+			h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve transactions")
+			return
+		}
+		body = struct {
+			Transactions []map[string]json.RawMessage `json:"transactions"`
+			Pagination   dto.PaginationResponse       `json:"pagination"`
+		}{Transactions: projected, Pagination: result.Pagination}
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully retrieved transactions",
+		zap.Int("count", len(result.Transactions)),
+		zap.Int64("total", result.Pagination.Total),
+		zap.Int("page", result.Pagination.Page),
+		zap.Int("limit", result.Pagination.Limit))
+}
+
+// CountTransactions counts the transactions matching a filter
+// @Summary Count transactions
+// @Description Count the transactions matching the filters of GET /transactions without retrieving them. Pagination and sorting parameters are ignored.
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param portfolio_id query string false "Filter by portfolio ID (24 characters)"
+// @Param security_id query string false "Filter by security ID (24 characters). Use 'null' for cash transactions"
+// @Param transaction_date query string false "Filter by transaction date (YYYYMMDD format)"
+// @Param transaction_type query string false "Filter by transaction type" Enums(BUY,SELL,SHORT,COVER,DEP,WD,IN,OUT)
+// @Param status query string false "Filter by transaction status" Enums(NEW,PROC,FATAL,ERROR,DEAD,QUAR)
+// @Success 200 {object} dto.CountResponse "Successfully counted transactions"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request parameters"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Failure 504 {object} dto.ErrorResponse "Request timed out before the query completed"
+// @Security ApiKeyAuth
+// @Router /transactions/count [get]
+func (h *TransactionHandler) CountTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Parse query parameters
+	filter, err := h.parseTransactionFilter(r)
+	if err != nil {
+		h.logger.Error("Failed to parse transaction filter", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_FILTER", err.Error())
+		return
+	}
+
+	// Log the request
+	h.logger.Info("GET /api/v1/transactions/count",
+		zap.Any("filter", filter),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	count, err := h.transactionService.CountTransactions(ctx, *filter)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid filter") {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_FILTER", err.Error())
+			return
+		}
+		if status, code, ok := queryCanceledStatus(err); ok {
+			h.logger.Debug("Transaction count canceled", zap.Error(err))
+			h.writeErrorResponse(w, status, code, "Request ended before the transactions were counted")
+			return
+		}
+		h.logger.Error("Failed to count transactions", zap.Error(err), zap.Any("filter", filter))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to count transactions")
+		return
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(dto.CountResponse{Count: count}); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully counted transactions", zap.Int64("count", count))
+}
+
+// GetTransactionQuerySchema returns the filter and sort fields of the transaction list
+// @Summary Get transaction query schema
+// @Description List the filter and sort fields GET /transactions accepts. Requests are validated against the same allowlist, so a sort field not listed here is rejected.
+// @Tags Transactions
+// @Produce json
+// @Success 200 {object} dto.QuerySchemaDTO "Supported filter and sort fields"
+// @Security ApiKeyAuth
+// @Router /transactions/schema [get]
+func (h *TransactionHandler) GetTransactionQuerySchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(dto.TransactionQuerySchema()); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// GetTransactionByID retrieves a specific transaction by its ID
+// @Summary Get transaction by ID
+// @Description Retrieve a specific transaction using its unique ID
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param id path int true "Transaction ID" minimum(1)
+// @Success 200 {object} dto.TransactionResponseDTO "Successfully retrieved transaction"
+// @Failure 400 {object} dto.ErrorResponse "Invalid transaction ID"
+// @Failure 404 {object} dto.ErrorResponse "Transaction not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /transaction/{id} [get]
+func (h *TransactionHandler) GetTransactionByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := middleware.PathID(r)
+
+	// Log the request
+	h.logger.Info("GET /api/v1/transaction/{id}",
+		zap.Int64("id", id),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	// Get transaction from service
+	transaction, err := h.transactionService.GetTransaction(ctx, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			h.logger.Warn("Transaction not found", zap.Int64("id", id))
+			h.writeErrorResponse(w, http.StatusNotFound, "NOT_FOUND", "Transaction not found")
+			return
+		}
+		h.logger.Error("Failed to get transaction", zap.Error(err), zap.Int64("id", id))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve transaction")
+		return
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(transaction); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully retrieved transaction", zap.Int64("id", id))
+}
+
+// BatchGetTransactions retrieves several transactions by ID in one request
+// @Summary Get transactions by IDs
+// @Description Retrieve the transactions with the given IDs in one request. Transactions are returned in the order their IDs were first given; IDs without a transaction are listed in notFoundIds. At most transactions.max_batch_get_ids distinct IDs may be requested.
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param request body dto.TransactionBatchGetRequest true "IDs of the transactions to retrieve"
+// @Success 200 {object} dto.TransactionBatchGetResponse "Found transactions and IDs without a transaction"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request body, no IDs or too many IDs"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /transactions/batch-get [post]
+func (h *TransactionHandler) BatchGetTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request dto.TransactionBatchGetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON format")
+		return
+	}
+
+	if len(request.IDs) == 0 {
+		h.writeErrorResponse(w, http.StatusBadRequest, "EMPTY_BATCH", "At least one transaction ID is required")
+		return
+	}
+
+	h.logger.Info("POST /api/v1/transactions/batch-get",
+		zap.Int("ids", len(request.IDs)),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	response, err := h.transactionService.GetTransactionsByIDs(ctx, request.IDs)
+	if err != nil {
+		var tooLarge *services.BatchGetTooLargeError
+		if errors.As(err, &tooLarge) {
+			h.writeErrorResponse(w, http.StatusBadRequest, "BATCH_TOO_LARGE",
+				fmt.Sprintf("At most %d transactions may be requested at once, %d were requested", tooLarge.Max, tooLarge.Requested))
+			return
+		}
+		if status, code, ok := queryCanceledStatus(err); ok {
+			h.logger.Debug("Transaction batch get canceled", zap.Error(err))
+			h.writeErrorResponse(w, status, code, "Request ended before the transactions were retrieved")
+			return
+		}
+		h.logger.Error("Failed to get transactions by ID", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve transactions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully retrieved transactions by ID",
+		zap.Int("found", len(response.Transactions)),
+		zap.Int("notFound", len(response.NotFoundIDs)))
+}
+
+// BatchGetTransactionsBySourceID retrieves several transactions by source ID in one request
+// @Summary Get transactions by source IDs
+// @Description Retrieve the transactions with the given source IDs in one request. Transactions are returned in the order their source IDs were first given; source IDs without a transaction are listed in notFoundSourceIds. At most transactions.max_batch_get_ids distinct source IDs may be requested.
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param request body dto.TransactionSourceBatchGetRequest true "Source IDs of the transactions to retrieve"
+// @Success 200 {object} dto.TransactionSourceBatchGetResponse "Found transactions and source IDs without a transaction"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request body, no source IDs or too many source IDs"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /transactions/by-source/batch [post]
+func (h *TransactionHandler) BatchGetTransactionsBySourceID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var request dto.TransactionSourceBatchGetRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON format")
+		return
+	}
+
+	if len(request.SourceIDs) == 0 {
+		h.writeErrorResponse(w, http.StatusBadRequest, "EMPTY_BATCH", "At least one source ID is required")
+		return
+	}
+
+	h.logger.Info("POST /api/v1/transactions/by-source/batch",
+		zap.Int("sourceIds", len(request.SourceIDs)),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	response, err := h.transactionService.GetTransactionsBySourceIDs(ctx, request.SourceIDs)
+	if err != nil {
+		var tooLarge *services.BatchGetTooLargeError
+		if errors.As(err, &tooLarge) {
+			h.writeErrorResponse(w, http.StatusBadRequest, "BATCH_TOO_LARGE",
+				fmt.Sprintf("At most %d transactions may be requested at once, %d were requested", tooLarge.Max, tooLarge.Requested))
+			return
+		}
+		if status, code, ok := queryCanceledStatus(err); ok {
+			h.logger.Debug("Transaction batch get by source ID canceled", zap.Error(err))
+			h.writeErrorResponse(w, status, code, "Request ended before the transactions were retrieved")
+			return
+		}
+		h.logger.Error("Failed to get transactions by source ID", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve transactions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully retrieved transactions by source ID",
+		zap.Int("found", len(response.Transactions)),
+		zap.Int("notFound", len(response.NotFoundSourceIDs)))
+}
+
+const (
+	defaultQuarantineReleaseLimit = 100
+	maxQuarantineReleaseLimit     = 1000
+)
+
+// ReleaseQuarantinedTransactions processes the quarantined transactions whose references now exist
+// @Summary Release quarantined transactions
+// @Description Look up the portfolio and security of up to limit QUAR transactions, oldest first. Those whose references now exist are moved to NEW and processed, and their processing results returned in released; the IDs of those still referring to unknown portfolios or securities, or whose lookup failed, are listed in stillQuarantined; those whose release or processing failed are listed in failed.
+// @Tags Transactions
+// @Produce json
+// @Param limit query int false "Number of quarantined transactions to check (default: 100, max: 1000)" minimum(1) maximum(1000)
+// @Success 200 {object} dto.QuarantineReleaseResponse "Released and still quarantined transactions"
+// @Failure 400 {object} dto.ErrorResponse "Invalid limit"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /transactions/quarantine/release [post]
+func (h *TransactionHandler) ReleaseQuarantinedTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	limit := defaultQuarantineReleaseLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > maxQuarantineReleaseLimit {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_LIMIT", fmt.Sprintf("limit must be between 1 and %d", maxQuarantineReleaseLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	h.logger.Info("POST /api/v1/transactions/quarantine/release",
+		zap.Int("limit", limit),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	response, err := h.transactionService.ReleaseQuarantinedTransactions(ctx, limit)
+	if err != nil {
+		h.logger.Error("Failed to release quarantined transactions", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to release quarantined transactions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully released quarantined transactions",
+		zap.Int("checked", response.Checked),
+		zap.Int("released", len(response.Released)),
+		zap.Int("stillQuarantined", len(response.StillQuarantined)),
+		zap.Int("failed", len(response.Failed)))
+}
+
+// GetLatestTransactions retrieves the most recent transaction of several portfolios
+// @Summary Get the latest transaction per portfolio
+// @Description Retrieve the most recently created transaction, in any status, of each portfolio named in portfolio_ids. Transactions are returned in the order their portfolios were first given; portfolios without transactions are listed in portfoliosWithoutTransactions. At most transactions.max_batch_get_ids distinct portfolios may be requested.
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param portfolio_ids query string true "Comma-separated portfolio IDs"
+// @Success 200 {object} dto.LatestTransactionsResponse "Latest transactions and portfolios without transactions"
+// @Failure 400 {object} dto.ErrorResponse "No portfolio IDs or too many portfolio IDs"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /portfolios/latest-transactions [get]
+func (h *TransactionHandler) GetLatestTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var portfolioIDs []string
+	for _, portfolioID := range strings.Split(r.URL.Query().Get("portfolio_ids"), ",") {
+		if portfolioID = strings.TrimSpace(portfolioID); portfolioID != "" {
+			portfolioIDs = append(portfolioIDs, portfolioID)
+		}
+	}
+
+	if len(portfolioIDs) == 0 {
+		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_PORTFOLIO_IDS", "At least one portfolio ID is required in portfolio_ids")
+		return
+	}
+
+	h.logger.Info("GET /api/v1/portfolios/latest-transactions",
+		zap.Int("portfolios", len(portfolioIDs)),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	response, err := h.transactionService.GetLatestTransactions(ctx, portfolioIDs)
+	if err != nil {
+		var tooLarge *services.BatchGetTooLargeError
+		if errors.As(err, &tooLarge) {
+			h.writeErrorResponse(w, http.StatusBadRequest, "TOO_MANY_PORTFOLIOS",
+				fmt.Sprintf("At most %d portfolios may be requested at once, %d were requested", tooLarge.Max, tooLarge.Requested))
+			return
+		}
+		if status, code, ok := queryCanceledStatus(err); ok {
+			h.logger.Debug("Latest transactions request canceled", zap.Error(err))
+			h.writeErrorResponse(w, status, code, "Request ended before the transactions were retrieved")
+			return
+		}
+		h.logger.Error("Failed to get latest transactions", zap.Error(err))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve latest transactions")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully retrieved latest transactions",
+		zap.Int("found", len(response.Transactions)),
+		zap.Int("withoutTransactions", len(response.PortfoliosWithoutTransactions)))
+}
+
+// GetTransactionHistory retrieves the audit history of a transaction
+// @Summary Get transaction history
+// @Description Retrieve every recorded status change and reprocessing attempt of a transaction, oldest first
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param id path int true "Transaction ID" minimum(1)
+// @Success 200 {object} dto.TransactionHistoryResponse "Successfully retrieved transaction history"
+// @Failure 400 {object} dto.ErrorResponse "Invalid transaction ID"
+// @Failure 404 {object} dto.ErrorResponse "Transaction not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /transaction/{id}/history [get]
+func (h *TransactionHandler) GetTransactionHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := middleware.PathID(r)
+
+	// Log the request
+	h.logger.Info("GET /api/v1/transaction/{id}/history",
+		zap.Int64("id", id),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	history, err := h.transactionService.GetTransactionHistory(ctx, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			h.logger.Warn("Transaction not found", zap.Int64("id", id))
+			h.writeErrorResponse(w, http.StatusNotFound, "NOT_FOUND", "Transaction not found")
+			return
+		}
+		h.logger.Error("Failed to get transaction history", zap.Error(err), zap.Int64("id", id))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve transaction history")
+		return
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(history); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully retrieved transaction history",
+		zap.Int64("id", id),
+		zap.Int("events", len(history.Events)))
+}
+
+// GetTransactionBalanceImpact computes how a transaction changes its portfolio's balances
+// @Summary Get transaction balance impact
+// @Description Compute the security and cash balance changes of a transaction and the balances they result in. By default the changes build on the balances at the time the transaction was processed, re-derived from the processed transactions before it; state=current builds on the stored balances instead.
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param id path int true "Transaction ID" minimum(1)
+// @Param state query string false "Balances the impact builds on" Enums(processing, current) default(processing)
+// @Success 200 {object} dto.TransactionBalanceImpactDTO "Successfully computed balance impact"
+// @Failure 400 {object} dto.ErrorResponse "Invalid transaction ID or state"
+// @Failure 404 {object} dto.ErrorResponse "Transaction not found"
+// @Failure 409 {object} dto.ErrorResponse "Transaction has not been processed"
+// @Failure 422 {object} dto.ErrorResponse "Replayed balances overflow"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /transaction/{id}/impact [get]
+func (h *TransactionHandler) GetTransactionBalanceImpact(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := middleware.PathID(r)
+	state := r.URL.Query().Get("state")
+
+	// Log the request
+	h.logger.Info("GET /api/v1/transaction/{id}/impact",
+		zap.Int64("id", id),
+		zap.String("state", state),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	impact, err := h.transactionService.GetTransactionBalanceImpact(ctx, id, state)
+	if err != nil {
+		var stateErr *services.InvalidImpactStateError
+		switch {
+		case errors.As(err, &stateErr):
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_STATE", stateErr.Error())
+		case errors.Is(err, services.ErrTransactionNotProcessed):
+			h.writeErrorResponse(w, http.StatusConflict, "TRANSACTION_NOT_PROCESSED", "Transaction has not been processed; use state=current for its impact on the current balances")
+		case h.writeCalculationError(w, err):
+		case strings.Contains(err.Error(), "not found"):
+			h.logger.Warn("Transaction not found", zap.Int64("id", id))
+			h.writeErrorResponse(w, http.StatusNotFound, "NOT_FOUND", "Transaction not found")
+		default:
+			h.logger.Error("Failed to get transaction balance impact", zap.Error(err), zap.Int64("id", id))
+			h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to compute transaction balance impact")
+		}
+		return
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(impact); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully computed transaction balance impact",
+		zap.Int64("id", id),
+		zap.String("state", impact.State))
+}
+
+// GetTransactionBalances retrieves the current balances a transaction affects
+// @Summary Get transaction balances
+// @Description Get the current values of the balances a transaction affects, resolved from its portfolio and security: the security balance of BUY, SELL, SHORT, COVER, IN and OUT transactions and the cash balance of BUY, SELL, SHORT, COVER, DEP and WD transactions. Balances that do not exist yet, for example before the transaction is processed, are left out.
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param id path int true "Transaction ID" minimum(1)
+// @Success 200 {object} dto.TransactionBalancesDTO "Successfully retrieved transaction balances"
+// @Failure 400 {object} dto.ErrorResponse "Invalid transaction ID"
+// @Failure 404 {object} dto.ErrorResponse "Transaction not found"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /transactions/{id}/balances [get]
+func (h *TransactionHandler) GetTransactionBalances(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id := middleware.PathID(r)
+
+	// Log the request
+	h.logger.Info("GET /api/v1/transactions/{id}/balances",
+		zap.Int64("id", id),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	balances, err := h.transactionService.GetTransactionBalances(ctx, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			h.logger.Warn("Transaction not found", zap.Int64("id", id))
+			h.writeErrorResponse(w, http.StatusNotFound, "NOT_FOUND", "Transaction not found")
+			return
+		}
+		h.logger.Error("Failed to get transaction balances", zap.Error(err), zap.Int64("id", id))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve transaction balances")
+		return
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(balances); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully retrieved transaction balances",
+		zap.Int64("id", id),
+		zap.Int("count", len(balances.Balances)))
+}
+
+// CreateTransactions processes a batch of transactions
+// @Summary Create batch of transactions
+// @Description Create and process multiple transactions in a single request. Supports batch processing with individual transaction validation and error reporting.
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param transactions body []dto.TransactionPostDTO true "Array of transactions to create"
+// @Param strict query bool false "Validate every transaction first and create nothing if any is invalid (422 listing all validation errors)"
+// @Param timing query bool false "Add the batch duration, records per second and per-portfolio counts to the summary"
+// @Success 201 {object} dto.TransactionBatchResponse "All transactions created; Location header points to the first created transaction"
+// @Header 201 {string} Location "Path of the first created transaction"
+// @Success 207 {object} dto.TransactionBatchResponse "Multi-status: some transactions succeeded, others failed"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request body or validation errors"
+// @Failure 413 {object} dto.ErrorResponse "Request too large (batch size limit exceeded)"
+// @Failure 422 {object} dto.ErrorResponse "Strict batch with invalid transactions; details list each invalid transaction by index"
+// @Failure 429 {object} dto.TransactionBatchResponse "No transaction created because every one was beyond its portfolio's daily transaction limit; a strict batch rejected for that reason alone is answered with a DAILY_LIMIT_EXCEEDED error listing the transactions by index"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Failure 503 {object} dto.ErrorResponse "Batch aborted on a database failure with transactions.abort_on_infrastructure_error; details give the failed index and the number created, retry the whole batch"
+// @Security ApiKeyAuth
+// @Router /transactions [post]
+func (h *TransactionHandler) CreateTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Strict batches are rejected as a whole if any transaction is invalid
+	strict := false
+	if strictStr := r.URL.Query().Get("strict"); strictStr != "" {
+		parsed, err := strconv.ParseBool(strictStr)
+		if err != nil {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_PARAMETER", "strict must be a boolean")
+			return
+		}
+		strict = parsed
+	}
+
+	// Timing is added to the summary on request
+	timing := false
+	if timingStr := r.URL.Query().Get("timing"); timingStr != "" {
+		parsed, err := strconv.ParseBool(timingStr)
+		if err != nil {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_PARAMETER", "timing must be a boolean")
+			return
+		}
+		timing = parsed
+	}
+
+	// Log the request
+	h.logger.Info("POST /api/v1/transactions",
+		zap.Bool("strict", strict),
+		zap.Bool("timing", timing),
+		zap.String("content_type", r.Header.Get("Content-Type")),
+		zap.Int64("content_length", r.ContentLength),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	// Parse request body
+	var transactions []dto.TransactionPostDTO
+	if err := json.NewDecoder(r.Body).Decode(&transactions); err != nil {
+		h.logger.Error("Failed to decode request body", zap.Error(err))
+		h.writeDecodeError(w, err)
+		return
+	}
+
+	// Validate batch size
+	if len(transactions) == 0 {
+		h.writeErrorResponse(w, http.StatusBadRequest, "EMPTY_BATCH", "At least one transaction is required")
+		return
+	}
+
+	if len(transactions) > 1000 { // Configurable limit
+		h.writeErrorResponse(w, http.StatusBadRequest, "BATCH_TOO_LARGE", "Maximum 1000 transactions per batch")
+		return
+	}
+
+	// Create transactions using service
+	options := services.BatchOptions{Timing: timing}
+	var result *dto.TransactionBatchResponse
+	var err error
+	if strict {
+		result, err = h.transactionService.CreateTransactionsStrict(ctx, transactions, options)
+	} else {
+		result, err = h.transactionService.CreateTransactions(ctx, transactions, options)
+	}
+	if err != nil {
+		var batchErr *services.BatchValidationError
+		if errors.As(err, &batchErr) {
+			h.writeBatchValidationErrorResponse(w, batchErr)
+			return
+		}
+		var abortedErr *services.BatchAbortedError
+		if errors.As(err, &abortedErr) {
+			h.writeBatchAbortedErrorResponse(w, abortedErr)
+			return
+		}
+		h.logger.Error("Failed to create transactions", zap.Error(err), zap.Int("count", len(transactions)))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create transactions")
+		return
+	}
+
+	// Determine HTTP status based on results
+	status := http.StatusCreated
+	if result.Summary.Failed > 0 {
+		status = http.StatusMultiStatus // 207 Multi-Status for partial success
+	}
+	if result.Summary.Successful == 0 && result.Summary.DailyLimitExceeded > 0 &&
+		result.Summary.DailyLimitExceeded == result.Summary.Failed {
+		// Nothing was created and only because portfolios reached their daily limit
+		status = http.StatusTooManyRequests
+	}
+
+	// Link created resources
+	result.Locations = make([]string, 0, len(result.Successful))
+	for _, created := range result.Successful {
+		result.Locations = append(result.Locations, transactionLocation(created.ID))
+	}
+	if status == http.StatusCreated && len(result.Locations) > 0 {
+		w.Header().Set("Location", result.Locations[0])
+	}
+
+	// Write successful response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Successfully processed transaction batch",
+		zap.Int("input_count", len(transactions)),
+		zap.Int("success_count", result.Summary.Successful),
+		zap.Int("error_count", result.Summary.Failed),
+		zap.Int("status", status))
+}
+
+// ValidateTransaction validates a single transaction without persisting it
+// @Summary Validate a transaction
+// @Description Validate a single transaction's fields and business rules without persisting it or touching balances. Source ID uniqueness is only checked against stored transactions when check_source_id is true.
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param transaction body dto.TransactionPostDTO true "Transaction to validate"
+// @Param check_source_id query bool false "Also check that the source ID is not already in use (default: false)"
+// @Success 200 {object} dto.TransactionValidationResponse "Validation result"
+// @Failure 400 {object} dto.ErrorResponse "Invalid request body or parameters"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /transaction/validate [post]
+func (h *TransactionHandler) ValidateTransaction(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	checkSourceID := false
+	if raw := r.URL.Query().Get("check_source_id"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_PARAMETER", "check_source_id must be a boolean")
+			return
+		}
+		checkSourceID = parsed
+	}
+
+	// Parse request body
+	var transaction dto.TransactionPostDTO
+	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
+		h.logger.Debug("Failed to decode request body", zap.Error(err))
+		h.writeDecodeError(w, err)
+		return
+	}
+
+	result, err := h.transactionService.ValidateTransaction(ctx, transaction, checkSourceID)
+	if err != nil {
+		h.logger.Error("Failed to validate transaction", zap.Error(err), zap.String("source_id", transaction.SourceID))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to validate transaction")
+		return
+	}
+
+	// Write response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+// RecomputePortfolioBalances re-derives portfolio balances from its processed transactions
+// @Summary Recompute portfolio balances
+// @Description Re-derive all balances of a portfolio by replaying its processed (PROC) transactions strictly ordered by transaction date, transaction type and creation time, independent of the order in which they were originally processed. Balances without supporting transactions are reset to zero.
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Param portfolioId path string true "Portfolio ID (24 characters)"
+// @Success 200 {object} dto.PortfolioRecomputeResponse "Balances recomputed successfully"
+// @Failure 400 {object} dto.ErrorResponse "Invalid portfolio ID"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /portfolios/{portfolioId}/recompute [post]
+func (h *TransactionHandler) RecomputePortfolioBalances(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// Parse portfolio ID from URL
+	portfolioID := chi.URLParam(r, "portfolioId")
+	if portfolioID == "" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_PORTFOLIO_ID", "Portfolio ID is required")
+		return
+	}
+
 	// Log the request
-	h.logger.Info("GET /api/v1/transactions",
-		zap.Any("filter", filter),
+	h.logger.Info("POST /api/v1/portfolios/{portfolioId}/recompute",
+		zap.String("portfolioId", portfolioID),
 		zap.String("user_agent", r.Header.Get("User-Agent")),
 		zap.String("remote_addr", r.RemoteAddr))
 
-	// Get transactions from service
-	result, err := h.transactionService.GetTransactions(ctx, *filter)
+	result, err := h.transactionService.RecomputePortfolioBalances(ctx, portfolioID)
 	if err != nil {
-		h.logger.Error("Failed to get transactions", zap.Error(err), zap.Any("filter", filter))
-		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve transactions")
+		if strings.Contains(err.Error(), "invalid portfolio ID") {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_PORTFOLIO_ID", "Portfolio ID must be exactly 24 characters")
+			return
+		}
+		h.logger.Error("Failed to recompute portfolio balances", zap.Error(err), zap.String("portfolioId", portfolioID))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to recompute portfolio balances")
 		return
 	}
 
@@ -81,145 +903,287 @@ func (h *TransactionHandler) GetTransactions(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	h.logger.Info("Successfully retrieved transactions",
-		zap.Int("count", len(result.Transactions)),
-		zap.Int64("total", result.Pagination.Total),
-		zap.Int("page", result.Pagination.Page),
-		zap.Int("limit", result.Pagination.Limit))
+	h.logger.Info("Successfully recomputed portfolio balances",
+		zap.String("portfolioId", portfolioID),
+		zap.Int("transactions_replayed", result.TransactionsReplayed))
 }
 
-// GetTransactionByID retrieves a specific transaction by its ID
-// @Summary Get transaction by ID
-// @Description Retrieve a specific transaction using its unique ID
-// @Tags Transactions
+// GetPortfolioBalancesAsOf returns portfolio balances as they stood at the end of a past date
+// @Summary Get portfolio balances as of a date
+// @Description Re-derive a portfolio's balances as of the end of the given date by replaying its processed (PROC) transactions effective by then. Whether a transaction becomes effective on its trade date or its settlement date follows the configured balance date basis (balances.date_basis); transactions without a settlement date settle on their trade date. Stored balances are not modified.
+// @Tags Balances
 // @Accept json
 // @Produce json
-// @Param id path int true "Transaction ID" minimum(1)
-// @Success 200 {object} dto.TransactionResponseDTO "Successfully retrieved transaction"
-// @Failure 400 {object} dto.ErrorResponse "Invalid transaction ID"
-// @Failure 404 {object} dto.ErrorResponse "Transaction not found"
+// @Param portfolioId path string true "Portfolio ID (24 characters)"
+// @Param date query string true "As-of date (YYYY-MM-DD format)"
+// @Success 200 {object} dto.PortfolioBalancesAsOfResponse "Balances as of the date"
+// @Failure 400 {object} dto.ErrorResponse "Invalid portfolio ID or date"
+// @Failure 422 {object} dto.ErrorResponse "Replayed balances overflow"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Security ApiKeyAuth
-// @Router /transaction/{id} [get]
-func (h *TransactionHandler) GetTransactionByID(w http.ResponseWriter, r *http.Request) {
+// @Router /portfolios/{portfolioId}/balances/as-of [get]
+func (h *TransactionHandler) GetPortfolioBalancesAsOf(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Parse transaction ID from URL
-	idStr := chi.URLParam(r, "id")
-	if idStr == "" {
-		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_ID", "Transaction ID is required")
+	portfolioID := chi.URLParam(r, "portfolioId")
+	if portfolioID == "" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_PORTFOLIO_ID", "Portfolio ID is required")
 		return
 	}
 
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	dateParam := r.URL.Query().Get("date")
+	if dateParam == "" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_DATE", "date query parameter is required")
+		return
+	}
+	asOf, err := time.Parse("2006-01-02", dateParam)
 	if err != nil {
-		h.logger.Error("Invalid transaction ID", zap.String("id", idStr), zap.Error(err))
-		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_ID", "Transaction ID must be a valid integer")
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_DATE", "date must be in YYYY-MM-DD format")
 		return
 	}
 
-	// Log the request
-	h.logger.Info("GET /api/v1/transaction/{id}",
-		zap.Int64("id", id),
-		zap.String("user_agent", r.Header.Get("User-Agent")),
-		zap.String("remote_addr", r.RemoteAddr))
-
-	// Get transaction from service
-	transaction, err := h.transactionService.GetTransaction(ctx, id)
+	result, err := h.transactionService.GetPortfolioBalancesAsOf(ctx, portfolioID, asOf)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			h.logger.Warn("Transaction not found", zap.Int64("id", id))
-			h.writeErrorResponse(w, http.StatusNotFound, "NOT_FOUND", "Transaction not found")
+		if strings.Contains(err.Error(), "invalid portfolio ID") {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_PORTFOLIO_ID", "Portfolio ID must be exactly 24 characters")
 			return
 		}
-		h.logger.Error("Failed to get transaction", zap.Error(err), zap.Int64("id", id))
-		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve transaction")
+		if h.writeCalculationError(w, err) {
+			return
+		}
+		h.logger.Error("Failed to get portfolio balances as of date", zap.Error(err), zap.String("portfolioId", portfolioID))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get portfolio balances as of date")
 		return
 	}
 
-	// Write successful response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	if err := json.NewEncoder(w).Encode(transaction); err != nil {
+	if err := json.NewEncoder(w).Encode(result); err != nil {
 		h.logger.Error("Failed to encode response", zap.Error(err))
-		return
 	}
-
-	h.logger.Info("Successfully retrieved transaction", zap.Int64("id", id))
 }
 
-// CreateTransactions processes a batch of transactions
-// @Summary Create batch of transactions
-// @Description Create and process multiple transactions in a single request. Supports batch processing with individual transaction validation and error reporting.
-// @Tags Transactions
-// @Accept json
+// GetPortfolioLedger returns a window of a portfolio's ledger
+// @Summary Get a portfolio ledger window
+// @Description Re-derive the ledger of a portfolio from its processed (PROC) transactions for the dates from through to: the balances before from, then every transaction effective in the window, in replay order, with the security and cash balances it left behind. Transactions before the window are replayed for the opening balances, so consecutive windows line up with the ledger of all dates. A window may cover at most balances.ledger_max_window_days days. Accept: application/x-ndjson streams only the entries, one JSON object per line, and stream=true streams them as a JSON array; each entry is written as soon as it is replayed. A failure before the first entry is answered with its error status; once an entry has been written, a failure ends the response early, leaving a JSON array unterminated.
+// @Tags Balances
 // @Produce json
-// @Param transactions body []dto.TransactionPostDTO true "Array of transactions to create"
-// @Success 200 {object} dto.TransactionBatchResponse "Batch processing completed (may include partial failures)"
-// @Success 207 {object} dto.TransactionBatchResponse "Multi-status: some transactions succeeded, others failed"
-// @Failure 400 {object} dto.ErrorResponse "Invalid request body or validation errors"
-// @Failure 413 {object} dto.ErrorResponse "Request too large (batch size limit exceeded)"
+// @Param portfolioId path string true "Portfolio ID (24 characters)"
+// @Param from query string true "First date of the window (YYYY-MM-DD format)"
+// @Param to query string true "Last date of the window (YYYY-MM-DD format)"
+// @Param stream query bool false "Stream only the entries, as a JSON array"
+// @Param Accept header string false "application/x-ndjson streams only the entries, one JSON object per line"
+// @Success 200 {object} dto.PortfolioLedgerResponse "Ledger window"
+// @Failure 400 {object} dto.ErrorResponse "Invalid portfolio ID, dates or window"
+// @Failure 422 {object} dto.ErrorResponse "Replayed balances overflow"
 // @Failure 500 {object} dto.ErrorResponse "Internal server error"
 // @Security ApiKeyAuth
-// @Router /transactions [post]
-func (h *TransactionHandler) CreateTransactions(w http.ResponseWriter, r *http.Request) {
+// @Router /portfolios/{portfolioId}/ledger [get]
+func (h *TransactionHandler) GetPortfolioLedger(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Log the request
-	h.logger.Info("POST /api/v1/transactions",
-		zap.String("content_type", r.Header.Get("Content-Type")),
-		zap.Int64("content_length", r.ContentLength),
+	portfolioID := chi.URLParam(r, "portfolioId")
+	if portfolioID == "" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_PORTFOLIO_ID", "Portfolio ID is required")
+		return
+	}
+
+	var window [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_DATE", name+" query parameter is required")
+			return
+		}
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_DATE", name+" must be in YYYY-MM-DD format")
+			return
+		}
+		window[i] = date
+	}
+	from, to := window[0], window[1]
+	if to.Before(from) {
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_WINDOW", "to must not be before from")
+		return
+	}
+
+	format, err := streamFormat(r)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_PARAMETER", err.Error())
+		return
+	}
+
+	h.logger.Info("GET /api/v1/portfolios/{portfolioId}/ledger",
+		zap.String("portfolioId", portfolioID),
+		zap.String("from", from.Format("2006-01-02")),
+		zap.String("to", to.Format("2006-01-02")),
 		zap.String("user_agent", r.Header.Get("User-Agent")),
 		zap.String("remote_addr", r.RemoteAddr))
 
-	// Parse request body
-	var transactions []dto.TransactionPostDTO
-	if err := json.NewDecoder(r.Body).Decode(&transactions); err != nil {
-		h.logger.Error("Failed to decode request body", zap.Error(err))
-		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON format")
+	if format != "" {
+		h.streamLedgerEntries(w, r, portfolioID, from, to, format)
 		return
 	}
 
-	// Validate batch size
-	if len(transactions) == 0 {
-		h.writeErrorResponse(w, http.StatusBadRequest, "EMPTY_BATCH", "At least one transaction is required")
+	result, err := h.transactionService.GetPortfolioLedger(ctx, portfolioID, from, to)
+	if err != nil {
+		h.writeLedgerError(w, err, portfolioID)
 		return
 	}
 
-	if len(transactions) > 1000 { // Configurable limit
-		h.writeErrorResponse(w, http.StatusBadRequest, "BATCH_TOO_LARGE", "Maximum 1000 transactions per batch")
-		return
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Error("Failed to encode response", zap.Error(err))
 	}
+}
 
-	// Create transactions using service
-	result, err := h.transactionService.CreateTransactions(ctx, transactions)
+// streamLedgerEntries writes the entries of a ledger window as they are replayed, either as a
+// JSON array or as NDJSON. Once the first entry is written the status can no longer change, so a
+// failure part way through ends the response early: an NDJSON stream stops at a line boundary
+// and a JSON array is left unterminated.
+func (h *TransactionHandler) streamLedgerEntries(w http.ResponseWriter, r *http.Request, portfolioID string, from, to time.Time, format string) {
+	controller := http.NewResponseController(w)
+	encoder := json.NewEncoder(w)
+	array := format != ndjsonContentType
+	started := false
+	written := 0
+
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", format)
+		w.WriteHeader(http.StatusOK)
+		if array {
+			_, err := io.WriteString(w, "[")
+			return err
+		}
+		return nil
+	}
+
+	count, err := h.transactionService.StreamPortfolioLedger(r.Context(), portfolioID, from, to, func(entry dto.LedgerEntryDTO) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		} else if array {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+
+		// Push entries to the client in chunks rather than waiting for the whole window
+		written++
+		if written%streamFlushInterval == 0 {
+			// Writers that cannot flush still deliver the stream as their buffers fill
+			_ = controller.Flush()
+		}
+		return nil
+	})
 	if err != nil {
-		h.logger.Error("Failed to create transactions", zap.Error(err), zap.Int("count", len(transactions)))
-		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create transactions")
+		if !started {
+			h.writeLedgerError(w, err, portfolioID)
+			return
+		}
+		h.logger.Error("Failed to stream portfolio ledger",
+			zap.Error(err),
+			zap.String("portfolioId", portfolioID),
+			zap.Int("streamed", count))
 		return
 	}
 
-	// Determine HTTP status based on results
-	status := http.StatusCreated
-	if result.Summary.Failed > 0 {
-		status = http.StatusMultiStatus // 207 Multi-Status for partial success
+	if !started {
+		if err := start(); err != nil {
+			return
+		}
+	}
+	if array {
+		if _, err := io.WriteString(w, "]\n"); err != nil {
+			return
+		}
+	}
+	_ = controller.Flush()
+}
+
+// writeLedgerError writes the error response of a ledger window that could not be replayed
+func (h *TransactionHandler) writeLedgerError(w http.ResponseWriter, err error, portfolioID string) {
+	var tooLarge *services.LedgerWindowTooLargeError
+	if errors.As(err, &tooLarge) {
+		h.writeErrorResponse(w, http.StatusBadRequest, "WINDOW_TOO_LARGE", tooLarge.Error())
+		return
+	}
+	if strings.Contains(err.Error(), "invalid portfolio ID") {
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_PORTFOLIO_ID", "Portfolio ID must be exactly 24 characters")
+		return
+	}
+	if h.writeCalculationError(w, err) {
+		return
+	}
+	if status, code, ok := queryCanceledStatus(err); ok {
+		h.writeErrorResponse(w, status, code, "Request ended before the ledger was replayed")
+		return
+	}
+	h.logger.Error("Failed to get portfolio ledger", zap.Error(err), zap.String("portfolioId", portfolioID))
+	h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to get portfolio ledger")
+}
+
+// CheckPortfolioConsistency reports balances that drifted from a portfolio's processed transactions
+// @Summary Check portfolio balance consistency
+// @Description Replay a portfolio's processed (PROC) transactions the same way as the recompute endpoint and compare the result with the stored balances. Discrepancies are reported without modifying any balances, so the check is safe to run as a production monitor.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param portfolioId query string true "Portfolio ID (24 characters)"
+// @Success 200 {object} dto.ConsistencyCheckResponse "Consistency check completed"
+// @Failure 400 {object} dto.ErrorResponse "Missing or invalid portfolio ID"
+// @Failure 500 {object} dto.ErrorResponse "Internal server error"
+// @Security ApiKeyAuth
+// @Router /admin/consistency-check [get]
+func (h *TransactionHandler) CheckPortfolioConsistency(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	portfolioID := r.URL.Query().Get("portfolioId")
+	if portfolioID == "" {
+		h.writeErrorResponse(w, http.StatusBadRequest, "MISSING_PORTFOLIO_ID", "portfolioId query parameter is required")
+		return
+	}
+
+	// Log the request
+	h.logger.Info("GET /api/v1/admin/consistency-check",
+		zap.String("portfolioId", portfolioID),
+		zap.String("user_agent", r.Header.Get("User-Agent")),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	result, err := h.transactionService.CheckPortfolioConsistency(ctx, portfolioID)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid portfolio ID") {
+			h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_PORTFOLIO_ID", "Portfolio ID must be exactly 24 characters")
+			return
+		}
+		h.logger.Error("Failed to check portfolio consistency", zap.Error(err), zap.String("portfolioId", portfolioID))
+		h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to check portfolio consistency")
+		return
 	}
 
 	// Write successful response
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
+	w.WriteHeader(http.StatusOK)
 
 	if err := json.NewEncoder(w).Encode(result); err != nil {
 		h.logger.Error("Failed to encode response", zap.Error(err))
 		return
 	}
 
-	h.logger.Info("Successfully processed transaction batch",
-		zap.Int("input_count", len(transactions)),
-		zap.Int("success_count", result.Summary.Successful),
-		zap.Int("error_count", result.Summary.Failed),
-		zap.Int("status", status))
+	h.logger.Info("Portfolio consistency check completed",
+		zap.String("portfolioId", portfolioID),
+		zap.Bool("consistent", result.Consistent),
+		zap.Int("drift_count", result.DriftCount))
 }
 
 // parseTransactionFilter parses query parameters into TransactionFilter
@@ -266,17 +1230,7 @@ func (h *TransactionHandler) parseTransactionFilter(r *http.Request) (*dto.Trans
 	}
 
 	// Pagination
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
-		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
-			filter.Pagination.Offset = offset
-		}
-	}
-
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 && limit <= 1000 {
-			filter.Pagination.Limit = limit
-		}
-	}
+	filter.Pagination.Offset, filter.Pagination.Limit = middleware.Pagination(r)
 
 	// Default pagination values
 	if filter.Pagination.Limit == 0 {
@@ -284,23 +1238,15 @@ func (h *TransactionHandler) parseTransactionFilter(r *http.Request) (*dto.Trans
 	}
 
 	// Sort fields
+	schema := dto.TransactionQuerySchema()
 	if sortBy := r.URL.Query().Get("sortby"); sortBy != "" {
 		// Parse comma-separated sort fields
 		fields := strings.Split(sortBy, ",")
 		var validFields []string
 
-		validSortFields := map[string]bool{
-			"portfolio_id":     true,
-			"security_id":      true,
-			"transaction_date": true,
-			"transaction_type": true,
-			"status":           true,
-			"created_at":       true,
-		}
-
 		for _, field := range fields {
 			field = strings.TrimSpace(field)
-			if validSortFields[field] {
+			if schema.IsSortable(field) {
 				validFields = append(validFields, field)
 			}
 		}
@@ -315,15 +1261,194 @@ func (h *TransactionHandler) parseTransactionFilter(r *http.Request) (*dto.Trans
 
 	// Default sort if none specified
 	if len(filter.SortBy) == 0 {
-		filter.SortBy = []dto.SortRequest{
-			{Field: "transaction_date", Direction: "desc"},
-			{Field: "id", Direction: "asc"},
-		}
+		filter.SortBy = schema.DefaultSort
 	}
 
 	return filter, nil
 }
 
+// streamTransactions writes every transaction matching filter as it is read, either as a JSON
+// array or as NDJSON. Once the first transaction is written the status can no longer change, so
+// a failure part way through ends the response early: an NDJSON stream stops at a line boundary
+// and a JSON array is left unterminated.
+func (h *TransactionHandler) streamTransactions(w http.ResponseWriter, r *http.Request, filter *dto.TransactionFilter, fields []string, format string) {
+	controller := http.NewResponseController(w)
+	encoder := json.NewEncoder(w)
+	array := format != ndjsonContentType
+	started := false
+	written := 0
+
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", format)
+		w.WriteHeader(http.StatusOK)
+		if array {
+			_, err := io.WriteString(w, "[")
+			return err
+		}
+		return nil
+	}
+
+	count, err := h.transactionService.StreamTransactions(r.Context(), *filter, func(txn dto.TransactionResponseDTO) error {
+		var item interface{} = txn
+		if fields != nil {
+			projected, err := projectFields([]dto.TransactionResponseDTO{txn}, fields)
+			if err != nil {
+				return err
+			}
+			item = projected[0]
+		}
+
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		} else if array {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+
+		// Push records to the client in chunks rather than waiting for the whole result
+		written++
+		if written%streamFlushInterval == 0 {
+			// Writers that cannot flush still deliver the stream as their buffers fill
+			_ = controller.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		h.logger.Error("Failed to stream transactions",
+			zap.Error(err),
+			zap.Int("streamed", count),
+			zap.Any("filter", filter))
+		if !started {
+			h.writeErrorResponse(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to retrieve transactions")
+		}
+		return
+	}
+
+	if !started {
+		if err := start(); err != nil {
+			return
+		}
+	}
+	if array {
+		if _, err := io.WriteString(w, "]\n"); err != nil {
+			return
+		}
+	}
+	_ = controller.Flush()
+
+	h.logger.Info("Successfully streamed transactions",
+		zap.Int("count", count),
+		zap.String("format", format))
+}
+
+// transactionLocation returns the resource path of a transaction
+func transactionLocation(id int64) string {
+	return "/api/v1/transaction/" + strconv.FormatInt(id, 10)
+}
+
+// writeBatchValidationErrorResponse rejects a strict batch with the errors of every invalid
+// transaction. A batch rejected only because portfolios reached their daily transaction limit is
+// answered with 429, like a non-strict batch in which nothing was created for that reason.
+func (h *TransactionHandler) writeBatchValidationErrorResponse(w http.ResponseWriter, batchErr *services.BatchValidationError) {
+	status := http.StatusUnprocessableEntity
+	code := "BATCH_VALIDATION_FAILED"
+	message := fmt.Sprintf("%d of %d transactions failed validation; no transactions were created", len(batchErr.Failed), batchErr.Total)
+	if batchErr.DailyLimitExceeded > 0 && batchErr.DailyLimitExceeded == len(batchErr.Failed) {
+		status = http.StatusTooManyRequests
+		code = "DAILY_LIMIT_EXCEEDED"
+		message = fmt.Sprintf("%d of %d transactions are beyond their portfolio's daily transaction limit; no transactions were created", len(batchErr.Failed), batchErr.Total)
+	}
+
+	errorResp := dto.ErrorResponse{
+		Error: dto.ErrorDetail{
+			Code:    code,
+			Message: message,
+			Details: map[string]interface{}{
+				"totalRequested":     batchErr.Total,
+				"invalid":            batchErr.Failed,
+				"dailyLimitExceeded": batchErr.DailyLimitExceeded,
+			},
+			Timestamp: time.Now(),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		h.logger.Error("Failed to write error response", zap.Error(err))
+	}
+}
+
+// writeBatchAbortedErrorResponse writes the 503 response for a batch stopped by an
+// infrastructure error; the client is expected to retry the whole batch
+func (h *TransactionHandler) writeBatchAbortedErrorResponse(w http.ResponseWriter, abortedErr *services.BatchAbortedError) {
+	h.logger.Error("Transaction batch aborted",
+		zap.Int("failed_index", abortedErr.Index),
+		zap.Int("created", abortedErr.Created),
+		zap.Error(abortedErr.Cause))
+
+	errorResp := dto.ErrorResponse{
+		Error: dto.ErrorDetail{
+			Code:    "BATCH_ABORTED",
+			Message: fmt.Sprintf("Batch aborted at transaction %d of %d on a database failure; retry the batch", abortedErr.Index, abortedErr.Total),
+			Details: map[string]interface{}{
+				"totalRequested": abortedErr.Total,
+				"failedIndex":    abortedErr.Index,
+				"created":        abortedErr.Created,
+			},
+			Timestamp: time.Now(),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		h.logger.Error("Failed to write error response", zap.Error(err))
+	}
+}
+
+// writeDecodeError writes the response to a transaction body that could not be decoded, naming
+// the field when a quantity or price is not a decimal number
+func (h *TransactionHandler) writeDecodeError(w http.ResponseWriter, err error) {
+	var decimalErr *dto.DecimalFieldError
+	if errors.As(err, &decimalErr) {
+		h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_DECIMAL", decimalErr.Error())
+		return
+	}
+	h.writeErrorResponse(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON format")
+}
+
+// writeCalculationError writes the response to a typed balance calculation error and reports
+// whether err was one
+func (h *TransactionHandler) writeCalculationError(w http.ResponseWriter, err error) bool {
+	var insufficient *domainServices.InsufficientPositionError
+	var missing *domainServices.MissingBalanceError
+	var overflow *domainServices.CalculationOverflowError
+
+	switch {
+	case errors.As(err, &insufficient):
+		h.writeErrorResponse(w, http.StatusUnprocessableEntity, "INSUFFICIENT_POSITION", insufficient.Error())
+	case errors.As(err, &missing):
+		h.writeErrorResponse(w, http.StatusNotFound, "BALANCE_NOT_FOUND", missing.Error())
+	case errors.As(err, &overflow):
+		h.writeErrorResponse(w, http.StatusUnprocessableEntity, "CALCULATION_OVERFLOW", overflow.Error())
+	default:
+		return false
+	}
+	return true
+}
+
 // writeErrorResponse writes a standardized error response
 func (h *TransactionHandler) writeErrorResponse(w http.ResponseWriter, statusCode int, errorCode, message string) {
 	errorResp := dto.ErrorResponse{
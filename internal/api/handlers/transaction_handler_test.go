@@ -0,0 +1,1050 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/api/middleware"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	domainServices "github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// stubCreateTransactionService assigns sequential IDs and fails transactions whose source ID
+// starts with FAIL, or with LIMIT as beyond their portfolio's daily limit. A source ID starting
+// with DOWN aborts the batch as if the database failed. Requested timing is reported as a fixed
+// duration.
+type stubCreateTransactionService struct {
+	services.TransactionService
+	nextID int64
+}
+
+func (s *stubCreateTransactionService) CreateTransactions(ctx context.Context, transactionDTOs []dto.TransactionPostDTO, options services.BatchOptions) (*dto.TransactionBatchResponse, error) {
+	result := &dto.TransactionBatchResponse{}
+	limited := 0
+	for i, txn := range transactionDTOs {
+		if strings.HasPrefix(txn.SourceID, "DOWN") {
+			return nil, &services.BatchAbortedError{
+				Index:   i,
+				Created: len(result.Successful),
+				Total:   len(transactionDTOs),
+				Cause:   repositories.NewConnectionError("create", errors.New("connection refused")),
+			}
+		}
+		if strings.HasPrefix(txn.SourceID, "FAIL") {
+			result.Failed = append(result.Failed, dto.TransactionErrorDTO{Transaction: txn})
+			continue
+		}
+		if strings.HasPrefix(txn.SourceID, "LIMIT") {
+			limited++
+			result.Failed = append(result.Failed, dto.TransactionErrorDTO{Transaction: txn})
+			continue
+		}
+		s.nextID++
+		result.Successful = append(result.Successful, dto.TransactionResponseDTO{ID: s.nextID, SourceID: txn.SourceID})
+	}
+	result.Summary = dto.BatchSummaryDTO{
+		TotalRequested:     len(transactionDTOs),
+		Successful:         len(result.Successful),
+		Failed:             len(result.Failed),
+		DailyLimitExceeded: limited,
+	}
+	if options.Timing {
+		result.Summary.Timing = &dto.BatchTimingDTO{DurationMs: 5, RecordsPerSecond: float64(len(transactionDTOs)) * 200}
+	}
+	return result, nil
+}
+
+// CreateTransactionsStrict rejects the batch if any source ID starts with FAIL or LIMIT, the
+// latter as beyond the daily limit, and otherwise creates it
+func (s *stubCreateTransactionService) CreateTransactionsStrict(ctx context.Context, transactionDTOs []dto.TransactionPostDTO, options services.BatchOptions) (*dto.TransactionBatchResponse, error) {
+	var invalid []dto.IndexedTransactionErrorDTO
+	limited := 0
+	for i, txn := range transactionDTOs {
+		field := "sourceId"
+		switch {
+		case strings.HasPrefix(txn.SourceID, "FAIL"):
+		case strings.HasPrefix(txn.SourceID, "LIMIT"):
+			field = "transactionDate"
+			limited++
+		default:
+			continue
+		}
+		invalid = append(invalid, dto.IndexedTransactionErrorDTO{
+			Index: i,
+			TransactionErrorDTO: dto.TransactionErrorDTO{
+				Transaction: txn,
+				Errors:      []dto.ValidationError{{Field: field, Message: "rejected"}},
+			},
+		})
+	}
+	if len(invalid) > 0 {
+		return nil, &services.BatchValidationError{Total: len(transactionDTOs), Failed: invalid, DailyLimitExceeded: limited}
+	}
+	return s.CreateTransactions(ctx, transactionDTOs, services.BatchOptions{})
+}
+
+func postTransactions(t *testing.T, handler *TransactionHandler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/transactions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.CreateTransactions(rec, req)
+	return rec
+}
+
+func TestCreateTransactions_Location(t *testing.T) {
+	t.Run("Single creation sets Location header", func(t *testing.T) {
+		handler := NewTransactionHandler(&stubCreateTransactionService{nextID: 41}, logger.NewNoop())
+
+		rec := postTransactions(t, handler, `[{"sourceId":"SRC-1"}]`)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "/api/v1/transaction/42", rec.Header().Get("Location"))
+
+		var body dto.TransactionBatchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, []string{"/api/v1/transaction/42"}, body.Locations)
+	})
+
+	t.Run("Batch lists all created locations in order", func(t *testing.T) {
+		handler := NewTransactionHandler(&stubCreateTransactionService{}, logger.NewNoop())
+
+		rec := postTransactions(t, handler, `[{"sourceId":"SRC-1"},{"sourceId":"SRC-2"},{"sourceId":"SRC-3"}]`)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "/api/v1/transaction/1", rec.Header().Get("Location"))
+
+		var body dto.TransactionBatchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, []string{
+			"/api/v1/transaction/1",
+			"/api/v1/transaction/2",
+			"/api/v1/transaction/3",
+		}, body.Locations)
+	})
+
+	t.Run("Partial failure omits Location header but lists created resources", func(t *testing.T) {
+		handler := NewTransactionHandler(&stubCreateTransactionService{}, logger.NewNoop())
+
+		rec := postTransactions(t, handler, `[{"sourceId":"FAIL-1"},{"sourceId":"SRC-2"}]`)
+
+		require.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Empty(t, rec.Header().Get("Location"))
+
+		var body dto.TransactionBatchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, []string{"/api/v1/transaction/1"}, body.Locations)
+	})
+
+	t.Run("No created transactions omits locations", func(t *testing.T) {
+		handler := NewTransactionHandler(&stubCreateTransactionService{}, logger.NewNoop())
+
+		rec := postTransactions(t, handler, `[{"sourceId":"FAIL-1"}]`)
+
+		require.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Empty(t, rec.Header().Get("Location"))
+		assert.NotContains(t, rec.Body.String(), "locations")
+	})
+}
+
+func TestCreateTransactions_DailyLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "Every transaction beyond the limit", body: `[{"sourceId":"LIMIT-1"},{"sourceId":"LIMIT-2"}]`, status: http.StatusTooManyRequests},
+		{name: "Some transactions created", body: `[{"sourceId":"SRC-1"},{"sourceId":"LIMIT-2"}]`, status: http.StatusMultiStatus},
+		{name: "Other failures too", body: `[{"sourceId":"FAIL-1"},{"sourceId":"LIMIT-2"}]`, status: http.StatusMultiStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewTransactionHandler(&stubCreateTransactionService{}, logger.NewNoop())
+
+			rec := postTransactions(t, handler, tt.body)
+
+			require.Equal(t, tt.status, rec.Code)
+			var body dto.TransactionBatchResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, strings.Count(tt.body, "LIMIT"), body.Summary.DailyLimitExceeded)
+		})
+	}
+
+	strictTests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "Strict batch beyond the limit", body: `[{"sourceId":"SRC-1"},{"sourceId":"LIMIT-2"}]`, status: http.StatusTooManyRequests, code: "DAILY_LIMIT_EXCEEDED"},
+		{name: "Strict batch with other failures too", body: `[{"sourceId":"FAIL-1"},{"sourceId":"LIMIT-2"}]`, status: http.StatusUnprocessableEntity, code: "BATCH_VALIDATION_FAILED"},
+	}
+
+	for _, tt := range strictTests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubCreateTransactionService{}
+			handler := NewTransactionHandler(svc, logger.NewNoop())
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/transactions?strict=true", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			handler.CreateTransactions(rec, req)
+
+			require.Equal(t, tt.status, rec.Code)
+			assert.Zero(t, svc.nextID, "no transaction may be created")
+
+			var body dto.ErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, tt.code, body.Error.Code)
+			assert.EqualValues(t, 1, body.Error.Details["dailyLimitExceeded"])
+		})
+	}
+}
+
+func TestCreateTransactions_Aborted(t *testing.T) {
+	handler := NewTransactionHandler(&stubCreateTransactionService{}, logger.NewNoop())
+
+	rec := postTransactions(t, handler, `[{"sourceId":"SRC-1"},{"sourceId":"DOWN-2"},{"sourceId":"SRC-3"}]`)
+
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	var body dto.ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "BATCH_ABORTED", body.Error.Code)
+	assert.EqualValues(t, 1, body.Error.Details["failedIndex"])
+	assert.EqualValues(t, 1, body.Error.Details["created"])
+	assert.EqualValues(t, 3, body.Error.Details["totalRequested"])
+	assert.NotContains(t, body.Error.Message, "connection refused")
+}
+
+func TestCreateTransactions_Timing(t *testing.T) {
+	post := func(query string) *httptest.ResponseRecorder {
+		handler := NewTransactionHandler(&stubCreateTransactionService{}, logger.NewNoop())
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/transactions"+query, strings.NewReader(`[{"sourceId":"SRC-1"}]`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.CreateTransactions(rec, req)
+		return rec
+	}
+
+	t.Run("Timing flag adds timing to the summary", func(t *testing.T) {
+		rec := post("?timing=true")
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+		var body dto.TransactionBatchResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.NotNil(t, body.Summary.Timing)
+		assert.Equal(t, float64(5), body.Summary.Timing.DurationMs)
+	})
+
+	t.Run("Timing is omitted without the flag", func(t *testing.T) {
+		rec := post("")
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "timing")
+	})
+
+	t.Run("Invalid timing flag", func(t *testing.T) {
+		rec := post("?timing=sometimes")
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "INVALID_PARAMETER")
+	})
+}
+
+func TestCreateTransactions_Strict(t *testing.T) {
+	const mixedBatch = `[{"sourceId":"SRC-1"},{"sourceId":"FAIL-2"},{"sourceId":"SRC-3"},{"sourceId":"FAIL-4"}]`
+
+	post := func(handler *TransactionHandler, query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/transactions"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.CreateTransactions(rec, req)
+		return rec
+	}
+
+	t.Run("Strict mixed batch is rejected with every error and creates nothing", func(t *testing.T) {
+		svc := &stubCreateTransactionService{}
+		handler := NewTransactionHandler(svc, logger.NewNoop())
+
+		rec := post(handler, "?strict=true", mixedBatch)
+
+		require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Zero(t, svc.nextID, "no transaction may be created")
+
+		var body struct {
+			Error struct {
+				Code    string `json:"code"`
+				Details struct {
+					TotalRequested int                              `json:"totalRequested"`
+					Invalid        []dto.IndexedTransactionErrorDTO `json:"invalid"`
+				} `json:"details"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "BATCH_VALIDATION_FAILED", body.Error.Code)
+		assert.Equal(t, 4, body.Error.Details.TotalRequested)
+		require.Len(t, body.Error.Details.Invalid, 2)
+		assert.Equal(t, 1, body.Error.Details.Invalid[0].Index)
+		assert.Equal(t, "FAIL-2", body.Error.Details.Invalid[0].Transaction.SourceID)
+		assert.Equal(t, 3, body.Error.Details.Invalid[1].Index)
+	})
+
+	t.Run("Non-strict mixed batch succeeds partially", func(t *testing.T) {
+		svc := &stubCreateTransactionService{}
+		handler := NewTransactionHandler(svc, logger.NewNoop())
+
+		rec := post(handler, "", mixedBatch)
+
+		require.Equal(t, http.StatusMultiStatus, rec.Code)
+		assert.Equal(t, int64(2), svc.nextID)
+	})
+
+	t.Run("Strict valid batch is created", func(t *testing.T) {
+		svc := &stubCreateTransactionService{}
+		handler := NewTransactionHandler(svc, logger.NewNoop())
+
+		rec := post(handler, "?strict=true", `[{"sourceId":"SRC-1"},{"sourceId":"SRC-2"}]`)
+
+		require.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, int64(2), svc.nextID)
+	})
+
+	t.Run("Invalid strict value", func(t *testing.T) {
+		handler := NewTransactionHandler(&stubCreateTransactionService{}, logger.NewNoop())
+
+		rec := post(handler, "?strict=maybe", mixedBatch)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Non-finite price names the field", func(t *testing.T) {
+		svc := &stubCreateTransactionService{}
+		handler := NewTransactionHandler(svc, logger.NewNoop())
+
+		rec := post(handler, "", `[{"sourceId":"SRC-1","price":"1"},{"sourceId":"SRC-2","price":"Infinity"}]`)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"code":"INVALID_DECIMAL"`)
+		assert.Contains(t, rec.Body.String(), "price must be a finite number, got Infinity")
+		assert.Zero(t, svc.nextID)
+	})
+}
+
+// stubStreamTransactionService emits a fixed number of sequential transactions
+type stubStreamTransactionService struct {
+	services.TransactionService
+	count int
+}
+
+func (s *stubStreamTransactionService) StreamTransactions(ctx context.Context, filter dto.TransactionFilter, emit func(dto.TransactionResponseDTO) error) (int, error) {
+	for i := 1; i <= s.count; i++ {
+		if err := emit(dto.TransactionResponseDTO{ID: int64(i), SourceID: fmt.Sprintf("SRC-%d", i), Status: "PROC"}); err != nil {
+			return i - 1, err
+		}
+	}
+	return s.count, nil
+}
+
+func TestGetTransactions_Streaming(t *testing.T) {
+	handler := NewTransactionHandler(&stubStreamTransactionService{count: 250}, logger.NewNoop())
+
+	t.Run("NDJSON is read line by line", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/transactions?fields=id,sourceId", nil)
+		req.Header.Set("Accept", "application/x-ndjson")
+		rec := httptest.NewRecorder()
+		handler.GetTransactions(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+		scanner := bufio.NewScanner(rec.Body)
+		lines := 0
+		for scanner.Scan() {
+			lines++
+			var record map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &record), "line %d", lines)
+			assert.Equal(t, float64(lines), record["id"])
+			assert.Equal(t, fmt.Sprintf("SRC-%d", lines), record["sourceId"])
+			assert.NotContains(t, record, "status")
+		}
+		require.NoError(t, scanner.Err())
+		assert.Equal(t, 250, lines)
+	})
+
+	t.Run("stream=true writes a JSON array", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/transactions?stream=true", nil)
+		rec := httptest.NewRecorder()
+		handler.GetTransactions(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var records []dto.TransactionResponseDTO
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &records))
+		require.Len(t, records, 250)
+		assert.Equal(t, int64(250), records[249].ID)
+	})
+
+	t.Run("Empty result is an empty array", func(t *testing.T) {
+		empty := NewTransactionHandler(&stubStreamTransactionService{}, logger.NewNoop())
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/transactions?stream=true", nil)
+		rec := httptest.NewRecorder()
+		empty.GetTransactions(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, "[]", rec.Body.String())
+	})
+
+	t.Run("Invalid stream parameter", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/transactions?stream=maybe", nil)
+		rec := httptest.NewRecorder()
+		handler.GetTransactions(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+// stubImpactTransactionService returns fixed impacts: 1 is a processed BUY, 2 a processed DEP,
+// 3 an unprocessed transaction, 4 one whose replayed balances overflow and any other ID is not found
+type stubImpactTransactionService struct {
+	services.TransactionService
+}
+
+func (s *stubImpactTransactionService) GetTransactionBalanceImpact(ctx context.Context, id int64, state string) (*dto.TransactionBalanceImpactDTO, error) {
+	if state == "" {
+		state = "processing"
+	}
+	if state != "processing" && state != "current" {
+		return nil, &services.InvalidImpactStateError{State: state}
+	}
+
+	securityID := "SECURITY1234567890123456"
+	switch id {
+	case 1:
+		return &dto.TransactionBalanceImpactDTO{
+			TransactionID: 1, SecurityID: &securityID, TransactionType: "BUY", State: state,
+			SecurityImpact: &dto.BalanceChangeDTO{BalanceType: "SECURITY", LongChange: decimal.NewFromInt(100), ResultingLong: decimal.NewFromInt(100)},
+			CashImpact:     &dto.BalanceChangeDTO{BalanceType: "CASH", LongChange: decimal.NewFromInt(-5000), ResultingLong: decimal.NewFromInt(5000)},
+		}, nil
+	case 2:
+		return &dto.TransactionBalanceImpactDTO{
+			TransactionID: 2, TransactionType: "DEP", State: state,
+			CashImpact: &dto.BalanceChangeDTO{BalanceType: "CASH", LongChange: decimal.NewFromInt(2000), ResultingLong: decimal.NewFromInt(7000)},
+		}, nil
+	case 3:
+		if state == "processing" {
+			return nil, fmt.Errorf("%w: transaction 3 has status NEW", services.ErrTransactionNotProcessed)
+		}
+		return &dto.TransactionBalanceImpactDTO{TransactionID: 3, State: state}, nil
+	case 4:
+		return nil, fmt.Errorf("failed to compute transaction balance impact: %w", &domainServices.CalculationOverflowError{
+			PortfolioID: "PORTFOLIO123456789012345", Field: "quantityLong", Value: decimal.RequireFromString("12000000000"),
+		})
+	}
+	return nil, fmt.Errorf("transaction not found: %d", id)
+}
+
+func TestGetTransactionBalanceImpact(t *testing.T) {
+	handler := NewTransactionHandler(&stubImpactTransactionService{}, logger.NewNoop())
+	r := chi.NewRouter()
+	r.With(middleware.ValidateParams(middleware.PathIDParam("id"))).Get("/api/v1/transaction/{id}/impact", handler.GetTransactionBalanceImpact)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("Security transaction", func(t *testing.T) {
+		rec := get("/api/v1/transaction/1/impact")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var impact dto.TransactionBalanceImpactDTO
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &impact))
+		assert.Equal(t, "processing", impact.State)
+		require.NotNil(t, impact.SecurityImpact)
+		assert.True(t, decimal.NewFromInt(100).Equal(impact.SecurityImpact.ResultingLong))
+		require.NotNil(t, impact.CashImpact)
+		assert.True(t, decimal.NewFromInt(-5000).Equal(impact.CashImpact.LongChange))
+	})
+
+	t.Run("Cash transaction against current balances", func(t *testing.T) {
+		rec := get("/api/v1/transaction/2/impact?state=current")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "current", body["state"])
+		assert.NotContains(t, body, "securityImpact")
+		assert.Contains(t, body, "cashImpact")
+	})
+
+	t.Run("Unprocessed transaction", func(t *testing.T) {
+		rec := get("/api/v1/transaction/3/impact")
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "TRANSACTION_NOT_PROCESSED")
+
+		assert.Equal(t, http.StatusOK, get("/api/v1/transaction/3/impact?state=current").Code)
+	})
+
+	t.Run("Invalid state", func(t *testing.T) {
+		rec := get("/api/v1/transaction/1/impact?state=yesterday")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "INVALID_STATE")
+	})
+
+	t.Run("Overflowing replay", func(t *testing.T) {
+		rec := get("/api/v1/transaction/4/impact")
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "CALCULATION_OVERFLOW")
+	})
+
+	t.Run("Transaction not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/api/v1/transaction/99/impact").Code)
+	})
+}
+
+// stubBalancesTransactionService returns the security and cash balances of transaction 1, a
+// BUY, the cash balance of transaction 2, a DEP, and reports any other ID as not found
+type stubBalancesTransactionService struct {
+	services.TransactionService
+}
+
+func (s *stubBalancesTransactionService) GetTransactionBalances(ctx context.Context, id int64) (*dto.TransactionBalancesDTO, error) {
+	securityID := "SECURITY1234567890123456"
+	cash := dto.BalanceDTO{ID: 10, PortfolioID: "PORTFOLIO123456789012345", QuantityLong: decimal.NewFromInt(5000)}
+	switch id {
+	case 1:
+		return &dto.TransactionBalancesDTO{
+			TransactionID: 1, SecurityID: &securityID, TransactionType: "BUY",
+			Balances: []dto.BalanceDTO{
+				{ID: 11, PortfolioID: "PORTFOLIO123456789012345", SecurityID: &securityID, QuantityLong: decimal.NewFromInt(100)},
+				cash,
+			},
+		}, nil
+	case 2:
+		return &dto.TransactionBalancesDTO{TransactionID: 2, TransactionType: "DEP", Balances: []dto.BalanceDTO{cash}}, nil
+	}
+	return nil, fmt.Errorf("transaction not found: %d", id)
+}
+
+func TestGetTransactionBalances(t *testing.T) {
+	handler := NewTransactionHandler(&stubBalancesTransactionService{}, logger.NewNoop())
+	r := chi.NewRouter()
+	r.With(middleware.ValidateParams(middleware.PathIDParam("id"))).Get("/api/v1/transactions/{id}/balances", handler.GetTransactionBalances)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("BUY returns two balances", func(t *testing.T) {
+		rec := get("/api/v1/transactions/1/balances")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body dto.TransactionBalancesDTO
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Balances, 2)
+		assert.NotNil(t, body.Balances[0].SecurityID)
+		assert.Nil(t, body.Balances[1].SecurityID)
+	})
+
+	t.Run("DEP returns the cash balance", func(t *testing.T) {
+		rec := get("/api/v1/transactions/2/balances")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body dto.TransactionBalancesDTO
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Balances, 1)
+		assert.Nil(t, body.Balances[0].SecurityID)
+	})
+
+	t.Run("Invalid ID", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("/api/v1/transactions/abc/balances").Code)
+	})
+
+	t.Run("Transaction not found", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/api/v1/transactions/99/balances").Code)
+	})
+}
+
+// stubCountTransactionService counts 42 transactions for any filter
+type stubCountTransactionService struct {
+	services.TransactionService
+	filter *dto.TransactionFilter
+}
+
+func (s *stubCountTransactionService) CountTransactions(ctx context.Context, filter dto.TransactionFilter) (int64, error) {
+	s.filter = &filter
+	return 42, nil
+}
+
+func TestCountTransactions(t *testing.T) {
+	svc := &stubCountTransactionService{}
+	handler := NewTransactionHandler(svc, logger.NewNoop())
+
+	rec := httptest.NewRecorder()
+	handler.CountTransactions(rec, httptest.NewRequest(http.MethodGet, "/api/v1/transactions/count?status=PROC", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"count":42}`, rec.Body.String())
+	require.NotNil(t, svc.filter)
+	require.NotNil(t, svc.filter.Status)
+	assert.Equal(t, "PROC", *svc.filter.Status)
+}
+
+// stubCanceledTransactionService blocks each query until the request's context ends and then
+// fails the way the repository reports an abandoned query
+type stubCanceledTransactionService struct {
+	services.TransactionService
+}
+
+func (s *stubCanceledTransactionService) canceledQuery(ctx context.Context, operation string) error {
+	<-ctx.Done()
+	return fmt.Errorf("failed to %s transactions: %w", operation,
+		repositories.NewQueryCanceledError(operation, "transaction", ctx.Err(), errors.New("pq: canceling statement due to user request")))
+}
+
+func (s *stubCanceledTransactionService) GetTransactions(ctx context.Context, filter dto.TransactionFilter) (*dto.TransactionListResponse, error) {
+	return nil, s.canceledQuery(ctx, "list")
+}
+
+func (s *stubCanceledTransactionService) CountTransactions(ctx context.Context, filter dto.TransactionFilter) (int64, error) {
+	return 0, s.canceledQuery(ctx, "count")
+}
+
+func TestTransactionQueriesCanceledMidQuery(t *testing.T) {
+	handler := NewTransactionHandler(&stubCanceledTransactionService{}, logger.NewNoop())
+
+	serve := func(h http.HandlerFunc, path string, ctx context.Context) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx))
+		return rec
+	}
+
+	t.Run("Client disconnects", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		rec := serve(handler.GetTransactions, "/api/v1/transactions", ctx)
+		assert.Equal(t, 499, rec.Code)
+		assert.Contains(t, rec.Body.String(), "CLIENT_CLOSED_REQUEST")
+	})
+
+	t.Run("Request times out", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		rec := serve(handler.CountTransactions, "/api/v1/transactions/count", ctx)
+		assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+		assert.Contains(t, rec.Body.String(), "QUERY_TIMEOUT")
+	})
+}
+
+// stubBatchGetTransactionService finds the transactions with an even ID or a source ID starting
+// with "FOUND" and caps requests at three IDs
+type stubBatchGetTransactionService struct {
+	services.TransactionService
+}
+
+func (s *stubBatchGetTransactionService) GetTransactionsByIDs(ctx context.Context, ids []int64) (*dto.TransactionBatchGetResponse, error) {
+	if len(ids) > 3 {
+		return nil, &services.BatchGetTooLargeError{Requested: len(ids), Max: 3}
+	}
+	response := &dto.TransactionBatchGetResponse{Transactions: []dto.TransactionResponseDTO{}, NotFoundIDs: []int64{}}
+	for _, id := range ids {
+		if id%2 == 0 {
+			response.Transactions = append(response.Transactions, dto.TransactionResponseDTO{ID: id})
+		} else {
+			response.NotFoundIDs = append(response.NotFoundIDs, id)
+		}
+	}
+	return response, nil
+}
+
+func (s *stubBatchGetTransactionService) GetTransactionsBySourceIDs(ctx context.Context, sourceIDs []string) (*dto.TransactionSourceBatchGetResponse, error) {
+	if len(sourceIDs) > 3 {
+		return nil, &services.BatchGetTooLargeError{Requested: len(sourceIDs), Max: 3}
+	}
+	response := &dto.TransactionSourceBatchGetResponse{Transactions: []dto.TransactionResponseDTO{}, NotFoundSourceIDs: []string{}}
+	for _, sourceID := range sourceIDs {
+		if strings.HasPrefix(sourceID, "FOUND") {
+			response.Transactions = append(response.Transactions, dto.TransactionResponseDTO{SourceID: sourceID})
+		} else {
+			response.NotFoundSourceIDs = append(response.NotFoundSourceIDs, sourceID)
+		}
+	}
+	return response, nil
+}
+
+// stubLatestTransactionService returns a transaction for portfolios starting with "P" and caps
+// requests at three portfolios
+type stubLatestTransactionService struct {
+	services.TransactionService
+}
+
+func (s *stubLatestTransactionService) GetLatestTransactions(ctx context.Context, portfolioIDs []string) (*dto.LatestTransactionsResponse, error) {
+	if len(portfolioIDs) > 3 {
+		return nil, &services.BatchGetTooLargeError{Requested: len(portfolioIDs), Max: 3}
+	}
+	response := &dto.LatestTransactionsResponse{Transactions: []dto.TransactionResponseDTO{}, PortfoliosWithoutTransactions: []string{}}
+	for _, portfolioID := range portfolioIDs {
+		if strings.HasPrefix(portfolioID, "P") {
+			response.Transactions = append(response.Transactions, dto.TransactionResponseDTO{PortfolioID: portfolioID})
+		} else {
+			response.PortfoliosWithoutTransactions = append(response.PortfoliosWithoutTransactions, portfolioID)
+		}
+	}
+	return response, nil
+}
+
+func TestGetLatestTransactions(t *testing.T) {
+	handler := NewTransactionHandler(&stubLatestTransactionService{}, logger.NewNoop())
+
+	serve := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.GetLatestTransactions(rec, httptest.NewRequest(http.MethodGet, "/api/v1/portfolios/latest-transactions"+query, nil))
+		return rec
+	}
+
+	t.Run("Portfolios with and without transactions", func(t *testing.T) {
+		rec := serve("?portfolio_ids=P1,%20X2,,P3")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response dto.LatestTransactionsResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Len(t, response.Transactions, 2)
+		assert.Equal(t, "P1", response.Transactions[0].PortfolioID)
+		assert.Equal(t, "P3", response.Transactions[1].PortfolioID)
+		assert.Equal(t, []string{"X2"}, response.PortfoliosWithoutTransactions)
+	})
+
+	t.Run("No portfolio IDs", func(t *testing.T) {
+		for _, query := range []string{"", "?portfolio_ids=", "?portfolio_ids=,%20"} {
+			rec := serve(query)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+			assert.Contains(t, rec.Body.String(), "MISSING_PORTFOLIO_IDS", query)
+		}
+	})
+
+	t.Run("Too many portfolio IDs", func(t *testing.T) {
+		rec := serve("?portfolio_ids=P1,P2,P3,P4")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "TOO_MANY_PORTFOLIOS")
+		assert.Contains(t, rec.Body.String(), "At most 3 portfolios")
+	})
+}
+
+func TestBatchGetTransactions(t *testing.T) {
+	handler := NewTransactionHandler(&stubBatchGetTransactionService{}, logger.NewNoop())
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.BatchGetTransactions(rec, httptest.NewRequest(http.MethodPost, "/api/v1/transactions/batch-get", strings.NewReader(body)))
+		return rec
+	}
+
+	t.Run("Mixed found and not found", func(t *testing.T) {
+		rec := serve(`{"ids":[2,3,4]}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response dto.TransactionBatchGetResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Len(t, response.Transactions, 2)
+		assert.Equal(t, int64(2), response.Transactions[0].ID)
+		assert.Equal(t, int64(4), response.Transactions[1].ID)
+		assert.Equal(t, []int64{3}, response.NotFoundIDs)
+	})
+
+	t.Run("No IDs", func(t *testing.T) {
+		rec := serve(`{"ids":[]}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "EMPTY_BATCH")
+	})
+
+	t.Run("Too many IDs", func(t *testing.T) {
+		rec := serve(`{"ids":[1,2,3,4]}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "BATCH_TOO_LARGE")
+		assert.Contains(t, rec.Body.String(), "At most 3 transactions")
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		rec := serve(`{"ids":"1"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "INVALID_JSON")
+	})
+}
+
+func TestBatchGetTransactionsBySourceID(t *testing.T) {
+	handler := NewTransactionHandler(&stubBatchGetTransactionService{}, logger.NewNoop())
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.BatchGetTransactionsBySourceID(rec, httptest.NewRequest(http.MethodPost, "/api/v1/transactions/by-source/batch", strings.NewReader(body)))
+		return rec
+	}
+
+	t.Run("Mixed found and not found", func(t *testing.T) {
+		rec := serve(`{"sourceIds":["FOUND-1","MISSING-1","FOUND-2"]}`)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response dto.TransactionSourceBatchGetResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Len(t, response.Transactions, 2)
+		assert.Equal(t, "FOUND-1", response.Transactions[0].SourceID)
+		assert.Equal(t, "FOUND-2", response.Transactions[1].SourceID)
+		assert.Equal(t, []string{"MISSING-1"}, response.NotFoundSourceIDs)
+	})
+
+	t.Run("No source IDs", func(t *testing.T) {
+		rec := serve(`{"sourceIds":[]}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "EMPTY_BATCH")
+	})
+
+	t.Run("Too many source IDs", func(t *testing.T) {
+		rec := serve(`{"sourceIds":["A","B","C","D"]}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "BATCH_TOO_LARGE")
+		assert.Contains(t, rec.Body.String(), "At most 3 transactions")
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		rec := serve(`{"sourceIds":"A"}`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "INVALID_JSON")
+	})
+}
+
+// quarantineReleaseService records the limit of the last release
+type quarantineReleaseService struct {
+	services.TransactionService
+
+	limit int
+}
+
+func (s *quarantineReleaseService) ReleaseQuarantinedTransactions(ctx context.Context, limit int) (*dto.QuarantineReleaseResponse, error) {
+	s.limit = limit
+	return &dto.QuarantineReleaseResponse{
+		Checked:          2,
+		Released:         []dto.TransactionProcessingResult{{TransactionID: 2, Status: "PROC", BalanceUpdated: true}},
+		StillQuarantined: []int64{1},
+	}, nil
+}
+
+func TestReleaseQuarantinedTransactions(t *testing.T) {
+	service := &quarantineReleaseService{}
+	handler := NewTransactionHandler(service, logger.NewNoop())
+
+	serve := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ReleaseQuarantinedTransactions(rec, httptest.NewRequest(http.MethodPost, "/api/v1/transactions/quarantine/release"+query, nil))
+		return rec
+	}
+
+	t.Run("Default limit", func(t *testing.T) {
+		rec := serve("")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, defaultQuarantineReleaseLimit, service.limit)
+
+		var response dto.QuarantineReleaseResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, 2, response.Checked)
+		require.Len(t, response.Released, 1)
+		assert.Equal(t, int64(2), response.Released[0].TransactionID)
+		assert.Equal(t, []int64{1}, response.StillQuarantined)
+	})
+
+	t.Run("Explicit limit", func(t *testing.T) {
+		rec := serve("?limit=25")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 25, service.limit)
+	})
+
+	for _, limit := range []string{"0", "1001", "abc"} {
+		t.Run("Invalid limit "+limit, func(t *testing.T) {
+			rec := serve("?limit=" + limit)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "INVALID_LIMIT")
+		})
+	}
+}
+
+// ledgerTransactionService returns a two-entry ledger for any window and counts the replays.
+// With failAfter set, a stream fails with an overflow after that many entries.
+type ledgerTransactionService struct {
+	services.TransactionService
+
+	replays   int
+	failAfter *int
+}
+
+func (s *ledgerTransactionService) checkWindow(from, to time.Time) error {
+	if to.Sub(from) > 31*24*time.Hour {
+		return &services.LedgerWindowTooLargeError{Days: int(to.Sub(from).Hours()/24) + 1, Max: 32}
+	}
+	return nil
+}
+
+func (s *ledgerTransactionService) entries(from, to time.Time) []dto.LedgerEntryDTO {
+	return []dto.LedgerEntryDTO{
+		{EffectiveDate: from.Format("2006-01-02"), Transaction: &dto.TransactionResponseDTO{ID: 1}},
+		{EffectiveDate: to.Format("2006-01-02"), Transaction: &dto.TransactionResponseDTO{ID: 2}},
+	}
+}
+
+func (s *ledgerTransactionService) GetPortfolioLedger(ctx context.Context, portfolioID string, from, to time.Time) (*dto.PortfolioLedgerResponse, error) {
+	if err := s.checkWindow(from, to); err != nil {
+		return nil, err
+	}
+	s.replays++
+	return &dto.PortfolioLedgerResponse{
+		PortfolioID:     portfolioID,
+		FromDate:        from.Format("2006-01-02"),
+		ToDate:          to.Format("2006-01-02"),
+		OpeningBalances: []dto.BalanceDTO{},
+		Entries:         s.entries(from, to),
+	}, nil
+}
+
+func (s *ledgerTransactionService) StreamPortfolioLedger(ctx context.Context, portfolioID string, from, to time.Time, emit func(dto.LedgerEntryDTO) error) (int, error) {
+	if err := s.checkWindow(from, to); err != nil {
+		return 0, err
+	}
+	s.replays++
+	for i, entry := range s.entries(from, to) {
+		if s.failAfter != nil && i == *s.failAfter {
+			return i, fmt.Errorf("failed to stream portfolio ledger: %w", &domainServices.CalculationOverflowError{PortfolioID: portfolioID, Field: "quantity_long"})
+		}
+		if err := emit(entry); err != nil {
+			return i, err
+		}
+	}
+	return 2, nil
+}
+
+func TestGetPortfolioLedger(t *testing.T) {
+	const portfolioID = "PORTFOLIO123456789012345"
+
+	setup := func(t *testing.T) (*TransactionHandler, *ledgerTransactionService) {
+		service := &ledgerTransactionService{}
+		return NewTransactionHandler(service, logger.NewNoop()), service
+	}
+
+	serve := func(handler *TransactionHandler, query string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/portfolios/"+portfolioID+"/ledger?"+query, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		routeCtx := chi.NewRouteContext()
+		routeCtx.URLParams.Add("portfolioId", portfolioID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+
+		rec := httptest.NewRecorder()
+		handler.GetPortfolioLedger(rec, req)
+		return rec
+	}
+
+	t.Run("Every request is replayed", func(t *testing.T) {
+		handler, service := setup(t)
+
+		for i := 0; i < 2; i++ {
+			rec := serve(handler, "from=2024-01-01&to=2024-01-31", nil)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var response dto.PortfolioLedgerResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Len(t, response.Entries, 2)
+		}
+		assert.Equal(t, 2, service.replays)
+	})
+
+	t.Run("NDJSON returns only the entries", func(t *testing.T) {
+		handler, _ := setup(t)
+
+		rec := serve(handler, "from=2024-01-01&to=2024-01-31", http.Header{"Accept": {"application/x-ndjson"}})
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+		var ids []int64
+		scanner := bufio.NewScanner(rec.Body)
+		for scanner.Scan() {
+			var entry dto.LedgerEntryDTO
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+			ids = append(ids, entry.Transaction.ID)
+		}
+		assert.Equal(t, []int64{1, 2}, ids)
+	})
+
+	t.Run("stream=true returns the entries as a JSON array", func(t *testing.T) {
+		handler, _ := setup(t)
+
+		rec := serve(handler, "from=2024-01-01&to=2024-01-31&stream=true", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var entries []dto.LedgerEntryDTO
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+		require.Len(t, entries, 2)
+		assert.Equal(t, "2024-01-31", entries[1].EffectiveDate)
+	})
+
+	t.Run("Invalid windows", func(t *testing.T) {
+		handler, service := setup(t)
+
+		for query, code := range map[string]string{
+			"to=2024-01-31":                              "MISSING_DATE",
+			"from=2024-01-01&to=31-01-2024":              "INVALID_DATE",
+			"from=2024-02-01&to=2024-01-31":              "INVALID_WINDOW",
+			"from=2024-01-01&to=2024-12-31":              "WINDOW_TOO_LARGE",
+			"from=2024-01-01&to=2024-01-31&stream=maybe": "INVALID_PARAMETER",
+		} {
+			rec := serve(handler, query, nil)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+			assert.Contains(t, rec.Body.String(), code, query)
+		}
+		assert.Zero(t, service.replays)
+	})
+
+	t.Run("Overflow before the first entry is answered with its status", func(t *testing.T) {
+		handler, service := setup(t)
+		failAfter := 0
+		service.failAfter = &failAfter
+
+		rec := serve(handler, "from=2024-01-01&to=2024-01-31&stream=true", nil)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "CALCULATION_OVERFLOW")
+	})
+
+	t.Run("Overflow after the first entry leaves the array unterminated", func(t *testing.T) {
+		handler, service := setup(t)
+		failAfter := 1
+		service.failAfter = &failAfter
+
+		rec := serve(handler, "from=2024-01-01&to=2024-01-31&stream=true", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var entries []dto.LedgerEntryDTO
+		assert.Error(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+		assert.True(t, strings.HasPrefix(rec.Body.String(), "["))
+		assert.Contains(t, rec.Body.String(), `"effectiveDate":"2024-01-01"`)
+	})
+}
@@ -34,11 +34,13 @@ func DefaultCORSConfig() CORSConfig {
 			"Accept",
 			"Authorization",
 			"Content-Type",
+			"If-Match",
 			"X-Correlation-ID",
 			"X-Request-ID",
 			"X-CSRF-Token",
 		},
 		ExposedHeaders: []string{
+			"ETag",
 			"X-Correlation-ID",
 			"X-Request-ID",
 		},
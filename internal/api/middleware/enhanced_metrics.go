@@ -13,6 +13,7 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
@@ -25,6 +26,10 @@ type EnhancedMetricsConfig struct {
 	MaxPathPatternCache   int  // Maximum number of path patterns to cache
 	MaxPathLength         int  // Maximum path length to prevent cardinality explosion
 	EnableFailsafeLogging bool // Enable detailed error logging for debugging
+	// EnableExemplars attaches the active trace to duration observations of at least
+	// ExemplarThreshold, linking slow requests in the histogram to their traces
+	EnableExemplars   bool
+	ExemplarThreshold time.Duration
 }
 
 // EnhancedMetricsMiddleware provides OpenTelemetry-based HTTP metrics collection
@@ -42,6 +47,8 @@ type EnhancedMetricsMiddleware struct {
 	maxPathPatternCache   int
 	maxPathLength         int
 	enableFailsafeLogging bool
+	enableExemplars       bool
+	exemplarThreshold     time.Duration
 
 	// Path pattern cache for performance with thread safety
 	pathPatterns map[string]string
@@ -70,6 +77,8 @@ func NewEnhancedMetricsMiddleware(config EnhancedMetricsConfig) *EnhancedMetrics
 		maxPathPatternCache:   config.MaxPathPatternCache,
 		maxPathLength:         config.MaxPathLength,
 		enableFailsafeLogging: config.EnableFailsafeLogging,
+		enableExemplars:       config.EnableExemplars,
+		exemplarThreshold:     config.ExemplarThreshold,
 		pathPatterns:          make(map[string]string),
 		logger:                logger.GetGlobal(),
 		initializationFailed:  false,
@@ -248,7 +257,8 @@ func (m *EnhancedMetricsMiddleware) Handler() func(http.Handler) http.Handler {
 			}()
 
 			// Calculate duration in milliseconds
-			duration := float64(time.Since(start).Nanoseconds()) / 1e6
+			elapsed := time.Since(start)
+			duration := float64(elapsed.Nanoseconds()) / 1e6
 
 			// Prepare labels with validation
 			method := m.sanitizeMethod(r.Method)
@@ -279,13 +289,26 @@ func (m *EnhancedMetricsMiddleware) Handler() func(http.Handler) http.Handler {
 				if duration < 0 || duration > 300000 { // 5 minutes max
 					return fmt.Errorf("invalid duration: %f ms", duration)
 				}
-				m.httpRequestDuration.Record(r.Context(), duration, metric.WithAttributes(attrs...))
+				m.httpRequestDuration.Record(m.exemplarContext(r.Context(), elapsed), duration, metric.WithAttributes(attrs...))
 				return nil
 			}, "http_request_duration_milliseconds")
 		})
 	}
 }
 
+// exemplarContext returns the context a request duration is recorded with. The SDK attaches a
+// trace exemplar to observations made with a sampled span in the context, so the span is hidden
+// unless exemplars are enabled and the request took at least the exemplar threshold.
+func (m *EnhancedMetricsMiddleware) exemplarContext(ctx context.Context, elapsed time.Duration) context.Context {
+	if m.enableExemplars && elapsed >= m.exemplarThreshold {
+		return ctx
+	}
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	return trace.ContextWithSpanContext(ctx, trace.SpanContext{})
+}
+
 // enhancedMetricsResponseWriter wraps http.ResponseWriter to capture status codes
 type enhancedMetricsResponseWriter struct {
 	http.ResponseWriter
@@ -310,6 +333,11 @@ func (w *enhancedMetricsResponseWriter) Write(b []byte) (int, error) {
 	return w.ResponseWriter.Write(b)
 }
 
+// Unwrap returns the underlying response writer so http.ResponseController can reach it
+func (w *enhancedMetricsResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // extractPathPatternSafely normalizes URL paths to route patterns with cardinality protection
 func (m *EnhancedMetricsMiddleware) extractPathPatternSafely(path string) string {
 	// Validate path length to prevent cardinality explosion
@@ -15,6 +15,7 @@ import (
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 )
 
@@ -1292,4 +1293,67 @@ func setupTestMeterProviderWithReader(t *testing.T, reader metric.Reader) *metri
 	})
 
 	return meterProvider
-}
\ No newline at end of file
+}
+func TestEnhancedMetricsMiddleware_Exemplars(t *testing.T) {
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()))
+
+	tests := []struct {
+		name           string
+		enabled        bool
+		threshold      time.Duration
+		expectExemplar bool
+	}{
+		{name: "Attached when enabled", enabled: true, threshold: 0, expectExemplar: true},
+		{name: "Not attached when disabled", enabled: false, threshold: 0, expectExemplar: false},
+		{name: "Not attached below the threshold", enabled: true, threshold: time.Hour, expectExemplar: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := metric.NewManualReader()
+			setupTestMeterProviderWithReader(t, reader)
+
+			middleware := NewEnhancedMetricsMiddleware(EnhancedMetricsConfig{
+				ServiceName:       "test-service",
+				Enabled:           true,
+				EnableExemplars:   tt.enabled,
+				ExemplarThreshold: tt.threshold,
+			})
+			handler := middleware.Handler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			// The request runs inside a sampled span, as it does behind the tracing handler
+			ctx, span := tracerProvider.Tracer("test").Start(context.Background(), "request")
+			req := httptest.NewRequest("GET", "/api/v1/transactions", nil).WithContext(ctx)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			span.End()
+
+			rm := metricdata.ResourceMetrics{}
+			require.NoError(t, reader.Collect(context.Background(), &rm))
+
+			var histogramData *metricdata.Histogram[float64]
+			for _, scopeMetric := range rm.ScopeMetrics {
+				for _, m := range scopeMetric.Metrics {
+					if data, ok := m.Data.(metricdata.Histogram[float64]); ok && m.Name == "http_request_duration_milliseconds" {
+						histogramData = &data
+					}
+				}
+			}
+			require.NotNil(t, histogramData, "Should find histogram metric")
+			require.Len(t, histogramData.DataPoints, 1)
+
+			exemplars := histogramData.DataPoints[0].Exemplars
+			if !tt.expectExemplar {
+				assert.Empty(t, exemplars)
+				return
+			}
+
+			require.Len(t, exemplars, 1)
+			traceID := span.SpanContext().TraceID()
+			spanID := span.SpanContext().SpanID()
+			assert.Equal(t, traceID[:], exemplars[0].TraceID)
+			assert.Equal(t, spanID[:], exemplars[0].SpanID)
+		})
+	}
+}
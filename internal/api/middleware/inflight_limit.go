@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// InFlightLimitMiddleware sheds load by answering 503 once too many requests are being served,
+// so a traffic spike queues at the client rather than on the database connection pool
+type InFlightLimitMiddleware struct {
+	slots  chan struct{}
+	exempt []string
+	logger logger.Logger
+}
+
+// NewInFlightLimitMiddleware creates a middleware that serves at most limit requests at once.
+// Requests whose path starts with one of the exempt prefixes, such as health probes, are
+// neither limited nor counted. A non-positive limit disables the middleware.
+func NewInFlightLimitMiddleware(limit int, exempt []string, logger logger.Logger) *InFlightLimitMiddleware {
+	m := &InFlightLimitMiddleware{
+		exempt: exempt,
+		logger: logger,
+	}
+	if limit > 0 {
+		m.slots = make(chan struct{}, limit)
+	}
+	return m
+}
+
+// Handler returns a middleware handler that rejects a request arriving while the limit is
+// reached with 503 SERVICE_OVERLOADED and a Retry-After header. A rejected request never
+// waits. Install it after the enhanced metrics middleware so shed requests still appear in the
+// in-flight gauge and are counted with their 503 status.
+func (m *InFlightLimitMiddleware) Handler() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if m.slots == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.isExempt(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			select {
+			case m.slots <- struct{}{}:
+				defer func() { <-m.slots }()
+				next.ServeHTTP(w, r)
+			default:
+				m.logger.Debug("Request shed, too many requests in flight",
+					zap.String("request_id", GetRequestID(r.Context())),
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.Int("max_in_flight", cap(m.slots)))
+				writeOverloadedResponse(w)
+			}
+		})
+	}
+}
+
+// isExempt reports whether requests for the path bypass the limit
+func (m *InFlightLimitMiddleware) isExempt(path string) bool {
+	for _, prefix := range m.exempt {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeOverloadedResponse writes the 503 response for a shed request
+func writeOverloadedResponse(w http.ResponseWriter) {
+	errorResp := dto.ErrorResponse{
+		Error: dto.ErrorDetail{
+			Code:      "SERVICE_OVERLOADED",
+			Message:   "Too many requests in flight, retry later",
+			Timestamp: time.Now(),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", "1")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = json.NewEncoder(w).Encode(errorResp)
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+func TestInFlightLimitMiddleware(t *testing.T) {
+	const limit = 3
+
+	// blockingHandler holds every request until release is closed
+	setup := func(limit int) (http.Handler, chan struct{}, chan struct{}) {
+		entered := make(chan struct{}, 100)
+		release := make(chan struct{})
+		handler := NewInFlightLimitMiddleware(limit, []string{"/health"}, logger.NewNoop()).Handler()(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				entered <- struct{}{}
+				<-release
+				w.WriteHeader(http.StatusOK)
+			}))
+		return handler, entered, release
+	}
+	serve := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("Requests beyond the limit are shed", func(t *testing.T) {
+		handler, entered, release := setup(limit)
+
+		// Fill every slot
+		var wg sync.WaitGroup
+		admitted := make([]*httptest.ResponseRecorder, limit)
+		for i := 0; i < limit; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				admitted[i] = serve(handler, "/api/v1/transactions")
+			}(i)
+		}
+		for i := 0; i < limit; i++ {
+			<-entered
+		}
+
+		// Concurrent requests past the limit are answered at once
+		shed := make([]*httptest.ResponseRecorder, 5)
+		var shedWG sync.WaitGroup
+		for i := range shed {
+			shedWG.Add(1)
+			go func(i int) {
+				defer shedWG.Done()
+				shed[i] = serve(handler, "/api/v1/balances")
+			}(i)
+		}
+		shedWG.Wait()
+
+		for _, rec := range shed {
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+			assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+			var response dto.ErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Equal(t, "SERVICE_OVERLOADED", response.Error.Code)
+		}
+
+		// Exempt paths are served even at the limit
+		exempt := make(chan *httptest.ResponseRecorder)
+		go func() { exempt <- serve(handler, "/health/ready") }()
+
+		close(release)
+		assert.Equal(t, http.StatusOK, (<-exempt).Code)
+		wg.Wait()
+		for _, rec := range admitted {
+			assert.Equal(t, http.StatusOK, rec.Code)
+		}
+
+		// Completed requests free their slots
+		assert.Equal(t, http.StatusOK, serve(handler, "/api/v1/transactions").Code)
+	})
+
+	t.Run("Zero limit serves every request", func(t *testing.T) {
+		handler, entered, release := setup(0)
+		close(release)
+
+		var wg sync.WaitGroup
+		codes := make([]int, 10)
+		for i := range codes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				codes[i] = serve(handler, "/api/v1/transactions").Code
+			}(i)
+		}
+		wg.Wait()
+		assert.Len(t, entered, 10)
+		for _, code := range codes {
+			assert.Equal(t, http.StatusOK, code)
+		}
+	})
+}
@@ -123,6 +123,11 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap returns the underlying response writer so http.ResponseController can reach it
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // CorrelationIDMiddleware adds correlation ID to requests if not present
 func CorrelationIDMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -196,6 +196,11 @@ func (mrw *metricsResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap returns the underlying response writer so http.ResponseController can reach it
+func (mrw *metricsResponseWriter) Unwrap() http.ResponseWriter {
+	return mrw.ResponseWriter
+}
+
 // getEndpointPattern extracts the endpoint pattern from URL path
 func (m *MetricsMiddleware) getEndpointPattern(path string) string {
 	// Map specific paths to patterns for better metric grouping
@@ -0,0 +1,263 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+)
+
+// ParamRule validates one path or query parameter. Absent query parameters are not validated,
+// so handlers keep deciding which parameters are required and what their defaults are.
+type ParamRule struct {
+	name     string
+	inPath   bool
+	integer  bool                      // the validated value is stored for PathID, Pagination and IntParam
+	validate func(value string) string // returns the failure message, or "" when valid
+}
+
+// validatedIntsKey is the request context key of the integer parameters validated by ValidateParams
+type validatedIntsKey struct{}
+
+// PathIDParam requires a path parameter to be a positive integer ID
+func PathIDParam(name string) ParamRule {
+	return ParamRule{
+		name:    name,
+		inPath:  true,
+		integer: true,
+		validate: func(value string) string {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id < 1 {
+				return "must be a positive integer"
+			}
+			return ""
+		},
+	}
+}
+
+// QueryInt requires a query parameter to be an integer of at least min and, when max is
+// positive, at most max
+func QueryInt(name string, min, max int) ParamRule {
+	return ParamRule{
+		name:    name,
+		integer: true,
+		validate: func(value string) string {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return "must be an integer"
+			}
+			if n < min {
+				return fmt.Sprintf("must be at least %d", min)
+			}
+			if max > 0 && n > max {
+				return fmt.Sprintf("must be at most %d", max)
+			}
+			return ""
+		},
+	}
+}
+
+// QueryDate requires a query parameter to be a date in the given layout
+func QueryDate(name, layout string) ParamRule {
+	return ParamRule{
+		name: name,
+		validate: func(value string) string {
+			if _, err := time.Parse(layout, value); err != nil {
+				return fmt.Sprintf("must be a date in %s format", dateFormatName(layout))
+			}
+			return ""
+		},
+	}
+}
+
+// QueryDuration requires a query parameter to be a non-negative duration such as 720h
+func QueryDuration(name string) ParamRule {
+	return ParamRule{
+		name: name,
+		validate: func(value string) string {
+			if d, err := time.ParseDuration(value); err != nil || d < 0 {
+				return "must be a non-negative duration such as 720h"
+			}
+			return ""
+		},
+	}
+}
+
+// QueryBool requires a query parameter to be a boolean
+func QueryBool(name string) ParamRule {
+	return ParamRule{
+		name: name,
+		validate: func(value string) string {
+			if _, err := strconv.ParseBool(value); err != nil {
+				return "must be a boolean"
+			}
+			return ""
+		},
+	}
+}
+
+// QueryDecimal requires a query parameter to be a decimal number
+func QueryDecimal(name string) ParamRule {
+	return ParamRule{
+		name: name,
+		validate: func(value string) string {
+			if _, err := decimal.NewFromString(value); err != nil {
+				return "must be a decimal number"
+			}
+			return ""
+		},
+	}
+}
+
+// QuerySortFields requires every comma-separated field of a query parameter to be one of the
+// allowed sort fields
+func QuerySortFields(name string, allowed []string) ParamRule {
+	return ParamRule{
+		name: name,
+		validate: func(value string) string {
+			for _, field := range strings.Split(value, ",") {
+				field = strings.TrimSpace(field)
+				if !containsString(allowed, field) {
+					return fmt.Sprintf("unsupported sort field %q; sortable fields are %s", field, strings.Join(allowed, ", "))
+				}
+			}
+			return ""
+		},
+	}
+}
+
+// QuerySchema validates the typed filter fields and the sortby parameter of a list endpoint
+// against its schema, so the fields a client can discover are exactly the ones accepted
+func QuerySchema(schema dto.QuerySchemaDTO) []ParamRule {
+	rules := make([]ParamRule, 0, len(schema.FilterFields)+1)
+	for _, field := range schema.FilterFields {
+		switch field.Type {
+		case dto.QueryFieldTypeDate:
+			rules = append(rules, QueryDate(field.Name, dto.QueryDateLayout))
+		case dto.QueryFieldTypeBoolean:
+			rules = append(rules, QueryBool(field.Name))
+		case dto.QueryFieldTypeDecimal:
+			rules = append(rules, QueryDecimal(field.Name))
+		}
+	}
+	return append(rules, QuerySortFields("sortby", schema.SortFields))
+}
+
+// PaginationParams validates the offset and limit query parameters; maxLimit <= 0 leaves the
+// limit unbounded
+func PaginationParams(maxLimit int) []ParamRule {
+	return []ParamRule{
+		QueryInt("offset", 0, 0),
+		QueryInt("limit", 1, maxLimit),
+	}
+}
+
+// ValidateParams returns a middleware that validates path and query parameters before the
+// handler runs. A request with invalid parameters is answered with 400 INVALID_PARAMETERS
+// listing every invalid parameter; the integer parameters of a valid request are handed to the
+// handler through PathID, Pagination and IntParam. Register it on the route (e.g. with chi's
+// With) so path parameters are already resolved.
+func ValidateParams(rules ...ParamRule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+
+			var validationErrors []dto.ValidationError
+			ints := make(map[string]int64)
+			for _, rule := range rules {
+				var value string
+				if rule.inPath {
+					value = chi.URLParam(r, rule.name)
+				} else {
+					if !query.Has(rule.name) {
+						continue
+					}
+					value = query.Get(rule.name)
+				}
+
+				if message := rule.validate(value); message != "" {
+					validationErrors = append(validationErrors, dto.ValidationError{
+						Field:   rule.name,
+						Message: message,
+						Value:   value,
+					})
+				} else if rule.integer {
+					ints[rule.name], _ = strconv.ParseInt(value, 10, 64)
+				}
+			}
+
+			if len(validationErrors) > 0 {
+				writeParamErrorResponse(w, validationErrors)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), validatedIntsKey{}, ints)))
+		})
+	}
+}
+
+// IntParam returns an integer parameter validated by ValidateParams and whether it was present
+func IntParam(r *http.Request, name string) (int64, bool) {
+	ints, _ := r.Context().Value(validatedIntsKey{}).(map[string]int64)
+	value, ok := ints[name]
+	return value, ok
+}
+
+// PathID returns the id path parameter validated by PathIDParam("id")
+func PathID(r *http.Request) int64 {
+	id, _ := IntParam(r, "id")
+	return id
+}
+
+// Pagination returns the offset and limit query parameters validated by PaginationParams; an
+// absent parameter is returned as zero, so handlers keep applying their own defaults
+func Pagination(r *http.Request) (offset, limit int) {
+	o, _ := IntParam(r, "offset")
+	l, _ := IntParam(r, "limit")
+	return int(o), int(l)
+}
+
+// writeParamErrorResponse writes a 400 response with the invalid parameters as details
+func writeParamErrorResponse(w http.ResponseWriter, validationErrors []dto.ValidationError) {
+	errorResp := dto.ErrorResponse{
+		Error: dto.ErrorDetail{
+			Code:      "INVALID_PARAMETERS",
+			Message:   "Request parameters failed validation",
+			Details:   map[string]interface{}{"errors": validationErrors},
+			Timestamp: time.Now(),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(errorResp)
+}
+
+// dateFormatName describes a date layout in the notation used by the API documentation
+func dateFormatName(layout string) string {
+	switch layout {
+	case "2006-01-02":
+		return "YYYY-MM-DD"
+	case "20060102":
+		return "YYYYMMDD"
+	}
+	return layout
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+)
+
+func TestValidateParams(t *testing.T) {
+	rules := append(PaginationParams(1000),
+		QueryDate("from_date", "2006-01-02"),
+		QueryBool("strict"),
+	)
+
+	r := chi.NewRouter()
+	r.With(ValidateParams(PathIDParam("id"))).Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	r.With(ValidateParams(rules...)).Get("/items", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedFields []string
+	}{
+		{name: "Valid id", target: "/items/42", expectedStatus: http.StatusOK},
+		{name: "Malformed id", target: "/items/abc", expectedStatus: http.StatusBadRequest, expectedFields: []string{"id"}},
+		{name: "Zero id", target: "/items/0", expectedStatus: http.StatusBadRequest, expectedFields: []string{"id"}},
+		{name: "Negative id", target: "/items/-5", expectedStatus: http.StatusBadRequest, expectedFields: []string{"id"}},
+		{name: "No parameters", target: "/items", expectedStatus: http.StatusOK},
+		{name: "Valid parameters", target: "/items?offset=0&limit=1000&from_date=2024-01-31&strict=true", expectedStatus: http.StatusOK},
+		{name: "Non-numeric limit", target: "/items?limit=ten", expectedStatus: http.StatusBadRequest, expectedFields: []string{"limit"}},
+		{name: "Zero limit", target: "/items?limit=0", expectedStatus: http.StatusBadRequest, expectedFields: []string{"limit"}},
+		{name: "Limit above maximum", target: "/items?limit=1001", expectedStatus: http.StatusBadRequest, expectedFields: []string{"limit"}},
+		{name: "Negative offset", target: "/items?offset=-1", expectedStatus: http.StatusBadRequest, expectedFields: []string{"offset"}},
+		{name: "Empty offset", target: "/items?offset=", expectedStatus: http.StatusBadRequest, expectedFields: []string{"offset"}},
+		{name: "Malformed date", target: "/items?from_date=01/31/2024", expectedStatus: http.StatusBadRequest, expectedFields: []string{"from_date"}},
+		{name: "Malformed boolean", target: "/items?strict=maybe", expectedStatus: http.StatusBadRequest, expectedFields: []string{"strict"}},
+		{
+			name:           "Every invalid parameter is reported",
+			target:         "/items?offset=x&limit=-3&from_date=yesterday",
+			expectedStatus: http.StatusBadRequest,
+			expectedFields: []string{"offset", "limit", "from_date"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus == http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Error struct {
+					Code    string `json:"code"`
+					Details struct {
+						Errors []dto.ValidationError `json:"errors"`
+					} `json:"details"`
+				} `json:"error"`
+			}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, "INVALID_PARAMETERS", resp.Error.Code)
+
+			fields := make([]string, 0, len(resp.Error.Details.Errors))
+			for _, validationError := range resp.Error.Details.Errors {
+				fields = append(fields, validationError.Field)
+				assert.NotEmpty(t, validationError.Message)
+			}
+			assert.Equal(t, tt.expectedFields, fields)
+		})
+	}
+}
+
+func TestPagination_UnboundedLimit(t *testing.T) {
+	handler := ValidateParams(PaginationParams(0)...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?limit=50000", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?limit=0", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestValidateParams_ValidatedInts(t *testing.T) {
+	var id int64
+	var offset, limit int
+	var rank int64
+	var hasRank bool
+
+	r := chi.NewRouter()
+	r.With(ValidateParams(append(PaginationParams(0), PathIDParam("id"), QueryInt("rank", 1, 10))...)).Get("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id = PathID(r)
+		offset, limit = Pagination(r)
+		rank, hasRank = IntParam(r, "rank")
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items/42?offset=10&limit=25&rank=3", nil))
+	assert.Equal(t, int64(42), id)
+	assert.Equal(t, 10, offset)
+	assert.Equal(t, 25, limit)
+	assert.Equal(t, int64(3), rank)
+	assert.True(t, hasRank)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items/7", nil))
+	assert.Equal(t, int64(7), id)
+	assert.Zero(t, offset)
+	assert.Zero(t, limit)
+	assert.False(t, hasRank)
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// RecoveryMiddleware turns a panic in a handler into a 500 error response
+type RecoveryMiddleware struct {
+	logger logger.Logger
+}
+
+// NewRecoveryMiddleware creates a new recovery middleware
+func NewRecoveryMiddleware(logger logger.Logger) *RecoveryMiddleware {
+	return &RecoveryMiddleware{
+		logger: logger,
+	}
+}
+
+// Handler returns a middleware handler that recovers from panics further down the chain. The
+// panic is logged with its stack and the request ID, and the client receives a 500 error
+// response carrying the request ID. A panic after the response has started cannot change its
+// status, so the connection is aborted instead of leaving a truncated response looking complete.
+// Install it after RequestIDMiddleware so the request ID is available.
+func (m *RecoveryMiddleware) Handler() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tracked := &recoveryResponseWriter{ResponseWriter: w}
+
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					// Deliberate aborts are left to net/http, which closes the connection quietly
+					panic(recovered)
+				}
+
+				requestID := GetRequestID(r.Context())
+				m.logger.Error("Recovered from handler panic",
+					zap.String("request_id", requestID),
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.String("panic", fmt.Sprint(recovered)),
+					zap.Bool("response_started", tracked.wroteHeader),
+					zap.ByteString("stack", debug.Stack()),
+				)
+
+				if tracked.wroteHeader {
+					panic(http.ErrAbortHandler)
+				}
+				writePanicErrorResponse(w, requestID)
+			}()
+
+			next.ServeHTTP(tracked, r)
+		})
+	}
+}
+
+// writePanicErrorResponse writes the 500 response for a recovered panic
+func writePanicErrorResponse(w http.ResponseWriter, requestID string) {
+	errorResp := dto.ErrorResponse{
+		Error: dto.ErrorDetail{
+			Code:      "INTERNAL_ERROR",
+			Message:   "Internal server error",
+			Timestamp: time.Now(),
+		},
+	}
+	if requestID != "" {
+		errorResp.Error.Details = map[string]interface{}{"requestId": requestID}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = json.NewEncoder(w).Encode(errorResp)
+}
+
+// recoveryResponseWriter records whether the response has started
+type recoveryResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader records that the response has started
+func (rw *recoveryResponseWriter) WriteHeader(code int) {
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Write records that the response has started and writes the data
+func (rw *recoveryResponseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying response writer so http.ResponseController can reach it
+func (rw *recoveryResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
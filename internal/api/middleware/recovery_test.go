@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	setup := func(handler http.HandlerFunc) (http.Handler, *observer.ObservedLogs) {
+		core, logs := observer.New(zapcore.ErrorLevel)
+		recovery := NewRecoveryMiddleware(logger.NewFromZap(zap.New(core)))
+		return RequestIDMiddleware()(recovery.Handler()(handler)), logs
+	}
+
+	t.Run("Panic becomes a 500 error response", func(t *testing.T) {
+		handler, logs := setup(func(w http.ResponseWriter, r *http.Request) {
+			panic("handler bug")
+		})
+
+		rec := httptest.NewRecorder()
+		require.NotPanics(t, func() {
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/transactions", nil))
+		})
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		requestID := rec.Header().Get("X-Request-ID")
+		require.NotEmpty(t, requestID)
+
+		var response dto.ErrorResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		assert.Equal(t, "INTERNAL_ERROR", response.Error.Code)
+		assert.Equal(t, requestID, response.Error.Details["requestId"])
+
+		require.Equal(t, 1, logs.Len())
+		fields := logs.All()[0].ContextMap()
+		assert.Equal(t, requestID, fields["request_id"])
+		assert.Equal(t, "handler bug", fields["panic"])
+		assert.Equal(t, "/api/v1/transactions", fields["path"])
+		assert.Contains(t, fields["stack"], "recovery_test.go")
+	})
+
+	t.Run("Panic after the response started aborts it", func(t *testing.T) {
+		handler, logs := setup(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`[{"id":1},`))
+			panic("handler bug")
+		})
+
+		rec := httptest.NewRecorder()
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/transactions", nil))
+		})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, 1, logs.Len())
+	})
+
+	t.Run("Deliberate aborts pass through", func(t *testing.T) {
+		handler, logs := setup(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		})
+
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+		assert.Zero(t, logs.Len())
+	})
+
+	t.Run("Requests without a panic are untouched", func(t *testing.T) {
+		handler, logs := setup(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Zero(t, logs.Len())
+	})
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/api/handlers"
 	apiMiddleware "github.com/kasbench/globeco-portfolio-accounting-service/internal/api/middleware"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
 	otelhttp "go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
@@ -23,14 +25,53 @@ type Config struct {
 	EnableEnhancedMetrics bool
 	EnableCORS            bool
 	CORSConfig            apiMiddleware.CORSConfig
+
+	// EnableExemplars links enhanced request duration observations of at least ExemplarThreshold
+	// to the request's trace
+	EnableExemplars   bool
+	ExemplarThreshold time.Duration
+
+	// MaxInFlight is the most requests served at once; more are shed with 503. Zero is unlimited.
+	MaxInFlight int
 }
 
+// inFlightExemptPaths are never shed, so probes and scrapes keep working under load
+var inFlightExemptPaths = []string{"/health", "/api/v1/health", "/metrics"}
+
+// Parameter validation shared by the nested and flat API routers; malformed IDs, pagination
+// and dates are rejected before the handlers run. The list limits are not bounded here: the
+// services clamp them to dto.MaxPageLimit and report the clamp in the pagination response.
+var (
+	validateIDParam = apiMiddleware.ValidateParams(apiMiddleware.PathIDParam("id"))
+
+	validateTransactionListParams = apiMiddleware.ValidateParams(append(append(apiMiddleware.PaginationParams(0),
+		apiMiddleware.QuerySchema(dto.TransactionQuerySchema())...),
+		apiMiddleware.QueryBool("strict"),
+	)...)
+
+	validateBalanceListParams = apiMiddleware.ValidateParams(append(apiMiddleware.PaginationParams(0),
+		apiMiddleware.QuerySchema(dto.BalanceQuerySchema())...,
+	)...)
+
+	validateTopPositionsParams = apiMiddleware.ValidateParams(apiMiddleware.QueryInt("limit", 1, 1000))
+	validateSummaryParams      = apiMiddleware.ValidateParams(apiMiddleware.PaginationParams(0)...)
+	validateZeroBalanceParams  = apiMiddleware.ValidateParams(append(apiMiddleware.PaginationParams(0), apiMiddleware.QueryDuration("olderThan"))...)
+	validateAsOfParams         = apiMiddleware.ValidateParams(apiMiddleware.QueryDate("date", "2006-01-02"))
+	validateLedgerParams       = apiMiddleware.ValidateParams(apiMiddleware.QueryDate("from", "2006-01-02"), apiMiddleware.QueryDate("to", "2006-01-02"))
+	validateRetentionParams    = apiMiddleware.ValidateParams(apiMiddleware.QueryDate("before", "2006-01-02"))
+	validateStatsParams        = apiMiddleware.ValidateParams(apiMiddleware.QueryBool("refresh"))
+)
+
 // RouterDependencies holds all dependencies needed for route setup
 type RouterDependencies struct {
 	TransactionHandler *handlers.TransactionHandler
 	BalanceHandler     *handlers.BalanceHandler
 	HealthHandler      *handlers.HealthHandler
 	SwaggerHandler     *handlers.SwaggerHandler
+	FileHandler        *handlers.FileHandler      // Optional; file routes are only registered when set
+	FlagsHandler       *handlers.FlagsHandler     // Optional; the flags route is only registered when set
+	RetentionHandler   *handlers.RetentionHandler // Optional; retention routes are only registered when set
+	StatsHandler       *handlers.StatsHandler     // Optional; stats routes are only registered when set
 	Logger             logger.Logger
 	MetricsRegistry    prometheus.Registerer // Optional custom registry for metrics (used in tests)
 }
@@ -41,6 +82,7 @@ func SetupRouter(config Config, deps RouterDependencies) http.Handler {
 
 	// Create middleware instances
 	loggingMiddleware := apiMiddleware.NewLoggingMiddleware(deps.Logger)
+	recoveryMiddleware := apiMiddleware.NewRecoveryMiddleware(deps.Logger)
 
 	var metricsMiddleware *apiMiddleware.MetricsMiddleware
 	if deps.MetricsRegistry != nil {
@@ -55,8 +97,10 @@ func SetupRouter(config Config, deps RouterDependencies) http.Handler {
 	var enhancedMetricsMiddleware *apiMiddleware.EnhancedMetricsMiddleware
 	if config.EnableEnhancedMetrics {
 		enhancedMetricsConfig := apiMiddleware.EnhancedMetricsConfig{
-			ServiceName: config.ServiceName,
-			Enabled:     true,
+			ServiceName:       config.ServiceName,
+			Enabled:           true,
+			EnableExemplars:   config.EnableExemplars,
+			ExemplarThreshold: config.ExemplarThreshold,
 		}
 		enhancedMetricsMiddleware = apiMiddleware.NewEnhancedMetricsMiddleware(enhancedMetricsConfig)
 	}
@@ -64,7 +108,11 @@ func SetupRouter(config Config, deps RouterDependencies) http.Handler {
 	// Global middleware stack
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
-	r.Use(middleware.Recoverer)
+	r.Use(apiMiddleware.RequestIDMiddleware())
+
+	// Recovery wraps everything after the request ID, so a panicking handler is logged with its
+	// request ID and answered with a 500 error response
+	r.Use(recoveryMiddleware.Handler())
 
 	// Add enhanced metrics middleware after recovery but before other middleware
 	// This ensures it captures all requests including errors
@@ -72,7 +120,11 @@ func SetupRouter(config Config, deps RouterDependencies) http.Handler {
 		r.Use(enhancedMetricsMiddleware.Handler())
 	}
 
-	r.Use(apiMiddleware.RequestIDMiddleware())
+	// Load shedding follows the enhanced metrics, which count shed requests with their 503
+	if config.MaxInFlight > 0 {
+		r.Use(apiMiddleware.NewInFlightLimitMiddleware(config.MaxInFlight, inFlightExemptPaths, deps.Logger).Handler())
+	}
+
 	r.Use(apiMiddleware.CorrelationIDMiddleware())
 
 	// Add CORS middleware if enabled
@@ -126,27 +178,86 @@ func setupAPIRoutes(r chi.Router, deps RouterDependencies) {
 
 		// Transaction endpoints
 		r.Route("/transactions", func(r chi.Router) {
-			r.Get("/", deps.TransactionHandler.GetTransactions)
-			r.Post("/", deps.TransactionHandler.CreateTransactions)
+			r.With(validateTransactionListParams).Get("/", deps.TransactionHandler.GetTransactions)
+			r.With(validateTransactionListParams).Post("/", deps.TransactionHandler.CreateTransactions)
+			r.With(validateTransactionListParams).Get("/count", deps.TransactionHandler.CountTransactions)
+			r.Get("/schema", deps.TransactionHandler.GetTransactionQuerySchema)
+			r.Post("/batch-get", deps.TransactionHandler.BatchGetTransactions)
+			r.Post("/by-source/batch", deps.TransactionHandler.BatchGetTransactionsBySourceID)
+			r.Post("/quarantine/release", deps.TransactionHandler.ReleaseQuarantinedTransactions)
+			r.With(validateIDParam).Get("/{id}/balances", deps.TransactionHandler.GetTransactionBalances)
+			if deps.StatsHandler != nil {
+				r.With(validateStatsParams).Get("/stats", deps.StatsHandler.GetTransactionStats)
+			}
 		})
 
 		r.Route("/transaction", func(r chi.Router) {
-			r.Get("/{id}", deps.TransactionHandler.GetTransactionByID)
+			r.Post("/validate", deps.TransactionHandler.ValidateTransaction)
+			r.With(validateIDParam).Get("/{id}", deps.TransactionHandler.GetTransactionByID)
+			r.With(validateIDParam).Get("/{id}/history", deps.TransactionHandler.GetTransactionHistory)
+			r.With(validateIDParam).Get("/{id}/impact", deps.TransactionHandler.GetTransactionBalanceImpact)
 		})
 
 		// Balance endpoints
 		r.Route("/balances", func(r chi.Router) {
-			r.Get("/", deps.BalanceHandler.GetBalances)
+			r.With(validateBalanceListParams).Get("/", deps.BalanceHandler.GetBalances)
+			r.With(validateBalanceListParams).Get("/count", deps.BalanceHandler.CountBalances)
+			r.Get("/schema", deps.BalanceHandler.GetBalanceQuerySchema)
+			r.With(validateZeroBalanceParams).Get("/zero", deps.BalanceHandler.GetZeroBalances)
+			r.Post("/adjustments", deps.BalanceHandler.AdjustBalance)
+			if deps.StatsHandler != nil {
+				r.With(validateStatsParams).Get("/stats", deps.StatsHandler.GetBalanceStats)
+			}
 		})
 
 		r.Route("/balance", func(r chi.Router) {
-			r.Get("/{id}", deps.BalanceHandler.GetBalanceByID)
+			r.With(validateIDParam).Get("/{id}", deps.BalanceHandler.GetBalanceByID)
+			r.With(validateIDParam).Put("/{id}", deps.BalanceHandler.UpdateBalance)
+		})
+
+		// Position endpoints
+		r.Route("/positions", func(r chi.Router) {
+			r.With(validateTopPositionsParams).Get("/top", deps.BalanceHandler.GetTopPositions)
 		})
 
 		// Portfolio endpoints
 		r.Route("/portfolios", func(r chi.Router) {
-			r.Get("/{portfolioId}/summary", deps.BalanceHandler.GetPortfolioSummary)
+			r.With(validateSummaryParams).Get("/summaries", deps.BalanceHandler.GetPortfolioSummaries)
+			r.Get("/latest-transactions", deps.TransactionHandler.GetLatestTransactions)
+			r.With(validateSummaryParams).Get("/{portfolioId}/summary", deps.BalanceHandler.GetPortfolioSummary)
+			r.Get("/{portfolioId}/exposure", deps.BalanceHandler.GetPortfolioExposure)
+			r.Get("/{portfolioId}/balances", deps.BalanceHandler.GetPortfolioBalances)
+			r.Get("/{portfolioId}/securities/{securityId}/balance", deps.BalanceHandler.GetPortfolioSecurityBalance)
+			r.Get("/{portfolioId}/cash/balance", deps.BalanceHandler.GetPortfolioCashBalance)
+			r.With(validateAsOfParams).Get("/{portfolioId}/balances/as-of", deps.TransactionHandler.GetPortfolioBalancesAsOf)
+			r.With(validateLedgerParams).Get("/{portfolioId}/ledger", deps.TransactionHandler.GetPortfolioLedger)
+			r.Post("/{portfolioId}/recompute", deps.TransactionHandler.RecomputePortfolioBalances)
+		})
+
+		// Statistics across transactions and balances
+		if deps.StatsHandler != nil {
+			r.With(validateStatsParams).Get("/stats", deps.StatsHandler.GetStats)
+		}
+
+		// Admin endpoints
+		r.Route("/admin", func(r chi.Router) {
+			r.Get("/consistency-check", deps.TransactionHandler.CheckPortfolioConsistency)
+			if deps.FlagsHandler != nil {
+				r.Get("/flags", deps.FlagsHandler.GetFlags)
+			}
+			if deps.RetentionHandler != nil {
+				r.With(validateRetentionParams).Get("/retention/transactions", deps.RetentionHandler.CountExpiredTransactions)
+				r.With(validateRetentionParams).Delete("/retention/transactions", deps.RetentionHandler.DeleteExpiredTransactions)
+			}
 		})
+
+		// File processing endpoints
+		if deps.FileHandler != nil {
+			r.Route("/files", func(r chi.Router) {
+				r.Post("/{filename}/process", deps.FileHandler.StartFileProcessing)
+				r.Get("/{filename}/progress", deps.FileHandler.StreamFileProgress)
+			})
+		}
 	})
 
 	// API v2 routes (placeholder for future versions)
@@ -189,21 +300,71 @@ func SetupV1Router(deps RouterDependencies) chi.Router {
 
 	// Minimal middleware for testing
 	r.Use(middleware.RequestID)
-	r.Use(middleware.Recoverer)
+	r.Use(apiMiddleware.RequestIDMiddleware())
+	r.Use(apiMiddleware.NewRecoveryMiddleware(deps.Logger).Handler())
 
 	// Only v1 API routes
 	r.Route("/api/v1", func(r chi.Router) {
 		// Transaction endpoints
-		r.Get("/transactions", deps.TransactionHandler.GetTransactions)
-		r.Post("/transactions", deps.TransactionHandler.CreateTransactions)
-		r.Get("/transaction/{id}", deps.TransactionHandler.GetTransactionByID)
+		r.With(validateTransactionListParams).Get("/transactions", deps.TransactionHandler.GetTransactions)
+		r.With(validateTransactionListParams).Post("/transactions", deps.TransactionHandler.CreateTransactions)
+		r.With(validateTransactionListParams).Get("/transactions/count", deps.TransactionHandler.CountTransactions)
+		r.Get("/transactions/schema", deps.TransactionHandler.GetTransactionQuerySchema)
+		r.Post("/transactions/batch-get", deps.TransactionHandler.BatchGetTransactions)
+		r.Post("/transactions/by-source/batch", deps.TransactionHandler.BatchGetTransactionsBySourceID)
+		r.Post("/transactions/quarantine/release", deps.TransactionHandler.ReleaseQuarantinedTransactions)
+		r.With(validateIDParam).Get("/transactions/{id}/balances", deps.TransactionHandler.GetTransactionBalances)
+		r.Post("/transaction/validate", deps.TransactionHandler.ValidateTransaction)
+		r.With(validateIDParam).Get("/transaction/{id}", deps.TransactionHandler.GetTransactionByID)
+		r.With(validateIDParam).Get("/transaction/{id}/history", deps.TransactionHandler.GetTransactionHistory)
+		r.With(validateIDParam).Get("/transaction/{id}/impact", deps.TransactionHandler.GetTransactionBalanceImpact)
 
 		// Balance endpoints
-		r.Get("/balances", deps.BalanceHandler.GetBalances)
-		r.Get("/balance/{id}", deps.BalanceHandler.GetBalanceByID)
+		r.With(validateBalanceListParams).Get("/balances", deps.BalanceHandler.GetBalances)
+		r.With(validateBalanceListParams).Get("/balances/count", deps.BalanceHandler.CountBalances)
+		r.Get("/balances/schema", deps.BalanceHandler.GetBalanceQuerySchema)
+		r.With(validateZeroBalanceParams).Get("/balances/zero", deps.BalanceHandler.GetZeroBalances)
+		r.Post("/balances/adjustments", deps.BalanceHandler.AdjustBalance)
+		r.With(validateIDParam).Get("/balance/{id}", deps.BalanceHandler.GetBalanceByID)
+		r.With(validateIDParam).Put("/balance/{id}", deps.BalanceHandler.UpdateBalance)
+
+		// Statistics endpoints
+		if deps.StatsHandler != nil {
+			r.With(validateStatsParams).Get("/transactions/stats", deps.StatsHandler.GetTransactionStats)
+			r.With(validateStatsParams).Get("/balances/stats", deps.StatsHandler.GetBalanceStats)
+			r.With(validateStatsParams).Get("/stats", deps.StatsHandler.GetStats)
+		}
+
+		// Position endpoints
+		r.With(validateTopPositionsParams).Get("/positions/top", deps.BalanceHandler.GetTopPositions)
 
 		// Portfolio endpoints
-		r.Get("/portfolios/{portfolioId}/summary", deps.BalanceHandler.GetPortfolioSummary)
+		r.With(validateSummaryParams).Get("/portfolios/summaries", deps.BalanceHandler.GetPortfolioSummaries)
+		r.Get("/portfolios/latest-transactions", deps.TransactionHandler.GetLatestTransactions)
+		r.With(validateSummaryParams).Get("/portfolios/{portfolioId}/summary", deps.BalanceHandler.GetPortfolioSummary)
+		r.Get("/portfolios/{portfolioId}/exposure", deps.BalanceHandler.GetPortfolioExposure)
+		r.Get("/portfolios/{portfolioId}/balances", deps.BalanceHandler.GetPortfolioBalances)
+		r.Get("/portfolios/{portfolioId}/securities/{securityId}/balance", deps.BalanceHandler.GetPortfolioSecurityBalance)
+		r.Get("/portfolios/{portfolioId}/cash/balance", deps.BalanceHandler.GetPortfolioCashBalance)
+		r.With(validateAsOfParams).Get("/portfolios/{portfolioId}/balances/as-of", deps.TransactionHandler.GetPortfolioBalancesAsOf)
+		r.With(validateLedgerParams).Get("/portfolios/{portfolioId}/ledger", deps.TransactionHandler.GetPortfolioLedger)
+		r.Post("/portfolios/{portfolioId}/recompute", deps.TransactionHandler.RecomputePortfolioBalances)
+
+		// Admin endpoints
+		r.Get("/admin/consistency-check", deps.TransactionHandler.CheckPortfolioConsistency)
+		if deps.FlagsHandler != nil {
+			r.Get("/admin/flags", deps.FlagsHandler.GetFlags)
+		}
+		if deps.RetentionHandler != nil {
+			r.With(validateRetentionParams).Get("/admin/retention/transactions", deps.RetentionHandler.CountExpiredTransactions)
+			r.With(validateRetentionParams).Delete("/admin/retention/transactions", deps.RetentionHandler.DeleteExpiredTransactions)
+		}
+
+		// File processing endpoints
+		if deps.FileHandler != nil {
+			r.Post("/files/{filename}/process", deps.FileHandler.StartFileProcessing)
+			r.Get("/files/{filename}/progress", deps.FileHandler.StreamFileProgress)
+		}
 	})
 
 	return r
@@ -242,10 +403,43 @@ func GetAllRoutes() []Route {
 		// API v1 endpoints
 		{Method: "GET", Path: "/api/v1/transactions", Description: "Get transactions"},
 		{Method: "POST", Path: "/api/v1/transactions", Description: "Create transactions"},
+		{Method: "GET", Path: "/api/v1/transactions/count", Description: "Count transactions matching a filter"},
+		{Method: "GET", Path: "/api/v1/transactions/schema", Description: "List the supported transaction filter and sort fields"},
+		{Method: "POST", Path: "/api/v1/transactions/batch-get", Description: "Get transactions by IDs"},
+		{Method: "POST", Path: "/api/v1/transactions/by-source/batch", Description: "Get transactions by source IDs"},
+		{Method: "POST", Path: "/api/v1/transactions/quarantine/release", Description: "Process quarantined transactions whose references now exist"},
 		{Method: "GET", Path: "/api/v1/transaction/{id}", Description: "Get transaction by ID"},
+		{Method: "GET", Path: "/api/v1/transaction/{id}/history", Description: "Get transaction audit history"},
+		{Method: "GET", Path: "/api/v1/transaction/{id}/impact", Description: "Get transaction balance impact"},
+		{Method: "GET", Path: "/api/v1/transactions/{id}/balances", Description: "Get the current balances a transaction affects"},
+		{Method: "POST", Path: "/api/v1/transaction/validate", Description: "Validate a transaction without persisting it"},
 		{Method: "GET", Path: "/api/v1/balances", Description: "Get balances"},
+		{Method: "GET", Path: "/api/v1/balances/count", Description: "Count balances matching a filter"},
+		{Method: "GET", Path: "/api/v1/balances/schema", Description: "List the supported balance filter and sort fields"},
+		{Method: "GET", Path: "/api/v1/balances/zero", Description: "List zero balances that are candidates for compaction"},
+		{Method: "POST", Path: "/api/v1/balances/adjustments", Description: "Apply an idempotent balance adjustment"},
+		{Method: "GET", Path: "/api/v1/transactions/stats", Description: "Get transaction statistics (cached)"},
+		{Method: "GET", Path: "/api/v1/balances/stats", Description: "Get balance statistics for a filter (cached)"},
+		{Method: "GET", Path: "/api/v1/stats", Description: "Get transaction and balance statistics together (cached)"},
 		{Method: "GET", Path: "/api/v1/balance/{id}", Description: "Get balance by ID"},
+		{Method: "PUT", Path: "/api/v1/balance/{id}", Description: "Update balance quantities conditionally on its version (If-Match)"},
+		{Method: "GET", Path: "/api/v1/positions/top", Description: "Get the largest positions across all portfolios"},
+		{Method: "GET", Path: "/api/v1/portfolios/summaries", Description: "Get summaries of several portfolios"},
+		{Method: "GET", Path: "/api/v1/portfolios/latest-transactions", Description: "Get the latest transaction of several portfolios"},
 		{Method: "GET", Path: "/api/v1/portfolios/{portfolioId}/summary", Description: "Get portfolio summary"},
+		{Method: "GET", Path: "/api/v1/portfolios/{portfolioId}/exposure", Description: "Get portfolio long/short exposure"},
+		{Method: "GET", Path: "/api/v1/portfolios/{portfolioId}/balances", Description: "Get portfolio balances in a set of securities"},
+		{Method: "GET", Path: "/api/v1/portfolios/{portfolioId}/securities/{securityId}/balance", Description: "Get portfolio balance in one security"},
+		{Method: "GET", Path: "/api/v1/portfolios/{portfolioId}/cash/balance", Description: "Get portfolio cash balance"},
+		{Method: "GET", Path: "/api/v1/portfolios/{portfolioId}/balances/as-of", Description: "Get portfolio balances as of a date"},
+		{Method: "GET", Path: "/api/v1/portfolios/{portfolioId}/ledger", Description: "Get a window of the portfolio ledger with running balances"},
+		{Method: "POST", Path: "/api/v1/portfolios/{portfolioId}/recompute", Description: "Recompute portfolio balances in chronological order"},
+		{Method: "GET", Path: "/api/v1/admin/consistency-check", Description: "Report balances that drifted from processed transactions"},
+		{Method: "GET", Path: "/api/v1/admin/flags", Description: "Get the feature flags the service is running with"},
+		{Method: "GET", Path: "/api/v1/admin/retention/transactions", Description: "Count transactions past the retention cutoff (dry run)"},
+		{Method: "DELETE", Path: "/api/v1/admin/retention/transactions", Description: "Delete transactions past the retention cutoff"},
+		{Method: "POST", Path: "/api/v1/files/{filename}/process", Description: "Start processing a transaction file in the background"},
+		{Method: "GET", Path: "/api/v1/files/{filename}/progress", Description: "Stream file processing progress as server-sent events"},
 
 		// API v2 placeholder
 		{Method: "GET", Path: "/api/v2/", Description: "API v2 placeholder (not implemented)"},
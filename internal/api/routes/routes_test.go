@@ -1,10 +1,15 @@
 package routes
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/api/handlers"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/api/middleware"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -121,4 +126,64 @@ func TestConfig_EnhancedMetricsField(t *testing.T) {
 	assert.True(t, config.EnableMetrics)
 	assert.True(t, config.EnableEnhancedMetrics)
 	assert.False(t, config.EnableCORS)
-}
\ No newline at end of file
+}
+
+func TestQuerySchema_MatchesValidationAllowlist(t *testing.T) {
+	testLogger := logger.NewNoop()
+	router := SetupV1Router(RouterDependencies{
+		TransactionHandler: handlers.NewTransactionHandler(nil, testLogger),
+		BalanceHandler:     handlers.NewBalanceHandler(nil, testLogger),
+		Logger:             testLogger,
+	})
+
+	tests := []struct {
+		name      string
+		path      string
+		schema    dto.QuerySchemaDTO
+		validator func(http.Handler) http.Handler
+	}{
+		{"Transactions", "/api/v1/transactions/schema", dto.TransactionQuerySchema(), validateTransactionListParams},
+		{"Balances", "/api/v1/balances/schema", dto.BalanceQuerySchema(), validateBalanceListParams},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var served dto.QuerySchemaDTO
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &served))
+			assert.Equal(t, tt.schema, served)
+
+			validate := func(query url.Values) int {
+				handler := tt.validator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusNoContent)
+				}))
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?"+query.Encode(), nil))
+				return rec.Code
+			}
+
+			for _, field := range served.SortFields {
+				assert.Equal(t, http.StatusNoContent, validate(url.Values{"sortby": {field}}), "sort field %s", field)
+			}
+			assert.Equal(t, http.StatusBadRequest, validate(url.Values{"sortby": {"not_a_field"}}))
+			assert.Equal(t, http.StatusBadRequest, validate(url.Values{"sortby": {served.SortFields[0] + ",not_a_field"}}))
+
+			for _, field := range served.FilterFields {
+				switch field.Type {
+				case dto.QueryFieldTypeDate:
+					assert.Equal(t, http.StatusNoContent, validate(url.Values{field.Name: {"2024-01-02"}}), "filter field %s", field.Name)
+				case dto.QueryFieldTypeBoolean:
+					assert.Equal(t, http.StatusNoContent, validate(url.Values{field.Name: {"true"}}), "filter field %s", field.Name)
+				case dto.QueryFieldTypeDecimal:
+					assert.Equal(t, http.StatusNoContent, validate(url.Values{field.Name: {"1000.50"}}), "filter field %s", field.Name)
+				default:
+					continue
+				}
+				assert.Equal(t, http.StatusBadRequest, validate(url.Values{field.Name: {"invalid"}}), "filter field %s", field.Name)
+			}
+		})
+	}
+}
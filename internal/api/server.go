@@ -2,19 +2,26 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/api/handlers"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/api/middleware"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/api/routes"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/mappers"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/services"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/config"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
 	domainServices "github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/services"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/cache"
@@ -23,14 +30,56 @@ import (
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/external"
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
 	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
+// buildVersionOverride is the service version set at link time with
+// -ldflags "-X github.com/kasbench/globeco-portfolio-accounting-service/internal/api.buildVersionOverride=<version>"
+var buildVersionOverride string
+
+// serviceVersion is the version the health endpoints report
+var serviceVersion = buildVersion(buildVersionOverride, debug.ReadBuildInfo)
+
+// ServiceVersion returns the version of the running binary, as the health endpoints report it
+func ServiceVersion() string {
+	return serviceVersion
+}
+
+// buildVersion returns the version of the running binary: the link-time override, else the
+// module version, else the VCS revision recorded by the Go toolchain, else "unknown"
+func buildVersion(override string, readBuildInfo func() (*debug.BuildInfo, bool)) string {
+	if override != "" {
+		return override
+	}
+
+	info, ok := readBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && setting.Value != "" {
+			if len(setting.Value) > 12 {
+				return setting.Value[:12]
+			}
+			return setting.Value
+		}
+	}
+	return "unknown"
+}
+
 // Server represents the HTTP server with basic configuration
 type Server struct {
 	httpServer *http.Server
 	config     *config.Config
 	logger     logger.Logger
 
+	// redirectServer sends plain HTTP requests to HTTPS; nil unless configured
+	redirectServer *http.Server
+
 	// Database
 	db *database.DB
 
@@ -42,8 +91,9 @@ type Server struct {
 	securityClient  external.SecurityClient
 
 	// Repositories
-	transactionRepo repositories.TransactionRepository
-	balanceRepo     repositories.BalanceRepository
+	transactionRepo       repositories.TransactionRepository
+	balanceRepo           repositories.BalanceRepository
+	balanceAdjustmentRepo repositories.BalanceAdjustmentRepository
 
 	// Domain services
 	transactionValidator *domainServices.TransactionValidator
@@ -53,12 +103,24 @@ type Server struct {
 	// Application services
 	transactionService services.TransactionService
 	balanceService     services.BalanceService
+	fileService        services.FileProcessorService
+	retentionService   services.TransactionRetentionService
+
+	// Background jobs
+	transactionReprocessor services.TransactionReprocessor
+	zeroBalanceCompactor   services.ZeroBalanceCompactor
+	notionalBackfill       services.NotionalAmountBackfill
+	adjustmentKeyExpirer   services.AdjustmentKeyExpirer
 
 	// Handler dependencies
 	transactionHandler *handlers.TransactionHandler
 	balanceHandler     *handlers.BalanceHandler
 	healthHandler      *handlers.HealthHandler
 	swaggerHandler     *handlers.SwaggerHandler
+	fileHandler        *handlers.FileHandler
+	flagsHandler       *handlers.FlagsHandler
+	retentionHandler   *handlers.RetentionHandler
+	statsHandler       *handlers.StatsHandler
 }
 
 // NewServer creates a new server instance with external service clients
@@ -68,6 +130,10 @@ func NewServer(cfg *config.Config, lg logger.Logger) (*Server, error) {
 		logger: lg,
 	}
 
+	if err := dto.SetDecimalEncoding(cfg.Server.DecimalEncoding); err != nil {
+		return nil, err
+	}
+
 	if err := server.initializeDatabase(); err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
@@ -131,12 +197,15 @@ func (s *Server) initializeCache() error {
 
 	// Create cache configuration from main config
 	cacheConfig := cache.Config{
-		Type:          cache.CacheTypeRedis,
-		Enabled:       s.config.Cache.Enabled,
-		KeyPrefix:     "portfolio-accounting",
-		DefaultTTL:    s.config.Cache.TTL,
-		EnableMetrics: true,
-		EnableLogging: true,
+		Type:             cache.CacheTypeRedis,
+		Enabled:          s.config.Flags().Caching,
+		KeyPrefix:        "portfolio-accounting",
+		DefaultTTL:       s.config.Cache.TTL,
+		OperationTimeout: s.config.Cache.OperationTimeout,
+		OperationRetries: s.config.Cache.OperationRetries,
+		RetryBackoff:     s.config.Cache.RetryBackoff,
+		EnableMetrics:    true,
+		EnableLogging:    true,
 		Redis: cache.RedisConfig{
 			Address:      s.config.Cache.Address,
 			Password:     s.config.Cache.Password,
@@ -177,6 +246,7 @@ func (s *Server) initializeExternalClients() error {
 		ClientConfig: external.ClientConfig{
 			BaseURL:             fmt.Sprintf("http://%s:%d", s.config.External.PortfolioService.Host, s.config.External.PortfolioService.Port),
 			Timeout:             s.config.External.PortfolioService.Timeout,
+			CallTimeout:         s.config.External.PortfolioService.CallTimeout,
 			MaxIdleConnections:  100,
 			MaxIdleConnsPerHost: 10,
 			IdleConnTimeout:     90 * time.Second,
@@ -205,6 +275,7 @@ func (s *Server) initializeExternalClients() error {
 		ClientConfig: external.ClientConfig{
 			BaseURL:             fmt.Sprintf("http://%s:%d", s.config.External.SecurityService.Host, s.config.External.SecurityService.Port),
 			Timeout:             s.config.External.SecurityService.Timeout,
+			CallTimeout:         s.config.External.SecurityService.CallTimeout,
 			MaxIdleConnections:  100,
 			MaxIdleConnsPerHost: 10,
 			IdleConnTimeout:     90 * time.Second,
@@ -246,10 +317,21 @@ func (s *Server) initializeRepositories() error {
 	s.logger.Info("Initializing repositories")
 
 	// Initialize transaction repository
-	s.transactionRepo = postgresql.NewTransactionRepository(s.db, s.logger)
+	s.transactionRepo = postgresql.NewTransactionRepository(s.db, s.logger).
+		WithMaxListFilterSize(s.config.Database.MaxListFilterSize)
 
 	// Initialize balance repository
-	s.balanceRepo = postgresql.NewBalanceRepository(s.db, s.logger)
+	cashSecurityID, err := repositories.NewCashSecurityID(s.config.Database.CashSecurityID)
+	if err != nil {
+		return fmt.Errorf("invalid cash security ID configuration: %w", err)
+	}
+	balanceRepo := postgresql.NewBalanceRepository(s.db, s.logger).
+		WithCashSecurityID(cashSecurityID).
+		WithMaxListFilterSize(s.config.Database.MaxListFilterSize)
+	s.balanceRepo = balanceRepo
+
+	// Initialize balance adjustment ledger
+	s.balanceAdjustmentRepo = postgresql.NewBalanceAdjustmentRepository(s.db, balanceRepo, s.logger)
 
 	s.logger.Info("Repositories initialized")
 	return nil
@@ -260,10 +342,25 @@ func (s *Server) initializeDomainServices() error {
 	s.logger.Info("Initializing domain services")
 
 	// Initialize transaction validator
-	s.transactionValidator = domainServices.NewTransactionValidator(s.transactionRepo, s.balanceRepo, s.logger)
+	sourceIDPattern, err := s.config.Validation.SourceIDRegexp()
+	if err != nil {
+		return fmt.Errorf("invalid source ID pattern: %w", err)
+	}
+	s.transactionValidator = domainServices.NewTransactionValidator(s.transactionRepo, s.balanceRepo, s.logger).
+		WithMaxFutureDays(s.config.Validation.MaxFutureDays).
+		WithSourceIDPattern(sourceIDPattern, s.config.Validation.SourceIDPattern).
+		WithDailyTransactionLimit(s.config.Validation.DailyTransactionLimit, s.config.Validation.DailyTransactionLimitOverrides)
+	if policy := domainServices.UnknownReferencePolicy(s.config.Validation.UnknownReferencePolicy); policy != "" && policy != domainServices.UnknownReferenceOff {
+		checker := external.NewReferenceChecker(s.portfolioClient, s.securityClient).
+			WithLookupTimeout(s.config.Validation.ReferenceLookupTimeout)
+		s.transactionValidator.WithUnknownReferencePolicy(checker, policy)
+	}
 
 	// Initialize balance calculator
 	s.balanceCalculator = domainServices.NewBalanceCalculator(s.balanceRepo, s.logger)
+	if s.config.Balances.DateBasis != "" {
+		s.balanceCalculator.WithDateBasis(models.DateBasis(s.config.Balances.DateBasis))
+	}
 
 	// Initialize transaction processor
 	s.transactionProcessor = domainServices.NewTransactionProcessor(
@@ -273,6 +370,13 @@ func (s *Server) initializeDomainServices() error {
 		s.balanceCalculator,
 		s.logger,
 	)
+	if s.config.Logging.BalanceChanges != "" {
+		s.transactionProcessor.WithBalanceChangeLogLevel(domainServices.BalanceChangeLogLevel(s.config.Logging.BalanceChanges))
+	}
+	s.transactionProcessor.WithForcedReprocessing(s.config.Reprocessing.AllowForced)
+	s.transactionProcessor.WithNotionalAmounts(s.config.Transactions.StoreNotionalAmount)
+	s.transactionProcessor.WithBalanceAdjustments(s.balanceAdjustmentRepo)
+	s.transactionProcessor.WithUnitOfWork(postgresql.NewUnitOfWork(s.db))
 
 	s.logger.Info("Domain services initialized")
 	return nil
@@ -283,14 +387,20 @@ func (s *Server) initializeApplicationServices() error {
 	s.logger.Info("Initializing application services")
 
 	// Initialize mappers
-	transactionMapper := mappers.NewTransactionMapper()
+	transactionMapper := mappers.NewTransactionMapper().
+		WithTransactionTypeAliases(s.config.Validation.TransactionTypeAliases).
+		WithCashPriceAutoFill(s.config.Validation.CashPriceAutoFill)
 	balanceMapper := mappers.NewBalanceMapper()
 
 	// Initialize transaction service
 	transactionServiceConfig := services.TransactionServiceConfig{
-		MaxBatchSize:          1000,
-		ProcessingTimeout:     30 * time.Second,
-		EnableAsyncProcessing: false,
+		MaxBatchSize:               1000,
+		ProcessingTimeout:          s.config.Transactions.ProcessingTimeout,
+		MaxBatchGetIDs:             s.config.Transactions.MaxBatchGetIDs,
+		ValidationConcurrency:      s.config.Transactions.ValidationConcurrency,
+		MaxLedgerWindowDays:        s.config.Balances.LedgerMaxWindowDays,
+		AbortOnInfrastructureError: s.config.Transactions.AbortOnInfrastructureError,
+		UnitOfWork:                 postgresql.NewUnitOfWork(s.db),
 	}
 
 	s.transactionService = services.NewTransactionService(
@@ -305,20 +415,112 @@ func (s *Server) initializeApplicationServices() error {
 
 	// Initialize balance service
 	balanceServiceConfig := services.BalanceServiceConfig{
-		MaxBulkUpdateSize:    1000,
-		CacheTimeout:         15 * time.Minute,
-		HistoryRetentionDays: 90,
+		MaxBulkUpdateSize:        1000,
+		CacheTimeout:             15 * time.Minute,
+		HistoryRetentionDays:     90,
+		DefaultSummarySecurities: s.config.Balances.DefaultSummarySecurities,
+		MaxSummarySecurities:     s.config.Balances.MaxSummarySecurities,
+		MaxSummaryPortfolios:     s.config.Balances.MaxSummaryPortfolios,
+		EmptySummaryNotFound:     s.config.Balances.EmptySummaryNotFound,
+		MissingBalanceNotFound:   s.config.Balances.MissingBalanceNotFound,
+		RequireAdjustmentReason:  s.config.Balances.RequireAdjustmentReason,
+		AdjustmentKeyMaxAge:      s.config.Balances.AdjustmentKeyMaxAge,
 	}
 
 	s.balanceService = services.NewBalanceService(
 		s.balanceRepo,
 		s.transactionRepo,
+		s.balanceAdjustmentRepo,
 		*s.balanceCalculator,
 		balanceMapper,
 		balanceServiceConfig,
 		s.logger,
 	)
 
+	// Initialize file processor service
+	s.fileService = services.NewFileProcessorService(
+		s.transactionService,
+		services.FileProcessorConfig{
+			WorkingDirectory:   s.config.FileProcessing.WorkingDirectory,
+			ErrorFileDirectory: s.config.FileProcessing.ErrorDirectory,
+			MaxFileSize:        s.config.FileProcessing.MaxFileSize,
+
+			MaxProcessingDuration: s.config.FileProcessing.MaxProcessingDuration,
+			ProgressDirectory:     s.config.FileProcessing.ProgressDirectory,
+			DecimalFormat: services.DecimalFormat{
+				DecimalSeparator:   s.config.FileProcessing.DecimalSeparator,
+				ThousandsSeparator: s.config.FileProcessing.ThousandsSeparator,
+			},
+			ErrorFileNaming:    services.ErrorFileNaming(s.config.FileProcessing.ErrorFileNaming),
+			SlowBatchThreshold: s.config.FileProcessing.SlowBatchThreshold,
+			StrictFieldCount:   s.config.Flags().StrictCSV,
+		},
+		s.logger,
+	)
+
+	// Initialize transaction retention, only exposed when enabled
+	if s.config.Flags().Retention {
+		s.retentionService = services.NewTransactionRetentionService(
+			s.transactionRepo,
+			services.TransactionRetentionConfig{
+				MinimumAge: time.Duration(s.config.Retention.MinimumAgeDays) * 24 * time.Hour,
+				BatchSize:  s.config.Retention.BatchSize,
+			},
+			s.logger,
+		)
+	}
+
+	// Initialize background reprocessor for transiently failed transactions
+	if s.config.Flags().Reprocessing {
+		s.transactionReprocessor = services.NewTransactionReprocessor(
+			s.transactionRepo,
+			s.transactionProcessor,
+			services.TransactionReprocessorConfig{
+				Interval:       s.config.Reprocessing.Interval,
+				BatchSize:      s.config.Reprocessing.BatchSize,
+				MaxAttempts:    s.config.Reprocessing.MaxAttempts,
+				InitialBackoff: s.config.Reprocessing.InitialBackoff,
+				MaxBackoff:     s.config.Reprocessing.MaxBackoff,
+			},
+			s.logger,
+		)
+	}
+
+	// Initialize background compaction of long-standing zero balances
+	if s.config.Flags().Compaction {
+		s.zeroBalanceCompactor = services.NewZeroBalanceCompactor(
+			s.balanceRepo,
+			services.ZeroBalanceCompactorConfig{
+				Interval:         s.config.Compaction.Interval,
+				MinimumAge:       s.config.Compaction.MinimumAge,
+				BatchSize:        s.config.Compaction.BatchSize,
+				MaxBatchesPerRun: s.config.Compaction.MaxBatchesPerRun,
+			},
+			s.logger,
+		)
+	}
+
+	// Initialize the release of balance adjustment keys past their maximum age
+	if s.config.Balances.AdjustmentKeyMaxAge > 0 {
+		s.adjustmentKeyExpirer = services.NewAdjustmentKeyExpirer(
+			s.balanceAdjustmentRepo,
+			services.AdjustmentKeyExpirerConfig{
+				Interval: s.config.Balances.AdjustmentKeyCleanupInterval,
+				MaxAge:   s.config.Balances.AdjustmentKeyMaxAge,
+			},
+			s.logger,
+		)
+	}
+
+	// Initialize the backfill of notional amounts on transactions processed before they were stored
+	if s.config.Transactions.StoreNotionalAmount {
+		s.notionalBackfill = services.NewNotionalAmountBackfill(
+			s.transactionRepo,
+			services.NotionalAmountBackfillConfig{BatchSize: s.config.Transactions.NotionalBackfillBatchSize},
+			s.logger,
+		)
+	}
+
 	s.logger.Info("Application services initialized")
 	return nil
 }
@@ -334,31 +536,53 @@ func (s *Server) initializeHandlers() error {
 		s.portfolioClient,
 		s.securityClient,
 		s.logger,
-		"1.0.0",       // version
-		"development", // environment
-	)
+		serviceVersion,
+		s.config.App.Environment,
+	).WithCheckCacheTTL(s.config.Server.HealthCheckCacheTTL).
+		WithCheckTimeout(s.config.Server.HealthCheckTimeout).
+		WithFileProcessor(s.fileService)
+	if s.db != nil {
+		s.healthHandler.WithWriteTransactionStats(s.db.WriteTransactionStats)
+	}
 	s.swaggerHandler = handlers.NewSwaggerHandler(s.logger)
+	s.fileHandler = handlers.NewFileHandler(s.fileService, s.logger)
+	s.flagsHandler = handlers.NewFlagsHandler(s.config.Flags(), s.logger)
+	if s.retentionService != nil {
+		s.retentionHandler = handlers.NewRetentionHandler(s.retentionService, s.logger)
+	}
+
+	// Stats responses are cached only when the cache is enabled and given a TTL
+	var statsCache *cache.ResponseCache
+	if s.cacheManager != nil && s.cacheManager.IsEnabled() && s.config.Cache.StatsTTL > 0 {
+		statsCache = s.cacheManager.ResponseCache(s.config.Cache.StatsTTL)
+	}
+	s.statsHandler = handlers.NewStatsHandler(s.transactionService, s.balanceService, statsCache, s.logger)
 
 	s.logger.Info("HTTP handlers initialized")
 	return nil
 }
 
+// newRouterConfig creates the router configuration for the given feature flags
+func newRouterConfig(flags config.FeatureFlags) routes.Config {
+	return routes.Config{
+		ServiceName:           "globeco-portfolio-accounting-service",
+		Version:               "1.0.0",
+		CORSConfig:            middleware.DefaultCORSConfig(),
+		EnableMetrics:         flags.Metrics,
+		EnableEnhancedMetrics: flags.EnhancedMetrics,
+		EnableExemplars:       flags.Exemplars,
+		EnableCORS:            true,
+	}
+}
+
 // setupHTTPServer configures the HTTP server
 func (s *Server) setupHTTPServer() error {
 	s.logger.Info("Setting up HTTP server")
 
-	// Configure CORS
-	corsConfig := middleware.DefaultCORSConfig()
-
 	// Setup router configuration
-	routerConfig := routes.Config{
-		ServiceName:           "globeco-portfolio-accounting-service",
-		Version:               "1.0.0",
-		CORSConfig:            corsConfig,
-		EnableMetrics:         s.config.Metrics.Enabled,
-		EnableEnhancedMetrics: s.config.Metrics.Enhanced.Enabled,
-		EnableCORS:            true,
-	}
+	routerConfig := newRouterConfig(s.config.Flags())
+	routerConfig.ExemplarThreshold = s.config.Metrics.Enhanced.ExemplarThreshold
+	routerConfig.MaxInFlight = s.config.Server.MaxInFlight
 
 	// Setup router dependencies
 	routerDeps := routes.RouterDependencies{
@@ -366,6 +590,10 @@ func (s *Server) setupHTTPServer() error {
 		BalanceHandler:     s.balanceHandler,
 		HealthHandler:      s.healthHandler,
 		SwaggerHandler:     s.swaggerHandler,
+		FileHandler:        s.fileHandler,
+		FlagsHandler:       s.flagsHandler,
+		RetentionHandler:   s.retentionHandler,
+		StatsHandler:       s.statsHandler,
 		Logger:             s.logger,
 	}
 
@@ -373,32 +601,127 @@ func (s *Server) setupHTTPServer() error {
 	router := routes.SetupRouter(routerConfig, routerDeps)
 
 	// Create HTTP server
-	s.httpServer = &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", s.config.Server.Host, s.config.Server.Port),
-		Handler:      router,
-		ReadTimeout:  s.config.Server.ReadTimeout,
-		WriteTimeout: s.config.Server.WriteTimeout,
-		IdleTimeout:  s.config.Server.IdleTimeout,
+	s.httpServer = newHTTPServer(s.config.Server, router)
+	if s.config.Server.TLSEnabled() && s.config.Server.HTTPRedirectPort > 0 {
+		s.redirectServer = newHTTPSRedirectServer(s.config.Server)
 	}
 
 	s.logger.Info("HTTP server configured",
-		zap.String("address", s.httpServer.Addr))
+		zap.String("address", s.httpServer.Addr),
+		zap.Bool("tls", s.config.Server.TLSEnabled()),
+		zap.String("tls_min_version", s.config.Server.TLSMinVersion),
+		zap.Int("http_redirect_port", s.config.Server.HTTPRedirectPort),
+		zap.Bool("http2", s.config.Server.HTTP2.Enabled),
+		zap.Bool("keep_alives", s.config.Server.KeepAlivesEnabled))
 
 	return nil
 }
 
+// newHTTPServer builds the HTTP server with the configured timeouts, header limit and
+// keep-alive behaviour. With HTTP/2 enabled, TLS servers negotiate h2 via ALPN and
+// plaintext servers accept cleartext h2c, as used behind a TLS-terminating proxy.
+func newHTTPServer(cfg config.ServerConfig, handler http.Handler) *http.Server {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	srv.SetKeepAlivesEnabled(cfg.KeepAlivesEnabled)
+
+	if cfg.TLSEnabled() {
+		// The version was checked when the configuration was validated
+		minVersion, _ := cfg.MinTLSVersion()
+		srv.TLSConfig = &tls.Config{MinVersion: minVersion}
+	}
+
+	if !cfg.HTTP2.Enabled {
+		if cfg.TLSEnabled() {
+			// A non-nil, empty map stops net/http from enabling HTTP/2 over TLS on its own
+			srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+		return srv
+	}
+
+	h2 := &http2.Server{
+		MaxConcurrentStreams: cfg.HTTP2.MaxConcurrentStreams,
+		IdleTimeout:          cfg.IdleTimeout,
+	}
+	if cfg.TLSEnabled() {
+		// ConfigureServer only fails for incompatible cipher suites, which are never set here
+		_ = http2.ConfigureServer(srv, h2)
+	} else {
+		srv.Handler = h2c.NewHandler(handler, h2)
+	}
+
+	return srv
+}
+
+// newHTTPSRedirectServer answers plain HTTP on the redirect port with a permanent redirect to the
+// same host, path and query on the HTTPS port. It has the timeouts and header limit of the main
+// server, so idle or slow clients cannot hold its connections open either.
+func newHTTPSRedirectServer(cfg config.ServerConfig) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", cfg.Host, cfg.HTTPRedirectPort),
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if hostname, _, err := net.SplitHostPort(host); err == nil {
+				host = hostname
+			}
+			if cfg.Port != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(cfg.Port))
+			}
+			target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+			http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+		}),
+	}
+}
+
 // Start starts the HTTP server
 func (s *Server) Start(ctx context.Context) error {
 	s.logger.Info("Starting HTTP server",
 		zap.String("address", s.httpServer.Addr))
 
 	// Channel to listen for interrupt signal to gracefully shutdown the server
-	serverErrors := make(chan error, 1)
+	serverErrors := make(chan error, 2)
+
+	// Start background jobs
+	if s.transactionReprocessor != nil {
+		s.transactionReprocessor.Start(ctx)
+	}
+	if s.zeroBalanceCompactor != nil {
+		s.zeroBalanceCompactor.Start(ctx)
+	}
+	if s.notionalBackfill != nil {
+		s.notionalBackfill.Start(ctx)
+	}
+	if s.adjustmentKeyExpirer != nil {
+		s.adjustmentKeyExpirer.Start(ctx)
+	}
 
 	// Start server in a goroutine
 	go func() {
+		if s.config.Server.TLSEnabled() {
+			serverErrors <- s.httpServer.ListenAndServeTLS(s.config.Server.TLSCertFile, s.config.Server.TLSKeyFile)
+			return
+		}
 		serverErrors <- s.httpServer.ListenAndServe()
 	}()
+	if s.redirectServer != nil {
+		s.logger.Info("Redirecting plain HTTP to HTTPS",
+			zap.String("address", s.redirectServer.Addr))
+		go func() {
+			serverErrors <- s.redirectServer.ListenAndServe()
+		}()
+	}
 
 	// Channel to listen for interrupt signal to gracefully shutdown the server
 	shutdown := make(chan os.Signal, 1)
@@ -415,8 +738,8 @@ func (s *Server) Start(ctx context.Context) error {
 		s.logger.Info("Received shutdown signal",
 			zap.String("signal", sig.String()))
 
-		// Give outstanding requests 30 seconds to complete
-		shutdownCtx, cancel := context.WithTimeout(ctx, s.config.Server.GracefulShutdownTimeout)
+		// Give load balancers the drain period, then outstanding requests the shutdown timeout
+		shutdownCtx, cancel := context.WithTimeout(ctx, s.config.Server.ShutdownDrainPeriod+s.config.Server.GracefulShutdownTimeout)
 		defer cancel()
 
 		return s.Shutdown(shutdownCtx)
@@ -427,16 +750,50 @@ func (s *Server) Start(ctx context.Context) error {
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info("Initiating graceful shutdown")
 
+	// Fail readiness first, and keep serving for the drain period so load balancers deregister
+	// the instance before it stops accepting connections
+	if s.healthHandler != nil {
+		s.healthHandler.StartDraining()
+	}
+	if drain := s.config.Server.ShutdownDrainPeriod; drain > 0 {
+		s.logger.Info("Draining before shutdown", zap.Duration("drain_period", drain))
+		timer := time.NewTimer(drain)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
 	// Create shutdown context with timeout
 	shutdownCtx, cancel := context.WithTimeout(ctx, s.config.Server.GracefulShutdownTimeout)
 	defer cancel()
 
 	// Shutdown HTTP server
+	if s.redirectServer != nil {
+		if err := s.redirectServer.Shutdown(shutdownCtx); err != nil {
+			s.logger.Error("Failed to shutdown HTTP redirect server", zap.Error(err))
+		}
+	}
 	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
 		s.logger.Error("Failed to shutdown HTTP server gracefully", zap.Error(err))
 		return fmt.Errorf("failed to shutdown HTTP server: %w", err)
 	}
 
+	// Stop background jobs
+	if s.transactionReprocessor != nil {
+		s.transactionReprocessor.Stop()
+	}
+	if s.zeroBalanceCompactor != nil {
+		s.zeroBalanceCompactor.Stop()
+	}
+	if s.notionalBackfill != nil {
+		s.notionalBackfill.Stop()
+	}
+	if s.adjustmentKeyExpirer != nil {
+		s.adjustmentKeyExpirer.Stop()
+	}
+
 	// Close external service clients
 	if s.portfolioClient != nil {
 		if err := s.portfolioClient.Close(); err != nil {
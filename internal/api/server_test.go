@@ -0,0 +1,391 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/api/handlers"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/api/routes"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/config"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/external"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+func TestNewRouterConfig_MetricsFlag(t *testing.T) {
+	tests := []struct {
+		name         string
+		metrics      bool
+		expectedCode int
+	}{
+		{name: "Metrics on", metrics: true, expectedCode: http.StatusOK},
+		{name: "Metrics off", metrics: false, expectedCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := config.FeatureFlags{Metrics: tt.metrics, Caching: true}
+			router := routes.SetupRouter(newRouterConfig(flags), routes.RouterDependencies{
+				TransactionHandler: &handlers.TransactionHandler{},
+				BalanceHandler:     &handlers.BalanceHandler{},
+				HealthHandler:      &handlers.HealthHandler{},
+				SwaggerHandler:     &handlers.SwaggerHandler{},
+				FlagsHandler:       handlers.NewFlagsHandler(flags, logger.NewNoop()),
+				Logger:             logger.NewNoop(),
+				MetricsRegistry:    prometheus.NewRegistry(),
+			})
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			assert.Equal(t, tt.expectedCode, rec.Code)
+
+			// The flags endpoint reports the flags the router was built with
+			rec = httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/admin/flags", nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var reported config.FeatureFlags
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&reported))
+			assert.Equal(t, flags, reported)
+		})
+	}
+}
+
+func TestNewHTTPServer_Tuning(t *testing.T) {
+	srv := newHTTPServer(config.ServerConfig{
+		Host:              "127.0.0.1",
+		Port:              8087,
+		ReadTimeout:       30 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    64 << 10,
+	}, http.NotFoundHandler())
+
+	assert.Equal(t, "127.0.0.1:8087", srv.Addr)
+	assert.Equal(t, 5*time.Second, srv.ReadHeaderTimeout)
+	assert.Equal(t, 2*time.Minute, srv.IdleTimeout)
+	assert.Equal(t, 64<<10, srv.MaxHeaderBytes)
+}
+
+func TestNewHTTPServer_HTTP2Negotiation(t *testing.T) {
+	protoHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	})
+
+	serve := func(t *testing.T, http2Enabled bool) string {
+		srv := newHTTPServer(config.ServerConfig{
+			KeepAlivesEnabled: true,
+			IdleTimeout:       time.Minute,
+			HTTP2:             config.HTTP2Config{Enabled: http2Enabled, MaxConcurrentStreams: 10},
+		}, protoHandler)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go func() { _ = srv.Serve(listener) }()
+		t.Cleanup(func() { _ = srv.Close() })
+
+		return "http://" + listener.Addr().String()
+	}
+
+	// Prior-knowledge h2c client, as a proxy or high-concurrency client would use
+	h2cClient := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+	}
+
+	t.Run("HTTP/2 enabled", func(t *testing.T) {
+		resp, err := h2cClient.Get(serve(t, true))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, 2, resp.ProtoMajor)
+		assert.Equal(t, "HTTP/2.0", resp.Proto)
+	})
+
+	t.Run("HTTP/2 disabled", func(t *testing.T) {
+		baseURL := serve(t, false)
+
+		_, err := h2cClient.Get(baseURL)
+		assert.Error(t, err)
+
+		resp, err := (&http.Client{Timeout: 5 * time.Second}).Get(baseURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, 1, resp.ProtoMajor)
+	})
+}
+
+// writeSelfSignedCert writes a certificate and key for 127.0.0.1 and returns their files and a
+// pool trusting the certificate
+func writeSelfSignedCert(t *testing.T) (certFile, keyFile string, roots *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	roots = x509.NewCertPool()
+	roots.AddCert(cert)
+	return certFile, keyFile, roots
+}
+
+func TestNewHTTPServer_TLS(t *testing.T) {
+	certFile, keyFile, roots := writeSelfSignedCert(t)
+	srv := newHTTPServer(config.ServerConfig{
+		KeepAlivesEnabled: true,
+		TLSCertFile:       certFile,
+		TLSKeyFile:        keyFile,
+		TLSMinVersion:     "1.3",
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.ServeTLS(listener, certFile, keyFile) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	addr := listener.Addr().String()
+
+	client := func(maxVersion uint16) *http.Client {
+		return &http.Client{
+			Timeout:   5 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MaxVersion: maxVersion}},
+		}
+	}
+
+	t.Run("HTTPS request succeeds", func(t *testing.T) {
+		resp, err := client(0).Get("https://" + addr + "/health")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NotNil(t, resp.TLS)
+		assert.Equal(t, uint16(tls.VersionTLS13), resp.TLS.Version)
+	})
+
+	t.Run("Older TLS than the minimum is refused", func(t *testing.T) {
+		_, err := client(tls.VersionTLS12).Get("https://" + addr + "/health")
+		assert.Error(t, err)
+	})
+
+	t.Run("Plain HTTP is refused", func(t *testing.T) {
+		resp, err := (&http.Client{Timeout: 5 * time.Second}).Get("http://" + addr + "/health")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestNewHTTPSRedirectServer(t *testing.T) {
+	tests := []struct {
+		name     string
+		port     int
+		host     string
+		expected string
+	}{
+		{name: "Custom HTTPS port", port: 8443, host: "accounting.example.com:8080", expected: "https://accounting.example.com:8443/api/v1/balances?limit=5"},
+		{name: "Default HTTPS port", port: 443, host: "accounting.example.com", expected: "https://accounting.example.com/api/v1/balances?limit=5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newHTTPSRedirectServer(config.ServerConfig{Host: "0.0.0.0", Port: tt.port, HTTPRedirectPort: 8080})
+			assert.Equal(t, "0.0.0.0:8080", srv.Addr)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/balances?limit=5", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+			assert.Equal(t, tt.expected, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestNewHTTPSRedirectServer_Timeouts(t *testing.T) {
+	srv := newHTTPSRedirectServer(config.ServerConfig{
+		Port:              8443,
+		HTTPRedirectPort:  8080,
+		ReadTimeout:       30 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      20 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    64 << 10,
+	})
+
+	assert.Equal(t, 30*time.Second, srv.ReadTimeout)
+	assert.Equal(t, 5*time.Second, srv.ReadHeaderTimeout)
+	assert.Equal(t, 20*time.Second, srv.WriteTimeout)
+	assert.Equal(t, 2*time.Minute, srv.IdleTimeout)
+	assert.Equal(t, 64<<10, srv.MaxHeaderBytes)
+}
+
+// healthyPortfolioClient and healthySecurityClient always pass their health check
+type healthyPortfolioClient struct{ external.PortfolioClient }
+
+func (healthyPortfolioClient) Health(ctx context.Context) error { return nil }
+
+type healthySecurityClient struct{ external.SecurityClient }
+
+func (healthySecurityClient) Health(ctx context.Context) error { return nil }
+
+func TestServer_ShutdownDrainsReadinessFirst(t *testing.T) {
+	healthHandler := handlers.NewHealthHandler(healthyPortfolioClient{}, healthySecurityClient{}, logger.NewNoop(), "test", "test")
+	router := routes.SetupRouter(newRouterConfig(config.FeatureFlags{}), routes.RouterDependencies{
+		TransactionHandler: &handlers.TransactionHandler{},
+		BalanceHandler:     &handlers.BalanceHandler{},
+		HealthHandler:      healthHandler,
+		SwaggerHandler:     &handlers.SwaggerHandler{},
+		Logger:             logger.NewNoop(),
+		MetricsRegistry:    prometheus.NewRegistry(),
+	})
+
+	serverConfig := config.ServerConfig{
+		GracefulShutdownTimeout: 5 * time.Second,
+		ShutdownDrainPeriod:     300 * time.Millisecond,
+	}
+	server := &Server{
+		httpServer:    newHTTPServer(serverConfig, router),
+		config:        &config.Config{Server: serverConfig},
+		logger:        logger.NewNoop(),
+		healthHandler: healthHandler,
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.httpServer.Serve(listener) }()
+	readyURL := "http://" + listener.Addr().String() + "/health/ready"
+
+	client := &http.Client{Timeout: time.Second}
+	ready := func() (int, error) {
+		resp, err := client.Get(readyURL)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	code, err := ready()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	shutdownDone := make(chan error, 1)
+	go func() { shutdownDone <- server.Shutdown(context.Background()) }()
+
+	// During the drain period the server still answers, but reports not ready
+	require.Eventually(t, func() bool {
+		code, err := ready()
+		return err == nil && code == http.StatusServiceUnavailable
+	}, 250*time.Millisecond, 10*time.Millisecond)
+	select {
+	case <-shutdownDone:
+		t.Fatal("server stopped before the drain period ended")
+	default:
+	}
+
+	require.NoError(t, <-shutdownDone)
+	_, err = ready()
+	assert.Error(t, err, "connections are refused after shutdown")
+}
+
+func TestServer_HealthReportsEnvironmentAndBuildVersion(t *testing.T) {
+	original := serviceVersion
+	serviceVersion = "v2.3.4"
+	t.Cleanup(func() { serviceVersion = original })
+
+	server := &Server{
+		config: &config.Config{App: config.AppConfig{Environment: "staging"}},
+		logger: logger.NewNoop(),
+	}
+	require.NoError(t, server.initializeHandlers())
+
+	rec := httptest.NewRecorder()
+	server.healthHandler.GetHealth(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Version     string `json:"version"`
+		Environment string `json:"environment"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, "v2.3.4", body.Version)
+	assert.Equal(t, "staging", body.Environment)
+}
+
+func TestBuildVersion(t *testing.T) {
+	buildInfo := func(version string, settings ...debug.BuildSetting) func() (*debug.BuildInfo, bool) {
+		return func() (*debug.BuildInfo, bool) {
+			return &debug.BuildInfo{Main: debug.Module{Version: version}, Settings: settings}, true
+		}
+	}
+	revision := debug.BuildSetting{Key: "vcs.revision", Value: "0123456789abcdef0123"}
+
+	tests := []struct {
+		name          string
+		override      string
+		readBuildInfo func() (*debug.BuildInfo, bool)
+		expected      string
+	}{
+		{name: "Link-time override", override: "1.4.0", readBuildInfo: buildInfo("v1.2.0"), expected: "1.4.0"},
+		{name: "Module version", readBuildInfo: buildInfo("v1.2.0", revision), expected: "v1.2.0"},
+		{name: "Development build revision", readBuildInfo: buildInfo("(devel)", revision), expected: "0123456789ab"},
+		{name: "Development build without revision", readBuildInfo: buildInfo("(devel)"), expected: "unknown"},
+		{name: "No build info", readBuildInfo: func() (*debug.BuildInfo, bool) { return nil, false }, expected: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, buildVersion(tt.override, tt.readBuildInfo))
+		})
+	}
+}
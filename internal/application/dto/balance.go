@@ -21,6 +21,16 @@ type BalanceDTO struct {
 type BalanceListResponse struct {
 	Balances   []BalanceDTO       `json:"balances"`
 	Pagination PaginationResponse `json:"pagination"`
+	// UnpricedSecurityIDs lists the securities a notional filter excluded for lack of a
+	// reference price
+	UnpricedSecurityIDs []string `json:"unpricedSecurityIds,omitempty"`
+}
+
+// TopPositionsResponse lists the largest security positions across all portfolios
+type TopPositionsResponse struct {
+	By        string       `json:"by"`
+	Limit     int          `json:"limit"`
+	Positions []BalanceDTO `json:"positions"`
 }
 
 // BalanceStatsDTO represents balance statistics
@@ -33,13 +43,21 @@ type BalanceStatsDTO struct {
 	LastUpdated      time.Time `json:"lastUpdated"`
 }
 
-// PortfolioSummaryDTO represents a summary of portfolio balances
+// PortfolioSummaryDTO represents a summary of portfolio balances. The totals cover the whole
+// portfolio while Securities holds the requested page of security positions.
 type PortfolioSummaryDTO struct {
 	PortfolioID   string                `json:"portfolioId"`
 	CashBalance   decimal.Decimal       `json:"cashBalance"`
 	SecurityCount int                   `json:"securityCount"`
 	LastUpdated   time.Time             `json:"lastUpdated"`
 	Securities    []SecurityPositionDTO `json:"securities"`
+	Pagination    PaginationResponse    `json:"pagination"`
+}
+
+// PortfolioSummariesResponse lists the summaries of several portfolios
+type PortfolioSummariesResponse struct {
+	Summaries []PortfolioSummaryDTO `json:"summaries"`
+	Count     int                   `json:"count"`
 }
 
 // SecurityPositionDTO represents a security position within a portfolio
@@ -56,6 +74,8 @@ type BalanceUpdateRequest struct {
 	QuantityLong  *decimal.Decimal `json:"quantityLong,omitempty" validate:"omitempty"`
 	QuantityShort *decimal.Decimal `json:"quantityShort,omitempty" validate:"omitempty"`
 	Version       int              `json:"version" validate:"required,min=1"`
+	// Reason explains the update for audit; required when balances.require_adjustment_reason is set
+	Reason string `json:"reason,omitempty" validate:"omitempty,max=500"`
 }
 
 // BalanceUpdateResponse represents a response for balance update operations
@@ -76,13 +96,16 @@ type BalanceUpdateItem struct {
 	QuantityLong  *decimal.Decimal `json:"quantityLong,omitempty"`
 	QuantityShort *decimal.Decimal `json:"quantityShort,omitempty"`
 	Version       int              `json:"version" validate:"required,min=1"`
+	Reason        string           `json:"reason,omitempty" validate:"omitempty,max=500"`
 }
 
-// BulkBalanceUpdateResponse represents a response for bulk balance updates
+// BulkBalanceUpdateResponse represents a response for bulk balance updates. Items whose
+// requested quantities already matched the balance are reported as unchanged rather than updated.
 type BulkBalanceUpdateResponse struct {
-	Successful []BalanceUpdateResponse `json:"successful"`
-	Failed     []BalanceUpdateError    `json:"failed"`
-	Summary    BulkUpdateSummaryDTO    `json:"summary"`
+	Updated   []BalanceUpdateResponse `json:"updated"`
+	Unchanged []BalanceUpdateResponse `json:"unchanged"`
+	Failed    []BalanceUpdateError    `json:"failed"`
+	Summary   BulkUpdateSummaryDTO    `json:"summary"`
 }
 
 // BalanceUpdateError represents a failed balance update
@@ -94,7 +117,8 @@ type BalanceUpdateError struct {
 // BulkUpdateSummaryDTO represents summary information for bulk updates
 type BulkUpdateSummaryDTO struct {
 	TotalRequested int     `json:"totalRequested"`
-	Successful     int     `json:"successful"`
+	Updated        int     `json:"updated"`
+	Unchanged      int     `json:"unchanged"`
 	Failed         int     `json:"failed"`
 	SuccessRate    float64 `json:"successRate"`
 }
@@ -116,3 +140,135 @@ type BalanceHistoryResponse struct {
 	History    []BalanceHistoryDTO `json:"history"`
 	Pagination PaginationResponse  `json:"pagination"`
 }
+
+// PortfolioRecomputeResponse represents the result of re-deriving a portfolio's balances
+type PortfolioRecomputeResponse struct {
+	PortfolioID          string       `json:"portfolioId"`
+	TransactionsReplayed int          `json:"transactionsReplayed"`
+	BalancesCreated      int          `json:"balancesCreated"`
+	BalancesUpdated      int          `json:"balancesUpdated"`
+	BalancesZeroed       int          `json:"balancesZeroed"`
+	Balances             []BalanceDTO `json:"balances"`
+	RecomputedAt         time.Time    `json:"recomputedAt"`
+}
+
+// PortfolioBalancesAsOfResponse represents a portfolio's balances re-derived as of a past date
+type PortfolioBalancesAsOfResponse struct {
+	PortfolioID          string       `json:"portfolioId"`
+	AsOfDate             string       `json:"asOfDate"`
+	DateBasis            string       `json:"dateBasis"`
+	TransactionsReplayed int          `json:"transactionsReplayed"`
+	Balances             []BalanceDTO `json:"balances"`
+}
+
+// PortfolioLedgerResponse is a window of a portfolio's ledger re-derived from its processed
+// transactions and balance adjustments: the balances before fromDate and every transaction and
+// adjustment effective through toDate
+type PortfolioLedgerResponse struct {
+	PortfolioID          string           `json:"portfolioId"`
+	FromDate             string           `json:"fromDate"`
+	ToDate               string           `json:"toDate"`
+	DateBasis            string           `json:"dateBasis"`
+	TransactionsReplayed int              `json:"transactionsReplayed"`
+	OpeningBalances      []BalanceDTO     `json:"openingBalances"`
+	Entries              []LedgerEntryDTO `json:"entries"`
+}
+
+// LedgerEntryDTO is a ledger transaction or manual balance adjustment with the balances it left
+// behind; exactly one of Transaction and Adjustment is set
+type LedgerEntryDTO struct {
+	EffectiveDate   string                  `json:"effectiveDate"`
+	Transaction     *TransactionResponseDTO `json:"transaction,omitempty"`
+	Adjustment      *BalanceAdjustmentDTO   `json:"adjustment,omitempty"`
+	SecurityBalance *BalanceDTO             `json:"securityBalance,omitempty"`
+	CashBalance     *BalanceDTO             `json:"cashBalance,omitempty"`
+}
+
+// ConsistencyCheckResponse reports stored balances that drifted from a portfolio's processed transactions
+type ConsistencyCheckResponse struct {
+	PortfolioID          string                  `json:"portfolioId"`
+	Consistent           bool                    `json:"consistent"`
+	TransactionsReplayed int                     `json:"transactionsReplayed"`
+	BalancesChecked      int                     `json:"balancesChecked"`
+	DriftCount           int                     `json:"driftCount"`
+	Discrepancies        []BalanceDiscrepancyDTO `json:"discrepancies"`
+	CheckedAt            time.Time               `json:"checkedAt"`
+}
+
+// BalanceDiscrepancyDTO describes a single drifted balance. Kind is MISMATCH (stored quantities
+// differ), MISSING (expected balance not stored) or UNEXPECTED (stored balance without
+// supporting transactions).
+type BalanceDiscrepancyDTO struct {
+	Kind                  string          `json:"kind"`
+	BalanceID             *int64          `json:"balanceId,omitempty"`
+	SecurityID            *string         `json:"securityId,omitempty"`
+	ExpectedQuantityLong  decimal.Decimal `json:"expectedQuantityLong"`
+	ExpectedQuantityShort decimal.Decimal `json:"expectedQuantityShort"`
+	StoredQuantityLong    decimal.Decimal `json:"storedQuantityLong"`
+	StoredQuantityShort   decimal.Decimal `json:"storedQuantityShort"`
+}
+
+// BalanceAdjustmentRequest represents a manual adjustment of a portfolio/security balance.
+// AdjustmentKey makes the request idempotent; a nil SecurityID adjusts the cash balance.
+type BalanceAdjustmentRequest struct {
+	AdjustmentKey      string          `json:"adjustmentKey" validate:"required,max=100"`
+	PortfolioID        string          `json:"portfolioId" validate:"required,len=24"`
+	SecurityID         *string         `json:"securityId,omitempty" validate:"omitempty,len=24"`
+	QuantityLongDelta  decimal.Decimal `json:"quantityLongDelta"`
+	QuantityShortDelta decimal.Decimal `json:"quantityShortDelta"`
+	Reason             string          `json:"reason" validate:"required,max=500"`
+	Operator           string          `json:"operator" validate:"required,max=100"`
+	ExpectedVersion    *int            `json:"expectedVersion,omitempty" validate:"omitempty,min=0"`
+}
+
+// BalanceAdjustmentDTO represents an entry in the balance adjustment ledger
+type BalanceAdjustmentDTO struct {
+	ID                  int64           `json:"id"`
+	AdjustmentKey       string          `json:"adjustmentKey"`
+	BalanceID           int64           `json:"balanceId"`
+	PortfolioID         string          `json:"portfolioId"`
+	SecurityID          *string         `json:"securityId,omitempty"`
+	QuantityLongDelta   decimal.Decimal `json:"quantityLongDelta"`
+	QuantityShortDelta  decimal.Decimal `json:"quantityShortDelta"`
+	QuantityLongBefore  decimal.Decimal `json:"quantityLongBefore"`
+	QuantityShortBefore decimal.Decimal `json:"quantityShortBefore"`
+	QuantityLongAfter   decimal.Decimal `json:"quantityLongAfter"`
+	QuantityShortAfter  decimal.Decimal `json:"quantityShortAfter"`
+	BalanceVersion      int             `json:"balanceVersion"`
+	Reason              string          `json:"reason"`
+	Operator            string          `json:"operator"`
+	CreatedAt           time.Time       `json:"createdAt"`
+}
+
+// BalanceAdjustmentResponse represents the result of a balance adjustment. Applied is false when
+// the adjustment key was already recorded and the stored adjustment is returned unchanged.
+type BalanceAdjustmentResponse struct {
+	Adjustment BalanceAdjustmentDTO `json:"adjustment"`
+	Balance    BalanceDTO           `json:"balance"`
+	Applied    bool                 `json:"applied"`
+}
+
+// PortfolioExposureDTO represents the aggregate long/short exposure of a portfolio's security
+// positions. Cash is excluded. Quantities are always reported; Value is present when at least
+// one position could be priced.
+type PortfolioExposureDTO struct {
+	PortfolioID   string            `json:"portfolioId"`
+	SecurityCount int               `json:"securityCount"`
+	TotalLong     decimal.Decimal   `json:"totalLong"`
+	TotalShort    decimal.Decimal   `json:"totalShort"`
+	Gross         decimal.Decimal   `json:"gross"`
+	Net           decimal.Decimal   `json:"net"`
+	Value         *ExposureValueDTO `json:"value,omitempty"`
+	CalculatedAt  time.Time         `json:"calculatedAt"`
+}
+
+// ExposureValueDTO represents exposure in value terms, using the latest processed transaction
+// price of each security. Unpriced positions are left out of the totals and counted separately.
+type ExposureValueDTO struct {
+	Long               decimal.Decimal `json:"long"`
+	Short              decimal.Decimal `json:"short"`
+	Gross              decimal.Decimal `json:"gross"`
+	Net                decimal.Decimal `json:"net"`
+	PricedSecurities   int             `json:"pricedSecurities"`
+	UnpricedSecurities int             `json:"unpricedSecurities"`
+}
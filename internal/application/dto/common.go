@@ -4,6 +4,10 @@ import (
 	"time"
 )
 
+// MaxPageLimit is the largest page the transaction and balance lists return; a larger requested
+// limit is clamped to it and the clamp is reported in the pagination response
+const MaxPageLimit = 1000
+
 // PaginationRequest represents pagination parameters for requests
 type PaginationRequest struct {
 	Limit  int `json:"limit" validate:"min=1,max=1000"`
@@ -18,6 +22,15 @@ type PaginationResponse struct {
 	HasMore    bool  `json:"hasMore"`
 	Page       int   `json:"page"`
 	TotalPages int   `json:"totalPages"`
+
+	// RequestedLimit is the limit the client asked for when it was clamped to Limit
+	RequestedLimit int  `json:"requestedLimit,omitempty"`
+	LimitClamped   bool `json:"limitClamped,omitempty"`
+}
+
+// CountResponse represents the number of records matching a filter
+type CountResponse struct {
+	Count int64 `json:"count"`
 }
 
 // SortRequest represents sorting parameters
@@ -63,6 +76,12 @@ type MetricsResponse struct {
 	Metrics   map[string]interface{} `json:"metrics"`
 }
 
+// StatsResponse combines the transaction and balance statistics
+type StatsResponse struct {
+	Transactions *TransactionStatsDTO `json:"transactions"`
+	Balances     *BalanceStatsDTO     `json:"balances"`
+}
+
 // NewPaginationResponse creates a new pagination response
 func NewPaginationResponse(limit, offset int, total int64) PaginationResponse {
 	page := (offset / limit) + 1
@@ -79,6 +98,16 @@ func NewPaginationResponse(limit, offset int, total int64) PaginationResponse {
 	}
 }
 
+// WithRequestedLimit reports on the response that the requested limit was clamped to Limit;
+// a requested limit within Limit leaves the response unchanged
+func (p PaginationResponse) WithRequestedLimit(requested int) PaginationResponse {
+	if requested > p.Limit {
+		p.RequestedLimit = requested
+		p.LimitClamped = true
+	}
+	return p
+}
+
 // NewErrorResponse creates a new error response
 func NewErrorResponse(code, message string, details map[string]interface{}) ErrorResponse {
 	return ErrorResponse{
@@ -0,0 +1,114 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Decimal JSON encodings. Quantities, prices and amounts are decimal.Decimal values, which
+// are written either as JSON strings ("100.25"), keeping their exact digits for clients that
+// parse numbers as floating point, or as JSON numbers (100.25). Requests accept both forms
+// whatever the encoding.
+const (
+	DecimalEncodingString = "string"
+	DecimalEncodingNumber = "number"
+)
+
+// SetDecimalEncoding selects how decimal values are written to JSON for the whole process;
+// an empty encoding selects DecimalEncodingString. It is meant to be called once at startup.
+func SetDecimalEncoding(encoding string) error {
+	switch encoding {
+	case "", DecimalEncodingString:
+		decimal.MarshalJSONWithoutQuotes = false
+	case DecimalEncodingNumber:
+		decimal.MarshalJSONWithoutQuotes = true
+	default:
+		return fmt.Errorf("unknown decimal encoding %q (must be %s or %s)", encoding, DecimalEncodingString, DecimalEncodingNumber)
+	}
+	return nil
+}
+
+// DecimalEncoding returns the encoding decimal values are currently written with
+func DecimalEncoding() string {
+	if decimal.MarshalJSONWithoutQuotes {
+		return DecimalEncodingNumber
+	}
+	return DecimalEncodingString
+}
+
+// DecimalFieldError reports a decimal field of a request body that is not a finite decimal
+// number, such as "NaN" or "Infinity"
+type DecimalFieldError struct {
+	Field string
+	Value string
+}
+
+// Error implements error
+func (e *DecimalFieldError) Error() string {
+	if IsNonFiniteDecimal(e.Value) {
+		return fmt.Sprintf("%s must be a finite number, got %s", e.Field, e.Value)
+	}
+	return fmt.Sprintf("%s must be a decimal number, got %s", e.Field, e.Value)
+}
+
+// maxDecimalFieldErrorValue bounds how much of a rejected value a DecimalFieldError repeats
+const maxDecimalFieldErrorValue = 40
+
+// UnmarshalJSON decodes a transaction, reporting a quantity or price that is not a decimal
+// number as a *DecimalFieldError naming the field instead of a bare parse failure
+func (t *TransactionPostDTO) UnmarshalJSON(data []byte) error {
+	type plain TransactionPostDTO
+	fields := struct {
+		*plain
+		Quantity json.RawMessage `json:"quantity"`
+		Price    json.RawMessage `json:"price"`
+	}{plain: (*plain)(t)}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var err error
+	if t.Quantity, err = unmarshalDecimalField("quantity", fields.Quantity); err != nil {
+		return err
+	}
+	// An absent or null price stays nil, so an omitted price can be told apart from a zero one
+	t.Price = nil
+	if len(fields.Price) == 0 || string(fields.Price) == "null" {
+		return nil
+	}
+	price, err := unmarshalDecimalField("price", fields.Price)
+	if err != nil {
+		return err
+	}
+	t.Price = &price
+	return nil
+}
+
+// unmarshalDecimalField decodes a JSON string or number into a decimal; an absent field is zero
+func unmarshalDecimalField(field string, raw json.RawMessage) (decimal.Decimal, error) {
+	var value decimal.Decimal
+	if len(raw) == 0 {
+		return value, nil
+	}
+	if err := value.UnmarshalJSON(raw); err != nil {
+		text := strings.Trim(string(raw), `"`)
+		if len(text) > maxDecimalFieldErrorValue {
+			text = text[:maxDecimalFieldErrorValue] + "..."
+		}
+		return decimal.Decimal{}, &DecimalFieldError{Field: field, Value: text}
+	}
+	return value, nil
+}
+
+// IsNonFiniteDecimal reports whether text spells NaN or an infinity, in any case and with any
+// sign, none of which a decimal can hold
+func IsNonFiniteDecimal(text string) bool {
+	switch strings.ToLower(strings.TrimLeft(strings.TrimSpace(text), "+-")) {
+	case "nan", "inf", "infinity":
+		return true
+	}
+	return false
+}
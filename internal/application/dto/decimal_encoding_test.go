@@ -0,0 +1,137 @@
+package dto
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetDecimalEncoding(t *testing.T) {
+	t.Cleanup(func() { require.NoError(t, SetDecimalEncoding(DecimalEncodingString)) })
+
+	// More digits than a float64 holds, so a number encoding loses them in most clients
+	quantity := decimal.RequireFromString("12345678901234567.125")
+	balance := BalanceDTO{ID: 1, PortfolioID: "PORTFOLIO123456789012345", QuantityLong: quantity, QuantityShort: decimal.Zero}
+
+	t.Run("String encoding", func(t *testing.T) {
+		require.NoError(t, SetDecimalEncoding(DecimalEncodingString))
+		assert.Equal(t, DecimalEncodingString, DecimalEncoding())
+
+		body, err := json.Marshal(balance)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"quantityLong":"12345678901234567.125"`)
+		assert.Contains(t, string(body), `"quantityShort":"0"`)
+	})
+
+	t.Run("Number encoding", func(t *testing.T) {
+		require.NoError(t, SetDecimalEncoding(DecimalEncodingNumber))
+		assert.Equal(t, DecimalEncodingNumber, DecimalEncoding())
+
+		body, err := json.Marshal(balance)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `"quantityLong":12345678901234567.125`)
+		assert.Contains(t, string(body), `"quantityShort":0`)
+	})
+
+	t.Run("Empty encoding defaults to string", func(t *testing.T) {
+		require.NoError(t, SetDecimalEncoding(""))
+		assert.Equal(t, DecimalEncodingString, DecimalEncoding())
+	})
+
+	t.Run("Unknown encoding is rejected", func(t *testing.T) {
+		require.NoError(t, SetDecimalEncoding(DecimalEncodingNumber))
+		assert.Error(t, SetDecimalEncoding("float"))
+		assert.Equal(t, DecimalEncodingNumber, DecimalEncoding(), "the encoding is left unchanged")
+	})
+
+	t.Run("Requests accept both forms in either encoding", func(t *testing.T) {
+		for _, encoding := range []string{DecimalEncodingString, DecimalEncodingNumber} {
+			require.NoError(t, SetDecimalEncoding(encoding))
+			for _, body := range []string{`{"quantity":"100.25"}`, `{"quantity":100.25}`} {
+				var post TransactionPostDTO
+				require.NoError(t, json.Unmarshal([]byte(body), &post), "%s: %s", encoding, body)
+				assert.True(t, decimal.RequireFromString("100.25").Equal(post.Quantity), "%s: %s", encoding, body)
+			}
+		}
+	})
+}
+
+func TestTransactionPostDTO_UnmarshalJSON(t *testing.T) {
+	decode := func(body string) (TransactionPostDTO, error) {
+		var transaction TransactionPostDTO
+		err := json.Unmarshal([]byte(body), &transaction)
+		return transaction, err
+	}
+
+	t.Run("Strings and numbers", func(t *testing.T) {
+		transaction, err := decode(`{"sourceId":"SRC-1","quantity":"100.5","price":2.25,"settlementDate":"20240103"}`)
+		require.NoError(t, err)
+		assert.Equal(t, "SRC-1", transaction.SourceID)
+		assert.True(t, decimal.RequireFromString("100.5").Equal(transaction.Quantity))
+		require.NotNil(t, transaction.Price)
+		assert.True(t, decimal.RequireFromString("2.25").Equal(*transaction.Price))
+		require.NotNil(t, transaction.SettlementDate)
+		assert.Equal(t, "20240103", *transaction.SettlementDate)
+	})
+
+	t.Run("Absent and null values are zero or nil", func(t *testing.T) {
+		transaction, err := decode(`{"sourceId":"SRC-1","quantity":null}`)
+		require.NoError(t, err)
+		assert.True(t, transaction.Quantity.IsZero())
+		assert.Nil(t, transaction.Price)
+
+		transaction, err = decode(`{"sourceId":"SRC-1","price":null}`)
+		require.NoError(t, err)
+		assert.Nil(t, transaction.Price)
+	})
+
+	t.Run("Explicit zero price is kept", func(t *testing.T) {
+		transaction, err := decode(`{"sourceId":"SRC-1","price":0}`)
+		require.NoError(t, err)
+		require.NotNil(t, transaction.Price)
+		assert.True(t, transaction.Price.IsZero())
+	})
+
+	t.Run("Absurd exponents decode and are left to validation", func(t *testing.T) {
+		transaction, err := decode(`{"quantity":1e999999999,"price":"1e-999999999"}`)
+		require.NoError(t, err)
+		assert.Equal(t, int32(999999999), transaction.Quantity.Exponent())
+		require.NotNil(t, transaction.Price)
+		assert.Equal(t, int32(-999999999), transaction.Price.Exponent())
+	})
+
+	tests := []struct {
+		name    string
+		body    string
+		message string
+	}{
+		{name: "NaN", body: `{"quantity":"NaN"}`, message: "quantity must be a finite number, got NaN"},
+		{name: "Infinity", body: `{"quantity":"1","price":"-Infinity"}`, message: "price must be a finite number, got -Infinity"},
+		{name: "Short infinity", body: `{"price":"+inf"}`, message: "price must be a finite number, got +inf"},
+		{name: "Not a number", body: `{"quantity":"12abc"}`, message: "quantity must be a decimal number, got 12abc"},
+		{name: "Long value is cut", body: `{"price":"` + strings.Repeat("x", 100) + `"}`,
+			message: "price must be a decimal number, got " + strings.Repeat("x", 40) + "..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decode(tt.body)
+
+			var decimalErr *DecimalFieldError
+			require.ErrorAs(t, err, &decimalErr)
+			assert.Equal(t, tt.message, err.Error())
+		})
+	}
+
+	t.Run("Error survives decoding a batch", func(t *testing.T) {
+		var batch []TransactionPostDTO
+		err := json.NewDecoder(strings.NewReader(`[{"quantity":"1"},{"quantity":"NaN"}]`)).Decode(&batch)
+
+		var decimalErr *DecimalFieldError
+		require.ErrorAs(t, err, &decimalErr)
+		assert.Equal(t, "quantity", decimalErr.Field)
+	})
+}
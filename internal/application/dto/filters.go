@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -19,8 +20,8 @@ type TransactionFilter struct {
 	CashOnly     *bool    `json:"cashOnly,omitempty"`
 
 	// Status and Type filters
-	Status           *string  `json:"status,omitempty" validate:"omitempty,oneof=NEW PROC FATAL ERROR"`
-	Statuses         []string `json:"statuses,omitempty" validate:"omitempty,max=10,dive,oneof=NEW PROC FATAL ERROR"`
+	Status           *string  `json:"status,omitempty" validate:"omitempty,oneof=NEW PROC FATAL ERROR DEAD"`
+	Statuses         []string `json:"statuses,omitempty" validate:"omitempty,max=10,dive,oneof=NEW PROC FATAL ERROR DEAD"`
 	TransactionType  *string  `json:"transactionType,omitempty" validate:"omitempty,oneof=BUY SELL SHORT COVER DEP WD IN OUT"`
 	TransactionTypes []string `json:"transactionTypes,omitempty" validate:"omitempty,max=10,dive,oneof=BUY SELL SHORT COVER DEP WD IN OUT"`
 
@@ -68,7 +69,11 @@ type BalanceFilter struct {
 	PortfolioIDs []string `json:"portfolioIds,omitempty" validate:"omitempty,max=50,dive,len=24"`
 	SecurityID   *string  `json:"securityId,omitempty" validate:"omitempty,len=24"`
 	SecurityIDs  []string `json:"securityIds,omitempty" validate:"omitempty,max=50,dive,len=24"`
-	CashOnly     *bool    `json:"cashOnly,omitempty"`
+
+	// Cash vs security filter. CashOnly is the legacy form of Scope and may only be
+	// combined with a Scope that agrees with it.
+	Scope    BalanceScope `json:"scope,omitempty" validate:"omitempty,oneof=ALL CASH_ONLY SECURITIES_ONLY"`
+	CashOnly *bool        `json:"cashOnly,omitempty"`
 
 	// Quantity filters
 	MinQuantityLong  *decimal.Decimal `json:"minQuantityLong,omitempty"`
@@ -78,6 +83,15 @@ type BalanceFilter struct {
 	MinNetQuantity   *decimal.Decimal `json:"minNetQuantity,omitempty"`
 	MaxNetQuantity   *decimal.Decimal `json:"maxNetQuantity,omitempty"`
 
+	// Magnitude filters on the absolute long and short quantities, e.g. to hide dust positions
+	MinAbsQuantityLong  *decimal.Decimal `json:"minAbsQuantityLong,omitempty"`
+	MinAbsQuantityShort *decimal.Decimal `json:"minAbsQuantityShort,omitempty"`
+
+	// Notional filters on quantityLong times the security's reference price. Positions
+	// without a reference price never match and cash balances are excluded.
+	MinNotional *decimal.Decimal `json:"minNotional,omitempty"`
+	MaxNotional *decimal.Decimal `json:"maxNotional,omitempty"`
+
 	// Zero balance filters
 	ZeroBalancesOnly    *bool `json:"zeroBalancesOnly,omitempty"`
 	NonZeroBalancesOnly *bool `json:"nonZeroBalancesOnly,omitempty"`
@@ -95,6 +109,24 @@ type BalanceFilter struct {
 	HasShortPositions *bool `json:"hasShortPositions,omitempty"`
 }
 
+// BalanceScope selects which kind of balances a balance query returns
+type BalanceScope string
+
+const (
+	BalanceScopeAll            BalanceScope = "ALL"
+	BalanceScopeCashOnly       BalanceScope = "CASH_ONLY"
+	BalanceScopeSecuritiesOnly BalanceScope = "SECURITIES_ONLY"
+)
+
+// IsValid checks if the balance scope is one of the supported values
+func (s BalanceScope) IsValid() bool {
+	switch s {
+	case BalanceScopeAll, BalanceScopeCashOnly, BalanceScopeSecuritiesOnly:
+		return true
+	}
+	return false
+}
+
 // PortfolioSummaryFilter represents filters for portfolio summary queries
 type PortfolioSummaryFilter struct {
 	PortfolioIDs     []string          `json:"portfolioIds,omitempty" validate:"omitempty,max=50,dive,len=24"`
@@ -199,6 +231,12 @@ func (bf *BalanceFilter) IsValid() bool {
 		}
 	}
 
+	if bf.MinNotional != nil && bf.MaxNotional != nil {
+		if bf.MinNotional.GreaterThan(*bf.MaxNotional) {
+			return false
+		}
+	}
+
 	// Check date range validity
 	if bf.LastUpdatedFrom != nil && bf.LastUpdatedTo != nil {
 		if bf.LastUpdatedFrom.After(*bf.LastUpdatedTo) {
@@ -208,3 +246,59 @@ func (bf *BalanceFilter) IsValid() bool {
 
 	return true
 }
+
+// HasNotionalFilter reports whether the filter selects balances by notional value
+func (bf *BalanceFilter) HasNotionalFilter() bool {
+	return bf.MinNotional != nil || bf.MaxNotional != nil
+}
+
+// MatchesNotional reports whether a notional value lies within the filter's bounds
+func (bf *BalanceFilter) MatchesNotional(notional decimal.Decimal) bool {
+	if bf.MinNotional != nil && notional.LessThan(*bf.MinNotional) {
+		return false
+	}
+	if bf.MaxNotional != nil && notional.GreaterThan(*bf.MaxNotional) {
+		return false
+	}
+	return true
+}
+
+// ResolveScope returns the effective balance scope, folding the legacy CashOnly flag
+// into Scope. Contradictory combinations are rejected with an error.
+func (bf *BalanceFilter) ResolveScope() (BalanceScope, error) {
+	scope := bf.Scope
+	if scope != "" && !scope.IsValid() {
+		return "", fmt.Errorf("invalid scope %q: must be one of ALL, CASH_ONLY, SECURITIES_ONLY", scope)
+	}
+
+	// cashOnly=true narrows to cash balances; cashOnly=false only rules out CASH_ONLY
+	if bf.CashOnly != nil {
+		if *bf.CashOnly {
+			if scope != "" && scope != BalanceScopeCashOnly {
+				return "", fmt.Errorf("cashOnly=true contradicts scope %s", scope)
+			}
+			scope = BalanceScopeCashOnly
+		} else if scope == BalanceScopeCashOnly {
+			return "", fmt.Errorf("cashOnly=false contradicts scope %s", scope)
+		}
+	}
+
+	if scope == "" {
+		scope = BalanceScopeAll
+	}
+
+	// A specific security can never match a cash-only query
+	if scope == BalanceScopeCashOnly && (bf.SecurityID != nil || len(bf.SecurityIDs) > 0) {
+		return "", fmt.Errorf("security ID filters cannot be combined with scope %s", scope)
+	}
+
+	// Cash has no reference price, so notional filters only apply to securities
+	if bf.HasNotionalFilter() {
+		if scope == BalanceScopeCashOnly {
+			return "", fmt.Errorf("notional filters cannot be combined with scope %s", scope)
+		}
+		scope = BalanceScopeSecuritiesOnly
+	}
+
+	return scope, nil
+}
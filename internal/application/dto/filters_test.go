@@ -0,0 +1,98 @@
+package dto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalanceFilter_ResolveScope(t *testing.T) {
+	boolPtr := func(b bool) *bool { return &b }
+	securityID := "SECURITY1234567890123456"
+
+	tests := []struct {
+		name          string
+		filter        BalanceFilter
+		expectedScope BalanceScope
+		expectError   bool
+	}{
+		{
+			name:          "No scope defaults to all",
+			filter:        BalanceFilter{},
+			expectedScope: BalanceScopeAll,
+		},
+		{
+			name:          "Explicit all",
+			filter:        BalanceFilter{Scope: BalanceScopeAll},
+			expectedScope: BalanceScopeAll,
+		},
+		{
+			name:          "Cash only",
+			filter:        BalanceFilter{Scope: BalanceScopeCashOnly},
+			expectedScope: BalanceScopeCashOnly,
+		},
+		{
+			name:          "Securities only",
+			filter:        BalanceFilter{Scope: BalanceScopeSecuritiesOnly},
+			expectedScope: BalanceScopeSecuritiesOnly,
+		},
+		{
+			name:          "Legacy cashOnly=true maps to cash only",
+			filter:        BalanceFilter{CashOnly: boolPtr(true)},
+			expectedScope: BalanceScopeCashOnly,
+		},
+		{
+			name:          "Legacy cashOnly=false leaves all balances",
+			filter:        BalanceFilter{CashOnly: boolPtr(false)},
+			expectedScope: BalanceScopeAll,
+		},
+		{
+			name:          "Legacy cashOnly=false agrees with securities only",
+			filter:        BalanceFilter{Scope: BalanceScopeSecuritiesOnly, CashOnly: boolPtr(false)},
+			expectedScope: BalanceScopeSecuritiesOnly,
+		},
+		{
+			name:          "Security ID with securities only",
+			filter:        BalanceFilter{Scope: BalanceScopeSecuritiesOnly, SecurityID: &securityID},
+			expectedScope: BalanceScopeSecuritiesOnly,
+		},
+		{
+			name:        "Invalid scope",
+			filter:      BalanceFilter{Scope: "CASH"},
+			expectError: true,
+		},
+		{
+			name:        "cashOnly=true contradicts securities only",
+			filter:      BalanceFilter{Scope: BalanceScopeSecuritiesOnly, CashOnly: boolPtr(true)},
+			expectError: true,
+		},
+		{
+			name:        "cashOnly=false contradicts cash only",
+			filter:      BalanceFilter{Scope: BalanceScopeCashOnly, CashOnly: boolPtr(false)},
+			expectError: true,
+		},
+		{
+			name:        "Security ID contradicts cash only",
+			filter:      BalanceFilter{CashOnly: boolPtr(true), SecurityID: &securityID},
+			expectError: true,
+		},
+		{
+			name:        "Security IDs contradict cash only",
+			filter:      BalanceFilter{Scope: BalanceScopeCashOnly, SecurityIDs: []string{securityID}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, err := tt.filter.ResolveScope()
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedScope, scope)
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package dto
+
+// Query field types reported by the list endpoint schemas
+const (
+	QueryFieldTypeString  = "string"
+	QueryFieldTypeDate    = "date"
+	QueryFieldTypeBoolean = "boolean"
+	QueryFieldTypeDecimal = "decimal"
+)
+
+// QueryDateLayout is the layout of date query parameters of the list endpoints (YYYY-MM-DD)
+const QueryDateLayout = "2006-01-02"
+
+// QueryFieldDTO describes a query parameter that filters a list endpoint
+type QueryFieldDTO struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Format      string   `json:"format,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Description string   `json:"description"`
+}
+
+// QuerySchemaDTO lists the filter and sort fields a list endpoint accepts. The schemas returned
+// by TransactionQuerySchema and BalanceQuerySchema are the allowlists the API validates
+// requests against, so clients can discover the supported fields instead of guessing.
+type QuerySchemaDTO struct {
+	Resource     string          `json:"resource"`
+	FilterFields []QueryFieldDTO `json:"filterFields"`
+	SortFields   []string        `json:"sortFields"`
+	DefaultSort  []SortRequest   `json:"defaultSort"`
+}
+
+// IsSortable reports whether field may be used in the sortby parameter
+func (s QuerySchemaDTO) IsSortable(field string) bool {
+	for _, sortField := range s.SortFields {
+		if sortField == field {
+			return true
+		}
+	}
+	return false
+}
+
+// TransactionQuerySchema returns the filter and sort fields of GET /api/v1/transactions
+func TransactionQuerySchema() QuerySchemaDTO {
+	return QuerySchemaDTO{
+		Resource: "transactions",
+		FilterFields: []QueryFieldDTO{
+			{Name: "portfolio_id", Type: QueryFieldTypeString, Description: "Portfolio ID (24 characters)"},
+			{Name: "security_id", Type: QueryFieldTypeString, Description: "Security ID (24 characters)"},
+			{Name: "transaction_type", Type: QueryFieldTypeString, Enum: []string{"BUY", "SELL", "SHORT", "COVER", "DEP", "WD", "IN", "OUT"}, Description: "Transaction type"},
+			{Name: "status", Type: QueryFieldTypeString, Enum: []string{"NEW", "PROC", "FATAL", "ERROR", "DEAD", "QUAR"}, Description: "Transaction status"},
+			{Name: "transaction_date", Type: QueryFieldTypeDate, Format: "YYYY-MM-DD", Description: "Exact transaction date"},
+			{Name: "from_date", Type: QueryFieldTypeDate, Format: "YYYY-MM-DD", Description: "Earliest transaction date"},
+			{Name: "to_date", Type: QueryFieldTypeDate, Format: "YYYY-MM-DD", Description: "Latest transaction date"},
+		},
+		SortFields: []string{"portfolio_id", "security_id", "transaction_date", "transaction_type", "status", "created_at"},
+		DefaultSort: []SortRequest{
+			{Field: "transaction_date", Direction: "desc"},
+			{Field: "id", Direction: "asc"},
+		},
+	}
+}
+
+// BalanceQuerySchema returns the filter and sort fields of GET /api/v1/balances
+func BalanceQuerySchema() QuerySchemaDTO {
+	return QuerySchemaDTO{
+		Resource: "balances",
+		FilterFields: []QueryFieldDTO{
+			{Name: "portfolio_id", Type: QueryFieldTypeString, Description: "Portfolio ID (24 characters)"},
+			{Name: "security_id", Type: QueryFieldTypeString, Description: "Security ID (24 characters); null selects cash balances"},
+			{Name: "scope", Type: QueryFieldTypeString, Enum: []string{string(BalanceScopeAll), string(BalanceScopeCashOnly), string(BalanceScopeSecuritiesOnly)}, Description: "Cash or security balances"},
+			{Name: "cash_only", Type: QueryFieldTypeBoolean, Description: "Legacy form of scope=CASH_ONLY"},
+			{Name: "min_notional", Type: QueryFieldTypeDecimal, Description: "Minimum long quantity times the reference price"},
+			{Name: "max_notional", Type: QueryFieldTypeDecimal, Description: "Maximum long quantity times the reference price"},
+			{Name: "min_abs_long", Type: QueryFieldTypeDecimal, Description: "Minimum absolute long quantity"},
+			{Name: "min_abs_short", Type: QueryFieldTypeDecimal, Description: "Minimum absolute short quantity"},
+			{Name: "zero_balances_only", Type: QueryFieldTypeBoolean, Description: "Only balances without any position"},
+			{Name: "non_zero_balances_only", Type: QueryFieldTypeBoolean, Description: "Only balances with a position"},
+			{Name: "last_updated_from", Type: QueryFieldTypeDate, Format: "YYYY-MM-DD", Description: "Earliest last update date"},
+			{Name: "last_updated_to", Type: QueryFieldTypeDate, Format: "YYYY-MM-DD", Description: "Latest last update date"},
+		},
+		SortFields: []string{"portfolio_id", "security_id", "last_updated", "quantity_long", "quantity_short"},
+		DefaultSort: []SortRequest{
+			{Field: "portfolio_id", Direction: "asc"},
+			{Field: "security_id", Direction: "asc"},
+		},
+	}
+}
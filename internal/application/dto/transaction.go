@@ -8,13 +8,22 @@ import (
 
 // TransactionPostDTO represents the request DTO for creating transactions
 type TransactionPostDTO struct {
-	PortfolioID     string          `json:"portfolioId" validate:"required,len=24"`
-	SecurityID      *string         `json:"securityId,omitempty" validate:"omitempty,len=24"`
-	SourceID        string          `json:"sourceId" validate:"required,max=50"`
-	TransactionType string          `json:"transactionType" validate:"required,oneof=BUY SELL SHORT COVER DEP WD IN OUT"`
-	Quantity        decimal.Decimal `json:"quantity" validate:"required"`
-	Price           decimal.Decimal `json:"price" validate:"required,gt=0"`
-	TransactionDate string          `json:"transactionDate" validate:"required"`
+	PortfolioID     string           `json:"portfolioId" validate:"required,len=24"`
+	SecurityID      *string          `json:"securityId,omitempty" validate:"omitempty,len=24"`
+	SourceID        string           `json:"sourceId" validate:"required,max=50"`
+	TransactionType string           `json:"transactionType" validate:"required,oneof=BUY SELL SHORT COVER DEP WD IN OUT"`
+	Quantity        decimal.Decimal  `json:"quantity" validate:"required"`
+	Price           *decimal.Decimal `json:"price,omitempty" validate:"omitempty,gt=0"`
+	TransactionDate string           `json:"transactionDate" validate:"required"`
+	SettlementDate  *string          `json:"settlementDate,omitempty"`
+}
+
+// PriceString returns the posted price as text, or "" when the transaction was posted without one
+func (t *TransactionPostDTO) PriceString() string {
+	if t.Price == nil {
+		return ""
+	}
+	return t.Price.String()
 }
 
 // TransactionResponseDTO represents the response DTO for transactions
@@ -28,9 +37,12 @@ type TransactionResponseDTO struct {
 	Quantity             decimal.Decimal `json:"quantity"`
 	Price                decimal.Decimal `json:"price"`
 	TransactionDate      string          `json:"transactionDate"`
+	SettlementDate       *string         `json:"settlementDate,omitempty"`
 	ReprocessingAttempts int             `json:"reprocessingAttempts"`
 	Version              int             `json:"version"`
 	ErrorMessage         *string         `json:"errorMessage,omitempty"`
+	// BatchIndex is the transaction's position in the submitted array of a batch create
+	BatchIndex *int `json:"batchIndex,omitempty"`
 }
 
 // TransactionListResponse represents a paginated list of transactions
@@ -39,6 +51,37 @@ type TransactionListResponse struct {
 	Pagination   PaginationResponse       `json:"pagination"`
 }
 
+// TransactionBatchGetRequest names the transactions to fetch in one request
+type TransactionBatchGetRequest struct {
+	IDs []int64 `json:"ids" validate:"required,min=1"`
+}
+
+// TransactionBatchGetResponse holds the requested transactions that exist, in request order, and
+// the requested IDs without a transaction
+type TransactionBatchGetResponse struct {
+	Transactions []TransactionResponseDTO `json:"transactions"`
+	NotFoundIDs  []int64                  `json:"notFoundIds"`
+}
+
+// TransactionSourceBatchGetRequest names by source ID the transactions to fetch in one request
+type TransactionSourceBatchGetRequest struct {
+	SourceIDs []string `json:"sourceIds" validate:"required,min=1"`
+}
+
+// TransactionSourceBatchGetResponse holds the requested transactions that exist, in request
+// order, and the requested source IDs without a transaction
+type TransactionSourceBatchGetResponse struct {
+	Transactions      []TransactionResponseDTO `json:"transactions"`
+	NotFoundSourceIDs []string                 `json:"notFoundSourceIds"`
+}
+
+// LatestTransactionsResponse holds the most recent transaction of each requested portfolio, in
+// request order, and the requested portfolios without transactions
+type LatestTransactionsResponse struct {
+	Transactions                  []TransactionResponseDTO `json:"transactions"`
+	PortfoliosWithoutTransactions []string                 `json:"portfoliosWithoutTransactions"`
+}
+
 // TransactionStatsDTO represents transaction statistics
 type TransactionStatsDTO struct {
 	TotalCount     int64            `json:"totalCount"`
@@ -59,12 +102,27 @@ type TransactionBatchResponse struct {
 	Successful []TransactionResponseDTO `json:"successful"`
 	Failed     []TransactionErrorDTO    `json:"failed"`
 	Summary    BatchSummaryDTO          `json:"summary"`
+	Locations  []string                 `json:"locations,omitempty"` // Resource paths of created transactions, in order
+}
+
+// TransactionValidationResponse represents the outcome of validating a single transaction
+type TransactionValidationResponse struct {
+	Valid  bool              `json:"valid"`
+	Errors []ValidationError `json:"errors,omitempty"`
 }
 
 // TransactionErrorDTO represents a failed transaction in batch operations
 type TransactionErrorDTO struct {
 	Transaction TransactionPostDTO `json:"transaction"`
 	Errors      []ValidationError  `json:"errors"`
+	// BatchIndex is the transaction's position in the submitted array of a batch create
+	BatchIndex *int `json:"batchIndex,omitempty"`
+}
+
+// IndexedTransactionErrorDTO is a transaction error with the transaction's position in its batch
+type IndexedTransactionErrorDTO struct {
+	Index int `json:"index"`
+	TransactionErrorDTO
 }
 
 // BatchSummaryDTO represents summary information for batch operations
@@ -73,6 +131,29 @@ type BatchSummaryDTO struct {
 	Successful     int     `json:"successful"`
 	Failed         int     `json:"failed"`
 	SuccessRate    float64 `json:"successRate"`
+	// DeadlineExceeded reports that the batch ran past its processing timeout; the Unprocessed
+	// transactions were not created and are listed as failed
+	DeadlineExceeded bool `json:"deadlineExceeded,omitempty"`
+	Unprocessed      int  `json:"unprocessed,omitempty"`
+	// DailyLimitExceeded is how many transactions were not created because their portfolio had
+	// reached its daily transaction limit; they are listed as failed
+	DailyLimitExceeded int `json:"dailyLimitExceeded,omitempty"`
+	// Timing is reported only when the batch create was asked for it
+	Timing *BatchTimingDTO `json:"timing,omitempty"`
+}
+
+// BatchTimingDTO represents how long a batch create took and how its portfolios fared
+type BatchTimingDTO struct {
+	DurationMs       float64                    `json:"durationMs"`
+	RecordsPerSecond float64                    `json:"recordsPerSecond"`
+	Portfolios       []PortfolioBatchSummaryDTO `json:"portfolios"` // Ordered by portfolio ID
+}
+
+// PortfolioBatchSummaryDTO represents the outcome of a batch create for one portfolio
+type PortfolioBatchSummaryDTO struct {
+	PortfolioID string `json:"portfolioId"`
+	Successful  int    `json:"successful"`
+	Failed      int    `json:"failed"`
 }
 
 // ValidationError represents a validation error
@@ -91,6 +172,22 @@ type TransactionProcessingResult struct {
 	ErrorMessage   *string   `json:"errorMessage,omitempty"`
 }
 
+// QuarantineReleaseResponse reports a pass over quarantined transactions: the processing result
+// of each one released, the IDs of those whose references are still unknown or could not be
+// looked up, and those whose release or processing failed
+type QuarantineReleaseResponse struct {
+	Checked          int                           `json:"checked"`
+	Released         []TransactionProcessingResult `json:"released"`
+	StillQuarantined []int64                       `json:"stillQuarantined"`
+	Failed           []QuarantineReleaseFailure    `json:"failed"`
+}
+
+// QuarantineReleaseFailure is a quarantined transaction whose release or processing failed
+type QuarantineReleaseFailure struct {
+	TransactionID int64  `json:"transactionId"`
+	Error         string `json:"error"`
+}
+
 // FileProcessingStatus represents the status of file processing
 type FileProcessingStatus struct {
 	Filename         string     `json:"filename"`
@@ -101,4 +198,124 @@ type FileProcessingStatus struct {
 	ProcessedRecords int        `json:"processedRecords"`
 	FailedRecords    int        `json:"failedRecords"`
 	ErrorFilename    *string    `json:"errorFilename,omitempty"`
+	// ContentHash is the hex encoded SHA-256 hash of the file's content when processing started
+	ContentHash string `json:"contentHash,omitempty"`
+
+	// CompletedBatches is the number of batches submitted so far
+	CompletedBatches int `json:"completedBatches"`
+	// ResumeFromRecord is set when a job stops early: the position, in processing order, of
+	// the first record that has not been handled yet
+	ResumeFromRecord *int `json:"resumeFromRecord,omitempty"`
+	// ResumedFromRecord is set when a job continued an earlier, unfinished run of the same file:
+	// the position of the first record this run handled
+	ResumedFromRecord *int `json:"resumedFromRecord,omitempty"`
+	// CheckpointPortfolio is the portfolio of the last batch recorded in the progress checkpoint
+	CheckpointPortfolio *string `json:"checkpointPortfolio,omitempty"`
+	// RecoveredRecords counts records of a batch interrupted before its checkpoint that a
+	// resumed run found already stored; they are included in ProcessedRecords
+	RecoveredRecords int `json:"recoveredRecords,omitempty"`
+}
+
+// FileProcessingSuccessDTO describes the most recent file processing run that completed
+type FileProcessingSuccessDTO struct {
+	Filename         string    `json:"filename"`
+	CompletedAt      time.Time `json:"completedAt"`
+	ProcessedRecords int       `json:"processedRecords"`
+	FailedRecords    int       `json:"failedRecords"`
+}
+
+// FileDirectoryHealthDTO reports whether a file processing directory exists and is writable
+type FileDirectoryHealthDTO struct {
+	Path     string `json:"path"`
+	Writable bool   `json:"writable"`
+	Error    string `json:"error,omitempty"`
+}
+
+// FileDirectoriesHealthDTO reports the directories the file processor reads from and writes to
+type FileDirectoriesHealthDTO struct {
+	WorkingDirectory FileDirectoryHealthDTO `json:"workingDirectory"`
+	ErrorDirectory   FileDirectoryHealthDTO `json:"errorDirectory"`
+}
+
+// Writable reports whether every directory is writable
+func (h FileDirectoriesHealthDTO) Writable() bool {
+	return h.WorkingDirectory.Writable && h.ErrorDirectory.Writable
+}
+
+// TransactionEventDTO represents one entry in a transaction's audit history. Status, attempts,
+// error and version describe the transaction after the change.
+type TransactionEventDTO struct {
+	ID                   int64     `json:"id"`
+	EventType            string    `json:"eventType"`
+	OldStatus            *string   `json:"oldStatus,omitempty"`
+	NewStatus            string    `json:"newStatus"`
+	ReprocessingAttempts int       `json:"reprocessingAttempts"`
+	ErrorMessage         *string   `json:"errorMessage,omitempty"`
+	ErrorRetryable       bool      `json:"errorRetryable"`
+	Version              int       `json:"version"`
+	Timestamp            time.Time `json:"timestamp"`
+}
+
+// TransactionHistoryResponse represents the audit history of a transaction, oldest event first
+type TransactionHistoryResponse struct {
+	TransactionID int64                 `json:"transactionId"`
+	Events        []TransactionEventDTO `json:"events"`
+}
+
+// TransactionBalanceImpactDTO represents how a transaction changes its portfolio's balances.
+// State is "processing" when the resulting balances build on the balances the transaction was
+// processed against, or "current" when they build on the stored balances.
+type TransactionBalanceImpactDTO struct {
+	TransactionID   int64             `json:"transactionId"`
+	PortfolioID     string            `json:"portfolioId"`
+	SecurityID      *string           `json:"securityId,omitempty"`
+	TransactionType string            `json:"transactionType"`
+	Quantity        decimal.Decimal   `json:"quantity"`
+	Price           decimal.Decimal   `json:"price"`
+	NotionalAmount  decimal.Decimal   `json:"notionalAmount"`
+	State           string            `json:"state"`
+	DateBasis       string            `json:"dateBasis"`
+	SecurityImpact  *BalanceChangeDTO `json:"securityImpact,omitempty"`
+	CashImpact      *BalanceChangeDTO `json:"cashImpact,omitempty"`
+}
+
+// TransactionBalancesDTO lists the current values of the balances a transaction affects: the
+// security balance of a security transaction first, then the cash balance when the transaction
+// moves cash. Balances that do not exist yet are left out.
+type TransactionBalancesDTO struct {
+	TransactionID   int64        `json:"transactionId"`
+	PortfolioID     string       `json:"portfolioId"`
+	SecurityID      *string      `json:"securityId,omitempty"`
+	TransactionType string       `json:"transactionType"`
+	Status          string       `json:"status"`
+	Balances        []BalanceDTO `json:"balances"`
+}
+
+// BalanceChangeDTO represents a transaction's change to one balance
+type BalanceChangeDTO struct {
+	BalanceType    string          `json:"balanceType"` // "SECURITY" or "CASH"
+	LongChange     decimal.Decimal `json:"longChange"`
+	ShortChange    decimal.Decimal `json:"shortChange"`
+	NetChange      decimal.Decimal `json:"netChange"`
+	ResultingLong  decimal.Decimal `json:"resultingLong"`
+	ResultingShort decimal.Decimal `json:"resultingShort"`
+}
+
+// TransactionRetentionRequest represents a request to delete transactions past their retention window
+type TransactionRetentionRequest struct {
+	// Before is the cutoff; transactions dated earlier are selected
+	Before time.Time `json:"before"`
+	// Statuses limits the selection; empty selects every final status (PROC, FATAL, DEAD)
+	Statuses []string `json:"statuses,omitempty"`
+	// DryRun only counts the selected transactions
+	DryRun bool `json:"dryRun"`
+}
+
+// TransactionRetentionResponse reports the transactions selected and deleted by a retention request
+type TransactionRetentionResponse struct {
+	Before   string   `json:"before"`
+	Statuses []string `json:"statuses"`
+	DryRun   bool     `json:"dryRun"`
+	Matched  int64    `json:"matched"`
+	Deleted  int64    `json:"deleted"`
 }
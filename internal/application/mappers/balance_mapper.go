@@ -1,6 +1,7 @@
 package mappers
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
@@ -93,24 +94,38 @@ func (m *BalanceMapper) ToPortfolioSummaryDTO(portfolioID string, balances []*mo
 	return summary
 }
 
-// ToBatchUpdateResponse converts balance update results to batch response
+// ToBatchUpdateResponse converts balance update results to batch response, splitting the
+// successful results into updated and unchanged by their Updated flag
 func (m *BalanceMapper) ToBatchUpdateResponse(successful []dto.BalanceUpdateResponse, failed []dto.BalanceUpdateError) dto.BulkBalanceUpdateResponse {
+	updated := make([]dto.BalanceUpdateResponse, 0, len(successful))
+	unchanged := make([]dto.BalanceUpdateResponse, 0)
+	for _, result := range successful {
+		if result.Updated {
+			updated = append(updated, result)
+		} else {
+			unchanged = append(unchanged, result)
+		}
+	}
+	if failed == nil {
+		failed = []dto.BalanceUpdateError{}
+	}
+
 	totalRequested := len(successful) + len(failed)
-	successfulCount := len(successful)
-	failedCount := len(failed)
 
 	var successRate float64
 	if totalRequested > 0 {
-		successRate = float64(successfulCount) / float64(totalRequested) * 100
+		successRate = float64(len(successful)) / float64(totalRequested) * 100
 	}
 
 	return dto.BulkBalanceUpdateResponse{
-		Successful: successful,
-		Failed:     failed,
+		Updated:   updated,
+		Unchanged: unchanged,
+		Failed:    failed,
 		Summary: dto.BulkUpdateSummaryDTO{
 			TotalRequested: totalRequested,
-			Successful:     successfulCount,
-			Failed:         failedCount,
+			Updated:        len(updated),
+			Unchanged:      len(unchanged),
+			Failed:         len(failed),
 			SuccessRate:    successRate,
 		},
 	}
@@ -179,6 +194,13 @@ func (m *BalanceMapper) ValidateBalanceUpdateRequest(request *dto.BalanceUpdateR
 		})
 	}
 
+	if len(request.Reason) > 500 {
+		errors = append(errors, dto.ValidationError{
+			Field:   "reason",
+			Message: "cannot exceed 500 characters",
+		})
+	}
+
 	return errors
 }
 
@@ -232,3 +254,87 @@ func (m *BalanceMapper) ValidateBulkBalanceUpdateRequest(request *dto.BulkBalanc
 
 	return errors
 }
+
+// ValidateBalanceAdjustmentRequest validates a balance adjustment request
+func (m *BalanceMapper) ValidateBalanceAdjustmentRequest(request *dto.BalanceAdjustmentRequest) []dto.ValidationError {
+	var errors []dto.ValidationError
+
+	if request.AdjustmentKey == "" {
+		errors = append(errors, dto.ValidationError{
+			Field:   "adjustmentKey",
+			Message: "is required",
+		})
+	} else if len(request.AdjustmentKey) > 100 {
+		errors = append(errors, dto.ValidationError{
+			Field:   "adjustmentKey",
+			Message: "cannot exceed 100 characters",
+			Value:   request.AdjustmentKey,
+		})
+	}
+
+	if len(request.PortfolioID) != 24 {
+		errors = append(errors, dto.ValidationError{
+			Field:   "portfolioId",
+			Message: "must be exactly 24 characters",
+			Value:   request.PortfolioID,
+		})
+	}
+
+	if request.SecurityID != nil && len(*request.SecurityID) != 24 {
+		errors = append(errors, dto.ValidationError{
+			Field:   "securityId",
+			Message: "must be exactly 24 characters",
+			Value:   *request.SecurityID,
+		})
+	}
+
+	if request.QuantityLongDelta.IsZero() && request.QuantityShortDelta.IsZero() {
+		errors = append(errors, dto.ValidationError{
+			Field:   "quantities",
+			Message: "at least one delta (long or short) must be non-zero",
+		})
+	}
+
+	if request.SecurityID == nil && !request.QuantityShortDelta.IsZero() {
+		errors = append(errors, dto.ValidationError{
+			Field:   "quantityShortDelta",
+			Message: "must be zero for cash balances",
+			Value:   request.QuantityShortDelta.String(),
+		})
+	}
+
+	if request.Reason == "" {
+		errors = append(errors, dto.ValidationError{
+			Field:   "reason",
+			Message: "is required",
+		})
+	} else if len(request.Reason) > 500 {
+		errors = append(errors, dto.ValidationError{
+			Field:   "reason",
+			Message: "cannot exceed 500 characters",
+		})
+	}
+
+	if request.Operator == "" {
+		errors = append(errors, dto.ValidationError{
+			Field:   "operator",
+			Message: "is required",
+		})
+	} else if len(request.Operator) > 100 {
+		errors = append(errors, dto.ValidationError{
+			Field:   "operator",
+			Message: "cannot exceed 100 characters",
+			Value:   request.Operator,
+		})
+	}
+
+	if request.ExpectedVersion != nil && *request.ExpectedVersion < 0 {
+		errors = append(errors, dto.ValidationError{
+			Field:   "expectedVersion",
+			Message: "cannot be negative",
+			Value:   fmt.Sprintf("%d", *request.ExpectedVersion),
+		})
+	}
+
+	return errors
+}
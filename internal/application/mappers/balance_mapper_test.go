@@ -229,7 +229,7 @@ func TestBalanceMapper_ToBatchUpdateResponse(t *testing.T) {
 
 		successful := []dto.BalanceUpdateResponse{
 			mapper.ToBalanceUpdateResponse(balance1, nil, true),
-			mapper.ToBalanceUpdateResponse(balance2, nil, true),
+			mapper.ToBalanceUpdateResponse(balance2, nil, false),
 		}
 
 		// Create failed balance update errors
@@ -249,24 +249,36 @@ func TestBalanceMapper_ToBatchUpdateResponse(t *testing.T) {
 		batchResponse := mapper.ToBatchUpdateResponse(successful, failed)
 
 		assert.NotNil(t, batchResponse)
-		assert.Len(t, batchResponse.Successful, 2)
+		assert.Len(t, batchResponse.Updated, 1)
+		assert.Len(t, batchResponse.Unchanged, 1)
 		assert.Len(t, batchResponse.Failed, 1)
 		assert.Equal(t, 3, batchResponse.Summary.TotalRequested)
-		assert.Equal(t, 2, batchResponse.Summary.Successful)
+		assert.Equal(t, 1, batchResponse.Summary.Updated)
+		assert.Equal(t, 1, batchResponse.Summary.Unchanged)
 		assert.Equal(t, 1, batchResponse.Summary.Failed)
 		assert.InDelta(t, 66.67, batchResponse.Summary.SuccessRate, 0.01) // 2/3 * 100
 
-		// Verify successful balance updates
-		assert.Equal(t, int64(1), batchResponse.Successful[0].Balance.ID)
-		assert.True(t, batchResponse.Successful[0].Updated)
-		assert.Equal(t, int64(2), batchResponse.Successful[1].Balance.ID)
-		assert.True(t, batchResponse.Successful[1].Updated)
+		// Verify updated and unchanged balances
+		assert.Equal(t, int64(1), batchResponse.Updated[0].Balance.ID)
+		assert.True(t, batchResponse.Updated[0].Updated)
+		assert.Equal(t, int64(2), batchResponse.Unchanged[0].Balance.ID)
+		assert.False(t, batchResponse.Unchanged[0].Updated)
 
 		// Verify failed balance update
 		assert.Equal(t, int64(999), batchResponse.Failed[0].BalanceID)
 		assert.Len(t, batchResponse.Failed[0].Errors, 1)
 		assert.Equal(t, "version", batchResponse.Failed[0].Errors[0].Field)
 	})
+
+	t.Run("Empty buckets are empty lists", func(t *testing.T) {
+		batchResponse := mapper.ToBatchUpdateResponse(nil, nil)
+
+		assert.NotNil(t, batchResponse.Updated)
+		assert.NotNil(t, batchResponse.Unchanged)
+		assert.NotNil(t, batchResponse.Failed)
+		assert.Zero(t, batchResponse.Summary.TotalRequested)
+		assert.Zero(t, batchResponse.Summary.SuccessRate)
+	})
 }
 
 func TestBalanceMapper_ValidateBalanceUpdateRequest(t *testing.T) {
@@ -2,20 +2,132 @@ package mappers
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/shopspring/decimal"
+
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
 )
 
 // TransactionMapper handles mapping between Transaction domain models and DTOs
-type TransactionMapper struct{}
+type TransactionMapper struct {
+	// typeAliases maps upper-cased alternative names to transaction types
+	typeAliases map[string]string
+	// cashPriceAutoFill fills in the price of 1.0 for cash transactions posted without one
+	cashPriceAutoFill bool
+}
 
 // NewTransactionMapper creates a new transaction mapper
 func NewTransactionMapper() *TransactionMapper {
 	return &TransactionMapper{}
 }
 
+// validTransactionTypes are the transaction types a posted transaction may have
+var validTransactionTypes = []string{"BUY", "SELL", "SHORT", "COVER", "DEP", "WD", "IN", "OUT"}
+
+// WithTransactionTypeAliases accepts alternative names for transaction types in posted
+// transactions, e.g. PURCHASE for BUY. Aliases and types are matched case-insensitively.
+func (m *TransactionMapper) WithTransactionTypeAliases(aliases map[string]string) *TransactionMapper {
+	m.typeAliases = make(map[string]string, len(aliases))
+	for alias, transactionType := range aliases {
+		m.typeAliases[strings.ToUpper(strings.TrimSpace(alias))] = strings.ToUpper(strings.TrimSpace(transactionType))
+	}
+	return m
+}
+
+// WithCashPriceAutoFill lets DEP and WD transactions be posted without a price, which is then
+// set to 1.0. A cash transaction with any other price is rejected either way.
+func (m *TransactionMapper) WithCashPriceAutoFill(enabled bool) *TransactionMapper {
+	m.cashPriceAutoFill = enabled
+	return m
+}
+
+// isCashTransactionType reports whether a normalized transaction type is DEP or WD
+func isCashTransactionType(transactionType string) bool {
+	return transactionType == "DEP" || transactionType == "WD"
+}
+
+// Quantities and prices are stored as DECIMAL(18,8): ten integer digits and eight decimal places
+const (
+	maxDecimalIntegerDigits = 10
+	decimalScale            = 8
+)
+
+// decimalRangeError returns the validation error of a quantity or price the database cannot
+// store: more integer digits than its columns hold, or a nonzero value that rounds away at eight
+// decimal places. It works from the digit count and exponent, so absurd values such as
+// 1e999999999 are rejected without being expanded.
+func decimalRangeError(field string, value decimal.Decimal) *dto.ValidationError {
+	if value.IsZero() {
+		return nil
+	}
+
+	// Position of the leading digit: 3 for 123.4, 0 for 0.5, -2 for 0.005
+	magnitude := int64(value.NumDigits()) + int64(value.Exponent())
+	switch {
+	case magnitude > maxDecimalIntegerDigits:
+		return &dto.ValidationError{
+			Field:   field,
+			Message: fmt.Sprintf("must have at most %d integer digits", maxDecimalIntegerDigits),
+			Value:   compactDecimalString(value),
+		}
+	case magnitude <= -decimalScale:
+		return &dto.ValidationError{
+			Field:   field,
+			Message: fmt.Sprintf("must be zero or at least %s in magnitude", decimal.New(1, -decimalScale).StringFixed(decimalScale)),
+			Value:   compactDecimalString(value),
+		}
+	}
+	return nil
+}
+
+// compactDecimalString formats a decimal, using exponent notation when writing it out in full
+// would take more than a few dozen digits
+func compactDecimalString(value decimal.Decimal) string {
+	if exponent := value.Exponent(); exponent > 30 || exponent < -30 {
+		return fmt.Sprintf("%se%d", value.Coefficient().String(), exponent)
+	}
+	return value.String()
+}
+
+// postedPrice returns the price of a posted transaction, filling in the cash price of 1.0 for
+// cash transactions posted without one when auto-fill is enabled. An explicit price, even zero,
+// is returned as posted; a missing one that is not filled in is reported as absent.
+func (m *TransactionMapper) postedPrice(transactionType string, price *decimal.Decimal) (decimal.Decimal, bool) {
+	if price != nil {
+		return *price, true
+	}
+	if m.cashPriceAutoFill && isCashTransactionType(transactionType) {
+		return models.CashPrice().Value(), true
+	}
+	return decimal.Zero, false
+}
+
+// NormalizeTransactionType upper-cases a posted transaction type and resolves configured
+// aliases. Unknown types are returned upper-cased for validation to reject.
+func (m *TransactionMapper) NormalizeTransactionType(transactionType string) string {
+	normalized := strings.ToUpper(strings.TrimSpace(transactionType))
+	if aliased, ok := m.typeAliases[normalized]; ok {
+		return aliased
+	}
+	return normalized
+}
+
+// IsValidTransactionType reports whether a posted transaction type, after normalization and
+// alias resolution, is one of the supported transaction types
+func (m *TransactionMapper) IsValidTransactionType(transactionType string) bool {
+	normalized := m.NormalizeTransactionType(transactionType)
+	for _, validType := range validTransactionTypes {
+		if normalized == validType {
+			return true
+		}
+	}
+	return false
+}
+
 // ToResponseDTO converts a domain Transaction to TransactionResponseDTO
 func (m *TransactionMapper) ToResponseDTO(transaction *models.Transaction) *dto.TransactionResponseDTO {
 	if transaction == nil {
@@ -33,6 +145,12 @@ func (m *TransactionMapper) ToResponseDTO(transaction *models.Transaction) *dto.
 		errorMessage = transaction.ErrorMessage()
 	}
 
+	var settlementDate *string
+	if transaction.SettlementDate() != nil {
+		formatted := transaction.SettlementDate().Format("20060102")
+		settlementDate = &formatted
+	}
+
 	return &dto.TransactionResponseDTO{
 		ID:                   transaction.ID(),
 		PortfolioID:          transaction.PortfolioID().String(),
@@ -43,6 +161,7 @@ func (m *TransactionMapper) ToResponseDTO(transaction *models.Transaction) *dto.
 		Quantity:             transaction.Quantity().Value(),
 		Price:                transaction.Price().Value(),
 		TransactionDate:      transaction.TransactionDate().Format("20060102"),
+		SettlementDate:       settlementDate,
 		ReprocessingAttempts: transaction.ReprocessingAttempts(),
 		Version:              transaction.Version(),
 		ErrorMessage:         errorMessage,
@@ -70,13 +189,16 @@ func (m *TransactionMapper) FromPostDTO(postDTO *dto.TransactionPostDTO) (*model
 		return nil, fmt.Errorf("post DTO cannot be nil")
 	}
 
+	transactionType := m.NormalizeTransactionType(postDTO.TransactionType)
+	price, _ := m.postedPrice(transactionType, postDTO.Price)
+
 	// Build transaction using the builder pattern with strings
 	builder := models.NewTransactionBuilder().
 		WithPortfolioID(postDTO.PortfolioID).
 		WithSourceID(postDTO.SourceID).
-		WithTransactionType(postDTO.TransactionType).
+		WithTransactionType(transactionType).
 		WithQuantity(postDTO.Quantity).
-		WithPrice(postDTO.Price).
+		WithPrice(price).
 		WithTransactionDateFromString(postDTO.TransactionDate)
 
 	// Handle optional security ID
@@ -84,6 +206,11 @@ func (m *TransactionMapper) FromPostDTO(postDTO *dto.TransactionPostDTO) (*model
 		builder = builder.WithSecurityIDFromString(*postDTO.SecurityID)
 	}
 
+	// Handle optional settlement date
+	if postDTO.SettlementDate != nil && *postDTO.SettlementDate != "" {
+		builder = builder.WithSettlementDateFromString(*postDTO.SettlementDate)
+	}
+
 	return builder.Build()
 }
 
@@ -136,6 +263,44 @@ func (m *TransactionMapper) ToBatchResponse(successful []*models.Transaction, fa
 	}
 }
 
+// IndexedTransaction is a transaction of a batch with its position in the submitted array
+type IndexedTransaction struct {
+	Index       int
+	Transaction *models.Transaction
+}
+
+// ToIndexedBatchResponse converts the results of a batch create to a batch response whose
+// entries carry the batchIndex of their input. Successful and failed entries are each ordered
+// by batchIndex, whatever order they were processed in; failed entries must have their
+// BatchIndex set.
+func (m *TransactionMapper) ToIndexedBatchResponse(successful []IndexedTransaction, failed []dto.TransactionErrorDTO) dto.TransactionBatchResponse {
+	successful = append([]IndexedTransaction(nil), successful...)
+	sort.SliceStable(successful, func(i, j int) bool { return successful[i].Index < successful[j].Index })
+
+	failed = append([]dto.TransactionErrorDTO(nil), failed...)
+	sort.SliceStable(failed, func(i, j int) bool { return batchIndexOf(failed[i]) < batchIndexOf(failed[j]) })
+
+	transactions := make([]*models.Transaction, len(successful))
+	for i, indexed := range successful {
+		transactions[i] = indexed.Transaction
+	}
+
+	response := m.ToBatchResponse(transactions, failed)
+	for i, indexed := range successful {
+		index := indexed.Index
+		response.Successful[i].BatchIndex = &index
+	}
+	return response
+}
+
+// batchIndexOf returns the batch index of a failed entry, or -1 when it has none
+func batchIndexOf(failed dto.TransactionErrorDTO) int {
+	if failed.BatchIndex == nil {
+		return -1
+	}
+	return *failed.BatchIndex
+}
+
 // ValidatePostDTO validates a TransactionPostDTO
 func (m *TransactionMapper) ValidatePostDTO(postDTO *dto.TransactionPostDTO) []dto.ValidationError {
 	var errors []dto.ValidationError
@@ -168,33 +333,59 @@ func (m *TransactionMapper) ValidatePostDTO(postDTO *dto.TransactionPostDTO) []d
 	}
 
 	// Validate transaction type
-	validTypes := []string{"BUY", "SELL", "SHORT", "COVER", "DEP", "WD", "IN", "OUT"}
+	transactionType := m.NormalizeTransactionType(postDTO.TransactionType)
 	isValidType := false
-	for _, validType := range validTypes {
-		if postDTO.TransactionType == validType {
+	for _, validType := range validTransactionTypes {
+		if transactionType == validType {
 			isValidType = true
 			break
 		}
 	}
 	if !isValidType {
+		message := "unknown transaction type: must be one of: " + strings.Join(validTransactionTypes, ", ")
+		if len(m.typeAliases) > 0 {
+			message += " or a configured alias"
+		}
 		errors = append(errors, dto.ValidationError{
 			Field:   "transactionType",
-			Message: "must be one of: BUY, SELL, SHORT, COVER, DEP, WD, IN, OUT",
+			Message: message,
 			Value:   postDTO.TransactionType,
 		})
 	}
 
-	// Validate price is positive
-	if postDTO.Price.IsNegative() || postDTO.Price.IsZero() {
+	// Validate quantity and price fit the database columns before they are formatted anywhere
+	if rangeErr := decimalRangeError("quantity", postDTO.Quantity); rangeErr != nil {
+		errors = append(errors, *rangeErr)
+	}
+
+	// Validate price is positive, and 1.0 for cash transactions
+	price, pricePresent := m.postedPrice(transactionType, postDTO.Price)
+	priceRangeErr := decimalRangeError("price", price)
+	switch {
+	case !pricePresent:
+		errors = append(errors, dto.ValidationError{
+			Field:   "price",
+			Message: "is required",
+		})
+	case priceRangeErr != nil:
+		errors = append(errors, *priceRangeErr)
+	case price.IsNegative() || price.IsZero():
 		errors = append(errors, dto.ValidationError{
 			Field:   "price",
 			Message: "must be positive",
-			Value:   postDTO.Price.String(),
+			Value:   postDTO.PriceString(),
+		})
+	case isCashTransactionType(transactionType) && !price.Equal(models.CashPrice().Value()):
+		errors = append(errors, dto.ValidationError{
+			Field:   "price",
+			Message: "must be 1.0 for DEP/WD transactions",
+			Value:   postDTO.PriceString(),
 		})
 	}
 
 	// Validate transaction date format
-	if _, err := time.Parse("20060102", postDTO.TransactionDate); err != nil {
+	transactionDate, transactionDateErr := time.Parse("20060102", postDTO.TransactionDate)
+	if transactionDateErr != nil {
 		errors = append(errors, dto.ValidationError{
 			Field:   "transactionDate",
 			Message: "must be in YYYYMMDD format",
@@ -202,8 +393,27 @@ func (m *TransactionMapper) ValidatePostDTO(postDTO *dto.TransactionPostDTO) []d
 		})
 	}
 
+	// Validate optional settlement date format and ordering
+	if postDTO.SettlementDate != nil && *postDTO.SettlementDate != "" {
+		settlementDate, err := time.Parse("20060102", *postDTO.SettlementDate)
+		switch {
+		case err != nil:
+			errors = append(errors, dto.ValidationError{
+				Field:   "settlementDate",
+				Message: "must be in YYYYMMDD format",
+				Value:   *postDTO.SettlementDate,
+			})
+		case transactionDateErr == nil && settlementDate.Before(transactionDate):
+			errors = append(errors, dto.ValidationError{
+				Field:   "settlementDate",
+				Message: "cannot be before transactionDate",
+				Value:   *postDTO.SettlementDate,
+			})
+		}
+	}
+
 	// Business rule validation: DEP/WD transactions must not have security ID
-	if (postDTO.TransactionType == "DEP" || postDTO.TransactionType == "WD") && postDTO.SecurityID != nil {
+	if isCashTransactionType(transactionType) && postDTO.SecurityID != nil {
 		errors = append(errors, dto.ValidationError{
 			Field:   "securityId",
 			Message: "must be null for DEP/WD transactions",
@@ -212,7 +422,7 @@ func (m *TransactionMapper) ValidatePostDTO(postDTO *dto.TransactionPostDTO) []d
 	}
 
 	// Business rule validation: Non-cash transactions must have security ID
-	if (postDTO.TransactionType != "DEP" && postDTO.TransactionType != "WD") && postDTO.SecurityID == nil {
+	if !isCashTransactionType(transactionType) && postDTO.SecurityID == nil {
 		errors = append(errors, dto.ValidationError{
 			Field:   "securityId",
 			Message: "is required for non-cash transactions",
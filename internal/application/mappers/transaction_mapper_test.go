@@ -1,6 +1,7 @@
 package mappers
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -22,7 +23,7 @@ func TestTransactionMapper_FromPostDTO(t *testing.T) {
 			SourceID:        "SOURCE001",
 			TransactionType: "BUY",
 			Quantity:        decimal.NewFromInt(100),
-			Price:           decimal.NewFromFloat(50.25),
+			Price:           pricePtr(decimal.NewFromFloat(50.25)),
 			TransactionDate: "20240101",
 		}
 
@@ -46,7 +47,7 @@ func TestTransactionMapper_FromPostDTO(t *testing.T) {
 			SourceID:        "SOURCE002",
 			TransactionType: "DEP",
 			Quantity:        decimal.NewFromFloat(1000.00),
-			Price:           decimal.NewFromInt(1),
+			Price:           pricePtr(decimal.NewFromInt(1)),
 			TransactionDate: "20240115",
 		}
 
@@ -70,7 +71,7 @@ func TestTransactionMapper_FromPostDTO(t *testing.T) {
 			SourceID:        "SOURCE001",
 			TransactionType: "BUY",
 			Quantity:        decimal.NewFromInt(100),
-			Price:           decimal.NewFromFloat(50.25),
+			Price:           pricePtr(decimal.NewFromFloat(50.25)),
 			TransactionDate: "20240101",
 		}
 
@@ -88,7 +89,7 @@ func TestTransactionMapper_FromPostDTO(t *testing.T) {
 			SourceID:        "SOURCE001",
 			TransactionType: "BUY",
 			Quantity:        decimal.NewFromInt(100),
-			Price:           decimal.NewFromFloat(50.25),
+			Price:           pricePtr(decimal.NewFromFloat(50.25)),
 			TransactionDate: "invalid-date",
 		}
 
@@ -106,7 +107,7 @@ func TestTransactionMapper_FromPostDTO(t *testing.T) {
 			SourceID:        "SOURCE001",
 			TransactionType: "BUY", // Requires security ID
 			Quantity:        decimal.NewFromInt(100),
-			Price:           decimal.NewFromFloat(50.25),
+			Price:           pricePtr(decimal.NewFromFloat(50.25)),
 			TransactionDate: "20240101",
 		}
 
@@ -124,7 +125,7 @@ func TestTransactionMapper_FromPostDTO(t *testing.T) {
 			SourceID:        "SOURCE002",
 			TransactionType: "DEP", // Cash transaction
 			Quantity:        decimal.NewFromFloat(1000.00),
-			Price:           decimal.NewFromInt(1),
+			Price:           pricePtr(decimal.NewFromInt(1)),
 			TransactionDate: "20240115",
 		}
 
@@ -248,7 +249,7 @@ func TestTransactionMapper_ValidatePostDTO(t *testing.T) {
 			SourceID:        "SOURCE001",
 			TransactionType: "BUY",
 			Quantity:        decimal.NewFromInt(100),
-			Price:           decimal.NewFromFloat(50.25),
+			Price:           pricePtr(decimal.NewFromFloat(50.25)),
 			TransactionDate: "20240101",
 		}
 
@@ -262,10 +263,10 @@ func TestTransactionMapper_ValidatePostDTO(t *testing.T) {
 			PortfolioID:     "",                 // Required field missing
 			SecurityID:      stringPtr("SHORT"), // Invalid length
 			SourceID:        "SOURCE001",
-			TransactionType: "INVALID",               // Invalid type
-			Quantity:        decimal.Zero,            // Zero quantity not allowed
-			Price:           decimal.NewFromInt(-10), // Negative price not allowed
-			TransactionDate: "invalid-date",          // Invalid date format
+			TransactionType: "INVALID",                         // Invalid type
+			Quantity:        decimal.Zero,                      // Zero quantity not allowed
+			Price:           pricePtr(decimal.NewFromInt(-10)), // Negative price not allowed
+			TransactionDate: "invalid-date",                    // Invalid date format
 		}
 
 		errors := mapper.ValidatePostDTO(&postDTO)
@@ -296,6 +297,201 @@ func TestTransactionMapper_ValidatePostDTO(t *testing.T) {
 	})
 }
 
+func TestTransactionMapper_ValidatePostDTO_DecimalRange(t *testing.T) {
+	mapper := NewTransactionMapper()
+
+	tests := []struct {
+		name     string
+		quantity string
+		price    string
+		field    string
+		message  string
+		value    string
+	}{
+		{name: "Largest quantity", quantity: "9999999999.99999999", price: "1"},
+		{name: "Smallest quantity", quantity: "-0.00000001", price: "1"},
+		{name: "Zero quantity", quantity: "0e-999999999", price: "1"},
+		{name: "Trailing zeros beyond the scale", quantity: "1.500000000000", price: "1"},
+		{name: "Quantity with too many integer digits", quantity: "12345678901", price: "1",
+			field: "quantity", message: "must have at most 10 integer digits", value: "12345678901"},
+		{name: "Huge exponent", quantity: "1e999999999", price: "1",
+			field: "quantity", message: "must have at most 10 integer digits", value: "1e999999999"},
+		{name: "Negative huge exponent price", quantity: "1", price: "-5e400",
+			field: "price", message: "must have at most 10 integer digits", value: "-5e400"},
+		{name: "Price rounding to zero", quantity: "1", price: "0.000000001",
+			field: "price", message: "must be zero or at least 0.00000001 in magnitude", value: "0.000000001"},
+		{name: "Tiny exponent", quantity: "1e-999999999", price: "1",
+			field: "quantity", message: "must be zero or at least 0.00000001 in magnitude", value: "1e-999999999"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			postDTO := dto.TransactionPostDTO{
+				PortfolioID:     "PORTFOLIO123456789012345",
+				SecurityID:      stringPtr("SECURITY1234567890123456"),
+				SourceID:        "SOURCE001",
+				TransactionType: "BUY",
+				Quantity:        decimal.RequireFromString(tt.quantity),
+				Price:           pricePtr(decimal.RequireFromString(tt.price)),
+				TransactionDate: "20240101",
+			}
+
+			errors := mapper.ValidatePostDTO(&postDTO)
+			if tt.field == "" {
+				assert.Empty(t, errors)
+				return
+			}
+
+			// A price out of range is not also reported as negative
+			require.Len(t, errors, 1)
+			assert.Equal(t, tt.field, errors[0].Field)
+			assert.Equal(t, tt.message, errors[0].Message)
+			assert.Equal(t, tt.value, errors[0].Value)
+		})
+	}
+}
+
+func TestTransactionMapper_TransactionTypeSanitization(t *testing.T) {
+	buyDTO := func(transactionType string) dto.TransactionPostDTO {
+		return dto.TransactionPostDTO{
+			PortfolioID:     "PORTFOLIO123456789012345",
+			SecurityID:      stringPtr("SECURITY1234567890123456"),
+			SourceID:        "SOURCE001",
+			TransactionType: transactionType,
+			Quantity:        decimal.NewFromInt(100),
+			Price:           pricePtr(decimal.NewFromFloat(50.25)),
+			TransactionDate: "20240101",
+		}
+	}
+
+	t.Run("Lowercase and mixed case types are accepted by default", func(t *testing.T) {
+		mapper := NewTransactionMapper()
+
+		for _, transactionType := range []string{"buy", "Buy", " BUY "} {
+			postDTO := buyDTO(transactionType)
+			assert.Empty(t, mapper.ValidatePostDTO(&postDTO), transactionType)
+
+			transaction, err := mapper.FromPostDTO(&postDTO)
+			require.NoError(t, err, transactionType)
+			assert.Equal(t, models.TransactionTypeBuy, transaction.TransactionType())
+		}
+	})
+
+	t.Run("Aliases are not mapped by default", func(t *testing.T) {
+		mapper := NewTransactionMapper()
+
+		postDTO := buyDTO("PURCHASE")
+		errors := mapper.ValidatePostDTO(&postDTO)
+		require.Len(t, errors, 1)
+		assert.Equal(t, "transactionType", errors[0].Field)
+		assert.Contains(t, errors[0].Message, "unknown transaction type")
+		assert.Equal(t, "PURCHASE", errors[0].Value)
+	})
+
+	t.Run("Configured aliases map to transaction types", func(t *testing.T) {
+		mapper := NewTransactionMapper().WithTransactionTypeAliases(map[string]string{
+			"purchase": "BUY",
+			"SALE":     "sell",
+		})
+
+		postDTO := buyDTO("Purchase")
+		assert.Empty(t, mapper.ValidatePostDTO(&postDTO))
+		transaction, err := mapper.FromPostDTO(&postDTO)
+		require.NoError(t, err)
+		assert.Equal(t, models.TransactionTypeBuy, transaction.TransactionType())
+
+		assert.Equal(t, "SELL", mapper.NormalizeTransactionType("sale"))
+
+		postDTO = buyDTO("GIFT")
+		errors := mapper.ValidatePostDTO(&postDTO)
+		require.Len(t, errors, 1)
+		assert.Contains(t, errors[0].Message, "or a configured alias")
+	})
+
+	t.Run("Cash rules apply to the normalized type", func(t *testing.T) {
+		mapper := NewTransactionMapper()
+
+		postDTO := buyDTO("dep")
+		postDTO.Price = pricePtr(decimal.NewFromInt(1))
+		errors := mapper.ValidatePostDTO(&postDTO)
+		require.Len(t, errors, 1)
+		assert.Equal(t, "must be null for DEP/WD transactions", errors[0].Message)
+	})
+}
+
+func TestTransactionMapper_CashPrice(t *testing.T) {
+	deposit := func(price *decimal.Decimal) dto.TransactionPostDTO {
+		return dto.TransactionPostDTO{
+			PortfolioID:     "PORTFOLIO123456789012345",
+			SourceID:        "DEP-CASH-PRICE",
+			TransactionType: "DEP",
+			Quantity:        decimal.NewFromInt(1000),
+			Price:           price,
+			TransactionDate: "20240115",
+		}
+	}
+
+	t.Run("Omitted price is filled in with auto-fill", func(t *testing.T) {
+		mapper := NewTransactionMapper().WithCashPriceAutoFill(true)
+
+		postDTO := deposit(nil)
+		assert.Empty(t, mapper.ValidatePostDTO(&postDTO))
+		transaction, err := mapper.FromPostDTO(&postDTO)
+		require.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(1).Equal(transaction.Price().Value()))
+
+		postDTO.TransactionType = "wd"
+		assert.Empty(t, mapper.ValidatePostDTO(&postDTO))
+	})
+
+	t.Run("Omitted price is rejected without auto-fill", func(t *testing.T) {
+		mapper := NewTransactionMapper()
+
+		postDTO := deposit(nil)
+		errors := mapper.ValidatePostDTO(&postDTO)
+		require.Len(t, errors, 1)
+		assert.Equal(t, "price", errors[0].Field)
+		assert.Equal(t, "is required", errors[0].Message)
+	})
+
+	t.Run("Explicit zero price is rejected with auto-fill", func(t *testing.T) {
+		mapper := NewTransactionMapper().WithCashPriceAutoFill(true)
+
+		postDTO := deposit(pricePtr(decimal.Zero))
+		errors := mapper.ValidatePostDTO(&postDTO)
+		require.Len(t, errors, 1)
+		assert.Equal(t, "price", errors[0].Field)
+		assert.Equal(t, "must be positive", errors[0].Message)
+		assert.Equal(t, "0", errors[0].Value)
+		_, err := mapper.FromPostDTO(&postDTO)
+		assert.Error(t, err)
+	})
+
+	t.Run("Wrong cash price is rejected either way", func(t *testing.T) {
+		for _, autoFill := range []bool{false, true} {
+			mapper := NewTransactionMapper().WithCashPriceAutoFill(autoFill)
+
+			postDTO := deposit(pricePtr(decimal.NewFromFloat(1.5)))
+			errors := mapper.ValidatePostDTO(&postDTO)
+			require.Len(t, errors, 1, "auto-fill %v", autoFill)
+			assert.Equal(t, "price", errors[0].Field)
+			assert.Equal(t, "must be 1.0 for DEP/WD transactions", errors[0].Message)
+			assert.Equal(t, "1.5", errors[0].Value)
+		}
+	})
+
+	t.Run("Security transactions still require a price", func(t *testing.T) {
+		mapper := NewTransactionMapper().WithCashPriceAutoFill(true)
+
+		postDTO := deposit(nil)
+		postDTO.TransactionType = "BUY"
+		postDTO.SecurityID = stringPtr("SECURITY1234567890123456")
+		errors := mapper.ValidatePostDTO(&postDTO)
+		require.Len(t, errors, 1)
+		assert.Equal(t, "is required", errors[0].Message)
+	})
+}
+
 func TestTransactionMapper_ToBatchResponse(t *testing.T) {
 	mapper := NewTransactionMapper()
 
@@ -333,7 +529,7 @@ func TestTransactionMapper_ToBatchResponse(t *testing.T) {
 			SourceID:        "SOURCE003",
 			TransactionType: "BUY",
 			Quantity:        decimal.NewFromInt(100),
-			Price:           decimal.NewFromFloat(50.25),
+			Price:           pricePtr(decimal.NewFromFloat(50.25)),
 			TransactionDate: "20240101",
 		}
 
@@ -377,6 +573,10 @@ func stringPtr(s string) *string {
 	return &s
 }
 
+func pricePtr(price decimal.Decimal) *decimal.Decimal {
+	return &price
+}
+
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr ||
 		(len(s) > len(substr) && (s[:len(substr)] == substr ||
@@ -392,3 +592,57 @@ func containsSubstring(s, substr string) bool {
 	}
 	return false
 }
+
+func TestTransactionMapper_ToIndexedBatchResponse(t *testing.T) {
+	mapper := NewTransactionMapper()
+
+	deposit := func(id int64) *models.Transaction {
+		txn, err := models.NewTransactionBuilder().
+			WithID(id).
+			WithPortfolioID("PORTFOLIO123456789012345").
+			WithSourceID(fmt.Sprintf("SOURCE%03d", id)).
+			WithTransactionType("DEP").
+			WithQuantity(decimal.NewFromInt(100)).
+			WithPrice(decimal.NewFromInt(1)).
+			WithTransactionDate(time.Now()).
+			Build()
+		require.NoError(t, err)
+		return txn
+	}
+	failure := func(index int, sourceID string) dto.TransactionErrorDTO {
+		return dto.TransactionErrorDTO{
+			Transaction: dto.TransactionPostDTO{SourceID: sourceID},
+			Errors:      []dto.ValidationError{{Field: "portfolioId", Message: "invalid"}},
+			BatchIndex:  &index,
+		}
+	}
+
+	// Results arrive in processing order, which need not be the submitted order
+	successful := []IndexedTransaction{
+		{Index: 4, Transaction: deposit(14)},
+		{Index: 0, Transaction: deposit(10)},
+		{Index: 2, Transaction: deposit(12)},
+	}
+	failed := []dto.TransactionErrorDTO{failure(3, "SOURCE-D"), failure(1, "SOURCE-B")}
+
+	response := mapper.ToIndexedBatchResponse(successful, failed)
+
+	require.Len(t, response.Successful, 3)
+	for i, want := range []struct {
+		index int
+		id    int64
+	}{{0, 10}, {2, 12}, {4, 14}} {
+		require.NotNil(t, response.Successful[i].BatchIndex)
+		assert.Equal(t, want.index, *response.Successful[i].BatchIndex)
+		assert.Equal(t, want.id, response.Successful[i].ID, "each index stays with its transaction")
+	}
+
+	require.Len(t, response.Failed, 2)
+	assert.Equal(t, 1, *response.Failed[0].BatchIndex)
+	assert.Equal(t, "SOURCE-B", response.Failed[0].Transaction.SourceID)
+	assert.Equal(t, 3, *response.Failed[1].BatchIndex)
+	assert.Equal(t, "SOURCE-D", response.Failed[1].Transaction.SourceID)
+
+	assert.Equal(t, 5, response.Summary.TotalRequested)
+	assert.Equal(t, 4, successful[0].Index, "the input is not reordered")
+}
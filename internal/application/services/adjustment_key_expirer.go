@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// adjustmentKeyMeterName is the instrumentation scope of the adjustment key expiry metrics
+const adjustmentKeyMeterName = "globeco-portfolio-accounting-service/adjustment-keys"
+
+// AdjustmentKeyExpirer periodically releases balance adjustment idempotency keys older than
+// their maximum age, so the stored keys do not grow without bound. A released key is no longer
+// replayed: a request carrying it is applied as a new adjustment. Ledger entries are kept.
+type AdjustmentKeyExpirer interface {
+	// Lifecycle operations
+	Start(ctx context.Context)
+	Stop()
+
+	// RunOnce performs a single expiry pass
+	RunOnce(ctx context.Context) (*AdjustmentKeyExpiryResult, error)
+}
+
+// AdjustmentKeyExpirerConfig holds configuration for the adjustment key expirer
+type AdjustmentKeyExpirerConfig struct {
+	Interval time.Duration
+	// MaxAge is how long an adjustment key is replayed after the adjustment was recorded
+	MaxAge time.Duration
+	// BatchSize is the number of keys released per statement
+	BatchSize int
+	// MaxBatchesPerRun bounds the statements of a single pass; the rest waits for the next one
+	MaxBatchesPerRun int
+	// MeterProvider records the released and stored keys; nil uses the global provider
+	MeterProvider metric.MeterProvider
+}
+
+// AdjustmentKeyExpiryResult summarizes a single expiry pass
+type AdjustmentKeyExpiryResult struct {
+	Cutoff   time.Time `json:"cutoff"`
+	Batches  int       `json:"batches"`
+	Released int64     `json:"released"`
+	// Stored is the number of keys still held after the pass
+	Stored int64 `json:"stored"`
+}
+
+// adjustmentKeyExpirer implements AdjustmentKeyExpirer interface
+type adjustmentKeyExpirer struct {
+	adjustmentRepo repositories.BalanceAdjustmentRepository
+	config         AdjustmentKeyExpirerConfig
+	logger         logger.Logger
+	now            func() time.Time
+	released       metric.Int64Counter
+
+	// stored is the key count of the last pass, -1 until a pass has counted the keys
+	stored atomic.Int64
+
+	job *periodicJob
+}
+
+// NewAdjustmentKeyExpirer creates a new background adjustment key expirer
+func NewAdjustmentKeyExpirer(
+	adjustmentRepo repositories.BalanceAdjustmentRepository,
+	config AdjustmentKeyExpirerConfig,
+	lg logger.Logger,
+) AdjustmentKeyExpirer {
+	if lg == nil {
+		lg = logger.NewDevelopment()
+	}
+
+	// Set default configuration
+	if config.Interval == 0 {
+		config.Interval = time.Hour
+	}
+	if config.MaxAge == 0 {
+		config.MaxAge = 30 * 24 * time.Hour
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = 500
+	}
+	if config.MaxBatchesPerRun == 0 {
+		config.MaxBatchesPerRun = 20
+	}
+
+	provider := config.MeterProvider
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	meter := provider.Meter(adjustmentKeyMeterName)
+
+	released, err := meter.Int64Counter(
+		"balance_adjustment_keys_released_total",
+		metric.WithDescription("Total number of balance adjustment idempotency keys released after their maximum age"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		lg.Warn("Failed to create adjustment key release counter", logger.Err(err))
+	}
+
+	expirer := &adjustmentKeyExpirer{
+		adjustmentRepo: adjustmentRepo,
+		config:         config,
+		logger:         lg,
+		now:            time.Now,
+		released:       released,
+	}
+	expirer.stored.Store(-1)
+	expirer.registerStoredGauge(meter)
+	expirer.job = newPeriodicJob(config.Interval, func(ctx context.Context) error {
+		_, err := expirer.RunOnce(ctx)
+		return err
+	}, "Adjustment key expiry pass failed", lg)
+
+	return expirer
+}
+
+// registerStoredGauge exposes the key count of the last pass; nothing is observed until a pass
+// has counted the keys
+func (e *adjustmentKeyExpirer) registerStoredGauge(meter metric.Meter) {
+	stored, err := meter.Int64ObservableGauge(
+		"balance_adjustment_keys_stored",
+		metric.WithDescription("Balance adjustment idempotency keys held after the most recent expiry pass"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		e.logger.Warn("Failed to create stored adjustment keys gauge", logger.Err(err))
+		return
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+		if count := e.stored.Load(); count >= 0 {
+			observer.ObserveInt64(stored, count)
+		}
+		return nil
+	}, stored)
+	if err != nil {
+		e.logger.Warn("Failed to register stored adjustment keys callback", logger.Err(err))
+	}
+}
+
+// Start launches the periodic expiry loop until Stop is called or ctx is cancelled
+func (e *adjustmentKeyExpirer) Start(ctx context.Context) {
+	if !e.job.Start(ctx) {
+		return
+	}
+
+	e.logger.Info("Starting adjustment key expirer",
+		logger.String("interval", e.config.Interval.String()),
+		logger.String("maxAge", e.config.MaxAge.String()),
+		logger.Int("batchSize", e.config.BatchSize),
+		logger.Int("maxBatchesPerRun", e.config.MaxBatchesPerRun))
+}
+
+// Stop stops the expiry loop and waits for an in-flight pass to finish
+func (e *adjustmentKeyExpirer) Stop() {
+	if e.job.Stop() {
+		e.logger.Info("Adjustment key expirer stopped")
+	}
+}
+
+// RunOnce releases adjustment keys older than the maximum age in batches until none are left
+// or the pass has used its batches, then counts the keys still held. Keys already released stay
+// released if a later batch fails.
+func (e *adjustmentKeyExpirer) RunOnce(ctx context.Context) (*AdjustmentKeyExpiryResult, error) {
+	result := &AdjustmentKeyExpiryResult{Cutoff: e.now().UTC().Add(-e.config.MaxAge)}
+
+	for result.Batches < e.config.MaxBatchesPerRun {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		released, err := e.adjustmentRepo.ReleaseKeysBefore(ctx, result.Cutoff, e.config.BatchSize)
+		result.Batches++
+		result.Released += released
+		if e.released != nil && released > 0 {
+			e.released.Add(ctx, released)
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to release adjustment keys after %d releases: %w", result.Released, err)
+		}
+
+		if released < int64(e.config.BatchSize) {
+			break
+		}
+	}
+
+	stored, err := e.adjustmentRepo.CountKeys(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to count adjustment keys: %w", err)
+	}
+	result.Stored = stored
+	e.stored.Store(stored)
+
+	if result.Released > 0 {
+		e.logger.Info("Adjustment key expiry pass completed",
+			logger.String("cutoff", result.Cutoff.Format(time.RFC3339)),
+			logger.Int("batches", result.Batches),
+			logger.Int64("released", result.Released),
+			logger.Int64("stored", result.Stored))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/mappers"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	domainServices "github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+func TestAdjustmentKeyExpirer_RunOnce(t *testing.T) {
+	ctx := context.Background()
+	securityID := "SECURITY0000000000000001"
+	day := 24 * time.Hour
+
+	newFixture := func(config AdjustmentKeyExpirerConfig) (*memoryAdjustmentRepo, BalanceService, *adjustmentKeyExpirer) {
+		repo := newMemoryAdjustmentRepo(&repositories.Balance{
+			ID:           7,
+			PortfolioID:  testPortfolioID,
+			SecurityID:   &securityID,
+			QuantityLong: decimal.NewFromInt(100),
+			Version:      3,
+		})
+		service := NewBalanceService(nil, nil, repo, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(),
+			BalanceServiceConfig{AdjustmentKeyMaxAge: 30 * day}, logger.NewNoop())
+		config.MaxAge = 30 * day
+		expirer := NewAdjustmentKeyExpirer(repo, config, logger.NewNoop()).(*adjustmentKeyExpirer)
+		return repo, service, expirer
+	}
+	adjust := func(key string) dto.BalanceAdjustmentRequest {
+		return dto.BalanceAdjustmentRequest{
+			AdjustmentKey:     key,
+			PortfolioID:       testPortfolioID,
+			SecurityID:        &securityID,
+			QuantityLongDelta: decimal.NewFromInt(-25),
+			Reason:            "Custodian reconciliation break",
+			Operator:          "ops.user",
+		}
+	}
+	age := func(repo *memoryAdjustmentRepo, key string, by time.Duration) {
+		repo.adjustments[key].CreatedAt = repo.adjustments[key].CreatedAt.Add(-by)
+	}
+
+	t.Run("Expired keys are no longer replayed", func(t *testing.T) {
+		repo, service, expirer := newFixture(AdjustmentKeyExpirerConfig{})
+
+		first, err := service.AdjustBalance(ctx, adjust("ADJ-OLD"))
+		require.NoError(t, err)
+		require.True(t, first.Applied)
+		_, err = service.AdjustBalance(ctx, adjust("ADJ-NEW"))
+		require.NoError(t, err)
+		age(repo, "ADJ-OLD", 31*day)
+
+		again, err := service.AdjustBalance(ctx, adjust("ADJ-OLD"))
+		require.NoError(t, err)
+		assert.True(t, again.Applied, "a key past its maximum age is applied as a new adjustment before it is released")
+		assert.NotEqual(t, first.Adjustment.ID, again.Adjustment.ID)
+		assert.True(t, decimal.NewFromInt(25).Equal(again.Balance.QuantityLong))
+
+		recent, err := service.AdjustBalance(ctx, adjust("ADJ-NEW"))
+		require.NoError(t, err)
+		assert.False(t, recent.Applied, "keys within the maximum age are still replayed")
+
+		age(repo, "ADJ-NEW", 31*day)
+		result, err := expirer.RunOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), result.Released)
+		assert.Equal(t, int64(1), result.Stored)
+
+		released, err := service.AdjustBalance(ctx, adjust("ADJ-NEW"))
+		require.NoError(t, err)
+		assert.True(t, released.Applied, "a released key is applied as a new adjustment")
+	})
+
+	t.Run("Releases in batches up to the maximum of a pass", func(t *testing.T) {
+		repo, service, expirer := newFixture(AdjustmentKeyExpirerConfig{BatchSize: 1, MaxBatchesPerRun: 2})
+		for _, key := range []string{"ADJ-1", "ADJ-2", "ADJ-3"} {
+			_, err := service.AdjustBalance(ctx, adjust(key))
+			require.NoError(t, err)
+			age(repo, key, 40*day)
+		}
+
+		result, err := expirer.RunOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Released)
+		assert.Equal(t, 2, result.Batches)
+		assert.Equal(t, int64(1), result.Stored)
+
+		result, err = expirer.RunOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), result.Released)
+		assert.Equal(t, int64(0), result.Stored)
+	})
+
+	t.Run("Reports released and stored keys", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		repo, service, expirer := newFixture(AdjustmentKeyExpirerConfig{
+			MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		})
+
+		collect := func() map[string]int64 {
+			var collected metricdata.ResourceMetrics
+			require.NoError(t, reader.Collect(ctx, &collected))
+			values := make(map[string]int64)
+			for _, scope := range collected.ScopeMetrics {
+				for _, m := range scope.Metrics {
+					switch data := m.Data.(type) {
+					case metricdata.Sum[int64]:
+						for _, point := range data.DataPoints {
+							values[m.Name] += point.Value
+						}
+					case metricdata.Gauge[int64]:
+						for _, point := range data.DataPoints {
+							values[m.Name] = point.Value
+						}
+					}
+				}
+			}
+			return values
+		}
+
+		assert.NotContains(t, collect(), "balance_adjustment_keys_stored", "nothing is observed before the first pass")
+
+		for _, key := range []string{"ADJ-1", "ADJ-2", "ADJ-3"} {
+			_, err := service.AdjustBalance(ctx, adjust(key))
+			require.NoError(t, err)
+		}
+		age(repo, "ADJ-1", 40*day)
+
+		_, err := expirer.RunOnce(ctx)
+		require.NoError(t, err)
+
+		values := collect()
+		assert.Equal(t, int64(1), values["balance_adjustment_keys_released_total"])
+		assert.Equal(t, int64(2), values["balance_adjustment_keys_stored"])
+	})
+}
+
+func TestAdjustmentKeyExpirer_StartStop(t *testing.T) {
+	repo := newMemoryAdjustmentRepo()
+	expirer := NewAdjustmentKeyExpirer(repo, AdjustmentKeyExpirerConfig{Interval: 5 * time.Millisecond}, logger.NewNoop()).(*adjustmentKeyExpirer)
+
+	expirer.Start(context.Background())
+	expirer.Start(context.Background()) // already running
+	assert.Eventually(t, func() bool {
+		return expirer.stored.Load() == 0
+	}, time.Second, 5*time.Millisecond)
+
+	expirer.Stop()
+	expirer.Stop() // already stopped
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	errDiskFull := errors.New("no space left on device")
+
+	// failingWrite writes part of an error file and then fails, like a crash or full disk would
+	failingWrite := func(w io.Writer) error {
+		if _, err := fmt.Fprintln(w, "portfolio_id,source_id,error_message"); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprint(w, "PORTFOLIO123456789012345,SRC-1,inval"); err != nil {
+			return err
+		}
+		return errDiskFull
+	}
+
+	t.Run("Writes the complete file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "transactions-errors.csv")
+
+		require.NoError(t, WriteFileAtomic(path, func(w io.Writer) error {
+			_, err := io.WriteString(w, "header\nrecord\n")
+			return err
+		}))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "header\nrecord\n", string(data))
+	})
+
+	t.Run("Failed write leaves no partial file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "transactions-errors.csv")
+
+		err := WriteFileAtomic(path, failingWrite)
+		require.ErrorIs(t, err, errDiskFull)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "neither the error file nor a temporary file may remain")
+	})
+
+	t.Run("Failed rewrite keeps the previous file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "transactions-errors.csv")
+		require.NoError(t, os.WriteFile(path, []byte("header\nearlier record\n"), 0644))
+
+		require.ErrorIs(t, WriteFileAtomic(path, failingWrite), errDiskFull)
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "header\nearlier record\n", string(data))
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
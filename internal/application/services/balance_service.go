@@ -2,7 +2,10 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
@@ -19,11 +22,16 @@ type BalanceService interface {
 	// Balance query operations
 	GetBalance(ctx context.Context, id int64) (*dto.BalanceDTO, error)
 	GetBalances(ctx context.Context, filter dto.BalanceFilter) (*dto.BalanceListResponse, error)
+	CountBalances(ctx context.Context, filter dto.BalanceFilter) (int64, error)
 	GetBalancesByPortfolio(ctx context.Context, portfolioID string, pagination dto.PaginationRequest) (*dto.BalanceListResponse, error)
+	GetPortfolioBalance(ctx context.Context, portfolioID string, securityID *string) (*dto.BalanceDTO, error)
+	GetZeroBalances(ctx context.Context, olderThan time.Duration, pagination dto.PaginationRequest) (*dto.BalanceListResponse, error)
 
 	// Portfolio summary operations
-	GetPortfolioSummary(ctx context.Context, portfolioID string) (*dto.PortfolioSummaryDTO, error)
+	GetPortfolioSummary(ctx context.Context, portfolioID string, pagination dto.PaginationRequest) (*dto.PortfolioSummaryDTO, error)
 	GetPortfolioSummaries(ctx context.Context, filter dto.PortfolioSummaryFilter) ([]dto.PortfolioSummaryDTO, error)
+	GetPortfolioExposure(ctx context.Context, portfolioID string) (*dto.PortfolioExposureDTO, error)
+	GetTopPositions(ctx context.Context, by repositories.PositionRanking, limit int) (*dto.TopPositionsResponse, error)
 
 	// Balance statistics
 	GetBalanceStats(ctx context.Context, filter dto.BalanceFilter) (*dto.BalanceStatsDTO, error)
@@ -31,15 +39,64 @@ type BalanceService interface {
 	// Balance update operations
 	UpdateBalance(ctx context.Context, id int64, updateRequest dto.BalanceUpdateRequest) (*dto.BalanceUpdateResponse, error)
 	BulkUpdateBalances(ctx context.Context, bulkRequest dto.BulkBalanceUpdateRequest) (*dto.BulkBalanceUpdateResponse, error)
+	AdjustBalance(ctx context.Context, request dto.BalanceAdjustmentRequest) (*dto.BalanceAdjustmentResponse, error)
 
 	// Health and monitoring
 	GetServiceHealth(ctx context.Context) error
 }
 
+// ErrAdjustmentKeyConflict is returned when an adjustment key was already used for a different adjustment
+var ErrAdjustmentKeyConflict = errors.New("adjustment key already used for a different adjustment")
+
+// AdjustmentValidationError is returned when a balance adjustment request is invalid
+type AdjustmentValidationError struct {
+	Errors []dto.ValidationError
+}
+
+// Error implements the error interface
+func (e *AdjustmentValidationError) Error() string {
+	return fmt.Sprintf("adjustment validation failed: %d errors", len(e.Errors))
+}
+
+// BalanceUpdateValidationError is returned when a balance update request is invalid
+type BalanceUpdateValidationError struct {
+	Errors []dto.ValidationError
+}
+
+// Error implements the error interface
+func (e *BalanceUpdateValidationError) Error() string {
+	return fmt.Sprintf("validation failed: %d errors", len(e.Errors))
+}
+
+// SummaryBatchTooLargeError is returned when a summaries request names more portfolios than
+// MaxSummaryPortfolios
+type SummaryBatchTooLargeError struct {
+	Requested int
+	Max       int
+}
+
+// Error implements the error interface
+func (e *SummaryBatchTooLargeError) Error() string {
+	return fmt.Sprintf("too many portfolios requested: %d (maximum %d)", e.Requested, e.Max)
+}
+
+// InvalidBalanceIDError is returned when a portfolio or security ID of a balance lookup is not
+// exactly 24 characters
+type InvalidBalanceIDError struct {
+	Field string
+	Value string
+}
+
+// Error implements the error interface
+func (e *InvalidBalanceIDError) Error() string {
+	return fmt.Sprintf("invalid %s %q: must be exactly 24 characters", e.Field, e.Value)
+}
+
 // balanceService implements BalanceService interface
 type balanceService struct {
 	balanceRepo       repositories.BalanceRepository
 	transactionRepo   repositories.TransactionRepository
+	adjustmentRepo    repositories.BalanceAdjustmentRepository
 	balanceCalculator services.BalanceCalculator
 	balanceMapper     *mappers.BalanceMapper
 	config            BalanceServiceConfig
@@ -51,12 +108,34 @@ type BalanceServiceConfig struct {
 	MaxBulkUpdateSize    int
 	HistoryRetentionDays int
 	CacheTimeout         time.Duration
+	// DefaultSummarySecurities is the page of security positions a summary returns when no
+	// limit is requested; it defaults to MaxSummarySecurities
+	DefaultSummarySecurities int
+	// MaxSummarySecurities is the largest page of security positions a summary returns
+	MaxSummarySecurities int
+	// EmptySummaryNotFound reports a portfolio without balances as not found instead of
+	// returning a zeroed summary
+	EmptySummaryNotFound bool
+	// PriceLookupBatchSize is the number of securities priced per lookup by notional filters
+	PriceLookupBatchSize int
+	// MaxSummaryPortfolios is the largest number of portfolios one summaries request covers
+	MaxSummaryPortfolios int
+	// RequireAdjustmentReason rejects balance updates that do not give a reason
+	RequireAdjustmentReason bool
+	// MissingBalanceNotFound reports a portfolio without a balance in the requested security or
+	// cash as not found instead of returning a zeroed balance
+	MissingBalanceNotFound bool
+	// AdjustmentKeyMaxAge is how long an adjustment key is replayed; a request with an older key
+	// is applied as a new adjustment even before the key is released in the background. Zero
+	// replays keys until they are released.
+	AdjustmentKeyMaxAge time.Duration
 }
 
 // NewBalanceService creates a new balance application service
 func NewBalanceService(
 	balanceRepo repositories.BalanceRepository,
 	transactionRepo repositories.TransactionRepository,
+	adjustmentRepo repositories.BalanceAdjustmentRepository,
 	balanceCalculator services.BalanceCalculator,
 	balanceMapper *mappers.BalanceMapper,
 	config BalanceServiceConfig,
@@ -76,10 +155,23 @@ func NewBalanceService(
 	if config.CacheTimeout == 0 {
 		config.CacheTimeout = 15 * time.Minute
 	}
+	if config.MaxSummarySecurities == 0 {
+		config.MaxSummarySecurities = 1000
+	}
+	if config.DefaultSummarySecurities == 0 || config.DefaultSummarySecurities > config.MaxSummarySecurities {
+		config.DefaultSummarySecurities = config.MaxSummarySecurities
+	}
+	if config.PriceLookupBatchSize == 0 {
+		config.PriceLookupBatchSize = 500
+	}
+	if config.MaxSummaryPortfolios == 0 {
+		config.MaxSummaryPortfolios = 100
+	}
 
 	return &balanceService{
 		balanceRepo:       balanceRepo,
 		transactionRepo:   transactionRepo,
+		adjustmentRepo:    adjustmentRepo,
 		balanceCalculator: balanceCalculator,
 		balanceMapper:     balanceMapper,
 		config:            config,
@@ -119,6 +211,9 @@ func (s *balanceService) GetBalances(ctx context.Context, filter dto.BalanceFilt
 	if !filter.IsValid() {
 		return nil, fmt.Errorf("invalid filter parameters")
 	}
+	if _, err := filter.ResolveScope(); err != nil {
+		return nil, fmt.Errorf("invalid filter parameters: %w", err)
+	}
 
 	// Convert DTO filter to repository filter
 	repoFilter := s.convertDTOFilterToRepo(filter)
@@ -127,23 +222,31 @@ func (s *balanceService) GetBalances(ctx context.Context, filter dto.BalanceFilt
 	if repoFilter.Limit == 0 {
 		repoFilter.Limit = 50
 	}
-	if repoFilter.Limit > 1000 {
-		repoFilter.Limit = 1000
+	requestedLimit := repoFilter.Limit
+	if repoFilter.Limit > dto.MaxPageLimit {
+		repoFilter.Limit = dto.MaxPageLimit
+	}
+
+	if filter.HasNotionalFilter() {
+		response, err := s.getBalancesByNotional(ctx, filter, repoFilter)
+		if err != nil {
+			return nil, err
+		}
+		response.Pagination = response.Pagination.WithRequestedLimit(requestedLimit)
+		return response, nil
 	}
 
 	// Get balances from repository
 	repoBalances, err := s.balanceRepo.List(ctx, repoFilter)
 	if err != nil {
-		s.logger.Error("Failed to retrieve balances",
-			logger.Err(err))
+		logQueryError(s.logger, "Failed to retrieve balances", err)
 		return nil, fmt.Errorf("failed to retrieve balances: %w", err)
 	}
 
 	// Get total count for pagination
 	totalCount, err := s.balanceRepo.Count(ctx, repoFilter)
 	if err != nil {
-		s.logger.Error("Failed to count balances",
-			logger.Err(err))
+		logQueryError(s.logger, "Failed to count balances", err)
 		return nil, fmt.Errorf("failed to count balances: %w", err)
 	}
 
@@ -163,10 +266,152 @@ func (s *balanceService) GetBalances(ctx context.Context, filter dto.BalanceFilt
 			repoFilter.Limit,
 			repoFilter.Offset,
 			totalCount,
-		),
+		).WithRequestedLimit(requestedLimit),
 	}, nil
 }
 
+// CountBalances counts the balances matching a filter without loading them; pagination and
+// sorting of the filter are ignored. A notional filter still has to read and price the
+// matching positions, as listing does.
+func (s *balanceService) CountBalances(ctx context.Context, filter dto.BalanceFilter) (int64, error) {
+	if !filter.IsValid() {
+		return 0, fmt.Errorf("invalid filter parameters")
+	}
+	if _, err := filter.ResolveScope(); err != nil {
+		return 0, fmt.Errorf("invalid filter parameters: %w", err)
+	}
+
+	repoFilter := s.convertDTOFilterToRepo(filter)
+	repoFilter.Limit, repoFilter.Offset, repoFilter.SortBy = 0, 0, nil
+
+	if filter.HasNotionalFilter() {
+		repoFilter.Limit = 1
+		result, err := s.getBalancesByNotional(ctx, filter, repoFilter)
+		if err != nil {
+			return 0, err
+		}
+		return result.Pagination.Total, nil
+	}
+
+	count, err := s.balanceRepo.Count(ctx, repoFilter)
+	if err != nil {
+		logQueryError(s.logger, "Failed to count balances", err)
+		return 0, fmt.Errorf("failed to count balances: %w", err)
+	}
+
+	return count, nil
+}
+
+// notionalScanPageSize is the number of balances read per query while applying a notional filter
+const notionalScanPageSize = 1000
+
+// getBalancesByNotional applies a notional filter after the query: it reads every balance
+// matching the other filters page by page, prices each page's securities in batches and keeps
+// the positions whose quantityLong times reference price is within bounds. Pagination applies
+// to the matching positions; positions without a reference price are excluded and reported.
+func (s *balanceService) getBalancesByNotional(ctx context.Context, filter dto.BalanceFilter, repoFilter repositories.BalanceFilter) (*dto.BalanceListResponse, error) {
+	limit, offset := repoFilter.Limit, repoFilter.Offset
+
+	prices := make(map[string]decimal.Decimal)
+	looked := make(map[string]bool)
+	unpriced := make(map[string]bool)
+	var matched []*repositories.Balance
+
+	scan := repoFilter
+	scan.Limit = notionalScanPageSize
+	for scan.Offset = 0; ; scan.Offset += notionalScanPageSize {
+		page, err := s.balanceRepo.List(ctx, scan)
+		if err != nil {
+			logQueryError(s.logger, "Failed to retrieve balances", err)
+			return nil, fmt.Errorf("failed to retrieve balances: %w", err)
+		}
+
+		var toPrice []string
+		for _, balance := range page {
+			if balance.SecurityID != nil && !looked[*balance.SecurityID] {
+				looked[*balance.SecurityID] = true
+				toPrice = append(toPrice, *balance.SecurityID)
+			}
+		}
+		if err := s.lookupReferencePrices(ctx, toPrice, prices); err != nil {
+			return nil, err
+		}
+
+		for _, balance := range page {
+			if balance.SecurityID == nil {
+				continue
+			}
+			price, ok := prices[*balance.SecurityID]
+			if !ok {
+				unpriced[*balance.SecurityID] = true
+				continue
+			}
+			if filter.MatchesNotional(balance.QuantityLong.Mul(price)) {
+				matched = append(matched, balance)
+			}
+		}
+
+		if len(page) < notionalScanPageSize {
+			break
+		}
+	}
+
+	totalCount := int64(len(matched))
+	if offset < len(matched) {
+		matched = matched[offset:]
+	} else {
+		matched = nil
+	}
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+
+	unpricedIDs := make([]string, 0, len(unpriced))
+	for securityID := range unpriced {
+		unpricedIDs = append(unpricedIDs, securityID)
+	}
+	sort.Strings(unpricedIDs)
+
+	if len(unpricedIDs) > 0 {
+		s.logger.Warn("Notional filter excluded securities without a reference price",
+			logger.Int("unpricedSecurities", len(unpricedIDs)))
+	}
+
+	domainBalances := make([]*models.Balance, len(matched))
+	for i, repoBalance := range matched {
+		domainBalances[i] = s.convertRepoToDomain(repoBalance)
+	}
+
+	return &dto.BalanceListResponse{
+		Balances:            s.balanceMapper.ToDTOs(domainBalances),
+		Pagination:          dto.NewPaginationResponse(limit, offset, totalCount),
+		UnpricedSecurityIDs: unpricedIDs,
+	}, nil
+}
+
+// lookupReferencePrices adds the reference prices of the given securities to prices,
+// querying at most PriceLookupBatchSize securities at a time
+func (s *balanceService) lookupReferencePrices(ctx context.Context, securityIDs []string, prices map[string]decimal.Decimal) error {
+	for start := 0; start < len(securityIDs); start += s.config.PriceLookupBatchSize {
+		end := start + s.config.PriceLookupBatchSize
+		if end > len(securityIDs) {
+			end = len(securityIDs)
+		}
+
+		batch, err := s.transactionRepo.GetLatestPricesForSecurities(ctx, securityIDs[start:end])
+		if err != nil {
+			s.logger.Error("Failed to retrieve reference prices",
+				logger.Err(err),
+				logger.Int("securities", end-start))
+			return fmt.Errorf("failed to retrieve reference prices: %w", err)
+		}
+		for securityID, price := range batch {
+			prices[securityID] = price
+		}
+	}
+	return nil
+}
+
 // GetBalancesByPortfolio retrieves all balances for a specific portfolio
 func (s *balanceService) GetBalancesByPortfolio(ctx context.Context, portfolioID string, pagination dto.PaginationRequest) (*dto.BalanceListResponse, error) {
 	s.logger.Debug("Retrieving balances for portfolio",
@@ -181,29 +426,184 @@ func (s *balanceService) GetBalancesByPortfolio(ctx context.Context, portfolioID
 	return s.GetBalances(ctx, filter)
 }
 
-// GetPortfolioSummary retrieves a summary of balances for a portfolio
-func (s *balanceService) GetPortfolioSummary(ctx context.Context, portfolioID string) (*dto.PortfolioSummaryDTO, error) {
-	s.logger.Debug("Retrieving portfolio summary",
-		logger.String("portfolioId", portfolioID))
+// GetZeroBalances retrieves a page of the security balances with zero quantities, the
+// candidates of zero balance compaction, oldest first. With a positive olderThan only balances
+// left unchanged for at least that long are included. Cash balances and balances with manual
+// adjustments are never compacted and are left out.
+func (s *balanceService) GetZeroBalances(ctx context.Context, olderThan time.Duration, pagination dto.PaginationRequest) (*dto.BalanceListResponse, error) {
+	if olderThan < 0 {
+		return nil, fmt.Errorf("invalid zero balance age: %s", olderThan)
+	}
 
-	// Get all balances for the portfolio
 	repoFilter := repositories.BalanceFilter{
-		PortfolioID: &portfolioID,
-		Limit:       1000, // Get all balances for summary
+		Scope:            repositories.BalanceScopeSecuritiesOnly,
+		OnlyZeroBalances: true,
+		ExcludeAdjusted:  true,
+		Limit:            pagination.Limit,
+		Offset:           pagination.Offset,
+		SortBy:           []string{"last_updated", "id"},
 	}
+	if olderThan > 0 {
+		cutoff := time.Now().Add(-olderThan)
+		repoFilter.LastUpdatedTo = &cutoff
+	}
+
+	if repoFilter.Limit <= 0 {
+		repoFilter.Limit = 50
+	}
+	requestedLimit := repoFilter.Limit
+	if repoFilter.Limit > dto.MaxPageLimit {
+		repoFilter.Limit = dto.MaxPageLimit
+	}
+
+	s.logger.Debug("Retrieving zero balances",
+		logger.String("olderThan", olderThan.String()),
+		logger.Int("limit", repoFilter.Limit),
+		logger.Int("offset", repoFilter.Offset))
 
 	repoBalances, err := s.balanceRepo.List(ctx, repoFilter)
 	if err != nil {
-		s.logger.Error("Failed to retrieve portfolio balances",
+		logQueryError(s.logger, "Failed to retrieve zero balances", err)
+		return nil, fmt.Errorf("failed to retrieve zero balances: %w", err)
+	}
+
+	totalCount, err := s.balanceRepo.Count(ctx, repoFilter)
+	if err != nil {
+		logQueryError(s.logger, "Failed to count zero balances", err)
+		return nil, fmt.Errorf("failed to count zero balances: %w", err)
+	}
+
+	domainBalances := make([]*models.Balance, len(repoBalances))
+	for i, repoBalance := range repoBalances {
+		domainBalances[i] = s.convertRepoToDomain(repoBalance)
+	}
+
+	return &dto.BalanceListResponse{
+		Balances: s.balanceMapper.ToDTOs(domainBalances),
+		Pagination: dto.NewPaginationResponse(
+			repoFilter.Limit,
+			repoFilter.Offset,
+			totalCount,
+		).WithRequestedLimit(requestedLimit),
+	}, nil
+}
+
+// GetPortfolioBalance retrieves a portfolio's balance in one security, or its cash balance when
+// securityID is nil. A portfolio without that balance gets a zeroed balance with ID 0, unless
+// MissingBalanceNotFound is set. Both IDs must be exactly 24 characters.
+func (s *balanceService) GetPortfolioBalance(ctx context.Context, portfolioID string, securityID *string) (*dto.BalanceDTO, error) {
+	if len(portfolioID) != 24 {
+		return nil, &InvalidBalanceIDError{Field: "portfolioId", Value: portfolioID}
+	}
+	if securityID != nil && len(*securityID) != 24 {
+		return nil, &InvalidBalanceIDError{Field: "securityId", Value: *securityID}
+	}
+
+	s.logger.Debug("Retrieving portfolio balance",
+		logger.String("portfolioId", portfolioID),
+		logger.Bool("cash", securityID == nil))
+
+	repoBalance, err := s.balanceRepo.GetByPortfolioAndSecurity(ctx, portfolioID, securityID)
+	if err != nil {
+		if !repositories.IsNotFoundError(err) {
+			s.logger.Error("Failed to retrieve portfolio balance",
+				logger.Err(err),
+				logger.String("portfolioId", portfolioID))
+			return nil, fmt.Errorf("failed to retrieve portfolio balance: %w", err)
+		}
+
+		if s.config.MissingBalanceNotFound {
+			s.logger.Warn("Portfolio balance not found",
+				logger.String("portfolioId", portfolioID))
+			return nil, fmt.Errorf("balance not found for portfolio %s", portfolioID)
+		}
+		return &dto.BalanceDTO{
+			PortfolioID:   portfolioID,
+			SecurityID:    securityID,
+			QuantityLong:  decimal.Zero,
+			QuantityShort: decimal.Zero,
+		}, nil
+	}
+
+	return s.balanceMapper.ToDTO(s.convertRepoToDomain(repoBalance)), nil
+}
+
+// GetPortfolioSummary retrieves a summary of balances for a portfolio. Totals are aggregated
+// over all balances; only the security positions are paginated, DefaultSummarySecurities to a
+// page unless a limit is requested and never more than MaxSummarySecurities.
+func (s *balanceService) GetPortfolioSummary(ctx context.Context, portfolioID string, pagination dto.PaginationRequest) (*dto.PortfolioSummaryDTO, error) {
+	// Positions beyond a page the caller did not choose are cut off by configuration
+	configuredLimit := true
+	switch {
+	case pagination.Limit <= 0:
+		pagination.Limit = s.config.DefaultSummarySecurities
+	case pagination.Limit > s.config.MaxSummarySecurities:
+		pagination.Limit = s.config.MaxSummarySecurities
+	default:
+		configuredLimit = false
+	}
+	if pagination.Offset < 0 {
+		pagination.Offset = 0
+	}
+
+	s.logger.Debug("Retrieving portfolio summary",
+		logger.String("portfolioId", portfolioID),
+		logger.Int("limit", pagination.Limit),
+		logger.Int("offset", pagination.Offset))
+
+	// Aggregate totals server-side over the whole portfolio
+	totals, err := s.balanceRepo.GetPortfolioSummary(ctx, portfolioID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve portfolio totals",
 			logger.Err(err),
 			logger.String("portfolioId", portfolioID))
-		return nil, fmt.Errorf("failed to retrieve portfolio balances: %w", err)
+		return nil, fmt.Errorf("failed to retrieve portfolio totals: %w", err)
 	}
 
-	if len(repoBalances) == 0 {
-		s.logger.Warn("No balances found for portfolio",
+	if totals.TotalPositions == 0 {
+		if s.config.EmptySummaryNotFound {
+			s.logger.Warn("No balances found for portfolio",
+				logger.String("portfolioId", portfolioID))
+			return nil, fmt.Errorf("portfolio not found: no balances for portfolio %s", portfolioID)
+		}
+
+		s.logger.Debug("Portfolio has no balances, returning empty summary",
 			logger.String("portfolioId", portfolioID))
-		return nil, fmt.Errorf("no balances found for portfolio: %s", portfolioID)
+		return &dto.PortfolioSummaryDTO{
+			PortfolioID: portfolioID,
+			CashBalance: decimal.Zero,
+			Securities:  []dto.SecurityPositionDTO{},
+			Pagination:  dto.NewPaginationResponse(pagination.Limit, pagination.Offset, 0),
+		}, nil
+	}
+
+	securityFilter := repositories.BalanceFilter{
+		PortfolioID: &portfolioID,
+		Scope:       repositories.BalanceScopeSecuritiesOnly,
+	}
+
+	securityCount, err := s.balanceRepo.Count(ctx, securityFilter)
+	if err != nil {
+		s.logger.Error("Failed to count portfolio securities",
+			logger.Err(err),
+			logger.String("portfolioId", portfolioID))
+		return nil, fmt.Errorf("failed to count portfolio securities: %w", err)
+	}
+	if configuredLimit {
+		s.warnSummaryTruncated(portfolioID, securityCount, pagination.Offset, pagination.Limit)
+	}
+
+	// Get the requested page of security positions
+	securityFilter.Limit = pagination.Limit
+	securityFilter.Offset = pagination.Offset
+	securityFilter.SortBy = []string{"security_id", "id"}
+
+	repoBalances, err := s.balanceRepo.List(ctx, securityFilter)
+	if err != nil {
+		s.logger.Error("Failed to retrieve portfolio balances",
+			logger.Err(err),
+			logger.String("portfolioId", portfolioID))
+		return nil, fmt.Errorf("failed to retrieve portfolio balances: %w", err)
 	}
 
 	// Convert to domain balances
@@ -212,55 +612,219 @@ func (s *balanceService) GetPortfolioSummary(ctx context.Context, portfolioID st
 		domainBalances[i] = s.convertRepoToDomain(repoBalance)
 	}
 
-	// Create portfolio summary
+	// Create portfolio summary for the page, then apply the portfolio-wide totals
 	summary := s.balanceMapper.ToPortfolioSummaryDTO(portfolioID, domainBalances)
+	summary.CashBalance = totals.CashBalance
+	summary.SecurityCount = int(securityCount)
+	summary.LastUpdated = totals.LastUpdated
+	summary.Pagination = dto.NewPaginationResponse(pagination.Limit, pagination.Offset, securityCount)
 
 	s.logger.Debug("Portfolio summary created",
 		logger.String("portfolioId", portfolioID),
-		logger.Int("securityCount", summary.SecurityCount))
+		logger.Int("securityCount", summary.SecurityCount),
+		logger.Int("pageSize", len(summary.Securities)))
 
 	return summary, nil
 }
 
-// GetPortfolioSummaries retrieves summaries for multiple portfolios
-func (s *balanceService) GetPortfolioSummaries(ctx context.Context, filter dto.PortfolioSummaryFilter) ([]dto.PortfolioSummaryDTO, error) {
-	s.logger.Debug("Retrieving portfolio summaries with filter")
+// GetPortfolioExposure aggregates the long/short exposure of a portfolio's security positions.
+// Value terms use the latest processed price of each security when one is available.
+func (s *balanceService) GetPortfolioExposure(ctx context.Context, portfolioID string) (*dto.PortfolioExposureDTO, error) {
+	s.logger.Debug("Calculating portfolio exposure",
+		logger.String("portfolioId", portfolioID))
 
-	var portfolioIDs []string
-	if len(filter.PortfolioIDs) > 0 {
-		portfolioIDs = filter.PortfolioIDs
-	} else {
-		// Get distinct portfolio IDs if none specified
-		repoFilter := repositories.BalanceFilter{
-			Limit: 10000, // Large limit to get all portfolios
+	balances, err := s.balanceRepo.GetBalancesByPortfolio(ctx, portfolioID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve portfolio balances",
+			logger.Err(err),
+			logger.String("portfolioId", portfolioID))
+		return nil, fmt.Errorf("failed to retrieve portfolio balances: %w", err)
+	}
+
+	if len(balances) == 0 {
+		s.logger.Warn("No balances found for portfolio",
+			logger.String("portfolioId", portfolioID))
+		return nil, fmt.Errorf("no balances found for portfolio: %s", portfolioID)
+	}
+
+	prices, err := s.transactionRepo.GetLatestPrices(ctx, portfolioID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve latest prices",
+			logger.Err(err),
+			logger.String("portfolioId", portfolioID))
+		return nil, fmt.Errorf("failed to retrieve latest prices: %w", err)
+	}
+
+	exposure := &dto.PortfolioExposureDTO{
+		PortfolioID:  portfolioID,
+		TotalLong:    decimal.Zero,
+		TotalShort:   decimal.Zero,
+		CalculatedAt: time.Now(),
+	}
+	value := &dto.ExposureValueDTO{
+		Long:  decimal.Zero,
+		Short: decimal.Zero,
+	}
+
+	for _, balance := range balances {
+		// Cash is not exposure
+		if balance.SecurityID == nil {
+			continue
 		}
-		repoBalances, err := s.balanceRepo.List(ctx, repoFilter)
-		if err != nil {
-			return nil, fmt.Errorf("failed to retrieve balances: %w", err)
+
+		exposure.SecurityCount++
+		exposure.TotalLong = exposure.TotalLong.Add(balance.QuantityLong)
+		exposure.TotalShort = exposure.TotalShort.Add(balance.QuantityShort)
+
+		price, priced := prices[*balance.SecurityID]
+		if !priced {
+			value.UnpricedSecurities++
+			continue
 		}
+		value.PricedSecurities++
+		value.Long = value.Long.Add(balance.QuantityLong.Mul(price))
+		value.Short = value.Short.Add(balance.QuantityShort.Mul(price))
+	}
+
+	exposure.Gross = exposure.TotalLong.Add(exposure.TotalShort)
+	exposure.Net = exposure.TotalLong.Sub(exposure.TotalShort)
+
+	if value.PricedSecurities > 0 {
+		value.Gross = value.Long.Add(value.Short)
+		value.Net = value.Long.Sub(value.Short)
+		exposure.Value = value
+	}
+
+	return exposure, nil
+}
 
-		// Extract unique portfolio IDs
-		portfolioMap := make(map[string]bool)
-		for _, balance := range repoBalances {
-			portfolioMap[balance.PortfolioID] = true
+// GetTopPositions retrieves the largest security positions across all portfolios, ranked by
+// long quantity, short quantity or absolute net quantity
+func (s *balanceService) GetTopPositions(ctx context.Context, by repositories.PositionRanking, limit int) (*dto.TopPositionsResponse, error) {
+	if !by.IsValid() {
+		return nil, fmt.Errorf("invalid position ranking: %q", by)
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid top positions limit: %d", limit)
+	}
+
+	s.logger.Debug("Retrieving top positions",
+		logger.String("by", by.String()),
+		logger.Int("limit", limit))
+
+	repoBalances, err := s.balanceRepo.GetTopPositions(ctx, by, limit)
+	if err != nil {
+		s.logger.Error("Failed to retrieve top positions",
+			logger.Err(err),
+			logger.String("by", by.String()))
+		return nil, fmt.Errorf("failed to retrieve top positions: %w", err)
+	}
+
+	domainBalances := make([]*models.Balance, len(repoBalances))
+	for i, repoBalance := range repoBalances {
+		domainBalances[i] = s.convertRepoToDomain(repoBalance)
+	}
+
+	return &dto.TopPositionsResponse{
+		By:        by.String(),
+		Limit:     limit,
+		Positions: s.balanceMapper.ToDTOs(domainBalances),
+	}, nil
+}
+
+// GetPortfolioSummaries retrieves the summaries of the requested portfolios, or of a page of all
+// portfolios when none are named. Totals come from one grouped query and the security positions
+// of every summarized portfolio from one list query, each cut to DefaultSummarySecurities.
+func (s *balanceService) GetPortfolioSummaries(ctx context.Context, filter dto.PortfolioSummaryFilter) ([]dto.PortfolioSummaryDTO, error) {
+	portfolioIDs := uniquePortfolioIDs(filter.PortfolioIDs)
+	if len(portfolioIDs) > s.config.MaxSummaryPortfolios {
+		return nil, &SummaryBatchTooLargeError{Requested: len(portfolioIDs), Max: s.config.MaxSummaryPortfolios}
+	}
+
+	// Named portfolios are all summarized; otherwise page through every portfolio
+	limit, offset := 0, 0
+	if len(portfolioIDs) == 0 {
+		limit, offset = filter.Pagination.Limit, filter.Pagination.Offset
+		if limit <= 0 || limit > s.config.MaxSummaryPortfolios {
+			limit = s.config.MaxSummaryPortfolios
 		}
+		if offset < 0 {
+			offset = 0
+		}
+	}
 
-		portfolioIDs = make([]string, 0, len(portfolioMap))
-		for portfolioID := range portfolioMap {
-			portfolioIDs = append(portfolioIDs, portfolioID)
+	s.logger.Debug("Retrieving portfolio summaries",
+		logger.Int("requestedPortfolios", len(portfolioIDs)),
+		logger.Int("limit", limit),
+		logger.Int("offset", offset))
+
+	totals, err := s.balanceRepo.GetPortfolioSummaries(ctx, portfolioIDs, limit, offset)
+	if err != nil {
+		s.logger.Error("Failed to retrieve portfolio totals", logger.Err(err))
+		return nil, fmt.Errorf("failed to retrieve portfolio totals: %w", err)
+	}
+
+	totalsByPortfolio := make(map[string]*repositories.PortfolioSummary, len(totals))
+	summarizedIDs := make([]string, 0, len(totals))
+	for _, total := range totals {
+		totalsByPortfolio[total.PortfolioID] = total
+		summarizedIDs = append(summarizedIDs, total.PortfolioID)
+	}
+	if len(portfolioIDs) == 0 {
+		portfolioIDs = summarizedIDs
+	}
+
+	// Load the security positions of every summarized portfolio at once
+	securitiesByPortfolio := make(map[string][]*models.Balance, len(summarizedIDs))
+	if len(summarizedIDs) > 0 {
+		repoBalances, err := s.balanceRepo.List(ctx, repositories.BalanceFilter{
+			PortfolioIDs: summarizedIDs,
+			Scope:        repositories.BalanceScopeSecuritiesOnly,
+			SortBy:       []string{"portfolio_id", "security_id", "id"},
+		})
+		if err != nil {
+			s.logger.Error("Failed to retrieve portfolio balances", logger.Err(err))
+			return nil, fmt.Errorf("failed to retrieve portfolio balances: %w", err)
+		}
+		for _, repoBalance := range repoBalances {
+			securitiesByPortfolio[repoBalance.PortfolioID] = append(securitiesByPortfolio[repoBalance.PortfolioID], s.convertRepoToDomain(repoBalance))
 		}
 	}
 
-	// Get summaries for each portfolio
 	summaries := make([]dto.PortfolioSummaryDTO, 0, len(portfolioIDs))
 	for _, portfolioID := range portfolioIDs {
-		summary, err := s.GetPortfolioSummary(ctx, portfolioID)
-		if err != nil {
-			s.logger.Warn("Failed to get portfolio summary",
-				logger.String("portfolioId", portfolioID),
-				logger.Err(err))
+		total, ok := totalsByPortfolio[portfolioID]
+		if !ok {
+			if s.config.EmptySummaryNotFound {
+				s.logger.Warn("No balances found for portfolio",
+					logger.String("portfolioId", portfolioID))
+				continue
+			}
+			summaries = append(summaries, dto.PortfolioSummaryDTO{
+				PortfolioID: portfolioID,
+				CashBalance: decimal.Zero,
+				Securities:  []dto.SecurityPositionDTO{},
+				Pagination:  dto.NewPaginationResponse(s.config.DefaultSummarySecurities, 0, 0),
+			})
 			continue
 		}
+
+		// A portfolio holding only cash has no security positions
+		securities := securitiesByPortfolio[portfolioID]
+		if securities == nil {
+			securities = []*models.Balance{}
+		}
+		securityCount := len(securities)
+		s.warnSummaryTruncated(portfolioID, int64(securityCount), 0, s.config.DefaultSummarySecurities)
+		if len(securities) > s.config.DefaultSummarySecurities {
+			securities = securities[:s.config.DefaultSummarySecurities]
+		}
+
+		summary := s.balanceMapper.ToPortfolioSummaryDTO(portfolioID, securities)
+		summary.CashBalance = total.CashBalance
+		summary.SecurityCount = securityCount
+		summary.LastUpdated = total.LastUpdated
+		summary.Pagination = dto.NewPaginationResponse(s.config.DefaultSummarySecurities, 0, int64(securityCount))
 		summaries = append(summaries, *summary)
 	}
 
@@ -270,6 +834,35 @@ func (s *balanceService) GetPortfolioSummaries(ctx context.Context, filter dto.P
 	return summaries, nil
 }
 
+// warnSummaryTruncated logs when a summary page sized by configuration leaves security
+// positions out, so operators know to raise the summary limits
+func (s *balanceService) warnSummaryTruncated(portfolioID string, securityCount int64, offset, limit int) {
+	if securityCount <= int64(offset+limit) {
+		return
+	}
+	s.logger.Warn("Portfolio summary truncated at the configured security limit",
+		logger.String("portfolioId", portfolioID),
+		logger.Int64("securityCount", securityCount),
+		logger.Int("offset", offset),
+		logger.Int("limit", limit),
+		logger.Int("defaultSummarySecurities", s.config.DefaultSummarySecurities),
+		logger.Int("maxSummarySecurities", s.config.MaxSummarySecurities))
+}
+
+// uniquePortfolioIDs drops repeated portfolio IDs, keeping the first occurrence of each
+func uniquePortfolioIDs(portfolioIDs []string) []string {
+	seen := make(map[string]bool, len(portfolioIDs))
+	unique := make([]string, 0, len(portfolioIDs))
+	for _, portfolioID := range portfolioIDs {
+		if seen[portfolioID] {
+			continue
+		}
+		seen[portfolioID] = true
+		unique = append(unique, portfolioID)
+	}
+	return unique
+}
+
 // GetBalanceStats retrieves balance statistics
 func (s *balanceService) GetBalanceStats(ctx context.Context, filter dto.BalanceFilter) (*dto.BalanceStatsDTO, error) {
 	s.logger.Debug("Retrieving balance statistics")
@@ -280,8 +873,7 @@ func (s *balanceService) GetBalanceStats(ctx context.Context, filter dto.Balance
 	// Get total count
 	totalCount, err := s.balanceRepo.Count(ctx, repoFilter)
 	if err != nil {
-		s.logger.Error("Failed to count balances",
-			logger.Err(err))
+		logQueryError(s.logger, "Failed to count balances", err)
 		return nil, fmt.Errorf("failed to count balances: %w", err)
 	}
 
@@ -329,18 +921,26 @@ func (s *balanceService) GetBalanceStats(ctx context.Context, filter dto.Balance
 	return statsDTO, nil
 }
 
-// UpdateBalance updates a single balance
+// UpdateBalance updates a single balance. The update's reason is logged with the quantities
+// before and after it, so manual changes can be attributed.
 func (s *balanceService) UpdateBalance(ctx context.Context, id int64, updateRequest dto.BalanceUpdateRequest) (*dto.BalanceUpdateResponse, error) {
 	s.logger.Info("Updating balance",
-		logger.Int64("balanceId", id))
+		logger.Int64("balanceId", id),
+		logger.String("reason", updateRequest.Reason))
 
 	// Validate update request
 	validationErrors := s.balanceMapper.ValidateBalanceUpdateRequest(&updateRequest)
+	if s.config.RequireAdjustmentReason && strings.TrimSpace(updateRequest.Reason) == "" {
+		validationErrors = append(validationErrors, dto.ValidationError{
+			Field:   "reason",
+			Message: "is required",
+		})
+	}
 	if len(validationErrors) > 0 {
 		s.logger.Warn("Balance update validation failed",
 			logger.Int("errorCount", len(validationErrors)),
 			logger.Int64("balanceId", id))
-		return nil, fmt.Errorf("validation failed: %d errors", len(validationErrors))
+		return nil, &BalanceUpdateValidationError{Errors: validationErrors}
 	}
 
 	// Get current balance
@@ -349,16 +949,26 @@ func (s *balanceService) UpdateBalance(ctx context.Context, id int64, updateRequ
 		return nil, fmt.Errorf("failed to get current balance: %w", err)
 	}
 
+	// The update applies only to the version the caller last read; the repository update
+	// repeats the check so a concurrent change between read and write is also rejected
+	if currentRepoBalance.Version != updateRequest.Version {
+		s.logger.Warn("Balance update version mismatch",
+			logger.Int64("balanceId", id),
+			logger.Int("expectedVersion", updateRequest.Version),
+			logger.Int("currentVersion", currentRepoBalance.Version))
+		return nil, repositories.NewOptimisticLockError("balance", id, updateRequest.Version, currentRepoBalance.Version)
+	}
+
 	// Keep copy of previous balance for response
 	previousBalance := s.convertRepoToDomain(currentRepoBalance)
 
-	// Update balance fields
+	// Update balance fields; a quantity equal to the current one is not a change
 	wasUpdated := false
-	if updateRequest.QuantityLong != nil {
+	if updateRequest.QuantityLong != nil && !updateRequest.QuantityLong.Equal(currentRepoBalance.QuantityLong) {
 		currentRepoBalance.QuantityLong = *updateRequest.QuantityLong
 		wasUpdated = true
 	}
-	if updateRequest.QuantityShort != nil {
+	if updateRequest.QuantityShort != nil && !updateRequest.QuantityShort.Equal(currentRepoBalance.QuantityShort) {
 		currentRepoBalance.QuantityShort = *updateRequest.QuantityShort
 		wasUpdated = true
 	}
@@ -381,7 +991,14 @@ func (s *balanceService) UpdateBalance(ctx context.Context, id int64, updateRequ
 	}
 
 	s.logger.Info("Balance updated successfully",
-		logger.Int64("balanceId", id))
+		logger.Int64("balanceId", id),
+		logger.String("portfolioId", currentRepoBalance.PortfolioID),
+		logger.String("quantityLongBefore", previousBalance.QuantityLong().String()),
+		logger.String("quantityShortBefore", previousBalance.QuantityShort().String()),
+		logger.String("quantityLongAfter", currentRepoBalance.QuantityLong.String()),
+		logger.String("quantityShortAfter", currentRepoBalance.QuantityShort.String()),
+		logger.Int("version", currentRepoBalance.Version),
+		logger.String("reason", updateRequest.Reason))
 
 	updatedBalance := s.convertRepoToDomain(currentRepoBalance)
 	return &dto.BalanceUpdateResponse{
@@ -415,10 +1032,17 @@ func (s *balanceService) BulkUpdateBalances(ctx context.Context, bulkRequest dto
 			QuantityLong:  updateItem.QuantityLong,
 			QuantityShort: updateItem.QuantityShort,
 			Version:       updateItem.Version,
+			Reason:        updateItem.Reason,
 		}
 
 		updateResponse, err := s.UpdateBalance(ctx, updateItem.BalanceID, updateRequest)
-		if err != nil {
+		var validationErr *BalanceUpdateValidationError
+		if errors.As(err, &validationErr) {
+			failed = append(failed, dto.BalanceUpdateError{
+				BalanceID: updateItem.BalanceID,
+				Errors:    validationErr.Errors,
+			})
+		} else if err != nil {
 			failed = append(failed, dto.BalanceUpdateError{
 				BalanceID: updateItem.BalanceID,
 				Errors: []dto.ValidationError{{
@@ -432,14 +1056,126 @@ func (s *balanceService) BulkUpdateBalances(ctx context.Context, bulkRequest dto
 		}
 	}
 
+	batchResponse := s.balanceMapper.ToBatchUpdateResponse(successful, failed)
+
 	s.logger.Info("Bulk balance update completed",
-		logger.Int("successful", len(successful)),
-		logger.Int("failed", len(failed)))
+		logger.Int("updated", batchResponse.Summary.Updated),
+		logger.Int("unchanged", batchResponse.Summary.Unchanged),
+		logger.Int("failed", batchResponse.Summary.Failed))
 
-	batchResponse := s.balanceMapper.ToBatchUpdateResponse(successful, failed)
 	return &batchResponse, nil
 }
 
+// AdjustBalance records a manual balance adjustment in the adjustment ledger and applies it to
+// the balance. Repeating a request with the same adjustment key returns the recorded adjustment
+// without changing the balance again, unless the key is older than AdjustmentKeyMaxAge.
+func (s *balanceService) AdjustBalance(ctx context.Context, request dto.BalanceAdjustmentRequest) (*dto.BalanceAdjustmentResponse, error) {
+	s.logger.Info("Adjusting balance",
+		logger.String("adjustmentKey", request.AdjustmentKey),
+		logger.String("portfolioId", request.PortfolioID),
+		logger.String("operator", request.Operator))
+
+	if validationErrors := s.balanceMapper.ValidateBalanceAdjustmentRequest(&request); len(validationErrors) > 0 {
+		s.logger.Warn("Balance adjustment validation failed",
+			logger.Int("errorCount", len(validationErrors)),
+			logger.String("adjustmentKey", request.AdjustmentKey))
+		return nil, &AdjustmentValidationError{Errors: validationErrors}
+	}
+
+	adjustment := newBalanceAdjustment(&request)
+	balance, applied, err := s.adjustmentRepo.Apply(ctx, adjustment, request.ExpectedVersion)
+	if err == nil && !applied && s.adjustmentKeyExpired(adjustment) {
+		// The key is past its maximum age but not released yet; a concurrent request may release
+		// it first, applying again then replays that request's adjustment
+		s.logger.Info("Releasing expired adjustment key",
+			logger.String("adjustmentKey", request.AdjustmentKey),
+			logger.Int64("adjustmentId", adjustment.ID))
+		if _, err = s.adjustmentRepo.ReleaseKey(ctx, request.AdjustmentKey, time.Now().Add(-s.config.AdjustmentKeyMaxAge)); err == nil {
+			adjustment = newBalanceAdjustment(&request)
+			balance, applied, err = s.adjustmentRepo.Apply(ctx, adjustment, request.ExpectedVersion)
+		}
+	}
+	if err != nil {
+		if repositories.IsOptimisticLockError(err) {
+			s.logger.Warn("Balance adjustment version conflict",
+				logger.String("adjustmentKey", request.AdjustmentKey),
+				logger.Err(err))
+		} else {
+			s.logger.Error("Failed to apply balance adjustment",
+				logger.Err(err),
+				logger.String("adjustmentKey", request.AdjustmentKey))
+		}
+		return nil, fmt.Errorf("failed to apply balance adjustment: %w", err)
+	}
+
+	if !applied && !sameAdjustment(adjustment, &request) {
+		s.logger.Warn("Adjustment key reused for a different adjustment",
+			logger.String("adjustmentKey", request.AdjustmentKey),
+			logger.Int64("adjustmentId", adjustment.ID))
+		return nil, ErrAdjustmentKeyConflict
+	}
+
+	return &dto.BalanceAdjustmentResponse{
+		Adjustment: toBalanceAdjustmentDTO(adjustment),
+		Balance:    *s.balanceMapper.ToDTO(s.convertRepoToDomain(balance)),
+		Applied:    applied,
+	}, nil
+}
+
+// newBalanceAdjustment returns the ledger entry of an adjustment request
+func newBalanceAdjustment(request *dto.BalanceAdjustmentRequest) *repositories.BalanceAdjustment {
+	return &repositories.BalanceAdjustment{
+		AdjustmentKey:      request.AdjustmentKey,
+		PortfolioID:        request.PortfolioID,
+		SecurityID:         request.SecurityID,
+		QuantityLongDelta:  request.QuantityLongDelta,
+		QuantityShortDelta: request.QuantityShortDelta,
+		Reason:             request.Reason,
+		Operator:           request.Operator,
+	}
+}
+
+// adjustmentKeyExpired reports whether a recorded adjustment's key is past AdjustmentKeyMaxAge
+func (s *balanceService) adjustmentKeyExpired(recorded *repositories.BalanceAdjustment) bool {
+	return s.config.AdjustmentKeyMaxAge > 0 && time.Since(recorded.CreatedAt) > s.config.AdjustmentKeyMaxAge
+}
+
+// sameAdjustment reports whether a recorded adjustment matches the target and deltas of a request
+func sameAdjustment(recorded *repositories.BalanceAdjustment, request *dto.BalanceAdjustmentRequest) bool {
+	if recorded.PortfolioID != request.PortfolioID {
+		return false
+	}
+	if (recorded.SecurityID == nil) != (request.SecurityID == nil) {
+		return false
+	}
+	if recorded.SecurityID != nil && *recorded.SecurityID != *request.SecurityID {
+		return false
+	}
+	return recorded.QuantityLongDelta.Equal(request.QuantityLongDelta) &&
+		recorded.QuantityShortDelta.Equal(request.QuantityShortDelta)
+}
+
+// toBalanceAdjustmentDTO converts a ledger entry to its DTO
+func toBalanceAdjustmentDTO(adjustment *repositories.BalanceAdjustment) dto.BalanceAdjustmentDTO {
+	return dto.BalanceAdjustmentDTO{
+		ID:                  adjustment.ID,
+		AdjustmentKey:       adjustment.AdjustmentKey,
+		BalanceID:           adjustment.BalanceID,
+		PortfolioID:         adjustment.PortfolioID,
+		SecurityID:          adjustment.SecurityID,
+		QuantityLongDelta:   adjustment.QuantityLongDelta,
+		QuantityShortDelta:  adjustment.QuantityShortDelta,
+		QuantityLongBefore:  adjustment.QuantityLongBefore,
+		QuantityShortBefore: adjustment.QuantityShortBefore,
+		QuantityLongAfter:   adjustment.QuantityLongAfter,
+		QuantityShortAfter:  adjustment.QuantityShortAfter,
+		BalanceVersion:      adjustment.BalanceVersion,
+		Reason:              adjustment.Reason,
+		Operator:            adjustment.Operator,
+		CreatedAt:           adjustment.CreatedAt,
+	}
+}
+
 // GetServiceHealth checks the health of the balance service
 func (s *balanceService) GetServiceHealth(ctx context.Context) error {
 	s.logger.Debug("Checking balance service health")
@@ -516,6 +1252,12 @@ func (s *balanceService) convertDTOFilterToRepo(dtoFilter dto.BalanceFilter) rep
 	if dtoFilter.MaxQuantityShort != nil {
 		repoFilter.QuantityShortMax = dtoFilter.MaxQuantityShort
 	}
+	if dtoFilter.MinAbsQuantityLong != nil {
+		repoFilter.QuantityLongAbsMin = dtoFilter.MinAbsQuantityLong
+	}
+	if dtoFilter.MinAbsQuantityShort != nil {
+		repoFilter.QuantityShortAbsMin = dtoFilter.MinAbsQuantityShort
+	}
 
 	// Convert date filters
 	if dtoFilter.LastUpdatedFrom != nil {
@@ -525,6 +1267,11 @@ func (s *balanceService) convertDTOFilterToRepo(dtoFilter dto.BalanceFilter) rep
 		repoFilter.LastUpdatedTo = dtoFilter.LastUpdatedTo
 	}
 
+	// Convert cash vs security scope (contradictions are rejected before conversion)
+	if scope, err := dtoFilter.ResolveScope(); err == nil && scope != dto.BalanceScopeAll {
+		repoFilter.Scope = repositories.BalanceScope(scope)
+	}
+
 	// Convert boolean filters
 	if dtoFilter.ZeroBalancesOnly != nil && *dtoFilter.ZeroBalancesOnly {
 		zero := decimal.Zero
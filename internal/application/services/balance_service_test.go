@@ -0,0 +1,1321 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/mappers"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	domainServices "github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// summaryBalanceRepo serves a fixed set of balances for portfolio summary queries
+type summaryBalanceRepo struct {
+	repositories.BalanceRepository
+
+	balances []*repositories.Balance
+}
+
+func (r *summaryBalanceRepo) matching(filter repositories.BalanceFilter) []*repositories.Balance {
+	var result []*repositories.Balance
+	for _, balance := range r.balances {
+		if filter.PortfolioID != nil && balance.PortfolioID != *filter.PortfolioID {
+			continue
+		}
+		if len(filter.PortfolioIDs) > 0 && !containsString(filter.PortfolioIDs, balance.PortfolioID) {
+			continue
+		}
+		if filter.Scope == repositories.BalanceScopeSecuritiesOnly && balance.SecurityID == nil {
+			continue
+		}
+		if filter.OnlyZeroBalances && !(balance.QuantityLong.IsZero() && balance.QuantityShort.IsZero()) {
+			continue
+		}
+		if filter.LastUpdatedTo != nil && balance.LastUpdated.After(*filter.LastUpdatedTo) {
+			continue
+		}
+		clone := *balance
+		result = append(result, &clone)
+	}
+	return result
+}
+
+func (r *summaryBalanceRepo) Count(ctx context.Context, filter repositories.BalanceFilter) (int64, error) {
+	return int64(len(r.matching(filter))), nil
+}
+
+func (r *summaryBalanceRepo) List(ctx context.Context, filter repositories.BalanceFilter) ([]*repositories.Balance, error) {
+	result := r.matching(filter)
+	sort.Slice(result, func(i, j int) bool {
+		return *result[i].SecurityID < *result[j].SecurityID
+	})
+
+	if filter.Offset >= len(result) {
+		return nil, nil
+	}
+	result = result[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(result) {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+func (r *summaryBalanceRepo) GetBalancesByPortfolio(ctx context.Context, portfolioID string) ([]*repositories.Balance, error) {
+	return r.matching(repositories.BalanceFilter{PortfolioID: &portfolioID}), nil
+}
+
+func (r *summaryBalanceRepo) GetPortfolioSummary(ctx context.Context, portfolioID string) (*repositories.PortfolioSummary, error) {
+	summary := &repositories.PortfolioSummary{PortfolioID: portfolioID, CashBalance: decimal.Zero}
+	for _, balance := range r.matching(repositories.BalanceFilter{PortfolioID: &portfolioID}) {
+		summary.TotalPositions++
+		if balance.SecurityID == nil {
+			summary.CashBalance = balance.QuantityLong
+		}
+		if balance.LastUpdated.After(summary.LastUpdated) {
+			summary.LastUpdated = balance.LastUpdated
+		}
+	}
+	return summary, nil
+}
+
+func newSummaryFixture(securities int) *summaryBalanceRepo {
+	base := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	repo := &summaryBalanceRepo{
+		balances: []*repositories.Balance{{
+			ID:            1,
+			PortfolioID:   testPortfolioID,
+			QuantityLong:  decimal.NewFromInt(5000),
+			QuantityShort: decimal.Zero,
+			Version:       1,
+			CreatedAt:     base,
+			LastUpdated:   base,
+		}},
+	}
+
+	for i := 0; i < securities; i++ {
+		securityID := fmt.Sprintf("SECURITY%016d", i)
+		repo.balances = append(repo.balances, &repositories.Balance{
+			ID:            int64(i + 2),
+			PortfolioID:   testPortfolioID,
+			SecurityID:    &securityID,
+			QuantityLong:  decimal.NewFromInt(int64(i + 1)),
+			QuantityShort: decimal.Zero,
+			Version:       1,
+			CreatedAt:     base,
+			LastUpdated:   base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	return repo
+}
+
+func TestBalanceService_GetPortfolioSummary_PaginatesSecurities(t *testing.T) {
+	ctx := context.Background()
+	repo := newSummaryFixture(25)
+	service := NewBalanceService(repo, nil, nil, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(),
+		BalanceServiceConfig{MaxSummarySecurities: 10}, logger.NewNoop())
+
+	lastUpdated := time.Date(2024, 1, 2, 0, 24, 0, 0, time.UTC)
+
+	t.Run("Defaults to the maximum page size", func(t *testing.T) {
+		summary, err := service.GetPortfolioSummary(ctx, testPortfolioID, dto.PaginationRequest{})
+		require.NoError(t, err)
+
+		assert.Len(t, summary.Securities, 10)
+		assert.Equal(t, 25, summary.SecurityCount)
+		assert.True(t, decimal.NewFromInt(5000).Equal(summary.CashBalance))
+		assert.True(t, lastUpdated.Equal(summary.LastUpdated))
+		assert.Equal(t, 10, summary.Pagination.Limit)
+		assert.Equal(t, int64(25), summary.Pagination.Total)
+		assert.True(t, summary.Pagination.HasMore)
+		assert.Equal(t, 3, summary.Pagination.TotalPages)
+	})
+
+	t.Run("Last page keeps portfolio-wide totals", func(t *testing.T) {
+		summary, err := service.GetPortfolioSummary(ctx, testPortfolioID, dto.PaginationRequest{Limit: 10, Offset: 20})
+		require.NoError(t, err)
+
+		require.Len(t, summary.Securities, 5)
+		assert.Equal(t, "SECURITY0000000000000020", summary.Securities[0].SecurityID)
+		assert.Equal(t, 25, summary.SecurityCount)
+		assert.True(t, decimal.NewFromInt(5000).Equal(summary.CashBalance))
+		assert.True(t, lastUpdated.Equal(summary.LastUpdated))
+		assert.False(t, summary.Pagination.HasMore)
+		assert.Equal(t, 3, summary.Pagination.Page)
+	})
+
+	t.Run("Pages cover every security exactly once", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for offset := 0; offset < 25; offset += 7 {
+			summary, err := service.GetPortfolioSummary(ctx, testPortfolioID, dto.PaginationRequest{Limit: 7, Offset: offset})
+			require.NoError(t, err)
+			for _, position := range summary.Securities {
+				assert.False(t, seen[position.SecurityID])
+				seen[position.SecurityID] = true
+			}
+		}
+		assert.Len(t, seen, 25)
+	})
+
+	t.Run("Limit above the maximum is capped", func(t *testing.T) {
+		summary, err := service.GetPortfolioSummary(ctx, testPortfolioID, dto.PaginationRequest{Limit: 500})
+		require.NoError(t, err)
+		assert.Len(t, summary.Securities, 10)
+		assert.Equal(t, 10, summary.Pagination.Limit)
+	})
+
+	t.Run("Unknown portfolio", func(t *testing.T) {
+		summary, err := service.GetPortfolioSummary(ctx, "PORTFOLIO000000000000000", dto.PaginationRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, 0, summary.SecurityCount)
+		assert.Empty(t, summary.Securities)
+	})
+}
+
+func TestBalanceService_GetPortfolioSummary_ConfiguredLimits(t *testing.T) {
+	ctx := context.Background()
+	newService := func(config BalanceServiceConfig) (BalanceService, *observer.ObservedLogs) {
+		core, logs := observer.New(zapcore.WarnLevel)
+		service := NewBalanceService(newSummaryFixture(25), nil, nil, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(),
+			config, logger.NewFromZap(zap.New(core)))
+		return service, logs
+	}
+	truncationWarnings := func(logs *observer.ObservedLogs) int {
+		return logs.FilterMessage("Portfolio summary truncated at the configured security limit").Len()
+	}
+
+	t.Run("Default page applies without a limit", func(t *testing.T) {
+		service, logs := newService(BalanceServiceConfig{DefaultSummarySecurities: 5, MaxSummarySecurities: 20})
+
+		summary, err := service.GetPortfolioSummary(ctx, testPortfolioID, dto.PaginationRequest{})
+		require.NoError(t, err)
+		assert.Len(t, summary.Securities, 5)
+		assert.Equal(t, 5, summary.Pagination.Limit)
+		assert.Equal(t, 25, summary.SecurityCount)
+		assert.Equal(t, 1, truncationWarnings(logs))
+	})
+
+	t.Run("Requested limit up to the maximum is respected", func(t *testing.T) {
+		service, logs := newService(BalanceServiceConfig{DefaultSummarySecurities: 5, MaxSummarySecurities: 20})
+
+		summary, err := service.GetPortfolioSummary(ctx, testPortfolioID, dto.PaginationRequest{Limit: 15})
+		require.NoError(t, err)
+		assert.Len(t, summary.Securities, 15)
+		assert.Equal(t, 0, truncationWarnings(logs), "a page the caller chose is not a truncation")
+
+		summary, err = service.GetPortfolioSummary(ctx, testPortfolioID, dto.PaginationRequest{Limit: 100})
+		require.NoError(t, err)
+		assert.Len(t, summary.Securities, 20)
+		assert.Equal(t, 20, summary.Pagination.Limit)
+		assert.Equal(t, 1, truncationWarnings(logs))
+	})
+
+	t.Run("No warning when every position fits", func(t *testing.T) {
+		service, logs := newService(BalanceServiceConfig{DefaultSummarySecurities: 30, MaxSummarySecurities: 30})
+
+		summary, err := service.GetPortfolioSummary(ctx, testPortfolioID, dto.PaginationRequest{})
+		require.NoError(t, err)
+		assert.Len(t, summary.Securities, 25)
+		assert.Equal(t, 0, truncationWarnings(logs))
+	})
+
+	t.Run("Default above the maximum falls back to the maximum", func(t *testing.T) {
+		service, _ := newService(BalanceServiceConfig{DefaultSummarySecurities: 50, MaxSummarySecurities: 8})
+
+		summary, err := service.GetPortfolioSummary(ctx, testPortfolioID, dto.PaginationRequest{})
+		require.NoError(t, err)
+		assert.Len(t, summary.Securities, 8)
+	})
+}
+
+// referencePriceRepo serves fixed reference prices and records each lookup batch
+type referencePriceRepo struct {
+	repositories.TransactionRepository
+
+	prices  map[string]decimal.Decimal
+	batches [][]string
+}
+
+func (r *referencePriceRepo) GetLatestPricesForSecurities(ctx context.Context, securityIDs []string) (map[string]decimal.Decimal, error) {
+	r.batches = append(r.batches, append([]string(nil), securityIDs...))
+
+	prices := make(map[string]decimal.Decimal)
+	for _, securityID := range securityIDs {
+		if price, ok := r.prices[securityID]; ok {
+			prices[securityID] = price
+		}
+	}
+	return prices, nil
+}
+
+func TestBalanceService_GetBalances_NotionalFilter(t *testing.T) {
+	ctx := context.Background()
+
+	// Security i holds i+1 units priced at 10, except securities 3 and 7 which have no price
+	newFixture := func() (*referencePriceRepo, BalanceService) {
+		prices := &referencePriceRepo{prices: make(map[string]decimal.Decimal)}
+		for i := 0; i < 25; i++ {
+			if i == 3 || i == 7 {
+				continue
+			}
+			prices.prices[fmt.Sprintf("SECURITY%016d", i)] = decimal.NewFromInt(10)
+		}
+		service := NewBalanceService(newSummaryFixture(25), prices, nil, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(),
+			BalanceServiceConfig{PriceLookupBatchSize: 4}, logger.NewNoop())
+		return prices, service
+	}
+	decimalPtr := func(value int64) *decimal.Decimal {
+		d := decimal.NewFromInt(value)
+		return &d
+	}
+
+	t.Run("Filters within bounds and reports unpriced securities", func(t *testing.T) {
+		prices, service := newFixture()
+
+		result, err := service.GetBalances(ctx, dto.BalanceFilter{
+			MinNotional: decimalPtr(100),
+			MaxNotional: decimalPtr(200),
+			Pagination:  dto.PaginationRequest{Limit: 50},
+		})
+		require.NoError(t, err)
+
+		// Notionals 100..200 are securities 9..19
+		require.Len(t, result.Balances, 11)
+		assert.Equal(t, "SECURITY0000000000000009", *result.Balances[0].SecurityID)
+		assert.Equal(t, "SECURITY0000000000000019", *result.Balances[10].SecurityID)
+		assert.Equal(t, int64(11), result.Pagination.Total)
+		assert.Equal(t, []string{"SECURITY0000000000000003", "SECURITY0000000000000007"}, result.UnpricedSecurityIDs)
+
+		// Every security is priced once, in batches of at most four
+		priced := 0
+		for _, batch := range prices.batches {
+			assert.LessOrEqual(t, len(batch), 4)
+			priced += len(batch)
+		}
+		assert.Equal(t, 25, priced)
+		assert.Len(t, prices.batches, 7)
+	})
+
+	t.Run("Minimum only", func(t *testing.T) {
+		_, service := newFixture()
+
+		result, err := service.GetBalances(ctx, dto.BalanceFilter{MinNotional: decimalPtr(230)})
+		require.NoError(t, err)
+
+		require.Len(t, result.Balances, 3)
+		assert.Equal(t, "SECURITY0000000000000022", *result.Balances[0].SecurityID)
+	})
+
+	t.Run("Maximum only excludes unpriced and cash balances", func(t *testing.T) {
+		_, service := newFixture()
+
+		result, err := service.GetBalances(ctx, dto.BalanceFilter{MaxNotional: decimalPtr(80)})
+		require.NoError(t, err)
+
+		// Securities 0..7 are at most 80, less the unpriced 3 and 7
+		require.Len(t, result.Balances, 6)
+		for _, balance := range result.Balances {
+			require.NotNil(t, balance.SecurityID)
+		}
+	})
+
+	t.Run("Pages the matching positions", func(t *testing.T) {
+		_, service := newFixture()
+
+		result, err := service.GetBalances(ctx, dto.BalanceFilter{
+			MinNotional: decimalPtr(100),
+			MaxNotional: decimalPtr(200),
+			Pagination:  dto.PaginationRequest{Limit: 5, Offset: 5},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, result.Balances, 5)
+		assert.Equal(t, "SECURITY0000000000000014", *result.Balances[0].SecurityID)
+		assert.Equal(t, int64(11), result.Pagination.Total)
+		assert.True(t, result.Pagination.HasMore)
+	})
+
+	t.Run("Cash-only scope is rejected", func(t *testing.T) {
+		prices, service := newFixture()
+
+		_, err := service.GetBalances(ctx, dto.BalanceFilter{
+			MinNotional: decimalPtr(100),
+			Scope:       dto.BalanceScopeCashOnly,
+		})
+		assert.Error(t, err)
+		assert.Empty(t, prices.batches)
+	})
+}
+
+// versionedBalanceRepo holds one balance and rejects updates at any other version
+type versionedBalanceRepo struct {
+	repositories.BalanceRepository
+
+	balance repositories.Balance
+	updates int
+}
+
+func (r *versionedBalanceRepo) GetByID(ctx context.Context, id int64) (*repositories.Balance, error) {
+	if id != r.balance.ID {
+		return nil, repositories.NewNotFoundError("balance", id)
+	}
+	balance := r.balance
+	return &balance, nil
+}
+
+func (r *versionedBalanceRepo) Update(ctx context.Context, balance *repositories.Balance) error {
+	if balance.Version != r.balance.Version {
+		return repositories.NewOptimisticLockError("balance", balance.ID, balance.Version, r.balance.Version)
+	}
+	balance.Version++
+	r.balance = *balance
+	r.updates++
+	return nil
+}
+
+func TestBalanceService_UpdateBalance_Version(t *testing.T) {
+	ctx := context.Background()
+	securityID := "SECURITY0000000000000001"
+	newFixture := func() (*versionedBalanceRepo, BalanceService) {
+		repo := &versionedBalanceRepo{balance: repositories.Balance{
+			ID:           7,
+			PortfolioID:  testPortfolioID,
+			SecurityID:   &securityID,
+			QuantityLong: decimal.NewFromInt(100),
+			Version:      3,
+		}}
+		return repo, NewBalanceService(repo, nil, nil, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(),
+			BalanceServiceConfig{}, logger.NewNoop())
+	}
+	quantity := decimal.NewFromInt(250)
+
+	t.Run("Current version applies", func(t *testing.T) {
+		repo, service := newFixture()
+
+		result, err := service.UpdateBalance(ctx, 7, dto.BalanceUpdateRequest{QuantityLong: &quantity, Version: 3})
+		require.NoError(t, err)
+		assert.Equal(t, 4, result.Balance.Version)
+		assert.Equal(t, 3, result.PreviousValue.Version)
+		assert.Equal(t, 1, repo.updates)
+	})
+
+	t.Run("Stale version is an optimistic lock failure", func(t *testing.T) {
+		repo, service := newFixture()
+
+		_, err := service.UpdateBalance(ctx, 7, dto.BalanceUpdateRequest{QuantityLong: &quantity, Version: 2})
+		assert.True(t, repositories.IsOptimisticLockError(err))
+		assert.Zero(t, repo.updates)
+	})
+
+	t.Run("Missing quantities", func(t *testing.T) {
+		_, service := newFixture()
+
+		_, err := service.UpdateBalance(ctx, 7, dto.BalanceUpdateRequest{Version: 3})
+		var validationErr *BalanceUpdateValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+}
+
+func TestBalanceService_BulkUpdateBalances_Buckets(t *testing.T) {
+	ctx := context.Background()
+	securityID := "SECURITY0000000000000001"
+	repo := &versionedBalanceRepo{balance: repositories.Balance{
+		ID:            7,
+		PortfolioID:   testPortfolioID,
+		SecurityID:    &securityID,
+		QuantityLong:  decimal.NewFromInt(100),
+		QuantityShort: decimal.NewFromInt(5),
+		Version:       3,
+	}}
+	service := NewBalanceService(repo, nil, nil, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(),
+		BalanceServiceConfig{MaxBulkUpdateSize: 10}, logger.NewNoop())
+	same := decimal.NewFromInt(100)
+	changed := decimal.NewFromInt(250)
+
+	result, err := service.BulkUpdateBalances(ctx, dto.BulkBalanceUpdateRequest{Updates: []dto.BalanceUpdateItem{
+		{BalanceID: 7, QuantityLong: &same, Version: 3},
+		{BalanceID: 7, QuantityLong: &changed, Version: 3},
+		{BalanceID: 7, QuantityLong: &changed, Version: 3},
+		{BalanceID: 8, QuantityLong: &changed, Version: 1},
+	}})
+	require.NoError(t, err)
+
+	require.Len(t, result.Unchanged, 1)
+	assert.False(t, result.Unchanged[0].Updated)
+	assert.Equal(t, 3, result.Unchanged[0].Balance.Version)
+
+	require.Len(t, result.Updated, 1)
+	assert.True(t, result.Updated[0].Updated)
+	assert.True(t, changed.Equal(result.Updated[0].Balance.QuantityLong))
+
+	// The repeated update now carries a stale version and the unknown balance is not found
+	require.Len(t, result.Failed, 2)
+	assert.Equal(t, int64(7), result.Failed[0].BalanceID)
+	assert.Equal(t, int64(8), result.Failed[1].BalanceID)
+
+	assert.Equal(t, dto.BulkUpdateSummaryDTO{
+		TotalRequested: 4,
+		Updated:        1,
+		Unchanged:      1,
+		Failed:         2,
+		SuccessRate:    50,
+	}, result.Summary)
+	assert.Equal(t, 1, repo.updates)
+}
+
+func TestBalanceService_UpdateBalance_RequireReason(t *testing.T) {
+	ctx := context.Background()
+	securityID := "SECURITY0000000000000001"
+	newFixture := func(requireReason bool) (*versionedBalanceRepo, BalanceService) {
+		repo := &versionedBalanceRepo{balance: repositories.Balance{
+			ID:           7,
+			PortfolioID:  testPortfolioID,
+			SecurityID:   &securityID,
+			QuantityLong: decimal.NewFromInt(100),
+			Version:      3,
+		}}
+		return repo, NewBalanceService(repo, nil, nil, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(),
+			BalanceServiceConfig{MaxBulkUpdateSize: 10, RequireAdjustmentReason: requireReason}, logger.NewNoop())
+	}
+	quantity := decimal.NewFromInt(250)
+
+	t.Run("Reason is optional by default", func(t *testing.T) {
+		repo, service := newFixture(false)
+
+		_, err := service.UpdateBalance(ctx, 7, dto.BalanceUpdateRequest{QuantityLong: &quantity, Version: 3})
+		require.NoError(t, err)
+		assert.Equal(t, 1, repo.updates)
+	})
+
+	for _, reason := range []string{"", "   "} {
+		t.Run(fmt.Sprintf("Missing reason %q is rejected", reason), func(t *testing.T) {
+			repo, service := newFixture(true)
+
+			_, err := service.UpdateBalance(ctx, 7, dto.BalanceUpdateRequest{QuantityLong: &quantity, Version: 3, Reason: reason})
+			var validationErr *BalanceUpdateValidationError
+			require.ErrorAs(t, err, &validationErr)
+			require.Len(t, validationErr.Errors, 1)
+			assert.Equal(t, "reason", validationErr.Errors[0].Field)
+			assert.Equal(t, "is required", validationErr.Errors[0].Message)
+			assert.Zero(t, repo.updates)
+		})
+	}
+
+	t.Run("Update with a reason applies", func(t *testing.T) {
+		repo, service := newFixture(true)
+
+		result, err := service.UpdateBalance(ctx, 7, dto.BalanceUpdateRequest{QuantityLong: &quantity, Version: 3, Reason: "Custodian reconciliation"})
+		require.NoError(t, err)
+		assert.True(t, result.Updated)
+		assert.Equal(t, 1, repo.updates)
+	})
+
+	t.Run("Reason longer than 500 characters", func(t *testing.T) {
+		repo, service := newFixture(false)
+
+		_, err := service.UpdateBalance(ctx, 7, dto.BalanceUpdateRequest{QuantityLong: &quantity, Version: 3, Reason: strings.Repeat("x", 501)})
+		var validationErr *BalanceUpdateValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, "reason", validationErr.Errors[0].Field)
+		assert.Zero(t, repo.updates)
+	})
+
+	t.Run("Bulk items without a reason fail individually", func(t *testing.T) {
+		repo, service := newFixture(true)
+
+		result, err := service.BulkUpdateBalances(ctx, dto.BulkBalanceUpdateRequest{Updates: []dto.BalanceUpdateItem{
+			{BalanceID: 7, QuantityLong: &quantity, Version: 3},
+			{BalanceID: 7, QuantityLong: &quantity, Version: 3, Reason: "Custodian reconciliation"},
+		}})
+		require.NoError(t, err)
+
+		require.Len(t, result.Failed, 1)
+		assert.Equal(t, int64(7), result.Failed[0].BalanceID)
+		assert.Equal(t, []dto.ValidationError{{Field: "reason", Message: "is required"}}, result.Failed[0].Errors)
+		require.Len(t, result.Updated, 1)
+		assert.Equal(t, 1, repo.updates)
+	})
+}
+
+// memoryAdjustmentRepo applies adjustments to in-memory balances and keeps the ledger by key
+type memoryAdjustmentRepo struct {
+	repositories.BalanceAdjustmentRepository
+
+	balances    map[string]*repositories.Balance
+	adjustments map[string]*repositories.BalanceAdjustment
+	nextID      int64
+}
+
+func newMemoryAdjustmentRepo(balances ...*repositories.Balance) *memoryAdjustmentRepo {
+	repo := &memoryAdjustmentRepo{
+		balances:    make(map[string]*repositories.Balance),
+		adjustments: make(map[string]*repositories.BalanceAdjustment),
+		nextID:      100,
+	}
+	for _, balance := range balances {
+		repo.balances[balanceKey(balance.PortfolioID, balance.SecurityID)] = balance
+	}
+	return repo
+}
+
+func balanceKey(portfolioID string, securityID *string) string {
+	if securityID == nil {
+		return portfolioID + "/CASH"
+	}
+	return portfolioID + "/" + *securityID
+}
+
+func (r *memoryAdjustmentRepo) Apply(ctx context.Context, adjustment *repositories.BalanceAdjustment, expectedVersion *int) (*repositories.Balance, bool, error) {
+	if existing, ok := r.adjustments[adjustment.AdjustmentKey]; ok {
+		*adjustment = *existing
+		for _, balance := range r.balances {
+			if balance.ID == existing.BalanceID {
+				clone := *balance
+				return &clone, false, nil
+			}
+		}
+	}
+
+	key := balanceKey(adjustment.PortfolioID, adjustment.SecurityID)
+	balance, exists := r.balances[key]
+	actualVersion := 0
+	if exists {
+		actualVersion = balance.Version
+	}
+	if expectedVersion != nil && *expectedVersion != actualVersion {
+		return nil, false, repositories.NewOptimisticLockError("balance", key, *expectedVersion, actualVersion)
+	}
+	if !exists {
+		r.nextID++
+		balance = &repositories.Balance{ID: r.nextID, PortfolioID: adjustment.PortfolioID, SecurityID: adjustment.SecurityID}
+		r.balances[key] = balance
+	}
+
+	adjustment.QuantityLongBefore = balance.QuantityLong
+	adjustment.QuantityShortBefore = balance.QuantityShort
+	balance.QuantityLong = balance.QuantityLong.Add(adjustment.QuantityLongDelta)
+	balance.QuantityShort = balance.QuantityShort.Add(adjustment.QuantityShortDelta)
+	balance.Version++
+	adjustment.QuantityLongAfter = balance.QuantityLong
+	adjustment.QuantityShortAfter = balance.QuantityShort
+
+	r.nextID++
+	adjustment.ID = r.nextID
+	adjustment.BalanceID = balance.ID
+	adjustment.BalanceVersion = balance.Version
+	adjustment.CreatedAt = time.Now().UTC()
+	recorded := *adjustment
+	r.adjustments[adjustment.AdjustmentKey] = &recorded
+
+	clone := *balance
+	return &clone, true, nil
+}
+
+func (r *memoryAdjustmentRepo) ReleaseKeysBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	var expired []*repositories.BalanceAdjustment
+	for _, adjustment := range r.adjustments {
+		if adjustment.CreatedAt.Before(cutoff) {
+			expired = append(expired, adjustment)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].CreatedAt.Before(expired[j].CreatedAt) })
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+	for _, adjustment := range expired {
+		delete(r.adjustments, adjustment.AdjustmentKey)
+	}
+	return int64(len(expired)), nil
+}
+
+func (r *memoryAdjustmentRepo) ReleaseKey(ctx context.Context, adjustmentKey string, cutoff time.Time) (bool, error) {
+	adjustment, ok := r.adjustments[adjustmentKey]
+	if !ok || !adjustment.CreatedAt.Before(cutoff) {
+		return false, nil
+	}
+	delete(r.adjustments, adjustmentKey)
+	return true, nil
+}
+
+func (r *memoryAdjustmentRepo) CountKeys(ctx context.Context) (int64, error) {
+	return int64(len(r.adjustments)), nil
+}
+
+func TestBalanceService_GetPortfolioSummary_EmptyPortfolio(t *testing.T) {
+	ctx := context.Background()
+	repo := &summaryBalanceRepo{}
+
+	t.Run("Returns a zeroed summary by default", func(t *testing.T) {
+		service := NewBalanceService(repo, nil, nil, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(),
+			BalanceServiceConfig{MaxSummarySecurities: 10}, logger.NewNoop())
+
+		summary, err := service.GetPortfolioSummary(ctx, testPortfolioID, dto.PaginationRequest{})
+		require.NoError(t, err)
+
+		assert.Equal(t, testPortfolioID, summary.PortfolioID)
+		assert.True(t, summary.CashBalance.IsZero())
+		assert.Equal(t, 0, summary.SecurityCount)
+		require.NotNil(t, summary.Securities)
+		assert.Empty(t, summary.Securities)
+		assert.Equal(t, int64(0), summary.Pagination.Total)
+		assert.False(t, summary.Pagination.HasMore)
+	})
+
+	t.Run("Reports not found when configured", func(t *testing.T) {
+		service := NewBalanceService(repo, nil, nil, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(),
+			BalanceServiceConfig{MaxSummarySecurities: 10, EmptySummaryNotFound: true}, logger.NewNoop())
+
+		_, err := service.GetPortfolioSummary(ctx, testPortfolioID, dto.PaginationRequest{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
+func (r *summaryBalanceRepo) GetByPortfolioAndSecurity(ctx context.Context, portfolioID string, securityID *string) (*repositories.Balance, error) {
+	for _, balance := range r.balances {
+		if balance.PortfolioID != portfolioID || (balance.SecurityID == nil) != (securityID == nil) {
+			continue
+		}
+		if securityID == nil || *balance.SecurityID == *securityID {
+			clone := *balance
+			return &clone, nil
+		}
+	}
+	return nil, repositories.NewNotFoundError("balance", portfolioID)
+}
+
+func TestBalanceService_GetPortfolioBalance(t *testing.T) {
+	ctx := context.Background()
+	securityID := "SECURITY0000000000000001"
+	otherSecurityID := "SECURITY0000000000000009"
+	now := time.Now()
+	repo := &summaryBalanceRepo{balances: []*repositories.Balance{
+		{ID: 1, PortfolioID: testPortfolioID, QuantityLong: decimal.NewFromInt(5000), Version: 2, LastUpdated: now},
+		{ID: 2, PortfolioID: testPortfolioID, SecurityID: &securityID, QuantityLong: decimal.NewFromInt(100), Version: 3, LastUpdated: now},
+	}}
+	newService := func(config BalanceServiceConfig) BalanceService {
+		return NewBalanceService(repo, nil, nil, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(), config, logger.NewNoop())
+	}
+
+	t.Run("Security and cash balances", func(t *testing.T) {
+		service := newService(BalanceServiceConfig{})
+
+		balance, err := service.GetPortfolioBalance(ctx, testPortfolioID, &securityID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), balance.ID)
+		assert.True(t, decimal.NewFromInt(100).Equal(balance.QuantityLong))
+
+		balance, err = service.GetPortfolioBalance(ctx, testPortfolioID, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), balance.ID)
+		assert.Nil(t, balance.SecurityID)
+		assert.True(t, decimal.NewFromInt(5000).Equal(balance.QuantityLong))
+	})
+
+	t.Run("Missing balance is zeroed by default", func(t *testing.T) {
+		balance, err := newService(BalanceServiceConfig{}).GetPortfolioBalance(ctx, testPortfolioID, &otherSecurityID)
+		require.NoError(t, err)
+		assert.Zero(t, balance.ID)
+		assert.Equal(t, testPortfolioID, balance.PortfolioID)
+		assert.Equal(t, &otherSecurityID, balance.SecurityID)
+		assert.True(t, balance.QuantityLong.IsZero())
+		assert.True(t, balance.QuantityShort.IsZero())
+	})
+
+	t.Run("Missing balance is not found when configured", func(t *testing.T) {
+		_, err := newService(BalanceServiceConfig{MissingBalanceNotFound: true}).GetPortfolioBalance(ctx, "PORTFOLIO000000000000009", nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+
+	t.Run("IDs must be 24 characters", func(t *testing.T) {
+		service := newService(BalanceServiceConfig{})
+		var invalidID *InvalidBalanceIDError
+
+		_, err := service.GetPortfolioBalance(ctx, "PORTFOLIO1", nil)
+		require.ErrorAs(t, err, &invalidID)
+		assert.Equal(t, "portfolioId", invalidID.Field)
+
+		short := "SECURITY1"
+		_, err = service.GetPortfolioBalance(ctx, testPortfolioID, &short)
+		require.ErrorAs(t, err, &invalidID)
+		assert.Equal(t, "securityId", invalidID.Field)
+	})
+}
+
+// summariesBalanceRepo aggregates portfolio summaries over the fixture balances and counts the
+// queries a batch of summaries issues
+type summariesBalanceRepo struct {
+	*summaryBalanceRepo
+
+	summariesCalls int
+	summaryCalls   int
+	listCalls      int
+}
+
+func (r *summariesBalanceRepo) List(ctx context.Context, filter repositories.BalanceFilter) ([]*repositories.Balance, error) {
+	r.listCalls++
+	return r.summaryBalanceRepo.List(ctx, filter)
+}
+
+func (r *summariesBalanceRepo) GetPortfolioSummary(ctx context.Context, portfolioID string) (*repositories.PortfolioSummary, error) {
+	r.summaryCalls++
+	return r.summaryBalanceRepo.GetPortfolioSummary(ctx, portfolioID)
+}
+
+func (r *summariesBalanceRepo) GetPortfolioSummaries(ctx context.Context, portfolioIDs []string, limit, offset int) ([]*repositories.PortfolioSummary, error) {
+	r.summariesCalls++
+
+	var ids []string
+	for _, balance := range r.balances {
+		if len(portfolioIDs) > 0 && !containsString(portfolioIDs, balance.PortfolioID) {
+			continue
+		}
+		if !containsString(ids, balance.PortfolioID) {
+			ids = append(ids, balance.PortfolioID)
+		}
+	}
+	sort.Strings(ids)
+
+	if offset >= len(ids) {
+		return nil, nil
+	}
+	ids = ids[offset:]
+	if limit > 0 && limit < len(ids) {
+		ids = ids[:limit]
+	}
+
+	summaries := make([]*repositories.PortfolioSummary, len(ids))
+	for i, portfolioID := range ids {
+		summaries[i], _ = r.summaryBalanceRepo.GetPortfolioSummary(ctx, portfolioID)
+	}
+	return summaries, nil
+}
+
+func TestBalanceService_GetPortfolioSummaries(t *testing.T) {
+	ctx := context.Background()
+	cashOnlyPortfolioID := "PORTFOLIO000000000000002"
+	emptyPortfolioID := "PORTFOLIO000000000000003"
+
+	newFixture := func() *summariesBalanceRepo {
+		repo := &summariesBalanceRepo{summaryBalanceRepo: newSummaryFixture(3)}
+		repo.balances = append(repo.balances, &repositories.Balance{
+			ID:           100,
+			PortfolioID:  cashOnlyPortfolioID,
+			QuantityLong: decimal.NewFromInt(750),
+			Version:      1,
+		})
+		return repo
+	}
+	newService := func(repo repositories.BalanceRepository, config BalanceServiceConfig) BalanceService {
+		return NewBalanceService(repo, nil, nil, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(), config, logger.NewNoop())
+	}
+
+	t.Run("Rejects more portfolios than the maximum", func(t *testing.T) {
+		repo := newFixture()
+		service := newService(repo, BalanceServiceConfig{MaxSummaryPortfolios: 2})
+
+		_, err := service.GetPortfolioSummaries(ctx, dto.PortfolioSummaryFilter{
+			PortfolioIDs: []string{testPortfolioID, cashOnlyPortfolioID, emptyPortfolioID},
+		})
+
+		var tooLarge *SummaryBatchTooLargeError
+		require.ErrorAs(t, err, &tooLarge)
+		assert.Equal(t, 3, tooLarge.Requested)
+		assert.Equal(t, 2, tooLarge.Max)
+		assert.Zero(t, repo.summariesCalls, "an oversized request must not reach the database")
+	})
+
+	t.Run("Repeated portfolio IDs count once", func(t *testing.T) {
+		service := newService(newFixture(), BalanceServiceConfig{MaxSummaryPortfolios: 2})
+
+		summaries, err := service.GetPortfolioSummaries(ctx, dto.PortfolioSummaryFilter{
+			PortfolioIDs: []string{testPortfolioID, cashOnlyPortfolioID, testPortfolioID},
+		})
+		require.NoError(t, err)
+		assert.Len(t, summaries, 2)
+	})
+
+	t.Run("Summarizes the requested portfolios with two queries", func(t *testing.T) {
+		repo := newFixture()
+		service := newService(repo, BalanceServiceConfig{MaxSummarySecurities: 2})
+
+		summaries, err := service.GetPortfolioSummaries(ctx, dto.PortfolioSummaryFilter{
+			PortfolioIDs: []string{cashOnlyPortfolioID, testPortfolioID, emptyPortfolioID},
+		})
+		require.NoError(t, err)
+		require.Len(t, summaries, 3)
+
+		assert.Equal(t, 1, repo.summariesCalls)
+		assert.Equal(t, 1, repo.listCalls)
+		assert.Zero(t, repo.summaryCalls, "summaries must not be loaded portfolio by portfolio")
+
+		// Requested order is kept
+		cashOnly, full, empty := summaries[0], summaries[1], summaries[2]
+
+		assert.Equal(t, cashOnlyPortfolioID, cashOnly.PortfolioID)
+		assert.True(t, decimal.NewFromInt(750).Equal(cashOnly.CashBalance))
+		assert.Equal(t, 0, cashOnly.SecurityCount)
+		assert.NotNil(t, cashOnly.Securities)
+		assert.Empty(t, cashOnly.Securities)
+
+		assert.Equal(t, testPortfolioID, full.PortfolioID)
+		assert.True(t, decimal.NewFromInt(5000).Equal(full.CashBalance))
+		assert.Equal(t, 3, full.SecurityCount)
+		assert.Len(t, full.Securities, 2, "security positions are capped at MaxSummarySecurities")
+		assert.Equal(t, int64(3), full.Pagination.Total)
+		assert.True(t, full.Pagination.HasMore)
+
+		assert.Equal(t, emptyPortfolioID, empty.PortfolioID)
+		assert.True(t, empty.CashBalance.IsZero())
+		assert.Empty(t, empty.Securities)
+	})
+
+	t.Run("Leaves out portfolios without balances when configured", func(t *testing.T) {
+		service := newService(newFixture(), BalanceServiceConfig{EmptySummaryNotFound: true})
+
+		summaries, err := service.GetPortfolioSummaries(ctx, dto.PortfolioSummaryFilter{
+			PortfolioIDs: []string{emptyPortfolioID, testPortfolioID},
+		})
+		require.NoError(t, err)
+		require.Len(t, summaries, 1)
+		assert.Equal(t, testPortfolioID, summaries[0].PortfolioID)
+	})
+
+	t.Run("Pages through all portfolios when none are named", func(t *testing.T) {
+		service := newService(newFixture(), BalanceServiceConfig{MaxSummaryPortfolios: 1})
+
+		first, err := service.GetPortfolioSummaries(ctx, dto.PortfolioSummaryFilter{})
+		require.NoError(t, err)
+		require.Len(t, first, 1, "the page size defaults to MaxSummaryPortfolios")
+		assert.Equal(t, cashOnlyPortfolioID, first[0].PortfolioID, "portfolios are ordered by ID")
+
+		second, err := service.GetPortfolioSummaries(ctx, dto.PortfolioSummaryFilter{
+			Pagination: dto.PaginationRequest{Limit: 50, Offset: 1},
+		})
+		require.NoError(t, err)
+		require.Len(t, second, 1)
+		assert.Equal(t, testPortfolioID, second[0].PortfolioID)
+	})
+}
+
+func TestBalanceService_AdjustBalance(t *testing.T) {
+	ctx := context.Background()
+	securityID := "SECURITY0000000000000001"
+
+	newFixture := func() (*memoryAdjustmentRepo, BalanceService) {
+		repo := newMemoryAdjustmentRepo(&repositories.Balance{
+			ID:            7,
+			PortfolioID:   testPortfolioID,
+			SecurityID:    &securityID,
+			QuantityLong:  decimal.NewFromInt(100),
+			QuantityShort: decimal.Zero,
+			Version:       3,
+		})
+		service := NewBalanceService(nil, nil, repo, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(),
+			BalanceServiceConfig{}, logger.NewNoop())
+		return repo, service
+	}
+
+	request := dto.BalanceAdjustmentRequest{
+		AdjustmentKey:     "ADJ-2024-0001",
+		PortfolioID:       testPortfolioID,
+		SecurityID:        &securityID,
+		QuantityLongDelta: decimal.NewFromInt(-25),
+		Reason:            "Custodian reconciliation break",
+		Operator:          "ops.user",
+	}
+
+	t.Run("Applies the adjustment and records the ledger entry", func(t *testing.T) {
+		_, service := newFixture()
+
+		result, err := service.AdjustBalance(ctx, request)
+		require.NoError(t, err)
+
+		assert.True(t, result.Applied)
+		assert.True(t, decimal.NewFromInt(75).Equal(result.Balance.QuantityLong))
+		assert.Equal(t, 4, result.Balance.Version)
+		assert.True(t, decimal.NewFromInt(100).Equal(result.Adjustment.QuantityLongBefore))
+		assert.True(t, decimal.NewFromInt(75).Equal(result.Adjustment.QuantityLongAfter))
+		assert.Equal(t, int64(7), result.Adjustment.BalanceID)
+		assert.Equal(t, "ops.user", result.Adjustment.Operator)
+	})
+
+	t.Run("Replaying the key does not apply twice", func(t *testing.T) {
+		repo, service := newFixture()
+
+		first, err := service.AdjustBalance(ctx, request)
+		require.NoError(t, err)
+		second, err := service.AdjustBalance(ctx, request)
+		require.NoError(t, err)
+
+		assert.False(t, second.Applied)
+		assert.Equal(t, first.Adjustment.ID, second.Adjustment.ID)
+		assert.True(t, decimal.NewFromInt(75).Equal(second.Balance.QuantityLong))
+		assert.Len(t, repo.adjustments, 1)
+	})
+
+	t.Run("Reusing the key for a different adjustment conflicts", func(t *testing.T) {
+		_, service := newFixture()
+
+		_, err := service.AdjustBalance(ctx, request)
+		require.NoError(t, err)
+
+		different := request
+		different.QuantityLongDelta = decimal.NewFromInt(-30)
+		_, err = service.AdjustBalance(ctx, different)
+		assert.ErrorIs(t, err, ErrAdjustmentKeyConflict)
+	})
+
+	t.Run("Stale expected version is rejected", func(t *testing.T) {
+		repo, service := newFixture()
+
+		stale := request
+		version := 2
+		stale.ExpectedVersion = &version
+		_, err := service.AdjustBalance(ctx, stale)
+		require.Error(t, err)
+		assert.True(t, repositories.IsOptimisticLockError(err))
+		assert.Empty(t, repo.adjustments)
+	})
+
+	t.Run("Creates a missing cash balance", func(t *testing.T) {
+		_, service := newFixture()
+
+		cash := request
+		cash.AdjustmentKey = "ADJ-2024-0002"
+		cash.SecurityID = nil
+		cash.QuantityLongDelta = decimal.NewFromInt(500)
+		result, err := service.AdjustBalance(ctx, cash)
+		require.NoError(t, err)
+
+		assert.True(t, result.Applied)
+		assert.Nil(t, result.Balance.SecurityID)
+		assert.True(t, decimal.Zero.Equal(result.Adjustment.QuantityLongBefore))
+		assert.True(t, decimal.NewFromInt(500).Equal(result.Balance.QuantityLong))
+	})
+
+	t.Run("Invalid request", func(t *testing.T) {
+		repo, service := newFixture()
+
+		invalid := request
+		invalid.AdjustmentKey = ""
+		invalid.QuantityLongDelta = decimal.Zero
+		invalid.Operator = ""
+		_, err := service.AdjustBalance(ctx, invalid)
+
+		var validationErr *AdjustmentValidationError
+		require.ErrorAs(t, err, &validationErr)
+		fields := make([]string, 0, len(validationErr.Errors))
+		for _, validationError := range validationErr.Errors {
+			fields = append(fields, validationError.Field)
+		}
+		assert.ElementsMatch(t, []string{"adjustmentKey", "quantities", "operator"}, fields)
+		assert.Empty(t, repo.adjustments)
+	})
+}
+
+func (r *fakeTransactionRepo) GetLatestPrices(ctx context.Context, portfolioID string) (map[string]decimal.Decimal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prices := make(map[string]decimal.Decimal)
+	latest := make(map[string]*repositories.Transaction)
+	for _, txn := range r.transactions {
+		if txn.PortfolioID != portfolioID || txn.SecurityID == nil || !txn.Price.IsPositive() ||
+			txn.Status != models.TransactionStatusProc.String() {
+			continue
+		}
+		if current, ok := latest[*txn.SecurityID]; ok && !txn.TransactionDate.After(current.TransactionDate) {
+			continue
+		}
+		latest[*txn.SecurityID] = txn
+		prices[*txn.SecurityID] = txn.Price
+	}
+	return prices, nil
+}
+
+func TestBalanceService_GetPortfolioExposure(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	longOnly := "SECURITY0000000000000001"
+	shortOnly := "SECURITY0000000000000002"
+	mixed := "SECURITY0000000000000003"
+
+	balanceRepo := &summaryBalanceRepo{balances: []*repositories.Balance{
+		{ID: 1, PortfolioID: testPortfolioID, QuantityLong: decimal.NewFromInt(10000), QuantityShort: decimal.Zero},
+		{ID: 2, PortfolioID: testPortfolioID, SecurityID: &longOnly, QuantityLong: decimal.NewFromInt(100), QuantityShort: decimal.Zero},
+		{ID: 3, PortfolioID: testPortfolioID, SecurityID: &shortOnly, QuantityLong: decimal.Zero, QuantityShort: decimal.NewFromInt(40)},
+		{ID: 4, PortfolioID: testPortfolioID, SecurityID: &mixed, QuantityLong: decimal.NewFromInt(30), QuantityShort: decimal.NewFromInt(50)},
+	}}
+
+	processed := func(id int64, securityID *string, price int64, daysAgo int) *repositories.Transaction {
+		return &repositories.Transaction{
+			ID:              id,
+			PortfolioID:     testPortfolioID,
+			SecurityID:      securityID,
+			Status:          models.TransactionStatusProc.String(),
+			Price:           decimal.NewFromInt(price),
+			TransactionDate: base.AddDate(0, 0, -daysAgo),
+		}
+	}
+
+	t.Run("Mixed long and short positions", func(t *testing.T) {
+		txnRepo := newFakeTransactionRepo(
+			processed(1, &longOnly, 10, 5),
+			processed(2, &longOnly, 12, 1), // latest price wins
+			processed(3, &shortOnly, 25, 2),
+			processed(4, nil, 1, 3), // cash is never priced
+		)
+		service := NewBalanceService(balanceRepo, txnRepo, nil, domainServices.BalanceCalculator{},
+			mappers.NewBalanceMapper(), BalanceServiceConfig{}, logger.NewNoop())
+
+		exposure, err := service.GetPortfolioExposure(ctx, testPortfolioID)
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, exposure.SecurityCount)
+		assert.True(t, decimal.NewFromInt(130).Equal(exposure.TotalLong))
+		assert.True(t, decimal.NewFromInt(90).Equal(exposure.TotalShort))
+		assert.True(t, decimal.NewFromInt(220).Equal(exposure.Gross))
+		assert.True(t, decimal.NewFromInt(40).Equal(exposure.Net))
+
+		// The mixed position has no price and stays out of the value totals
+		require.NotNil(t, exposure.Value)
+		assert.Equal(t, 2, exposure.Value.PricedSecurities)
+		assert.Equal(t, 1, exposure.Value.UnpricedSecurities)
+		assert.True(t, decimal.NewFromInt(1200).Equal(exposure.Value.Long))
+		assert.True(t, decimal.NewFromInt(1000).Equal(exposure.Value.Short))
+		assert.True(t, decimal.NewFromInt(2200).Equal(exposure.Value.Gross))
+		assert.True(t, decimal.NewFromInt(200).Equal(exposure.Value.Net))
+	})
+
+	t.Run("Net short portfolio without prices", func(t *testing.T) {
+		shortRepo := &summaryBalanceRepo{balances: []*repositories.Balance{
+			{ID: 3, PortfolioID: testPortfolioID, SecurityID: &shortOnly, QuantityLong: decimal.Zero, QuantityShort: decimal.NewFromInt(40)},
+			{ID: 4, PortfolioID: testPortfolioID, SecurityID: &mixed, QuantityLong: decimal.NewFromInt(30), QuantityShort: decimal.NewFromInt(50)},
+		}}
+		service := NewBalanceService(shortRepo, newFakeTransactionRepo(), nil, domainServices.BalanceCalculator{},
+			mappers.NewBalanceMapper(), BalanceServiceConfig{}, logger.NewNoop())
+
+		exposure, err := service.GetPortfolioExposure(ctx, testPortfolioID)
+		require.NoError(t, err)
+
+		assert.True(t, decimal.NewFromInt(120).Equal(exposure.Gross))
+		assert.True(t, decimal.NewFromInt(-60).Equal(exposure.Net))
+		assert.Nil(t, exposure.Value)
+	})
+
+	t.Run("Unknown portfolio", func(t *testing.T) {
+		service := NewBalanceService(balanceRepo, newFakeTransactionRepo(), nil, domainServices.BalanceCalculator{},
+			mappers.NewBalanceMapper(), BalanceServiceConfig{}, logger.NewNoop())
+
+		_, err := service.GetPortfolioExposure(ctx, "PORTFOLIO000000000000000")
+		assert.Error(t, err)
+	})
+}
+
+// countOnlyBalanceRepo fails listing queries so tests can check a count does not load rows
+type countOnlyBalanceRepo struct {
+	*summaryBalanceRepo
+}
+
+func (r *countOnlyBalanceRepo) List(ctx context.Context, filter repositories.BalanceFilter) ([]*repositories.Balance, error) {
+	return nil, errors.New("unexpected list query")
+}
+
+func TestBalanceService_CountBalances(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Counts without listing", func(t *testing.T) {
+		service := NewBalanceService(&countOnlyBalanceRepo{newSummaryFixture(25)}, nil, nil, domainServices.BalanceCalculator{},
+			mappers.NewBalanceMapper(), BalanceServiceConfig{}, logger.NewNoop())
+
+		count, err := service.CountBalances(ctx, dto.BalanceFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(26), count)
+
+		count, err = service.CountBalances(ctx, dto.BalanceFilter{
+			Scope:      dto.BalanceScopeSecuritiesOnly,
+			Pagination: dto.PaginationRequest{Limit: 1, Offset: 10},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(25), count)
+	})
+
+	t.Run("Notional filter counts matching positions", func(t *testing.T) {
+		prices := &referencePriceRepo{prices: make(map[string]decimal.Decimal)}
+		for i := 0; i < 25; i++ {
+			prices.prices[fmt.Sprintf("SECURITY%016d", i)] = decimal.NewFromInt(10)
+		}
+		service := NewBalanceService(newSummaryFixture(25), prices, nil, domainServices.BalanceCalculator{},
+			mappers.NewBalanceMapper(), BalanceServiceConfig{}, logger.NewNoop())
+
+		minNotional := decimal.NewFromInt(100)
+		count, err := service.CountBalances(ctx, dto.BalanceFilter{MinNotional: &minNotional})
+		require.NoError(t, err)
+		assert.Equal(t, int64(16), count)
+	})
+
+	t.Run("Invalid filter", func(t *testing.T) {
+		service := NewBalanceService(&countOnlyBalanceRepo{newSummaryFixture(1)}, nil, nil, domainServices.BalanceCalculator{},
+			mappers.NewBalanceMapper(), BalanceServiceConfig{}, logger.NewNoop())
+
+		cashOnly := true
+		_, err := service.CountBalances(ctx, dto.BalanceFilter{Scope: dto.BalanceScopeSecuritiesOnly, CashOnly: &cashOnly})
+		assert.Error(t, err)
+	})
+}
+
+func TestBalanceService_ConvertDTOFilterToRepo_AbsoluteQuantity(t *testing.T) {
+	service := &balanceService{}
+	minLong := decimal.NewFromInt(10)
+	minShort := decimal.NewFromInt(2)
+
+	repoFilter := service.convertDTOFilterToRepo(dto.BalanceFilter{
+		MinAbsQuantityLong:  &minLong,
+		MinAbsQuantityShort: &minShort,
+	})
+	require.NotNil(t, repoFilter.QuantityLongAbsMin)
+	require.NotNil(t, repoFilter.QuantityShortAbsMin)
+	assert.True(t, minLong.Equal(*repoFilter.QuantityLongAbsMin))
+	assert.True(t, minShort.Equal(*repoFilter.QuantityShortAbsMin))
+	assert.Nil(t, repoFilter.QuantityLongMin, "magnitude thresholds do not bound the signed quantity")
+
+	repoFilter = service.convertDTOFilterToRepo(dto.BalanceFilter{})
+	assert.Nil(t, repoFilter.QuantityLongAbsMin)
+	assert.Nil(t, repoFilter.QuantityShortAbsMin)
+}
+
+func TestBalanceService_GetBalances_ClampsLimit(t *testing.T) {
+	ctx := context.Background()
+	service := NewBalanceService(newSummaryFixture(dto.MaxPageLimit+1), nil, nil, domainServices.BalanceCalculator{},
+		mappers.NewBalanceMapper(), BalanceServiceConfig{}, logger.NewNoop())
+
+	t.Run("Over the maximum is clamped and reported", func(t *testing.T) {
+		result, err := service.GetBalances(ctx, dto.BalanceFilter{
+			Scope:      dto.BalanceScopeSecuritiesOnly,
+			Pagination: dto.PaginationRequest{Limit: 5000},
+		})
+		require.NoError(t, err)
+
+		assert.Len(t, result.Balances, dto.MaxPageLimit)
+		assert.Equal(t, dto.MaxPageLimit, result.Pagination.Limit)
+		assert.Equal(t, 5000, result.Pagination.RequestedLimit)
+		assert.True(t, result.Pagination.LimitClamped)
+		assert.True(t, result.Pagination.HasMore)
+	})
+
+	t.Run("Within the maximum is not reported", func(t *testing.T) {
+		result, err := service.GetBalances(ctx, dto.BalanceFilter{
+			Scope:      dto.BalanceScopeSecuritiesOnly,
+			Pagination: dto.PaginationRequest{Limit: dto.MaxPageLimit},
+		})
+		require.NoError(t, err)
+
+		assert.Len(t, result.Balances, dto.MaxPageLimit)
+		assert.Zero(t, result.Pagination.RequestedLimit)
+		assert.False(t, result.Pagination.LimitClamped)
+	})
+
+	t.Run("Notional filter reports the clamp", func(t *testing.T) {
+		minNotional := decimal.Zero
+		result, err := NewBalanceService(newSummaryFixture(5), &referencePriceRepo{prices: map[string]decimal.Decimal{}}, nil,
+			domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(), BalanceServiceConfig{}, logger.NewNoop()).
+			GetBalances(ctx, dto.BalanceFilter{MinNotional: &minNotional, Pagination: dto.PaginationRequest{Limit: 1500}})
+		require.NoError(t, err)
+
+		assert.Equal(t, dto.MaxPageLimit, result.Pagination.Limit)
+		assert.Equal(t, 1500, result.Pagination.RequestedLimit)
+		assert.True(t, result.Pagination.LimitClamped)
+	})
+}
+
+func TestBalanceService_GetZeroBalances(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	day := 24 * time.Hour
+	security := func(i int) *string {
+		securityID := fmt.Sprintf("SECURITY%016d", i)
+		return &securityID
+	}
+	balance := func(id int64, securityID *string, quantityLong int64, age time.Duration) *repositories.Balance {
+		return &repositories.Balance{
+			ID:           id,
+			PortfolioID:  testPortfolioID,
+			SecurityID:   securityID,
+			QuantityLong: decimal.NewFromInt(quantityLong),
+			Version:      1,
+			CreatedAt:    now.Add(-age),
+			LastUpdated:  now.Add(-age),
+		}
+	}
+	// Security IDs follow the age of the balances, so the fake's order is oldest first
+	repo := &summaryBalanceRepo{balances: []*repositories.Balance{
+		balance(1, nil, 0, 90*day),
+		balance(2, security(1), 0, 40*day),
+		balance(3, security(2), 0, 35*day),
+		balance(4, security(3), 0, 5*day),
+		balance(5, security(0), 10, 60*day),
+	}}
+	service := NewBalanceService(repo, nil, nil, domainServices.BalanceCalculator{}, mappers.NewBalanceMapper(),
+		BalanceServiceConfig{}, logger.NewNoop())
+	ids := func(response *dto.BalanceListResponse) []int64 {
+		var result []int64
+		for _, balance := range response.Balances {
+			result = append(result, balance.ID)
+		}
+		return result
+	}
+
+	t.Run("Only security balances with zero quantities", func(t *testing.T) {
+		response, err := service.GetZeroBalances(ctx, 0, dto.PaginationRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{2, 3, 4}, ids(response))
+		assert.Equal(t, int64(3), response.Pagination.Total)
+		assert.NotEmpty(t, response.Balances[0].LastUpdated)
+	})
+
+	t.Run("Age filter", func(t *testing.T) {
+		response, err := service.GetZeroBalances(ctx, 30*day, dto.PaginationRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{2, 3}, ids(response))
+		assert.Equal(t, int64(2), response.Pagination.Total)
+
+		response, err = service.GetZeroBalances(ctx, 50*day, dto.PaginationRequest{})
+		require.NoError(t, err)
+		assert.Empty(t, response.Balances)
+		assert.Zero(t, response.Pagination.Total)
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		first, err := service.GetZeroBalances(ctx, 0, dto.PaginationRequest{Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{2, 3}, ids(first))
+		assert.True(t, first.Pagination.HasMore)
+		assert.Equal(t, int64(3), first.Pagination.Total)
+
+		second, err := service.GetZeroBalances(ctx, 0, dto.PaginationRequest{Limit: 2, Offset: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{4}, ids(second))
+		assert.False(t, second.Pagination.HasMore)
+	})
+
+	t.Run("Negative age is rejected", func(t *testing.T) {
+		_, err := service.GetZeroBalances(ctx, -day, dto.PaginationRequest{})
+		assert.Error(t, err)
+	})
+}
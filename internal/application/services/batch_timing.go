@@ -0,0 +1,57 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+)
+
+// BatchOptions are the options of a batch create
+type BatchOptions struct {
+	// Timing adds the duration of the batch and a per-portfolio breakdown to the summary of
+	// its response
+	Timing bool
+}
+
+// addBatchTiming adds the timing of a batch that started at start to its summary when the
+// caller asked for it. The duration covers validation, creation and processing.
+func addBatchTiming(options BatchOptions, start time.Time, response *dto.TransactionBatchResponse) {
+	if !options.Timing {
+		return
+	}
+
+	elapsed := time.Since(start)
+	timing := &dto.BatchTimingDTO{
+		DurationMs: float64(elapsed.Microseconds()) / 1000,
+		Portfolios: []dto.PortfolioBatchSummaryDTO{},
+	}
+	if elapsed > 0 {
+		timing.RecordsPerSecond = float64(response.Summary.TotalRequested) / elapsed.Seconds()
+	}
+
+	portfolios := make(map[string]*dto.PortfolioBatchSummaryDTO)
+	portfolio := func(portfolioID string) *dto.PortfolioBatchSummaryDTO {
+		summary, ok := portfolios[portfolioID]
+		if !ok {
+			summary = &dto.PortfolioBatchSummaryDTO{PortfolioID: portfolioID}
+			portfolios[portfolioID] = summary
+		}
+		return summary
+	}
+	for _, created := range response.Successful {
+		portfolio(created.PortfolioID).Successful++
+	}
+	for _, failed := range response.Failed {
+		portfolio(failed.Transaction.PortfolioID).Failed++
+	}
+
+	for _, summary := range portfolios {
+		timing.Portfolios = append(timing.Portfolios, *summary)
+	}
+	sort.Slice(timing.Portfolios, func(i, j int) bool {
+		return timing.Portfolios[i].PortfolioID < timing.Portfolios[j].PortfolioID
+	})
+
+	response.Summary.Timing = timing
+}
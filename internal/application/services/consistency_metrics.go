@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// consistencyMeterName is the instrumentation scope of the consistency check metrics
+const consistencyMeterName = "globeco-portfolio-accounting-service/consistency"
+
+// consistencyMetrics records the outcome of balance consistency checks
+type consistencyMetrics struct {
+	checks metric.Int64Counter
+	drift  metric.Int64Counter
+}
+
+// newConsistencyMetrics creates the consistency check instruments. A nil provider uses the
+// global meter provider; instruments that fail to initialize are left nil and skipped.
+func newConsistencyMetrics(provider metric.MeterProvider, lg logger.Logger) *consistencyMetrics {
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	meter := provider.Meter(consistencyMeterName)
+
+	m := &consistencyMetrics{}
+	var err error
+
+	m.checks, err = meter.Int64Counter(
+		"balance_consistency_checks_total",
+		metric.WithDescription("Total number of balance consistency checks by result"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		lg.Warn("Failed to create consistency check counter", logger.Err(err))
+	}
+
+	m.drift, err = meter.Int64Counter(
+		"balance_consistency_drift_total",
+		metric.WithDescription("Total number of balances found drifted from their processed transactions"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		lg.Warn("Failed to create consistency drift counter", logger.Err(err))
+	}
+
+	return m
+}
+
+// record records a completed consistency check
+func (m *consistencyMetrics) record(ctx context.Context, report *services.ConsistencyReport) {
+	result := "consistent"
+	if !report.IsConsistent() {
+		result = "drift"
+	}
+	if m.checks != nil {
+		m.checks.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+	}
+
+	if m.drift == nil {
+		return
+	}
+	byKind := make(map[services.DiscrepancyKind]int64)
+	for _, discrepancy := range report.Discrepancies {
+		byKind[discrepancy.Kind]++
+	}
+	for kind, count := range byKind {
+		m.drift.Add(ctx, count, metric.WithAttributes(attribute.String("kind", string(kind))))
+	}
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/csv"
 	"fmt"
@@ -10,6 +12,7 @@ import (
 	"time"
 
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/mappers"
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
@@ -17,16 +20,51 @@ import (
 
 // CSVProcessor handles CSV file processing for transactions
 type CSVProcessor struct {
-	logger logger.Logger
+	logger        logger.Logger
+	decimalFormat DecimalFormat
+	mapper        *mappers.TransactionMapper
 }
 
 // NewCSVProcessor creates a new CSV processor
 func NewCSVProcessor(lg logger.Logger) *CSVProcessor {
 	return &CSVProcessor{
 		logger: lg,
+		mapper: mappers.NewTransactionMapper(),
 	}
 }
 
+// WithTransactionMapper sets the mapper whose normalization, including its transaction type
+// aliases, checks and normalizes the transaction types of records
+func (p *CSVProcessor) WithTransactionMapper(mapper *mappers.TransactionMapper) *CSVProcessor {
+	p.mapper = mapper
+	return p
+}
+
+// WithDecimalFormat sets how quantities and prices are written; the default is the US format
+func (p *CSVProcessor) WithDecimalFormat(format DecimalFormat) *CSVProcessor {
+	p.decimalFormat = format
+	return p
+}
+
+// utf8BOM is the byte order mark some tools, notably Excel, write at the start of UTF-8 CSV exports
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// newCSVReader creates a CSV reader that skips a leading UTF-8 byte order mark
+func newCSVReader(r io.Reader) *csv.Reader {
+	buffered := bufio.NewReader(r)
+	if prefix, err := buffered.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		_, _ = buffered.Discard(len(utf8BOM))
+	}
+	return csv.NewReader(buffered)
+}
+
+// normalizeCSVHeader normalizes a header name for matching: a stray byte order mark and
+// surrounding whitespace are removed and the name is lowercased
+func normalizeCSVHeader(header string) string {
+	header = strings.TrimPrefix(header, string(utf8BOM))
+	return strings.ToLower(strings.TrimSpace(header))
+}
+
 // CSVTransactionRecord represents a single CSV record for transaction processing
 type CSVTransactionRecord struct {
 	LineNumber      int
@@ -74,7 +112,7 @@ func (p *CSVProcessor) ValidateHeaders(filePath string) (*CSVHeader, error) {
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
+	reader := newCSVReader(file)
 
 	// Read first line (headers)
 	headers, err := reader.Read()
@@ -99,8 +137,7 @@ func (p *CSVProcessor) ValidateHeaders(filePath string) (*CSVHeader, error) {
 
 	// Map actual headers to indexes
 	for i, header := range headers {
-		normalizedHeader := strings.ToLower(strings.TrimSpace(header))
-		headerIndexes[normalizedHeader] = i
+		headerIndexes[normalizeCSVHeader(header)] = i
 	}
 
 	// Validate and map required headers
@@ -158,7 +195,7 @@ func (p *CSVProcessor) ReadCSVFile(ctx context.Context, filePath string, progres
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
+	reader := newCSVReader(file)
 	reader.FieldsPerRecord = -1 // Allow variable number of fields
 	reader.TrimLeadingSpace = true
 
@@ -272,23 +309,23 @@ func (p *CSVProcessor) parseCSVRecord(record []string, lineNumber int, headerMap
 		errors = append(errors, "source_id must be 50 characters or less")
 	}
 
-	csvRecord.TransactionType = strings.TrimSpace(strings.ToUpper(record[headerMap.TransactionType]))
-	if !p.isValidTransactionType(csvRecord.TransactionType) {
-		errors = append(errors, "invalid transaction_type: must be BUY, SELL, SHORT, COVER, DEP, WD, IN, or OUT")
+	csvRecord.TransactionType = p.mapper.NormalizeTransactionType(record[headerMap.TransactionType])
+	if !p.mapper.IsValidTransactionType(csvRecord.TransactionType) {
+		errors = append(errors, "invalid transaction_type: must be BUY, SELL, SHORT, COVER, DEP, WD, IN, OUT or a configured alias")
 	}
 
 	csvRecord.Quantity = strings.TrimSpace(record[headerMap.Quantity])
 	if csvRecord.Quantity == "" {
 		errors = append(errors, "quantity is required")
-	} else if _, err := decimal.NewFromString(csvRecord.Quantity); err != nil {
-		errors = append(errors, "quantity must be a valid decimal number")
+	} else if _, err := p.decimalFormat.Parse(csvRecord.Quantity); err != nil {
+		errors = append(errors, fmt.Sprintf("quantity must be a valid decimal number: %v", err))
 	}
 
 	csvRecord.Price = strings.TrimSpace(record[headerMap.Price])
 	if csvRecord.Price == "" {
 		errors = append(errors, "price is required")
-	} else if _, err := decimal.NewFromString(csvRecord.Price); err != nil {
-		errors = append(errors, "price must be a valid decimal number")
+	} else if _, err := p.decimalFormat.Parse(csvRecord.Price); err != nil {
+		errors = append(errors, fmt.Sprintf("price must be a valid decimal number: %v", err))
 	}
 
 	csvRecord.TransactionDate = strings.TrimSpace(record[headerMap.TransactionDate])
@@ -316,12 +353,12 @@ func (p *CSVProcessor) ConvertToTransactionDTO(csvRecord *CSVTransactionRecord)
 		return nil, fmt.Errorf("cannot convert invalid CSV record")
 	}
 
-	quantity, err := decimal.NewFromString(csvRecord.Quantity)
+	quantity, err := p.decimalFormat.Parse(csvRecord.Quantity)
 	if err != nil {
 		return nil, fmt.Errorf("invalid quantity: %w", err)
 	}
 
-	price, err := decimal.NewFromString(csvRecord.Price)
+	price, err := p.decimalFormat.Parse(csvRecord.Price)
 	if err != nil {
 		return nil, fmt.Errorf("invalid price: %w", err)
 	}
@@ -337,7 +374,7 @@ func (p *CSVProcessor) ConvertToTransactionDTO(csvRecord *CSVTransactionRecord)
 		SourceID:        csvRecord.SourceID,
 		TransactionType: csvRecord.TransactionType,
 		Quantity:        quantity,
-		Price:           price,
+		Price:           &price,
 		TransactionDate: csvRecord.TransactionDate, // Keep as string (YYYYMMDD format)
 	}
 
@@ -375,21 +412,6 @@ func (p *CSVProcessor) countLines(filePath string) (int, error) {
 	return lineCount, nil
 }
 
-// isValidTransactionType validates transaction type
-func (p *CSVProcessor) isValidTransactionType(txType string) bool {
-	validTypes := map[string]bool{
-		"BUY":   true,
-		"SELL":  true,
-		"SHORT": true,
-		"COVER": true,
-		"DEP":   true,
-		"WD":    true,
-		"IN":    true,
-		"OUT":   true,
-	}
-	return validTypes[txType]
-}
-
 // isValidDateFormat validates date format (YYYYMMDD)
 func (p *CSVProcessor) isValidDateFormat(dateStr string) bool {
 	if len(dateStr) != 8 {
@@ -438,8 +460,8 @@ func (p *CSVProcessor) GenerateStatistics(records []*CSVTransactionRecord) *File
 			}
 
 			// Calculate total amount (quantity * price)
-			if quantity, err := decimal.NewFromString(record.Quantity); err == nil {
-				if price, err := decimal.NewFromString(record.Price); err == nil {
+			if quantity, err := p.decimalFormat.Parse(record.Quantity); err == nil {
+				if price, err := p.decimalFormat.Parse(record.Price); err == nil {
 					amount := quantity.Mul(price)
 					totalAmount = totalAmount.Add(amount.Abs()) // Use absolute value for total
 				}
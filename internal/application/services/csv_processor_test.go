@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/mappers"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// bomCSV is an Excel-style export: a UTF-8 byte order mark before the first header and
+// whitespace around the header names
+const bomCSV = "\xEF\xBB\xBFportfolio_id , Security_ID,source_id ,transaction_type,quantity,price, transaction_date\n" +
+	"PORTFOLIO000000000000001,,DEP-BOM-1,DEP,1000,1,20240102\n"
+
+func writeBOMFile(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "transactions.csv")
+	require.NoError(t, os.WriteFile(path, []byte(bomCSV), 0644))
+	return path
+}
+
+func TestCSVProcessor_ResolvesHeadersWithBOM(t *testing.T) {
+	path := writeBOMFile(t)
+	processor := NewCSVProcessor(logger.NewNoop())
+
+	headers, err := processor.ValidateHeaders(path)
+	require.NoError(t, err)
+	assert.Equal(t, 0, headers.PortfolioID)
+	assert.Equal(t, 1, headers.SecurityID)
+	assert.Equal(t, 6, headers.TransactionDate)
+
+	records, err := processor.ReadCSVFile(context.Background(), path, nil)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.True(t, records[0].Valid, records[0].ErrorMessage)
+	assert.Equal(t, "PORTFOLIO000000000000001", records[0].PortfolioID)
+}
+
+func TestCSVProcessor_TransactionTypeAliases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.csv")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n"+
+			"PORTFOLIO000000000000001,,DEP-1,deposit,1000,1,20240102\n"+
+			"PORTFOLIO000000000000001,SECURITY0000000000000001,BUY-1, Purchase ,10,5,20240102\n"+
+			"PORTFOLIO000000000000001,SECURITY0000000000000001,XFER-1,TRANSFER,10,5,20240102\n"), 0644))
+
+	processor := NewCSVProcessor(logger.NewNoop()).WithTransactionMapper(
+		mappers.NewTransactionMapper().WithTransactionTypeAliases(map[string]string{"PURCHASE": "BUY", "Deposit": "DEP"}))
+
+	records, err := processor.ReadCSVFile(context.Background(), path, nil)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+
+	// Aliases resolve to their types, so the cash rule applies to the aliased deposit too
+	assert.True(t, records[0].Valid, records[0].ErrorMessage)
+	assert.Equal(t, "DEP", records[0].TransactionType)
+	assert.True(t, records[1].Valid, records[1].ErrorMessage)
+	assert.Equal(t, "BUY", records[1].TransactionType)
+	assert.False(t, records[2].Valid)
+	assert.Contains(t, records[2].ErrorMessage, "configured alias")
+}
+
+func TestFileProcessor_ReadAndSortCSVFileWithBOM(t *testing.T) {
+	path := writeBOMFile(t)
+	dir := filepath.Dir(path)
+	service := NewFileProcessorService(nil, FileProcessorConfig{
+		WorkingDirectory:   dir,
+		ErrorFileDirectory: filepath.Join(dir, "errors"),
+	}, logger.NewNoop()).(*fileProcessorService)
+
+	records, err := service.readAndSortCSVFile(path)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "PORTFOLIO000000000000001", records[0].PortfolioID)
+	assert.Nil(t, records[0].SecurityID)
+	assert.Equal(t, "DEP-BOM-1", records[0].SourceID)
+	assert.Equal(t, "20240102", records[0].TransactionDate)
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+)
+
+// DecimalFormat describes how quantities and prices are written in transaction files. The
+// zero value is the US format without digit grouping, e.g. 1234.56.
+type DecimalFormat struct {
+	// DecimalSeparator separates the integer and fractional digits; empty means "."
+	DecimalSeparator string
+	// ThousandsSeparator groups the integer digits in threes; empty means no grouping
+	ThousandsSeparator string
+}
+
+// decimalSeparatorCandidates are the characters files use as decimal or thousands separators
+var decimalSeparatorCandidates = []string{".", ",", "'", " "}
+
+// Parse converts a value written in this format to a decimal. Values that are ambiguous under
+// the format are rejected rather than guessed at: a separator the format does not define,
+// several decimal separators, or digit groups that are not threes.
+func (f DecimalFormat) Parse(value string) (decimal.Decimal, error) {
+	decimalSep := f.decimalSeparator()
+	value = strings.TrimSpace(value)
+	if dto.IsNonFiniteDecimal(value) {
+		return decimal.Decimal{}, fmt.Errorf("%q is not a finite number", value)
+	}
+
+	for _, candidate := range decimalSeparatorCandidates {
+		if candidate != decimalSep && candidate != f.ThousandsSeparator && strings.Contains(value, candidate) {
+			return decimal.Decimal{}, fmt.Errorf("%q contains %q, which is neither the decimal separator %q nor the thousands separator", value, candidate, decimalSep)
+		}
+	}
+	if strings.Count(value, decimalSep) > 1 {
+		return decimal.Decimal{}, fmt.Errorf("%q contains more than one decimal separator %q", value, decimalSep)
+	}
+
+	integerPart, fractionPart, hasFraction := strings.Cut(value, decimalSep)
+	if f.ThousandsSeparator != "" {
+		if strings.Contains(fractionPart, f.ThousandsSeparator) {
+			return decimal.Decimal{}, fmt.Errorf("%q has a thousands separator %q after the decimal separator", value, f.ThousandsSeparator)
+		}
+		if strings.Contains(integerPart, f.ThousandsSeparator) {
+			if !validDigitGroups(strings.TrimLeft(integerPart, "+-"), f.ThousandsSeparator) {
+				return decimal.Decimal{}, fmt.Errorf("%q does not group digits in threes with thousands separator %q", value, f.ThousandsSeparator)
+			}
+			integerPart = strings.ReplaceAll(integerPart, f.ThousandsSeparator, "")
+		}
+	}
+
+	normalized := integerPart
+	if hasFraction {
+		normalized += "." + fractionPart
+	}
+
+	parsed, err := decimal.NewFromString(normalized)
+	if err != nil {
+		return decimal.Decimal{}, fmt.Errorf("%q is not a valid decimal number", value)
+	}
+	return parsed, nil
+}
+
+// decimalSeparator returns the decimal separator, defaulting to "."
+func (f DecimalFormat) decimalSeparator() string {
+	if f.DecimalSeparator == "" {
+		return "."
+	}
+	return f.DecimalSeparator
+}
+
+// validDigitGroups reports whether digits separated by sep form a leading group of one to
+// three digits followed by groups of exactly three
+func validDigitGroups(integerPart, sep string) bool {
+	groups := strings.Split(integerPart, sep)
+	for i, group := range groups {
+		if i == 0 && (len(group) < 1 || len(group) > 3) {
+			return false
+		}
+		if i > 0 && len(group) != 3 {
+			return false
+		}
+		for _, r := range group {
+			if r < '0' || r > '9' {
+				return false
+			}
+		}
+	}
+	return true
+}
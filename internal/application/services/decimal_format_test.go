@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimalFormat_Parse(t *testing.T) {
+	us := DecimalFormat{}
+	usGrouped := DecimalFormat{DecimalSeparator: ".", ThousandsSeparator: ","}
+	european := DecimalFormat{DecimalSeparator: ",", ThousandsSeparator: "."}
+	commaOnly := DecimalFormat{DecimalSeparator: ","}
+	swiss := DecimalFormat{DecimalSeparator: ".", ThousandsSeparator: "'"}
+
+	tests := []struct {
+		name     string
+		format   DecimalFormat
+		value    string
+		expected string
+		errorMsg string
+	}{
+		{name: "US default", format: us, value: "1234.56", expected: "1234.56"},
+		{name: "US default rejects grouping", format: us, value: "1,234.56", errorMsg: "neither the decimal separator"},
+		{name: "US default negative", format: us, value: " -0.5 ", expected: "-0.5"},
+		{name: "US grouped", format: usGrouped, value: "1,234,567.89", expected: "1234567.89"},
+		{name: "Comma decimal", format: commaOnly, value: "1234,56", expected: "1234.56"},
+		{name: "Comma decimal integer", format: commaOnly, value: "100", expected: "100"},
+		{name: "Comma decimal rejects a point", format: commaOnly, value: "1234.56", errorMsg: "neither the decimal separator"},
+		{name: "European grouped", format: european, value: "1.234.567,89", expected: "1234567.89"},
+		{name: "European negative grouped", format: european, value: "-12.500,5", expected: "-12500.5"},
+		{name: "European ungrouped", format: european, value: "12500,5", expected: "12500.5"},
+		{name: "European misplaced group", format: european, value: "1.23,5", errorMsg: "group digits in threes"},
+		{name: "European group after decimal", format: european, value: "1,234.5", errorMsg: "after the decimal separator"},
+		{name: "Several decimal separators", format: european, value: "1,2,3", errorMsg: "more than one decimal separator"},
+		{name: "Swiss grouped", format: swiss, value: "1'234.5", expected: "1234.5"},
+		{name: "Not a number", format: commaOnly, value: "abc", errorMsg: "not a valid decimal number"},
+		{name: "Empty group", format: european, value: ".234,5", errorMsg: "group digits in threes"},
+		{name: "NaN", format: us, value: "NaN", errorMsg: "not a finite number"},
+		{name: "Negative infinity", format: european, value: "-Infinity", errorMsg: "not a finite number"},
+		{name: "Short infinity", format: us, value: "+inf", errorMsg: "not a finite number"},
+		{name: "Exponent", format: us, value: "1e400", expected: "1e400"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := tt.format.Parse(tt.value)
+			if tt.errorMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, decimal.RequireFromString(tt.expected).Equal(parsed), "expected %s, got %s", tt.expected, parsed)
+		})
+	}
+}
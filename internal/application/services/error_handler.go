@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -147,16 +148,20 @@ func (e *ErrorHandler) generateMultipleErrorFiles(ctx context.Context, originalF
 	return errorFiles[0], nil
 }
 
-// writeErrorFile writes error records to a CSV file
+// writeErrorFile writes error records to a CSV file; the file only appears once complete
 func (e *ErrorHandler) writeErrorFile(filePath string, errorRecords []*ErrorRecord, originalHeaders []string, options ErrorFileOptions) error {
-	file, err := os.Create(filePath)
+	err := WriteFileAtomic(filePath, func(w io.Writer) error {
+		return e.writeErrorRecords(w, errorRecords, originalHeaders, options)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create error file: %w", err)
+		return fmt.Errorf("failed to write error file: %w", err)
 	}
-	defer file.Close()
+	return nil
+}
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+// writeErrorRecords writes the header and error records of an error file as CSV
+func (e *ErrorHandler) writeErrorRecords(w io.Writer, errorRecords []*ErrorRecord, originalHeaders []string, options ErrorFileOptions) error {
+	writer := csv.NewWriter(w)
 
 	// Prepare headers
 	var headers []string
@@ -194,7 +199,8 @@ func (e *ErrorHandler) writeErrorFile(filePath string, errorRecords []*ErrorReco
 		}
 	}
 
-	return nil
+	writer.Flush()
+	return writer.Error()
 }
 
 // CreateErrorRecordsFromCSV creates error records from CSV validation failures
@@ -244,7 +250,7 @@ func (e *ErrorHandler) CreateErrorRecordsFromBatch(batchResponse *dto.Transactio
 				failed.Transaction.SourceID,
 				failed.Transaction.TransactionType,
 				failed.Transaction.Quantity.String(),
-				failed.Transaction.Price.String(),
+				failed.Transaction.PriceString(),
 				failed.Transaction.TransactionDate,
 			},
 			LineNumber:      0, // Line number not available in batch response
@@ -385,7 +391,7 @@ func (e *ErrorHandler) CreateErrorRecordFromTransaction(transaction *dto.Transac
 			transaction.SourceID,
 			transaction.TransactionType,
 			transaction.Quantity.String(),
-			transaction.Price.String(),
+			transaction.PriceString(),
 			transaction.TransactionDate,
 		},
 		LineNumber:      lineNumber,
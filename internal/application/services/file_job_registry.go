@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+)
+
+// File processing job states
+const (
+	FileStatusProcessing = "PROCESSING"
+	FileStatusCompleted  = "COMPLETED"
+	FileStatusFailed     = "FAILED"
+	FileStatusStopped    = "STOPPED" // Hit the processing time limit; can be resumed
+)
+
+// IsTerminalFileStatus reports whether a file processing job in this state is no longer running
+func IsTerminalFileStatus(status string) bool {
+	return status == FileStatusCompleted || status == FileStatusFailed || status == FileStatusStopped
+}
+
+// fileJobRegistry holds the latest status of every file processing job and notifies watchers
+// of each update. Statuses are stored and handed out as copies, so a job can keep updating its
+// working status without readers seeing it change underneath them.
+type fileJobRegistry struct {
+	mu       sync.RWMutex
+	statuses map[string]*dto.FileProcessingStatus
+	watchers map[string]map[chan dto.FileProcessingStatus]struct{}
+}
+
+// newFileJobRegistry creates an empty job registry
+func newFileJobRegistry() *fileJobRegistry {
+	return &fileJobRegistry{
+		statuses: make(map[string]*dto.FileProcessingStatus),
+		watchers: make(map[string]map[chan dto.FileProcessingStatus]struct{}),
+	}
+}
+
+// begin registers a new job for filename, failing if one is still running
+func (r *fileJobRegistry) begin(status *dto.FileProcessingStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.statuses[status.Filename]; ok && !IsTerminalFileStatus(existing.Status) {
+		return fmt.Errorf("file %s is already being processed", status.Filename)
+	}
+
+	r.publishLocked(status)
+	return nil
+}
+
+// publish records a snapshot of status and passes it to the job's watchers
+func (r *fileJobRegistry) publish(status *dto.FileProcessingStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.publishLocked(status)
+}
+
+func (r *fileJobRegistry) publishLocked(status *dto.FileProcessingStatus) {
+	snapshot := *status
+	r.statuses[status.Filename] = &snapshot
+
+	for ch := range r.watchers[status.Filename] {
+		// Watchers only need the latest state: replace an update they have not read yet
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- snapshot
+	}
+}
+
+// get returns a copy of the latest status of a job
+func (r *fileJobRegistry) get(filename string) (dto.FileProcessingStatus, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	status, ok := r.statuses[filename]
+	if !ok {
+		return dto.FileProcessingStatus{}, false
+	}
+	return *status, true
+}
+
+// list returns copies of the latest status of every job
+func (r *fileJobRegistry) list() []dto.FileProcessingStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]dto.FileProcessingStatus, 0, len(r.statuses))
+	for _, status := range r.statuses {
+		statuses = append(statuses, *status)
+	}
+	return statuses
+}
+
+// watch returns a channel that first receives the current status of a job and then each
+// update. Intermediate updates may be skipped if the reader falls behind, but the terminal
+// status is always delivered, after which the channel is closed. The channel is also closed
+// when ctx is done.
+func (r *fileJobRegistry) watch(ctx context.Context, filename string) (<-chan dto.FileProcessingStatus, error) {
+	r.mu.Lock()
+	current, ok := r.statuses[filename]
+	if !ok {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("no processing status found for file: %s", filename)
+	}
+
+	updates := make(chan dto.FileProcessingStatus, 1)
+	updates <- *current
+	if r.watchers[filename] == nil {
+		r.watchers[filename] = make(map[chan dto.FileProcessingStatus]struct{})
+	}
+	r.watchers[filename][updates] = struct{}{}
+	r.mu.Unlock()
+
+	out := make(chan dto.FileProcessingStatus)
+	go func() {
+		defer close(out)
+		defer r.unwatch(filename, updates)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case status := <-updates:
+				select {
+				case out <- status:
+				case <-ctx.Done():
+					return
+				}
+				if IsTerminalFileStatus(status.Status) {
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// unwatch stops delivering updates to a watcher
+func (r *fileJobRegistry) unwatch(filename string, updates chan dto.FileProcessingStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.watchers[filename], updates)
+	if len(r.watchers[filename]) == 0 {
+		delete(r.watchers, filename)
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// fileProcessingMeterName is the instrumentation scope of the file processing metrics
+const fileProcessingMeterName = "globeco-portfolio-accounting-service/file-processing"
+
+// registerLastSuccessGauges exposes the most recent completed file processing run as gauges,
+// so monitoring can alert when no file has been processed recently. Nothing is observed until
+// a run has completed. A nil provider uses the global meter provider; gauges that fail to
+// initialize are skipped.
+func registerLastSuccessGauges(provider metric.MeterProvider, lastSuccess func() *dto.FileProcessingSuccessDTO, lg logger.Logger) {
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	meter := provider.Meter(fileProcessingMeterName)
+
+	timestamp, err := meter.Float64ObservableGauge(
+		"file_processing_last_success_timestamp_seconds",
+		metric.WithDescription("Unix time at which the most recent successful file processing run completed"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		lg.Warn("Failed to create last successful file processing timestamp gauge", logger.Err(err))
+	}
+
+	records, err := meter.Int64ObservableGauge(
+		"file_processing_last_success_records",
+		metric.WithDescription("Records processed by the most recent successful file processing run"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		lg.Warn("Failed to create last successful file processing records gauge", logger.Err(err))
+	}
+
+	var instruments []metric.Observable
+	if timestamp != nil {
+		instruments = append(instruments, timestamp)
+	}
+	if records != nil {
+		instruments = append(instruments, records)
+	}
+	if len(instruments) == 0 {
+		return
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+		success := lastSuccess()
+		if success == nil {
+			return nil
+		}
+		if timestamp != nil {
+			observer.ObserveFloat64(timestamp, float64(success.CompletedAt.UnixNano())/1e9)
+		}
+		if records != nil {
+			observer.ObserveInt64(records, int64(success.ProcessedRecords))
+		}
+		return nil
+	}, instruments...)
+	if err != nil {
+		lg.Warn("Failed to register last successful file processing callback", logger.Err(err))
+	}
+}
+
+// newBatchDurationHistogram creates the histogram of how long each batch of a file processing
+// run took to be answered. A nil provider uses the global meter provider; if the histogram
+// fails to initialize nil is returned and batches are not recorded.
+func newBatchDurationHistogram(provider metric.MeterProvider, lg logger.Logger) metric.Float64Histogram {
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+
+	histogram, err := provider.Meter(fileProcessingMeterName).Float64Histogram(
+		"file_processing_batch_duration_seconds",
+		metric.WithDescription("Time taken to create and process a batch of a transaction file"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300),
+	)
+	if err != nil {
+		lg.Warn("Failed to create file processing batch duration histogram", logger.Err(err))
+		return nil
+	}
+	return histogram
+}
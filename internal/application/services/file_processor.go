@@ -1,27 +1,38 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
-	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // FileProcessorService interface defines file processing operations
 type FileProcessorService interface {
 	// File processing operations
-	ProcessTransactionFile(ctx context.Context, filename string) (*dto.FileProcessingStatus, error)
+	// A file whose name and content match a completed run is rejected with a
+	// FileAlreadyProcessedError unless force is set
+	ProcessTransactionFile(ctx context.Context, filename string, force bool) (*dto.FileProcessingStatus, error)
+	StartTransactionFile(ctx context.Context, filename string, force bool) (*dto.FileProcessingStatus, error)
 	GetFileProcessingStatus(ctx context.Context, filename string) (*dto.FileProcessingStatus, error)
 	ListFileProcessingStatus(ctx context.Context, filter dto.FileProcessingFilter) ([]dto.FileProcessingStatus, error)
+	WatchFileProcessing(ctx context.Context, filename string) (<-chan dto.FileProcessingStatus, error)
 
 	// Validation operations
 	ValidateTransactionFile(ctx context.Context, filename string) (*FileValidationResult, error)
@@ -31,6 +42,8 @@ type FileProcessorService interface {
 
 	// Health and monitoring
 	GetServiceHealth(ctx context.Context) error
+	CheckDirectories(ctx context.Context) dto.FileDirectoriesHealthDTO
+	GetLastSuccessfulProcessing(ctx context.Context) (*dto.FileProcessingSuccessDTO, error)
 }
 
 // fileProcessorService implements FileProcessorService interface
@@ -39,8 +52,21 @@ type fileProcessorService struct {
 	config             FileProcessorConfig
 	logger             logger.Logger
 
-	// In-memory storage for processing status (in production, this would be persistent)
-	processingStatus map[string]*dto.FileProcessingStatus
+	// In-memory registry of processing jobs (in production, this would be persistent)
+	jobs *fileJobRegistry
+
+	// Progress markers that let an interrupted job resume where it stopped
+	progress *fileProgressStore
+
+	// Completed runs by file name and content hash
+	processed *ProcessedFiles
+
+	// Most recent completed run, also persisted in the progress directory
+	lastSuccessMu sync.RWMutex
+	lastSuccess   *dto.FileProcessingSuccessDTO
+
+	// batchDuration is nil when the histogram failed to initialize
+	batchDuration metric.Float64Histogram
 }
 
 // FileProcessorConfig holds configuration for file processor service
@@ -51,8 +77,107 @@ type FileProcessorConfig struct {
 	MaxRecordsPerBatch int
 	TimeoutPerBatch    time.Duration
 	RequiredHeaders    []string
+
+	// MaxProcessingDuration bounds a whole processing run; zero means unlimited. A run that
+	// reaches it stops between batches with status STOPPED and a resume checkpoint.
+	MaxProcessingDuration time.Duration
+
+	// ProgressDirectory holds the progress markers of unfinished jobs; it defaults to a
+	// .progress directory inside the working directory
+	ProgressDirectory string
+
+	// DecimalFormat is how quantities and prices are written; the zero value is the US format
+	DecimalFormat DecimalFormat
+
+	// ErrorFileNaming is how error files are named; empty means ErrorFileNamingFixed
+	ErrorFileNaming ErrorFileNaming
+
+	// SlowBatchThreshold is how long a batch may take before it is logged as slow; zero turns
+	// the warning off
+	SlowBatchThreshold time.Duration
+
+	// StrictFieldCount rejects every row whose number of fields differs from the header's as a
+	// failed record, instead of leaving the missing fields blank and ignoring extra ones
+	StrictFieldCount bool
+
+	// MeterProvider exposes the last successful run as gauges and records batch durations; nil
+	// uses the global provider
+	MeterProvider metric.MeterProvider
+}
+
+// ErrorFileNaming selects the name of the error file a processing run writes
+type ErrorFileNaming string
+
+const (
+	// ErrorFileNamingFixed names the file <base>-errors.csv, so a new run of the same file
+	// replaces the error file of the previous run
+	ErrorFileNamingFixed ErrorFileNaming = "fixed"
+	// ErrorFileNamingTimestamp adds the UTC start time of the run, e.g.
+	// <base>-errors-20240610T153000.123Z.csv
+	ErrorFileNamingTimestamp ErrorFileNaming = "timestamp"
+	// ErrorFileNamingRunID adds a random ID generated for the run: <base>-errors-<run id>.csv
+	ErrorFileNamingRunID ErrorFileNaming = "run_id"
+)
+
+// IsValid checks if the error file naming is one of the supported schemes
+func (n ErrorFileNaming) IsValid() bool {
+	switch n {
+	case ErrorFileNamingFixed, ErrorFileNamingTimestamp, ErrorFileNamingRunID:
+		return true
+	}
+	return false
+}
+
+// DefaultMaxFileSize is the largest transaction file processed when no limit is configured
+const DefaultMaxFileSize int64 = 100 * 1024 * 1024 // 100MB
+
+// FileTooLargeError is returned for a transaction file larger than MaxFileSize
+type FileTooLargeError struct {
+	Size  int64
+	Limit int64
+}
+
+// Error implements the error interface
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("file size exceeds limit: %d > %d", e.Size, e.Limit)
+}
+
+// FileAlreadyProcessedError is returned for a file whose name and content hash match a run
+// that already completed; processing it again would submit the same transactions twice
+type FileAlreadyProcessedError struct {
+	Previous dto.FileProcessingStatus
+}
+
+// Error implements the error interface
+func (e *FileAlreadyProcessedError) Error() string {
+	completedAt := ""
+	if e.Previous.CompletedAt != nil {
+		completedAt = " at " + e.Previous.CompletedAt.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprintf("file %s with content hash %s was already processed%s", e.Previous.Filename, e.Previous.ContentHash, completedAt)
+}
+
+// fileSizeLimitReader fails once more than limit bytes were read, so a file that grew past the
+// limit after its size was checked is still rejected while it is streamed
+type fileSizeLimitReader struct {
+	reader io.Reader
+	read   int64
+	limit  int64
+}
+
+// Read implements io.Reader
+func (r *fileSizeLimitReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	if r.read > r.limit {
+		return n, &FileTooLargeError{Size: r.read, Limit: r.limit}
+	}
+	return n, err
 }
 
+// errProcessingTimeLimit stops a processing run that reached MaxProcessingDuration
+var errProcessingTimeLimit = errors.New("file processing time limit reached")
+
 // FileValidationResult represents the result of file validation
 type FileValidationResult struct {
 	IsValid      bool                   `json:"isValid"`
@@ -87,8 +212,13 @@ type CSVRecord struct {
 	Quantity        string
 	Price           string
 	TransactionDate string
+	SettlementDate  *string
 	ErrorMessage    string
 	LineNumber      int
+
+	// fieldCountErr is set for a row whose field count differs from the header's when
+	// StrictFieldCount is enabled
+	fieldCountErr error
 }
 
 // NewFileProcessorService creates a new file processor service
@@ -108,8 +238,11 @@ func NewFileProcessorService(
 	if config.ErrorFileDirectory == "" {
 		config.ErrorFileDirectory = "./data/errors"
 	}
+	if config.ProgressDirectory == "" {
+		config.ProgressDirectory = filepath.Join(config.WorkingDirectory, ".progress")
+	}
 	if config.MaxFileSize == 0 {
-		config.MaxFileSize = 100 * 1024 * 1024 // 100MB
+		config.MaxFileSize = DefaultMaxFileSize
 	}
 	if config.MaxRecordsPerBatch == 0 {
 		config.MaxRecordsPerBatch = 1000
@@ -117,6 +250,9 @@ func NewFileProcessorService(
 	if config.TimeoutPerBatch == 0 {
 		config.TimeoutPerBatch = 5 * time.Minute
 	}
+	if !config.ErrorFileNaming.IsValid() {
+		config.ErrorFileNaming = ErrorFileNamingFixed
+	}
 	if len(config.RequiredHeaders) == 0 {
 		config.RequiredHeaders = []string{
 			"portfolio_id", "security_id", "source_id", "transaction_type",
@@ -128,51 +264,144 @@ func NewFileProcessorService(
 	os.MkdirAll(config.WorkingDirectory, 0755)
 	os.MkdirAll(config.ErrorFileDirectory, 0755)
 
-	return &fileProcessorService{
+	service := &fileProcessorService{
 		transactionService: transactionService,
 		config:             config,
 		logger:             lg,
-		processingStatus:   make(map[string]*dto.FileProcessingStatus),
+		jobs:               newFileJobRegistry(),
+		progress:           newFileProgressStore(config.ProgressDirectory),
+		processed:          NewProcessedFiles(config.ProgressDirectory, lg),
+		batchDuration:      newBatchDurationHistogram(config.MeterProvider, lg),
+	}
+
+	lastSuccess, err := service.progress.loadLastSuccess()
+	if err != nil {
+		lg.Warn("Failed to load last successful file processing run", logger.Err(err))
 	}
+	service.lastSuccess = lastSuccess
+	registerLastSuccessGauges(config.MeterProvider, service.getLastSuccess, lg)
+
+	return service
 }
 
 // ProcessTransactionFile processes a CSV transaction file
-func (s *fileProcessorService) ProcessTransactionFile(ctx context.Context, filename string) (*dto.FileProcessingStatus, error) {
-	s.logger.Info("Starting file processing",
-		logger.String("filename", filename))
+func (s *fileProcessorService) ProcessTransactionFile(ctx context.Context, filename string, force bool) (*dto.FileProcessingStatus, error) {
+	contentHash, err := s.checkNotProcessed(filename, force)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := s.beginFile(filename, contentHash)
+	if err != nil {
+		return nil, err
+	}
+	return s.processFile(ctx, status)
+}
+
+// StartTransactionFile registers a processing job for a CSV transaction file and processes it in
+// the background. The returned status is the job's initial state; progress can be followed with
+// GetFileProcessingStatus or WatchFileProcessing. The job outlives the caller's context.
+func (s *fileProcessorService) StartTransactionFile(ctx context.Context, filename string, force bool) (*dto.FileProcessingStatus, error) {
+	// Reject an oversized file before starting a job; a missing file fails the job itself
+	if fileInfo, err := os.Stat(filepath.Join(s.config.WorkingDirectory, filename)); err == nil {
+		if err := s.checkFileSize(fileInfo); err != nil {
+			return nil, err
+		}
+	}
+
+	contentHash, err := s.checkNotProcessed(filename, force)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := s.beginFile(filename, contentHash)
+	if err != nil {
+		return nil, err
+	}
+	initial := *status
+
+	go func() {
+		if _, err := s.processFile(context.WithoutCancel(ctx), status); err != nil {
+			s.logger.Error("Background file processing failed",
+				logger.String("filename", filename),
+				logger.Err(err))
+		}
+	}()
 
-	// Initialize processing status
+	return &initial, nil
+}
+
+// checkNotProcessed returns the content hash of filename, failing with a
+// FileAlreadyProcessedError if a completed run had the same name and hash and force is not set
+func (s *fileProcessorService) checkNotProcessed(filename string, force bool) (string, error) {
+	return s.processed.Check(filepath.Join(s.config.WorkingDirectory, filename), force)
+}
+
+// hashFile returns the hex encoded SHA-256 hash of a file's content
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// beginFile registers a new processing job for filename
+func (s *fileProcessorService) beginFile(filename, contentHash string) (*dto.FileProcessingStatus, error) {
 	status := &dto.FileProcessingStatus{
 		Filename:         filename,
-		Status:           "PROCESSING",
+		Status:           FileStatusProcessing,
 		StartedAt:        time.Now(),
 		TotalRecords:     0,
 		ProcessedRecords: 0,
 		FailedRecords:    0,
+		ContentHash:      contentHash,
+	}
+	if err := s.jobs.begin(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// processFile runs a registered job, publishing its progress to the job registry
+func (s *fileProcessorService) processFile(ctx context.Context, status *dto.FileProcessingStatus) (*dto.FileProcessingStatus, error) {
+	filename := status.Filename
+	s.logger.Info("Starting file processing",
+		logger.String("filename", filename))
+
+	fail := func(err error) (*dto.FileProcessingStatus, error) {
+		status.Status = FileStatusFailed
+		status.CompletedAt = timePtr(time.Now())
+		s.jobs.publish(status)
+		return status, err
+	}
+
+	var deadline time.Time
+	if s.config.MaxProcessingDuration > 0 {
+		deadline = time.Now().Add(s.config.MaxProcessingDuration)
 	}
-	s.processingStatus[filename] = status
 
 	// Validate file existence and size
 	fullPath := filepath.Join(s.config.WorkingDirectory, filename)
 	fileInfo, err := os.Stat(fullPath)
 	if err != nil {
-		status.Status = "FAILED"
-		status.CompletedAt = timePtr(time.Now())
-		return status, fmt.Errorf("file not found: %w", err)
+		return fail(fmt.Errorf("file not found: %w", err))
 	}
 
-	if fileInfo.Size() > s.config.MaxFileSize {
-		status.Status = "FAILED"
-		status.CompletedAt = timePtr(time.Now())
-		return status, fmt.Errorf("file size exceeds limit: %d > %d", fileInfo.Size(), s.config.MaxFileSize)
+	if err := s.checkFileSize(fileInfo); err != nil {
+		return fail(err)
 	}
 
 	// Read and sort file
 	records, err := s.readAndSortCSVFile(fullPath)
 	if err != nil {
-		status.Status = "FAILED"
-		status.CompletedAt = timePtr(time.Now())
-		return status, fmt.Errorf("failed to read CSV file: %w", err)
+		return fail(fmt.Errorf("failed to read CSV file: %w", err))
 	}
 
 	status.TotalRecords = len(records)
@@ -180,29 +409,110 @@ func (s *fileProcessorService) ProcessTransactionFile(ctx context.Context, filen
 		logger.String("filename", filename),
 		logger.Int("totalRecords", len(records)))
 
-	// Process records by portfolio
-	errorRecords, err := s.processRecordsByPortfolio(ctx, records, status)
-	if err != nil {
-		status.Status = "FAILED"
-		status.CompletedAt = timePtr(time.Now())
-		return status, fmt.Errorf("failed to process records: %w", err)
+	// Pick up where an earlier, unfinished run of the same file stopped
+	marker := s.resumeFromMarker(filename, fileInfo, len(records), status)
+	start := marker.NextRecord
+	s.jobs.publish(status)
+
+	// Failed records are appended to the error file and the marker advanced after every
+	// batch, so both survive an interrupted run. A resumed run keeps the error file of the
+	// run it continues.
+	appendErrors := status.ErrorFilename != nil
+	errorFilename := s.errorFilename(filename, status.StartedAt)
+	if status.ErrorFilename != nil {
+		errorFilename = *status.ErrorFilename
 	}
-
-	// Create error file if there are failed records
-	if len(errorRecords) > 0 {
-		errorFilename, err := s.createErrorFile(filename, errorRecords)
+	errorsWritten := 0
+	flushErrors := func(errorRecords []CSVRecord) {
+		pending := errorRecords[errorsWritten:]
+		if len(pending) == 0 {
+			return
+		}
+		written, err := s.writeErrorFile(errorFilename, pending, appendErrors)
 		if err != nil {
-			s.logger.Error("Failed to create error file",
+			s.logger.Error("Failed to write error file",
+				logger.String("filename", filename),
+				logger.Err(err))
+			return
+		}
+		status.ErrorFilename = &written
+		errorsWritten = len(errorRecords)
+		appendErrors = true
+	}
+	saveMarker := func() {
+		if err := s.progress.save(marker); err != nil {
+			s.logger.Warn("Failed to save file progress marker",
 				logger.String("filename", filename),
 				logger.Err(err))
-		} else {
-			status.ErrorFilename = &errorFilename
 		}
 	}
+	checkpoint := func(next int, portfolioID string, errorRecords []CSVRecord) {
+		flushErrors(errorRecords)
+
+		marker.NextRecord = next
+		marker.InFlightEnd = 0
+		if portfolioID != "" {
+			marker.CheckpointPortfolio = portfolioID
+			status.CheckpointPortfolio = &portfolioID
+		}
+		marker.ProcessedRecords = status.ProcessedRecords
+		marker.FailedRecords = status.FailedRecords
+		marker.CompletedBatches = status.CompletedBatches
+		marker.ErrorFilename = status.ErrorFilename
+		saveMarker()
+	}
+	checkpoints := fileCheckpoints{
+		inFlight: func(end int) {
+			marker.InFlightEnd = end
+			saveMarker()
+		},
+		commit:        checkpoint,
+		recheckBefore: marker.InFlightEnd,
+	}
+
+	// Process records by portfolio
+	errorRecords, err := s.processRecordsByPortfolio(ctx, records, start, status, deadline, checkpoints)
+	stopped := errors.Is(err, errProcessingTimeLimit)
+	if err != nil && !stopped {
+		return fail(fmt.Errorf("failed to process records: %w", err))
+	}
+
+	if stopped {
+		checkpoint(*status.ResumeFromRecord, "", errorRecords)
+
+		status.Status = FileStatusStopped
+		status.CompletedAt = timePtr(time.Now())
+		s.jobs.publish(status)
+
+		s.logger.Warn("File processing stopped at the processing time limit",
+			logger.String("filename", filename),
+			logger.Duration("maxProcessingDuration", s.config.MaxProcessingDuration),
+			logger.Int("completedBatches", status.CompletedBatches),
+			logger.Int("resumeFromRecord", *status.ResumeFromRecord),
+			logger.Int("totalRecords", status.TotalRecords))
+
+		return status, nil
+	}
+
+	flushErrors(errorRecords)
+	if err := s.progress.remove(filename); err != nil {
+		s.logger.Warn("Failed to remove file progress marker",
+			logger.String("filename", filename),
+			logger.Err(err))
+	}
 
 	// Update final status
-	status.Status = "COMPLETED"
+	status.Status = FileStatusCompleted
 	status.CompletedAt = timePtr(time.Now())
+	// Recorded before the completion is published, so a client that saw it cannot start the
+	// same file again undetected
+	if err := s.processed.Record(status); err != nil {
+		s.logger.Warn("Failed to save completed file processing record",
+			logger.String("filename", filename),
+			logger.Err(err))
+	}
+	s.jobs.publish(status)
+	s.recordSuccess(status)
 
 	s.logger.Info("File processing completed",
 		logger.String("filename", filename),
@@ -213,7 +523,104 @@ func (s *fileProcessorService) ProcessTransactionFile(ctx context.Context, filen
 	return status, nil
 }
 
-// readAndSortCSVFile reads and sorts the CSV file by portfolio_id, transaction_date, transaction_type
+// recordSuccess remembers a completed run as the last successful one and persists it so it
+// survives restarts
+func (s *fileProcessorService) recordSuccess(status *dto.FileProcessingStatus) {
+	success := &dto.FileProcessingSuccessDTO{
+		Filename:         status.Filename,
+		CompletedAt:      *status.CompletedAt,
+		ProcessedRecords: status.ProcessedRecords,
+		FailedRecords:    status.FailedRecords,
+	}
+
+	s.lastSuccessMu.Lock()
+	s.lastSuccess = success
+	s.lastSuccessMu.Unlock()
+
+	if err := s.progress.saveLastSuccess(success); err != nil {
+		s.logger.Warn("Failed to save last successful file processing run",
+			logger.String("filename", status.Filename),
+			logger.Err(err))
+	}
+}
+
+// getLastSuccess returns the most recent completed run, or nil if there was none
+func (s *fileProcessorService) getLastSuccess() *dto.FileProcessingSuccessDTO {
+	s.lastSuccessMu.RLock()
+	defer s.lastSuccessMu.RUnlock()
+	return s.lastSuccess
+}
+
+// resumeFromMarker returns the progress marker for this run of a file. If an earlier run of the
+// same, unchanged file left a marker behind, the run continues from it: status takes over the
+// earlier counters and error file. Otherwise the run starts from the first record.
+func (s *fileProcessorService) resumeFromMarker(filename string, fileInfo os.FileInfo, totalRecords int, status *dto.FileProcessingStatus) *fileProgressMarker {
+	fresh := &fileProgressMarker{
+		Filename:    filename,
+		FileSize:    fileInfo.Size(),
+		FileModTime: fileInfo.ModTime(),
+	}
+
+	marker, err := s.progress.load(filename)
+	if err != nil {
+		s.logger.Warn("Ignoring unreadable file progress marker",
+			logger.String("filename", filename),
+			logger.Err(err))
+		return fresh
+	}
+	if marker == nil {
+		return fresh
+	}
+	if !marker.matches(fileInfo) || marker.NextRecord > totalRecords {
+		s.logger.Warn("File changed since its progress marker was written, processing from the start",
+			logger.String("filename", filename))
+		return fresh
+	}
+
+	status.ProcessedRecords = marker.ProcessedRecords
+	status.FailedRecords = marker.FailedRecords
+	status.CompletedBatches = marker.CompletedBatches
+	status.ErrorFilename = marker.ErrorFilename
+	resumedFrom := marker.NextRecord
+	status.ResumedFromRecord = &resumedFrom
+	if marker.CheckpointPortfolio != "" {
+		checkpointPortfolio := marker.CheckpointPortfolio
+		status.CheckpointPortfolio = &checkpointPortfolio
+	}
+
+	s.logger.Info("Resuming file processing from progress marker",
+		logger.String("filename", filename),
+		logger.String("checkpointPortfolio", marker.CheckpointPortfolio),
+		logger.Int("nextRecord", marker.NextRecord),
+		logger.Int("inFlightEnd", marker.InFlightEnd),
+		logger.Int("totalRecords", totalRecords))
+
+	return marker
+}
+
+// checkFileSize rejects a file larger than MaxFileSize
+func (s *fileProcessorService) checkFileSize(fileInfo os.FileInfo) error {
+	if fileInfo.Size() > s.config.MaxFileSize {
+		return &FileTooLargeError{Size: fileInfo.Size(), Limit: s.config.MaxFileSize}
+	}
+	return nil
+}
+
+// readFileWithinLimit checks the size of a file before reading and sorting its records
+func (s *fileProcessorService) readFileWithinLimit(filename string) ([]CSVRecord, error) {
+	fileInfo, err := os.Stat(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	if err := s.checkFileSize(fileInfo); err != nil {
+		return nil, err
+	}
+	return s.readAndSortCSVFile(filename)
+}
+
+// readAndSortCSVFile reads and sorts the CSV file by portfolio_id, transaction_date, transaction_type.
+// Records with the same keys keep their order in the file, so same-key transactions are always
+// processed in the same order.
 func (s *fileProcessorService) readAndSortCSVFile(filename string) ([]CSVRecord, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -221,8 +628,10 @@ func (s *fileProcessorService) readAndSortCSVFile(filename string) ([]CSVRecord,
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
-	reader.FieldsPerRecord = -1 // Allow variable number of fields
+	reader := newCSVReader(&fileSizeLimitReader{reader: file, limit: s.config.MaxFileSize})
+	// Field counts are checked per row with StrictFieldCount, so a ragged row fails on its own
+	// instead of stopping the read
+	reader.FieldsPerRecord = -1
 
 	// Read header
 	headers, err := reader.Read()
@@ -233,7 +642,7 @@ func (s *fileProcessorService) readAndSortCSVFile(filename string) ([]CSVRecord,
 	// Validate headers
 	headerMap := make(map[string]int)
 	for i, header := range headers {
-		headerMap[strings.ToLower(strings.TrimSpace(header))] = i
+		headerMap[normalizeCSVHeader(header)] = i
 	}
 
 	for _, requiredHeader := range s.config.RequiredHeaders {
@@ -242,6 +651,9 @@ func (s *fileProcessorService) readAndSortCSVFile(filename string) ([]CSVRecord,
 		}
 	}
 
+	// Any other column is ignored. That includes the error_message column of a re-ingested
+	// error file, so a corrected row is resubmitted without the message of the earlier run.
+
 	// Read all records
 	var records []CSVRecord
 	lineNumber := 2 // Starting from line 2 (after header)
@@ -258,6 +670,9 @@ func (s *fileProcessorService) readAndSortCSVFile(filename string) ([]CSVRecord,
 		record := CSVRecord{
 			LineNumber: lineNumber,
 		}
+		if s.config.StrictFieldCount && len(row) != len(headers) {
+			record.fieldCountErr = fmt.Errorf("line %d has %d fields, the header has %d", lineNumber, len(row), len(headers))
+		}
 
 		// Map fields from CSV
 		if idx, exists := headerMap["portfolio_id"]; exists && idx < len(row) {
@@ -284,40 +699,95 @@ func (s *fileProcessorService) readAndSortCSVFile(filename string) ([]CSVRecord,
 		if idx, exists := headerMap["transaction_date"]; exists && idx < len(row) {
 			record.TransactionDate = strings.TrimSpace(row[idx])
 		}
-		if idx, exists := headerMap["error_message"]; exists && idx < len(row) {
-			record.ErrorMessage = strings.TrimSpace(row[idx])
+		if idx, exists := headerMap["settlement_date"]; exists && idx < len(row) {
+			settlementDate := strings.TrimSpace(row[idx])
+			if settlementDate != "" {
+				record.SettlementDate = &settlementDate
+			}
 		}
 
 		records = append(records, record)
 		lineNumber++
 	}
 
-	// Sort records by portfolio_id, transaction_date, transaction_type
-	sort.Slice(records, func(i, j int) bool {
+	// Sort records by portfolio_id, transaction_date, transaction_type, then line number
+	sort.SliceStable(records, func(i, j int) bool {
 		if records[i].PortfolioID != records[j].PortfolioID {
 			return records[i].PortfolioID < records[j].PortfolioID
 		}
 		if records[i].TransactionDate != records[j].TransactionDate {
 			return records[i].TransactionDate < records[j].TransactionDate
 		}
-		return records[i].TransactionType < records[j].TransactionType
+		if records[i].TransactionType != records[j].TransactionType {
+			return records[i].TransactionType < records[j].TransactionType
+		}
+		return records[i].LineNumber < records[j].LineNumber
 	})
 
 	return records, nil
 }
 
-// processRecordsByPortfolio processes records grouped by portfolio
-func (s *fileProcessorService) processRecordsByPortfolio(ctx context.Context, records []CSVRecord, status *dto.FileProcessingStatus) ([]CSVRecord, error) {
+// fileCheckpoints is how processRecordsByPortfolio reports its progress around every batch
+type fileCheckpoints struct {
+	// inFlight is called before a batch is submitted with the position after its last record
+	inFlight func(end int)
+	// commit is called once a batch is answered with the position of the first record not yet
+	// handled, the portfolio of the batch and the failed records so far
+	commit func(next int, portfolioID string, errorRecords []CSVRecord)
+	// recheckBefore is the end of a batch an earlier run submitted but never saw answered.
+	// Records before it may already be stored and are checked before being submitted again.
+	recheckBefore int
+}
+
+// processRecordsByPortfolio processes records grouped by portfolio, starting at position start,
+// reporting its progress to checkpoints. With a non-zero deadline it stops before starting a
+// batch once the deadline has passed, recording in status the position of the first unhandled
+// record, and returns errProcessingTimeLimit with the errors so far.
+func (s *fileProcessorService) processRecordsByPortfolio(
+	ctx context.Context,
+	records []CSVRecord,
+	start int,
+	status *dto.FileProcessingStatus,
+	deadline time.Time,
+	checkpoints fileCheckpoints,
+) ([]CSVRecord, error) {
 	var errorRecords []CSVRecord
 	var currentBatch []dto.TransactionPostDTO
+	var currentPositions []int
 	var currentPortfolio string
 
-	for _, record := range records {
+	submit := func(end int) {
+		checkpoints.inFlight(end)
+
+		batch := currentBatch
+		if currentPositions[0] < checkpoints.recheckBefore {
+			var storedErrors []CSVRecord
+			batch, storedErrors = s.skipStoredRecords(ctx, currentBatch, currentPositions, checkpoints.recheckBefore, status)
+			errorRecords = append(errorRecords, storedErrors...)
+		}
+		if len(batch) > 0 {
+			batchErrors := s.processBatch(ctx, batch, status)
+			errorRecords = append(errorRecords, batchErrors...)
+		}
+
+		currentBatch, currentPositions = nil, nil
+		checkpoints.commit(end, currentPortfolio, errorRecords)
+	}
+
+	for i := start; i < len(records); i++ {
+		record := records[i]
+
 		// If we've moved to a new portfolio, process the current batch
 		if record.PortfolioID != currentPortfolio && len(currentBatch) > 0 {
-			batchErrors := s.processBatch(ctx, currentBatch, status)
-			errorRecords = append(errorRecords, batchErrors...)
-			currentBatch = nil
+			submit(i)
+		}
+
+		// Every record before this one has been submitted or rejected, so it is a clean
+		// place to stop
+		if len(currentBatch) == 0 && !deadline.IsZero() && time.Now().After(deadline) {
+			resumeFrom := i
+			status.ResumeFromRecord = &resumeFrom
+			return errorRecords, errProcessingTimeLimit
 		}
 
 		currentPortfolio = record.PortfolioID
@@ -332,29 +802,104 @@ func (s *fileProcessorService) processRecordsByPortfolio(ctx context.Context, re
 		}
 
 		currentBatch = append(currentBatch, *transactionDTO)
+		currentPositions = append(currentPositions, i)
 
 		// Process batch if it reaches max size
 		if len(currentBatch) >= s.config.MaxRecordsPerBatch {
-			batchErrors := s.processBatch(ctx, currentBatch, status)
-			errorRecords = append(errorRecords, batchErrors...)
-			currentBatch = nil
+			submit(i + 1)
 		}
 	}
 
 	// Process final batch
 	if len(currentBatch) > 0 {
-		batchErrors := s.processBatch(ctx, currentBatch, status)
-		errorRecords = append(errorRecords, batchErrors...)
+		submit(len(records))
 	}
 
 	return errorRecords, nil
 }
 
+// skipStoredRecords drops from a batch the records before recheckBefore whose source ID is
+// already stored: an interrupted run committed them without recording it. Those already past
+// NEW are counted as processed; those still NEW were stored but never applied, so they are
+// processed by ID instead of being submitted again, and any that fail are returned as error
+// records. If the lookup fails the whole batch is returned, and records already stored are
+// rejected by the source ID uniqueness check instead of being applied twice.
+func (s *fileProcessorService) skipStoredRecords(
+	ctx context.Context,
+	batch []dto.TransactionPostDTO,
+	positions []int,
+	recheckBefore int,
+	status *dto.FileProcessingStatus,
+) ([]dto.TransactionPostDTO, []CSVRecord) {
+	var sourceIDs []string
+	for i, transaction := range batch {
+		if positions[i] < recheckBefore {
+			sourceIDs = append(sourceIDs, transaction.SourceID)
+		}
+	}
+
+	stored, err := s.transactionService.FindStoredTransactions(ctx, sourceIDs)
+	if err != nil {
+		s.logger.Warn("Failed to check interrupted batch for stored records",
+			logger.Int("records", len(sourceIDs)),
+			logger.Err(err))
+		return batch, nil
+	}
+
+	var errorRecords []CSVRecord
+	remaining := make([]dto.TransactionPostDTO, 0, len(batch))
+	recovered := 0
+	for i, transaction := range batch {
+		storedTransaction, ok := stored[transaction.SourceID]
+		if positions[i] >= recheckBefore || !ok {
+			remaining = append(remaining, transaction)
+			continue
+		}
+
+		if storedTransaction.Status == models.TransactionStatusNew.String() {
+			if errorMessage := s.processStoredTransaction(ctx, storedTransaction.ID); errorMessage != "" {
+				errorRecord := s.convertDTOToRecord(transaction)
+				errorRecord.ErrorMessage = errorMessage
+				errorRecords = append(errorRecords, errorRecord)
+				status.FailedRecords++
+				continue
+			}
+		}
+		status.ProcessedRecords++
+		recovered++
+	}
+
+	if recovered > 0 {
+		status.RecoveredRecords += recovered
+		s.logger.Info("Skipped records of an interrupted batch that were already stored",
+			logger.Int("recoveredRecords", recovered))
+	}
+	return remaining, errorRecords
+}
+
+// processStoredTransaction processes a transaction an interrupted run stored but never
+// processed, returning why it failed or "" once it is processed
+func (s *fileProcessorService) processStoredTransaction(ctx context.Context, id int64) string {
+	result, err := s.transactionService.ProcessTransaction(ctx, id)
+	if err != nil {
+		return err.Error()
+	}
+	if result.Status != models.TransactionStatusProc.String() {
+		if result.ErrorMessage != nil {
+			return *result.ErrorMessage
+		}
+		return fmt.Sprintf("transaction left in status %s", result.Status)
+	}
+	return ""
+}
+
 // processBatch processes a batch of transactions
 func (s *fileProcessorService) processBatch(ctx context.Context, batch []dto.TransactionPostDTO, status *dto.FileProcessingStatus) []CSVRecord {
 	var errorRecords []CSVRecord
 
-	batchResponse, err := s.transactionService.CreateTransactions(ctx, batch)
+	start := time.Now()
+	batchResponse, err := s.transactionService.CreateTransactions(ctx, batch, BatchOptions{})
+	s.observeBatchDuration(ctx, batch, time.Since(start))
 	if err != nil {
 		s.logger.Error("Failed to process batch",
 			logger.Int("batchSize", len(batch)),
@@ -367,12 +912,16 @@ func (s *fileProcessorService) processBatch(ctx context.Context, batch []dto.Tra
 			errorRecords = append(errorRecords, errorRecord)
 		}
 		status.FailedRecords += len(batch)
+		status.CompletedBatches++
+		s.jobs.publish(status)
 		return errorRecords
 	}
 
 	// Update status counters
 	status.ProcessedRecords += len(batchResponse.Successful)
 	status.FailedRecords += len(batchResponse.Failed)
+	status.CompletedBatches++
+	s.jobs.publish(status)
 
 	// Convert failed transactions to error records
 	for _, failedTransaction := range batchResponse.Failed {
@@ -386,18 +935,39 @@ func (s *fileProcessorService) processBatch(ctx context.Context, batch []dto.Tra
 	return errorRecords
 }
 
+// observeBatchDuration records how long a batch took and warns when it exceeded the slow batch
+// threshold. Records are batched by portfolio, so the batch's first record names its portfolio.
+func (s *fileProcessorService) observeBatchDuration(ctx context.Context, batch []dto.TransactionPostDTO, elapsed time.Duration) {
+	if s.batchDuration != nil {
+		s.batchDuration.Record(ctx, elapsed.Seconds())
+	}
+
+	if s.config.SlowBatchThreshold <= 0 || elapsed <= s.config.SlowBatchThreshold {
+		return
+	}
+	s.logger.Warn("Slow file processing batch",
+		logger.String("portfolioId", batch[0].PortfolioID),
+		logger.Int("batchSize", len(batch)),
+		logger.Duration("elapsed", elapsed),
+		logger.Duration("threshold", s.config.SlowBatchThreshold))
+}
+
 // convertRecordToDTO converts a CSV record to TransactionPostDTO
 func (s *fileProcessorService) convertRecordToDTO(record CSVRecord) (*dto.TransactionPostDTO, error) {
+	if record.fieldCountErr != nil {
+		return nil, record.fieldCountErr
+	}
+
 	// Parse quantity
-	quantity, err := decimal.NewFromString(record.Quantity)
+	quantity, err := s.config.DecimalFormat.Parse(record.Quantity)
 	if err != nil {
-		return nil, fmt.Errorf("invalid quantity: %s", record.Quantity)
+		return nil, fmt.Errorf("invalid quantity: %w", err)
 	}
 
 	// Parse price
-	price, err := decimal.NewFromString(record.Price)
+	price, err := s.config.DecimalFormat.Parse(record.Price)
 	if err != nil {
-		return nil, fmt.Errorf("invalid price: %s", record.Price)
+		return nil, fmt.Errorf("invalid price: %w", err)
 	}
 
 	return &dto.TransactionPostDTO{
@@ -406,8 +976,9 @@ func (s *fileProcessorService) convertRecordToDTO(record CSVRecord) (*dto.Transa
 		SourceID:        record.SourceID,
 		TransactionType: record.TransactionType,
 		Quantity:        quantity,
-		Price:           price,
+		Price:           &price,
 		TransactionDate: record.TransactionDate,
+		SettlementDate:  record.SettlementDate,
 	}, nil
 }
 
@@ -419,41 +990,100 @@ func (s *fileProcessorService) convertDTOToRecord(transaction dto.TransactionPos
 		SourceID:        transaction.SourceID,
 		TransactionType: transaction.TransactionType,
 		Quantity:        transaction.Quantity.String(),
-		Price:           transaction.Price.String(),
+		Price:           transaction.PriceString(),
 		TransactionDate: transaction.TransactionDate,
+		SettlementDate:  transaction.SettlementDate,
 	}
 }
 
-// createErrorFile creates an error file for failed transactions
-func (s *fileProcessorService) createErrorFile(originalFilename string, errorRecords []CSVRecord) (string, error) {
+// errorFilename names the error file of a run of originalFilename that started at startedAt,
+// following the configured naming scheme
+func (s *fileProcessorService) errorFilename(originalFilename string, startedAt time.Time) string {
 	baseName := strings.TrimSuffix(originalFilename, filepath.Ext(originalFilename))
-	errorFilename := fmt.Sprintf("%s-errors.csv", baseName)
+	switch s.config.ErrorFileNaming {
+	case ErrorFileNamingTimestamp:
+		return fmt.Sprintf("%s-errors-%s.csv", baseName, startedAt.UTC().Format("20060102T150405.000Z"))
+	case ErrorFileNamingRunID:
+		return fmt.Sprintf("%s-errors-%s.csv", baseName, uuid.NewString())
+	}
+	return fmt.Sprintf("%s-errors.csv", baseName)
+}
+
+// writeErrorFile writes failed transactions to the named error file of a processed file. With
+// appendExisting the records are appended to the file left by earlier batches or runs, so a
+// checkpoint only writes its new rows; otherwise the file is recreated and replaced atomically.
+// An append that fails is cut back to the previous end of the file, so retrying it never
+// duplicates or splits rows.
+func (s *fileProcessorService) writeErrorFile(errorFilename string, errorRecords []CSVRecord, appendExisting bool) (string, error) {
 	errorPath := filepath.Join(s.config.ErrorFileDirectory, errorFilename)
 
-	file, err := os.Create(errorPath)
+	var err error
+	if appendExisting {
+		err = appendErrorRecords(errorPath, errorRecords)
+	} else {
+		err = WriteFileAtomic(errorPath, func(w io.Writer) error {
+			return writeErrorRecords(w, errorRecords, true)
+		})
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to create error file: %w", err)
+		return "", fmt.Errorf("failed to write error file: %w", err)
+	}
+
+	s.logger.Info("Error file written",
+		logger.String("errorFilename", errorFilename),
+		logger.Int("errorCount", len(errorRecords)))
+
+	return errorFilename, nil
+}
+
+// appendErrorRecords appends error records to an error file, starting it with the header when it
+// is missing or empty
+func appendErrorRecords(errorPath string, errorRecords []CSVRecord) error {
+	file, err := os.OpenFile(errorPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
 
-	// Write header
-	header := []string{
-		"portfolio_id", "security_id", "source_id", "transaction_type",
-		"quantity", "price", "transaction_date", "error_message",
+	// The rows are written with a single call so a failure leaves at most one partial append
+	var buffer bytes.Buffer
+	if err := writeErrorRecords(&buffer, errorRecords, info.Size() == 0); err != nil {
+		return err
 	}
-	if err := writer.Write(header); err != nil {
-		return "", fmt.Errorf("failed to write error file header: %w", err)
+	if _, err := file.Write(buffer.Bytes()); err != nil {
+		_ = file.Truncate(info.Size())
+		return fmt.Errorf("failed to append error records: %w", err)
+	}
+	return file.Sync()
+}
+
+// writeErrorRecords writes error records as CSV, preceded by the header when header is set
+func writeErrorRecords(w io.Writer, errorRecords []CSVRecord, header bool) error {
+	writer := csv.NewWriter(w)
+
+	if header {
+		if err := writer.Write([]string{
+			"portfolio_id", "security_id", "source_id", "transaction_type",
+			"quantity", "price", "transaction_date", "settlement_date", "error_message",
+		}); err != nil {
+			return fmt.Errorf("failed to write error file header: %w", err)
+		}
 	}
 
-	// Write error records
 	for _, record := range errorRecords {
 		securityID := ""
 		if record.SecurityID != nil {
 			securityID = *record.SecurityID
 		}
+		settlementDate := ""
+		if record.SettlementDate != nil {
+			settlementDate = *record.SettlementDate
+		}
 
 		row := []string{
 			record.PortfolioID,
@@ -463,34 +1093,39 @@ func (s *fileProcessorService) createErrorFile(originalFilename string, errorRec
 			record.Quantity,
 			record.Price,
 			record.TransactionDate,
+			settlementDate,
 			record.ErrorMessage,
 		}
 
 		if err := writer.Write(row); err != nil {
-			return "", fmt.Errorf("failed to write error record: %w", err)
+			return fmt.Errorf("failed to write error record: %w", err)
 		}
 	}
 
-	s.logger.Info("Error file created",
-		logger.String("errorFilename", errorFilename),
-		logger.Int("errorCount", len(errorRecords)))
-
-	return errorFilename, nil
+	writer.Flush()
+	return writer.Error()
 }
 
 // GetFileProcessingStatus retrieves the status of file processing
 func (s *fileProcessorService) GetFileProcessingStatus(ctx context.Context, filename string) (*dto.FileProcessingStatus, error) {
-	if status, exists := s.processingStatus[filename]; exists {
-		return status, nil
+	if status, exists := s.jobs.get(filename); exists {
+		return &status, nil
 	}
 	return nil, fmt.Errorf("no processing status found for file: %s", filename)
 }
 
+// WatchFileProcessing streams the status of a processing job: its current state first, then
+// each update until the job completes or fails, when the channel is closed. The channel is also
+// closed once ctx is done, so callers should cancel it when they stop reading.
+func (s *fileProcessorService) WatchFileProcessing(ctx context.Context, filename string) (<-chan dto.FileProcessingStatus, error) {
+	return s.jobs.watch(ctx, filename)
+}
+
 // ListFileProcessingStatus lists file processing statuses
 func (s *fileProcessorService) ListFileProcessingStatus(ctx context.Context, filter dto.FileProcessingFilter) ([]dto.FileProcessingStatus, error) {
 	var statuses []dto.FileProcessingStatus
 
-	for _, status := range s.processingStatus {
+	for _, status := range s.jobs.list() {
 		// Apply filters
 		if filter.Filename != nil && *filter.Filename != status.Filename {
 			continue
@@ -511,7 +1146,7 @@ func (s *fileProcessorService) ListFileProcessingStatus(ctx context.Context, fil
 			}
 		}
 
-		statuses = append(statuses, *status)
+		statuses = append(statuses, status)
 	}
 
 	return statuses, nil
@@ -523,7 +1158,7 @@ func (s *fileProcessorService) ValidateTransactionFile(ctx context.Context, file
 		logger.String("filename", filename))
 
 	fullPath := filepath.Join(s.config.WorkingDirectory, filename)
-	records, err := s.readAndSortCSVFile(fullPath)
+	records, err := s.readFileWithinLimit(fullPath)
 	if err != nil {
 		return &FileValidationResult{
 			IsValid:  false,
@@ -596,7 +1231,7 @@ func (s *fileProcessorService) ValidateTransactionFile(ctx context.Context, file
 
 // GetErrorFile retrieves the path to the error file for a given original file
 func (s *fileProcessorService) GetErrorFile(ctx context.Context, originalFilename string) (string, error) {
-	if status, exists := s.processingStatus[originalFilename]; exists {
+	if status, exists := s.jobs.get(originalFilename); exists {
 		if status.ErrorFilename != nil {
 			return filepath.Join(s.config.ErrorFileDirectory, *status.ErrorFilename), nil
 		}
@@ -605,6 +1240,17 @@ func (s *fileProcessorService) GetErrorFile(ctx context.Context, originalFilenam
 	return "", fmt.Errorf("no processing status found for file: %s", originalFilename)
 }
 
+// GetLastSuccessfulProcessing returns the most recent file processing run that completed,
+// including runs from before a restart, or nil if no run has completed yet
+func (s *fileProcessorService) GetLastSuccessfulProcessing(ctx context.Context) (*dto.FileProcessingSuccessDTO, error) {
+	success := s.getLastSuccess()
+	if success == nil {
+		return nil, nil
+	}
+	clone := *success
+	return &clone, nil
+}
+
 // GetServiceHealth checks the health of the file processor service
 func (s *fileProcessorService) GetServiceHealth(ctx context.Context) error {
 	s.logger.Debug("Checking file processor service health")
@@ -626,8 +1272,26 @@ func (s *fileProcessorService) GetServiceHealth(ctx context.Context) error {
 	return nil
 }
 
+// CheckDirectories reports whether the working and error file directories exist and are writable
+func (s *fileProcessorService) CheckDirectories(ctx context.Context) dto.FileDirectoriesHealthDTO {
+	return dto.FileDirectoriesHealthDTO{
+		WorkingDirectory: s.directoryHealth(s.config.WorkingDirectory),
+		ErrorDirectory:   s.directoryHealth(s.config.ErrorFileDirectory),
+	}
+}
+
 // Helper functions
 
+// directoryHealth checks a single directory for CheckDirectories
+func (s *fileProcessorService) directoryHealth(dirPath string) dto.FileDirectoryHealthDTO {
+	health := dto.FileDirectoryHealthDTO{Path: dirPath, Writable: true}
+	if err := s.checkDirectoryAccess(dirPath); err != nil {
+		health.Writable = false
+		health.Error = err.Error()
+	}
+	return health
+}
+
 // checkDirectoryAccess checks if a directory exists and is writable
 func (s *fileProcessorService) checkDirectoryAccess(dirPath string) error {
 	info, err := os.Stat(dirPath)
@@ -0,0 +1,855 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// slowBatchTransactionService answers each batch after a fixed delay, failing transactions whose
+// source ID starts with FAIL and accepting the rest
+type slowBatchTransactionService struct {
+	TransactionService
+
+	delay      time.Duration
+	batches    int
+	submitted  []string
+	quantities []decimal.Decimal
+}
+
+func (s *slowBatchTransactionService) CreateTransactions(ctx context.Context, transactionDTOs []dto.TransactionPostDTO, options BatchOptions) (*dto.TransactionBatchResponse, error) {
+	time.Sleep(s.delay)
+	s.batches++
+
+	result := &dto.TransactionBatchResponse{}
+	for _, txn := range transactionDTOs {
+		s.submitted = append(s.submitted, txn.SourceID)
+		s.quantities = append(s.quantities, txn.Quantity)
+		if strings.HasPrefix(txn.SourceID, "FAIL") {
+			result.Failed = append(result.Failed, dto.TransactionErrorDTO{
+				Transaction: txn,
+				Errors:      []dto.ValidationError{{Message: "rejected"}},
+			})
+			continue
+		}
+		result.Successful = append(result.Successful, dto.TransactionResponseDTO{SourceID: txn.SourceID})
+	}
+	return result, nil
+}
+
+func TestFileProcessor_StopsAtMaxProcessingDuration(t *testing.T) {
+	dir := t.TempDir()
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,DEP-1,DEP,1000,1,20240102\n" +
+		"PORTFOLIO000000000000001,,DEP-2,DEP,1000,1,20240103\n" +
+		"PORTFOLIO000000000000001,,DEP-3,DEP,1000,1,20240104\n" +
+		"PORTFOLIO000000000000001,,DEP-4,DEP,1000,1,20240105\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(csv), 0644))
+
+	transactionService := &slowBatchTransactionService{delay: 100 * time.Millisecond}
+	service := NewFileProcessorService(transactionService, FileProcessorConfig{
+		WorkingDirectory:      dir,
+		ErrorFileDirectory:    filepath.Join(dir, "errors"),
+		MaxRecordsPerBatch:    1,
+		MaxProcessingDuration: 50 * time.Millisecond,
+	}, logger.NewNoop())
+
+	status, err := service.ProcessTransactionFile(context.Background(), "transactions.csv", false)
+	require.NoError(t, err, "reaching the time limit is a clean stop")
+
+	// The first batch outlasts the limit, so the run stops before the second
+	assert.Equal(t, FileStatusStopped, status.Status)
+	assert.NotNil(t, status.CompletedAt)
+	assert.Equal(t, 1, transactionService.batches)
+	assert.Equal(t, 4, status.TotalRecords)
+	assert.Equal(t, 1, status.CompletedBatches)
+	assert.Equal(t, 1, status.ProcessedRecords)
+	assert.Equal(t, 0, status.FailedRecords)
+	require.NotNil(t, status.ResumeFromRecord)
+	assert.Equal(t, 1, *status.ResumeFromRecord)
+
+	recorded, err := service.GetFileProcessingStatus(context.Background(), "transactions.csv")
+	require.NoError(t, err)
+	assert.Equal(t, *status, *recorded)
+
+	// A stopped job no longer blocks a new run of the same file
+	_, err = service.StartTransactionFile(context.Background(), "transactions.csv", false)
+	require.NoError(t, err)
+	updates, err := service.WatchFileProcessing(context.Background(), "transactions.csv")
+	require.NoError(t, err)
+	var last dto.FileProcessingStatus
+	for last = range updates {
+	}
+	assert.Equal(t, FileStatusStopped, last.Status)
+}
+
+func TestFileProcessor_ResumesInterruptedFile(t *testing.T) {
+	dir := t.TempDir()
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,FAIL-1,DEP,1000,1,20240102\n" +
+		"PORTFOLIO000000000000001,,DEP-2,DEP,1000,1,20240103\n" +
+		"PORTFOLIO000000000000001,,FAIL-3,DEP,1000,1,20240104\n" +
+		"PORTFOLIO000000000000001,,DEP-4,DEP,1000,1,20240105\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(csv), 0644))
+
+	config := FileProcessorConfig{
+		WorkingDirectory:   dir,
+		ErrorFileDirectory: filepath.Join(dir, "errors"),
+		MaxRecordsPerBatch: 1,
+	}
+
+	// The first run is interrupted after one batch
+	interrupted := &slowBatchTransactionService{delay: 100 * time.Millisecond}
+	limited := config
+	limited.MaxProcessingDuration = 50 * time.Millisecond
+	status, err := NewFileProcessorService(interrupted, limited, logger.NewNoop()).
+		ProcessTransactionFile(context.Background(), "transactions.csv", false)
+	require.NoError(t, err)
+	require.Equal(t, FileStatusStopped, status.Status)
+	assert.Equal(t, []string{"FAIL-1"}, interrupted.submitted)
+
+	// A new service, as after a restart, picks up from the persisted marker
+	resumed := &slowBatchTransactionService{}
+	status, err = NewFileProcessorService(resumed, config, logger.NewNoop()).
+		ProcessTransactionFile(context.Background(), "transactions.csv", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, FileStatusCompleted, status.Status)
+	assert.Equal(t, []string{"DEP-2", "FAIL-3", "DEP-4"}, resumed.submitted, "handled records are not resubmitted")
+	require.NotNil(t, status.ResumedFromRecord)
+	assert.Equal(t, 1, *status.ResumedFromRecord)
+	assert.Equal(t, 4, status.TotalRecords)
+	assert.Equal(t, 2, status.ProcessedRecords)
+	assert.Equal(t, 2, status.FailedRecords)
+	assert.Equal(t, 4, status.CompletedBatches)
+
+	// Failures of both runs end up in one error file
+	require.NotNil(t, status.ErrorFilename)
+	errorFile, err := os.ReadFile(filepath.Join(dir, "errors", *status.ErrorFilename))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(errorFile)), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "portfolio_id,"))
+	assert.Contains(t, lines[1], "FAIL-1")
+	assert.Contains(t, lines[2], "FAIL-3")
+
+	// A completed file leaves no marker, so processing it again starts over
+	_, err = os.Stat(filepath.Join(dir, ".progress", "transactions.csv.progress.json"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// storingTransactionService keeps the source IDs it created. With crashOnBatch set it stores
+// the first transaction of that batch and panics, as if the process died mid-batch; with
+// crashBeforeProcessing also set it stores the second transaction as NEW before panicking, as
+// if the process died between storing and processing it. Transaction IDs are the numeric suffix
+// of the source ID.
+type storingTransactionService struct {
+	TransactionService
+
+	crashOnBatch          int
+	crashBeforeProcessing bool
+	batches               int
+	created               map[string]int
+	// unprocessed holds the created source IDs still in status NEW
+	unprocessed map[string]bool
+	// processedByID lists the source IDs processed by ID
+	processedByID []string
+}
+
+func (s *storingTransactionService) CreateTransactions(ctx context.Context, transactionDTOs []dto.TransactionPostDTO, options BatchOptions) (*dto.TransactionBatchResponse, error) {
+	s.batches++
+	result := &dto.TransactionBatchResponse{}
+	for i, txn := range transactionDTOs {
+		if s.created[txn.SourceID] > 0 {
+			result.Failed = append(result.Failed, dto.TransactionErrorDTO{
+				Transaction: txn,
+				Errors:      []dto.ValidationError{{Field: "sourceId", Message: "source ID must be unique"}},
+			})
+			continue
+		}
+		if s.batches == s.crashOnBatch && i == 1 {
+			if s.crashBeforeProcessing {
+				s.created[txn.SourceID]++
+				s.unprocessed[txn.SourceID] = true
+			}
+			panic("process killed")
+		}
+		s.created[txn.SourceID]++
+		result.Successful = append(result.Successful, dto.TransactionResponseDTO{SourceID: txn.SourceID})
+	}
+	return result, nil
+}
+
+func (s *storingTransactionService) FindStoredTransactions(ctx context.Context, sourceIDs []string) (map[string]StoredTransaction, error) {
+	stored := make(map[string]StoredTransaction)
+	for _, sourceID := range sourceIDs {
+		if s.created[sourceID] == 0 {
+			continue
+		}
+		id, err := strconv.ParseInt(sourceID[strings.LastIndex(sourceID, "-")+1:], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		status := "PROC"
+		if s.unprocessed[sourceID] {
+			status = "NEW"
+		}
+		stored[sourceID] = StoredTransaction{ID: id, Status: status}
+	}
+	return stored, nil
+}
+
+func (s *storingTransactionService) ProcessTransaction(ctx context.Context, id int64) (*dto.TransactionProcessingResult, error) {
+	for sourceID := range s.unprocessed {
+		if strings.HasSuffix(sourceID, fmt.Sprintf("-%d", id)) {
+			delete(s.unprocessed, sourceID)
+			s.processedByID = append(s.processedByID, sourceID)
+			return &dto.TransactionProcessingResult{TransactionID: id, Status: "PROC", BalanceUpdated: true}, nil
+		}
+	}
+	message := "transaction cannot be processed in current status"
+	return &dto.TransactionProcessingResult{TransactionID: id, Status: "NEW", ErrorMessage: &message}, nil
+}
+
+func TestFileProcessor_ResumesFromCheckpointWithoutDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,DEP-1,DEP,1000,1,20240102\n" +
+		"PORTFOLIO000000000000001,,DEP-2,DEP,1000,1,20240103\n" +
+		"PORTFOLIO000000000000001,,DEP-3,DEP,1000,1,20240104\n" +
+		"PORTFOLIO000000000000002,,DEP-4,DEP,1000,1,20240102\n" +
+		"PORTFOLIO000000000000002,,DEP-5,DEP,1000,1,20240103\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(csv), 0644))
+
+	config := FileProcessorConfig{
+		WorkingDirectory:   dir,
+		ErrorFileDirectory: filepath.Join(dir, "errors"),
+		ProgressDirectory:  filepath.Join(dir, ".progress"),
+		MaxRecordsPerBatch: 2,
+	}
+	created := make(map[string]int)
+
+	// The process dies in the third batch, after storing DEP-4 but before its answer
+	crashing := &storingTransactionService{crashOnBatch: 3, created: created, unprocessed: map[string]bool{}}
+	assert.PanicsWithValue(t, "process killed", func() {
+		_, _ = NewFileProcessorService(crashing, config, logger.NewNoop()).
+			ProcessTransactionFile(context.Background(), "transactions.csv", false)
+	})
+	assert.Equal(t, map[string]int{"DEP-1": 1, "DEP-2": 1, "DEP-3": 1, "DEP-4": 1}, created)
+
+	marker, err := newFileProgressStore(config.ProgressDirectory).load("transactions.csv")
+	require.NoError(t, err)
+	require.NotNil(t, marker)
+	assert.Equal(t, 3, marker.NextRecord)
+	assert.Equal(t, "PORTFOLIO000000000000001", marker.CheckpointPortfolio)
+	assert.Equal(t, 5, marker.InFlightEnd)
+
+	// After a restart the run continues at the checkpoint and does not resubmit DEP-4
+	resumed := &storingTransactionService{created: created, unprocessed: map[string]bool{}}
+	status, err := NewFileProcessorService(resumed, config, logger.NewNoop()).
+		ProcessTransactionFile(context.Background(), "transactions.csv", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, FileStatusCompleted, status.Status)
+	assert.Equal(t, map[string]int{"DEP-1": 1, "DEP-2": 1, "DEP-3": 1, "DEP-4": 1, "DEP-5": 1}, created)
+	assert.Empty(t, resumed.processedByID)
+	require.NotNil(t, status.ResumedFromRecord)
+	assert.Equal(t, 3, *status.ResumedFromRecord)
+	require.NotNil(t, status.CheckpointPortfolio)
+	assert.Equal(t, "PORTFOLIO000000000000002", *status.CheckpointPortfolio)
+	assert.Equal(t, 5, status.ProcessedRecords)
+	assert.Equal(t, 1, status.RecoveredRecords)
+	assert.Equal(t, 0, status.FailedRecords)
+	assert.Nil(t, status.ErrorFilename, "stored records are not reported as duplicates")
+}
+
+func TestFileProcessor_ResumeProcessesStoredNewRecords(t *testing.T) {
+	dir := t.TempDir()
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,DEP-1,DEP,1000,1,20240102\n" +
+		"PORTFOLIO000000000000001,,DEP-2,DEP,1000,1,20240103\n" +
+		"PORTFOLIO000000000000001,,DEP-3,DEP,1000,1,20240104\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(csv), 0644))
+
+	config := FileProcessorConfig{
+		WorkingDirectory:   dir,
+		ErrorFileDirectory: filepath.Join(dir, "errors"),
+		ProgressDirectory:  filepath.Join(dir, ".progress"),
+		MaxRecordsPerBatch: 3,
+	}
+	created := make(map[string]int)
+	unprocessed := make(map[string]bool)
+
+	// The process dies after storing DEP-2 and before processing it
+	crashing := &storingTransactionService{crashOnBatch: 1, crashBeforeProcessing: true, created: created, unprocessed: unprocessed}
+	assert.PanicsWithValue(t, "process killed", func() {
+		_, _ = NewFileProcessorService(crashing, config, logger.NewNoop()).
+			ProcessTransactionFile(context.Background(), "transactions.csv", false)
+	})
+	assert.Equal(t, map[string]bool{"DEP-2": true}, unprocessed)
+
+	// After a restart DEP-1 is skipped, DEP-2 is processed by ID and DEP-3 is submitted
+	resumed := &storingTransactionService{created: created, unprocessed: unprocessed}
+	status, err := NewFileProcessorService(resumed, config, logger.NewNoop()).
+		ProcessTransactionFile(context.Background(), "transactions.csv", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, FileStatusCompleted, status.Status)
+	assert.Equal(t, []string{"DEP-2"}, resumed.processedByID)
+	assert.Empty(t, unprocessed)
+	assert.Equal(t, map[string]int{"DEP-1": 1, "DEP-2": 1, "DEP-3": 1}, created)
+	assert.Equal(t, 3, status.ProcessedRecords)
+	assert.Equal(t, 2, status.RecoveredRecords)
+	assert.Equal(t, 0, status.FailedRecords)
+	assert.Nil(t, status.ErrorFilename)
+}
+
+func TestFileProcessor_MaxFileSize(t *testing.T) {
+	dir := t.TempDir()
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,DEP-1,DEP,1000,1,20240102\n" +
+		"PORTFOLIO000000000000001,,DEP-2,DEP,1000,1,20240103\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(csv), 0644))
+
+	newService := func(maxFileSize int64) (*slowBatchTransactionService, FileProcessorService) {
+		transactionService := &slowBatchTransactionService{}
+		return transactionService, NewFileProcessorService(transactionService, FileProcessorConfig{
+			WorkingDirectory:   dir,
+			ErrorFileDirectory: filepath.Join(dir, "errors"),
+			MaxFileSize:        maxFileSize,
+		}, logger.NewNoop())
+	}
+
+	t.Run("Rejects a file over a custom limit", func(t *testing.T) {
+		transactionService, service := newService(64)
+
+		status, err := service.ProcessTransactionFile(context.Background(), "transactions.csv", false)
+		var tooLarge *FileTooLargeError
+		require.ErrorAs(t, err, &tooLarge)
+		assert.Equal(t, int64(len(csv)), tooLarge.Size)
+		assert.Equal(t, int64(64), tooLarge.Limit)
+		assert.Equal(t, FileStatusFailed, status.Status)
+		assert.Zero(t, transactionService.batches, "no record of an oversized file is submitted")
+
+		_, err = service.StartTransactionFile(context.Background(), "transactions.csv", false)
+		require.ErrorAs(t, err, &tooLarge, "the limit is enforced before a background job starts")
+
+		result, err := service.ValidateTransactionFile(context.Background(), "transactions.csv")
+		require.NoError(t, err)
+		assert.False(t, result.IsValid)
+		require.Len(t, result.Errors, 1)
+		assert.Contains(t, result.Errors[0].Message, "file size exceeds limit")
+	})
+
+	t.Run("Processes a file within the limit", func(t *testing.T) {
+		transactionService, service := newService(int64(len(csv)))
+
+		status, err := service.ProcessTransactionFile(context.Background(), "transactions.csv", false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, status.ProcessedRecords)
+		assert.Equal(t, []string{"DEP-1", "DEP-2"}, transactionService.submitted)
+	})
+
+	t.Run("Rejects a file that grows past the limit while it is read", func(t *testing.T) {
+		_, service := newService(64)
+
+		_, err := service.(*fileProcessorService).readAndSortCSVFile(filepath.Join(dir, "transactions.csv"))
+		var tooLarge *FileTooLargeError
+		require.ErrorAs(t, err, &tooLarge)
+	})
+}
+
+func TestFileProcessor_SortKeepsFileOrderOfSameKeyRecords(t *testing.T) {
+	dir := t.TempDir()
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000002,,DEP-P2,DEP,100,1,20240102\n"
+	var expected []string
+	for i := 1; i <= 40; i++ {
+		sourceID := "BUY-" + strconv.Itoa(i)
+		csv += "PORTFOLIO000000000000001,SECURITY0000000000000001," + sourceID + ",BUY,1,10,20240103\n"
+		expected = append(expected, sourceID)
+	}
+	csv += "PORTFOLIO000000000000001,,DEP-P1,DEP,1000,1,20240102\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(csv), 0644))
+
+	service := NewFileProcessorService(&slowBatchTransactionService{}, FileProcessorConfig{
+		WorkingDirectory:   dir,
+		ErrorFileDirectory: filepath.Join(dir, "errors"),
+	}, logger.NewNoop())
+
+	records, err := service.(*fileProcessorService).readAndSortCSVFile(filepath.Join(dir, "transactions.csv"))
+	require.NoError(t, err)
+	require.Len(t, records, 42)
+
+	sourceIDs := make([]string, 0, len(records))
+	for _, record := range records {
+		sourceIDs = append(sourceIDs, record.SourceID)
+	}
+	assert.Equal(t, "DEP-P1", sourceIDs[0])
+	assert.Equal(t, expected, sourceIDs[1:41], "same-key records keep their order in the file")
+	assert.Equal(t, "DEP-P2", sourceIDs[41])
+}
+
+func TestFileProcessor_DecimalFormat(t *testing.T) {
+	dir := t.TempDir()
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,DEP-1,DEP,\"1.250,5\",1,20240102\n" +
+		"PORTFOLIO000000000000001,SECURITY0000000000000001,BUY-1,BUY,10,\"99,75\",20240103\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "european.csv"), []byte(csv), 0644))
+
+	newService := func(format DecimalFormat) (*slowBatchTransactionService, FileProcessorService) {
+		transactionService := &slowBatchTransactionService{}
+		return transactionService, NewFileProcessorService(transactionService, FileProcessorConfig{
+			WorkingDirectory:   dir,
+			ErrorFileDirectory: filepath.Join(dir, "errors"),
+			DecimalFormat:      format,
+		}, logger.NewNoop())
+	}
+
+	t.Run("Comma decimals are rejected in the default format", func(t *testing.T) {
+		_, service := newService(DecimalFormat{})
+
+		result, err := service.ValidateTransactionFile(context.Background(), "european.csv")
+		require.NoError(t, err)
+		assert.False(t, result.IsValid)
+		require.Len(t, result.Errors, 2)
+		assert.Contains(t, result.Errors[0].Message, "neither the decimal separator")
+	})
+
+	t.Run("Comma decimals are parsed in the European format", func(t *testing.T) {
+		transactionService, service := newService(DecimalFormat{DecimalSeparator: ",", ThousandsSeparator: "."})
+
+		result, err := service.ValidateTransactionFile(context.Background(), "european.csv")
+		require.NoError(t, err)
+		assert.True(t, result.IsValid)
+
+		status, err := service.ProcessTransactionFile(context.Background(), "european.csv", false)
+		require.NoError(t, err)
+		assert.Equal(t, 2, status.ProcessedRecords)
+		require.Len(t, transactionService.quantities, 2)
+		assert.True(t, decimal.RequireFromString("1250.5").Equal(transactionService.quantities[0]))
+	})
+}
+
+func TestFileProcessor_StrictFieldCount(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,DEP-1,DEP,1000,1,20240102\n" +
+		"PORTFOLIO000000000000001,,DEP-2,DEP,1000,1\n" +
+		"PORTFOLIO000000000000001,,DEP-3,DEP,1000,1,20240104,extra\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ragged.csv"), []byte(csv), 0644))
+
+	newService := func(strict bool) (*slowBatchTransactionService, FileProcessorService) {
+		transactionService := &slowBatchTransactionService{}
+		return transactionService, NewFileProcessorService(transactionService, FileProcessorConfig{
+			WorkingDirectory:   dir,
+			ErrorFileDirectory: filepath.Join(dir, "errors"),
+			StrictFieldCount:   strict,
+		}, logger.NewNoop())
+	}
+
+	t.Run("Ragged rows are tolerated by default", func(t *testing.T) {
+		transactionService, service := newService(false)
+
+		result, err := service.ValidateTransactionFile(ctx, "ragged.csv")
+		require.NoError(t, err)
+		assert.True(t, result.IsValid)
+
+		status, err := service.ProcessTransactionFile(ctx, "ragged.csv", false)
+		require.NoError(t, err)
+		// The row without a date still goes through, sorted ahead of the others
+		assert.Equal(t, []string{"DEP-2", "DEP-1", "DEP-3"}, transactionService.submitted)
+		assert.Equal(t, 3, status.ProcessedRecords)
+		assert.Zero(t, status.FailedRecords)
+	})
+
+	t.Run("Ragged rows fail with their line numbers when strict", func(t *testing.T) {
+		transactionService, service := newService(true)
+
+		result, err := service.ValidateTransactionFile(ctx, "ragged.csv")
+		require.NoError(t, err)
+		assert.False(t, result.IsValid)
+		require.Len(t, result.Errors, 2)
+		assert.Equal(t, 3, result.Errors[0].Line)
+		assert.Equal(t, "line 3 has 6 fields, the header has 7", result.Errors[0].Message)
+		assert.Equal(t, 4, result.Errors[1].Line)
+		assert.Equal(t, "line 4 has 8 fields, the header has 7", result.Errors[1].Message)
+
+		status, err := service.ProcessTransactionFile(ctx, "ragged.csv", true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"DEP-1"}, transactionService.submitted)
+		assert.Equal(t, 1, status.ProcessedRecords)
+		assert.Equal(t, 2, status.FailedRecords)
+
+		require.NotNil(t, status.ErrorFilename)
+		errorFile, err := os.ReadFile(filepath.Join(dir, "errors", *status.ErrorFilename))
+		require.NoError(t, err)
+		assert.Contains(t, string(errorFile), "line 3 has 6 fields, the header has 7")
+		assert.Contains(t, string(errorFile), "line 4 has 8 fields, the header has 7")
+	})
+}
+
+func TestFileProcessor_ReingestsErrorFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,FAIL-1,DEP,1000,1,20240102\n" +
+		"PORTFOLIO000000000000001,,DEP-2,DEP,1000,1,20240103\n" +
+		"PORTFOLIO000000000000001,,FAIL-3,DEP,1000,1,20240104\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(csv), 0644))
+
+	config := FileProcessorConfig{
+		WorkingDirectory:   dir,
+		ErrorFileDirectory: filepath.Join(dir, "errors"),
+	}
+	status, err := NewFileProcessorService(&slowBatchTransactionService{}, config, logger.NewNoop()).
+		ProcessTransactionFile(ctx, "transactions.csv", false)
+	require.NoError(t, err)
+	require.NotNil(t, status.ErrorFilename)
+
+	// Correct one row of the error file and mark the messages of the earlier run
+	errorFile, err := os.ReadFile(filepath.Join(dir, "errors", *status.ErrorFilename))
+	require.NoError(t, err)
+	corrected := strings.ReplaceAll(string(errorFile), "FAIL-1", "FIXED-1")
+	corrected = strings.ReplaceAll(corrected, "rejected", "stale message")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "corrected.csv"), []byte(corrected), 0644))
+
+	transactionService := &slowBatchTransactionService{}
+	service := NewFileProcessorService(transactionService, config, logger.NewNoop())
+
+	result, err := service.ValidateTransactionFile(ctx, "corrected.csv")
+	require.NoError(t, err)
+	assert.True(t, result.IsValid, "the error_message column is not a validation error")
+	assert.Equal(t, 2, result.TotalRecords)
+
+	status, err = service.ProcessTransactionFile(ctx, "corrected.csv", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FIXED-1", "FAIL-3"}, transactionService.submitted)
+	assert.Equal(t, 1, status.ProcessedRecords)
+	assert.Equal(t, 1, status.FailedRecords)
+
+	// The new error file reports only the message of this run
+	require.NotNil(t, status.ErrorFilename)
+	errorFile, err = os.ReadFile(filepath.Join(dir, "errors", *status.ErrorFilename))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(errorFile)), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, 1, strings.Count(lines[0], "error_message"))
+	assert.Contains(t, lines[1], "FAIL-3")
+	assert.True(t, strings.HasSuffix(lines[1], ",rejected"))
+	assert.NotContains(t, string(errorFile), "stale message")
+}
+
+func TestFileProcessor_ErrorFileNaming(t *testing.T) {
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,FAIL-1,DEP,1000,1,20240102\n" +
+		"PORTFOLIO000000000000001,,DEP-2,DEP,1000,1,20240103\n"
+
+	runTwice := func(t *testing.T, naming ErrorFileNaming) (string, string, string) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(csv), 0644))
+		service := NewFileProcessorService(&slowBatchTransactionService{}, FileProcessorConfig{
+			WorkingDirectory:   dir,
+			ErrorFileDirectory: filepath.Join(dir, "errors"),
+			ErrorFileNaming:    naming,
+		}, logger.NewNoop())
+
+		var names []string
+		for run := 0; run < 2; run++ {
+			// The second run is a forced retry of the same file
+			status, err := service.ProcessTransactionFile(context.Background(), "transactions.csv", run > 0)
+			require.NoError(t, err)
+			require.NotNil(t, status.ErrorFilename)
+			names = append(names, *status.ErrorFilename)
+
+			errorFile, err := service.GetErrorFile(context.Background(), "transactions.csv")
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(dir, "errors", *status.ErrorFilename), errorFile, "the status names the file written")
+			content, err := os.ReadFile(errorFile)
+			require.NoError(t, err)
+			assert.Equal(t, 2, strings.Count(string(content), "\n"), "each run writes the header and its own failure")
+
+			time.Sleep(2 * time.Millisecond)
+		}
+		return dir, names[0], names[1]
+	}
+
+	t.Run("Fixed name is replaced by the next run", func(t *testing.T) {
+		_, first, second := runTwice(t, "")
+		assert.Equal(t, "transactions-errors.csv", first)
+		assert.Equal(t, first, second)
+	})
+
+	t.Run("Timestamp names keep every run", func(t *testing.T) {
+		dir, first, second := runTwice(t, ErrorFileNamingTimestamp)
+		assert.NotEqual(t, first, second)
+		assert.Regexp(t, `^transactions-errors-\d{8}T\d{6}\.\d{3}Z\.csv$`, first)
+		assert.FileExists(t, filepath.Join(dir, "errors", first))
+		assert.FileExists(t, filepath.Join(dir, "errors", second))
+	})
+
+	t.Run("Run ID names keep every run", func(t *testing.T) {
+		dir, first, second := runTwice(t, ErrorFileNamingRunID)
+		assert.NotEqual(t, first, second)
+		assert.Regexp(t, `^transactions-errors-[0-9a-f-]{36}\.csv$`, first)
+		assert.FileExists(t, filepath.Join(dir, "errors", first))
+		assert.FileExists(t, filepath.Join(dir, "errors", second))
+	})
+}
+
+func TestFileProcessor_WriteErrorFileAppendsNewRows(t *testing.T) {
+	dir := t.TempDir()
+	service := NewFileProcessorService(&slowBatchTransactionService{}, FileProcessorConfig{
+		WorkingDirectory:   dir,
+		ErrorFileDirectory: filepath.Join(dir, "errors"),
+	}, logger.NewNoop()).(*fileProcessorService)
+
+	record := func(sourceID string) CSVRecord {
+		return CSVRecord{PortfolioID: "PORTFOLIO000000000000001", SourceID: sourceID, TransactionType: "DEP", Quantity: "1", Price: "1", TransactionDate: "20240102", ErrorMessage: "rejected"}
+	}
+	path := filepath.Join(dir, "errors", "transactions-errors.csv")
+
+	_, err := service.writeErrorFile("transactions-errors.csv", []CSVRecord{record("DEP-1")}, false)
+	require.NoError(t, err)
+	before, err := os.Stat(path)
+	require.NoError(t, err)
+
+	// Checkpoints append their rows to the same file instead of rewriting it
+	for _, sourceID := range []string{"DEP-2", "DEP-3"} {
+		_, err = service.writeErrorFile("transactions-errors.csv", []CSVRecord{record(sourceID)}, true)
+		require.NoError(t, err)
+	}
+	after, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(before, after))
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "portfolio_id,"))
+	for i, sourceID := range []string{"DEP-1", "DEP-2", "DEP-3"} {
+		assert.Contains(t, lines[i+1], ","+sourceID+",")
+	}
+
+	// Appending to a file that does not exist yet starts it with the header
+	_, err = service.writeErrorFile("other-errors.csv", []CSVRecord{record("DEP-4")}, true)
+	require.NoError(t, err)
+	content, err = os.ReadFile(filepath.Join(dir, "errors", "other-errors.csv"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "portfolio_id,"))
+}
+
+func TestFileProcessor_DetectsAlreadyProcessedFile(t *testing.T) {
+	dir := t.TempDir()
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,DEP-1,DEP,1000,1,20240102\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(csv), 0644))
+
+	config := FileProcessorConfig{
+		WorkingDirectory:   dir,
+		ErrorFileDirectory: filepath.Join(dir, "errors"),
+	}
+	transactionService := &slowBatchTransactionService{}
+	service := NewFileProcessorService(transactionService, config, logger.NewNoop())
+	ctx := context.Background()
+
+	first, err := service.ProcessTransactionFile(ctx, "transactions.csv", false)
+	require.NoError(t, err)
+	require.Equal(t, FileStatusCompleted, first.Status)
+	assert.Len(t, first.ContentHash, 64)
+
+	// The same file again, also after a restart, is detected and not submitted
+	for _, svc := range []FileProcessorService{service, NewFileProcessorService(transactionService, config, logger.NewNoop())} {
+		_, err = svc.ProcessTransactionFile(ctx, "transactions.csv", false)
+		var processed *FileAlreadyProcessedError
+		require.ErrorAs(t, err, &processed)
+		assert.Equal(t, first.ContentHash, processed.Previous.ContentHash)
+		assert.Equal(t, FileStatusCompleted, processed.Previous.Status)
+		assert.Equal(t, 1, processed.Previous.ProcessedRecords)
+
+		_, err = svc.StartTransactionFile(ctx, "transactions.csv", false)
+		require.ErrorAs(t, err, &processed)
+	}
+	assert.Equal(t, []string{"DEP-1"}, transactionService.submitted)
+
+	// Force processes it again
+	forced, err := service.ProcessTransactionFile(ctx, "transactions.csv", true)
+	require.NoError(t, err)
+	assert.Equal(t, FileStatusCompleted, forced.Status)
+	assert.Equal(t, []string{"DEP-1", "DEP-1"}, transactionService.submitted)
+
+	// Changed content under the same name is a new file
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"),
+		[]byte(csv+"PORTFOLIO000000000000001,,DEP-2,DEP,1000,1,20240103\n"), 0644))
+	changed, err := service.ProcessTransactionFile(ctx, "transactions.csv", false)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ContentHash, changed.ContentHash)
+
+	// Reverting to the first content is detected, although another run completed since
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(csv), 0644))
+	_, err = service.ProcessTransactionFile(ctx, "transactions.csv", false)
+	var reverted *FileAlreadyProcessedError
+	require.ErrorAs(t, err, &reverted)
+	assert.Equal(t, first.ContentHash, reverted.Previous.ContentHash)
+}
+
+func TestFileProcessor_LastSuccessfulProcessing(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,DEP-1,DEP,1000,1,20240102\n" +
+		"PORTFOLIO000000000000001,,FAIL-1,DEP,1000,1,20240103\n" +
+		"PORTFOLIO000000000000001,,DEP-2,DEP,1000,1,20240104\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(csv), 0644))
+
+	newService := func() (*sdkmetric.ManualReader, FileProcessorService) {
+		reader := sdkmetric.NewManualReader()
+		return reader, NewFileProcessorService(&slowBatchTransactionService{}, FileProcessorConfig{
+			WorkingDirectory:   dir,
+			ErrorFileDirectory: filepath.Join(dir, "errors"),
+			MeterProvider:      sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		}, logger.NewNoop())
+	}
+	gauges := func(t *testing.T, reader *sdkmetric.ManualReader) map[string]float64 {
+		var collected metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(ctx, &collected))
+
+		values := make(map[string]float64)
+		for _, scope := range collected.ScopeMetrics {
+			for _, m := range scope.Metrics {
+				switch data := m.Data.(type) {
+				case metricdata.Gauge[float64]:
+					for _, point := range data.DataPoints {
+						values[m.Name] = point.Value
+					}
+				case metricdata.Gauge[int64]:
+					for _, point := range data.DataPoints {
+						values[m.Name] = float64(point.Value)
+					}
+				}
+			}
+		}
+		return values
+	}
+
+	reader, service := newService()
+	lastSuccess, err := service.GetLastSuccessfulProcessing(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, lastSuccess, "no run has completed yet")
+	assert.Empty(t, gauges(t, reader))
+
+	before := time.Now()
+	_, err = service.ProcessTransactionFile(ctx, "transactions.csv", false)
+	require.NoError(t, err)
+
+	lastSuccess, err = service.GetLastSuccessfulProcessing(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, lastSuccess)
+	assert.Equal(t, "transactions.csv", lastSuccess.Filename)
+	assert.False(t, lastSuccess.CompletedAt.Before(before), "timestamp updates after the run")
+	assert.Equal(t, 2, lastSuccess.ProcessedRecords)
+	assert.Equal(t, 1, lastSuccess.FailedRecords)
+
+	values := gauges(t, reader)
+	assert.InDelta(t, float64(lastSuccess.CompletedAt.UnixNano())/1e9, values["file_processing_last_success_timestamp_seconds"], 0.001)
+	assert.Equal(t, float64(2), values["file_processing_last_success_records"])
+
+	t.Run("Survives a restart", func(t *testing.T) {
+		reader, restarted := newService()
+
+		persisted, err := restarted.GetLastSuccessfulProcessing(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, persisted)
+		assert.True(t, lastSuccess.CompletedAt.Equal(persisted.CompletedAt))
+		assert.Equal(t, 2, persisted.ProcessedRecords)
+		assert.Equal(t, float64(2), gauges(t, reader)["file_processing_last_success_records"])
+	})
+
+	t.Run("A failed run keeps the last success", func(t *testing.T) {
+		_, err := service.ProcessTransactionFile(ctx, "missing.csv", false)
+		require.Error(t, err)
+
+		unchanged, err := service.GetLastSuccessfulProcessing(ctx)
+		require.NoError(t, err)
+		assert.True(t, lastSuccess.CompletedAt.Equal(unchanged.CompletedAt))
+	})
+}
+
+func TestFileProcessor_SlowBatches(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	csv := "portfolio_id,security_id,source_id,transaction_type,quantity,price,transaction_date\n" +
+		"PORTFOLIO000000000000001,,DEP-1,DEP,1000,1,20240102\n" +
+		"PORTFOLIO000000000000001,,DEP-2,DEP,1000,1,20240103\n" +
+		"PORTFOLIO000000000000002,,DEP-3,DEP,1000,1,20240102\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "transactions.csv"), []byte(csv), 0644))
+
+	process := func(t *testing.T, threshold time.Duration) (*observer.ObservedLogs, *sdkmetric.ManualReader) {
+		core, logs := observer.New(zapcore.WarnLevel)
+		reader := sdkmetric.NewManualReader()
+		service := NewFileProcessorService(&slowBatchTransactionService{delay: 20 * time.Millisecond}, FileProcessorConfig{
+			WorkingDirectory:   dir,
+			ErrorFileDirectory: filepath.Join(dir, "errors"),
+			SlowBatchThreshold: threshold,
+			MeterProvider:      sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		}, logger.NewFromZap(zap.New(core)))
+
+		_, err := service.ProcessTransactionFile(ctx, "transactions.csv", true)
+		require.NoError(t, err)
+		return logs.FilterMessage("Slow file processing batch"), reader
+	}
+
+	t.Run("Batches over the threshold are logged", func(t *testing.T) {
+		logs, reader := process(t, 5*time.Millisecond)
+
+		require.Equal(t, 2, logs.Len(), "one batch per portfolio")
+		first := logs.All()[0].ContextMap()
+		assert.Equal(t, "PORTFOLIO000000000000001", first["portfolioId"])
+		assert.Equal(t, int64(2), first["batchSize"])
+		assert.GreaterOrEqual(t, first["elapsed"], 20*time.Millisecond)
+		assert.Equal(t, "PORTFOLIO000000000000002", logs.All()[1].ContextMap()["portfolioId"])
+
+		var collected metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(ctx, &collected))
+		var count uint64
+		for _, scope := range collected.ScopeMetrics {
+			for _, m := range scope.Metrics {
+				if histogram, ok := m.Data.(metricdata.Histogram[float64]); ok && m.Name == "file_processing_batch_duration_seconds" {
+					for _, point := range histogram.DataPoints {
+						count += point.Count
+					}
+				}
+			}
+		}
+		assert.Equal(t, uint64(2), count)
+	})
+
+	t.Run("Batches within the threshold are not logged", func(t *testing.T) {
+		logs, _ := process(t, time.Minute)
+		assert.Zero(t, logs.Len())
+	})
+
+	t.Run("Zero threshold turns the warning off", func(t *testing.T) {
+		logs, _ := process(t, 0)
+		assert.Zero(t, logs.Len())
+	})
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+)
+
+// fileProgressMarker records how far a file processing job got, so that a later run of the
+// same file can skip the records it already handled
+type fileProgressMarker struct {
+	Filename    string    `json:"filename"`
+	FileSize    int64     `json:"fileSize"`
+	FileModTime time.Time `json:"fileModTime"`
+
+	// NextRecord is the position, in processing order, of the first record not yet handled
+	NextRecord int `json:"nextRecord"`
+	// CheckpointPortfolio is the portfolio of the last batch whose answer was recorded
+	CheckpointPortfolio string `json:"checkpointPortfolio,omitempty"`
+	// InFlightEnd is set while a batch is submitted: the position after its last record. A
+	// marker left with it set means the run stopped before the batch was answered, so records
+	// before it may already be stored.
+	InFlightEnd      int     `json:"inFlightEnd,omitempty"`
+	ProcessedRecords int     `json:"processedRecords"`
+	FailedRecords    int     `json:"failedRecords"`
+	CompletedBatches int     `json:"completedBatches"`
+	ErrorFilename    *string `json:"errorFilename,omitempty"`
+
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// matches reports whether the marker was written for the file as it is now
+func (m *fileProgressMarker) matches(info os.FileInfo) bool {
+	return m.FileSize == info.Size() && m.FileModTime.Equal(info.ModTime())
+}
+
+// fileProgressStore persists progress markers as JSON files in a directory
+type fileProgressStore struct {
+	directory string
+}
+
+// newFileProgressStore creates a progress store in directory
+func newFileProgressStore(directory string) *fileProgressStore {
+	return &fileProgressStore{directory: directory}
+}
+
+func (s *fileProgressStore) path(filename string) string {
+	return filepath.Join(s.directory, filename+".progress.json")
+}
+
+// load returns the marker for filename, or nil if there is none
+func (s *fileProgressStore) load(filename string) (*fileProgressMarker, error) {
+	data, err := os.ReadFile(s.path(filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read progress marker: %w", err)
+	}
+
+	var marker fileProgressMarker
+	if err := json.Unmarshal(data, &marker); err != nil {
+		return nil, fmt.Errorf("failed to decode progress marker: %w", err)
+	}
+	return &marker, nil
+}
+
+// save writes the marker, replacing the previous one atomically so a crash never leaves a
+// partially written marker behind
+func (s *fileProgressStore) save(marker *fileProgressMarker) error {
+	marker.UpdatedAt = time.Now()
+	data, err := json.Marshal(marker)
+	if err != nil {
+		return fmt.Errorf("failed to encode progress marker: %w", err)
+	}
+
+	if err := s.replace(s.path(marker.Filename), data); err != nil {
+		return fmt.Errorf("failed to save progress marker: %w", err)
+	}
+	return nil
+}
+
+// lastSuccessPath is where the most recent completed run is recorded; it never collides with a
+// marker, whose names end in .progress.json
+func (s *fileProgressStore) lastSuccessPath() string {
+	return filepath.Join(s.directory, "last-success.json")
+}
+
+// loadLastSuccess returns the most recent completed run, or nil if none was recorded
+func (s *fileProgressStore) loadLastSuccess() (*dto.FileProcessingSuccessDTO, error) {
+	data, err := os.ReadFile(s.lastSuccessPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read last successful run: %w", err)
+	}
+
+	var success dto.FileProcessingSuccessDTO
+	if err := json.Unmarshal(data, &success); err != nil {
+		return nil, fmt.Errorf("failed to decode last successful run: %w", err)
+	}
+	return &success, nil
+}
+
+// saveLastSuccess records the most recent completed run
+func (s *fileProgressStore) saveLastSuccess(success *dto.FileProcessingSuccessDTO) error {
+	data, err := json.Marshal(success)
+	if err != nil {
+		return fmt.Errorf("failed to encode last successful run: %w", err)
+	}
+
+	if err := s.replace(s.lastSuccessPath(), data); err != nil {
+		return fmt.Errorf("failed to save last successful run: %w", err)
+	}
+	return nil
+}
+
+// completedPath is where the completed run of filename with contentHash is recorded; like
+// lastSuccessPath it never collides with a marker
+func (s *fileProgressStore) completedPath(filename, contentHash string) string {
+	return filepath.Join(s.directory, filename+"."+contentHash+".completed.json")
+}
+
+// loadCompleted returns the status of the completed run of filename with contentHash, or nil if
+// none was recorded
+func (s *fileProgressStore) loadCompleted(filename, contentHash string) (*dto.FileProcessingStatus, error) {
+	data, err := os.ReadFile(s.completedPath(filename, contentHash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read completed run: %w", err)
+	}
+
+	var status dto.FileProcessingStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("failed to decode completed run: %w", err)
+	}
+	return &status, nil
+}
+
+// saveCompleted records the status of a completed run, replacing an earlier run of the file with
+// the same content
+func (s *fileProgressStore) saveCompleted(status *dto.FileProcessingStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to encode completed run: %w", err)
+	}
+
+	if err := s.replace(s.completedPath(status.Filename, status.ContentHash), data); err != nil {
+		return fmt.Errorf("failed to save completed run: %w", err)
+	}
+	return nil
+}
+
+// replace writes data to path through a temporary file that is renamed into place
+func (s *fileProgressStore) replace(path string, data []byte) error {
+	if err := os.MkdirAll(s.directory, 0755); err != nil {
+		return fmt.Errorf("failed to create progress directory: %w", err)
+	}
+
+	return WriteFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// remove deletes the marker for filename if there is one
+func (s *fileProgressStore) remove(filename string) error {
+	if err := os.Remove(s.path(filename)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove progress marker: %w", err)
+	}
+	return nil
+}
@@ -98,7 +98,7 @@ func (s *FileSorter) sortInMemory(ctx context.Context, inputFile, outputPath str
 	}
 	defer inputFileHandle.Close()
 
-	reader := csv.NewReader(inputFileHandle)
+	reader := newCSVReader(inputFileHandle)
 	reader.FieldsPerRecord = -1 // Allow variable number of fields
 
 	// Read header
@@ -194,7 +194,7 @@ func (s *FileSorter) createSortedChunks(ctx context.Context, inputFile, tempDir
 	}
 	defer inputFileHandle.Close()
 
-	reader := csv.NewReader(inputFileHandle)
+	reader := newCSVReader(inputFileHandle)
 	reader.FieldsPerRecord = -1
 
 	// Read header
@@ -395,8 +395,7 @@ func (s *FileSorter) buildHeaderMap(header []string) (map[string]int, error) {
 	headerMap := make(map[string]int)
 
 	for i, col := range header {
-		normalizedCol := strings.ToLower(strings.TrimSpace(col))
-		headerMap[normalizedCol] = i
+		headerMap[normalizeCSVHeader(col)] = i
 	}
 
 	// Validate required headers
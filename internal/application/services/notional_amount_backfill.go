@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// NotionalAmountBackfill stores the notional amount of processed transactions that were
+// processed before notional amounts were stored, or whose amount could not be stored
+type NotionalAmountBackfill interface {
+	// Lifecycle operations. Start runs a single backfill in the background.
+	Start(ctx context.Context)
+	Stop()
+
+	// Run backfills in batches until no processed transaction without an amount is left
+	Run(ctx context.Context) (int64, error)
+}
+
+// NotionalAmountBackfillConfig holds configuration for the notional amount backfill
+type NotionalAmountBackfillConfig struct {
+	// BatchSize is the number of transactions updated per statement
+	BatchSize int
+}
+
+// notionalAmountBackfill implements NotionalAmountBackfill interface
+type notionalAmountBackfill struct {
+	transactionRepo repositories.TransactionRepository
+	config          NotionalAmountBackfillConfig
+	logger          logger.Logger
+
+	job *periodicJob
+}
+
+// NewNotionalAmountBackfill creates a new notional amount backfill
+func NewNotionalAmountBackfill(
+	transactionRepo repositories.TransactionRepository,
+	config NotionalAmountBackfillConfig,
+	lg logger.Logger,
+) NotionalAmountBackfill {
+	if lg == nil {
+		lg = logger.NewDevelopment()
+	}
+
+	// Set default configuration
+	if config.BatchSize == 0 {
+		config.BatchSize = 1000
+	}
+
+	backfill := &notionalAmountBackfill{
+		transactionRepo: transactionRepo,
+		config:          config,
+		logger:          lg,
+	}
+	// Without an interval the job runs the backfill once
+	backfill.job = newPeriodicJob(0, func(ctx context.Context) error {
+		_, err := backfill.Run(ctx)
+		return err
+	}, "Notional amount backfill failed", lg)
+
+	return backfill
+}
+
+// Start launches the backfill until it completes, Stop is called or ctx is cancelled
+func (b *notionalAmountBackfill) Start(ctx context.Context) {
+	if !b.job.Start(ctx) {
+		return
+	}
+
+	b.logger.Info("Starting notional amount backfill",
+		logger.Int("batchSize", b.config.BatchSize))
+}
+
+// Stop stops the backfill and waits for an in-flight batch to finish
+func (b *notionalAmountBackfill) Stop() {
+	b.job.Stop()
+}
+
+// Run backfills notional amounts in batches. Batches already updated stay updated if a later
+// one fails.
+func (b *notionalAmountBackfill) Run(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		updated, err := b.transactionRepo.BackfillNotionalAmounts(ctx, b.config.BatchSize)
+		if err != nil {
+			return total, fmt.Errorf("failed to backfill notional amounts after %d updates: %w", total, err)
+		}
+		total += updated
+
+		if updated < int64(b.config.BatchSize) {
+			break
+		}
+	}
+
+	if total > 0 {
+		b.logger.Info("Notional amount backfill completed",
+			logger.Int64("updated", total))
+	}
+
+	return total, nil
+}
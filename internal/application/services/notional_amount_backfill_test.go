@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// backfillTransactionRepo serves BackfillNotionalAmounts from a count of transactions missing
+// their notional amount
+type backfillTransactionRepo struct {
+	repositories.TransactionRepository
+
+	mu      sync.Mutex
+	missing int64
+	limits  []int
+	failOn  int // fails the nth backfill call when positive
+}
+
+func (r *backfillTransactionRepo) BackfillNotionalAmounts(ctx context.Context, limit int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.limits = append(r.limits, limit)
+	if r.failOn == len(r.limits) {
+		return 0, errors.New("connection reset")
+	}
+
+	updated := min(r.missing, int64(limit))
+	r.missing -= updated
+	return updated, nil
+}
+
+func TestNotionalAmountBackfill_Run(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Backfills in batches until none are missing", func(t *testing.T) {
+		repo := &backfillTransactionRepo{missing: 25}
+		backfill := NewNotionalAmountBackfill(repo, NotionalAmountBackfillConfig{BatchSize: 10}, logger.NewNoop())
+
+		updated, err := backfill.Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(25), updated)
+		assert.Equal(t, []int{10, 10, 10}, repo.limits)
+		assert.Zero(t, repo.missing)
+	})
+
+	t.Run("A full last batch is followed by an empty one", func(t *testing.T) {
+		repo := &backfillTransactionRepo{missing: 20}
+		backfill := NewNotionalAmountBackfill(repo, NotionalAmountBackfillConfig{BatchSize: 10}, logger.NewNoop())
+
+		updated, err := backfill.Run(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(20), updated)
+		assert.Len(t, repo.limits, 3)
+	})
+
+	t.Run("A failed batch reports the transactions already updated", func(t *testing.T) {
+		repo := &backfillTransactionRepo{missing: 25, failOn: 2}
+		backfill := NewNotionalAmountBackfill(repo, NotionalAmountBackfillConfig{BatchSize: 10}, logger.NewNoop())
+
+		updated, err := backfill.Run(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "after 10 updates")
+		assert.Equal(t, int64(10), updated)
+	})
+
+	t.Run("Start runs once in the background", func(t *testing.T) {
+		repo := &backfillTransactionRepo{missing: 5}
+		backfill := NewNotionalAmountBackfill(repo, NotionalAmountBackfillConfig{}, logger.NewNoop())
+
+		backfill.Start(ctx)
+		require.Eventually(t, func() bool {
+			repo.mu.Lock()
+			defer repo.mu.Unlock()
+			return repo.missing == 0
+		}, time.Second, 5*time.Millisecond)
+		backfill.Stop()
+		backfill.Stop()
+
+		assert.Equal(t, []int{1000}, repo.limits, "the batch size defaults to 1000")
+	})
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// periodicJob runs a pass in the background on every tick of its interval, or once when the
+// interval is zero, until it is stopped or its context is cancelled
+type periodicJob struct {
+	interval time.Duration
+	runOnce  func(ctx context.Context) error
+	// failure is the message logged when a pass fails
+	failure string
+	logger  logger.Logger
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newPeriodicJob creates a stopped periodic job
+func newPeriodicJob(interval time.Duration, runOnce func(ctx context.Context) error, failure string, lg logger.Logger) *periodicJob {
+	return &periodicJob{
+		interval: interval,
+		runOnce:  runOnce,
+		failure:  failure,
+		logger:   lg,
+	}
+}
+
+// Start launches the job and reports whether it was started; it is not started again while
+// it is running
+func (j *periodicJob) Start(ctx context.Context) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.cancel != nil {
+		return false
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	j.cancel = cancel
+	j.done = make(chan struct{})
+
+	go j.run(runCtx, j.done)
+	return true
+}
+
+// Stop stops the job, waits for an in-flight pass to finish and reports whether it was running
+func (j *periodicJob) Stop() bool {
+	j.mu.Lock()
+	cancel, done := j.cancel, j.done
+	j.cancel, j.done = nil, nil
+	j.mu.Unlock()
+
+	if cancel == nil {
+		return false
+	}
+
+	cancel()
+	<-done
+	return true
+}
+
+// run executes a single pass, or a pass on every tick when the job has an interval
+func (j *periodicJob) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	if j.interval <= 0 {
+		j.pass(ctx)
+		return
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.pass(ctx)
+		}
+	}
+}
+
+// pass runs the job once, logging a failure unless the job is being stopped
+func (j *periodicJob) pass(ctx context.Context) {
+	if err := j.runOnce(ctx); err != nil && ctx.Err() == nil {
+		j.logger.Error(j.failure, logger.Err(err))
+	}
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+func TestPeriodicJob(t *testing.T) {
+	t.Run("Runs on every tick until stopped", func(t *testing.T) {
+		var passes atomic.Int32
+		job := newPeriodicJob(time.Millisecond, func(ctx context.Context) error {
+			passes.Add(1)
+			return nil
+		}, "pass failed", logger.NewNoop())
+
+		assert.True(t, job.Start(context.Background()))
+		assert.False(t, job.Start(context.Background()), "already running")
+		assert.Eventually(t, func() bool { return passes.Load() >= 3 }, time.Second, time.Millisecond)
+
+		assert.True(t, job.Stop())
+		assert.False(t, job.Stop(), "already stopped")
+
+		stopped := passes.Load()
+		time.Sleep(5 * time.Millisecond)
+		assert.Equal(t, stopped, passes.Load())
+	})
+
+	t.Run("Runs once without an interval", func(t *testing.T) {
+		var passes atomic.Int32
+		finished := make(chan struct{})
+		job := newPeriodicJob(0, func(ctx context.Context) error {
+			passes.Add(1)
+			close(finished)
+			return nil
+		}, "pass failed", logger.NewNoop())
+
+		job.Start(context.Background())
+		<-finished
+		assert.True(t, job.Stop())
+		assert.Equal(t, int32(1), passes.Load())
+	})
+
+	t.Run("Stop waits for the pass in flight", func(t *testing.T) {
+		started := make(chan struct{})
+		var finished atomic.Bool
+		job := newPeriodicJob(0, func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			finished.Store(true)
+			return ctx.Err()
+		}, "pass failed", logger.NewNoop())
+
+		job.Start(context.Background())
+		<-started
+		job.Stop()
+		assert.True(t, finished.Load())
+	})
+}
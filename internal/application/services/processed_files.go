@@ -0,0 +1,63 @@
+package services
+
+import (
+	"path/filepath"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// ProcessedFiles records every completed run of a transaction file by its name and content
+// hash, so the same file is not submitted twice. Each name and hash pair is kept, so a file
+// reverted to content that was processed before is detected as well. The service and the CLI
+// share the records when they use the same progress directory.
+type ProcessedFiles struct {
+	store  *fileProgressStore
+	logger logger.Logger
+}
+
+// NewProcessedFiles creates a record of completed runs kept in progressDirectory
+func NewProcessedFiles(progressDirectory string, lg logger.Logger) *ProcessedFiles {
+	return &ProcessedFiles{
+		store:  newFileProgressStore(progressDirectory),
+		logger: lg,
+	}
+}
+
+// Check returns the content hash of the file at path, failing with a FileAlreadyProcessedError
+// if a completed run had the same file name and hash and force is not set. A file that cannot be
+// read has no hash; processing fails on it itself.
+func (p *ProcessedFiles) Check(path string, force bool) (string, error) {
+	filename := filepath.Base(path)
+	contentHash, err := hashFile(path)
+	if err != nil {
+		return "", nil
+	}
+
+	previous, err := p.store.loadCompleted(filename, contentHash)
+	if err != nil {
+		p.logger.Warn("Ignoring unreadable completed file processing record",
+			logger.String("filename", filename),
+			logger.Err(err))
+		return contentHash, nil
+	}
+	if previous == nil {
+		return contentHash, nil
+	}
+
+	if !force {
+		return "", &FileAlreadyProcessedError{Previous: *previous}
+	}
+	p.logger.Warn("Processing a file again that was already processed",
+		logger.String("filename", filename),
+		logger.String("contentHash", contentHash))
+	return contentHash, nil
+}
+
+// Record records a completed run of a file; runs without a content hash are not recorded
+func (p *ProcessedFiles) Record(status *dto.FileProcessingStatus) error {
+	if status.ContentHash == "" {
+		return nil
+	}
+	return p.store.saveCompleted(status)
+}
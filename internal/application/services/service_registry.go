@@ -41,8 +41,9 @@ type ServiceRegistryConfig struct {
 // ServiceRegistryDependencies holds all dependencies needed to create services
 type ServiceRegistryDependencies struct {
 	// Repositories
-	TransactionRepo repositories.TransactionRepository
-	BalanceRepo     repositories.BalanceRepository
+	TransactionRepo       repositories.TransactionRepository
+	BalanceRepo           repositories.BalanceRepository
+	BalanceAdjustmentRepo repositories.BalanceAdjustmentRepository
 
 	// Domain services
 	TransactionProcessor *services.TransactionProcessor
@@ -98,7 +99,7 @@ func NewServiceRegistry(deps ServiceRegistryDependencies, config ServiceRegistry
 		config.FileProcessor.TimeoutPerBatch = config.DefaultTimeout
 	}
 	if config.FileProcessor.MaxFileSize == 0 {
-		config.FileProcessor.MaxFileSize = 100 * 1024 * 1024 // 100MB
+		config.FileProcessor.MaxFileSize = DefaultMaxFileSize
 	}
 
 	// Create transaction service
@@ -116,6 +117,7 @@ func NewServiceRegistry(deps ServiceRegistryDependencies, config ServiceRegistry
 	balanceService := NewBalanceService(
 		deps.BalanceRepo,
 		deps.TransactionRepo,
+		deps.BalanceAdjustmentRepo,
 		*deps.BalanceCalculator,
 		deps.BalanceMapper,
 		config.Balance,
@@ -236,9 +238,8 @@ func DefaultServiceRegistryConfig() ServiceRegistryConfig {
 		DefaultTimeout: 30 * time.Second,
 		BatchSize:      1000,
 		Transaction: TransactionServiceConfig{
-			MaxBatchSize:          1000,
-			ProcessingTimeout:     30 * time.Second,
-			EnableAsyncProcessing: false,
+			MaxBatchSize:      1000,
+			ProcessingTimeout: 30 * time.Second,
 		},
 		Balance: BalanceServiceConfig{
 			MaxBulkUpdateSize:    1000,
@@ -248,9 +249,10 @@ func DefaultServiceRegistryConfig() ServiceRegistryConfig {
 		FileProcessor: FileProcessorConfig{
 			WorkingDirectory:   "./data",
 			ErrorFileDirectory: "./data/errors",
-			MaxFileSize:        100 * 1024 * 1024, // 100MB
+			MaxFileSize:        DefaultMaxFileSize,
 			MaxRecordsPerBatch: 1000,
 			TimeoutPerBatch:    5 * time.Minute,
+			SlowBatchThreshold: 30 * time.Second,
 			RequiredHeaders: []string{
 				"portfolio_id", "security_id", "source_id", "transaction_type",
 				"quantity", "price", "transaction_date",
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// TransactionReprocessor periodically retries transactions that failed for transient reasons
+type TransactionReprocessor interface {
+	// Lifecycle operations
+	Start(ctx context.Context)
+	Stop()
+
+	// RunOnce performs a single reprocessing pass
+	RunOnce(ctx context.Context) (*ReprocessingRunResult, error)
+}
+
+// TransactionReprocessorConfig holds configuration for the background reprocessor
+type TransactionReprocessorConfig struct {
+	Interval       time.Duration
+	BatchSize      int
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// ReprocessingRunResult summarizes a single reprocessing pass
+type ReprocessingRunResult struct {
+	Candidates int `json:"candidates"`
+	Skipped    int `json:"skipped"`
+	Processed  int `json:"processed"`
+	Failed     int `json:"failed"`
+	Dead       int `json:"dead"`
+}
+
+// transactionReprocessor implements TransactionReprocessor interface
+type transactionReprocessor struct {
+	transactionRepo      repositories.TransactionRepository
+	transactionProcessor *services.TransactionProcessor
+	config               TransactionReprocessorConfig
+	logger               logger.Logger
+
+	job *periodicJob
+}
+
+// NewTransactionReprocessor creates a new background transaction reprocessor
+func NewTransactionReprocessor(
+	transactionRepo repositories.TransactionRepository,
+	transactionProcessor *services.TransactionProcessor,
+	config TransactionReprocessorConfig,
+	lg logger.Logger,
+) TransactionReprocessor {
+	if lg == nil {
+		lg = logger.NewDevelopment()
+	}
+
+	// Set default configuration
+	if config.Interval == 0 {
+		config.Interval = time.Minute
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = 100
+	}
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = 3
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = 30 * time.Minute
+	}
+
+	reprocessor := &transactionReprocessor{
+		transactionRepo:      transactionRepo,
+		transactionProcessor: transactionProcessor,
+		config:               config,
+		logger:               lg,
+	}
+	reprocessor.job = newPeriodicJob(config.Interval, func(ctx context.Context) error {
+		_, err := reprocessor.RunOnce(ctx)
+		return err
+	}, "Transaction reprocessing pass failed", lg)
+
+	return reprocessor
+}
+
+// Start launches the periodic reprocessing loop until Stop is called or ctx is cancelled
+func (r *transactionReprocessor) Start(ctx context.Context) {
+	if !r.job.Start(ctx) {
+		return
+	}
+
+	r.logger.Info("Starting transaction reprocessor",
+		logger.String("interval", r.config.Interval.String()),
+		logger.Int("batchSize", r.config.BatchSize),
+		logger.Int("maxAttempts", r.config.MaxAttempts))
+}
+
+// Stop stops the reprocessing loop and waits for an in-flight pass to finish
+func (r *transactionReprocessor) Stop() {
+	if r.job.Stop() {
+		r.logger.Info("Transaction reprocessor stopped")
+	}
+}
+
+// RunOnce retries eligible transiently-failed transactions and marks exhausted ones DEAD
+func (r *transactionReprocessor) RunOnce(ctx context.Context) (*ReprocessingRunResult, error) {
+	retryable := true
+	candidates, err := r.transactionRepo.List(ctx, repositories.TransactionFilter{
+		Status:    stringPtr(models.TransactionStatusError.String()),
+		Retryable: &retryable,
+		Limit:     r.config.BatchSize,
+		SortBy:    []string{"updated_at", "id"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find retryable transactions: %w", err)
+	}
+
+	result := &ReprocessingRunResult{Candidates: len(candidates)}
+	now := time.Now()
+
+	for _, candidate := range candidates {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		if candidate.ReprocessingAttempts >= r.config.MaxAttempts {
+			if err := r.markDead(ctx, candidate); err != nil {
+				r.logger.Error("Failed to mark transaction as dead",
+					logger.Int64("transactionId", candidate.ID),
+					logger.Err(err))
+				continue
+			}
+			result.Dead++
+			continue
+		}
+
+		if now.Before(candidate.UpdatedAt.Add(r.backoff(candidate.ReprocessingAttempts))) {
+			result.Skipped++
+			continue
+		}
+
+		if r.reprocess(ctx, candidate) {
+			result.Processed++
+		} else {
+			result.Failed++
+		}
+	}
+
+	if result.Candidates > 0 {
+		r.logger.Info("Transaction reprocessing pass completed",
+			logger.Int("candidates", result.Candidates),
+			logger.Int("skipped", result.Skipped),
+			logger.Int("processed", result.Processed),
+			logger.Int("failed", result.Failed),
+			logger.Int("dead", result.Dead))
+	}
+
+	return result, nil
+}
+
+// reprocess attempts a single transaction and records the attempt when it fails again
+func (r *transactionReprocessor) reprocess(ctx context.Context, repoTxn *repositories.Transaction) bool {
+	domainTxn, err := convertRepoTransactionToDomain(repoTxn)
+	if err != nil {
+		r.logger.Error("Failed to convert transaction for reprocessing",
+			logger.Int64("transactionId", repoTxn.ID),
+			logger.Err(err))
+		return false
+	}
+
+	processingResult, err := r.transactionProcessor.ProcessTransaction(ctx, domainTxn)
+	if err == nil && processingResult != nil && processingResult.Success {
+		r.logger.Info("Transaction reprocessed successfully",
+			logger.Int64("transactionId", repoTxn.ID),
+			logger.Int("attempt", repoTxn.ReprocessingAttempts+1))
+		return true
+	}
+
+	if err != nil {
+		r.logger.Warn("Transaction reprocessing failed",
+			logger.Int64("transactionId", repoTxn.ID),
+			logger.Err(err))
+	}
+
+	// The processor has bumped the version when recording the failure
+	current, err := r.transactionRepo.GetByID(ctx, repoTxn.ID)
+	if err != nil {
+		r.logger.Error("Failed to reload transaction after reprocessing",
+			logger.Int64("transactionId", repoTxn.ID),
+			logger.Err(err))
+		return false
+	}
+
+	if err := r.transactionRepo.IncrementReprocessingAttempts(ctx, current.ID, current.Version); err != nil {
+		r.logger.Error("Failed to record reprocessing attempt",
+			logger.Int64("transactionId", repoTxn.ID),
+			logger.Err(err))
+	}
+
+	return false
+}
+
+// markDead moves a transaction whose automatic attempts are exhausted to DEAD
+func (r *transactionReprocessor) markDead(ctx context.Context, repoTxn *repositories.Transaction) error {
+	message := fmt.Sprintf("automatic reprocessing abandoned after %d attempts", repoTxn.ReprocessingAttempts)
+	if repoTxn.ErrorMessage != nil {
+		message = fmt.Sprintf("%s: %s", message, *repoTxn.ErrorMessage)
+	}
+	if len(message) > 255 {
+		message = message[:255]
+	}
+
+	if err := r.transactionRepo.UpdateStatus(ctx, repoTxn.ID, models.TransactionStatusDead.String(), &message, repoTxn.Version); err != nil {
+		return err
+	}
+
+	r.logger.Warn("Transaction marked as dead",
+		logger.Int64("transactionId", repoTxn.ID),
+		logger.Int("attempts", repoTxn.ReprocessingAttempts))
+
+	return nil
+}
+
+// backoff returns the delay required after the last failure before the next attempt
+func (r *transactionReprocessor) backoff(attempts int) time.Duration {
+	delay := r.config.InitialBackoff
+	for i := 0; i < attempts && delay < r.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.config.MaxBackoff {
+		delay = r.config.MaxBackoff
+	}
+	return delay
+}
+
+// convertRepoTransactionToDomain converts a repository transaction to a domain transaction
+func convertRepoTransactionToDomain(repoTxn *repositories.Transaction) (*models.Transaction, error) {
+	builder := models.NewTransactionBuilder().
+		WithID(repoTxn.ID).
+		WithPortfolioID(repoTxn.PortfolioID).
+		WithSecurityID(repoTxn.SecurityID).
+		WithSourceID(repoTxn.SourceID).
+		WithTransactionType(repoTxn.TransactionType).
+		WithStatus(repoTxn.Status).
+		WithQuantity(repoTxn.Quantity).
+		WithPrice(repoTxn.Price).
+		WithTransactionDate(repoTxn.TransactionDate).
+		WithSettlementDate(repoTxn.SettlementDate).
+		WithReprocessingAttempts(repoTxn.ReprocessingAttempts).
+		WithVersion(repoTxn.Version).
+		WithTimestamps(repoTxn.CreatedAt, repoTxn.UpdatedAt)
+
+	if repoTxn.ErrorMessage != nil {
+		builder.WithErrorMessage(*repoTxn.ErrorMessage)
+	}
+
+	return builder.Build()
+}
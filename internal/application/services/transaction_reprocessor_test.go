@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	domainServices "github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+const testPortfolioID = "PORTFOLIO123456789012345"
+
+// fakeTransactionRepo is an in-memory TransactionRepository for the methods used by processing
+type fakeTransactionRepo struct {
+	repositories.TransactionRepository
+
+	mu           sync.Mutex
+	transactions map[int64]*repositories.Transaction
+
+	deleteBatchSizes []int
+}
+
+func newFakeTransactionRepo(transactions ...*repositories.Transaction) *fakeTransactionRepo {
+	repo := &fakeTransactionRepo{transactions: make(map[int64]*repositories.Transaction)}
+	for _, txn := range transactions {
+		repo.transactions[txn.ID] = txn
+	}
+	return repo
+}
+
+func (r *fakeTransactionRepo) get(id int64) repositories.Transaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.transactions[id]
+}
+
+func (r *fakeTransactionRepo) GetByID(ctx context.Context, id int64) (*repositories.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	txn, ok := r.transactions[id]
+	if !ok {
+		return nil, repositories.NewNotFoundError("transaction", id)
+	}
+	clone := *txn
+	return &clone, nil
+}
+
+func (r *fakeTransactionRepo) List(ctx context.Context, filter repositories.TransactionFilter) ([]*repositories.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var result []*repositories.Transaction
+	for _, txn := range r.transactions {
+		if filter.Status != nil && txn.Status != *filter.Status {
+			continue
+		}
+		if filter.Retryable != nil && txn.ErrorRetryable != *filter.Retryable {
+			continue
+		}
+		if filter.PortfolioID != nil && txn.PortfolioID != *filter.PortfolioID {
+			continue
+		}
+		if filter.TransactionDate != nil && !txn.TransactionDate.Equal(*filter.TransactionDate) {
+			continue
+		}
+		if len(filter.Statuses) > 0 && !containsString(filter.Statuses, txn.Status) {
+			continue
+		}
+		if filter.AfterID != nil && txn.ID <= *filter.AfterID {
+			continue
+		}
+		if len(filter.IDs) > 0 && !containsID(filter.IDs, txn.ID) {
+			continue
+		}
+		clone := *txn
+		result = append(result, &clone)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	if filter.Limit > 0 && filter.Limit < len(result) {
+		result = result[:filter.Limit]
+	}
+	return result, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fakeTransactionRepo) update(id int64, version int, apply func(txn *repositories.Transaction)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	txn, ok := r.transactions[id]
+	if !ok {
+		return repositories.NewNotFoundError("transaction", id)
+	}
+	if txn.Version != version {
+		return repositories.NewOptimisticLockError("transaction", id, version, txn.Version)
+	}
+	apply(txn)
+	txn.Version++
+	txn.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *fakeTransactionRepo) UpdateStatus(ctx context.Context, id int64, status string, errorMessage *string, version int) error {
+	return r.update(id, version, func(txn *repositories.Transaction) {
+		txn.Status = status
+		txn.ErrorMessage = errorMessage
+		txn.ErrorRetryable = false
+	})
+}
+
+func (r *fakeTransactionRepo) MarkRetryableError(ctx context.Context, id int64, errorMessage *string, version int) error {
+	return r.update(id, version, func(txn *repositories.Transaction) {
+		txn.Status = models.TransactionStatusError.String()
+		txn.ErrorMessage = errorMessage
+		txn.ErrorRetryable = true
+	})
+}
+
+func (r *fakeTransactionRepo) IncrementReprocessingAttempts(ctx context.Context, id int64, version int) error {
+	return r.update(id, version, func(txn *repositories.Transaction) {
+		txn.ReprocessingAttempts++
+	})
+}
+
+// flakyBalanceRepo holds a single cash balance whose first updates fail with lock conflicts
+type flakyBalanceRepo struct {
+	repositories.BalanceRepository
+
+	mu        sync.Mutex
+	cash      *repositories.Balance
+	conflicts int
+}
+
+func (r *flakyBalanceRepo) GetCashBalance(ctx context.Context, portfolioID string) (*repositories.Balance, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clone := *r.cash
+	return &clone, nil
+}
+
+func (r *flakyBalanceRepo) Update(ctx context.Context, balance *repositories.Balance) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conflicts != 0 {
+		r.conflicts--
+		return repositories.NewOptimisticLockError("balance", balance.ID, balance.Version, balance.Version+1)
+	}
+
+	r.cash.QuantityLong = balance.QuantityLong
+	r.cash.QuantityShort = balance.QuantityShort
+	r.cash.Version++
+	return nil
+}
+
+func (r *flakyBalanceRepo) cashLong() decimal.Decimal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cash.QuantityLong
+}
+
+func newReprocessingFixture(t *testing.T, conflicts int, config TransactionReprocessorConfig) (*fakeTransactionRepo, *flakyBalanceRepo, TransactionReprocessor) {
+	t.Helper()
+
+	now := time.Now()
+	txnRepo := newFakeTransactionRepo(&repositories.Transaction{
+		ID:              1,
+		PortfolioID:     testPortfolioID,
+		SourceID:        "DEP-REPROCESS-1",
+		Status:          models.TransactionStatusNew.String(),
+		TransactionType: models.TransactionTypeDep.String(),
+		Quantity:        decimal.NewFromInt(250),
+		Price:           decimal.NewFromInt(1),
+		TransactionDate: now,
+		Version:         1,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	balanceRepo := &flakyBalanceRepo{
+		cash: &repositories.Balance{
+			ID:            10,
+			PortfolioID:   testPortfolioID,
+			QuantityLong:  decimal.NewFromInt(1000),
+			QuantityShort: decimal.Zero,
+			Version:       1,
+			CreatedAt:     now,
+			LastUpdated:   now,
+		},
+		conflicts: conflicts,
+	}
+
+	lg := logger.NewNoop()
+	processor := domainServices.NewTransactionProcessor(
+		txnRepo,
+		balanceRepo,
+		domainServices.NewTransactionValidator(txnRepo, balanceRepo, lg),
+		domainServices.NewBalanceCalculator(balanceRepo, lg),
+		lg,
+	)
+
+	// Initial processing hits a lock conflict and leaves a retryable ERROR behind
+	stored := txnRepo.get(1)
+	domainTxn, err := convertRepoTransactionToDomain(&stored)
+	require.NoError(t, err)
+	result, err := processor.ProcessTransaction(context.Background(), domainTxn)
+	require.NoError(t, err)
+	require.True(t, result.Retryable)
+
+	return txnRepo, balanceRepo, NewTransactionReprocessor(txnRepo, processor, config, lg)
+}
+
+func TestTransactionReprocessor_TransientFailureReachesProc(t *testing.T) {
+	txnRepo, balanceRepo, reprocessor := newReprocessingFixture(t, 2, TransactionReprocessorConfig{
+		Interval:    10 * time.Millisecond,
+		BatchSize:   10,
+		MaxAttempts: 3,
+	})
+
+	stored := txnRepo.get(1)
+	require.Equal(t, models.TransactionStatusError.String(), stored.Status)
+	require.True(t, stored.ErrorRetryable)
+
+	reprocessor.Start(context.Background())
+	defer reprocessor.Stop()
+
+	require.Eventually(t, func() bool {
+		return txnRepo.get(1).Status == models.TransactionStatusProc.String()
+	}, 2*time.Second, 10*time.Millisecond)
+
+	stored = txnRepo.get(1)
+	assert.Equal(t, 1, stored.ReprocessingAttempts)
+	assert.False(t, stored.ErrorRetryable)
+	assert.True(t, decimal.NewFromInt(1250).Equal(balanceRepo.cashLong()))
+}
+
+func TestTransactionReprocessor_RunOnce(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Marks transaction DEAD after max attempts", func(t *testing.T) {
+		txnRepo, balanceRepo, reprocessor := newReprocessingFixture(t, 100, TransactionReprocessorConfig{
+			BatchSize:   10,
+			MaxAttempts: 2,
+		})
+
+		for i := 0; i < 2; i++ {
+			result, err := reprocessor.RunOnce(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 1, result.Failed)
+		}
+
+		result, err := reprocessor.RunOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Dead)
+
+		stored := txnRepo.get(1)
+		assert.Equal(t, models.TransactionStatusDead.String(), stored.Status)
+		assert.Equal(t, 2, stored.ReprocessingAttempts)
+		require.NotNil(t, stored.ErrorMessage)
+		assert.Contains(t, *stored.ErrorMessage, "automatic reprocessing abandoned after 2 attempts: Failed to persist balance changes")
+		assert.True(t, decimal.NewFromInt(1000).Equal(balanceRepo.cashLong()))
+
+		result, err = reprocessor.RunOnce(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, result.Candidates)
+	})
+
+	t.Run("Waits for backoff before retrying", func(t *testing.T) {
+		txnRepo, _, reprocessor := newReprocessingFixture(t, 1, TransactionReprocessorConfig{
+			BatchSize:      10,
+			MaxAttempts:    3,
+			InitialBackoff: time.Hour,
+			MaxBackoff:     time.Hour,
+		})
+
+		result, err := reprocessor.RunOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Skipped)
+		assert.Equal(t, models.TransactionStatusError.String(), txnRepo.get(1).Status)
+	})
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// TransactionRetentionService deletes transactions that are past their retention window.
+// Balances are left as they are: they already reflect the deleted transactions, so a later
+// recompute or consistency check of an affected portfolio no longer matches them.
+type TransactionRetentionService interface {
+	PurgeTransactions(ctx context.Context, request dto.TransactionRetentionRequest) (*dto.TransactionRetentionResponse, error)
+}
+
+// TransactionRetentionConfig holds configuration for the transaction retention service
+type TransactionRetentionConfig struct {
+	// MinimumAge is how far in the past a cutoff must at least be
+	MinimumAge time.Duration
+	// BatchSize is the number of transactions deleted per statement
+	BatchSize int
+}
+
+// RetentionValidationError is returned when a retention request is invalid
+type RetentionValidationError struct {
+	Errors []dto.ValidationError
+}
+
+// Error implements the error interface
+func (e *RetentionValidationError) Error() string {
+	return fmt.Sprintf("retention validation failed: %d errors", len(e.Errors))
+}
+
+// transactionRetentionService implements TransactionRetentionService interface
+type transactionRetentionService struct {
+	transactionRepo repositories.TransactionRepository
+	config          TransactionRetentionConfig
+	logger          logger.Logger
+	now             func() time.Time
+}
+
+// NewTransactionRetentionService creates a new transaction retention service
+func NewTransactionRetentionService(
+	transactionRepo repositories.TransactionRepository,
+	config TransactionRetentionConfig,
+	lg logger.Logger,
+) TransactionRetentionService {
+	if lg == nil {
+		lg = logger.NewDevelopment()
+	}
+
+	// Set default configuration
+	if config.MinimumAge == 0 {
+		config.MinimumAge = 365 * 24 * time.Hour
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = 1000
+	}
+
+	return &transactionRetentionService{
+		transactionRepo: transactionRepo,
+		config:          config,
+		logger:          lg,
+		now:             time.Now,
+	}
+}
+
+// PurgeTransactions counts, and unless the request is a dry run deletes, the transactions dated
+// before the request's cutoff. Only transactions in a final status can be selected, so pending
+// work is never removed.
+func (s *transactionRetentionService) PurgeTransactions(ctx context.Context, request dto.TransactionRetentionRequest) (*dto.TransactionRetentionResponse, error) {
+	cutoff := request.Before.UTC().Truncate(24 * time.Hour)
+	statuses, validationErrors := s.validate(cutoff, request.Statuses)
+	if len(validationErrors) > 0 {
+		return nil, &RetentionValidationError{Errors: validationErrors}
+	}
+
+	matched, err := s.transactionRepo.CountTransactionsOlderThan(ctx, cutoff, statuses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count transactions older than cutoff: %w", err)
+	}
+
+	response := &dto.TransactionRetentionResponse{
+		Before:   cutoff.Format("2006-01-02"),
+		Statuses: statuses,
+		DryRun:   request.DryRun,
+		Matched:  matched,
+	}
+	if request.DryRun || matched == 0 {
+		return response, nil
+	}
+
+	s.logger.Warn("Deleting transactions past their retention window",
+		logger.String("before", response.Before),
+		logger.Any("statuses", statuses),
+		logger.Int64("matched", matched))
+
+	deleted, err := s.transactionRepo.DeleteTransactionsOlderThan(ctx, cutoff, statuses, s.config.BatchSize)
+	response.Deleted = deleted
+	if err != nil {
+		s.logger.Error("Transaction retention delete stopped early",
+			logger.Err(err),
+			logger.Int64("deleted", deleted))
+		return nil, fmt.Errorf("failed to delete transactions older than cutoff after %d deletions: %w", deleted, err)
+	}
+
+	return response, nil
+}
+
+// validate checks the cutoff against the minimum age and normalizes the statuses
+func (s *transactionRetentionService) validate(cutoff time.Time, requested []string) ([]string, []dto.ValidationError) {
+	var errors []dto.ValidationError
+
+	if latest := s.now().UTC().Add(-s.config.MinimumAge); cutoff.After(latest) {
+		errors = append(errors, dto.ValidationError{
+			Field:   "before",
+			Message: fmt.Sprintf("must be on or before %s", latest.Format("2006-01-02")),
+			Value:   cutoff.Format("2006-01-02"),
+		})
+	}
+
+	statuses := make([]string, 0, len(requested))
+	for _, raw := range requested {
+		status, err := models.ParseTransactionStatus(raw)
+		if err != nil || !status.IsFinalState() {
+			errors = append(errors, dto.ValidationError{
+				Field:   "statuses",
+				Message: "must be one of: PROC, FATAL, DEAD",
+				Value:   raw,
+			})
+			continue
+		}
+		statuses = append(statuses, status.String())
+	}
+	if len(requested) == 0 {
+		for _, status := range models.AllTransactionStatuses() {
+			if status.IsFinalState() {
+				statuses = append(statuses, status.String())
+			}
+		}
+	}
+
+	return statuses, errors
+}
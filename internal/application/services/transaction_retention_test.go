@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// retentionMatches reports whether a transaction is selected by a retention cutoff and statuses
+func retentionMatches(txn *repositories.Transaction, cutoff time.Time, statuses []string) bool {
+	if !txn.TransactionDate.Before(cutoff) {
+		return false
+	}
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, status := range statuses {
+		if txn.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fakeTransactionRepo) CountTransactionsOlderThan(ctx context.Context, cutoff time.Time, statuses []string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for _, txn := range r.transactions {
+		if retentionMatches(txn, cutoff, statuses) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *fakeTransactionRepo) DeleteTransactionsOlderThan(ctx context.Context, cutoff time.Time, statuses []string, batchSize int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deleteBatchSizes = append(r.deleteBatchSizes, batchSize)
+
+	var deleted int64
+	for id, txn := range r.transactions {
+		if retentionMatches(txn, cutoff, statuses) {
+			delete(r.transactions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func TestTransactionRetentionService_PurgeTransactions(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	newRepo := func() *fakeTransactionRepo {
+		return newFakeTransactionRepo(
+			&repositories.Transaction{ID: 1, Status: "PROC", TransactionDate: day(2023, 1, 10)},
+			&repositories.Transaction{ID: 2, Status: "FATAL", TransactionDate: day(2023, 2, 10)},
+			&repositories.Transaction{ID: 3, Status: "ERROR", TransactionDate: day(2023, 3, 10)},
+			&repositories.Transaction{ID: 4, Status: "PROC", TransactionDate: day(2025, 1, 10)},
+		)
+	}
+	newService := func(repo *fakeTransactionRepo) *transactionRetentionService {
+		service := NewTransactionRetentionService(repo, TransactionRetentionConfig{BatchSize: 2}, logger.NewNoop()).(*transactionRetentionService)
+		service.now = func() time.Time { return now }
+		return service
+	}
+
+	t.Run("Dry run counts without deleting", func(t *testing.T) {
+		repo := newRepo()
+
+		result, err := newService(repo).PurgeTransactions(context.Background(), dto.TransactionRetentionRequest{
+			Before: day(2024, 1, 1),
+			DryRun: true,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(2), result.Matched, "ERROR transactions are pending work and never selected")
+		assert.Equal(t, int64(0), result.Deleted)
+		assert.Equal(t, "2024-01-01", result.Before)
+		assert.Equal(t, []string{"PROC", "FATAL", "DEAD"}, result.Statuses)
+		assert.Empty(t, repo.deleteBatchSizes)
+		assert.Len(t, repo.transactions, 4)
+	})
+
+	t.Run("Deletes matching transactions in configured batches", func(t *testing.T) {
+		repo := newRepo()
+
+		result, err := newService(repo).PurgeTransactions(context.Background(), dto.TransactionRetentionRequest{
+			Before:   day(2024, 1, 1),
+			Statuses: []string{"proc"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(1), result.Matched)
+		assert.Equal(t, int64(1), result.Deleted)
+		assert.Equal(t, []string{"PROC"}, result.Statuses)
+		assert.Equal(t, []int{2}, repo.deleteBatchSizes)
+		assert.NotContains(t, repo.transactions, int64(1))
+		assert.Len(t, repo.transactions, 3)
+	})
+
+	t.Run("Nothing to delete skips the delete", func(t *testing.T) {
+		repo := newRepo()
+
+		result, err := newService(repo).PurgeTransactions(context.Background(), dto.TransactionRetentionRequest{
+			Before: day(2022, 1, 1),
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(0), result.Matched)
+		assert.Empty(t, repo.deleteBatchSizes)
+	})
+
+	t.Run("Rejects recent cutoffs and non-final statuses", func(t *testing.T) {
+		repo := newRepo()
+
+		_, err := newService(repo).PurgeTransactions(context.Background(), dto.TransactionRetentionRequest{
+			Before:   day(2026, 1, 1),
+			Statuses: []string{"ERROR", "BOGUS"},
+		})
+
+		var validationErr *RetentionValidationError
+		require.True(t, errors.As(err, &validationErr))
+		require.Len(t, validationErr.Errors, 3)
+		assert.Equal(t, "before", validationErr.Errors[0].Field)
+		assert.Equal(t, "statuses", validationErr.Errors[1].Field)
+		assert.Equal(t, "statuses", validationErr.Errors[2].Field)
+		assert.Len(t, repo.transactions, 4)
+	})
+}
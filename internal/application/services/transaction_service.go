@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
@@ -11,19 +13,39 @@ import (
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/services"
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // TransactionService interface defines transaction application service operations
 type TransactionService interface {
 	// Transaction CRUD operations
 	CreateTransaction(ctx context.Context, transactionDTO dto.TransactionPostDTO) (*dto.TransactionResponseDTO, error)
-	CreateTransactions(ctx context.Context, transactionDTOs []dto.TransactionPostDTO) (*dto.TransactionBatchResponse, error)
+	CreateTransactions(ctx context.Context, transactionDTOs []dto.TransactionPostDTO, options BatchOptions) (*dto.TransactionBatchResponse, error)
+	CreateTransactionsStrict(ctx context.Context, transactionDTOs []dto.TransactionPostDTO, options BatchOptions) (*dto.TransactionBatchResponse, error)
 	GetTransaction(ctx context.Context, id int64) (*dto.TransactionResponseDTO, error)
+	GetTransactionsByIDs(ctx context.Context, ids []int64) (*dto.TransactionBatchGetResponse, error)
+	GetTransactionsBySourceIDs(ctx context.Context, sourceIDs []string) (*dto.TransactionSourceBatchGetResponse, error)
+	GetLatestTransactions(ctx context.Context, portfolioIDs []string) (*dto.LatestTransactionsResponse, error)
 	GetTransactions(ctx context.Context, filter dto.TransactionFilter) (*dto.TransactionListResponse, error)
+	CountTransactions(ctx context.Context, filter dto.TransactionFilter) (int64, error)
+	StreamTransactions(ctx context.Context, filter dto.TransactionFilter, emit func(dto.TransactionResponseDTO) error) (int, error)
+	GetTransactionHistory(ctx context.Context, id int64) (*dto.TransactionHistoryResponse, error)
+	GetTransactionBalanceImpact(ctx context.Context, id int64, state string) (*dto.TransactionBalanceImpactDTO, error)
+	GetTransactionBalances(ctx context.Context, id int64) (*dto.TransactionBalancesDTO, error)
+
+	// Validation operations
+	ValidateTransaction(ctx context.Context, transactionDTO dto.TransactionPostDTO, checkSourceID bool) (*dto.TransactionValidationResponse, error)
+	FindStoredTransactions(ctx context.Context, sourceIDs []string) (map[string]StoredTransaction, error)
 
 	// Transaction processing operations
 	ProcessTransaction(ctx context.Context, id int64) (*dto.TransactionProcessingResult, error)
 	ReprocessFailedTransactions(ctx context.Context, filter dto.TransactionFilter) (*dto.TransactionBatchResponse, error)
+	ReleaseQuarantinedTransactions(ctx context.Context, limit int) (*dto.QuarantineReleaseResponse, error)
+	RecomputePortfolioBalances(ctx context.Context, portfolioID string) (*dto.PortfolioRecomputeResponse, error)
+	GetPortfolioBalancesAsOf(ctx context.Context, portfolioID string, asOf time.Time) (*dto.PortfolioBalancesAsOfResponse, error)
+	GetPortfolioLedger(ctx context.Context, portfolioID string, from, to time.Time) (*dto.PortfolioLedgerResponse, error)
+	StreamPortfolioLedger(ctx context.Context, portfolioID string, from, to time.Time, emit func(dto.LedgerEntryDTO) error) (int, error)
+	CheckPortfolioConsistency(ctx context.Context, portfolioID string) (*dto.ConsistencyCheckResponse, error)
 
 	// Statistics and reporting
 	GetTransactionStats(ctx context.Context, filter dto.TransactionFilter) (*dto.TransactionStatsDTO, error)
@@ -32,6 +54,82 @@ type TransactionService interface {
 	GetServiceHealth(ctx context.Context) error
 }
 
+// BatchValidationError is returned by CreateTransactionsStrict when any transaction in the batch
+// fails validation, or with a UnitOfWork fails to be created or processed; nothing was created
+type BatchValidationError struct {
+	Total  int
+	Failed []dto.IndexedTransactionErrorDTO
+	// DailyLimitExceeded is how many of the failed transactions are beyond their portfolio's
+	// daily transaction limit
+	DailyLimitExceeded int
+}
+
+// Error implements the error interface
+func (e *BatchValidationError) Error() string {
+	return fmt.Sprintf("batch validation failed: %d of %d transactions invalid", len(e.Failed), e.Total)
+}
+
+// BatchAbortedError is returned by CreateTransactions and CreateTransactionsStrict when
+// AbortOnInfrastructureError is set and a transaction could not be created for a reason unrelated
+// to the transaction itself, such as an unreachable database. The transactions before Index were
+// created and processed, unless a strict batch was rolled back in its UnitOfWork; nothing after
+// it was attempted. The batch can be resubmitted as a whole, the transactions already created
+// are then reported as duplicates of their source ID.
+type BatchAbortedError struct {
+	Index   int
+	Created int
+	Total   int
+	Cause   error
+}
+
+// Error implements the error interface
+func (e *BatchAbortedError) Error() string {
+	return fmt.Sprintf("batch aborted at transaction %d of %d after %d created: %v", e.Index, e.Total, e.Created, e.Cause)
+}
+
+// Unwrap returns the infrastructure error that aborted the batch
+func (e *BatchAbortedError) Unwrap() error {
+	return e.Cause
+}
+
+// BatchGetTooLargeError is returned when a batch get names more transactions, or a latest
+// transactions request more portfolios, than MaxBatchGetIDs
+type BatchGetTooLargeError struct {
+	Requested int
+	Max       int
+}
+
+// Error implements the error interface
+func (e *BatchGetTooLargeError) Error() string {
+	return fmt.Sprintf("too many transactions requested: %d (maximum %d)", e.Requested, e.Max)
+}
+
+// LedgerWindowTooLargeError is returned when a ledger window covers more days than
+// MaxLedgerWindowDays
+type LedgerWindowTooLargeError struct {
+	Days int
+	Max  int
+}
+
+// Error implements the error interface
+func (e *LedgerWindowTooLargeError) Error() string {
+	return fmt.Sprintf("ledger window too large: %d days (maximum %d)", e.Days, e.Max)
+}
+
+// ErrTransactionNotProcessed is returned when the balance impact at processing time is requested
+// for a transaction that has not been processed
+var ErrTransactionNotProcessed = errors.New("transaction has not been processed")
+
+// InvalidImpactStateError is returned when a balance impact is requested against an unknown state
+type InvalidImpactStateError struct {
+	State string
+}
+
+// Error implements the error interface
+func (e *InvalidImpactStateError) Error() string {
+	return fmt.Sprintf("invalid impact state %q: must be %s or %s", e.State, services.ImpactStateProcessing, services.ImpactStateCurrent)
+}
+
 // transactionService implements TransactionService interface
 type transactionService struct {
 	transactionRepo      repositories.TransactionRepository
@@ -40,14 +138,36 @@ type transactionService struct {
 	validator            services.TransactionValidator
 	transactionMapper    *mappers.TransactionMapper
 	config               TransactionServiceConfig
+	consistencyMetrics   *consistencyMetrics
 	logger               logger.Logger
 }
 
 // TransactionServiceConfig holds configuration for transaction service
 type TransactionServiceConfig struct {
-	MaxBatchSize          int
-	ProcessingTimeout     time.Duration
-	EnableAsyncProcessing bool
+	MaxBatchSize int
+	// ProcessingTimeout bounds the validation and creation of a batch; transactions not reached
+	// by then are returned unprocessed
+	ProcessingTimeout time.Duration
+	// StreamPageSize is how many transactions a streamed listing reads from the database at a time
+	StreamPageSize int
+	// MaxBatchGetIDs is the largest number of transactions one batch get request, by ID or by
+	// source ID, or portfolios one latest transactions request, may name
+	MaxBatchGetIDs int
+	// ValidationConcurrency is how many transactions of a batch are validated at a time;
+	// creation and balance updates always run one transaction at a time in batch order
+	ValidationConcurrency int
+	// MaxLedgerWindowDays is the most days, first and last included, one ledger request may cover
+	MaxLedgerWindowDays int
+	// AbortOnInfrastructureError stops a batch with a *BatchAbortedError when a transaction cannot
+	// be created because of a database failure, instead of reporting that transaction as failed
+	// and continuing with the next
+	AbortOnInfrastructureError bool
+	// MeterProvider records consistency check metrics; nil uses the global provider
+	MeterProvider metric.MeterProvider
+	// UnitOfWork creates and processes a strict batch in one database transaction, rolled back
+	// as a whole when any transaction fails or the batch runs past ProcessingTimeout; nil
+	// creates a strict batch one transaction at a time like any other
+	UnitOfWork repositories.UnitOfWork
 }
 
 // NewTransactionService creates a new transaction application service
@@ -71,6 +191,18 @@ func NewTransactionService(
 	if config.ProcessingTimeout == 0 {
 		config.ProcessingTimeout = 30 * time.Second
 	}
+	if config.StreamPageSize == 0 {
+		config.StreamPageSize = 500
+	}
+	if config.MaxBatchGetIDs == 0 {
+		config.MaxBatchGetIDs = 100
+	}
+	if config.ValidationConcurrency == 0 {
+		config.ValidationConcurrency = 4
+	}
+	if config.MaxLedgerWindowDays == 0 {
+		config.MaxLedgerWindowDays = 366
+	}
 
 	return &transactionService{
 		transactionRepo:      transactionRepo,
@@ -79,6 +211,7 @@ func NewTransactionService(
 		validator:            validator,
 		transactionMapper:    transactionMapper,
 		config:               config,
+		consistencyMetrics:   newConsistencyMetrics(config.MeterProvider, lg),
 		logger:               lg,
 	}
 }
@@ -114,12 +247,19 @@ func (s *transactionService) CreateTransaction(ctx context.Context, transactionD
 			logger.String("sourceId", transactionDTO.SourceID))
 		return nil, fmt.Errorf("business validation failed: %s", validationResult.Errors[0].Message)
 	}
+	if validationResult.Quarantine {
+		domainTransaction = domainTransaction.SetStatus(models.TransactionStatusQuarantined, nil)
+	}
 
 	// Convert domain transaction to repository transaction for persistence
 	repoTransaction := s.convertDomainToRepo(domainTransaction)
 
 	// Create transaction in repository with status NEW
-	err = s.transactionRepo.Create(ctx, repoTransaction)
+	err = s.storeTransaction(ctx, repoTransaction)
+	var limitErr *services.DailyLimitExceededError
+	if errors.As(err, &limitErr) {
+		return nil, fmt.Errorf("transaction rejected: %w", err)
+	}
 	if err != nil {
 		s.logger.Error("Failed to create transaction in repository",
 			logger.Err(err),
@@ -134,6 +274,14 @@ func (s *transactionService) CreateTransaction(ctx context.Context, transactionD
 	// Convert back to domain transaction with ID for processing
 	domainTransactionWithID := s.convertRepoToDomain(repoTransaction)
 
+	// A quarantined transaction waits for its references; ReleaseQuarantinedTransactions processes it
+	if domainTransactionWithID.Status() == models.TransactionStatusQuarantined {
+		s.logger.Warn("Transaction quarantined for unknown references",
+			logger.Int64("transactionId", repoTransaction.ID),
+			logger.String("sourceId", transactionDTO.SourceID))
+		return s.transactionMapper.ToResponseDTO(domainTransactionWithID), nil
+	}
+
 	// STEP 2: Process transaction to update balances and set status to PROC
 	// This implements the required business workflow from requirements
 	processingResult, err := s.transactionProcessor.ProcessTransaction(ctx, domainTransactionWithID)
@@ -175,8 +323,9 @@ func (s *transactionService) CreateTransaction(ctx context.Context, transactionD
 	return s.transactionMapper.ToResponseDTO(processedDomainTransaction), nil
 }
 
-// CreateTransactions creates multiple transactions in a batch
-func (s *transactionService) CreateTransactions(ctx context.Context, transactionDTOs []dto.TransactionPostDTO) (*dto.TransactionBatchResponse, error) {
+// CreateTransactions creates multiple transactions in a batch, adding timing to the summary when
+// options ask for it
+func (s *transactionService) CreateTransactions(ctx context.Context, transactionDTOs []dto.TransactionPostDTO, options BatchOptions) (*dto.TransactionBatchResponse, error) {
 	s.logger.Info("Creating batch of transactions",
 		logger.Int("count", len(transactionDTOs)))
 
@@ -193,144 +342,998 @@ func (s *transactionService) CreateTransactions(ctx context.Context, transaction
 		}, nil
 	}
 
-	var successful []*models.Transaction
+	start := time.Now()
+	deadline := s.batchDeadline()
+	var created []*createdTransaction
+	var resubmitted []mappers.IndexedTransaction
 	var failed []dto.TransactionErrorDTO
 
-	// Process each transaction
+	// Records sharing a source ID would otherwise fail one by one on the unique constraint
+	// partway through the batch, so they are all rejected before anything is written
+	duplicates := duplicateSourceIDs(transactionDTOs)
+
+	// Validation only reads, so it runs concurrently; writes then follow one at a time
+	validated := s.validateBatch(ctx, transactionDTOs, duplicates)
+
+	// Create each transaction, then process the created ones together
+	unprocessed, limited := 0, 0
 	for i, transactionDTO := range transactionDTOs {
-		// Validate DTO
-		validationErrors := s.transactionMapper.ValidatePostDTO(&transactionDTO)
-		if len(validationErrors) > 0 {
+		if duplicates[i] {
 			failed = append(failed, dto.TransactionErrorDTO{
 				Transaction: transactionDTO,
-				Errors:      validationErrors,
+				Errors:      []dto.ValidationError{duplicateSourceIDError(transactionDTO)},
+				BatchIndex:  intPtr(i),
 			})
 			continue
 		}
 
-		// Convert DTO to domain model
-		domainTransaction, err := s.transactionMapper.FromPostDTO(&transactionDTO)
-		if err != nil {
+		if validated[i].stored != nil {
+			resubmitted = append(resubmitted, mappers.IndexedTransaction{Index: i, Transaction: validated[i].stored})
+			continue
+		}
+
+		if len(validated[i].errors) > 0 {
 			failed = append(failed, dto.TransactionErrorDTO{
 				Transaction: transactionDTO,
-				Errors: []dto.ValidationError{{
-					Field:   "transaction",
-					Message: err.Error(),
-					Value:   fmt.Sprintf("index_%d", i),
-				}},
+				Errors:      validated[i].errors,
+				BatchIndex:  intPtr(i),
 			})
 			continue
 		}
 
-		// Validate business rules
-		validationResult := s.validator.ValidateTransaction(ctx, domainTransaction)
-		if !validationResult.IsValid() {
-			var errors []dto.ValidationError
-			for _, validationError := range validationResult.Errors {
-				errors = append(errors, dto.ValidationError{
-					Field:   validationError.Field,
-					Message: validationError.Message,
-					Value:   fmt.Sprintf("%v", validationError.Value),
-				})
+		if unprocessed > 0 || time.Now().After(deadline) {
+			unprocessed++
+			failed = append(failed, batchDeadlineExceededError(i, transactionDTO))
+			continue
+		}
+
+		// Earlier transactions of the batch are stored by now, so the daily count includes them
+		transaction, err := s.createTransaction(ctx, i, transactionDTO, validated[i].transaction)
+		if err != nil {
+			if stored := s.storedDuplicateOnCreate(ctx, err, validated[i].transaction); stored != nil {
+				resubmitted = append(resubmitted, mappers.IndexedTransaction{Index: i, Transaction: stored})
+				continue
 			}
-			failed = append(failed, dto.TransactionErrorDTO{
-				Transaction: transactionDTO,
-				Errors:      errors,
+			if s.config.AbortOnInfrastructureError && isInfrastructureError(err) {
+				return nil, s.abortBatch(ctx, i, len(transactionDTOs), created, err)
+			}
+			if isDailyLimitExceeded(err) {
+				limited++
+			}
+			failed = append(failed, createFailure(i, transactionDTO, err))
+			continue
+		}
+		created = append(created, transaction)
+	}
+
+	successful, processingFailed := s.processCreated(ctx, created)
+	successful = append(successful, resubmitted...)
+	failed = append(failed, processingFailed...)
+
+	s.logger.Info("Batch transaction creation and processing completed",
+		logger.Int("successful", len(successful)),
+		logger.Int("failed", len(failed)),
+		logger.Int("total", len(transactionDTOs)))
+
+	response := s.batchResponse(successful, failed, unprocessed)
+	response.Summary.DailyLimitExceeded = limited
+	addBatchTiming(options, start, response)
+	return response, nil
+}
+
+// CreateTransactionsStrict validates every transaction in the batch before creating any. If a
+// transaction fails validation nothing is created and a *BatchValidationError lists the errors
+// of all failing transactions. Otherwise the batch is created and processed like
+// CreateTransactions; failures after validation, such as processing errors, are still reported
+// per transaction. With a UnitOfWork the batch is instead created and processed in one database
+// transaction that must finish by the processing timeout, see createStrictBatch.
+func (s *transactionService) CreateTransactionsStrict(ctx context.Context, transactionDTOs []dto.TransactionPostDTO, options BatchOptions) (*dto.TransactionBatchResponse, error) {
+	s.logger.Info("Creating strict batch of transactions",
+		logger.Int("count", len(transactionDTOs)))
+
+	start := time.Now()
+	deadline := s.batchDeadline()
+	var invalid []dto.IndexedTransactionErrorDTO
+	limited := 0
+	duplicates := duplicateSourceIDs(transactionDTOs)
+	validated := s.validateBatch(ctx, transactionDTOs, duplicates)
+	bookings := make(dailyBookings)
+	for i := range transactionDTOs {
+		if duplicates[i] {
+			invalid = append(invalid, dto.IndexedTransactionErrorDTO{
+				Index: i,
+				TransactionErrorDTO: dto.TransactionErrorDTO{
+					Transaction: transactionDTOs[i],
+					Errors:      []dto.ValidationError{duplicateSourceIDError(transactionDTOs[i])},
+					BatchIndex:  intPtr(i),
+				},
 			})
 			continue
 		}
 
-		// Convert domain transaction to repository transaction
-		repoTransaction := s.convertDomainToRepo(domainTransaction)
+		if validated[i].stored != nil {
+			continue
+		}
+
+		if len(validated[i].errors) > 0 {
+			invalid = append(invalid, dto.IndexedTransactionErrorDTO{
+				Index: i,
+				TransactionErrorDTO: dto.TransactionErrorDTO{
+					Transaction: transactionDTOs[i],
+					Errors:      validated[i].errors,
+					BatchIndex:  intPtr(i),
+				},
+			})
+			continue
+		}
+
+		// Nothing is stored before every transaction is checked, and every valid one will be, so
+		// the valid transactions before it count toward its daily limit
+		pending := bookings[bookings.key(validated[i].transaction)]
+		if err := s.validator.CheckDailyLimit(ctx, validated[i].transaction, pending); err != nil {
+			limited++
+			invalid = append(invalid, dto.IndexedTransactionErrorDTO{Index: i, TransactionErrorDTO: createFailure(i, transactionDTOs[i], err)})
+			continue
+		}
+		bookings.add(validated[i].transaction)
+	}
+
+	if len(invalid) > 0 {
+		s.logger.Warn("Strict batch rejected",
+			logger.Int("invalid", len(invalid)),
+			logger.Int("dailyLimitExceeded", limited),
+			logger.Int("total", len(transactionDTOs)))
+		return nil, &BatchValidationError{Total: len(transactionDTOs), Failed: invalid, DailyLimitExceeded: limited}
+	}
+
+	if s.config.UnitOfWork != nil {
+		return s.createStrictBatch(ctx, start, deadline, transactionDTOs, validated, options)
+	}
+
+	// The count above did not lock anything, so a concurrent request may still take the last
+	// transactions of a day; creating checks the limit again
+	var created []*createdTransaction
+	var resubmitted []mappers.IndexedTransaction
+	var failed []dto.TransactionErrorDTO
+	unprocessed := 0
+	limited = 0
+	for i, transactionDTO := range transactionDTOs {
+		if validated[i].stored != nil {
+			resubmitted = append(resubmitted, mappers.IndexedTransaction{Index: i, Transaction: validated[i].stored})
+			continue
+		}
+
+		if unprocessed > 0 || time.Now().After(deadline) {
+			unprocessed++
+			failed = append(failed, batchDeadlineExceededError(i, transactionDTO))
+			continue
+		}
+
+		transaction, err := s.createTransaction(ctx, i, transactionDTO, validated[i].transaction)
+		if err != nil {
+			if stored := s.storedDuplicateOnCreate(ctx, err, validated[i].transaction); stored != nil {
+				resubmitted = append(resubmitted, mappers.IndexedTransaction{Index: i, Transaction: stored})
+				continue
+			}
+			if s.config.AbortOnInfrastructureError && isInfrastructureError(err) {
+				return nil, s.abortBatch(ctx, i, len(transactionDTOs), created, err)
+			}
+			if isDailyLimitExceeded(err) {
+				limited++
+			}
+			failed = append(failed, createFailure(i, transactionDTO, err))
+			continue
+		}
+		created = append(created, transaction)
+	}
+
+	successful, processingFailed := s.processCreated(ctx, created)
+	successful = append(successful, resubmitted...)
+	failed = append(failed, processingFailed...)
+
+	s.logger.Info("Strict batch transaction creation and processing completed",
+		logger.Int("successful", len(successful)),
+		logger.Int("failed", len(failed)),
+		logger.Int("total", len(transactionDTOs)))
+
+	response := s.batchResponse(successful, failed, unprocessed)
+	response.Summary.DailyLimitExceeded = limited
+	addBatchTiming(options, start, response)
+	return response, nil
+}
+
+// errStrictBatchFailed rolls back the unit of work of a strict batch in which a transaction failed
+var errStrictBatchFailed = errors.New("strict batch transaction failed")
+
+// createStrictBatch creates and processes a validated strict batch in the UnitOfWork, with a
+// context that expires at deadline. A transaction that fails to be created or processed rolls
+// the whole batch back and is reported in a *BatchValidationError, or with
+// AbortOnInfrastructureError a database failure in a *BatchAbortedError. Running past the
+// deadline rolls it back too; every transaction not already stored is then returned unprocessed.
+func (s *transactionService) createStrictBatch(ctx context.Context, start, deadline time.Time, transactionDTOs []dto.TransactionPostDTO, validated []validatedTransaction, options BatchOptions) (*dto.TransactionBatchResponse, error) {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	var successful []mappers.IndexedTransaction
+	var failed []dto.TransactionErrorDTO
+	limited := 0
+	err := s.config.UnitOfWork.Atomically(ctx, func(ctx context.Context) error {
+		var created []*createdTransaction
+		for i, transactionDTO := range transactionDTOs {
+			if validated[i].stored != nil {
+				successful = append(successful, mappers.IndexedTransaction{Index: i, Transaction: validated[i].stored})
+				continue
+			}
+
+			transaction, err := s.createTransaction(ctx, i, transactionDTO, validated[i].transaction)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if s.config.AbortOnInfrastructureError && isInfrastructureError(err) {
+					return &BatchAbortedError{Index: i, Total: len(transactionDTOs), Cause: err}
+				}
+				if isDailyLimitExceeded(err) {
+					limited++
+				}
+				failed = append(failed, createFailure(i, transactionDTO, err))
+				return errStrictBatchFailed
+			}
+			created = append(created, transaction)
+		}
+
+		processed, processingFailed := s.processCreated(ctx, created)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if len(processingFailed) > 0 {
+			failed = append(failed, processingFailed...)
+			return errStrictBatchFailed
+		}
+		successful = append(successful, processed...)
+		return nil
+	})
+
+	var abortedErr *BatchAbortedError
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil:
+		// Nothing the batch created is left, so everything but the stored transactions is unprocessed
+		successful, failed = nil, nil
+		for i, transactionDTO := range transactionDTOs {
+			if validated[i].stored != nil {
+				successful = append(successful, mappers.IndexedTransaction{Index: i, Transaction: validated[i].stored})
+				continue
+			}
+			failed = append(failed, batchDeadlineExceededError(i, transactionDTO))
+		}
+		response := s.batchResponse(successful, failed, len(failed))
+		addBatchTiming(options, start, response)
+		return response, nil
+	case errors.As(err, &abortedErr):
+		s.logger.Error("Strict batch rolled back on infrastructure error",
+			logger.Int("index", abortedErr.Index),
+			logger.Int("total", abortedErr.Total),
+			logger.Err(abortedErr.Cause))
+		return nil, abortedErr
+	case errors.Is(err, errStrictBatchFailed):
+		invalid := make([]dto.IndexedTransactionErrorDTO, len(failed))
+		for k, failure := range failed {
+			invalid[k] = dto.IndexedTransactionErrorDTO{Index: *failure.BatchIndex, TransactionErrorDTO: failure}
+		}
+		s.logger.Warn("Strict batch rolled back",
+			logger.Int("failed", len(invalid)),
+			logger.Int("dailyLimitExceeded", limited),
+			logger.Int("total", len(transactionDTOs)))
+		return nil, &BatchValidationError{Total: len(transactionDTOs), Failed: invalid, DailyLimitExceeded: limited}
+	default:
+		s.logger.Error("Failed to create strict batch", logger.Err(err))
+		return nil, fmt.Errorf("failed to create strict batch: %w", err)
+	}
+
+	s.logger.Info("Strict batch transaction creation and processing completed",
+		logger.Int("successful", len(successful)),
+		logger.Int("total", len(transactionDTOs)))
+
+	response := s.batchResponse(successful, nil, 0)
+	addBatchTiming(options, start, response)
+	return response, nil
+}
+
+// abortBatch stops a batch at transaction i after an infrastructure error. The transactions
+// created before it are still processed so they do not stay NEW.
+func (s *transactionService) abortBatch(ctx context.Context, i, total int, created []*createdTransaction, cause error) *BatchAbortedError {
+	s.logger.Error("Batch aborted on infrastructure error",
+		logger.Int("index", i),
+		logger.Int("created", len(created)),
+		logger.Int("total", total),
+		logger.Err(cause))
+
+	if len(created) > 0 {
+		_, processingFailed := s.processCreated(ctx, created)
+		if len(processingFailed) > 0 {
+			s.logger.Warn("Transactions created before the batch aborted failed processing",
+				logger.Int("failed", len(processingFailed)))
+		}
+	}
+
+	return &BatchAbortedError{Index: i, Created: len(created), Total: total, Cause: cause}
+}
+
+// isInfrastructureError reports whether creating a transaction failed because of the database
+// rather than the transaction, so the next transactions would most likely fail the same way
+func isInfrastructureError(err error) bool {
+	return repositories.IsConnectionError(err) ||
+		repositories.IsTransactionError(err) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// batchDeadline returns the time after which a batch received now stops creating transactions
+func (s *transactionService) batchDeadline() time.Time {
+	return time.Now().Add(s.config.ProcessingTimeout)
+}
+
+// batchResponse builds the response of a created batch, flagging the transactions left
+// unprocessed when the batch ran past its deadline
+func (s *transactionService) batchResponse(successful []mappers.IndexedTransaction, failed []dto.TransactionErrorDTO, unprocessed int) *dto.TransactionBatchResponse {
+	batchResponse := s.transactionMapper.ToIndexedBatchResponse(successful, failed)
+	if unprocessed > 0 {
+		s.logger.Warn("Batch processing deadline exceeded",
+			logger.String("timeout", s.config.ProcessingTimeout.String()),
+			logger.Int("processed", batchResponse.Summary.TotalRequested-unprocessed),
+			logger.Int("unprocessed", unprocessed))
+		batchResponse.Summary.DeadlineExceeded = true
+		batchResponse.Summary.Unprocessed = unprocessed
+	}
+	return &batchResponse
+}
+
+// batchDeadlineExceededError is the failure of a transaction that was not created because its
+// batch ran past the processing timeout. Nothing was written for it, so it can be resubmitted.
+func batchDeadlineExceededError(i int, transactionDTO dto.TransactionPostDTO) dto.TransactionErrorDTO {
+	return dto.TransactionErrorDTO{
+		Transaction: transactionDTO,
+		Errors: []dto.ValidationError{{
+			Field:   "batch",
+			Message: "deadline exceeded; transaction was not processed",
+			Value:   fmt.Sprintf("index_%d", i),
+		}},
+		BatchIndex: intPtr(i),
+	}
+}
+
+// duplicateSourceIDs returns the indexes of the transactions whose source ID appears more than
+// once in the batch. Every record of a duplicate group is included, since there is no telling
+// which of them the caller meant. Empty source IDs are left to field validation.
+func duplicateSourceIDs(transactionDTOs []dto.TransactionPostDTO) map[int]bool {
+	indexes := make(map[string][]int, len(transactionDTOs))
+	for i, transactionDTO := range transactionDTOs {
+		if transactionDTO.SourceID == "" {
+			continue
+		}
+		indexes[transactionDTO.SourceID] = append(indexes[transactionDTO.SourceID], i)
+	}
+
+	duplicates := make(map[int]bool)
+	for _, group := range indexes {
+		if len(group) < 2 {
+			continue
+		}
+		for _, i := range group {
+			duplicates[i] = true
+		}
+	}
+	return duplicates
+}
+
+// duplicateSourceIDError is the validation error of a transaction whose source ID is repeated
+// within its batch
+func duplicateSourceIDError(transactionDTO dto.TransactionPostDTO) dto.ValidationError {
+	return dto.ValidationError{
+		Field:   "sourceId",
+		Message: "duplicate source_id within batch",
+		Value:   transactionDTO.SourceID,
+	}
+}
+
+// dailyBookingKey identifies the portfolio and transaction date a daily limit applies to
+type dailyBookingKey struct {
+	portfolioID     string
+	transactionDate string
+}
+
+// dailyBookings counts the valid transactions of a strict batch by portfolio and transaction
+// date, since they are checked against the daily limit before any of them is stored
+type dailyBookings map[dailyBookingKey]int
+
+// key returns the portfolio and transaction date of a transaction
+func (b dailyBookings) key(transaction *models.Transaction) dailyBookingKey {
+	return dailyBookingKey{
+		portfolioID:     transaction.PortfolioID().String(),
+		transactionDate: transaction.TransactionDate().Format("20060102"),
+	}
+}
+
+// add counts a transaction
+func (b dailyBookings) add(transaction *models.Transaction) {
+	b[b.key(transaction)]++
+}
+
+// isDailyLimitExceeded reports whether a transaction was rejected by its portfolio's daily
+// transaction limit
+func isDailyLimitExceeded(err error) bool {
+	var limitErr *services.DailyLimitExceededError
+	return errors.As(err, &limitErr)
+}
+
+// createFailure returns the error entry of a batch transaction that could not be created. A
+// transaction beyond its portfolio's daily limit fails on its transaction date.
+func createFailure(i int, transactionDTO dto.TransactionPostDTO, err error) dto.TransactionErrorDTO {
+	if isDailyLimitExceeded(err) {
+		return dto.TransactionErrorDTO{
+			Transaction: transactionDTO,
+			Errors: []dto.ValidationError{{
+				Field:   "transactionDate",
+				Message: err.Error(),
+				Value:   transactionDTO.TransactionDate,
+			}},
+			BatchIndex: intPtr(i),
+		}
+	}
+	return dto.TransactionErrorDTO{
+		Transaction: transactionDTO,
+		Errors: []dto.ValidationError{{
+			Field:   "repository",
+			Message: err.Error(),
+			Value:   fmt.Sprintf("index_%d", i),
+		}},
+		BatchIndex: intPtr(i),
+	}
+}
+
+// validatedTransaction is the outcome of validating one transaction of a batch: the domain
+// transaction, or the validation errors when it is invalid
+type validatedTransaction struct {
+	transaction *models.Transaction
+	errors      []dto.ValidationError
+	// stored is the transaction already stored for the source ID with the same booking, e.g. by
+	// an earlier attempt of a batch that was aborted; it is reported as created, not created again
+	stored *models.Transaction
+}
+
+// validateBatch validates the transactions of a batch, except the skipped ones, up to
+// ValidationConcurrency at a time. Validation reads but never writes, so transactions of the
+// same portfolio can be validated in parallel; each result is stored at its batch index.
+func (s *transactionService) validateBatch(ctx context.Context, transactionDTOs []dto.TransactionPostDTO, skip map[int]bool) []validatedTransaction {
+	results := make([]validatedTransaction, len(transactionDTOs))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, s.config.ValidationConcurrency)
+	for i := range transactionDTOs {
+		if skip[i] {
+			continue
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			results[i] = s.validateForCreate(ctx, i, &transactionDTOs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// validateForCreate checks a transaction's fields and business rules and converts it to the
+// domain model. It returns the validation errors when the transaction is invalid, or the stored
+// transaction when its only error is a source ID already stored with the same booking.
+func (s *transactionService) validateForCreate(ctx context.Context, i int, transactionDTO *dto.TransactionPostDTO) validatedTransaction {
+	// Validate DTO
+	if validationErrors := s.transactionMapper.ValidatePostDTO(transactionDTO); len(validationErrors) > 0 {
+		return validatedTransaction{errors: validationErrors}
+	}
+
+	// Convert DTO to domain model
+	domainTransaction, err := s.transactionMapper.FromPostDTO(transactionDTO)
+	if err != nil {
+		return validatedTransaction{errors: []dto.ValidationError{{
+			Field:   "transaction",
+			Message: err.Error(),
+			Value:   fmt.Sprintf("index_%d", i),
+		}}}
+	}
+
+	// Validate business rules
+	validationResult := s.validator.ValidateTransaction(ctx, domainTransaction)
+	if !validationResult.IsValid() {
+		if onlyDuplicateSourceID(validationResult.Errors) {
+			if stored := s.storedDuplicate(ctx, domainTransaction); stored != nil {
+				return validatedTransaction{stored: stored}
+			}
+		}
+
+		var errors []dto.ValidationError
+		for _, validationError := range validationResult.Errors {
+			errors = append(errors, dto.ValidationError{
+				Field:   validationError.Field,
+				Message: validationError.Message,
+				Value:   fmt.Sprintf("%v", validationError.Value),
+			})
+		}
+		return validatedTransaction{errors: errors}
+	}
+
+	// A transaction with unknown references under the quarantine policy is stored but not processed
+	if validationResult.Quarantine {
+		domainTransaction = domainTransaction.SetStatus(models.TransactionStatusQuarantined, nil)
+	}
+
+	return validatedTransaction{transaction: domainTransaction}
+}
+
+// onlyDuplicateSourceID reports whether every validation error is a source ID already stored
+func onlyDuplicateSourceID(validationErrors []services.ValidationError) bool {
+	for _, validationError := range validationErrors {
+		if validationError.Code != "DUPLICATE_SOURCE_ID" {
+			return false
+		}
+	}
+	return len(validationErrors) > 0
+}
+
+// storedDuplicate returns the stored transaction with the source ID of a submitted one when both
+// record the same booking, so a client resubmitting a batch, e.g. after it was aborted, gets the
+// transactions created by the earlier attempt back instead of duplicate source ID errors. It
+// returns nil when the source ID is stored with a different booking or cannot be looked up.
+func (s *transactionService) storedDuplicate(ctx context.Context, submitted *models.Transaction) *models.Transaction {
+	stored, err := s.transactionRepo.GetBySourceID(ctx, submitted.SourceID().String())
+	if err != nil {
+		if !repositories.IsNotFoundError(err) {
+			s.logger.Warn("Failed to look up stored transaction of duplicate source ID",
+				logger.String("sourceId", submitted.SourceID().String()),
+				logger.Err(err))
+		}
+		return nil
+	}
+	if !sameBooking(stored, s.convertDomainToRepo(submitted)) {
+		return nil
+	}
+
+	s.logger.Info("Resubmitted transaction already stored",
+		logger.Int64("transactionId", stored.ID),
+		logger.String("sourceId", stored.SourceID),
+		logger.String("status", stored.Status))
+	return s.convertRepoToDomain(stored)
+}
+
+// storedDuplicateOnCreate returns the stored transaction when creating a transaction failed
+// because a concurrent request stored the same booking under its source ID first
+func (s *transactionService) storedDuplicateOnCreate(ctx context.Context, err error, submitted *models.Transaction) *models.Transaction {
+	if !repositories.IsDuplicateKeyError(err) || repositories.IsBalanceExistsError(err) {
+		return nil
+	}
+	return s.storedDuplicate(ctx, submitted)
+}
+
+// sameBooking reports whether two transactions book the same quantity and price of the same
+// security into the same portfolio on the same dates
+func sameBooking(a, b *repositories.Transaction) bool {
+	return a.PortfolioID == b.PortfolioID &&
+		stringPtrValue(a.SecurityID) == stringPtrValue(b.SecurityID) &&
+		a.TransactionType == b.TransactionType &&
+		a.Quantity.Equal(b.Quantity) &&
+		a.Price.Equal(b.Price) &&
+		sameDate(&a.TransactionDate, &b.TransactionDate) &&
+		sameDate(a.SettlementDate, b.SettlementDate)
+}
+
+// stringPtrValue returns the string an optional string points to, or "" when it is absent
+func stringPtrValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// sameDate reports whether two optional dates are both absent or fall on the same day
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Format("20060102") == b.Format("20060102")
+}
+
+// createdTransaction is a transaction of a batch stored with status NEW and awaiting processing
+type createdTransaction struct {
+	index          int
+	transactionDTO dto.TransactionPostDTO
+	transaction    *models.Transaction
+}
+
+// createTransaction stores a validated transaction of a batch with status NEW and returns it
+// with its ID. Callers turn an error into the transaction's entry with createFailure, or stop the
+// batch on an infrastructure error with AbortOnInfrastructureError.
+func (s *transactionService) createTransaction(ctx context.Context, i int, transactionDTO dto.TransactionPostDTO, domainTransaction *models.Transaction) (*createdTransaction, error) {
+	// Convert domain transaction to repository transaction
+	repoTransaction := s.convertDomainToRepo(domainTransaction)
+
+	// Create transaction in repository with status NEW
+	if err := s.storeTransaction(ctx, repoTransaction); err != nil {
+		return nil, err
+	}
+
+	// Convert back to domain transaction with ID for processing
+	return &createdTransaction{
+		index:          i,
+		transactionDTO: transactionDTO,
+		transaction:    s.convertRepoToDomain(repoTransaction),
+	}, nil
+}
+
+// storeTransaction creates a transaction. When its portfolio has a daily transaction limit the
+// repository counts and inserts under one lock, so concurrent requests cannot exceed the limit
+// together; a transaction beyond it fails with a *services.DailyLimitExceededError.
+func (s *transactionService) storeTransaction(ctx context.Context, repoTransaction *repositories.Transaction) error {
+	limit := s.validator.DailyTransactionLimit(repoTransaction.PortfolioID)
+	if limit <= 0 {
+		return s.transactionRepo.Create(ctx, repoTransaction)
+	}
+
+	err := s.transactionRepo.CreateWithinDailyLimit(ctx, repoTransaction, limit)
+	if repositories.IsDailyLimitReachedError(err) {
+		s.logger.Warn("Portfolio daily transaction limit reached",
+			logger.String("portfolioId", repoTransaction.PortfolioID),
+			logger.String("transactionDate", repoTransaction.TransactionDate.Format("20060102")),
+			logger.Int("limit", limit))
+		return &services.DailyLimitExceededError{
+			PortfolioID:     repoTransaction.PortfolioID,
+			TransactionDate: repoTransaction.TransactionDate,
+			Limit:           limit,
+		}
+	}
+	return err
+}
+
+// processCreated processes the created transactions of a batch into the balances in batch order.
+// They share one balance batch, so a balance touched by many of them is read and written once.
+// Quarantined transactions are returned as stored. It returns the processed transactions and
+// the error entries of those that failed.
+func (s *transactionService) processCreated(ctx context.Context, created []*createdTransaction) ([]mappers.IndexedTransaction, []dto.TransactionErrorDTO) {
+	var successful []mappers.IndexedTransaction
+	unprocessed := created[:0:0]
+	for _, c := range created {
+		if c.transaction.Status() == models.TransactionStatusQuarantined {
+			s.logger.Warn("Transaction quarantined for unknown references",
+				logger.Int64("transactionId", c.transaction.ID()),
+				logger.String("sourceId", c.transactionDTO.SourceID))
+			successful = append(successful, mappers.IndexedTransaction{Index: c.index, Transaction: c.transaction})
+			continue
+		}
+		unprocessed = append(unprocessed, c)
+	}
+	created = unprocessed
+	if len(created) == 0 {
+		return successful, nil
+	}
+
+	batch := s.transactionProcessor.NewBalanceBatch()
+	results := make([]*services.ProcessingResult, len(created))
+	errs := make([]error, len(created))
+	for k, c := range created {
+		results[k], errs[k] = batch.ProcessTransaction(ctx, c.transaction)
+	}
+	if err := batch.Commit(ctx); err != nil {
+		s.logger.Error("Failed to write batch balances", logger.Err(err))
+	}
+
+	var failed []dto.TransactionErrorDTO
+	for k, c := range created {
+		processed, processErr := s.processedTransaction(ctx, c, results[k], errs[k])
+		if processErr != nil {
+			failed = append(failed, *processErr)
+			continue
+		}
+		successful = append(successful, mappers.IndexedTransaction{Index: c.index, Transaction: processed})
+	}
+	return successful, failed
+}
+
+// processedTransaction returns a created transaction as stored after processing, or the error
+// entry for the batch response when processing failed
+func (s *transactionService) processedTransaction(ctx context.Context, c *createdTransaction, processingResult *services.ProcessingResult, err error) (*models.Transaction, *dto.TransactionErrorDTO) {
+	i, transactionDTO := c.index, c.transactionDTO
+	transactionID := c.transaction.ID()
+
+	if err != nil {
+		s.logger.Error("Failed to process transaction after creation",
+			logger.Err(err),
+			logger.Int64("transactionId", transactionID),
+			logger.String("sourceId", transactionDTO.SourceID))
+
+		// Transaction was created but processing failed - mark as ERROR
+		return nil, &dto.TransactionErrorDTO{
+			Transaction: transactionDTO,
+			Errors: []dto.ValidationError{{
+				Field:   "processing",
+				Message: fmt.Sprintf("balance processing failed: %v", err),
+				Value:   fmt.Sprintf("index_%d", i),
+			}},
+			BatchIndex: intPtr(i),
+		}
+	}
+
+	// Check if processing was successful
+	if processingResult != nil && !processingResult.Success {
+		s.logger.Warn("Transaction processing failed after creation",
+			logger.Int64("transactionId", transactionID),
+			logger.String("sourceId", transactionDTO.SourceID),
+			logger.String("error", processingResult.ErrorMessage))
+
+		return nil, &dto.TransactionErrorDTO{
+			Transaction: transactionDTO,
+			Errors: []dto.ValidationError{{
+				Field:   "processing",
+				Message: processingResult.ErrorMessage,
+				Value:   fmt.Sprintf("index_%d", i),
+			}},
+			BatchIndex: intPtr(i),
+		}
+	}
+
+	s.logger.Info("Transaction created and processed successfully",
+		logger.Int64("transactionId", transactionID),
+		logger.String("sourceId", transactionDTO.SourceID),
+		logger.String("status", "PROC"))
+
+	// Get the updated transaction with PROC status
+	updatedRepoTransaction, err := s.transactionRepo.GetByID(ctx, transactionID)
+	if err != nil {
+		s.logger.Error("Failed to retrieve processed transaction",
+			logger.Err(err),
+			logger.Int64("transactionId", transactionID))
+		// Continue with original transaction even if we can't retrieve updated version
+		return c.transaction, nil
+	}
+
+	// Use the updated transaction with PROC status
+	return s.convertRepoToDomain(updatedRepoTransaction), nil
+}
+
+// GetTransaction retrieves a transaction by ID
+func (s *transactionService) GetTransaction(ctx context.Context, id int64) (*dto.TransactionResponseDTO, error) {
+	s.logger.Debug("Retrieving transaction",
+		logger.Int64("transactionId", id))
+
+	repoTransaction, err := s.transactionRepo.GetByID(ctx, id)
+	if err != nil {
+		if repositories.IsNotFoundError(err) {
+			s.logger.Warn("Transaction not found",
+				logger.Int64("transactionId", id))
+			return nil, fmt.Errorf("transaction not found: %d", id)
+		}
+		s.logger.Error("Failed to retrieve transaction",
+			logger.Err(err),
+			logger.Int64("transactionId", id))
+		return nil, fmt.Errorf("failed to retrieve transaction: %w", err)
+	}
+
+	domainTransaction := s.convertRepoToDomain(repoTransaction)
+	return s.transactionMapper.ToResponseDTO(domainTransaction), nil
+}
+
+// GetTransactionsByIDs retrieves several transactions with one query. Repeated IDs are fetched
+// once; found transactions and missing IDs are both returned in the order first requested.
+func (s *transactionService) GetTransactionsByIDs(ctx context.Context, ids []int64) (*dto.TransactionBatchGetResponse, error) {
+	uniqueIDs := make([]int64, 0, len(ids))
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			uniqueIDs = append(uniqueIDs, id)
+		}
+	}
+	if len(uniqueIDs) > s.config.MaxBatchGetIDs {
+		return nil, &BatchGetTooLargeError{Requested: len(uniqueIDs), Max: s.config.MaxBatchGetIDs}
+	}
+
+	s.logger.Debug("Retrieving transactions by ID",
+		logger.Int("requestedTransactions", len(uniqueIDs)))
+
+	response := &dto.TransactionBatchGetResponse{
+		Transactions: []dto.TransactionResponseDTO{},
+		NotFoundIDs:  []int64{},
+	}
+	if len(uniqueIDs) == 0 {
+		return response, nil
+	}
+
+	repoTransactions, err := s.transactionRepo.List(ctx, repositories.TransactionFilter{
+		IDs:   uniqueIDs,
+		Limit: len(uniqueIDs),
+	})
+	if err != nil {
+		logQueryError(s.logger, "Failed to retrieve transactions by ID", err)
+		return nil, fmt.Errorf("failed to retrieve transactions: %w", err)
+	}
+
+	byID := make(map[int64]*repositories.Transaction, len(repoTransactions))
+	for _, repoTransaction := range repoTransactions {
+		byID[repoTransaction.ID] = repoTransaction
+	}
+	for _, id := range uniqueIDs {
+		repoTransaction, ok := byID[id]
+		if !ok {
+			response.NotFoundIDs = append(response.NotFoundIDs, id)
+			continue
+		}
+		domainTransaction := s.convertRepoToDomain(repoTransaction)
+		response.Transactions = append(response.Transactions, *s.transactionMapper.ToResponseDTO(domainTransaction))
+	}
+
+	return response, nil
+}
+
+// GetTransactionsBySourceIDs retrieves several transactions by source ID with one query. Repeated
+// source IDs are fetched once and count once against MaxBatchGetIDs; found transactions and
+// missing source IDs are both returned in the order first requested.
+func (s *transactionService) GetTransactionsBySourceIDs(ctx context.Context, sourceIDs []string) (*dto.TransactionSourceBatchGetResponse, error) {
+	uniqueIDs := make([]string, 0, len(sourceIDs))
+	seen := make(map[string]bool, len(sourceIDs))
+	for _, sourceID := range sourceIDs {
+		if !seen[sourceID] {
+			seen[sourceID] = true
+			uniqueIDs = append(uniqueIDs, sourceID)
+		}
+	}
+	if len(uniqueIDs) > s.config.MaxBatchGetIDs {
+		return nil, &BatchGetTooLargeError{Requested: len(uniqueIDs), Max: s.config.MaxBatchGetIDs}
+	}
+
+	s.logger.Debug("Retrieving transactions by source ID",
+		logger.Int("requestedTransactions", len(uniqueIDs)))
+
+	response := &dto.TransactionSourceBatchGetResponse{
+		Transactions:      []dto.TransactionResponseDTO{},
+		NotFoundSourceIDs: []string{},
+	}
+	if len(uniqueIDs) == 0 {
+		return response, nil
+	}
+
+	repoTransactions, err := s.transactionRepo.GetBySourceIDs(ctx, uniqueIDs)
+	if err != nil {
+		logQueryError(s.logger, "Failed to retrieve transactions by source ID", err)
+		return nil, fmt.Errorf("failed to retrieve transactions: %w", err)
+	}
+
+	bySourceID := make(map[string]*repositories.Transaction, len(repoTransactions))
+	for _, repoTransaction := range repoTransactions {
+		bySourceID[repoTransaction.SourceID] = repoTransaction
+	}
+	for _, sourceID := range uniqueIDs {
+		repoTransaction, ok := bySourceID[sourceID]
+		if !ok {
+			response.NotFoundSourceIDs = append(response.NotFoundSourceIDs, sourceID)
+			continue
+		}
+		domainTransaction := s.convertRepoToDomain(repoTransaction)
+		response.Transactions = append(response.Transactions, *s.transactionMapper.ToResponseDTO(domainTransaction))
+	}
+
+	return response, nil
+}
 
-		// Create transaction in repository with status NEW
-		err = s.transactionRepo.Create(ctx, repoTransaction)
-		if err != nil {
-			failed = append(failed, dto.TransactionErrorDTO{
-				Transaction: transactionDTO,
-				Errors: []dto.ValidationError{{
-					Field:   "repository",
-					Message: err.Error(),
-					Value:   fmt.Sprintf("index_%d", i),
-				}},
-			})
-			continue
+// GetLatestTransactions retrieves the most recently created transaction of each portfolio with
+// one query. Repeated portfolio IDs are fetched once and count once against MaxBatchGetIDs.
+func (s *transactionService) GetLatestTransactions(ctx context.Context, portfolioIDs []string) (*dto.LatestTransactionsResponse, error) {
+	uniqueIDs := make([]string, 0, len(portfolioIDs))
+	seen := make(map[string]bool, len(portfolioIDs))
+	for _, portfolioID := range portfolioIDs {
+		if !seen[portfolioID] {
+			seen[portfolioID] = true
+			uniqueIDs = append(uniqueIDs, portfolioID)
 		}
+	}
+	if len(uniqueIDs) > s.config.MaxBatchGetIDs {
+		return nil, &BatchGetTooLargeError{Requested: len(uniqueIDs), Max: s.config.MaxBatchGetIDs}
+	}
 
-		// Convert back to domain transaction with ID for processing
-		domainTransactionWithID := s.convertRepoToDomain(repoTransaction)
+	s.logger.Debug("Retrieving latest transaction per portfolio",
+		logger.Int("requestedPortfolios", len(uniqueIDs)))
 
-		// STEP 2: Process transaction to update balances and set status to PROC
-		// This implements the required business workflow from requirements
-		processingResult, err := s.transactionProcessor.ProcessTransaction(ctx, domainTransactionWithID)
-		if err != nil {
-			s.logger.Error("Failed to process transaction after creation",
-				logger.Err(err),
-				logger.Int64("transactionId", repoTransaction.ID),
-				logger.String("sourceId", transactionDTO.SourceID))
+	response := &dto.LatestTransactionsResponse{
+		Transactions:                  []dto.TransactionResponseDTO{},
+		PortfoliosWithoutTransactions: []string{},
+	}
+	if len(uniqueIDs) == 0 {
+		return response, nil
+	}
 
-			// Transaction was created but processing failed - mark as ERROR
-			failed = append(failed, dto.TransactionErrorDTO{
-				Transaction: transactionDTO,
-				Errors: []dto.ValidationError{{
-					Field:   "processing",
-					Message: fmt.Sprintf("balance processing failed: %v", err),
-					Value:   fmt.Sprintf("index_%d", i),
-				}},
-			})
+	repoTransactions, err := s.transactionRepo.GetLatestTransactionPerPortfolio(ctx, uniqueIDs)
+	if err != nil {
+		logQueryError(s.logger, "Failed to retrieve latest transactions", err)
+		return nil, fmt.Errorf("failed to retrieve latest transactions: %w", err)
+	}
+
+	byPortfolio := make(map[string]*repositories.Transaction, len(repoTransactions))
+	for _, repoTransaction := range repoTransactions {
+		byPortfolio[repoTransaction.PortfolioID] = repoTransaction
+	}
+	for _, portfolioID := range uniqueIDs {
+		repoTransaction, ok := byPortfolio[portfolioID]
+		if !ok {
+			response.PortfoliosWithoutTransactions = append(response.PortfoliosWithoutTransactions, portfolioID)
 			continue
 		}
+		domainTransaction := s.convertRepoToDomain(repoTransaction)
+		response.Transactions = append(response.Transactions, *s.transactionMapper.ToResponseDTO(domainTransaction))
+	}
 
-		// Check if processing was successful
-		if processingResult != nil && !processingResult.Success {
-			s.logger.Warn("Transaction processing failed after creation",
-				logger.Int64("transactionId", repoTransaction.ID),
-				logger.String("sourceId", transactionDTO.SourceID),
-				logger.String("error", processingResult.ErrorMessage))
+	return response, nil
+}
 
-			failed = append(failed, dto.TransactionErrorDTO{
-				Transaction: transactionDTO,
-				Errors: []dto.ValidationError{{
-					Field:   "processing",
-					Message: processingResult.ErrorMessage,
-					Value:   fmt.Sprintf("index_%d", i),
-				}},
-			})
-			continue
-		}
+// GetTransactionHistory retrieves the audit history of a transaction
+func (s *transactionService) GetTransactionHistory(ctx context.Context, id int64) (*dto.TransactionHistoryResponse, error) {
+	s.logger.Debug("Retrieving transaction history",
+		logger.Int64("transactionId", id))
 
-		// Get the updated transaction with PROC status
-		updatedRepoTransaction, err := s.transactionRepo.GetByID(ctx, repoTransaction.ID)
-		if err != nil {
-			s.logger.Error("Failed to retrieve processed transaction",
-				logger.Err(err),
-				logger.Int64("transactionId", repoTransaction.ID))
-			// Continue with original transaction even if we can't retrieve updated version
-			successful = append(successful, domainTransactionWithID)
-		} else {
-			// Use the updated transaction with PROC status
-			successful = append(successful, s.convertRepoToDomain(updatedRepoTransaction))
+	if _, err := s.transactionRepo.GetByID(ctx, id); err != nil {
+		if repositories.IsNotFoundError(err) {
+			s.logger.Warn("Transaction not found",
+				logger.Int64("transactionId", id))
+			return nil, fmt.Errorf("transaction not found: %d", id)
 		}
+		return nil, fmt.Errorf("failed to retrieve transaction: %w", err)
+	}
 
-		s.logger.Info("Transaction created and processed successfully",
-			logger.Int64("transactionId", repoTransaction.ID),
-			logger.String("sourceId", transactionDTO.SourceID),
-			logger.String("status", "PROC"))
+	events, err := s.transactionRepo.GetHistory(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to retrieve transaction history",
+			logger.Err(err),
+			logger.Int64("transactionId", id))
+		return nil, fmt.Errorf("failed to retrieve transaction history: %w", err)
 	}
 
-	s.logger.Info("Batch transaction creation and processing completed",
-		logger.Int("successful", len(successful)),
-		logger.Int("failed", len(failed)),
-		logger.Int("total", len(transactionDTOs)))
+	response := &dto.TransactionHistoryResponse{
+		TransactionID: id,
+		Events:        make([]dto.TransactionEventDTO, 0, len(events)),
+	}
+	for _, event := range events {
+		response.Events = append(response.Events, dto.TransactionEventDTO{
+			ID:                   event.ID,
+			EventType:            string(event.EventType),
+			OldStatus:            event.OldStatus,
+			NewStatus:            event.NewStatus,
+			ReprocessingAttempts: event.ReprocessingAttempts,
+			ErrorMessage:         event.ErrorMessage,
+			ErrorRetryable:       event.ErrorRetryable,
+			Version:              event.Version,
+			Timestamp:            event.CreatedAt,
+		})
+	}
 
-	batchResponse := s.transactionMapper.ToBatchResponse(successful, failed)
-	return &batchResponse, nil
+	return response, nil
 }
 
-// GetTransaction retrieves a transaction by ID
-func (s *transactionService) GetTransaction(ctx context.Context, id int64) (*dto.TransactionResponseDTO, error) {
-	s.logger.Debug("Retrieving transaction",
-		logger.Int64("transactionId", id))
+// GetTransactionBalanceImpact computes how a transaction changes its portfolio's balances. The
+// state "processing" (the default when empty) builds on the balances at the time the
+// transaction was processed; "current" builds on the stored balances.
+func (s *transactionService) GetTransactionBalanceImpact(ctx context.Context, id int64, state string) (*dto.TransactionBalanceImpactDTO, error) {
+	impactState := services.ImpactState(state)
+	if state == "" {
+		impactState = services.ImpactStateProcessing
+	}
+	if !impactState.IsValid() {
+		return nil, &InvalidImpactStateError{State: state}
+	}
+
+	s.logger.Debug("Computing transaction balance impact",
+		logger.Int64("transactionId", id),
+		logger.String("state", string(impactState)))
 
 	repoTransaction, err := s.transactionRepo.GetByID(ctx, id)
 	if err != nil {
@@ -339,14 +1342,98 @@ func (s *transactionService) GetTransaction(ctx context.Context, id int64) (*dto
 				logger.Int64("transactionId", id))
 			return nil, fmt.Errorf("transaction not found: %d", id)
 		}
-		s.logger.Error("Failed to retrieve transaction",
+		return nil, fmt.Errorf("failed to retrieve transaction: %w", err)
+	}
+
+	summary, err := s.transactionProcessor.GetTransactionBalanceImpact(ctx, s.convertRepoToDomain(repoTransaction), impactState)
+	if err != nil {
+		if errors.Is(err, services.ErrTransactionNotProcessed) {
+			return nil, fmt.Errorf("%w: transaction %d has status %s", ErrTransactionNotProcessed, id, repoTransaction.Status)
+		}
+		s.logger.Error("Failed to compute transaction balance impact",
 			logger.Err(err),
 			logger.Int64("transactionId", id))
+		return nil, fmt.Errorf("failed to compute transaction balance impact: %w", err)
+	}
+
+	return &dto.TransactionBalanceImpactDTO{
+		TransactionID:   summary.TransactionID,
+		PortfolioID:     summary.PortfolioID,
+		SecurityID:      summary.SecurityID,
+		TransactionType: summary.TransactionType,
+		Quantity:        summary.Quantity,
+		Price:           summary.Price,
+		NotionalAmount:  summary.NotionalAmount,
+		State:           string(impactState),
+		DateBasis:       s.transactionProcessor.DateBasis().String(),
+		SecurityImpact:  toBalanceChangeDTO(summary.SecurityImpact),
+		CashImpact:      toBalanceChangeDTO(summary.CashImpact),
+	}, nil
+}
+
+// GetTransactionBalances returns the current security and cash balances a transaction affects,
+// resolved from its portfolio and security
+func (s *transactionService) GetTransactionBalances(ctx context.Context, id int64) (*dto.TransactionBalancesDTO, error) {
+	s.logger.Debug("Retrieving transaction balances",
+		logger.Int64("transactionId", id))
+
+	repoTransaction, err := s.transactionRepo.GetByID(ctx, id)
+	if err != nil {
+		if repositories.IsNotFoundError(err) {
+			s.logger.Warn("Transaction not found",
+				logger.Int64("transactionId", id))
+			return nil, fmt.Errorf("transaction not found: %d", id)
+		}
 		return nil, fmt.Errorf("failed to retrieve transaction: %w", err)
 	}
 
-	domainTransaction := s.convertRepoToDomain(repoTransaction)
-	return s.transactionMapper.ToResponseDTO(domainTransaction), nil
+	impact := models.TransactionType(repoTransaction.TransactionType).GetBalanceImpact()
+	balanceMapper := mappers.NewBalanceMapper()
+	response := &dto.TransactionBalancesDTO{
+		TransactionID:   repoTransaction.ID,
+		PortfolioID:     repoTransaction.PortfolioID,
+		SecurityID:      repoTransaction.SecurityID,
+		TransactionType: repoTransaction.TransactionType,
+		Status:          repoTransaction.Status,
+		Balances:        []dto.BalanceDTO{},
+	}
+
+	if repoTransaction.SecurityID != nil && (impact.LongUnits != models.ImpactNone || impact.ShortUnits != models.ImpactNone) {
+		balance, err := s.balanceRepo.GetByPortfolioAndSecurity(ctx, repoTransaction.PortfolioID, repoTransaction.SecurityID)
+		if err != nil && !repositories.IsNotFoundError(err) {
+			return nil, fmt.Errorf("failed to retrieve security balance: %w", err)
+		}
+		if balance != nil {
+			response.Balances = append(response.Balances, *balanceMapper.ToDTO(s.convertRepoBalanceToDomain(balance)))
+		}
+	}
+
+	if impact.Cash != models.ImpactNone {
+		balance, err := s.balanceRepo.GetCashBalance(ctx, repoTransaction.PortfolioID)
+		if err != nil && !repositories.IsNotFoundError(err) {
+			return nil, fmt.Errorf("failed to retrieve cash balance: %w", err)
+		}
+		if balance != nil {
+			response.Balances = append(response.Balances, *balanceMapper.ToDTO(s.convertRepoBalanceToDomain(balance)))
+		}
+	}
+
+	return response, nil
+}
+
+// toBalanceChangeDTO converts a calculated balance change, which may be nil, to its DTO
+func toBalanceChangeDTO(change *services.BalanceChange) *dto.BalanceChangeDTO {
+	if change == nil {
+		return nil
+	}
+	return &dto.BalanceChangeDTO{
+		BalanceType:    change.BalanceType,
+		LongChange:     change.LongChange,
+		ShortChange:    change.ShortChange,
+		NetChange:      change.NetChange,
+		ResultingLong:  change.ResultingLong,
+		ResultingShort: change.ResultingShort,
+	}
 }
 
 // GetTransactions retrieves transactions with filtering and pagination
@@ -367,23 +1454,22 @@ func (s *transactionService) GetTransactions(ctx context.Context, filter dto.Tra
 	if repoFilter.Limit == 0 {
 		repoFilter.Limit = 50
 	}
-	if repoFilter.Limit > 1000 {
-		repoFilter.Limit = 1000
+	requestedLimit := repoFilter.Limit
+	if repoFilter.Limit > dto.MaxPageLimit {
+		repoFilter.Limit = dto.MaxPageLimit
 	}
 
 	// Get transactions from repository
 	repoTransactions, err := s.transactionRepo.List(ctx, repoFilter)
 	if err != nil {
-		s.logger.Error("Failed to retrieve transactions",
-			logger.Err(err))
+		logQueryError(s.logger, "Failed to retrieve transactions", err)
 		return nil, fmt.Errorf("failed to retrieve transactions: %w", err)
 	}
 
 	// Get total count for pagination
 	totalCount, err := s.transactionRepo.Count(ctx, repoFilter)
 	if err != nil {
-		s.logger.Error("Failed to count transactions",
-			logger.Err(err))
+		logQueryError(s.logger, "Failed to count transactions", err)
 		return nil, fmt.Errorf("failed to count transactions: %w", err)
 	}
 
@@ -403,10 +1489,137 @@ func (s *transactionService) GetTransactions(ctx context.Context, filter dto.Tra
 			repoFilter.Limit,
 			repoFilter.Offset,
 			totalCount,
-		),
+		).WithRequestedLimit(requestedLimit),
 	}, nil
 }
 
+// CountTransactions counts the transactions matching a filter without loading them; pagination
+// and sorting of the filter are ignored
+func (s *transactionService) CountTransactions(ctx context.Context, filter dto.TransactionFilter) (int64, error) {
+	if !filter.IsValid() {
+		return 0, fmt.Errorf("invalid filter parameters")
+	}
+
+	repoFilter := s.convertDTOFilterToRepo(filter)
+	repoFilter.Limit, repoFilter.Offset, repoFilter.SortBy = 0, 0, nil
+
+	count, err := s.transactionRepo.Count(ctx, repoFilter)
+	if err != nil {
+		logQueryError(s.logger, "Failed to count transactions", err)
+		return 0, fmt.Errorf("failed to count transactions: %w", err)
+	}
+
+	return count, nil
+}
+
+// StreamTransactions passes every transaction matching the filter to emit in ID order, reading
+// the database one page at a time with a keyset cursor so the full result is never held in
+// memory. Pagination and sorting of the filter are ignored. It stops at the first error
+// returned by emit and reports how many transactions were emitted.
+func (s *transactionService) StreamTransactions(ctx context.Context, filter dto.TransactionFilter, emit func(dto.TransactionResponseDTO) error) (int, error) {
+	if !filter.IsValid() {
+		return 0, fmt.Errorf("invalid filter parameters")
+	}
+
+	repoFilter := s.convertDTOFilterToRepo(filter)
+	repoFilter.Offset = 0
+	repoFilter.Limit = s.config.StreamPageSize
+	repoFilter.SortFields = nil
+	repoFilter.SortBy = []string{"id ASC"}
+
+	emitted := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return emitted, err
+		}
+
+		page, err := s.transactionRepo.List(ctx, repoFilter)
+		if err != nil {
+			s.logger.Error("Failed to retrieve transaction page",
+				logger.Err(err),
+				logger.Int("emitted", emitted))
+			return emitted, fmt.Errorf("failed to retrieve transactions: %w", err)
+		}
+
+		for _, repoTxn := range page {
+			response := s.transactionMapper.ToResponseDTO(s.convertRepoToDomain(repoTxn))
+			if err := emit(*response); err != nil {
+				return emitted, err
+			}
+			emitted++
+		}
+
+		if len(page) < repoFilter.Limit {
+			return emitted, nil
+		}
+		lastID := page[len(page)-1].ID
+		repoFilter.AfterID = &lastID
+	}
+}
+
+// ValidateTransaction validates a single transaction without persisting it. Repository lookups
+// (source ID uniqueness) are only performed when checkSourceID is set.
+func (s *transactionService) ValidateTransaction(ctx context.Context, transactionDTO dto.TransactionPostDTO, checkSourceID bool) (*dto.TransactionValidationResponse, error) {
+	// Validate DTO
+	if validationErrors := s.transactionMapper.ValidatePostDTO(&transactionDTO); len(validationErrors) > 0 {
+		return &dto.TransactionValidationResponse{Valid: false, Errors: validationErrors}, nil
+	}
+
+	// Convert DTO to domain model
+	domainTransaction, err := s.transactionMapper.FromPostDTO(&transactionDTO)
+	if err != nil {
+		return &dto.TransactionValidationResponse{
+			Valid: false,
+			Errors: []dto.ValidationError{{
+				Field:   "transaction",
+				Message: err.Error(),
+			}},
+		}, nil
+	}
+
+	// Validate business rules
+	var validationResult services.ValidationResult
+	if checkSourceID {
+		validationResult = s.validator.ValidateTransaction(ctx, domainTransaction)
+	} else {
+		validationResult = s.validator.ValidateTransactionRules(ctx, domainTransaction)
+	}
+
+	response := &dto.TransactionValidationResponse{Valid: validationResult.IsValid()}
+	for _, validationError := range validationResult.Errors {
+		response.Errors = append(response.Errors, dto.ValidationError{
+			Field:   validationError.Field,
+			Message: validationError.Message,
+			Value:   fmt.Sprintf("%v", validationError.Value),
+		})
+	}
+
+	return response, nil
+}
+
+// StoredTransaction identifies a stored transaction and its processing status
+type StoredTransaction struct {
+	ID     int64
+	Status string
+}
+
+// FindStoredTransactions returns the ID and status of the stored transaction of each source ID
+// that already belongs to one
+func (s *transactionService) FindStoredTransactions(ctx context.Context, sourceIDs []string) (map[string]StoredTransaction, error) {
+	stored := make(map[string]StoredTransaction)
+	for _, sourceID := range sourceIDs {
+		repoTransaction, err := s.transactionRepo.GetBySourceID(ctx, sourceID)
+		if err != nil {
+			if repositories.IsNotFoundError(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to look up source ID %s: %w", sourceID, err)
+		}
+		stored[sourceID] = StoredTransaction{ID: repoTransaction.ID, Status: repoTransaction.Status}
+	}
+	return stored, nil
+}
+
 // ProcessTransaction processes a single transaction
 func (s *transactionService) ProcessTransaction(ctx context.Context, id int64) (*dto.TransactionProcessingResult, error) {
 	s.logger.Info("Processing transaction",
@@ -422,7 +1635,7 @@ func (s *transactionService) ProcessTransaction(ctx context.Context, id int64) (
 	domainTransaction := s.convertRepoToDomain(repoTransaction)
 
 	// Check if transaction can be processed
-	if !domainTransaction.CanBeProcessed() {
+	if !s.transactionProcessor.CanProcess(domainTransaction) {
 		s.logger.Warn("Transaction cannot be processed",
 			logger.Int64("transactionId", id),
 			logger.String("status", string(domainTransaction.Status())))
@@ -479,6 +1692,70 @@ func (s *transactionService) ProcessTransaction(ctx context.Context, id int64) (
 	}, nil
 }
 
+// ReleaseQuarantinedTransactions looks up the references of up to limit quarantined
+// transactions, oldest first. Those whose portfolio and security now exist are moved to NEW and
+// processed; the rest, including those whose lookup failed, stay quarantined. Without a reference
+// checker every transaction is released. A transaction whose release or processing fails is
+// listed in the response's failed entries and the pass carries on with the next one.
+func (s *transactionService) ReleaseQuarantinedTransactions(ctx context.Context, limit int) (*dto.QuarantineReleaseResponse, error) {
+	status := models.TransactionStatusQuarantined.String()
+	repoTransactions, err := s.transactionRepo.List(ctx, repositories.TransactionFilter{
+		Status: &status,
+		Limit:  limit,
+		SortBy: []string{"created_at", "id"},
+	})
+	if err != nil {
+		logQueryError(s.logger, "Failed to list quarantined transactions", err)
+		return nil, fmt.Errorf("failed to list quarantined transactions: %w", err)
+	}
+
+	response := &dto.QuarantineReleaseResponse{
+		Checked:          len(repoTransactions),
+		Released:         []dto.TransactionProcessingResult{},
+		StillQuarantined: []int64{},
+		Failed:           []dto.QuarantineReleaseFailure{},
+	}
+	for _, repoTransaction := range repoTransactions {
+		domainTransaction := s.convertRepoToDomain(repoTransaction)
+		if unknown := s.validator.UnknownReferences(ctx, domainTransaction); len(unknown) > 0 {
+			response.StillQuarantined = append(response.StillQuarantined, repoTransaction.ID)
+			continue
+		}
+
+		if err := s.transactionRepo.UpdateStatus(ctx, repoTransaction.ID, models.TransactionStatusNew.String(), nil, repoTransaction.Version); err != nil {
+			s.logger.Error("Failed to release quarantined transaction",
+				logger.Err(err),
+				logger.Int64("transactionId", repoTransaction.ID))
+			response.Failed = append(response.Failed, dto.QuarantineReleaseFailure{
+				TransactionID: repoTransaction.ID,
+				Error:         fmt.Sprintf("failed to release quarantined transaction: %v", err),
+			})
+			continue
+		}
+
+		result, err := s.ProcessTransaction(ctx, repoTransaction.ID)
+		if err != nil {
+			s.logger.Error("Failed to process released transaction",
+				logger.Err(err),
+				logger.Int64("transactionId", repoTransaction.ID))
+			response.Failed = append(response.Failed, dto.QuarantineReleaseFailure{
+				TransactionID: repoTransaction.ID,
+				Error:         fmt.Sprintf("failed to process released transaction: %v", err),
+			})
+			continue
+		}
+		response.Released = append(response.Released, *result)
+	}
+
+	s.logger.Info("Quarantined transactions checked",
+		logger.Int("checked", response.Checked),
+		logger.Int("released", len(response.Released)),
+		logger.Int("stillQuarantined", len(response.StillQuarantined)),
+		logger.Int("failed", len(response.Failed)))
+
+	return response, nil
+}
+
 // ReprocessFailedTransactions reprocesses failed transactions
 func (s *transactionService) ReprocessFailedTransactions(ctx context.Context, filter dto.TransactionFilter) (*dto.TransactionBatchResponse, error) {
 	s.logger.Info("Reprocessing failed transactions")
@@ -514,12 +1791,16 @@ func (s *transactionService) ReprocessFailedTransactions(ctx context.Context, fi
 				SourceID:        repoTransaction.SourceID,
 				TransactionType: repoTransaction.TransactionType,
 				Quantity:        repoTransaction.Quantity,
-				Price:           repoTransaction.Price,
+				Price:           &repoTransaction.Price,
 				TransactionDate: repoTransaction.TransactionDate.Format("20060102"),
 			}
 			if repoTransaction.SecurityID != nil {
 				postDTO.SecurityID = repoTransaction.SecurityID
 			}
+			if repoTransaction.SettlementDate != nil {
+				settlementDate := repoTransaction.SettlementDate.Format("20060102")
+				postDTO.SettlementDate = &settlementDate
+			}
 
 			errorMessage := "processing failed"
 			if err != nil {
@@ -549,6 +1830,195 @@ func (s *transactionService) ReprocessFailedTransactions(ctx context.Context, fi
 	return &batchResponse, nil
 }
 
+// RecomputePortfolioBalances re-derives a portfolio's balances by replaying its processed transactions in chronological order
+func (s *transactionService) RecomputePortfolioBalances(ctx context.Context, portfolioID string) (*dto.PortfolioRecomputeResponse, error) {
+	s.logger.Info("Recomputing portfolio balances",
+		logger.String("portfolioId", portfolioID))
+
+	result, err := s.transactionProcessor.RecomputePortfolioBalances(ctx, portfolioID)
+	if err != nil {
+		s.logger.Error("Failed to recompute portfolio balances",
+			logger.Err(err),
+			logger.String("portfolioId", portfolioID))
+		return nil, fmt.Errorf("failed to recompute portfolio balances: %w", err)
+	}
+
+	balanceMapper := mappers.NewBalanceMapper()
+
+	return &dto.PortfolioRecomputeResponse{
+		PortfolioID:          result.PortfolioID,
+		TransactionsReplayed: result.TransactionsReplayed,
+		BalancesCreated:      result.BalancesCreated,
+		BalancesUpdated:      result.BalancesUpdated,
+		BalancesZeroed:       result.BalancesZeroed,
+		Balances:             balanceMapper.ToDTOs(result.Balances),
+		RecomputedAt:         time.Now(),
+	}, nil
+}
+
+// GetPortfolioBalancesAsOf re-derives a portfolio's balances as of a past date from its processed transactions
+func (s *transactionService) GetPortfolioBalancesAsOf(ctx context.Context, portfolioID string, asOf time.Time) (*dto.PortfolioBalancesAsOfResponse, error) {
+	s.logger.Debug("Replaying portfolio balances as of date",
+		logger.String("portfolioId", portfolioID),
+		logger.String("asOf", asOf.Format("2006-01-02")))
+
+	result, err := s.transactionProcessor.GetPortfolioBalancesAsOf(ctx, portfolioID, asOf)
+	if err != nil {
+		s.logger.Error("Failed to get portfolio balances as of date",
+			logger.Err(err),
+			logger.String("portfolioId", portfolioID))
+		return nil, fmt.Errorf("failed to get portfolio balances as of date: %w", err)
+	}
+
+	balanceMapper := mappers.NewBalanceMapper()
+
+	return &dto.PortfolioBalancesAsOfResponse{
+		PortfolioID:          result.PortfolioID,
+		AsOfDate:             result.AsOf.Format("2006-01-02"),
+		DateBasis:            result.DateBasis.String(),
+		TransactionsReplayed: result.TransactionsReplayed,
+		Balances:             balanceMapper.ToDTOs(result.Balances),
+	}, nil
+}
+
+// GetPortfolioLedger re-derives a window of a portfolio's ledger from its processed transactions
+func (s *transactionService) GetPortfolioLedger(ctx context.Context, portfolioID string, from, to time.Time) (*dto.PortfolioLedgerResponse, error) {
+	if err := s.checkLedgerWindow(from, to); err != nil {
+		return nil, err
+	}
+
+	s.logger.Debug("Replaying portfolio ledger",
+		logger.String("portfolioId", portfolioID),
+		logger.String("from", from.Format("2006-01-02")),
+		logger.String("to", to.Format("2006-01-02")))
+
+	result, err := s.transactionProcessor.GetPortfolioLedger(ctx, portfolioID, from, to)
+	if err != nil {
+		s.logger.Error("Failed to get portfolio ledger",
+			logger.Err(err),
+			logger.String("portfolioId", portfolioID))
+		return nil, fmt.Errorf("failed to get portfolio ledger: %w", err)
+	}
+
+	entries := make([]dto.LedgerEntryDTO, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		entries = append(entries, s.toLedgerEntryDTO(entry))
+	}
+
+	return &dto.PortfolioLedgerResponse{
+		PortfolioID:          result.PortfolioID,
+		FromDate:             result.From.Format("2006-01-02"),
+		ToDate:               result.To.Format("2006-01-02"),
+		DateBasis:            result.DateBasis.String(),
+		TransactionsReplayed: result.TransactionsReplayed,
+		OpeningBalances:      mappers.NewBalanceMapper().ToDTOs(result.OpeningBalances),
+		Entries:              entries,
+	}, nil
+}
+
+// StreamPortfolioLedger passes every entry of a window of a portfolio's ledger to emit as it is
+// replayed, without the opening balances, so the window is never held in memory. It stops at the
+// first error of the replay or emit and reports how many entries were emitted.
+func (s *transactionService) StreamPortfolioLedger(ctx context.Context, portfolioID string, from, to time.Time, emit func(dto.LedgerEntryDTO) error) (int, error) {
+	if err := s.checkLedgerWindow(from, to); err != nil {
+		return 0, err
+	}
+
+	s.logger.Debug("Streaming portfolio ledger",
+		logger.String("portfolioId", portfolioID),
+		logger.String("from", from.Format("2006-01-02")),
+		logger.String("to", to.Format("2006-01-02")))
+
+	count := 0
+	_, err := s.transactionProcessor.StreamPortfolioLedger(ctx, portfolioID, from, to,
+		func([]*models.Balance) error { return nil },
+		func(entry services.LedgerEntry) error {
+			if err := emit(s.toLedgerEntryDTO(entry)); err != nil {
+				return err
+			}
+			count++
+			return nil
+		})
+	if err != nil {
+		s.logger.Error("Failed to stream portfolio ledger",
+			logger.Err(err),
+			logger.String("portfolioId", portfolioID),
+			logger.Int("streamed", count))
+		return count, fmt.Errorf("failed to stream portfolio ledger: %w", err)
+	}
+
+	return count, nil
+}
+
+// checkLedgerWindow rejects ledger windows longer than the configured maximum
+func (s *transactionService) checkLedgerWindow(from, to time.Time) error {
+	if days := int(to.Sub(from).Hours()/24) + 1; days > s.config.MaxLedgerWindowDays {
+		return &LedgerWindowTooLargeError{Days: days, Max: s.config.MaxLedgerWindowDays}
+	}
+	return nil
+}
+
+// toLedgerEntryDTO converts a replayed ledger entry to its DTO
+func (s *transactionService) toLedgerEntryDTO(entry services.LedgerEntry) dto.LedgerEntryDTO {
+	balanceMapper := mappers.NewBalanceMapper()
+
+	item := dto.LedgerEntryDTO{
+		EffectiveDate:   entry.EffectiveDate.Format("2006-01-02"),
+		SecurityBalance: balanceMapper.ToDTO(entry.SecurityBalance),
+		CashBalance:     balanceMapper.ToDTO(entry.CashBalance),
+	}
+	if entry.Adjustment != nil {
+		adjustment := toBalanceAdjustmentDTO(entry.Adjustment)
+		item.Adjustment = &adjustment
+	} else {
+		item.Transaction = s.transactionMapper.ToResponseDTO(entry.Transaction)
+	}
+	return item
+}
+
+// CheckPortfolioConsistency compares a portfolio's stored balances with a replay of its processed transactions without modifying them
+func (s *transactionService) CheckPortfolioConsistency(ctx context.Context, portfolioID string) (*dto.ConsistencyCheckResponse, error) {
+	s.logger.Debug("Checking portfolio balance consistency",
+		logger.String("portfolioId", portfolioID))
+
+	report, err := s.transactionProcessor.CheckPortfolioConsistency(ctx, portfolioID)
+	if err != nil {
+		s.logger.Error("Failed to check portfolio consistency",
+			logger.Err(err),
+			logger.String("portfolioId", portfolioID))
+		return nil, fmt.Errorf("failed to check portfolio consistency: %w", err)
+	}
+
+	s.consistencyMetrics.record(ctx, report)
+
+	discrepancies := make([]dto.BalanceDiscrepancyDTO, 0, len(report.Discrepancies))
+	for _, discrepancy := range report.Discrepancies {
+		item := dto.BalanceDiscrepancyDTO{
+			Kind:                  string(discrepancy.Kind),
+			SecurityID:            discrepancy.SecurityID,
+			ExpectedQuantityLong:  discrepancy.ExpectedQuantityLong,
+			ExpectedQuantityShort: discrepancy.ExpectedQuantityShort,
+			StoredQuantityLong:    discrepancy.StoredQuantityLong,
+			StoredQuantityShort:   discrepancy.StoredQuantityShort,
+		}
+		if discrepancy.BalanceID != 0 {
+			balanceID := discrepancy.BalanceID
+			item.BalanceID = &balanceID
+		}
+		discrepancies = append(discrepancies, item)
+	}
+
+	return &dto.ConsistencyCheckResponse{
+		PortfolioID:          report.PortfolioID,
+		Consistent:           report.IsConsistent(),
+		TransactionsReplayed: report.TransactionsReplayed,
+		BalancesChecked:      report.BalancesChecked,
+		DriftCount:           len(report.Discrepancies),
+		Discrepancies:        discrepancies,
+		CheckedAt:            time.Now(),
+	}, nil
+}
+
 // GetTransactionStats retrieves transaction statistics
 func (s *transactionService) GetTransactionStats(ctx context.Context, filter dto.TransactionFilter) (*dto.TransactionStatsDTO, error) {
 	s.logger.Debug("Retrieving transaction statistics")
@@ -603,6 +2073,7 @@ func (s *transactionService) convertDomainToRepo(domainTxn *models.Transaction)
 		Quantity:             domainTxn.Quantity().Value(),
 		Price:                domainTxn.Price().Value(),
 		TransactionDate:      domainTxn.TransactionDate(),
+		SettlementDate:       domainTxn.SettlementDate(),
 		ReprocessingAttempts: domainTxn.ReprocessingAttempts(),
 		Version:              domainTxn.Version(),
 		CreatedAt:            domainTxn.CreatedAt(),
@@ -619,6 +2090,26 @@ func (s *transactionService) convertDomainToRepo(domainTxn *models.Transaction)
 	return repoTxn
 }
 
+// convertRepoBalanceToDomain converts a repository balance to the domain model
+func (s *transactionService) convertRepoBalanceToDomain(repoBalance *repositories.Balance) *models.Balance {
+	domainBalance, err := models.NewBalanceBuilder().
+		WithID(repoBalance.ID).
+		WithPortfolioID(repoBalance.PortfolioID).
+		WithSecurityID(repoBalance.SecurityID).
+		WithQuantityLong(repoBalance.QuantityLong).
+		WithQuantityShort(repoBalance.QuantityShort).
+		WithVersion(repoBalance.Version).
+		WithTimestamps(repoBalance.CreatedAt, repoBalance.LastUpdated).
+		Build()
+	if err != nil {
+		s.logger.Error("Failed to convert repository balance to domain model",
+			logger.Int64("balanceId", repoBalance.ID),
+			logger.Err(err))
+		return &models.Balance{}
+	}
+	return domainBalance
+}
+
 // convertRepoToDomain converts a repository transaction to domain transaction
 func (s *transactionService) convertRepoToDomain(repoTxn *repositories.Transaction) *models.Transaction {
 	builder := models.NewTransactionBuilder().
@@ -631,6 +2122,7 @@ func (s *transactionService) convertRepoToDomain(repoTxn *repositories.Transacti
 		WithQuantity(repoTxn.Quantity).
 		WithPrice(repoTxn.Price).
 		WithTransactionDate(repoTxn.TransactionDate).
+		WithSettlementDate(repoTxn.SettlementDate).
 		WithReprocessingAttempts(repoTxn.ReprocessingAttempts).
 		WithVersion(repoTxn.Version).
 		WithTimestamps(repoTxn.CreatedAt, repoTxn.UpdatedAt)
@@ -724,6 +2216,21 @@ func (s *transactionService) convertDTOFilterToRepo(dtoFilter dto.TransactionFil
 	return repoFilter
 }
 
+// logQueryError logs a failed repository query. A query abandoned because the request was
+// canceled or timed out is not a service failure and is only logged at debug level.
+func logQueryError(lg logger.Logger, msg string, err error) {
+	if repositories.IsQueryCanceledError(err) {
+		lg.Debug(msg+": query canceled", logger.Err(err))
+		return
+	}
+	lg.Error(msg, logger.Err(err))
+}
+
+// intPtr creates an int pointer
+func intPtr(i int) *int {
+	return &i
+}
+
 // stringPtr creates a string pointer
 func stringPtr(s string) *string {
 	return &s
@@ -0,0 +1,1564 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/dto"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/application/mappers"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	domainServices "github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/services"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+func (r *fakeTransactionRepo) GetBySourceID(ctx context.Context, sourceID string) (*repositories.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, txn := range r.transactions {
+		if txn.SourceID == sourceID {
+			clone := *txn
+			return &clone, nil
+		}
+	}
+	return nil, repositories.NewNotFoundError("transaction", sourceID)
+}
+
+func newValidationService(txnRepo *fakeTransactionRepo) TransactionService {
+	lg := logger.NewNoop()
+	validator := domainServices.NewTransactionValidator(txnRepo, nil, lg)
+	return NewTransactionService(txnRepo, nil, domainServices.TransactionProcessor{}, *validator,
+		mappers.NewTransactionMapper(), TransactionServiceConfig{}, lg)
+}
+
+func validDeposit() dto.TransactionPostDTO {
+	return dto.TransactionPostDTO{
+		PortfolioID:     testPortfolioID,
+		SourceID:        "DEP-VALIDATE-1",
+		TransactionType: "DEP",
+		Quantity:        decimal.NewFromInt(500),
+		Price:           pricePtr(decimal.NewFromInt(1)),
+		TransactionDate: "20240102",
+	}
+}
+
+func (r *fakeTransactionRepo) Count(ctx context.Context, filter repositories.TransactionFilter) (int64, error) {
+	filter.Limit = 0
+	transactions, err := r.List(ctx, filter)
+	return int64(len(transactions)), err
+}
+
+func TestTransactionService_ValidateTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Valid transaction", func(t *testing.T) {
+		service := newValidationService(newFakeTransactionRepo())
+
+		result, err := service.ValidateTransaction(ctx, validDeposit(), false)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+		assert.Empty(t, result.Errors)
+	})
+
+	t.Run("Invalid DTO fields", func(t *testing.T) {
+		service := newValidationService(newFakeTransactionRepo())
+
+		transaction := validDeposit()
+		transaction.PortfolioID = "SHORT"
+		transaction.TransactionDate = "2024-01-02"
+
+		result, err := service.ValidateTransaction(ctx, transaction, false)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+
+		fields := make([]string, 0, len(result.Errors))
+		for _, validationError := range result.Errors {
+			fields = append(fields, validationError.Field)
+		}
+		assert.Contains(t, fields, "portfolioId")
+		assert.Contains(t, fields, "transactionDate")
+	})
+
+	t.Run("Business rule violation", func(t *testing.T) {
+		service := newValidationService(newFakeTransactionRepo())
+
+		transaction := validDeposit()
+		transaction.Price = pricePtr(decimal.NewFromInt(2))
+
+		result, err := service.ValidateTransaction(ctx, transaction, false)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.NotEmpty(t, result.Errors)
+	})
+
+	t.Run("Source ID uniqueness only checked on request", func(t *testing.T) {
+		txnRepo := newFakeTransactionRepo(&repositories.Transaction{ID: 1, SourceID: "DEP-VALIDATE-1"})
+		service := newValidationService(txnRepo)
+
+		result, err := service.ValidateTransaction(ctx, validDeposit(), false)
+		require.NoError(t, err)
+		assert.True(t, result.Valid)
+
+		result, err = service.ValidateTransaction(ctx, validDeposit(), true)
+		require.NoError(t, err)
+		assert.False(t, result.Valid)
+		require.Len(t, result.Errors, 1)
+		assert.Equal(t, "sourceId", result.Errors[0].Field)
+	})
+}
+
+func TestTransactionService_FindStoredTransactions(t *testing.T) {
+	service := newValidationService(newFakeTransactionRepo(
+		&repositories.Transaction{ID: 1, SourceID: "DEP-STORED-1", Status: "PROC"},
+		&repositories.Transaction{ID: 2, SourceID: "DEP-STORED-2", Status: "NEW"},
+	))
+
+	stored, err := service.FindStoredTransactions(context.Background(), []string{"DEP-STORED-1", "DEP-NEW-1", "DEP-STORED-2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]StoredTransaction{
+		"DEP-STORED-1": {ID: 1, Status: "PROC"},
+		"DEP-STORED-2": {ID: 2, Status: "NEW"},
+	}, stored)
+}
+
+// positionBalanceRepo looks balances up by portfolio and security
+type positionBalanceRepo struct {
+	repositories.BalanceRepository
+
+	balances []*repositories.Balance
+}
+
+func (r *positionBalanceRepo) GetByPortfolioAndSecurity(ctx context.Context, portfolioID string, securityID *string) (*repositories.Balance, error) {
+	for _, balance := range r.balances {
+		if balance.PortfolioID != portfolioID || (balance.SecurityID == nil) != (securityID == nil) {
+			continue
+		}
+		if securityID == nil || *balance.SecurityID == *securityID {
+			clone := *balance
+			return &clone, nil
+		}
+	}
+	return nil, repositories.NewNotFoundError("balance", portfolioID)
+}
+
+func (r *positionBalanceRepo) GetCashBalance(ctx context.Context, portfolioID string) (*repositories.Balance, error) {
+	return r.GetByPortfolioAndSecurity(ctx, portfolioID, nil)
+}
+
+func TestTransactionService_GetTransactionBalances(t *testing.T) {
+	ctx := context.Background()
+	securityID := "SECURITY0000000000000001"
+	otherSecurityID := "SECURITY0000000000000002"
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	balance := func(id int64, securityID *string, quantityLong int64) *repositories.Balance {
+		return &repositories.Balance{
+			ID:            id,
+			PortfolioID:   testPortfolioID,
+			SecurityID:    securityID,
+			QuantityLong:  decimal.NewFromInt(quantityLong),
+			QuantityShort: decimal.Zero,
+			Version:       1,
+			CreatedAt:     now,
+			LastUpdated:   now,
+		}
+	}
+
+	txnRepo := newFakeTransactionRepo(
+		&repositories.Transaction{ID: 1, PortfolioID: testPortfolioID, SecurityID: &securityID, SourceID: "BUY-1",
+			Status: "PROC", TransactionType: "BUY", Quantity: decimal.NewFromInt(100), Price: decimal.NewFromInt(10), TransactionDate: now},
+		&repositories.Transaction{ID: 2, PortfolioID: testPortfolioID, SourceID: "DEP-1",
+			Status: "PROC", TransactionType: "DEP", Quantity: decimal.NewFromInt(5000), Price: decimal.NewFromInt(1), TransactionDate: now},
+	)
+	balanceRepo := &positionBalanceRepo{balances: []*repositories.Balance{
+		balance(10, nil, 4000),
+		balance(11, &securityID, 100),
+		balance(12, &otherSecurityID, 300),
+	}}
+	lg := logger.NewNoop()
+	service := NewTransactionService(txnRepo, balanceRepo, domainServices.TransactionProcessor{},
+		*domainServices.NewTransactionValidator(txnRepo, nil, lg), mappers.NewTransactionMapper(), TransactionServiceConfig{}, lg)
+
+	t.Run("BUY affects the security and cash balances", func(t *testing.T) {
+		result, err := service.GetTransactionBalances(ctx, 1)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(1), result.TransactionID)
+		assert.Equal(t, "BUY", result.TransactionType)
+		require.Len(t, result.Balances, 2)
+		assert.Equal(t, int64(11), result.Balances[0].ID)
+		assert.True(t, decimal.NewFromInt(100).Equal(result.Balances[0].QuantityLong))
+		assert.Equal(t, int64(10), result.Balances[1].ID)
+		assert.Nil(t, result.Balances[1].SecurityID)
+		assert.True(t, decimal.NewFromInt(4000).Equal(result.Balances[1].QuantityLong))
+	})
+
+	t.Run("DEP affects only the cash balance", func(t *testing.T) {
+		result, err := service.GetTransactionBalances(ctx, 2)
+		require.NoError(t, err)
+
+		require.Len(t, result.Balances, 1)
+		assert.Equal(t, int64(10), result.Balances[0].ID)
+		assert.Nil(t, result.Balances[0].SecurityID)
+	})
+
+	t.Run("Missing balances are left out", func(t *testing.T) {
+		service := NewTransactionService(txnRepo, &positionBalanceRepo{}, domainServices.TransactionProcessor{},
+			*domainServices.NewTransactionValidator(txnRepo, nil, lg), mappers.NewTransactionMapper(), TransactionServiceConfig{}, lg)
+
+		result, err := service.GetTransactionBalances(ctx, 1)
+		require.NoError(t, err)
+		assert.Empty(t, result.Balances)
+	})
+
+	t.Run("Unknown transaction", func(t *testing.T) {
+		_, err := service.GetTransactionBalances(ctx, 99)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
+func TestTransactionService_CreateTransactionsStrict(t *testing.T) {
+	ctx := context.Background()
+	txnRepo := newFakeTransactionRepo()
+	service := newValidationService(txnRepo)
+
+	invalidPrice := validDeposit()
+	invalidPrice.SourceID = "DEP-VALIDATE-2"
+	invalidPrice.Price = pricePtr(decimal.NewFromInt(2))
+
+	invalidDate := validDeposit()
+	invalidDate.SourceID = "DEP-VALIDATE-4"
+	invalidDate.TransactionDate = "2024-01-02"
+
+	valid := validDeposit()
+	valid.SourceID = "DEP-VALIDATE-3"
+
+	result, err := service.CreateTransactionsStrict(ctx, []dto.TransactionPostDTO{validDeposit(), invalidPrice, valid, invalidDate}, BatchOptions{})
+	assert.Nil(t, result)
+
+	var batchErr *BatchValidationError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, 4, batchErr.Total)
+	require.Len(t, batchErr.Failed, 2)
+	assert.Equal(t, 1, batchErr.Failed[0].Index)
+	assert.Equal(t, "DEP-VALIDATE-2", batchErr.Failed[0].Transaction.SourceID)
+	assert.NotEmpty(t, batchErr.Failed[0].Errors)
+	assert.Equal(t, 3, batchErr.Failed[1].Index)
+	assert.NotEmpty(t, batchErr.Failed[1].Errors)
+
+	// Validation happens before any creation, so the valid transactions were not stored
+	assert.Empty(t, txnRepo.transactions)
+}
+
+func TestTransactionService_CreateTransactionsDuplicateSourceID(t *testing.T) {
+	ctx := context.Background()
+	// The fake repository has no Create, so any write attempt would fail the test
+	txnRepo := newFakeTransactionRepo()
+	service := newValidationService(txnRepo)
+
+	duplicate := validDeposit()
+	duplicate.Quantity = decimal.NewFromInt(700)
+
+	result, err := service.CreateTransactions(ctx, []dto.TransactionPostDTO{validDeposit(), duplicate}, BatchOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, result.Successful)
+	require.Len(t, result.Failed, 2)
+	for i, failure := range result.Failed {
+		require.Len(t, failure.Errors, 1, "record %d", i)
+		assert.Equal(t, "sourceId", failure.Errors[0].Field)
+		assert.Equal(t, "duplicate source_id within batch", failure.Errors[0].Message)
+		assert.Equal(t, "DEP-VALIDATE-1", failure.Errors[0].Value)
+		require.NotNil(t, failure.BatchIndex, "record %d", i)
+		assert.Equal(t, i, *failure.BatchIndex)
+	}
+	assert.Equal(t, decimal.NewFromInt(500), result.Failed[0].Transaction.Quantity)
+	assert.Equal(t, decimal.NewFromInt(700), result.Failed[1].Transaction.Quantity)
+
+	t.Run("Strict batch rejects the duplicates", func(t *testing.T) {
+		_, err := service.CreateTransactionsStrict(ctx, []dto.TransactionPostDTO{validDeposit(), duplicate}, BatchOptions{})
+
+		var batchErr *BatchValidationError
+		require.ErrorAs(t, err, &batchErr)
+		require.Len(t, batchErr.Failed, 2)
+		assert.Equal(t, 0, batchErr.Failed[0].Index)
+		assert.Equal(t, 1, batchErr.Failed[1].Index)
+		assert.Equal(t, "duplicate source_id within batch", batchErr.Failed[1].Errors[0].Message)
+	})
+
+	assert.Empty(t, txnRepo.transactions)
+}
+
+// slowCreateTransactionRepo takes delay for every write and then fails it, so a batch spends
+// its processing timeout without needing balance processing
+type slowCreateTransactionRepo struct {
+	*fakeTransactionRepo
+
+	delay   time.Duration
+	mu      sync.Mutex
+	created []string
+}
+
+func (r *slowCreateTransactionRepo) Create(ctx context.Context, transaction *repositories.Transaction) error {
+	time.Sleep(r.delay)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.created = append(r.created, transaction.SourceID)
+	return errors.New("write timed out")
+}
+
+func TestTransactionService_CreateTransactionsDeadline(t *testing.T) {
+	ctx := context.Background()
+	batch := make([]dto.TransactionPostDTO, 4)
+	for i := range batch {
+		batch[i] = validDeposit()
+		batch[i].SourceID = fmt.Sprintf("DEP-DEADLINE-%d", i)
+	}
+
+	newService := func(repo *slowCreateTransactionRepo) TransactionService {
+		lg := logger.NewNoop()
+		validator := domainServices.NewTransactionValidator(repo.fakeTransactionRepo, nil, lg)
+		return NewTransactionService(repo, nil, domainServices.TransactionProcessor{}, *validator,
+			mappers.NewTransactionMapper(), TransactionServiceConfig{ProcessingTimeout: 20 * time.Millisecond}, lg)
+	}
+
+	assertDeadline := func(t *testing.T, repo *slowCreateTransactionRepo, result *dto.TransactionBatchResponse) {
+		// The first write outlasts the timeout, so nothing after it is attempted
+		assert.Equal(t, []string{"DEP-DEADLINE-0"}, repo.created)
+		require.Len(t, result.Failed, 4)
+		assert.Equal(t, "repository", result.Failed[0].Errors[0].Field)
+		for i := 1; i < 4; i++ {
+			failure := result.Failed[i]
+			require.Len(t, failure.Errors, 1, "record %d", i)
+			assert.Equal(t, "batch", failure.Errors[0].Field)
+			assert.Equal(t, "deadline exceeded; transaction was not processed", failure.Errors[0].Message)
+			require.NotNil(t, failure.BatchIndex)
+			assert.Equal(t, i, *failure.BatchIndex)
+		}
+		assert.True(t, result.Summary.DeadlineExceeded)
+		assert.Equal(t, 3, result.Summary.Unprocessed)
+		assert.Equal(t, 4, result.Summary.Failed)
+	}
+
+	t.Run("Remaining records are returned unprocessed", func(t *testing.T) {
+		repo := &slowCreateTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo(), delay: 50 * time.Millisecond}
+
+		result, err := newService(repo).CreateTransactions(ctx, batch, BatchOptions{})
+		require.NoError(t, err)
+		assertDeadline(t, repo, result)
+	})
+
+	t.Run("Strict batch stops at the deadline too", func(t *testing.T) {
+		repo := &slowCreateTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo(), delay: 50 * time.Millisecond}
+
+		result, err := newService(repo).CreateTransactionsStrict(ctx, batch, BatchOptions{})
+		require.NoError(t, err)
+		assertDeadline(t, repo, result)
+	})
+
+	t.Run("Batch within the timeout is not flagged", func(t *testing.T) {
+		repo := &slowCreateTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo()}
+
+		result, err := newService(repo).CreateTransactions(ctx, batch, BatchOptions{})
+		require.NoError(t, err)
+		assert.Len(t, repo.created, 4)
+		assert.False(t, result.Summary.DeadlineExceeded)
+		assert.Zero(t, result.Summary.Unprocessed)
+	})
+}
+
+// blockingCreateTransactionRepo records every write and then waits for its context to end, like
+// a database write that outlasts the batch deadline
+type blockingCreateTransactionRepo struct {
+	*fakeTransactionRepo
+
+	created []string
+}
+
+func (r *blockingCreateTransactionRepo) Create(ctx context.Context, transaction *repositories.Transaction) error {
+	r.created = append(r.created, transaction.SourceID)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// rollbackUnitOfWork drops the source IDs written in a failed unit like a database rollback
+type rollbackUnitOfWork struct {
+	written    *[]string
+	deadline   bool
+	rolledBack bool
+}
+
+func (u *rollbackUnitOfWork) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, u.deadline = ctx.Deadline()
+	written := len(*u.written)
+	if err := fn(ctx); err != nil {
+		*u.written = (*u.written)[:written]
+		u.rolledBack = true
+		return err
+	}
+	return nil
+}
+
+func TestTransactionService_CreateTransactionsStrictUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+	batch := func(sourceIDs ...string) []dto.TransactionPostDTO {
+		transactions := make([]dto.TransactionPostDTO, len(sourceIDs))
+		for i, sourceID := range sourceIDs {
+			transactions[i] = validDeposit()
+			transactions[i].SourceID = sourceID
+		}
+		return transactions
+	}
+	newService := func(repo repositories.TransactionRepository, validationRepo *fakeTransactionRepo, config TransactionServiceConfig) TransactionService {
+		lg := logger.NewNoop()
+		validator := domainServices.NewTransactionValidator(validationRepo, nil, lg)
+		return NewTransactionService(repo, nil, domainServices.TransactionProcessor{}, *validator,
+			mappers.NewTransactionMapper(), config, lg)
+	}
+
+	t.Run("Batch past the deadline is rolled back", func(t *testing.T) {
+		repo := &blockingCreateTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo()}
+		unitOfWork := &rollbackUnitOfWork{written: &repo.created}
+
+		result, err := newService(repo, repo.fakeTransactionRepo, TransactionServiceConfig{
+			ProcessingTimeout: 20 * time.Millisecond,
+			UnitOfWork:        unitOfWork,
+		}).CreateTransactionsStrict(ctx, batch("DEP-UOW-0", "DEP-UOW-1", "DEP-UOW-2"), BatchOptions{})
+		require.NoError(t, err)
+
+		assert.True(t, unitOfWork.deadline, "the unit of work runs with the batch deadline")
+		assert.True(t, unitOfWork.rolledBack)
+		assert.Empty(t, repo.created)
+		require.Len(t, result.Failed, 3)
+		for i, failure := range result.Failed {
+			assert.Equal(t, "deadline exceeded; transaction was not processed", failure.Errors[0].Message)
+			require.NotNil(t, failure.BatchIndex)
+			assert.Equal(t, i, *failure.BatchIndex)
+		}
+		assert.True(t, result.Summary.DeadlineExceeded)
+		assert.Equal(t, 3, result.Summary.Unprocessed)
+	})
+
+	t.Run("Failed transaction rolls the batch back", func(t *testing.T) {
+		repo := &failingCreateTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo()}
+		unitOfWork := &rollbackUnitOfWork{written: &repo.attempted}
+
+		_, err := newService(repo, repo.fakeTransactionRepo, TransactionServiceConfig{UnitOfWork: unitOfWork}).
+			CreateTransactionsStrict(ctx, batch("DEP-DUP-0", "DEP-DUP-1"), BatchOptions{})
+
+		var batchErr *BatchValidationError
+		require.ErrorAs(t, err, &batchErr)
+		require.Len(t, batchErr.Failed, 1)
+		assert.Equal(t, 0, batchErr.Failed[0].Index)
+		assert.Equal(t, "repository", batchErr.Failed[0].Errors[0].Field)
+		assert.True(t, unitOfWork.rolledBack)
+		assert.Empty(t, repo.attempted)
+	})
+
+	t.Run("Infrastructure error aborts with nothing created", func(t *testing.T) {
+		repo := &failingCreateTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo()}
+		unitOfWork := &rollbackUnitOfWork{written: &repo.attempted}
+
+		_, err := newService(repo, repo.fakeTransactionRepo, TransactionServiceConfig{UnitOfWork: unitOfWork, AbortOnInfrastructureError: true}).
+			CreateTransactionsStrict(ctx, batch("DOWN-0", "DEP-1"), BatchOptions{})
+
+		var abortedErr *BatchAbortedError
+		require.ErrorAs(t, err, &abortedErr)
+		assert.Zero(t, abortedErr.Index)
+		assert.Zero(t, abortedErr.Created)
+		assert.True(t, unitOfWork.rolledBack)
+	})
+}
+
+// failingCreateTransactionRepo fails every write, with a connection error for source IDs
+// starting with DOWN and a duplicate key error for the rest
+type failingCreateTransactionRepo struct {
+	*fakeTransactionRepo
+
+	attempted []string
+}
+
+func (r *failingCreateTransactionRepo) Create(ctx context.Context, transaction *repositories.Transaction) error {
+	r.attempted = append(r.attempted, transaction.SourceID)
+	if strings.HasPrefix(transaction.SourceID, "DOWN") {
+		return repositories.NewConnectionError("create", errors.New("connection refused"))
+	}
+	return repositories.NewDuplicateKeyError("transaction", "source_id", transaction.SourceID)
+}
+
+func TestTransactionService_CreateTransactionsInfrastructureErrors(t *testing.T) {
+	ctx := context.Background()
+	batch := func(sourceIDs ...string) []dto.TransactionPostDTO {
+		transactions := make([]dto.TransactionPostDTO, len(sourceIDs))
+		for i, sourceID := range sourceIDs {
+			transactions[i] = validDeposit()
+			transactions[i].SourceID = sourceID
+		}
+		return transactions
+	}
+
+	newService := func(repo *failingCreateTransactionRepo, abort bool) TransactionService {
+		lg := logger.NewNoop()
+		validator := domainServices.NewTransactionValidator(repo.fakeTransactionRepo, nil, lg)
+		return NewTransactionService(repo, nil, domainServices.TransactionProcessor{}, *validator,
+			mappers.NewTransactionMapper(), TransactionServiceConfig{AbortOnInfrastructureError: abort}, lg)
+	}
+
+	t.Run("Infrastructure error aborts the batch", func(t *testing.T) {
+		repo := &failingCreateTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo()}
+
+		result, err := newService(repo, true).CreateTransactions(ctx, batch("DEP-DUP-0", "DOWN-1", "DEP-2"), BatchOptions{})
+
+		assert.Nil(t, result)
+		var abortedErr *BatchAbortedError
+		require.ErrorAs(t, err, &abortedErr)
+		assert.Equal(t, 1, abortedErr.Index)
+		assert.Equal(t, 3, abortedErr.Total)
+		assert.Zero(t, abortedErr.Created)
+		assert.True(t, repositories.IsConnectionError(err))
+		// Nothing after the failed record is attempted
+		assert.Equal(t, []string{"DEP-DUP-0", "DOWN-1"}, repo.attempted)
+	})
+
+	t.Run("Strict batch aborts too", func(t *testing.T) {
+		repo := &failingCreateTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo()}
+
+		_, err := newService(repo, true).CreateTransactionsStrict(ctx, batch("DOWN-0", "DEP-1"), BatchOptions{})
+
+		var abortedErr *BatchAbortedError
+		require.ErrorAs(t, err, &abortedErr)
+		assert.Equal(t, 0, abortedErr.Index)
+		assert.Equal(t, []string{"DOWN-0"}, repo.attempted)
+	})
+
+	t.Run("Business errors skip the record", func(t *testing.T) {
+		repo := &failingCreateTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo()}
+
+		result, err := newService(repo, true).CreateTransactions(ctx, batch("DEP-DUP-0", "DEP-DUP-1"), BatchOptions{})
+		require.NoError(t, err)
+		require.Len(t, result.Failed, 2)
+		assert.Equal(t, "repository", result.Failed[0].Errors[0].Field)
+		assert.Equal(t, []string{"DEP-DUP-0", "DEP-DUP-1"}, repo.attempted)
+	})
+
+	t.Run("Resubmitted records stored before the abort are reported as created", func(t *testing.T) {
+		stored := &repositories.Transaction{
+			ID: 7, PortfolioID: testPortfolioID, SourceID: "DEP-RETRY-0", Status: models.TransactionStatusProc.String(),
+			TransactionType: "DEP", Quantity: decimal.NewFromInt(500), Price: decimal.NewFromInt(1),
+			TransactionDate: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Version: 1,
+		}
+		repo := &failingCreateTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo(stored)}
+
+		result, err := newService(repo, true).CreateTransactions(ctx, batch("DEP-RETRY-0"), BatchOptions{})
+		require.NoError(t, err)
+		require.Len(t, result.Successful, 1)
+		assert.Equal(t, int64(7), result.Successful[0].ID)
+		assert.Equal(t, "PROC", result.Successful[0].Status)
+		require.NotNil(t, result.Successful[0].BatchIndex)
+		assert.Zero(t, *result.Successful[0].BatchIndex)
+		assert.Empty(t, result.Failed)
+		assert.Empty(t, repo.attempted, "the stored record is not created again")
+
+		result, err = newService(repo, true).CreateTransactionsStrict(ctx, batch("DEP-RETRY-0"), BatchOptions{})
+		require.NoError(t, err)
+		require.Len(t, result.Successful, 1)
+		assert.Empty(t, repo.attempted)
+	})
+
+	t.Run("Source ID stored with another booking is still a duplicate", func(t *testing.T) {
+		stored := &repositories.Transaction{
+			ID: 7, PortfolioID: testPortfolioID, SourceID: "DEP-RETRY-0", Status: models.TransactionStatusProc.String(),
+			TransactionType: "DEP", Quantity: decimal.NewFromInt(900), Price: decimal.NewFromInt(1),
+			TransactionDate: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		}
+		repo := &failingCreateTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo(stored)}
+
+		result, err := newService(repo, true).CreateTransactions(ctx, batch("DEP-RETRY-0"), BatchOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, result.Successful)
+		require.Len(t, result.Failed, 1)
+		assert.Equal(t, "sourceId", result.Failed[0].Errors[0].Field)
+	})
+
+	t.Run("Without the option infrastructure errors skip the record", func(t *testing.T) {
+		repo := &failingCreateTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo()}
+
+		result, err := newService(repo, false).CreateTransactions(ctx, batch("DEP-DUP-0", "DOWN-1", "DEP-2"), BatchOptions{})
+		require.NoError(t, err)
+		require.Len(t, result.Failed, 3)
+		assert.Contains(t, result.Failed[1].Errors[0].Message, "connection")
+		assert.Equal(t, []string{"DEP-DUP-0", "DOWN-1", "DEP-2"}, repo.attempted)
+	})
+}
+
+func TestTransactionService_CheckPortfolioConsistency(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	txnRepo := newFakeTransactionRepo(&repositories.Transaction{
+		ID:              1,
+		PortfolioID:     testPortfolioID,
+		SourceID:        "DEP-CONSISTENCY-1",
+		Status:          models.TransactionStatusProc.String(),
+		TransactionType: models.TransactionTypeDep.String(),
+		Quantity:        decimal.NewFromInt(1000),
+		Price:           decimal.NewFromInt(1),
+		TransactionDate: now,
+		Version:         2,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	// Deliberate drift: the stored cash balance is 100 short of the deposit
+	balanceRepo := &summaryBalanceRepo{balances: []*repositories.Balance{{
+		ID:            5,
+		PortfolioID:   testPortfolioID,
+		QuantityLong:  decimal.NewFromInt(900),
+		QuantityShort: decimal.Zero,
+		Version:       1,
+		CreatedAt:     now,
+		LastUpdated:   now,
+	}}}
+
+	reader := sdkmetric.NewManualReader()
+	lg := logger.NewNoop()
+	processor := domainServices.NewTransactionProcessor(txnRepo, balanceRepo, nil,
+		domainServices.NewBalanceCalculator(balanceRepo, lg), lg)
+	service := NewTransactionService(txnRepo, balanceRepo, *processor, domainServices.TransactionValidator{},
+		mappers.NewTransactionMapper(),
+		TransactionServiceConfig{MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))}, lg)
+
+	result, err := service.CheckPortfolioConsistency(ctx, testPortfolioID)
+	require.NoError(t, err)
+
+	assert.False(t, result.Consistent)
+	assert.Equal(t, 1, result.DriftCount)
+	require.Len(t, result.Discrepancies, 1)
+	discrepancy := result.Discrepancies[0]
+	assert.Equal(t, "MISMATCH", discrepancy.Kind)
+	require.NotNil(t, discrepancy.BalanceID)
+	assert.Equal(t, int64(5), *discrepancy.BalanceID)
+	assert.Nil(t, discrepancy.SecurityID)
+	assert.True(t, decimal.NewFromInt(1000).Equal(discrepancy.ExpectedQuantityLong))
+	assert.True(t, decimal.NewFromInt(900).Equal(discrepancy.StoredQuantityLong))
+
+	// The check is read-only
+	assert.True(t, decimal.NewFromInt(900).Equal(balanceRepo.balances[0].QuantityLong))
+
+	var collected metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &collected))
+	assert.Equal(t, int64(1), counterValue(t, collected, "balance_consistency_drift_total", "kind", "MISMATCH"))
+	assert.Equal(t, int64(1), counterValue(t, collected, "balance_consistency_checks_total", "result", "drift"))
+}
+
+// counterValue returns the value of the counter data point carrying the given attribute
+func counterValue(t *testing.T, collected metricdata.ResourceMetrics, name, key, value string) int64 {
+	t.Helper()
+
+	for _, scope := range collected.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != name {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			require.True(t, ok, "metric %s is not an int64 sum", name)
+			for _, point := range sum.DataPoints {
+				if v, ok := point.Attributes.Value(attribute.Key(key)); ok && v.AsString() == value {
+					return point.Value
+				}
+			}
+		}
+	}
+	t.Fatalf("metric %s{%s=%q} not found", name, key, value)
+	return 0
+}
+
+// historyTransactionRepo serves fixed audit events on top of the in-memory transaction repo
+type historyTransactionRepo struct {
+	*fakeTransactionRepo
+
+	events map[int64][]*repositories.TransactionEvent
+}
+
+func (r *historyTransactionRepo) GetHistory(ctx context.Context, transactionID int64) ([]*repositories.TransactionEvent, error) {
+	return r.events[transactionID], nil
+}
+
+func TestTransactionService_GetTransactionHistory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	newStatus := "NEW"
+	errorStatus := "ERROR"
+	transient := "balance version conflict"
+
+	txnRepo := &historyTransactionRepo{
+		fakeTransactionRepo: newFakeTransactionRepo(&repositories.Transaction{ID: 1, SourceID: "HISTORY-1"}),
+		events: map[int64][]*repositories.TransactionEvent{1: {
+			{ID: 10, TransactionID: 1, EventType: repositories.TransactionEventRetryableError, OldStatus: &newStatus,
+				NewStatus: "ERROR", ErrorMessage: &transient, ErrorRetryable: true, Version: 2, CreatedAt: now},
+			{ID: 11, TransactionID: 1, EventType: repositories.TransactionEventReprocessingAttempt, OldStatus: &errorStatus,
+				NewStatus: "ERROR", ReprocessingAttempts: 1, Version: 3, CreatedAt: now},
+			{ID: 12, TransactionID: 1, EventType: repositories.TransactionEventStatusChange, OldStatus: &errorStatus,
+				NewStatus: "PROC", ReprocessingAttempts: 1, Version: 4, CreatedAt: now},
+		}},
+	}
+	lg := logger.NewNoop()
+	service := NewTransactionService(txnRepo, nil, domainServices.TransactionProcessor{}, domainServices.TransactionValidator{},
+		mappers.NewTransactionMapper(), TransactionServiceConfig{}, lg)
+
+	history, err := service.GetTransactionHistory(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), history.TransactionID)
+	require.Len(t, history.Events, 3)
+	assert.Equal(t, "RETRYABLE_ERROR", history.Events[0].EventType)
+	assert.Equal(t, transient, *history.Events[0].ErrorMessage)
+	assert.Equal(t, "REPROCESSING_ATTEMPT", history.Events[1].EventType)
+	assert.Equal(t, 1, history.Events[1].ReprocessingAttempts)
+	assert.Equal(t, "ERROR", *history.Events[2].OldStatus)
+	assert.Equal(t, "PROC", history.Events[2].NewStatus)
+
+	_, err = service.GetTransactionHistory(ctx, 99)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not found")
+}
+
+func TestTransactionService_StreamTransactions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	var stored []*repositories.Transaction
+	for i := 1; i <= 7; i++ {
+		stored = append(stored, &repositories.Transaction{
+			ID:              int64(i),
+			PortfolioID:     testPortfolioID,
+			SourceID:        fmt.Sprintf("STREAM-%d", i),
+			Status:          models.TransactionStatusProc.String(),
+			TransactionType: models.TransactionTypeDep.String(),
+			Quantity:        decimal.NewFromInt(int64(i)),
+			Price:           decimal.NewFromInt(1),
+			TransactionDate: now,
+			Version:         1,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		})
+	}
+	txnRepo := &pagingTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo(stored...)}
+	service := NewTransactionService(txnRepo, nil, domainServices.TransactionProcessor{}, domainServices.TransactionValidator{},
+		mappers.NewTransactionMapper(), TransactionServiceConfig{StreamPageSize: 3}, logger.NewNoop())
+
+	var ids []int64
+	count, err := service.StreamTransactions(ctx, dto.TransactionFilter{Pagination: dto.PaginationRequest{Limit: 2}},
+		func(txn dto.TransactionResponseDTO) error {
+			ids = append(ids, txn.ID)
+			return nil
+		})
+	require.NoError(t, err)
+
+	assert.Equal(t, 7, count)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7}, ids, "pagination of the filter is ignored")
+	assert.Equal(t, 3, txnRepo.pages, "the result is read one cursor page at a time")
+
+	stop := errors.New("client went away")
+	count, err = service.StreamTransactions(ctx, dto.TransactionFilter{}, func(txn dto.TransactionResponseDTO) error {
+		if txn.ID == 4 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 3, count)
+}
+
+// pagingTransactionRepo counts the pages read from the in-memory transaction repo
+type pagingTransactionRepo struct {
+	*fakeTransactionRepo
+
+	pages int
+}
+
+func (r *pagingTransactionRepo) List(ctx context.Context, filter repositories.TransactionFilter) ([]*repositories.Transaction, error) {
+	r.pages++
+	return r.fakeTransactionRepo.List(ctx, filter)
+}
+
+func TestTransactionService_GetTransactionsByIDs(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	var stored []*repositories.Transaction
+	for _, id := range []int64{1, 2, 3} {
+		stored = append(stored, &repositories.Transaction{
+			ID:              id,
+			PortfolioID:     testPortfolioID,
+			SourceID:        fmt.Sprintf("BATCH-GET-%d", id),
+			Status:          models.TransactionStatusProc.String(),
+			TransactionType: models.TransactionTypeDep.String(),
+			Quantity:        decimal.NewFromInt(id),
+			Price:           decimal.NewFromInt(1),
+			TransactionDate: now,
+			Version:         1,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		})
+	}
+	txnRepo := &pagingTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo(stored...)}
+	service := NewTransactionService(txnRepo, nil, domainServices.TransactionProcessor{}, domainServices.TransactionValidator{},
+		mappers.NewTransactionMapper(), TransactionServiceConfig{MaxBatchGetIDs: 4}, logger.NewNoop())
+
+	t.Run("Mixed found and not found", func(t *testing.T) {
+		txnRepo.pages = 0
+		response, err := service.GetTransactionsByIDs(ctx, []int64{3, 99, 1, 3, 42})
+		require.NoError(t, err)
+
+		var ids []int64
+		for _, txn := range response.Transactions {
+			ids = append(ids, txn.ID)
+		}
+		assert.Equal(t, []int64{3, 1}, ids, "found transactions keep the request order and appear once")
+		assert.Equal(t, []int64{99, 42}, response.NotFoundIDs)
+		assert.Equal(t, 1, txnRepo.pages, "all transactions are read with one query")
+	})
+
+	t.Run("None found", func(t *testing.T) {
+		response, err := service.GetTransactionsByIDs(ctx, []int64{7})
+		require.NoError(t, err)
+		assert.Empty(t, response.Transactions)
+		assert.NotNil(t, response.Transactions)
+		assert.Equal(t, []int64{7}, response.NotFoundIDs)
+	})
+
+	t.Run("Cap counts distinct IDs", func(t *testing.T) {
+		_, err := service.GetTransactionsByIDs(ctx, []int64{1, 2, 3, 4, 4, 4})
+		require.NoError(t, err)
+
+		_, err = service.GetTransactionsByIDs(ctx, []int64{1, 2, 3, 4, 5})
+		var tooLarge *BatchGetTooLargeError
+		require.ErrorAs(t, err, &tooLarge)
+		assert.Equal(t, 5, tooLarge.Requested)
+		assert.Equal(t, 4, tooLarge.Max)
+	})
+}
+
+func (r *fakeTransactionRepo) GetBySourceIDs(ctx context.Context, sourceIDs []string) ([]*repositories.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	requested := make(map[string]bool, len(sourceIDs))
+	for _, sourceID := range sourceIDs {
+		requested[sourceID] = true
+	}
+
+	var result []*repositories.Transaction
+	for _, txn := range r.transactions {
+		if requested[txn.SourceID] {
+			clone := *txn
+			result = append(result, &clone)
+		}
+	}
+	return result, nil
+}
+
+// sourceBatchTransactionRepo counts the source ID batch queries made against the in-memory repo
+type sourceBatchTransactionRepo struct {
+	*fakeTransactionRepo
+
+	queries int
+}
+
+func (r *sourceBatchTransactionRepo) GetBySourceIDs(ctx context.Context, sourceIDs []string) ([]*repositories.Transaction, error) {
+	r.queries++
+	return r.fakeTransactionRepo.GetBySourceIDs(ctx, sourceIDs)
+}
+
+func TestTransactionService_GetTransactionsBySourceIDs(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	var stored []*repositories.Transaction
+	for _, id := range []int64{1, 2, 3} {
+		stored = append(stored, &repositories.Transaction{
+			ID:              id,
+			PortfolioID:     testPortfolioID,
+			SourceID:        fmt.Sprintf("SOURCE-GET-%d", id),
+			Status:          models.TransactionStatusProc.String(),
+			TransactionType: models.TransactionTypeDep.String(),
+			Quantity:        decimal.NewFromInt(id),
+			Price:           decimal.NewFromInt(1),
+			TransactionDate: now,
+			Version:         1,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		})
+	}
+	txnRepo := &sourceBatchTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo(stored...)}
+	service := NewTransactionService(txnRepo, nil, domainServices.TransactionProcessor{}, domainServices.TransactionValidator{},
+		mappers.NewTransactionMapper(), TransactionServiceConfig{MaxBatchGetIDs: 4}, logger.NewNoop())
+
+	t.Run("Mixed found and not found", func(t *testing.T) {
+		txnRepo.queries = 0
+		response, err := service.GetTransactionsBySourceIDs(ctx, []string{"SOURCE-GET-3", "UNKNOWN-1", "SOURCE-GET-1", "SOURCE-GET-3", "UNKNOWN-2"})
+		require.NoError(t, err)
+
+		var sourceIDs []string
+		for _, txn := range response.Transactions {
+			sourceIDs = append(sourceIDs, txn.SourceID)
+		}
+		assert.Equal(t, []string{"SOURCE-GET-3", "SOURCE-GET-1"}, sourceIDs, "found transactions keep the request order and appear once")
+		assert.Equal(t, []string{"UNKNOWN-1", "UNKNOWN-2"}, response.NotFoundSourceIDs)
+		assert.Equal(t, 1, txnRepo.queries, "all transactions are read with one query")
+	})
+
+	t.Run("None found", func(t *testing.T) {
+		response, err := service.GetTransactionsBySourceIDs(ctx, []string{"UNKNOWN-1"})
+		require.NoError(t, err)
+		assert.NotNil(t, response.Transactions)
+		assert.Empty(t, response.Transactions)
+		assert.Equal(t, []string{"UNKNOWN-1"}, response.NotFoundSourceIDs)
+	})
+
+	t.Run("Cap counts distinct source IDs", func(t *testing.T) {
+		_, err := service.GetTransactionsBySourceIDs(ctx, []string{"A", "B", "C", "D", "D"})
+		require.NoError(t, err)
+
+		_, err = service.GetTransactionsBySourceIDs(ctx, []string{"A", "B", "C", "D", "E"})
+		var tooLarge *BatchGetTooLargeError
+		require.ErrorAs(t, err, &tooLarge)
+		assert.Equal(t, 5, tooLarge.Requested)
+		assert.Equal(t, 4, tooLarge.Max)
+	})
+}
+
+func (r *fakeTransactionRepo) GetLatestTransactionPerPortfolio(ctx context.Context, portfolioIDs []string) ([]*repositories.Transaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	requested := make(map[string]bool, len(portfolioIDs))
+	for _, portfolioID := range portfolioIDs {
+		requested[portfolioID] = true
+	}
+
+	latest := make(map[string]*repositories.Transaction)
+	for _, txn := range r.transactions {
+		if !requested[txn.PortfolioID] {
+			continue
+		}
+		current, ok := latest[txn.PortfolioID]
+		if !ok || txn.CreatedAt.After(current.CreatedAt) || (txn.CreatedAt.Equal(current.CreatedAt) && txn.ID > current.ID) {
+			latest[txn.PortfolioID] = txn
+		}
+	}
+
+	var result []*repositories.Transaction
+	for _, txn := range latest {
+		result = append(result, txn)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].PortfolioID < result[j].PortfolioID })
+	return result, nil
+}
+
+func TestTransactionService_GetLatestTransactions(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	portfolio1 := "PORTFOLIO000000000000001"
+	portfolio2 := "PORTFOLIO000000000000002"
+
+	newTransaction := func(id int64, portfolioID string, createdAt time.Time) *repositories.Transaction {
+		return &repositories.Transaction{
+			ID:              id,
+			PortfolioID:     portfolioID,
+			SourceID:        fmt.Sprintf("LATEST-%d", id),
+			Status:          models.TransactionStatusProc.String(),
+			TransactionType: models.TransactionTypeDep.String(),
+			Quantity:        decimal.NewFromInt(id),
+			Price:           decimal.NewFromInt(1),
+			TransactionDate: base,
+			Version:         1,
+			CreatedAt:       createdAt,
+			UpdatedAt:       createdAt,
+		}
+	}
+	txnRepo := newFakeTransactionRepo(
+		newTransaction(1, portfolio1, base),
+		newTransaction(2, portfolio1, base.Add(2*time.Hour)),
+		newTransaction(3, portfolio1, base.Add(time.Hour)),
+		newTransaction(4, portfolio2, base.Add(time.Hour)),
+		newTransaction(5, portfolio2, base),
+	)
+	service := NewTransactionService(txnRepo, nil, domainServices.TransactionProcessor{}, domainServices.TransactionValidator{},
+		mappers.NewTransactionMapper(), TransactionServiceConfig{MaxBatchGetIDs: 3}, logger.NewNoop())
+
+	t.Run("Latest transaction of each portfolio in request order", func(t *testing.T) {
+		response, err := service.GetLatestTransactions(ctx, []string{portfolio2, "PORTFOLIO000000000000099", portfolio1, portfolio2})
+		require.NoError(t, err)
+
+		var ids []int64
+		for _, txn := range response.Transactions {
+			ids = append(ids, txn.ID)
+		}
+		assert.Equal(t, []int64{4, 2}, ids)
+		assert.Equal(t, []string{"PORTFOLIO000000000000099"}, response.PortfoliosWithoutTransactions)
+	})
+
+	t.Run("No portfolios", func(t *testing.T) {
+		response, err := service.GetLatestTransactions(ctx, nil)
+		require.NoError(t, err)
+		assert.NotNil(t, response.Transactions)
+		assert.Empty(t, response.Transactions)
+		assert.Empty(t, response.PortfoliosWithoutTransactions)
+	})
+
+	t.Run("Cap counts distinct portfolios", func(t *testing.T) {
+		_, err := service.GetLatestTransactions(ctx, []string{"A", "B", "C", "D"})
+		var tooLarge *BatchGetTooLargeError
+		require.ErrorAs(t, err, &tooLarge)
+		assert.Equal(t, 4, tooLarge.Requested)
+		assert.Equal(t, 3, tooLarge.Max)
+	})
+}
+
+// concurrencyTrackingTransactionRepo records how many source ID lookups and creates run at once
+type concurrencyTrackingTransactionRepo struct {
+	*fakeTransactionRepo
+
+	lookupDelay time.Duration
+
+	mu             sync.Mutex
+	lookups        int
+	maxLookups     int
+	creates        int
+	maxCreates     int
+	createdSources []string
+	nextID         int64
+
+	dailyLimitMu sync.Mutex
+	// beforeCreate runs before CreateWithinDailyLimit takes its lock
+	beforeCreate func()
+}
+
+func (r *concurrencyTrackingTransactionRepo) track(inFlight, max *int, delta int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*inFlight += delta
+	if *inFlight > *max {
+		*max = *inFlight
+	}
+}
+
+func (r *concurrencyTrackingTransactionRepo) GetBySourceID(ctx context.Context, sourceID string) (*repositories.Transaction, error) {
+	r.track(&r.lookups, &r.maxLookups, 1)
+	defer r.track(&r.lookups, &r.maxLookups, -1)
+
+	time.Sleep(r.lookupDelay)
+	return r.fakeTransactionRepo.GetBySourceID(ctx, sourceID)
+}
+
+func (r *concurrencyTrackingTransactionRepo) Create(ctx context.Context, transaction *repositories.Transaction) error {
+	r.track(&r.creates, &r.maxCreates, 1)
+	defer r.track(&r.creates, &r.maxCreates, -1)
+
+	r.mu.Lock()
+	r.nextID++
+	transaction.ID = r.nextID
+	transaction.Version = 1
+	r.createdSources = append(r.createdSources, transaction.SourceID)
+	r.mu.Unlock()
+
+	clone := *transaction
+	r.fakeTransactionRepo.mu.Lock()
+	r.fakeTransactionRepo.transactions[clone.ID] = &clone
+	r.fakeTransactionRepo.mu.Unlock()
+	return nil
+}
+
+// CreateWithinDailyLimit counts and creates under the repo lock, like the database advisory lock
+func (r *concurrencyTrackingTransactionRepo) CreateWithinDailyLimit(ctx context.Context, transaction *repositories.Transaction, limit int) error {
+	if r.beforeCreate != nil {
+		r.beforeCreate()
+	}
+
+	r.dailyLimitMu.Lock()
+	defer r.dailyLimitMu.Unlock()
+
+	booked, err := r.Count(ctx, repositories.TransactionFilter{
+		PortfolioID:     &transaction.PortfolioID,
+		TransactionDate: &transaction.TransactionDate,
+	})
+	if err != nil {
+		return err
+	}
+	if booked >= int64(limit) {
+		return repositories.NewDailyLimitReachedError(transaction.PortfolioID, transaction.TransactionDate.Format("20060102"), limit)
+	}
+	return r.Create(ctx, transaction)
+}
+
+func TestTransactionService_CreateTransactionsValidatesConcurrently(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	txnRepo := &concurrencyTrackingTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo(), lookupDelay: 20 * time.Millisecond}
+	balanceRepo := &flakyBalanceRepo{cash: &repositories.Balance{
+		ID:            10,
+		PortfolioID:   testPortfolioID,
+		QuantityLong:  decimal.Zero,
+		QuantityShort: decimal.Zero,
+		Version:       1,
+		CreatedAt:     now,
+		LastUpdated:   now,
+	}}
+
+	lg := logger.NewNoop()
+	validator := domainServices.NewTransactionValidator(txnRepo, balanceRepo, lg)
+	processor := domainServices.NewTransactionProcessor(txnRepo, balanceRepo, validator,
+		domainServices.NewBalanceCalculator(balanceRepo, lg), lg)
+	service := NewTransactionService(txnRepo, balanceRepo, *processor, *validator,
+		mappers.NewTransactionMapper(), TransactionServiceConfig{ValidationConcurrency: 4}, lg)
+
+	// Every deposit goes to the same portfolio and its single cash balance
+	deposits := make([]dto.TransactionPostDTO, 8)
+	var expectedSources []string
+	for i := range deposits {
+		deposits[i] = validDeposit()
+		deposits[i].SourceID = fmt.Sprintf("DEP-CONCURRENT-%d", i)
+		deposits[i].Quantity = decimal.NewFromInt(int64(100 * (i + 1)))
+		expectedSources = append(expectedSources, deposits[i].SourceID)
+	}
+
+	result, err := service.CreateTransactions(ctx, deposits, BatchOptions{})
+	require.NoError(t, err)
+	require.Empty(t, result.Failed)
+	require.Len(t, result.Successful, len(deposits))
+
+	txnRepo.mu.Lock()
+	defer txnRepo.mu.Unlock()
+	assert.Greater(t, txnRepo.maxLookups, 1, "validation runs in parallel")
+	assert.LessOrEqual(t, txnRepo.maxLookups, 4, "validation stays within the configured concurrency")
+	assert.Equal(t, 1, txnRepo.maxCreates, "writes never overlap")
+	assert.Equal(t, expectedSources, txnRepo.createdSources, "writes follow the batch order")
+
+	// 100 + 200 + ... + 800, with no update lost to a concurrent write
+	assert.True(t, decimal.NewFromInt(3600).Equal(balanceRepo.cashLong()), "cash balance %s", balanceRepo.cashLong())
+}
+
+func TestTransactionService_GetTransactions_ClampsLimit(t *testing.T) {
+	ctx := context.Background()
+	txnRepo := newFakeTransactionRepo()
+	for id := int64(1); id <= dto.MaxPageLimit+1; id++ {
+		txnRepo.transactions[id] = &repositories.Transaction{
+			ID:              id,
+			PortfolioID:     testPortfolioID,
+			SourceID:        fmt.Sprintf("DEP-%d", id),
+			Status:          "PROC",
+			TransactionType: "DEP",
+			Quantity:        decimal.NewFromInt(100),
+			Price:           decimal.NewFromInt(1),
+			TransactionDate: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			Version:         1,
+		}
+	}
+	service := newValidationService(txnRepo)
+
+	t.Run("Over the maximum is clamped and reported", func(t *testing.T) {
+		result, err := service.GetTransactions(ctx, dto.TransactionFilter{Pagination: dto.PaginationRequest{Limit: 5000}})
+		require.NoError(t, err)
+
+		assert.Len(t, result.Transactions, dto.MaxPageLimit)
+		assert.Equal(t, dto.MaxPageLimit, result.Pagination.Limit)
+		assert.Equal(t, 5000, result.Pagination.RequestedLimit)
+		assert.True(t, result.Pagination.LimitClamped)
+		assert.Equal(t, int64(dto.MaxPageLimit+1), result.Pagination.Total)
+	})
+
+	t.Run("Within the maximum is not reported", func(t *testing.T) {
+		result, err := service.GetTransactions(ctx, dto.TransactionFilter{Pagination: dto.PaginationRequest{Limit: 10}})
+		require.NoError(t, err)
+
+		assert.Len(t, result.Transactions, 10)
+		assert.Equal(t, 10, result.Pagination.Limit)
+		assert.Zero(t, result.Pagination.RequestedLimit)
+		assert.False(t, result.Pagination.LimitClamped)
+	})
+}
+
+// knownPortfolioChecker knows the listed portfolios and every security
+type knownPortfolioChecker map[string]bool
+
+func (c knownPortfolioChecker) PortfolioExists(ctx context.Context, portfolioID string) (bool, error) {
+	return c[portfolioID], nil
+}
+
+func (c knownPortfolioChecker) SecurityExists(ctx context.Context, securityID string) (bool, error) {
+	return true, nil
+}
+
+func TestTransactionService_ReleaseQuarantinedTransactions(t *testing.T) {
+	ctx := context.Background()
+	const unknownPortfolioID = "PORTFOLIO000000000000000"
+
+	now := time.Now()
+	quarantined := func(id int64, portfolioID string) *repositories.Transaction {
+		return &repositories.Transaction{
+			ID:              id,
+			PortfolioID:     portfolioID,
+			SourceID:        fmt.Sprintf("DEP-QUARANTINE-%d", id),
+			Status:          models.TransactionStatusQuarantined.String(),
+			TransactionType: models.TransactionTypeDep.String(),
+			Quantity:        decimal.NewFromInt(250),
+			Price:           decimal.NewFromInt(1),
+			TransactionDate: now,
+			Version:         1,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+	}
+	txnRepo := newFakeTransactionRepo(quarantined(1, unknownPortfolioID), quarantined(2, testPortfolioID))
+	balanceRepo := &flakyBalanceRepo{
+		cash: &repositories.Balance{
+			ID:            10,
+			PortfolioID:   testPortfolioID,
+			QuantityLong:  decimal.NewFromInt(1000),
+			QuantityShort: decimal.Zero,
+			Version:       1,
+			CreatedAt:     now,
+			LastUpdated:   now,
+		},
+	}
+
+	lg := logger.NewNoop()
+	validator := domainServices.NewTransactionValidator(txnRepo, balanceRepo, lg).
+		WithUnknownReferencePolicy(knownPortfolioChecker{testPortfolioID: true}, domainServices.UnknownReferenceQuarantine)
+	processor := domainServices.NewTransactionProcessor(txnRepo, balanceRepo, validator,
+		domainServices.NewBalanceCalculator(balanceRepo, lg), lg)
+	service := NewTransactionService(txnRepo, balanceRepo, *processor, *validator,
+		mappers.NewTransactionMapper(), TransactionServiceConfig{}, lg)
+
+	response, err := service.ReleaseQuarantinedTransactions(ctx, 10)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, response.Checked)
+	assert.Equal(t, []int64{1}, response.StillQuarantined)
+	require.Len(t, response.Released, 1)
+	assert.Equal(t, int64(2), response.Released[0].TransactionID)
+	assert.Equal(t, models.TransactionStatusProc.String(), response.Released[0].Status)
+	assert.True(t, response.Released[0].BalanceUpdated)
+
+	assert.Equal(t, models.TransactionStatusQuarantined.String(), txnRepo.get(1).Status)
+	assert.Equal(t, models.TransactionStatusProc.String(), txnRepo.get(2).Status)
+	assert.True(t, decimal.NewFromInt(1250).Equal(balanceRepo.cashLong()))
+}
+
+// releaseFailingTransactionRepo fails to move one transaction out of QUAR
+type releaseFailingTransactionRepo struct {
+	*fakeTransactionRepo
+
+	failID int64
+}
+
+func (r *releaseFailingTransactionRepo) UpdateStatus(ctx context.Context, id int64, status string, errorMessage *string, version int) error {
+	if id == r.failID {
+		return errors.New("connection reset")
+	}
+	return r.fakeTransactionRepo.UpdateStatus(ctx, id, status, errorMessage, version)
+}
+
+func TestTransactionService_ReleaseQuarantinedTransactionsPartialFailure(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Now()
+	quarantined := func(id int64) *repositories.Transaction {
+		return &repositories.Transaction{
+			ID:              id,
+			PortfolioID:     testPortfolioID,
+			SourceID:        fmt.Sprintf("DEP-QUARANTINE-%d", id),
+			Status:          models.TransactionStatusQuarantined.String(),
+			TransactionType: models.TransactionTypeDep.String(),
+			Quantity:        decimal.NewFromInt(250),
+			Price:           decimal.NewFromInt(1),
+			TransactionDate: now,
+			Version:         1,
+			CreatedAt:       now.Add(time.Duration(id) * time.Second),
+			UpdatedAt:       now,
+		}
+	}
+	txnRepo := &releaseFailingTransactionRepo{
+		fakeTransactionRepo: newFakeTransactionRepo(quarantined(1), quarantined(2)),
+		failID:              1,
+	}
+	balanceRepo := &flakyBalanceRepo{
+		cash: &repositories.Balance{
+			ID:            10,
+			PortfolioID:   testPortfolioID,
+			QuantityLong:  decimal.NewFromInt(1000),
+			QuantityShort: decimal.Zero,
+			Version:       1,
+			CreatedAt:     now,
+			LastUpdated:   now,
+		},
+	}
+
+	lg := logger.NewNoop()
+	validator := domainServices.NewTransactionValidator(txnRepo, balanceRepo, lg).
+		WithUnknownReferencePolicy(knownPortfolioChecker{testPortfolioID: true}, domainServices.UnknownReferenceQuarantine)
+	processor := domainServices.NewTransactionProcessor(txnRepo, balanceRepo, validator,
+		domainServices.NewBalanceCalculator(balanceRepo, lg), lg)
+	service := NewTransactionService(txnRepo, balanceRepo, *processor, *validator,
+		mappers.NewTransactionMapper(), TransactionServiceConfig{}, lg)
+
+	// The failed release is reported and the pass carries on with the next transaction
+	response, err := service.ReleaseQuarantinedTransactions(ctx, 10)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, response.Checked)
+	assert.Empty(t, response.StillQuarantined)
+	require.Len(t, response.Failed, 1)
+	assert.Equal(t, int64(1), response.Failed[0].TransactionID)
+	assert.Contains(t, response.Failed[0].Error, "connection reset")
+	require.Len(t, response.Released, 1)
+	assert.Equal(t, int64(2), response.Released[0].TransactionID)
+
+	assert.Equal(t, models.TransactionStatusQuarantined.String(), txnRepo.get(1).Status)
+	assert.Equal(t, models.TransactionStatusProc.String(), txnRepo.get(2).Status)
+}
+
+func TestTransactionService_CreateTransactionsDailyLimit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	newService := func() (*concurrencyTrackingTransactionRepo, TransactionService) {
+		// The portfolio already booked one transaction on the day
+		txnRepo := &concurrencyTrackingTransactionRepo{
+			fakeTransactionRepo: newFakeTransactionRepo(&repositories.Transaction{
+				ID:              100,
+				PortfolioID:     testPortfolioID,
+				SourceID:        "DEP-EXISTING",
+				Status:          models.TransactionStatusProc.String(),
+				TransactionType: models.TransactionTypeDep.String(),
+				Quantity:        decimal.NewFromInt(100),
+				Price:           decimal.NewFromInt(1),
+				TransactionDate: day,
+				Version:         1,
+			}),
+			nextID: 100,
+		}
+		balanceRepo := &flakyBalanceRepo{cash: &repositories.Balance{
+			ID:            10,
+			PortfolioID:   testPortfolioID,
+			QuantityLong:  decimal.NewFromInt(100),
+			QuantityShort: decimal.Zero,
+			Version:       1,
+			CreatedAt:     now,
+			LastUpdated:   now,
+		}}
+
+		lg := logger.NewNoop()
+		validator := domainServices.NewTransactionValidator(txnRepo, balanceRepo, lg).
+			WithDailyTransactionLimit(3, nil)
+		processor := domainServices.NewTransactionProcessor(txnRepo, balanceRepo, validator,
+			domainServices.NewBalanceCalculator(balanceRepo, lg), lg)
+		return txnRepo, NewTransactionService(txnRepo, balanceRepo, *processor, *validator,
+			mappers.NewTransactionMapper(), TransactionServiceConfig{}, lg)
+	}
+
+	// Three more deposits on the day exceed the limit of three by one; the next day is separate
+	batch := make([]dto.TransactionPostDTO, 4)
+	for i := range batch {
+		batch[i] = validDeposit()
+		batch[i].SourceID = fmt.Sprintf("DEP-LIMIT-%d", i)
+	}
+	batch[3].TransactionDate = "20240103"
+
+	t.Run("Transactions beyond the limit fail", func(t *testing.T) {
+		txnRepo, service := newService()
+
+		result, err := service.CreateTransactions(ctx, batch, BatchOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"DEP-LIMIT-0", "DEP-LIMIT-1", "DEP-LIMIT-3"}, txnRepo.createdSources)
+		assert.Equal(t, 3, result.Summary.Successful)
+		assert.Equal(t, 1, result.Summary.DailyLimitExceeded)
+		require.Len(t, result.Failed, 1)
+		require.NotNil(t, result.Failed[0].BatchIndex)
+		assert.Equal(t, 2, *result.Failed[0].BatchIndex)
+		require.Len(t, result.Failed[0].Errors, 1)
+		assert.Equal(t, "transactionDate", result.Failed[0].Errors[0].Field)
+		assert.Contains(t, result.Failed[0].Errors[0].Message, "limit of 3 transactions for 20240102")
+
+		// The limit holds for a later batch too
+		again := validDeposit()
+		again.SourceID = "DEP-LIMIT-4"
+		result, err = service.CreateTransactions(ctx, []dto.TransactionPostDTO{again}, BatchOptions{})
+		require.NoError(t, err)
+		assert.Zero(t, result.Summary.Successful)
+		assert.Equal(t, 1, result.Summary.DailyLimitExceeded)
+	})
+
+	t.Run("Strict batch rejects transactions beyond the limit", func(t *testing.T) {
+		txnRepo, service := newService()
+
+		_, err := service.CreateTransactionsStrict(ctx, batch, BatchOptions{})
+
+		var batchErr *BatchValidationError
+		require.ErrorAs(t, err, &batchErr)
+		require.Len(t, batchErr.Failed, 1)
+		assert.Equal(t, 2, batchErr.Failed[0].Index)
+		assert.Equal(t, "transactionDate", batchErr.Failed[0].Errors[0].Field)
+		assert.Equal(t, 1, batchErr.DailyLimitExceeded)
+		assert.Empty(t, txnRepo.createdSources)
+	})
+
+	t.Run("Concurrent batches cannot exceed the limit together", func(t *testing.T) {
+		txnRepo, service := newService()
+
+		// Each batch fits the two transactions left on the day on its own
+		var wg sync.WaitGroup
+		results := make([]*dto.TransactionBatchResponse, 4)
+		for b := range results {
+			wg.Add(1)
+			go func(b int) {
+				defer wg.Done()
+				batch := make([]dto.TransactionPostDTO, 2)
+				for i := range batch {
+					batch[i] = validDeposit()
+					batch[i].SourceID = fmt.Sprintf("DEP-RACE-%d-%d", b, i)
+				}
+				results[b], _ = service.CreateTransactions(ctx, batch, BatchOptions{})
+			}(b)
+		}
+		wg.Wait()
+
+		successful, limited := 0, 0
+		for _, result := range results {
+			require.NotNil(t, result)
+			successful += result.Summary.Successful
+			limited += result.Summary.DailyLimitExceeded
+		}
+		assert.Equal(t, 2, successful)
+		assert.Equal(t, 6, limited)
+		assert.Len(t, txnRepo.createdSources, 2)
+	})
+
+	t.Run("Strict batch rechecks the limit when creating", func(t *testing.T) {
+		txnRepo, service := newService()
+
+		// Another request takes the last transactions of the day after the strict batch was
+		// checked; the repository refuses the rest
+		txnRepo.beforeCreate = func() {
+			txnRepo.beforeCreate = nil
+			for i := 0; i < 2; i++ {
+				deposit := validDeposit()
+				deposit.SourceID = fmt.Sprintf("DEP-CONCURRENT-%d", i)
+				_, err := service.CreateTransaction(ctx, deposit)
+				require.NoError(t, err)
+			}
+		}
+
+		strict := []dto.TransactionPostDTO{validDeposit()}
+		strict[0].SourceID = "DEP-STRICT-0"
+		result, err := service.CreateTransactionsStrict(ctx, strict, BatchOptions{})
+		require.NoError(t, err)
+		assert.Zero(t, result.Summary.Successful)
+		assert.Equal(t, 1, result.Summary.DailyLimitExceeded)
+		assert.Equal(t, []string{"DEP-CONCURRENT-0", "DEP-CONCURRENT-1"}, txnRepo.createdSources)
+	})
+
+	t.Run("Single transaction beyond the limit", func(t *testing.T) {
+		txnRepo, service := newService()
+		for i := 0; i < 2; i++ {
+			deposit := validDeposit()
+			deposit.SourceID = fmt.Sprintf("DEP-SINGLE-%d", i)
+			_, err := service.CreateTransaction(ctx, deposit)
+			require.NoError(t, err)
+		}
+
+		deposit := validDeposit()
+		deposit.SourceID = "DEP-SINGLE-2"
+		_, err := service.CreateTransaction(ctx, deposit)
+
+		var limitErr *domainServices.DailyLimitExceededError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Equal(t, 3, limitErr.Limit)
+		assert.Len(t, txnRepo.createdSources, 2)
+	})
+}
+
+func TestTransactionService_CreateTransactionsTiming(t *testing.T) {
+	now := time.Now()
+	newService := func() TransactionService {
+		txnRepo := &concurrencyTrackingTransactionRepo{fakeTransactionRepo: newFakeTransactionRepo()}
+		balanceRepo := &flakyBalanceRepo{cash: &repositories.Balance{
+			ID:            10,
+			PortfolioID:   testPortfolioID,
+			QuantityLong:  decimal.NewFromInt(100),
+			QuantityShort: decimal.Zero,
+			Version:       1,
+			CreatedAt:     now,
+			LastUpdated:   now,
+		}}
+
+		lg := logger.NewNoop()
+		validator := domainServices.NewTransactionValidator(txnRepo, balanceRepo, lg)
+		processor := domainServices.NewTransactionProcessor(txnRepo, balanceRepo, validator,
+			domainServices.NewBalanceCalculator(balanceRepo, lg), lg)
+		return NewTransactionService(txnRepo, balanceRepo, *processor, *validator,
+			mappers.NewTransactionMapper(), TransactionServiceConfig{}, lg)
+	}
+
+	// Two deposits succeed; the other portfolio's record fails validation
+	batch := make([]dto.TransactionPostDTO, 3)
+	for i := range batch {
+		batch[i] = validDeposit()
+		batch[i].SourceID = fmt.Sprintf("DEP-TIMING-%d", i)
+	}
+	batch[1].PortfolioID = "PORTFOLIO000000000000001"
+	batch[1].Quantity = decimal.NewFromInt(-1)
+
+	t.Run("Timing is reported on request", func(t *testing.T) {
+		result, err := newService().CreateTransactions(context.Background(), batch, BatchOptions{Timing: true})
+		require.NoError(t, err)
+
+		timing := result.Summary.Timing
+		require.NotNil(t, timing)
+		assert.Positive(t, timing.DurationMs)
+		assert.Positive(t, timing.RecordsPerSecond)
+		assert.Equal(t, []dto.PortfolioBatchSummaryDTO{
+			{PortfolioID: "PORTFOLIO000000000000001", Failed: 1},
+			{PortfolioID: testPortfolioID, Successful: 2},
+		}, timing.Portfolios)
+	})
+
+	t.Run("Strict batch reports timing on request", func(t *testing.T) {
+		valid := []dto.TransactionPostDTO{batch[0], batch[2]}
+		result, err := newService().CreateTransactionsStrict(context.Background(), valid, BatchOptions{Timing: true})
+		require.NoError(t, err)
+
+		require.NotNil(t, result.Summary.Timing)
+		assert.Positive(t, result.Summary.Timing.DurationMs)
+		assert.Equal(t, []dto.PortfolioBatchSummaryDTO{
+			{PortfolioID: testPortfolioID, Successful: 2},
+		}, result.Summary.Timing.Portfolios)
+	})
+
+	t.Run("Timing is omitted by default", func(t *testing.T) {
+		result, err := newService().CreateTransactions(context.Background(), batch, BatchOptions{})
+		require.NoError(t, err)
+		assert.Nil(t, result.Summary.Timing)
+	})
+}
+
+// pricePtr returns a posted transaction price
+func pricePtr(price decimal.Decimal) *decimal.Decimal {
+	return &price
+}
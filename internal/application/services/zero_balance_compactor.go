@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// compactionMeterName is the instrumentation scope of the zero balance compaction metrics
+const compactionMeterName = "globeco-portfolio-accounting-service/compaction"
+
+// ZeroBalanceCompactor periodically deletes security balances that have been zero for a long
+// time. Cash balances are never deleted.
+type ZeroBalanceCompactor interface {
+	// Lifecycle operations
+	Start(ctx context.Context)
+	Stop()
+
+	// RunOnce performs a single compaction pass
+	RunOnce(ctx context.Context) (*ZeroBalanceCompactionResult, error)
+}
+
+// ZeroBalanceCompactorConfig holds configuration for the zero balance compactor
+type ZeroBalanceCompactorConfig struct {
+	Interval time.Duration
+	// MinimumAge is how long a balance must have been left unchanged to be deleted
+	MinimumAge time.Duration
+	// BatchSize is the number of balances deleted per statement
+	BatchSize int
+	// MaxBatchesPerRun bounds the statements of a single pass; the rest waits for the next one
+	MaxBatchesPerRun int
+	// MeterProvider records the deleted balances; nil uses the global provider
+	MeterProvider metric.MeterProvider
+}
+
+// ZeroBalanceCompactionResult summarizes a single compaction pass
+type ZeroBalanceCompactionResult struct {
+	Cutoff  time.Time `json:"cutoff"`
+	Batches int       `json:"batches"`
+	Deleted int64     `json:"deleted"`
+}
+
+// zeroBalanceCompactor implements ZeroBalanceCompactor interface
+type zeroBalanceCompactor struct {
+	balanceRepo repositories.BalanceRepository
+	config      ZeroBalanceCompactorConfig
+	logger      logger.Logger
+	now         func() time.Time
+	deleted     metric.Int64Counter
+
+	job *periodicJob
+}
+
+// NewZeroBalanceCompactor creates a new background zero balance compactor
+func NewZeroBalanceCompactor(
+	balanceRepo repositories.BalanceRepository,
+	config ZeroBalanceCompactorConfig,
+	lg logger.Logger,
+) ZeroBalanceCompactor {
+	if lg == nil {
+		lg = logger.NewDevelopment()
+	}
+
+	// Set default configuration
+	if config.Interval == 0 {
+		config.Interval = time.Hour
+	}
+	if config.MinimumAge == 0 {
+		config.MinimumAge = 30 * 24 * time.Hour
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = 500
+	}
+	if config.MaxBatchesPerRun == 0 {
+		config.MaxBatchesPerRun = 20
+	}
+
+	provider := config.MeterProvider
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	deleted, err := provider.Meter(compactionMeterName).Int64Counter(
+		"zero_balances_compacted_total",
+		metric.WithDescription("Total number of zero balances deleted by compaction"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		lg.Warn("Failed to create zero balance compaction counter", logger.Err(err))
+	}
+
+	compactor := &zeroBalanceCompactor{
+		balanceRepo: balanceRepo,
+		config:      config,
+		logger:      lg,
+		now:         time.Now,
+		deleted:     deleted,
+	}
+	compactor.job = newPeriodicJob(config.Interval, func(ctx context.Context) error {
+		_, err := compactor.RunOnce(ctx)
+		return err
+	}, "Zero balance compaction pass failed", lg)
+
+	return compactor
+}
+
+// Start launches the periodic compaction loop until Stop is called or ctx is cancelled
+func (c *zeroBalanceCompactor) Start(ctx context.Context) {
+	if !c.job.Start(ctx) {
+		return
+	}
+
+	c.logger.Info("Starting zero balance compactor",
+		logger.String("interval", c.config.Interval.String()),
+		logger.String("minimumAge", c.config.MinimumAge.String()),
+		logger.Int("batchSize", c.config.BatchSize),
+		logger.Int("maxBatchesPerRun", c.config.MaxBatchesPerRun))
+}
+
+// Stop stops the compaction loop and waits for an in-flight pass to finish
+func (c *zeroBalanceCompactor) Stop() {
+	if c.job.Stop() {
+		c.logger.Info("Zero balance compactor stopped")
+	}
+}
+
+// RunOnce deletes zero security balances older than the minimum age in batches until none are
+// left or the pass has used its batches. Batches already deleted stay deleted if a later one
+// fails.
+func (c *zeroBalanceCompactor) RunOnce(ctx context.Context) (*ZeroBalanceCompactionResult, error) {
+	result := &ZeroBalanceCompactionResult{Cutoff: c.now().UTC().Add(-c.config.MinimumAge)}
+
+	for result.Batches < c.config.MaxBatchesPerRun {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		deleted, err := c.balanceRepo.DeleteZeroBalancesBefore(ctx, result.Cutoff, c.config.BatchSize)
+		result.Batches++
+		result.Deleted += deleted
+		c.record(ctx, deleted)
+		if err != nil {
+			return result, fmt.Errorf("failed to delete zero balances after %d deletions: %w", result.Deleted, err)
+		}
+
+		if deleted < int64(c.config.BatchSize) {
+			break
+		}
+	}
+
+	if result.Deleted > 0 {
+		c.logger.Info("Zero balance compaction pass completed",
+			logger.String("cutoff", result.Cutoff.Format(time.RFC3339)),
+			logger.Int("batches", result.Batches),
+			logger.Int64("deleted", result.Deleted))
+	}
+
+	return result, nil
+}
+
+// record adds deleted balances to the compaction counter
+func (c *zeroBalanceCompactor) record(ctx context.Context, deleted int64) {
+	if c.deleted != nil && deleted > 0 {
+		c.deleted.Add(ctx, deleted)
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// compactionBalanceRepo serves DeleteZeroBalancesBefore from an in-memory set of balances
+type compactionBalanceRepo struct {
+	repositories.BalanceRepository
+
+	mu       sync.Mutex
+	balances map[int64]*repositories.Balance
+	cutoffs  []time.Time
+	limits   []int
+	failOn   int // fails the nth delete call when positive
+}
+
+func newCompactionBalanceRepo(balances ...*repositories.Balance) *compactionBalanceRepo {
+	repo := &compactionBalanceRepo{balances: make(map[int64]*repositories.Balance)}
+	for _, balance := range balances {
+		repo.balances[balance.ID] = balance
+	}
+	return repo
+}
+
+func (r *compactionBalanceRepo) DeleteZeroBalancesBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cutoffs = append(r.cutoffs, cutoff)
+	r.limits = append(r.limits, limit)
+	if r.failOn == len(r.limits) {
+		return 0, errors.New("connection reset")
+	}
+
+	var candidates []*repositories.Balance
+	for _, balance := range r.balances {
+		if balance.SecurityID != nil && balance.QuantityLong.IsZero() && balance.QuantityShort.IsZero() &&
+			balance.LastUpdated.Before(cutoff) {
+			candidates = append(candidates, balance)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].LastUpdated.Before(candidates[j].LastUpdated) })
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	for _, balance := range candidates {
+		delete(r.balances, balance.ID)
+	}
+	return int64(len(candidates)), nil
+}
+
+// remaining returns the IDs of the balances still stored, in ascending order
+func (r *compactionBalanceRepo) remaining() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]int64, 0, len(r.balances))
+	for id := range r.balances {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestZeroBalanceCompactor_RunOnce(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	security := "SECURITY1234567890123456"
+	balance := func(id int64, securityID *string, quantityLong int64, age time.Duration) *repositories.Balance {
+		return &repositories.Balance{
+			ID:            id,
+			PortfolioID:   "PORTFOLIO123456789012345",
+			SecurityID:    securityID,
+			QuantityLong:  decimal.NewFromInt(quantityLong),
+			QuantityShort: decimal.Zero,
+			LastUpdated:   now.Add(-age),
+		}
+	}
+	day := 24 * time.Hour
+
+	newRepo := func() *compactionBalanceRepo {
+		return newCompactionBalanceRepo(
+			balance(1, &security, 0, 90*day),   // old zero position
+			balance(2, &security, 0, 45*day),   // old zero position
+			balance(3, &security, 0, 31*day),   // old zero position
+			balance(4, &security, 0, 10*day),   // zero but too recent
+			balance(5, &security, 100, 90*day), // old open position
+			balance(6, nil, 0, 90*day),         // old zero cash
+		)
+	}
+	newCompactor := func(repo *compactionBalanceRepo, config ZeroBalanceCompactorConfig) *zeroBalanceCompactor {
+		config.MinimumAge = 30 * day
+		compactor := NewZeroBalanceCompactor(repo, config, logger.NewNoop()).(*zeroBalanceCompactor)
+		compactor.now = func() time.Time { return now }
+		return compactor
+	}
+
+	t.Run("Deletes only old zero security balances", func(t *testing.T) {
+		repo := newRepo()
+		compactor := newCompactor(repo, ZeroBalanceCompactorConfig{BatchSize: 10})
+
+		result, err := compactor.RunOnce(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, now.Add(-30*day), result.Cutoff)
+		assert.Equal(t, int64(3), result.Deleted)
+		assert.Equal(t, 1, result.Batches)
+		assert.Equal(t, []int64{4, 5, 6}, repo.remaining())
+	})
+
+	t.Run("Deletes in batches until a short batch", func(t *testing.T) {
+		repo := newRepo()
+		compactor := newCompactor(repo, ZeroBalanceCompactorConfig{BatchSize: 2})
+
+		result, err := compactor.RunOnce(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(3), result.Deleted)
+		assert.Equal(t, 2, result.Batches)
+		assert.Equal(t, []int{2, 2}, repo.limits)
+		assert.Equal(t, []int64{4, 5, 6}, repo.remaining())
+	})
+
+	t.Run("Stops after the maximum batches of a pass", func(t *testing.T) {
+		repo := newRepo()
+		compactor := newCompactor(repo, ZeroBalanceCompactorConfig{BatchSize: 1, MaxBatchesPerRun: 2})
+
+		result, err := compactor.RunOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Deleted)
+		assert.Equal(t, 2, result.Batches)
+		// The oldest go first; the rest waits for the next pass
+		assert.Equal(t, []int64{3, 4, 5, 6}, repo.remaining())
+
+		result, err = compactor.RunOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), result.Deleted)
+		assert.Equal(t, []int64{4, 5, 6}, repo.remaining())
+	})
+
+	t.Run("Failing batch keeps the earlier deletions", func(t *testing.T) {
+		repo := newRepo()
+		repo.failOn = 2
+		compactor := newCompactor(repo, ZeroBalanceCompactorConfig{BatchSize: 1})
+
+		result, err := compactor.RunOnce(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "after 1 deletions")
+		assert.Equal(t, int64(1), result.Deleted)
+		assert.Equal(t, []int64{2, 3, 4, 5, 6}, repo.remaining())
+	})
+
+	t.Run("Counts deleted balances", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		repo := newRepo()
+		compactor := newCompactor(repo, ZeroBalanceCompactorConfig{
+			BatchSize:     2,
+			MeterProvider: sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		})
+
+		_, err := compactor.RunOnce(ctx)
+		require.NoError(t, err)
+
+		var collected metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(ctx, &collected))
+		var total int64
+		for _, scope := range collected.ScopeMetrics {
+			for _, m := range scope.Metrics {
+				if m.Name != "zero_balances_compacted_total" {
+					continue
+				}
+				sum, ok := m.Data.(metricdata.Sum[int64])
+				require.True(t, ok)
+				for _, point := range sum.DataPoints {
+					total += point.Value
+				}
+			}
+		}
+		assert.Equal(t, int64(3), total)
+	})
+}
+
+func TestZeroBalanceCompactor_StartStop(t *testing.T) {
+	repo := newCompactionBalanceRepo()
+	compactor := NewZeroBalanceCompactor(repo, ZeroBalanceCompactorConfig{Interval: 5 * time.Millisecond}, logger.NewNoop())
+
+	compactor.Start(context.Background())
+	compactor.Start(context.Background()) // already running
+	assert.Eventually(t, func() bool {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		return len(repo.limits) > 0
+	}, time.Second, 5*time.Millisecond)
+
+	compactor.Stop()
+	compactor.Stop() // already stopped
+}
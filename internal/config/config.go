@@ -1,7 +1,9 @@
 package config
 
 import (
+	"crypto/tls"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 
 // Config holds all configuration for our application
 type Config struct {
+	App      AppConfig      `mapstructure:"app"`
 	Server   ServerConfig   `mapstructure:"server"`
 	Database DatabaseConfig `mapstructure:"database"`
 	Cache    CacheConfig    `mapstructure:"cache"`
@@ -18,6 +21,21 @@ type Config struct {
 	Metrics  MetricsConfig  `mapstructure:"metrics"`
 	Tracing  TracingConfig  `mapstructure:"tracing"`
 	External ExternalConfig `mapstructure:"external"`
+
+	Reprocessing ReprocessingConfig `mapstructure:"reprocessing"`
+	Retention    RetentionConfig    `mapstructure:"retention"`
+	Compaction   CompactionConfig   `mapstructure:"compaction"`
+	Validation   ValidationConfig   `mapstructure:"validation"`
+	Transactions TransactionsConfig `mapstructure:"transactions"`
+	Balances     BalancesConfig     `mapstructure:"balances"`
+
+	FileProcessing FileProcessingConfig `mapstructure:"file_processing"`
+}
+
+// AppConfig holds settings describing the deployment of the service
+type AppConfig struct {
+	// Environment names the deployment environment reported by the health endpoints
+	Environment string `mapstructure:"environment"`
 }
 
 // ServerConfig holds HTTP server configuration
@@ -28,6 +46,58 @@ type ServerConfig struct {
 	WriteTimeout            time.Duration `mapstructure:"write_timeout"`
 	IdleTimeout             time.Duration `mapstructure:"idle_timeout"`
 	GracefulShutdownTimeout time.Duration `mapstructure:"graceful_shutdown_timeout"`
+	// ShutdownDrainPeriod is how long readiness reports draining before the server stops
+	// accepting connections, so load balancers can deregister the instance first
+	ShutdownDrainPeriod time.Duration `mapstructure:"shutdown_drain_period"`
+	HealthCheckCacheTTL time.Duration `mapstructure:"health_check_cache_ttl"`
+	// HealthCheckTimeout bounds the concurrent external service checks of /health/detailed
+	HealthCheckTimeout time.Duration `mapstructure:"health_check_timeout"`
+
+	// Connection tuning
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	MaxHeaderBytes    int           `mapstructure:"max_header_bytes"`
+	KeepAlivesEnabled bool          `mapstructure:"keep_alives_enabled"`
+	// MaxInFlight is the most requests served at once; further requests are shed with 503
+	// until one completes. Health and metrics endpoints are exempt. 0 is unlimited.
+	MaxInFlight int `mapstructure:"max_in_flight"`
+
+	// TLSCertFile and TLSKeyFile serve HTTPS when both are set
+	TLSCertFile string `mapstructure:"tls_cert_file"`
+	TLSKeyFile  string `mapstructure:"tls_key_file"`
+	// TLSMinVersion is the oldest TLS version HTTPS accepts: "1.2" or "1.3"
+	TLSMinVersion string `mapstructure:"tls_min_version"`
+	// HTTPRedirectPort, with TLS, answers plain HTTP on this port with a redirect to HTTPS;
+	// 0 leaves plain HTTP unanswered
+	HTTPRedirectPort int         `mapstructure:"http_redirect_port"`
+	HTTP2            HTTP2Config `mapstructure:"http2"`
+
+	// DecimalEncoding writes quantities, prices and amounts as JSON strings ("string", keeps
+	// every digit) or as JSON numbers ("number")
+	DecimalEncoding string `mapstructure:"decimal_encoding"`
+}
+
+// HTTP2Config holds HTTP/2 configuration. Without TLS, HTTP/2 is served in cleartext (h2c)
+// for clients and proxies that speak it with prior knowledge or upgrade to it.
+type HTTP2Config struct {
+	Enabled              bool   `mapstructure:"enabled"`
+	MaxConcurrentStreams uint32 `mapstructure:"max_concurrent_streams"`
+}
+
+// TLSEnabled reports whether the server is configured to serve HTTPS
+func (c ServerConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// MinTLSVersion returns the crypto/tls constant of TLSMinVersion; empty means TLS 1.2
+func (c ServerConfig) MinTLSVersion() (uint16, error) {
+	switch c.TLSMinVersion {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("invalid server TLS minimum version: %s (must be 1.2 or 1.3)", c.TLSMinVersion)
+	}
 }
 
 // DatabaseConfig holds database configuration
@@ -43,6 +113,18 @@ type DatabaseConfig struct {
 	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
 	MigrationsPath  string        `mapstructure:"migrations_path"`
 	AutoMigrate     bool          `mapstructure:"auto_migrate"`
+	// CashSecurityID stores cash balances under this 24-character sentinel security ID
+	// instead of NULL when set
+	CashSecurityID string `mapstructure:"cash_security_id"`
+	// MaxListFilterSize caps the values of an ID list filter (IDs, portfolio IDs, security IDs)
+	// in a single query; 0 disables the cap
+	MaxListFilterSize int `mapstructure:"max_list_filter_size"`
+	// StatementTimeout is set as the Postgres statement_timeout of every pooled connection, so the
+	// server cancels a statement that runs longer even if its context is never cancelled; 0 disables it
+	StatementTimeout time.Duration `mapstructure:"statement_timeout"`
+	// MaxWriteTransactionsFraction is the share of MaxOpenConns that write transactions may hold
+	// at once; further transactions wait for a slot so reads keep connections. 0 disables the limit
+	MaxWriteTransactionsFraction float64 `mapstructure:"max_write_transactions_fraction"`
 }
 
 // CacheConfig holds cache configuration
@@ -53,6 +135,16 @@ type CacheConfig struct {
 	Database int           `mapstructure:"database"`
 	TTL      time.Duration `mapstructure:"ttl"`
 	Timeout  time.Duration `mapstructure:"timeout"`
+
+	// OperationTimeout bounds each attempt of a cache read or write made while serving a request
+	OperationTimeout time.Duration `mapstructure:"operation_timeout"`
+	// OperationRetries is the number of retries of a cache call after a transient failure
+	OperationRetries int `mapstructure:"operation_retries"`
+	// RetryBackoff is the pause between cache call attempts
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+
+	// StatsTTL is how long stats endpoint responses are served from the cache; zero disables it
+	StatsTTL time.Duration `mapstructure:"stats_ttl"`
 }
 
 // KafkaConfig holds Kafka configuration
@@ -71,6 +163,9 @@ type LoggingConfig struct {
 	Format     string `mapstructure:"format"`
 	Output     string `mapstructure:"output"`
 	Structured bool   `mapstructure:"structured"`
+
+	// BalanceChanges logs every applied balance change at the given level: off, debug or info
+	BalanceChanges string `mapstructure:"balance_changes"`
 }
 
 // MetricsConfig holds metrics configuration
@@ -85,6 +180,10 @@ type MetricsConfig struct {
 type EnhancedMetricsConfig struct {
 	Enabled     bool   `mapstructure:"enabled"`
 	ServiceName string `mapstructure:"service_name"`
+	// Exemplars attaches the trace of requests taking at least ExemplarThreshold to their
+	// request duration observations; it needs tracing to be enabled
+	Exemplars         bool          `mapstructure:"exemplars"`
+	ExemplarThreshold time.Duration `mapstructure:"exemplar_threshold"`
 }
 
 // TracingConfig holds tracing configuration
@@ -106,12 +205,195 @@ type ServiceConfig struct {
 	Host                    string        `mapstructure:"host"`
 	Port                    int           `mapstructure:"port"`
 	Timeout                 time.Duration `mapstructure:"timeout"`
+	CallTimeout             time.Duration `mapstructure:"call_timeout"`
 	MaxRetries              int           `mapstructure:"max_retries"`
 	RetryBackoff            time.Duration `mapstructure:"retry_backoff"`
 	CircuitBreakerThreshold int           `mapstructure:"circuit_breaker_threshold"`
 	HealthEndpoint          string        `mapstructure:"health_endpoint"`
 }
 
+// ReprocessingConfig holds background reprocessing configuration for transiently failed transactions
+type ReprocessingConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	Interval       time.Duration `mapstructure:"interval"`
+	BatchSize      int           `mapstructure:"batch_size"`
+	MaxAttempts    int           `mapstructure:"max_attempts"`
+	InitialBackoff time.Duration `mapstructure:"initial_backoff"`
+	MaxBackoff     time.Duration `mapstructure:"max_backoff"`
+	// AllowForced lets processing run again on PROC transactions, reversing their earlier
+	// balance impact first; off by default
+	AllowForced bool `mapstructure:"allow_forced"`
+}
+
+// RetentionConfig holds configuration for deleting transactions past their retention window
+type RetentionConfig struct {
+	// Enabled registers the admin retention endpoint; it is off by default
+	Enabled bool `mapstructure:"enabled"`
+	// MinimumAgeDays is how many days in the past a deletion cutoff must at least be
+	MinimumAgeDays int `mapstructure:"minimum_age_days"`
+	// BatchSize is the number of transactions deleted per statement
+	BatchSize int `mapstructure:"batch_size"`
+}
+
+// CompactionConfig holds configuration for deleting long-standing zero balances in the background
+type CompactionConfig struct {
+	// Enabled runs the zero balance compactor; it is off by default
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"`
+	// MinimumAge is how long a zero security balance must have been unchanged to be deleted
+	MinimumAge time.Duration `mapstructure:"minimum_age"`
+	// BatchSize is the number of balances deleted per statement
+	BatchSize int `mapstructure:"batch_size"`
+	// MaxBatchesPerRun bounds the statements of a single compaction pass
+	MaxBatchesPerRun int `mapstructure:"max_batches_per_run"`
+}
+
+// ValidationConfig holds transaction validation rules
+type ValidationConfig struct {
+	// MaxFutureDays is how many days after today a transaction date may be; negative means unlimited
+	MaxFutureDays int `mapstructure:"max_future_days"`
+	// TransactionTypeAliases maps alternative transaction type names sent by upstream feeds,
+	// e.g. PURCHASE, to transaction types; names are matched case-insensitively
+	TransactionTypeAliases map[string]string `mapstructure:"transaction_type_aliases"`
+	// CashPriceAutoFill sets the price of DEP and WD transactions posted without one to 1.0
+	CashPriceAutoFill bool `mapstructure:"cash_price_auto_fill"`
+	// SourceIDPattern is a regular expression every source ID must match in full, e.g.
+	// SYS-\d{8}-\d+; empty accepts any source ID
+	SourceIDPattern string `mapstructure:"source_id_pattern"`
+	// UnknownReferencePolicy handles transactions whose portfolio or security the portfolio and
+	// security services do not know: off (not looked up), reject, warn or quarantine
+	UnknownReferencePolicy string `mapstructure:"unknown_reference_policy"`
+	// ReferenceLookupTimeout bounds each portfolio or security lookup made while validating a
+	// transaction, retries included; 0 uses the client's call timeout
+	ReferenceLookupTimeout time.Duration `mapstructure:"reference_lookup_timeout"`
+	// DailyTransactionLimit is how many transactions a portfolio may book for one transaction
+	// date; 0 is unlimited
+	DailyTransactionLimit int `mapstructure:"daily_transaction_limit"`
+	// DailyTransactionLimitOverrides sets the daily limit of individual portfolios, by portfolio
+	// ID matched case-insensitively; 0 leaves a portfolio unlimited
+	DailyTransactionLimitOverrides map[string]int `mapstructure:"daily_transaction_limit_overrides"`
+}
+
+// SourceIDRegexp compiles SourceIDPattern anchored to match whole source IDs. It returns nil
+// when no pattern is configured.
+func (c ValidationConfig) SourceIDRegexp() (*regexp.Regexp, error) {
+	if c.SourceIDPattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + c.SourceIDPattern + ")$")
+}
+
+// TransactionsConfig holds transaction query limits
+type TransactionsConfig struct {
+	// MaxBatchGetIDs is the largest number of transactions one batch get request, by ID or by
+	// source ID, or portfolios one latest transactions request, may name
+	MaxBatchGetIDs int `mapstructure:"max_batch_get_ids"`
+	// ValidationConcurrency is how many transactions of a batch are validated at a time
+	ValidationConcurrency int `mapstructure:"validation_concurrency"`
+	// ProcessingTimeout bounds the processing of one batch; records not reached in time are
+	// returned unprocessed
+	ProcessingTimeout time.Duration `mapstructure:"processing_timeout"`
+	// AbortOnInfrastructureError stops a batch with a retryable error when a transaction cannot be
+	// created because of a database failure, instead of failing that record and continuing
+	AbortOnInfrastructureError bool `mapstructure:"abort_on_infrastructure_error"`
+	// StoreNotionalAmount stores the notional amount of every processed transaction and
+	// backfills it on transactions processed earlier
+	StoreNotionalAmount bool `mapstructure:"store_notional_amount"`
+	// NotionalBackfillBatchSize is the number of transactions the backfill updates per statement
+	NotionalBackfillBatchSize int `mapstructure:"notional_backfill_batch_size"`
+}
+
+// BalancesConfig holds balance query limits
+type BalancesConfig struct {
+	// DefaultSummarySecurities is the page of security positions a portfolio summary returns
+	// when no limit is requested
+	DefaultSummarySecurities int `mapstructure:"default_summary_securities"`
+	// MaxSummarySecurities is the largest page of security positions a portfolio summary returns
+	MaxSummarySecurities int `mapstructure:"max_summary_securities"`
+	// MaxSummaryPortfolios is the largest number of portfolios one summaries request covers
+	MaxSummaryPortfolios int `mapstructure:"max_summary_portfolios"`
+	// EmptySummaryNotFound returns 404 for a portfolio without balances instead of a zeroed summary
+	EmptySummaryNotFound bool `mapstructure:"empty_summary_not_found"`
+	// MissingBalanceNotFound returns 404 for a portfolio without a balance in the requested
+	// security or cash instead of a zeroed balance
+	MissingBalanceNotFound bool `mapstructure:"missing_balance_not_found"`
+	// DateBasis selects whether the trade or the settlement date drives balance replay
+	// ordering and as-of queries: trade or settlement
+	DateBasis string `mapstructure:"date_basis"`
+	// AdjustmentKeyMaxAge is how long an adjustment key is replayed; older keys are released
+	// by a background job so the request is applied again. 0 keeps keys forever.
+	AdjustmentKeyMaxAge time.Duration `mapstructure:"adjustment_key_max_age"`
+	// AdjustmentKeyCleanupInterval is how often expired adjustment keys are released
+	AdjustmentKeyCleanupInterval time.Duration `mapstructure:"adjustment_key_cleanup_interval"`
+	// RequireAdjustmentReason rejects balance updates (PUT /balance/{id} and bulk updates)
+	// that do not give a reason
+	RequireAdjustmentReason bool `mapstructure:"require_adjustment_reason"`
+	// LedgerMaxWindowDays is the most days, first and last included, one portfolio ledger
+	// request may cover
+	LedgerMaxWindowDays int `mapstructure:"ledger_max_window_days"`
+}
+
+// FileProcessingConfig holds transaction file processing configuration
+type FileProcessingConfig struct {
+	// WorkingDirectory is where transaction files are read from
+	WorkingDirectory string `mapstructure:"working_directory"`
+	// ErrorDirectory is where error files for rejected records are written
+	ErrorDirectory string `mapstructure:"error_directory"`
+	// MaxFileSize is the largest transaction file in bytes that is processed or validated
+	MaxFileSize int64 `mapstructure:"max_file_size"`
+	// MaxProcessingDuration bounds a single processing run; zero means unlimited
+	MaxProcessingDuration time.Duration `mapstructure:"max_processing_duration"`
+	// ProgressDirectory holds the progress markers unfinished files resume from; empty uses
+	// a .progress directory inside the working directory
+	ProgressDirectory string `mapstructure:"progress_directory"`
+	// DecimalSeparator separates the integer and fractional digits of quantities and prices
+	DecimalSeparator string `mapstructure:"decimal_separator"`
+	// ThousandsSeparator groups the integer digits of quantities and prices; empty allows no grouping
+	ThousandsSeparator string `mapstructure:"thousands_separator"`
+	// ErrorFileNaming names error files: "fixed" (<base>-errors.csv, replaced by every run),
+	// "timestamp" or "run_id" (a file per run)
+	ErrorFileNaming string `mapstructure:"error_file_naming"`
+	// SlowBatchThreshold is how long a batch may take before it is logged as slow; zero
+	// turns the warning off
+	SlowBatchThreshold time.Duration `mapstructure:"slow_batch_threshold"`
+	// StrictFieldCount fails rows whose number of fields differs from the header's instead of
+	// leaving missing fields blank
+	StrictFieldCount bool `mapstructure:"strict_field_count"`
+}
+
+// FeatureFlags is the set of optional features and whether each is turned on. Read it through
+// Config.Flags rather than the individual enabled settings.
+type FeatureFlags struct {
+	Kafka           bool `json:"kafka"`
+	Caching         bool `json:"caching"`
+	Metrics         bool `json:"metrics"`
+	EnhancedMetrics bool `json:"enhancedMetrics"`
+	// Exemplars attach trace IDs to enhanced metrics; they need tracing to be on
+	Exemplars    bool `json:"exemplars"`
+	Tracing      bool `json:"tracing"`
+	Reprocessing bool `json:"reprocessing"`
+	Retention    bool `json:"retention"`
+	Compaction   bool `json:"compaction"`
+	// StrictCSV fails transaction file rows whose field count differs from the header's
+	StrictCSV bool `json:"strictCsv"`
+}
+
+// Flags returns the feature flags derived from the configuration
+func (c *Config) Flags() FeatureFlags {
+	return FeatureFlags{
+		Kafka:           c.Kafka.Enabled,
+		Caching:         c.Cache.Enabled,
+		Metrics:         c.Metrics.Enabled,
+		EnhancedMetrics: c.Metrics.Enhanced.Enabled,
+		Exemplars:       c.Metrics.Enhanced.Exemplars && c.Tracing.Enabled,
+		Tracing:         c.Tracing.Enabled,
+		Reprocessing:    c.Reprocessing.Enabled,
+		Retention:       c.Retention.Enabled,
+		Compaction:      c.Compaction.Enabled,
+		StrictCSV:       c.FileProcessing.StrictFieldCount,
+	}
+}
+
 // Load loads configuration from multiple sources
 func Load() (*Config, error) {
 	viper.SetConfigName("config")
@@ -146,6 +428,9 @@ func Load() (*Config, error) {
 
 // setDefaults sets default configuration values
 func setDefaults() {
+	// Application defaults
+	viper.SetDefault("app.environment", "development")
+
 	// Server defaults
 	viper.SetDefault("server.host", "0.0.0.0")
 	viper.SetDefault("server.port", 8087)
@@ -153,6 +438,20 @@ func setDefaults() {
 	viper.SetDefault("server.write_timeout", "30s")
 	viper.SetDefault("server.idle_timeout", "120s")
 	viper.SetDefault("server.graceful_shutdown_timeout", "30s")
+	viper.SetDefault("server.shutdown_drain_period", "0s")
+	viper.SetDefault("server.health_check_cache_ttl", "2s")
+	viper.SetDefault("server.health_check_timeout", "5s")
+	viper.SetDefault("server.read_header_timeout", "10s")
+	viper.SetDefault("server.max_header_bytes", 1<<20)
+	viper.SetDefault("server.keep_alives_enabled", true)
+	viper.SetDefault("server.max_in_flight", 0)
+	viper.SetDefault("server.tls_cert_file", "")
+	viper.SetDefault("server.tls_key_file", "")
+	viper.SetDefault("server.tls_min_version", "1.2")
+	viper.SetDefault("server.http_redirect_port", 0)
+	viper.SetDefault("server.http2.enabled", false)
+	viper.SetDefault("server.http2.max_concurrent_streams", 250)
+	viper.SetDefault("server.decimal_encoding", "string")
 
 	// Database defaults
 	viper.SetDefault("database.host", "globeco-portfolio-accounting-service-postgresql")
@@ -166,6 +465,10 @@ func setDefaults() {
 	viper.SetDefault("database.conn_max_lifetime", "15m")
 	viper.SetDefault("database.migrations_path", "migrations")
 	viper.SetDefault("database.auto_migrate", true)
+	viper.SetDefault("database.cash_security_id", "")
+	viper.SetDefault("database.max_list_filter_size", 1000)
+	viper.SetDefault("database.statement_timeout", "0s")
+	viper.SetDefault("database.max_write_transactions_fraction", 0.5)
 
 	// Cache defaults
 	viper.SetDefault("cache.enabled", true)
@@ -174,6 +477,10 @@ func setDefaults() {
 	viper.SetDefault("cache.database", 0)
 	viper.SetDefault("cache.ttl", "1h")
 	viper.SetDefault("cache.timeout", "5s")
+	viper.SetDefault("cache.operation_timeout", "250ms")
+	viper.SetDefault("cache.operation_retries", 1)
+	viper.SetDefault("cache.retry_backoff", "25ms")
+	viper.SetDefault("cache.stats_ttl", "30s")
 
 	// Kafka defaults
 	viper.SetDefault("kafka.enabled", false)
@@ -188,6 +495,7 @@ func setDefaults() {
 	viper.SetDefault("logging.format", "json")
 	viper.SetDefault("logging.output", "stdout")
 	viper.SetDefault("logging.structured", true)
+	viper.SetDefault("logging.balance_changes", "off")
 
 	// Metrics defaults
 	viper.SetDefault("metrics.enabled", true)
@@ -195,6 +503,8 @@ func setDefaults() {
 	viper.SetDefault("metrics.port", 9090)
 	viper.SetDefault("metrics.enhanced.enabled", true)
 	viper.SetDefault("metrics.enhanced.service_name", "globeco-portfolio-accounting-service")
+	viper.SetDefault("metrics.enhanced.exemplars", false)
+	viper.SetDefault("metrics.enhanced.exemplar_threshold", "500ms")
 
 	// Tracing defaults
 	viper.SetDefault("tracing.enabled", true)
@@ -206,6 +516,7 @@ func setDefaults() {
 	viper.SetDefault("external.portfolio_service.host", "globeco-portfolio-service")
 	viper.SetDefault("external.portfolio_service.port", 8000)
 	viper.SetDefault("external.portfolio_service.timeout", "30s")
+	viper.SetDefault("external.portfolio_service.call_timeout", "0s")
 	viper.SetDefault("external.portfolio_service.max_retries", 3)
 	viper.SetDefault("external.portfolio_service.retry_backoff", "1s")
 	viper.SetDefault("external.portfolio_service.circuit_breaker_threshold", 5)
@@ -214,24 +525,140 @@ func setDefaults() {
 	viper.SetDefault("external.security_service.host", "globeco-security-service")
 	viper.SetDefault("external.security_service.port", 8000)
 	viper.SetDefault("external.security_service.timeout", "30s")
+	viper.SetDefault("external.security_service.call_timeout", "0s")
 	viper.SetDefault("external.security_service.max_retries", 3)
 	viper.SetDefault("external.security_service.retry_backoff", "1s")
 	viper.SetDefault("external.security_service.circuit_breaker_threshold", 5)
 	viper.SetDefault("external.security_service.health_endpoint", "/health/liveness")
+
+	// Reprocessing defaults
+	viper.SetDefault("reprocessing.enabled", true)
+	viper.SetDefault("reprocessing.interval", "1m")
+	viper.SetDefault("reprocessing.batch_size", 100)
+	viper.SetDefault("reprocessing.max_attempts", 3)
+	viper.SetDefault("reprocessing.initial_backoff", "30s")
+	viper.SetDefault("reprocessing.max_backoff", "30m")
+	viper.SetDefault("reprocessing.allow_forced", false)
+
+	// Retention defaults
+	viper.SetDefault("retention.enabled", false)
+	viper.SetDefault("retention.minimum_age_days", 365)
+	viper.SetDefault("retention.batch_size", 1000)
+
+	// Zero balance compaction defaults
+	viper.SetDefault("compaction.enabled", false)
+	viper.SetDefault("compaction.interval", "1h")
+	viper.SetDefault("compaction.minimum_age", "720h")
+	viper.SetDefault("compaction.batch_size", 500)
+	viper.SetDefault("compaction.max_batches_per_run", 20)
+
+	// Validation defaults
+	viper.SetDefault("validation.max_future_days", -1)
+	viper.SetDefault("validation.transaction_type_aliases", map[string]string{})
+	viper.SetDefault("validation.cash_price_auto_fill", false)
+	viper.SetDefault("validation.source_id_pattern", "")
+	viper.SetDefault("validation.unknown_reference_policy", "off")
+	viper.SetDefault("validation.reference_lookup_timeout", "2s")
+	viper.SetDefault("validation.daily_transaction_limit", 0)
+	viper.SetDefault("validation.daily_transaction_limit_overrides", map[string]int{})
+
+	// Balance defaults
+	viper.SetDefault("transactions.max_batch_get_ids", 100)
+	viper.SetDefault("transactions.validation_concurrency", 4)
+	viper.SetDefault("transactions.processing_timeout", "30s")
+	viper.SetDefault("transactions.abort_on_infrastructure_error", false)
+	viper.SetDefault("transactions.store_notional_amount", false)
+	viper.SetDefault("transactions.notional_backfill_batch_size", 1000)
+
+	viper.SetDefault("balances.default_summary_securities", 1000)
+	viper.SetDefault("balances.max_summary_securities", 1000)
+	viper.SetDefault("balances.max_summary_portfolios", 100)
+	viper.SetDefault("balances.empty_summary_not_found", false)
+	viper.SetDefault("balances.missing_balance_not_found", false)
+	viper.SetDefault("balances.date_basis", "trade")
+	viper.SetDefault("balances.adjustment_key_max_age", "0s")
+	viper.SetDefault("balances.adjustment_key_cleanup_interval", "1h")
+	viper.SetDefault("balances.require_adjustment_reason", false)
+	viper.SetDefault("balances.ledger_max_window_days", 366)
+
+	// File processing defaults
+	viper.SetDefault("file_processing.working_directory", "./data")
+	viper.SetDefault("file_processing.error_directory", "./data/errors")
+	viper.SetDefault("file_processing.max_file_size", 100*1024*1024)
+	viper.SetDefault("file_processing.max_processing_duration", "0s")
+	viper.SetDefault("file_processing.progress_directory", "")
+	viper.SetDefault("file_processing.decimal_separator", ".")
+	viper.SetDefault("file_processing.thousands_separator", "")
+	viper.SetDefault("file_processing.error_file_naming", "fixed")
+	viper.SetDefault("file_processing.slow_batch_threshold", "30s")
+	viper.SetDefault("file_processing.strict_field_count", false)
 }
 
 // DatabaseConnectionString returns the database connection string
 func (c *DatabaseConfig) ConnectionString() string {
-	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		c.Host, c.Port, c.User, c.Password, c.Database, c.SSLMode)
+	if c.StatementTimeout > 0 {
+		// Startup options apply to the session of each new connection the pool opens
+		connStr += fmt.Sprintf(" options='-c statement_timeout=%d'", c.StatementTimeout.Milliseconds())
+	}
+	return connStr
 }
 
-// Validate validates the configuration
+// Validate validates the configuration, including settings that contradict each other such as
+// an enabled feature missing what it needs to run. The service refuses to start if it fails.
 func (c *Config) Validate() error {
+	if c.Server.Host == "" {
+		return fmt.Errorf("server host is required")
+	}
+
 	if c.Server.Port <= 0 || c.Server.Port > 65535 {
 		return fmt.Errorf("invalid server port: %d", c.Server.Port)
 	}
 
+	if c.Server.HealthCheckCacheTTL < 0 {
+		return fmt.Errorf("invalid health check cache TTL: %s", c.Server.HealthCheckCacheTTL)
+	}
+
+	if c.Server.HealthCheckTimeout < 0 {
+		return fmt.Errorf("invalid health check timeout: %s", c.Server.HealthCheckTimeout)
+	}
+
+	if c.Server.ShutdownDrainPeriod < 0 {
+		return fmt.Errorf("invalid shutdown drain period: %s", c.Server.ShutdownDrainPeriod)
+	}
+
+	if c.Server.MaxHeaderBytes < 0 {
+		return fmt.Errorf("invalid server max header bytes: %d", c.Server.MaxHeaderBytes)
+	}
+
+	if c.Server.MaxInFlight < 0 {
+		return fmt.Errorf("invalid server max in flight: %d (must be 0 or positive)", c.Server.MaxInFlight)
+	}
+
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		return fmt.Errorf("server TLS requires both a certificate file and a key file")
+	}
+
+	if _, err := c.Server.MinTLSVersion(); err != nil {
+		return err
+	}
+
+	if c.Server.HTTPRedirectPort != 0 {
+		if !c.Server.TLSEnabled() {
+			return fmt.Errorf("server HTTP redirect port requires TLS")
+		}
+		if c.Server.HTTPRedirectPort < 0 || c.Server.HTTPRedirectPort > 65535 || c.Server.HTTPRedirectPort == c.Server.Port {
+			return fmt.Errorf("invalid server HTTP redirect port: %d", c.Server.HTTPRedirectPort)
+		}
+	}
+
+	switch c.Server.DecimalEncoding {
+	case "", "string", "number":
+	default:
+		return fmt.Errorf("invalid server decimal encoding: %s (must be string or number)", c.Server.DecimalEncoding)
+	}
+
 	if c.Database.Host == "" {
 		return fmt.Errorf("database host is required")
 	}
@@ -240,12 +667,238 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid database port: %d", c.Database.Port)
 	}
 
+	if c.Database.Database == "" {
+		return fmt.Errorf("database name is required")
+	}
+
+	if c.Database.CashSecurityID != "" && len(c.Database.CashSecurityID) != 24 {
+		return fmt.Errorf("invalid cash security ID sentinel: %q (must be exactly 24 characters)", c.Database.CashSecurityID)
+	}
+
+	if c.Database.MaxListFilterSize < 0 {
+		return fmt.Errorf("invalid database max list filter size: %d (must be 0 or positive)", c.Database.MaxListFilterSize)
+	}
+
+	if c.Database.StatementTimeout < 0 || (c.Database.StatementTimeout > 0 && c.Database.StatementTimeout < time.Millisecond) {
+		return fmt.Errorf("invalid database statement timeout: %s (must be 0 or at least 1ms)", c.Database.StatementTimeout)
+	}
+
+	if c.Database.MaxWriteTransactionsFraction < 0 || c.Database.MaxWriteTransactionsFraction > 1 {
+		return fmt.Errorf("invalid database max write transactions fraction: %g (must be between 0 and 1)", c.Database.MaxWriteTransactionsFraction)
+	}
+
 	if c.Cache.Enabled && c.Cache.Address == "" {
 		return fmt.Errorf("cache address is required when cache is enabled")
 	}
 
-	if c.Kafka.Enabled && len(c.Kafka.Brokers) == 0 {
-		return fmt.Errorf("kafka brokers are required when kafka is enabled")
+	if c.Cache.OperationTimeout < 0 || c.Cache.OperationRetries < 0 || c.Cache.RetryBackoff < 0 {
+		return fmt.Errorf("cache operation timeout, retries and retry backoff cannot be negative")
+	}
+
+	if c.Cache.StatsTTL < 0 {
+		return fmt.Errorf("cache stats TTL cannot be negative: %s", c.Cache.StatsTTL)
+	}
+
+	if c.Kafka.Enabled {
+		if len(c.Kafka.Brokers) == 0 {
+			return fmt.Errorf("kafka brokers are required when kafka is enabled")
+		}
+		for _, broker := range c.Kafka.Brokers {
+			if strings.TrimSpace(broker) == "" {
+				return fmt.Errorf("kafka brokers cannot contain an empty address")
+			}
+		}
+		if c.Kafka.Topic == "" {
+			return fmt.Errorf("kafka topic is required when kafka is enabled")
+		}
+	}
+
+	if c.Tracing.Enabled {
+		if c.Tracing.Endpoint == "" {
+			return fmt.Errorf("tracing endpoint is required when tracing is enabled")
+		}
+		if c.Tracing.ServiceName == "" {
+			return fmt.Errorf("tracing service name is required when tracing is enabled")
+		}
+		if c.Tracing.SampleRate < 0 || c.Tracing.SampleRate > 1 {
+			return fmt.Errorf("invalid tracing sample rate: %g (must be between 0 and 1)", c.Tracing.SampleRate)
+		}
+	}
+
+	if c.Metrics.Enhanced.Enabled && c.Metrics.Enhanced.ServiceName == "" {
+		return fmt.Errorf("enhanced metrics service name is required when enhanced metrics are enabled")
+	}
+
+	if c.Metrics.Enhanced.ExemplarThreshold < 0 {
+		return fmt.Errorf("invalid enhanced metrics exemplar threshold: %s", c.Metrics.Enhanced.ExemplarThreshold)
+	}
+
+	if c.Metrics.Enhanced.Exemplars && !c.Metrics.Enhanced.Enabled {
+		return fmt.Errorf("enhanced metrics exemplars require enhanced metrics to be enabled")
+	}
+	// Exemplars link observations to traces, so without tracing there is nothing to attach
+	if c.Metrics.Enhanced.Exemplars && !c.Tracing.Enabled {
+		return fmt.Errorf("enhanced metrics exemplars require tracing to be enabled")
+	}
+
+	switch c.Logging.BalanceChanges {
+	case "", "off", "debug", "info":
+	default:
+		return fmt.Errorf("invalid balance change logging level: %s (must be off, debug or info)", c.Logging.BalanceChanges)
+	}
+
+	if c.Reprocessing.Enabled {
+		if c.Reprocessing.Interval <= 0 {
+			return fmt.Errorf("reprocessing interval must be positive when reprocessing is enabled")
+		}
+		if c.Reprocessing.BatchSize <= 0 {
+			return fmt.Errorf("invalid reprocessing batch size: %d", c.Reprocessing.BatchSize)
+		}
+		// Transactions beyond three attempts are rejected by the processing validator
+		if c.Reprocessing.MaxAttempts <= 0 || c.Reprocessing.MaxAttempts > 3 {
+			return fmt.Errorf("invalid reprocessing max attempts: %d (must be between 1 and 3)", c.Reprocessing.MaxAttempts)
+		}
+		if c.Reprocessing.InitialBackoff < 0 || c.Reprocessing.MaxBackoff < c.Reprocessing.InitialBackoff {
+			return fmt.Errorf("invalid reprocessing backoff: initial %s, max %s", c.Reprocessing.InitialBackoff, c.Reprocessing.MaxBackoff)
+		}
+	}
+
+	if c.Retention.Enabled {
+		if c.Retention.MinimumAgeDays <= 0 {
+			return fmt.Errorf("retention minimum age must be positive when retention is enabled: %d days", c.Retention.MinimumAgeDays)
+		}
+		if c.Retention.BatchSize <= 0 {
+			return fmt.Errorf("invalid retention batch size: %d", c.Retention.BatchSize)
+		}
+	}
+
+	if c.Compaction.Enabled {
+		if c.Compaction.Interval <= 0 {
+			return fmt.Errorf("compaction interval must be positive when compaction is enabled")
+		}
+		if c.Compaction.MinimumAge <= 0 {
+			return fmt.Errorf("compaction minimum age must be positive when compaction is enabled: %s", c.Compaction.MinimumAge)
+		}
+		if c.Compaction.BatchSize <= 0 || c.Compaction.MaxBatchesPerRun <= 0 {
+			return fmt.Errorf("invalid compaction batch size %d or max batches per run %d", c.Compaction.BatchSize, c.Compaction.MaxBatchesPerRun)
+		}
+	}
+
+	if c.FileProcessing.MaxFileSize <= 0 {
+		return fmt.Errorf("file processing max file size must be positive: %d", c.FileProcessing.MaxFileSize)
+	}
+
+	for alias, transactionType := range c.Validation.TransactionTypeAliases {
+		switch strings.ToUpper(strings.TrimSpace(transactionType)) {
+		case "BUY", "SELL", "SHORT", "COVER", "DEP", "WD", "IN", "OUT":
+		default:
+			return fmt.Errorf("invalid transaction type alias %s: %q is not a transaction type", alias, transactionType)
+		}
+	}
+
+	if _, err := c.Validation.SourceIDRegexp(); err != nil {
+		return fmt.Errorf("invalid validation source ID pattern %q: %w", c.Validation.SourceIDPattern, err)
+	}
+
+	switch c.Validation.UnknownReferencePolicy {
+	case "", "off", "reject", "warn", "quarantine":
+	default:
+		return fmt.Errorf("invalid unknown reference policy: %s (must be off, reject, warn or quarantine)", c.Validation.UnknownReferencePolicy)
+	}
+
+	if c.Validation.ReferenceLookupTimeout < 0 {
+		return fmt.Errorf("validation reference lookup timeout cannot be negative: %s", c.Validation.ReferenceLookupTimeout)
+	}
+
+	if c.Validation.DailyTransactionLimit < 0 {
+		return fmt.Errorf("validation daily transaction limit cannot be negative: %d", c.Validation.DailyTransactionLimit)
+	}
+	for portfolioID, limit := range c.Validation.DailyTransactionLimitOverrides {
+		if limit < 0 {
+			return fmt.Errorf("invalid daily transaction limit for portfolio %s: %d cannot be negative", portfolioID, limit)
+		}
+	}
+
+	if c.Transactions.MaxBatchGetIDs <= 0 {
+		return fmt.Errorf("transactions max batch get IDs must be positive: %d", c.Transactions.MaxBatchGetIDs)
+	}
+	if c.Database.MaxListFilterSize > 0 && c.Transactions.MaxBatchGetIDs > c.Database.MaxListFilterSize {
+		return fmt.Errorf("transactions max batch get IDs (%d) exceeds database max list filter size (%d)",
+			c.Transactions.MaxBatchGetIDs, c.Database.MaxListFilterSize)
+	}
+
+	if c.Transactions.ValidationConcurrency <= 0 {
+		return fmt.Errorf("transactions validation concurrency must be positive: %d", c.Transactions.ValidationConcurrency)
+	}
+
+	if c.Transactions.ProcessingTimeout <= 0 {
+		return fmt.Errorf("transactions processing timeout must be positive: %s", c.Transactions.ProcessingTimeout)
+	}
+
+	if c.Transactions.NotionalBackfillBatchSize <= 0 {
+		return fmt.Errorf("transactions notional backfill batch size must be positive: %d", c.Transactions.NotionalBackfillBatchSize)
+	}
+
+	if c.Balances.MaxSummarySecurities <= 0 {
+		return fmt.Errorf("balances max summary securities must be positive: %d", c.Balances.MaxSummarySecurities)
+	}
+
+	if c.Balances.DefaultSummarySecurities <= 0 || c.Balances.DefaultSummarySecurities > c.Balances.MaxSummarySecurities {
+		return fmt.Errorf("balances default summary securities must be between 1 and max summary securities (%d): %d",
+			c.Balances.MaxSummarySecurities, c.Balances.DefaultSummarySecurities)
+	}
+
+	if c.Balances.MaxSummaryPortfolios <= 0 {
+		return fmt.Errorf("balances max summary portfolios must be positive: %d", c.Balances.MaxSummaryPortfolios)
+	}
+
+	if c.Balances.LedgerMaxWindowDays <= 0 {
+		return fmt.Errorf("balances ledger max window days must be positive: %d", c.Balances.LedgerMaxWindowDays)
+	}
+
+	switch c.Balances.DateBasis {
+	case "", "trade", "settlement":
+	default:
+		return fmt.Errorf("invalid balances date basis: %s (must be trade or settlement)", c.Balances.DateBasis)
+	}
+
+	if c.Balances.AdjustmentKeyMaxAge < 0 {
+		return fmt.Errorf("balances adjustment key max age must not be negative: %s", c.Balances.AdjustmentKeyMaxAge)
+	}
+
+	if c.Balances.AdjustmentKeyMaxAge > 0 && c.Balances.AdjustmentKeyCleanupInterval <= 0 {
+		return fmt.Errorf("balances adjustment key cleanup interval must be positive when keys expire: %s", c.Balances.AdjustmentKeyCleanupInterval)
+	}
+
+	if c.FileProcessing.MaxProcessingDuration < 0 {
+		return fmt.Errorf("file processing max processing duration must not be negative: %s", c.FileProcessing.MaxProcessingDuration)
+	}
+	if c.FileProcessing.SlowBatchThreshold < 0 {
+		return fmt.Errorf("file processing slow batch threshold must not be negative: %s", c.FileProcessing.SlowBatchThreshold)
+	}
+
+	switch c.FileProcessing.DecimalSeparator {
+	case "", ".", ",":
+	default:
+		return fmt.Errorf("invalid file processing decimal separator: %q (must be \".\" or \",\")", c.FileProcessing.DecimalSeparator)
+	}
+	switch c.FileProcessing.ThousandsSeparator {
+	case "", ".", ",", "'", " ":
+	default:
+		return fmt.Errorf("invalid file processing thousands separator: %q (must be empty, \".\", \",\", \"'\" or \" \")", c.FileProcessing.ThousandsSeparator)
+	}
+	decimalSeparator := c.FileProcessing.DecimalSeparator
+	if decimalSeparator == "" {
+		decimalSeparator = "."
+	}
+	if c.FileProcessing.ThousandsSeparator == decimalSeparator {
+		return fmt.Errorf("file processing thousands separator must differ from the decimal separator %q", decimalSeparator)
+	}
+
+	switch c.FileProcessing.ErrorFileNaming {
+	case "", "fixed", "timestamp", "run_id":
+	default:
+		return fmt.Errorf("invalid file processing error file naming: %q (must be fixed, timestamp or run_id)", c.FileProcessing.ErrorFileNaming)
 	}
 
 	return nil
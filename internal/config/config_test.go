@@ -2,8 +2,11 @@ package config
 
 import (
 	"testing"
+	"time"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEnhancedMetricsConfig(t *testing.T) {
@@ -51,4 +54,187 @@ func TestConfig_WithEnhancedMetrics(t *testing.T) {
 	assert.True(t, config.Metrics.Enabled)
 	assert.True(t, config.Metrics.Enhanced.Enabled)
 	assert.Equal(t, "test-service", config.Metrics.Enhanced.ServiceName)
-}
\ No newline at end of file
+}
+func TestConfig_Flags(t *testing.T) {
+	config := Config{
+		Cache:          CacheConfig{Enabled: true},
+		Kafka:          KafkaConfig{Enabled: false},
+		Metrics:        MetricsConfig{Enabled: false, Enhanced: EnhancedMetricsConfig{Enabled: true}},
+		Tracing:        TracingConfig{Enabled: true},
+		Reprocessing:   ReprocessingConfig{Enabled: true},
+		Retention:      RetentionConfig{Enabled: true},
+		Compaction:     CompactionConfig{Enabled: true},
+		FileProcessing: FileProcessingConfig{StrictFieldCount: true},
+	}
+	config.Metrics.Enhanced.Exemplars = true
+
+	assert.Equal(t, FeatureFlags{
+		Kafka:           false,
+		Caching:         true,
+		Metrics:         false,
+		EnhancedMetrics: true,
+		Exemplars:       true,
+		Tracing:         true,
+		Reprocessing:    true,
+		Retention:       true,
+		Compaction:      true,
+		StrictCSV:       true,
+	}, config.Flags())
+
+	// Exemplars carry trace IDs, so they are off without tracing
+	config.Tracing.Enabled = false
+	assert.False(t, config.Flags().Exemplars)
+}
+
+func TestDatabaseConfig_ConnectionString(t *testing.T) {
+	config := DatabaseConfig{
+		Host:     "db",
+		Port:     5432,
+		User:     "postgres",
+		Password: "secret",
+		Database: "accounting",
+		SSLMode:  "disable",
+	}
+	assert.Equal(t, "host=db port=5432 user=postgres password=secret dbname=accounting sslmode=disable", config.ConnectionString())
+
+	config.StatementTimeout = 30 * time.Second
+	assert.Equal(t, "host=db port=5432 user=postgres password=secret dbname=accounting sslmode=disable"+
+		" options='-c statement_timeout=30000'", config.ConnectionString())
+}
+
+func TestValidationConfig_SourceIDRegexp(t *testing.T) {
+	pattern, err := ValidationConfig{}.SourceIDRegexp()
+	require.NoError(t, err)
+	assert.Nil(t, pattern, "no pattern is enforced by default")
+
+	pattern, err = ValidationConfig{SourceIDPattern: `SYS-\d+|EXT-\d+`}.SourceIDRegexp()
+	require.NoError(t, err)
+	assert.True(t, pattern.MatchString("SYS-1"))
+	assert.True(t, pattern.MatchString("EXT-2"))
+	assert.False(t, pattern.MatchString("SYS-1-EXT-2"), "the pattern must match the whole source ID")
+	assert.False(t, pattern.MatchString("XSYS-1"))
+
+	_, err = ValidationConfig{SourceIDPattern: "SYS-("}.SourceIDRegexp()
+	assert.Error(t, err)
+}
+
+// defaultConfig returns the configuration the service runs with when nothing is configured
+func defaultConfig(t *testing.T) *Config {
+	t.Helper()
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	setDefaults()
+
+	var config Config
+	require.NoError(t, viper.Unmarshal(&config))
+	return &config
+}
+
+func TestConfig_Validate(t *testing.T) {
+	require.NoError(t, defaultConfig(t).Validate(), "the defaults must be a valid configuration")
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		err    string
+	}{
+		{
+			name:   "Server without a host",
+			modify: func(c *Config) { c.Server.Host = "" },
+			err:    "server host is required",
+		},
+		{
+			name:   "Database without a name",
+			modify: func(c *Config) { c.Database.Database = "" },
+			err:    "database name is required",
+		},
+		{
+			name:   "Cache enabled without an address",
+			modify: func(c *Config) { c.Cache.Address = "" },
+			err:    "cache address is required when cache is enabled",
+		},
+		{
+			name: "Kafka enabled without brokers",
+			modify: func(c *Config) {
+				c.Kafka.Enabled = true
+				c.Kafka.Brokers = nil
+			},
+			err: "kafka brokers are required when kafka is enabled",
+		},
+		{
+			name: "Kafka enabled with an empty broker",
+			modify: func(c *Config) {
+				c.Kafka.Enabled = true
+				c.Kafka.Brokers = []string{"kafka:9092", " "}
+			},
+			err: "kafka brokers cannot contain an empty address",
+		},
+		{
+			name: "Kafka enabled without a topic",
+			modify: func(c *Config) {
+				c.Kafka.Enabled = true
+				c.Kafka.Topic = ""
+			},
+			err: "kafka topic is required when kafka is enabled",
+		},
+		{
+			name:   "Tracing enabled without an endpoint",
+			modify: func(c *Config) { c.Tracing.Endpoint = "" },
+			err:    "tracing endpoint is required when tracing is enabled",
+		},
+		{
+			name:   "Tracing enabled without a service name",
+			modify: func(c *Config) { c.Tracing.ServiceName = "" },
+			err:    "tracing service name is required when tracing is enabled",
+		},
+		{
+			name:   "Tracing sample rate above one",
+			modify: func(c *Config) { c.Tracing.SampleRate = 1.5 },
+			err:    "invalid tracing sample rate: 1.5",
+		},
+		{
+			name:   "Enhanced metrics enabled without a service name",
+			modify: func(c *Config) { c.Metrics.Enhanced.ServiceName = "" },
+			err:    "enhanced metrics service name is required when enhanced metrics are enabled",
+		},
+		{
+			name: "Exemplars without enhanced metrics",
+			modify: func(c *Config) {
+				c.Metrics.Enhanced.Exemplars = true
+				c.Metrics.Enhanced.Enabled = false
+			},
+			err: "enhanced metrics exemplars require enhanced metrics to be enabled",
+		},
+		{
+			name: "Exemplars with tracing off",
+			modify: func(c *Config) {
+				c.Metrics.Enhanced.Exemplars = true
+				c.Tracing.Enabled = false
+			},
+			err: "enhanced metrics exemplars require tracing to be enabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := defaultConfig(t)
+			tt.modify(config)
+
+			err := config.Validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+
+	t.Run("Settings of disabled features are not checked", func(t *testing.T) {
+		config := defaultConfig(t)
+		config.Kafka.Brokers = nil
+		config.Kafka.Topic = ""
+		config.Tracing.Enabled = false
+		config.Tracing.Endpoint = ""
+		config.Cache.Enabled = false
+		config.Cache.Address = ""
+		assert.NoError(t, config.Validate())
+	})
+}
@@ -75,6 +75,9 @@ const (
 	TransactionStatusProc  TransactionStatus = "PROC"  // Processed successfully
 	TransactionStatusError TransactionStatus = "ERROR" // Non-fatal error, can be reprocessed
 	TransactionStatusFatal TransactionStatus = "FATAL" // Fatal error, cannot be reprocessed
+	TransactionStatusDead  TransactionStatus = "DEAD"  // Automatic reprocessing attempts exhausted
+	// Held until the portfolio or security it refers to is registered
+	TransactionStatusQuarantined TransactionStatus = "QUAR"
 )
 
 // AllTransactionStatuses returns all valid transaction statuses
@@ -84,6 +87,8 @@ func AllTransactionStatuses() []TransactionStatus {
 		TransactionStatusProc,
 		TransactionStatusError,
 		TransactionStatusFatal,
+		TransactionStatusDead,
+		TransactionStatusQuarantined,
 	}
 }
 
@@ -112,9 +117,23 @@ func (s TransactionStatus) CanBeReprocessed() bool {
 	return s == TransactionStatusNew || s == TransactionStatusError
 }
 
+// CanTransitionToProc reports whether processing may move a transaction in this status to PROC.
+// The permitted transitions are:
+//
+//   - NEW -> PROC: first processing
+//   - ERROR -> PROC: reprocessing after a non-fatal error
+//   - PROC -> PROC: forced reprocessing, only when allowForcedReprocess is set; the balance
+//     impact of the earlier processing must be reversed before the transaction is applied again
+//
+// FATAL, DEAD and QUAR transactions are never processed; a quarantined transaction is released
+// to NEW once its references exist.
+func (s TransactionStatus) CanTransitionToProc(allowForcedReprocess bool) bool {
+	return s.CanBeReprocessed() || (allowForcedReprocess && s == TransactionStatusProc)
+}
+
 // IsFinalState returns true if the status represents a final state
 func (s TransactionStatus) IsFinalState() bool {
-	return s == TransactionStatusProc || s == TransactionStatusFatal
+	return s == TransactionStatusProc || s == TransactionStatusFatal || s == TransactionStatusDead
 }
 
 // ParseTransactionStatus parses a string into a TransactionStatus
@@ -126,6 +145,33 @@ func ParseTransactionStatus(s string) (TransactionStatus, error) {
 	return status, nil
 }
 
+// DateBasis selects which transaction date drives balance timing
+type DateBasis string
+
+const (
+	DateBasisTrade      DateBasis = "trade"      // Positions change on the trade date
+	DateBasisSettlement DateBasis = "settlement" // Positions change on the settlement date
+)
+
+// String returns the string representation of the date basis
+func (d DateBasis) String() string {
+	return string(d)
+}
+
+// IsValid checks if the date basis is valid
+func (d DateBasis) IsValid() bool {
+	return d == DateBasisTrade || d == DateBasisSettlement
+}
+
+// ParseDateBasis parses a string into a DateBasis
+func ParseDateBasis(s string) (DateBasis, error) {
+	basis := DateBasis(strings.ToLower(strings.TrimSpace(s)))
+	if !basis.IsValid() {
+		return "", errors.New("invalid date basis")
+	}
+	return basis, nil
+}
+
 // BalanceImpact represents how a transaction type affects balances
 type BalanceImpact struct {
 	LongUnits  ImpactDirection // Impact on long position
@@ -3,6 +3,7 @@ package models
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -19,6 +20,7 @@ type Transaction struct {
 	quantity             Quantity
 	price                Price
 	transactionDate      time.Time
+	settlementDate       *time.Time
 	reprocessingAttempts int
 	version              int
 	createdAt            time.Time
@@ -173,6 +175,27 @@ func (b *TransactionBuilder) WithTransactionDateFromString(dateStr string) *Tran
 	return b
 }
 
+// WithSettlementDate sets the optional settlement date
+func (b *TransactionBuilder) WithSettlementDate(date *time.Time) *TransactionBuilder {
+	if date == nil {
+		b.transaction.settlementDate = nil
+		return b
+	}
+	settlementDate := date.UTC().Truncate(24 * time.Hour)
+	b.transaction.settlementDate = &settlementDate
+	return b
+}
+
+// WithSettlementDateFromString sets the settlement date from YYYYMMDD string
+func (b *TransactionBuilder) WithSettlementDateFromString(dateStr string) *TransactionBuilder {
+	date, err := time.Parse("20060102", dateStr)
+	if err != nil {
+		b.errors = append(b.errors, fmt.Errorf("invalid settlement date format (expected YYYYMMDD): %w", err))
+		return b
+	}
+	return b.WithSettlementDate(&date)
+}
+
 // WithVersion sets the version for optimistic locking
 func (b *TransactionBuilder) WithVersion(version int) *TransactionBuilder {
 	if version < 1 {
@@ -269,6 +292,11 @@ func (b *TransactionBuilder) validateBusinessRules() error {
 		return errors.New("price must be positive")
 	}
 
+	// Rule: A transaction cannot settle before it trades
+	if t.settlementDate != nil && t.settlementDate.Before(t.transactionDate) {
+		return errors.New("settlement date cannot be before transaction date")
+	}
+
 	return nil
 }
 
@@ -319,6 +347,20 @@ func (t *Transaction) TransactionDate() time.Time {
 	return t.transactionDate
 }
 
+// SettlementDate returns the settlement date, or nil if the transaction has none
+func (t *Transaction) SettlementDate() *time.Time {
+	return t.settlementDate
+}
+
+// EffectiveDate returns the date on which the transaction affects balances under the given
+// basis. Transactions without a settlement date settle on their transaction date.
+func (t *Transaction) EffectiveDate(basis DateBasis) time.Time {
+	if basis == DateBasisSettlement && t.settlementDate != nil {
+		return *t.settlementDate
+	}
+	return t.transactionDate
+}
+
 // ReprocessingAttempts returns the number of reprocessing attempts
 func (t *Transaction) ReprocessingAttempts() int {
 	return t.reprocessingAttempts
@@ -361,7 +403,8 @@ func (t *Transaction) IsSecurityTransaction() bool {
 	return t.transactionType.IsSecurityTransaction()
 }
 
-// CanBeProcessed returns true if the transaction can be processed
+// CanBeProcessed returns true if the transaction can be processed without forced reprocessing
+// (see TransactionStatus.CanTransitionToProc)
 func (t *Transaction) CanBeProcessed() bool {
 	return t.status.CanBeReprocessed()
 }
@@ -406,3 +449,30 @@ func (t *Transaction) String() string {
 	return fmt.Sprintf("Transaction{ID: %d, Portfolio: %s, Security: %s, Type: %s, Status: %s, Quantity: %s, Price: %s}",
 		t.id, t.portfolioID.String(), t.securityID.String(), t.transactionType, t.status, t.quantity.String(), t.price.String())
 }
+
+// SortTransactionsChronologically orders transactions for balance replay by
+// transaction date, then transaction type, then creation time. The ID is used
+// as a final tie-breaker so the ordering is fully deterministic.
+func SortTransactionsChronologically(transactions []*Transaction) {
+	SortTransactionsByEffectiveDate(transactions, DateBasisTrade)
+}
+
+// SortTransactionsByEffectiveDate orders transactions for balance replay like
+// SortTransactionsChronologically, using the effective date under basis in place
+// of the transaction date.
+func SortTransactionsByEffectiveDate(transactions []*Transaction, basis DateBasis) {
+	sort.SliceStable(transactions, func(i, j int) bool {
+		a, b := transactions[i], transactions[j]
+		aDate, bDate := a.EffectiveDate(basis), b.EffectiveDate(basis)
+		if !aDate.Equal(bDate) {
+			return aDate.Before(bDate)
+		}
+		if a.transactionType != b.transactionType {
+			return a.transactionType < b.transactionType
+		}
+		if !a.createdAt.Equal(b.createdAt) {
+			return a.createdAt.Before(b.createdAt)
+		}
+		return a.id < b.id
+	})
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -466,6 +467,7 @@ func TestTransactionStatus(t *testing.T) {
 			TransactionStatusProc,
 			TransactionStatusFatal,
 			TransactionStatusError,
+			TransactionStatusDead,
 		}
 
 		for _, status := range validStatuses {
@@ -489,10 +491,112 @@ func TestTransactionStatus(t *testing.T) {
 		nonProcessableStatuses := []TransactionStatus{
 			TransactionStatusProc,
 			TransactionStatusFatal,
+			TransactionStatusDead,
 		}
 
 		for _, status := range nonProcessableStatuses {
 			assert.False(t, status.CanBeReprocessed(), "Status %s should not be processable", status)
 		}
 	})
+
+	t.Run("CanTransitionToProc", func(t *testing.T) {
+		tests := []struct {
+			status  TransactionStatus
+			regular bool
+			forced  bool
+		}{
+			{TransactionStatusNew, true, true},
+			{TransactionStatusError, true, true},
+			{TransactionStatusProc, false, true},
+			{TransactionStatusFatal, false, false},
+			{TransactionStatusDead, false, false},
+		}
+
+		for _, tt := range tests {
+			assert.Equal(t, tt.regular, tt.status.CanTransitionToProc(false), "%s -> PROC", tt.status)
+			assert.Equal(t, tt.forced, tt.status.CanTransitionToProc(true), "%s -> PROC when forced", tt.status)
+		}
+	})
+}
+
+func TestSortTransactionsChronologically(t *testing.T) {
+	day1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day2 := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	created := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+
+	build := func(id int64, txnType string, date, createdAt time.Time) *Transaction {
+		builder := NewTransactionBuilder().
+			WithID(id).
+			WithPortfolioID("PORTFOLIO123456789012345").
+			WithSourceID(fmt.Sprintf("SOURCE%03d", id)).
+			WithTransactionType(txnType).
+			WithQuantity(decimal.NewFromInt(10)).
+			WithPrice(decimal.NewFromInt(1)).
+			WithTransactionDate(date).
+			WithTimestamps(createdAt, createdAt)
+		if txnType != "DEP" && txnType != "WD" {
+			builder = builder.WithSecurityIDFromString("SECURITY1234567890123456")
+		}
+		transaction, err := builder.Build()
+		require.NoError(t, err)
+		return transaction
+	}
+
+	transactions := []*Transaction{
+		build(1, "SELL", day2, created),
+		build(2, "BUY", day2, created.Add(time.Minute)),
+		build(3, "BUY", day2, created),
+		build(4, "DEP", day1, created.Add(time.Hour)),
+		build(5, "BUY", day2, created),
+	}
+
+	SortTransactionsChronologically(transactions)
+
+	ids := make([]int64, len(transactions))
+	for i, transaction := range transactions {
+		ids[i] = transaction.ID()
+	}
+
+	// day1 first, then BUY before SELL, then by creation time, then by ID
+	assert.Equal(t, []int64{4, 3, 5, 2, 1}, ids)
+}
+
+func TestTransaction_SettlementDate(t *testing.T) {
+	tradeDate := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	builder := func() *TransactionBuilder {
+		return NewTransactionBuilder().
+			WithPortfolioID("PORTFOLIO123456789012345").
+			WithSecurityIDFromString("SECURITY1234567890123456").
+			WithSourceID("SOURCE001").
+			WithTransactionType("BUY").
+			WithQuantity(decimal.NewFromInt(10)).
+			WithPrice(decimal.NewFromInt(1)).
+			WithTransactionDate(tradeDate)
+	}
+
+	t.Run("Without a settlement date both bases use the trade date", func(t *testing.T) {
+		transaction, err := builder().Build()
+		require.NoError(t, err)
+
+		assert.Nil(t, transaction.SettlementDate())
+		assert.Equal(t, tradeDate, transaction.EffectiveDate(DateBasisTrade))
+		assert.Equal(t, tradeDate, transaction.EffectiveDate(DateBasisSettlement))
+	})
+
+	t.Run("Settlement basis uses the settlement date", func(t *testing.T) {
+		transaction, err := builder().WithSettlementDateFromString("20240104").Build()
+		require.NoError(t, err)
+
+		settles := time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)
+		require.NotNil(t, transaction.SettlementDate())
+		assert.Equal(t, settles, *transaction.SettlementDate())
+		assert.Equal(t, tradeDate, transaction.EffectiveDate(DateBasisTrade))
+		assert.Equal(t, settles, transaction.EffectiveDate(DateBasisSettlement))
+	})
+
+	t.Run("Settlement before the trade date is rejected", func(t *testing.T) {
+		_, err := builder().WithSettlementDateFromString("20240101").Build()
+		assert.Error(t, err)
+	})
 }
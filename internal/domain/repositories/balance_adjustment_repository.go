@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BalanceAdjustment represents a manual balance correction recorded in the adjustment ledger
+type BalanceAdjustment struct {
+	ID                  int64           `json:"id" db:"id"`
+	AdjustmentKey       string          `json:"adjustment_key" db:"adjustment_key"`
+	BalanceID           int64           `json:"balance_id" db:"balance_id"`
+	PortfolioID         string          `json:"portfolio_id" db:"portfolio_id"`
+	SecurityID          *string         `json:"security_id" db:"security_id"`
+	QuantityLongDelta   decimal.Decimal `json:"quantity_long_delta" db:"quantity_long_delta"`
+	QuantityShortDelta  decimal.Decimal `json:"quantity_short_delta" db:"quantity_short_delta"`
+	QuantityLongBefore  decimal.Decimal `json:"quantity_long_before" db:"quantity_long_before"`
+	QuantityShortBefore decimal.Decimal `json:"quantity_short_before" db:"quantity_short_before"`
+	QuantityLongAfter   decimal.Decimal `json:"quantity_long_after" db:"quantity_long_after"`
+	QuantityShortAfter  decimal.Decimal `json:"quantity_short_after" db:"quantity_short_after"`
+	BalanceVersion      int             `json:"balance_version" db:"balance_version"`
+	Reason              string          `json:"reason" db:"reason"`
+	Operator            string          `json:"operator" db:"operator"`
+	CreatedAt           time.Time       `json:"created_at" db:"created_at"`
+}
+
+// BalanceAdjustmentRepository defines the contract for the balance adjustment ledger
+type BalanceAdjustmentRepository interface {
+	// Apply records the adjustment and applies its deltas to the portfolio/security balance in a
+	// single database transaction, creating the balance if it does not exist. A non-nil
+	// expectedVersion must match the current balance version. If an adjustment with the same key
+	// was already recorded, nothing is changed: the stored adjustment is copied into adjustment
+	// and applied is false.
+	Apply(ctx context.Context, adjustment *BalanceAdjustment, expectedVersion *int) (balance *Balance, applied bool, err error)
+
+	// GetByKey retrieves an adjustment by its idempotency key
+	GetByKey(ctx context.Context, adjustmentKey string) (*BalanceAdjustment, error)
+
+	// ListByPortfolio returns a portfolio's adjustments in the order they were recorded
+	ListByPortfolio(ctx context.Context, portfolioID string) ([]*BalanceAdjustment, error)
+
+	// ReleaseKeysBefore clears the idempotency key of up to limit adjustments recorded before
+	// cutoff, oldest first, so a request with a released key is applied again. The ledger
+	// entries are kept.
+	ReleaseKeysBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+
+	// ReleaseKey clears the idempotency key of the adjustment recorded with it if that was before
+	// cutoff, so the next request with the key is applied again. It reports whether it did.
+	ReleaseKey(ctx context.Context, adjustmentKey string, cutoff time.Time) (bool, error)
+
+	// CountKeys returns the number of adjustments still holding their idempotency key
+	CountKeys(ctx context.Context) (int64, error)
+}
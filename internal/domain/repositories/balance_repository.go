@@ -14,11 +14,8 @@ type BalanceFilter struct {
 	PortfolioID *string `json:"portfolio_id,omitempty"`
 	SecurityID  *string `json:"security_id,omitempty"`
 
-	// Cash vs security filters
-	CashOnly       bool  `json:"cash_only,omitempty"`       // filter for cash balances (security_id IS NULL)
-	SecuritiesOnly bool  `json:"securities_only,omitempty"` // filter for security balances (security_id IS NOT NULL)
-	IncludeCash    *bool `json:"include_cash,omitempty"`    // include cash balances
-	OnlyCash       *bool `json:"only_cash,omitempty"`       // only cash balances
+	// Cash vs security filter (empty means all balances)
+	Scope BalanceScope `json:"scope,omitempty"`
 
 	// Date filters
 	LastUpdatedFrom *time.Time `json:"last_updated_from,omitempty"`
@@ -30,10 +27,17 @@ type BalanceFilter struct {
 	QuantityShortMin *decimal.Decimal `json:"quantity_short_min,omitempty"`
 	QuantityShortMax *decimal.Decimal `json:"quantity_short_max,omitempty"`
 
+	// Magnitude filters on the absolute quantity, for hiding dust positions of either sign
+	QuantityLongAbsMin  *decimal.Decimal `json:"quantity_long_abs_min,omitempty"`
+	QuantityShortAbsMin *decimal.Decimal `json:"quantity_short_abs_min,omitempty"`
+
 	// Zero balance filters
 	ExcludeZeroBalances bool `json:"exclude_zero_balances,omitempty"`
 	OnlyZeroBalances    bool `json:"only_zero_balances,omitempty"`
 
+	// ExcludeAdjusted leaves out balances the balance adjustments ledger references
+	ExcludeAdjusted bool `json:"exclude_adjusted,omitempty"`
+
 	// Collections for IN queries
 	IDs          []int64  `json:"ids,omitempty"`
 	PortfolioIDs []string `json:"portfolio_ids,omitempty"`
@@ -46,6 +50,44 @@ type BalanceFilter struct {
 	SortBy     []string    `json:"sort_by,omitempty"` // Legacy support for simple sorting
 }
 
+// BalanceScope selects cash balances, security balances or both
+type BalanceScope string
+
+const (
+	BalanceScopeAll            BalanceScope = "ALL"
+	BalanceScopeCashOnly       BalanceScope = "CASH_ONLY"       // security_id IS NULL
+	BalanceScopeSecuritiesOnly BalanceScope = "SECURITIES_ONLY" // security_id IS NOT NULL
+)
+
+// String returns the string representation of the balance scope
+func (s BalanceScope) String() string {
+	return string(s)
+}
+
+// IsValid checks if the balance scope is valid
+func (s BalanceScope) IsValid() bool {
+	return s == "" || s == BalanceScopeAll || s == BalanceScopeCashOnly || s == BalanceScopeSecuritiesOnly
+}
+
+// PositionRanking selects the quantity that orders positions in a top-N query
+type PositionRanking string
+
+const (
+	PositionRankingLong     PositionRanking = "long"     // quantity_long
+	PositionRankingShort    PositionRanking = "short"    // quantity_short
+	PositionRankingAbsolute PositionRanking = "absolute" // |quantity_long - quantity_short|
+)
+
+// String returns the string representation of the position ranking
+func (p PositionRanking) String() string {
+	return string(p)
+}
+
+// IsValid checks if the position ranking is valid
+func (p PositionRanking) IsValid() bool {
+	return p == PositionRankingLong || p == PositionRankingShort || p == PositionRankingAbsolute
+}
+
 // Balance represents a portfolio balance entity for repository operations
 type Balance struct {
 	ID            int64           `json:"id" db:"id"`
@@ -60,7 +102,8 @@ type Balance struct {
 
 // BalanceRepository defines the contract for balance data access
 type BalanceRepository interface {
-	// Create operations
+	// Create operations. Create fails with ErrBalanceExists if the portfolio and security
+	// already have a balance.
 	Create(ctx context.Context, balance *Balance) error
 	CreateOrUpdate(ctx context.Context, balance *Balance) error
 
@@ -76,15 +119,44 @@ type BalanceRepository interface {
 
 	// Batch operations
 	UpdateMultipleBalances(ctx context.Context, updates []BalanceUpdate) error
+	// DeleteZeroBalancesBefore deletes up to limit security balances with zero quantities last
+	// updated before cutoff, oldest first; cash balances are kept
+	DeleteZeroBalancesBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+	// RebuildPortfolioBalances runs rebuild within one database transaction that starts by
+	// locking the portfolio's balances. Everything rebuild writes through the rebuild is
+	// committed together, or rolled back if rebuild or the commit fails.
+	RebuildPortfolioBalances(ctx context.Context, portfolioID string, rebuild func(ctx context.Context, rebuild PortfolioBalanceRebuild) error) error
 
 	// Query operations
 	GetBalancesByPortfolio(ctx context.Context, portfolioID string) ([]*Balance, error)
 	GetCashBalance(ctx context.Context, portfolioID string) (*Balance, error)
 	GetZeroBalances(ctx context.Context, limit int) ([]*Balance, error)
+	// GetTopPositions returns the largest security positions across all portfolios,
+	// ordered by the ranking quantity descending and then by ID
+	GetTopPositions(ctx context.Context, by PositionRanking, limit int) ([]*Balance, error)
 
 	// Statistics
 	GetBalanceStats(ctx context.Context) (*BalanceStats, error)
 	GetPortfolioSummary(ctx context.Context, portfolioID string) (*PortfolioSummary, error)
+	// GetPortfolioSummaries aggregates the summaries of several portfolios in one query, or of all
+	// portfolios when portfolioIDs is empty, ordered by portfolio ID. Portfolios without balances
+	// are omitted; limit <= 0 returns every matching portfolio.
+	GetPortfolioSummaries(ctx context.Context, portfolioIDs []string, limit, offset int) ([]*PortfolioSummary, error)
+}
+
+// PortfolioBalanceRebuild gives RebuildPortfolioBalances access to a portfolio's balances,
+// transactions and adjustments within its database transaction
+type PortfolioBalanceRebuild interface {
+	// Balances returns the portfolio's balances, locked until the rebuild ends
+	Balances() []*Balance
+	// ListTransactions lists transactions like TransactionRepository.List
+	ListTransactions(ctx context.Context, filter TransactionFilter) ([]*Transaction, error)
+	// ListAdjustments lists the portfolio's adjustments like BalanceAdjustmentRepository.ListByPortfolio
+	ListAdjustments(ctx context.Context) ([]*BalanceAdjustment, error)
+	// Create creates a balance the portfolio did not have
+	Create(ctx context.Context, balance *Balance) error
+	// UpdateQuantities sets the quantities of one of the locked balances
+	UpdateQuantities(ctx context.Context, id int64, quantityLong, quantityShort decimal.Decimal, version int) error
 }
 
 // BalanceUpdate represents a balance update operation
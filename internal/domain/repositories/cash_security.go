@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"fmt"
+)
+
+// CashSecurityIDLength is the length of every stored security ID, including a cash sentinel
+const CashSecurityIDLength = 24
+
+// CashSecurityID describes how cash balances store their security ID. The zero value stores
+// cash as NULL; otherwise cash is stored under a fixed sentinel security ID. Outside of
+// storage cash is always represented by a nil security ID.
+type CashSecurityID struct {
+	sentinel string
+}
+
+// NewCashSecurityID creates a cash representation. An empty sentinel keeps cash as NULL.
+func NewCashSecurityID(sentinel string) (CashSecurityID, error) {
+	if sentinel == "" {
+		return CashSecurityID{}, nil
+	}
+
+	if len(sentinel) != CashSecurityIDLength {
+		return CashSecurityID{}, fmt.Errorf("cash security ID sentinel must be exactly %d characters, got %d", CashSecurityIDLength, len(sentinel))
+	}
+
+	for _, r := range sentinel {
+		if !((r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-') {
+			return CashSecurityID{}, fmt.Errorf("cash security ID sentinel may only contain letters, digits, '_' and '-'")
+		}
+	}
+
+	return CashSecurityID{sentinel: sentinel}, nil
+}
+
+// Sentinel returns the stored cash security ID, or an empty string when cash is stored as NULL
+func (c CashSecurityID) Sentinel() string {
+	return c.sentinel
+}
+
+// UsesSentinel returns true if cash is stored under a sentinel security ID
+func (c CashSecurityID) UsesSentinel() bool {
+	return c.sentinel != ""
+}
+
+// IsCash returns true if the stored security ID denotes cash
+func (c CashSecurityID) IsCash(storedSecurityID *string) bool {
+	if storedSecurityID == nil {
+		return true
+	}
+	return c.UsesSentinel() && *storedSecurityID == c.sentinel
+}
+
+// ToStorage converts a security ID to its stored form
+func (c CashSecurityID) ToStorage(securityID *string) *string {
+	if securityID == nil && c.UsesSentinel() {
+		sentinel := c.sentinel
+		return &sentinel
+	}
+	return securityID
+}
+
+// FromStorage converts a stored security ID back to its domain form, with nil for cash
+func (c CashSecurityID) FromStorage(storedSecurityID *string) *string {
+	if c.IsCash(storedSecurityID) {
+		return nil
+	}
+	return storedSecurityID
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"errors"
 	"fmt"
 )
@@ -15,6 +16,22 @@ var (
 	ErrConnectionFailed    = errors.New("database connection failed")
 	ErrTransactionFailed   = errors.New("database transaction failed")
 	ErrConstraintViolation = errors.New("database constraint violation")
+
+	// ErrQueryCanceled is a query abandoned because its context was canceled or timed out,
+	// typically because the client disconnected; it does not indicate a database failure
+	ErrQueryCanceled = errors.New("query canceled")
+
+	// ErrBalanceExists is a duplicate key error for a balance whose portfolio and security
+	// already have one, typically because a concurrent writer created it first
+	ErrBalanceExists = fmt.Errorf("%w: balance already exists for portfolio and security", ErrDuplicateKey)
+
+	// ErrListFilterTooLarge is an invalid filter whose ID list holds more values than a single
+	// query accepts; callers that need more can use the chunked list operations
+	ErrListFilterTooLarge = fmt.Errorf("%w: list filter too large", ErrInvalidFilter)
+
+	// ErrDailyLimitReached is a transaction not created because its portfolio already has as
+	// many transactions for its transaction date as the daily limit allows
+	ErrDailyLimitReached = errors.New("daily transaction limit reached")
 )
 
 // RepositoryError wraps errors with additional context
@@ -76,6 +93,17 @@ func NewDuplicateKeyError(entity string, field, value interface{}) *RepositoryEr
 		WithContext("value", value)
 }
 
+// NewBalanceExistsError creates the error returned when creating a balance that already exists.
+// Callers can retry the write with CreateOrUpdate or by re-reading and updating the balance.
+func NewBalanceExistsError(portfolioID string, securityID *string) *RepositoryError {
+	err := NewRepositoryError("create", "balance", ErrBalanceExists).
+		WithContext("portfolioId", portfolioID)
+	if securityID != nil {
+		err.WithContext("securityId", *securityID)
+	}
+	return err
+}
+
 // NewOptimisticLockError creates an optimistic locking error
 func NewOptimisticLockError(entity string, id, expectedVersion, actualVersion interface{}) *RepositoryError {
 	return NewRepositoryError("update", entity, ErrOptimisticLock).
@@ -103,6 +131,31 @@ func NewConnectionError(operation string, cause error) *RepositoryError {
 		WithContext("details", cause.Error())
 }
 
+// NewQueryCanceledError creates the error returned for a query abandoned because its context
+// ended. Both the context error and the ErrQueryCanceled sentinel match with errors.Is.
+func NewQueryCanceledError(operation, entity string, ctxErr, cause error) *RepositoryError {
+	return NewRepositoryError(operation, entity, fmt.Errorf("%w: %w", ErrQueryCanceled, ctxErr)).
+		WithContext("details", cause.Error())
+}
+
+// NewListFilterTooLargeError creates the error returned for a list filter with more than max values
+func NewListFilterTooLargeError(entity, field string, size, max int) *RepositoryError {
+	return NewRepositoryError("build_query", entity,
+		fmt.Errorf("%w: %s has %d values, at most %d are allowed", ErrListFilterTooLarge, field, size, max)).
+		WithContext("field", field).
+		WithContext("size", size).
+		WithContext("max", max)
+}
+
+// NewDailyLimitReachedError creates the error returned when creating a transaction beyond its
+// portfolio's daily transaction limit
+func NewDailyLimitReachedError(portfolioID string, transactionDate string, limit int) *RepositoryError {
+	return NewRepositoryError("create", "transaction", ErrDailyLimitReached).
+		WithContext("portfolioId", portfolioID).
+		WithContext("transactionDate", transactionDate).
+		WithContext("limit", limit)
+}
+
 // Helper functions to check error types
 
 // IsNotFoundError checks if the error is a not found error
@@ -115,6 +168,21 @@ func IsDuplicateKeyError(err error) bool {
 	return errors.Is(err, ErrDuplicateKey)
 }
 
+// IsBalanceExistsError checks if the error reports a balance that already exists
+func IsBalanceExistsError(err error) bool {
+	return errors.Is(err, ErrBalanceExists)
+}
+
+// IsListFilterTooLargeError checks if the error is a list filter with too many values
+func IsListFilterTooLargeError(err error) bool {
+	return errors.Is(err, ErrListFilterTooLarge)
+}
+
+// IsDailyLimitReachedError checks if the error reports a transaction beyond the daily limit
+func IsDailyLimitReachedError(err error) bool {
+	return errors.Is(err, ErrDailyLimitReached)
+}
+
 // IsOptimisticLockError checks if the error is an optimistic locking error
 func IsOptimisticLockError(err error) bool {
 	return errors.Is(err, ErrOptimisticLock)
@@ -134,3 +202,17 @@ func IsTransactionError(err error) bool {
 func IsConnectionError(err error) bool {
 	return errors.Is(err, ErrConnectionFailed)
 }
+
+// IsQueryCanceledError checks if the error is a query abandoned because its context ended
+func IsQueryCanceledError(err error) bool {
+	return errors.Is(err, ErrQueryCanceled)
+}
+
+// IsTransientError checks if the error is likely to succeed when retried later
+func IsTransientError(err error) bool {
+	return IsOptimisticLockError(err) ||
+		IsBalanceExistsError(err) ||
+		IsConnectionError(err) ||
+		IsTransactionError(err) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"time"
+)
+
+// TransactionEventType identifies what kind of change a transaction event records
+type TransactionEventType string
+
+const (
+	// TransactionEventStatusChange records a status update
+	TransactionEventStatusChange TransactionEventType = "STATUS_CHANGE"
+	// TransactionEventRetryableError records a transient failure queued for automatic reprocessing
+	TransactionEventRetryableError TransactionEventType = "RETRYABLE_ERROR"
+	// TransactionEventReprocessingAttempt records the start of a reprocessing attempt
+	TransactionEventReprocessingAttempt TransactionEventType = "REPROCESSING_ATTEMPT"
+)
+
+// TransactionEvent is an entry in a transaction's audit history. The status, attempt count,
+// error and version describe the transaction after the change.
+type TransactionEvent struct {
+	ID                   int64                `json:"id" db:"id"`
+	TransactionID        int64                `json:"transaction_id" db:"transaction_id"`
+	EventType            TransactionEventType `json:"event_type" db:"event_type"`
+	OldStatus            *string              `json:"old_status,omitempty" db:"old_status"`
+	NewStatus            string               `json:"new_status" db:"new_status"`
+	ReprocessingAttempts int                  `json:"reprocessing_attempts" db:"reprocessing_attempts"`
+	ErrorMessage         *string              `json:"error_message,omitempty" db:"error_message"`
+	ErrorRetryable       bool                 `json:"error_retryable" db:"error_retryable"`
+	Version              int                  `json:"version" db:"version"`
+	CreatedAt            time.Time            `json:"created_at" db:"created_at"`
+}
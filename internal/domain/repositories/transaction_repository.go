@@ -18,17 +18,20 @@ type Transaction struct {
 	Quantity             decimal.Decimal `json:"quantity" db:"quantity"`
 	Price                decimal.Decimal `json:"price" db:"price"`
 	TransactionDate      time.Time       `json:"transaction_date" db:"transaction_date"`
+	SettlementDate       *time.Time      `json:"settlement_date,omitempty" db:"settlement_date"`
 	ReprocessingAttempts int             `json:"reprocessing_attempts" db:"reprocessing_attempts"`
+	ErrorRetryable       bool            `json:"error_retryable" db:"error_retryable"`
 	Version              int             `json:"version" db:"version"`
 	CreatedAt            time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt            time.Time       `json:"updated_at" db:"updated_at"`
-	ErrorMessage         *string         `json:"error_message,omitempty"`
+	ErrorMessage         *string         `json:"error_message,omitempty" db:"error_message"`
 }
 
 // TransactionFilter holds filtering options for transaction queries
 type TransactionFilter struct {
 	// ID filters
 	ID          *int64  `json:"id,omitempty"`
+	AfterID     *int64  `json:"after_id,omitempty"` // Keyset cursor: only transactions with a larger ID
 	PortfolioID *string `json:"portfolio_id,omitempty"`
 	SecurityID  *string `json:"security_id,omitempty"`
 	SourceID    *string `json:"source_id,omitempty"`
@@ -36,6 +39,7 @@ type TransactionFilter struct {
 	// Status and type filters
 	Status          *string `json:"status,omitempty"`
 	TransactionType *string `json:"transaction_type,omitempty"`
+	Retryable       *bool   `json:"retryable,omitempty"`
 
 	// Date filters
 	TransactionDate     *time.Time `json:"transaction_date,omitempty"`
@@ -67,28 +71,67 @@ type TransactionRepository interface {
 	// Create operations
 	Create(ctx context.Context, transaction *Transaction) error
 	CreateBatch(ctx context.Context, transactions []*Transaction) error
+	// CreateWithinDailyLimit creates the transaction unless its portfolio already has limit
+	// transactions for its transaction date, returning ErrDailyLimitReached then. The count and
+	// the insert hold a lock on the portfolio and date, so concurrent creates cannot both pass.
+	CreateWithinDailyLimit(ctx context.Context, transaction *Transaction, limit int) error
 
 	// Read operations
 	GetByID(ctx context.Context, id int64) (*Transaction, error)
 	GetBySourceID(ctx context.Context, sourceID string) (*Transaction, error)
+	// GetBySourceIDs returns the transactions with the given source IDs in one query, in no
+	// particular order. Source IDs without a transaction are absent from the result.
+	GetBySourceIDs(ctx context.Context, sourceIDs []string) ([]*Transaction, error)
 	List(ctx context.Context, filter TransactionFilter) ([]*Transaction, error)
 	Count(ctx context.Context, filter TransactionFilter) (int64, error)
 
-	// Update operations
+	// Update operations. UpdateStatus, MarkRetryableError and IncrementReprocessingAttempts
+	// also append an event to the transaction's audit history.
 	Update(ctx context.Context, transaction *Transaction) error
 	UpdateStatus(ctx context.Context, id int64, status string, errorMessage *string, version int) error
 	IncrementReprocessingAttempts(ctx context.Context, id int64, version int) error
+	MarkRetryableError(ctx context.Context, id int64, errorMessage *string, version int) error
+
+	// SetNotionalAmount stores the notional amount computed when the transaction was processed.
+	// The amount is derived data: it neither changes the version nor appends an audit event.
+	SetNotionalAmount(ctx context.Context, id int64, amount decimal.Decimal) error
+	// BackfillNotionalAmounts stores quantity * price on up to limit processed transactions
+	// without a notional amount and returns how many were updated
+	BackfillNotionalAmounts(ctx context.Context, limit int) (int64, error)
 
 	// Query operations for processing
 	GetNewTransactions(ctx context.Context, limit int) ([]*Transaction, error)
 	GetTransactionsByPortfolio(ctx context.Context, portfolioID string, limit int, offset int) ([]*Transaction, error)
 	GetTransactionsByStatus(ctx context.Context, status string, limit int, offset int) ([]*Transaction, error)
 
+	// GetLatestTransactionPerPortfolio returns the most recently created transaction of each of
+	// the given portfolios, in any status, ordered by portfolio ID. Portfolios without
+	// transactions are absent from the result.
+	GetLatestTransactionPerPortfolio(ctx context.Context, portfolioIDs []string) ([]*Transaction, error)
+
+	// GetLatestPrices returns the price of the most recent processed transaction with a positive
+	// price for each security held in the portfolio, keyed by security ID
+	GetLatestPrices(ctx context.Context, portfolioID string) (map[string]decimal.Decimal, error)
+
+	// GetLatestPricesForSecurities returns the price of the most recent processed transaction
+	// with a positive price in any portfolio for each of the given securities. Securities
+	// without such a transaction are absent from the result.
+	GetLatestPricesForSecurities(ctx context.Context, securityIDs []string) (map[string]decimal.Decimal, error)
+
+	// GetHistory returns a transaction's audit events, oldest first
+	GetHistory(ctx context.Context, transactionID int64) ([]*TransactionEvent, error)
+
 	// Batch operations
 	UpdateTransactionsStatus(ctx context.Context, ids []int64, status string, errorMessage *string) error
 
 	// Statistics
 	GetTransactionStats(ctx context.Context) (*TransactionStats, error)
+
+	// Retention operations select transactions with a transaction date before cutoff, limited
+	// to the given statuses when any are given. Deleting transactions does not change balances,
+	// which already reflect them; their audit events are deleted with them.
+	CountTransactionsOlderThan(ctx context.Context, cutoff time.Time, statuses []string) (int64, error)
+	DeleteTransactionsOlderThan(ctx context.Context, cutoff time.Time, statuses []string, batchSize int) (int64, error)
 }
 
 // TransactionStats holds transaction statistics
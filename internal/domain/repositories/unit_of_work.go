@@ -0,0 +1,13 @@
+package repositories
+
+import (
+	"context"
+)
+
+// UnitOfWork runs repository calls as one atomic unit
+type UnitOfWork interface {
+	// Atomically runs fn in one database transaction, committed when fn returns nil and rolled
+	// back otherwise. Repository calls made with the context fn receives take part in the
+	// transaction; a call made within another unit joins it.
+	Atomically(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// BalanceBatch processes a run of transactions against balances loaded once. A balance is read
+// from the repository the first time a transaction of the batch touches it; later transactions
+// see the quantities the earlier ones left in memory, and Commit writes each changed balance
+// once. Transactions processed by the batch keep their status until Commit has written the
+// balances they changed.
+//
+// Forced reprocessing is not available within a batch. A BalanceBatch is not safe for
+// concurrent use.
+type BalanceBatch struct {
+	processor *TransactionProcessor
+	balances  *bufferedBalanceRepo
+	pending   []*pendingTransaction
+}
+
+// pendingTransaction is a transaction processed into the batch whose balances are not written yet
+type pendingTransaction struct {
+	transaction   *models.Transaction
+	result        *ProcessingResult
+	balanceResult *BalanceCalculationResult
+}
+
+// NewBalanceBatch starts a batch that processes transactions against balances shared in memory
+func (p *TransactionProcessor) NewBalanceBatch() *BalanceBatch {
+	balances := newBufferedBalanceRepo(p.balanceRepo)
+
+	calculator := *p.calculator
+	calculator.balanceRepo = balances
+
+	processor := *p
+	processor.balanceRepo = balances
+	processor.calculator = &calculator
+	processor.allowForcedReprocess = false
+
+	batch := &BalanceBatch{processor: &processor, balances: balances}
+	processor.batch = batch
+	return batch
+}
+
+// ProcessTransaction processes a transaction into the batch. A successful result is final only
+// once Commit has written the balances.
+func (b *BalanceBatch) ProcessTransaction(ctx context.Context, transaction *models.Transaction) (*ProcessingResult, error) {
+	return b.processor.ProcessTransaction(ctx, transaction)
+}
+
+// hold keeps a processed transaction until Commit writes its balances
+func (b *BalanceBatch) hold(transaction *models.Transaction, result *ProcessingResult, balanceResult *BalanceCalculationResult) {
+	b.pending = append(b.pending, &pendingTransaction{
+		transaction:   transaction,
+		result:        result,
+		balanceResult: balanceResult,
+	})
+}
+
+// Commit writes every balance the batch changed and moves the transactions processed since the
+// last Commit to PROC, all in one unit of work. When any write fails the unit is rolled back and
+// every transaction of the commit is set to ERROR with its result updated; without a unit of
+// work the writes made before the failure stay, and transactions they may have applied to are
+// not flagged for automatic reprocessing. The returned error reports the failed write; the
+// batch can be used again afterwards and reloads its balances.
+func (b *BalanceBatch) Commit(ctx context.Context) error {
+	p := b.processor
+	pending := b.pending
+	b.pending = nil
+	defer b.balances.reset()
+
+	written, completed := 0, 0
+	err := p.atomically(ctx, func(ctx context.Context) error {
+		var err error
+		if written, err = b.balances.flush(ctx); err != nil {
+			return fmt.Errorf("failed to persist balance changes: %w", err)
+		}
+
+		for _, held := range pending {
+			if err := p.completeTransaction(ctx, held.transaction, held.balanceResult); err != nil {
+				return fmt.Errorf("failed to update status of transaction %d: %w", held.transaction.ID(), err)
+			}
+			completed++
+		}
+		return nil
+	})
+	if err == nil {
+		p.logger.Info("Balance batch committed",
+			logger.Int("transactions", len(pending)),
+			logger.Int("balancesWritten", written))
+		return nil
+	}
+
+	partial := written > 0
+	if p.unitOfWork != nil {
+		partial, completed = false, 0
+	}
+
+	p.logger.Error("Failed to commit balance batch",
+		logger.Int("transactions", len(pending)),
+		logger.Bool("partiallyWritten", partial),
+		logger.Err(err))
+
+	for _, held := range pending[completed:] {
+		held.fail(fmt.Sprintf("Failed to commit balance batch: %v", err))
+
+		if statusErr := p.recordPersistError(ctx, held.transaction, held.result, err, partial); statusErr != nil {
+			p.logger.Error("Failed to record batch processing error",
+				logger.Int64("transactionId", held.transaction.ID()),
+				logger.Err(statusErr))
+		}
+	}
+	return fmt.Errorf("failed to commit batch of %d transactions: %w", len(pending), err)
+}
+
+// fail turns the transaction's successful result into a processing error
+func (t *pendingTransaction) fail(message string) {
+	t.result.Success = false
+	t.result.Status = models.TransactionStatusError
+	t.result.ErrorMessage = message
+	t.result.BalanceChanges = nil
+}
+
+// bufferedBalanceKey identifies a balance; the security ID is empty for cash
+type bufferedBalanceKey struct {
+	portfolioID string
+	securityID  string
+}
+
+// bufferedBalance is a balance held in memory by a batch
+type bufferedBalance struct {
+	// balance is nil while the portfolio has no balance for the security
+	balance *repositories.Balance
+	// stored reports whether the repository holds the balance, so it is updated rather than created
+	stored bool
+	dirty  bool
+}
+
+// bufferedBalanceRepo serves balance lookups and writes from memory, reading each balance from
+// the underlying repository at most once until it is flushed
+type bufferedBalanceRepo struct {
+	repositories.BalanceRepository
+
+	entries map[bufferedBalanceKey]*bufferedBalance
+	// order holds the changed balances in the order they were first changed
+	order []bufferedBalanceKey
+}
+
+func newBufferedBalanceRepo(repo repositories.BalanceRepository) *bufferedBalanceRepo {
+	return &bufferedBalanceRepo{
+		BalanceRepository: repo,
+		entries:           make(map[bufferedBalanceKey]*bufferedBalance),
+	}
+}
+
+// GetByPortfolioAndSecurity returns the balance as changed by the batch so far
+func (r *bufferedBalanceRepo) GetByPortfolioAndSecurity(ctx context.Context, portfolioID string, securityID *string) (*repositories.Balance, error) {
+	entry, err := r.load(ctx, portfolioID, securityID)
+	if err != nil {
+		return nil, err
+	}
+	if entry.balance == nil {
+		return nil, repositories.NewNotFoundError("balance", portfolioID)
+	}
+
+	clone := *entry.balance
+	return &clone, nil
+}
+
+// GetCashBalance returns the cash balance as changed by the batch so far
+func (r *bufferedBalanceRepo) GetCashBalance(ctx context.Context, portfolioID string) (*repositories.Balance, error) {
+	return r.GetByPortfolioAndSecurity(ctx, portfolioID, nil)
+}
+
+// Create holds a new balance until the batch is flushed
+func (r *bufferedBalanceRepo) Create(ctx context.Context, balance *repositories.Balance) error {
+	entry, err := r.load(ctx, balance.PortfolioID, balance.SecurityID)
+	if err != nil {
+		return err
+	}
+	if entry.balance != nil {
+		return repositories.ErrBalanceExists
+	}
+
+	r.change(balance, entry)
+	return nil
+}
+
+// Update holds the changed balance until the batch is flushed
+func (r *bufferedBalanceRepo) Update(ctx context.Context, balance *repositories.Balance) error {
+	entry, err := r.load(ctx, balance.PortfolioID, balance.SecurityID)
+	if err != nil {
+		return err
+	}
+	if entry.balance == nil {
+		return repositories.NewNotFoundError("balance", balance.ID)
+	}
+
+	r.change(balance, entry)
+	return nil
+}
+
+// load returns the batch's entry for a balance, reading it from the repository the first time
+func (r *bufferedBalanceRepo) load(ctx context.Context, portfolioID string, securityID *string) (*bufferedBalance, error) {
+	key := bufferedBalanceKey{portfolioID: portfolioID, securityID: balanceKey(securityID)}
+	if entry, ok := r.entries[key]; ok {
+		return entry, nil
+	}
+
+	var balance *repositories.Balance
+	var err error
+	if securityID == nil {
+		balance, err = r.BalanceRepository.GetCashBalance(ctx, portfolioID)
+	} else {
+		balance, err = r.BalanceRepository.GetByPortfolioAndSecurity(ctx, portfolioID, securityID)
+	}
+	if repositories.IsNotFoundError(err) {
+		balance = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	entry := &bufferedBalance{balance: balance, stored: balance != nil}
+	r.entries[key] = entry
+	return entry, nil
+}
+
+// change replaces the batch's copy of a balance, keeping the version the repository holds
+func (r *bufferedBalanceRepo) change(balance *repositories.Balance, entry *bufferedBalance) {
+	clone := *balance
+	if entry.balance != nil {
+		clone.ID = entry.balance.ID
+		clone.Version = entry.balance.Version
+	}
+
+	if !entry.dirty {
+		r.order = append(r.order, bufferedBalanceKey{portfolioID: clone.PortfolioID, securityID: balanceKey(clone.SecurityID)})
+	}
+	entry.balance = &clone
+	entry.dirty = true
+}
+
+// flush writes each changed balance once, stopping at the first failed write. It returns the
+// number of balances written.
+func (r *bufferedBalanceRepo) flush(ctx context.Context) (int, error) {
+	written := 0
+	for _, key := range r.order {
+		entry := r.entries[key]
+		balance := *entry.balance
+
+		var err error
+		if entry.stored {
+			err = r.BalanceRepository.Update(ctx, &balance)
+		} else {
+			err = r.BalanceRepository.Create(ctx, &balance)
+		}
+		if err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+// reset drops the balances held in memory so the next transactions read them again
+func (r *bufferedBalanceRepo) reset() {
+	r.entries = make(map[bufferedBalanceKey]*bufferedBalance)
+	r.order = nil
+}
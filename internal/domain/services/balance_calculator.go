@@ -2,7 +2,10 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/shopspring/decimal"
 
@@ -15,8 +18,10 @@ import (
 type BalanceCalculationResult struct {
 	SecurityBalance *models.Balance `json:"securityBalance,omitempty"`
 	CashBalance     *models.Balance `json:"cashBalance,omitempty"`
-	Success         bool            `json:"success"`
-	ErrorMessage    string          `json:"errorMessage,omitempty"`
+	// NotionalAmount is the transaction's unsigned quantity * price, set when it is applied
+	NotionalAmount decimal.Decimal `json:"notionalAmount"`
+	Success        bool            `json:"success"`
+	ErrorMessage   string          `json:"errorMessage,omitempty"`
 }
 
 // BalanceImpactSummary provides a summary of how a transaction affects balances
@@ -43,9 +48,75 @@ type BalanceChange struct {
 	ResultingShort decimal.Decimal `json:"resultingShort"`
 }
 
+// maxBalanceQuantity bounds the magnitude of a balance quantity; the balances table stores
+// quantities as DECIMAL(18,8), leaving 10 digits before the decimal point
+var maxBalanceQuantity = decimal.New(1, 10)
+
+// InsufficientPositionError is returned when a calculation would leave a balance holding a
+// position it cannot hold, such as a short cash position. Retrying cannot resolve it.
+type InsufficientPositionError struct {
+	PortfolioID string
+	SecurityID  *string
+	Reason      string
+}
+
+// Error implements the error interface
+func (e *InsufficientPositionError) Error() string {
+	return fmt.Sprintf("insufficient position in %s: %s", balanceDescription(e.PortfolioID, e.SecurityID), e.Reason)
+}
+
+// MissingBalanceError is returned when a balance the calculation requires does not exist
+type MissingBalanceError struct {
+	PortfolioID string
+	SecurityID  *string
+	Err         error
+}
+
+// Error implements the error interface
+func (e *MissingBalanceError) Error() string {
+	return fmt.Sprintf("%s not found", balanceDescription(e.PortfolioID, e.SecurityID))
+}
+
+// Unwrap returns the repository error reporting the missing balance
+func (e *MissingBalanceError) Unwrap() error {
+	return e.Err
+}
+
+// CalculationOverflowError is returned when a calculated quantity exceeds what a balance can
+// store. Retrying cannot resolve it.
+type CalculationOverflowError struct {
+	PortfolioID string
+	SecurityID  *string
+	Field       string
+	Value       decimal.Decimal
+}
+
+// Error implements the error interface
+func (e *CalculationOverflowError) Error() string {
+	return fmt.Sprintf("%s of %s overflows: %s exceeds the maximum magnitude of %s",
+		e.Field, balanceDescription(e.PortfolioID, e.SecurityID), e.Value.String(), maxBalanceQuantity.String())
+}
+
+// IsBalanceCalculationError reports whether err is one of the typed balance calculation errors
+func IsBalanceCalculationError(err error) bool {
+	var insufficient *InsufficientPositionError
+	var missing *MissingBalanceError
+	var overflow *CalculationOverflowError
+	return errors.As(err, &insufficient) || errors.As(err, &missing) || errors.As(err, &overflow)
+}
+
+// balanceDescription names a portfolio's cash or security balance for error messages
+func balanceDescription(portfolioID string, securityID *string) string {
+	if securityID == nil {
+		return fmt.Sprintf("cash balance of portfolio %s", portfolioID)
+	}
+	return fmt.Sprintf("balance of security %s in portfolio %s", *securityID, portfolioID)
+}
+
 // BalanceCalculator provides balance calculation services
 type BalanceCalculator struct {
 	balanceRepo repositories.BalanceRepository
+	dateBasis   models.DateBasis
 	logger      logger.Logger
 }
 
@@ -56,25 +127,27 @@ func NewBalanceCalculator(
 ) *BalanceCalculator {
 	return &BalanceCalculator{
 		balanceRepo: balanceRepo,
+		dateBasis:   models.DateBasisTrade,
 		logger:      logger,
 	}
 }
 
-// CalculateBalanceImpact calculates how a transaction will impact balances
-func (c *BalanceCalculator) CalculateBalanceImpact(ctx context.Context, transaction *models.Transaction) (*BalanceImpactSummary, error) {
-	summary := &BalanceImpactSummary{
-		TransactionID:   transaction.ID(),
-		PortfolioID:     transaction.PortfolioID().String(),
-		TransactionType: transaction.TransactionType().String(),
-		Quantity:        transaction.Quantity().Value(),
-		Price:           transaction.Price().Value(),
-		NotionalAmount:  transaction.CalculateNotionalAmount().Value(),
-	}
+// WithDateBasis selects whether the trade or the settlement date orders replayed
+// transactions and decides which transactions count towards an as-of date.
+// The trade date is used by default.
+func (c *BalanceCalculator) WithDateBasis(basis models.DateBasis) *BalanceCalculator {
+	c.dateBasis = basis
+	return c
+}
 
-	if !transaction.SecurityID().IsCash() {
-		summary.SecurityID = transaction.SecurityID().Value()
-	}
+// DateBasis returns the date basis used for balance timing
+func (c *BalanceCalculator) DateBasis() models.DateBasis {
+	return c.dateBasis
+}
 
+// CalculateBalanceImpact calculates how a transaction will impact balances
+func (c *BalanceCalculator) CalculateBalanceImpact(ctx context.Context, transaction *models.Transaction) (*BalanceImpactSummary, error) {
+	summary := newBalanceImpactSummary(transaction)
 	impact := transaction.GetBalanceImpact()
 
 	// Calculate security balance impact (if applicable)
@@ -98,6 +171,61 @@ func (c *BalanceCalculator) CalculateBalanceImpact(ctx context.Context, transact
 	return summary, nil
 }
 
+// CalculateBalanceImpactAgainst calculates how a transaction impacts the given balances of its
+// portfolio instead of the stored ones, e.g. balances replayed up to the transaction. A balance
+// missing from balances counts as zero.
+func (c *BalanceCalculator) CalculateBalanceImpactAgainst(transaction *models.Transaction, balances []*models.Balance) *BalanceImpactSummary {
+	summary := newBalanceImpactSummary(transaction)
+	impact := transaction.GetBalanceImpact()
+
+	var securityBalance, cashBalance *models.Balance
+	for _, balance := range balances {
+		switch {
+		case balance.SecurityID().IsCash():
+			cashBalance = balance
+		case balance.SecurityID().Equals(transaction.SecurityID()):
+			securityBalance = balance
+		}
+	}
+
+	if transaction.IsSecurityTransaction() {
+		currentLong, currentShort := decimal.Zero, decimal.Zero
+		if securityBalance != nil {
+			currentLong = securityBalance.QuantityLong().Value()
+			currentShort = securityBalance.QuantityShort().Value()
+		}
+		summary.SecurityImpact = securityChange(transaction, impact, currentLong, currentShort)
+	}
+
+	if impact.Cash != models.ImpactNone {
+		currentCash := decimal.Zero
+		if cashBalance != nil {
+			currentCash = cashBalance.QuantityLong().Value()
+		}
+		summary.CashImpact = cashChange(transaction, impact, currentCash)
+	}
+
+	return summary
+}
+
+// newBalanceImpactSummary describes a transaction without any balance impact yet
+func newBalanceImpactSummary(transaction *models.Transaction) *BalanceImpactSummary {
+	summary := &BalanceImpactSummary{
+		TransactionID:   transaction.ID(),
+		PortfolioID:     transaction.PortfolioID().String(),
+		TransactionType: transaction.TransactionType().String(),
+		Quantity:        transaction.Quantity().Value(),
+		Price:           transaction.Price().Value(),
+		NotionalAmount:  transaction.CalculateNotionalAmount().Value(),
+	}
+
+	if !transaction.SecurityID().IsCash() {
+		summary.SecurityID = transaction.SecurityID().Value()
+	}
+
+	return summary
+}
+
 // calculateSecurityImpact calculates the impact on security balances
 func (c *BalanceCalculator) calculateSecurityImpact(ctx context.Context, transaction *models.Transaction, impact models.BalanceImpact) (*BalanceChange, error) {
 	portfolioID := transaction.PortfolioID().String()
@@ -109,6 +237,17 @@ func (c *BalanceCalculator) calculateSecurityImpact(ctx context.Context, transac
 		return nil, fmt.Errorf("failed to get current security balance: %w", err)
 	}
 
+	currentLong, currentShort := decimal.Zero, decimal.Zero
+	if currentBalance != nil {
+		currentLong = currentBalance.QuantityLong
+		currentShort = currentBalance.QuantityShort
+	}
+
+	return securityChange(transaction, impact, currentLong, currentShort), nil
+}
+
+// securityChange calculates a transaction's change to a security balance holding the given quantities
+func securityChange(transaction *models.Transaction, impact models.BalanceImpact, currentLong, currentShort decimal.Decimal) *BalanceChange {
 	// Calculate changes
 	quantity := transaction.Quantity().Value()
 	longChange := decimal.Zero
@@ -128,26 +267,14 @@ func (c *BalanceCalculator) calculateSecurityImpact(ctx context.Context, transac
 		shortChange = quantity.Neg()
 	}
 
-	// Calculate resulting balances
-	resultingLong := decimal.Zero
-	resultingShort := decimal.Zero
-
-	if currentBalance != nil {
-		resultingLong = currentBalance.QuantityLong.Add(longChange)
-		resultingShort = currentBalance.QuantityShort.Add(shortChange)
-	} else {
-		resultingLong = longChange
-		resultingShort = shortChange
-	}
-
 	return &BalanceChange{
 		BalanceType:    "SECURITY",
 		LongChange:     longChange,
 		ShortChange:    shortChange,
 		NetChange:      longChange.Add(shortChange),
-		ResultingLong:  resultingLong,
-		ResultingShort: resultingShort,
-	}, nil
+		ResultingLong:  currentLong.Add(longChange),
+		ResultingShort: currentShort.Add(shortChange),
+	}
 }
 
 // calculateCashImpact calculates the impact on cash balances
@@ -160,42 +287,44 @@ func (c *BalanceCalculator) calculateCashImpact(ctx context.Context, transaction
 		return nil, fmt.Errorf("failed to get current cash balance: %w", err)
 	}
 
+	currentCash := decimal.Zero
+	if currentBalance != nil {
+		currentCash = currentBalance.QuantityLong
+	}
+
+	return cashChange(transaction, impact, currentCash), nil
+}
+
+// cashChange calculates a transaction's change to a cash balance holding the given amount
+func cashChange(transaction *models.Transaction, impact models.BalanceImpact, currentCash decimal.Decimal) *BalanceChange {
 	// Calculate cash change based on transaction type
-	var cashChange decimal.Decimal
+	var change decimal.Decimal
 
 	if transaction.IsCashTransaction() {
 		// For cash transactions, use the quantity directly
-		cashChange = transaction.Quantity().Value()
+		change = transaction.Quantity().Value()
 		if transaction.TransactionType() == models.TransactionTypeWd {
-			cashChange = cashChange.Neg()
+			change = change.Neg()
 		}
 	} else {
 		// For security transactions, calculate based on notional amount
 		notionalAmount := transaction.CalculateNotionalAmount().Value()
 		switch impact.Cash {
 		case models.ImpactIncrease:
-			cashChange = notionalAmount
+			change = notionalAmount
 		case models.ImpactDecrease:
-			cashChange = notionalAmount.Neg()
+			change = notionalAmount.Neg()
 		}
 	}
 
-	// Calculate resulting balance
-	resultingLong := decimal.Zero
-	if currentBalance != nil {
-		resultingLong = currentBalance.QuantityLong.Add(cashChange)
-	} else {
-		resultingLong = cashChange
-	}
-
 	return &BalanceChange{
 		BalanceType:    "CASH",
-		LongChange:     cashChange,
+		LongChange:     change,
 		ShortChange:    decimal.Zero, // Cash never has short positions
-		NetChange:      cashChange,
-		ResultingLong:  resultingLong,
+		NetChange:      change,
+		ResultingLong:  currentCash.Add(change),
 		ResultingShort: decimal.Zero,
-	}, nil
+	}
 }
 
 // ApplyTransactionToBalances applies a transaction to the relevant balances
@@ -211,6 +340,9 @@ func (c *BalanceCalculator) ApplyTransactionToBalances(ctx context.Context, tran
 	// Handle security balance update
 	if transaction.IsSecurityTransaction() {
 		securityBalance, err := c.applyToSecurityBalance(ctx, transaction, impact)
+		if err == nil {
+			err = checkQuantityRange(securityBalance)
+		}
 		if err != nil {
 			result.ErrorMessage = fmt.Sprintf("failed to apply to security balance: %v", err)
 			return result, err
@@ -221,6 +353,9 @@ func (c *BalanceCalculator) ApplyTransactionToBalances(ctx context.Context, tran
 	// Handle cash balance update
 	if impact.Cash != models.ImpactNone {
 		cashBalance, err := c.applyToCashBalance(ctx, transaction, impact, portfolioID)
+		if err == nil {
+			err = checkQuantityRange(cashBalance)
+		}
 		if err != nil {
 			result.ErrorMessage = fmt.Sprintf("failed to apply to cash balance: %v", err)
 			return result, err
@@ -228,6 +363,69 @@ func (c *BalanceCalculator) ApplyTransactionToBalances(ctx context.Context, tran
 		result.CashBalance = cashBalance
 	}
 
+	result.NotionalAmount = transaction.CalculateNotionalAmount().RoundToDecimalPlaces().Value()
+	result.Success = true
+	return result, nil
+}
+
+// ReverseTransactionFromBalances calculates the relevant balances with the impact of an already
+// applied transaction removed, undoing ApplyTransactionToBalances. The balances the transaction
+// was applied to must exist.
+func (c *BalanceCalculator) ReverseTransactionFromBalances(ctx context.Context, transaction *models.Transaction) (*BalanceCalculationResult, error) {
+	result := &BalanceCalculationResult{
+		Success: false,
+	}
+
+	impact := transaction.GetBalanceImpact()
+	portfolioID := transaction.PortfolioID().String()
+
+	if transaction.IsSecurityTransaction() {
+		securityID := transaction.SecurityID().Value()
+		current, err := c.balanceRepo.GetByPortfolioAndSecurity(ctx, portfolioID, securityID)
+		if err != nil {
+			result.ErrorMessage = fmt.Sprintf("failed to get security balance: %v", err)
+			if repositories.IsNotFoundError(err) {
+				return result, &MissingBalanceError{PortfolioID: portfolioID, SecurityID: securityID, Err: err}
+			}
+			return result, fmt.Errorf("failed to get security balance: %w", err)
+		}
+		balance, err := c.convertToDomainBalance(current)
+		if err != nil {
+			return result, fmt.Errorf("failed to convert balance: %w", err)
+		}
+
+		change := securityChange(transaction, impact, current.QuantityLong, current.QuantityShort)
+		result.SecurityBalance = balance.UpdateQuantities(
+			models.NewQuantity(current.QuantityLong.Sub(change.LongChange)),
+			models.NewQuantity(current.QuantityShort.Sub(change.ShortChange)))
+		if err := checkQuantityRange(result.SecurityBalance); err != nil {
+			return result, err
+		}
+	}
+
+	if impact.Cash != models.ImpactNone {
+		current, err := c.balanceRepo.GetCashBalance(ctx, portfolioID)
+		if err != nil {
+			result.ErrorMessage = fmt.Sprintf("failed to get cash balance: %v", err)
+			if repositories.IsNotFoundError(err) {
+				return result, &MissingBalanceError{PortfolioID: portfolioID, Err: err}
+			}
+			return result, fmt.Errorf("failed to get cash balance: %w", err)
+		}
+		balance, err := c.convertToDomainBalance(current)
+		if err != nil {
+			return result, fmt.Errorf("failed to convert cash balance: %w", err)
+		}
+
+		change := cashChange(transaction, impact, current.QuantityLong)
+		result.CashBalance = balance.UpdateQuantities(
+			models.NewQuantity(current.QuantityLong.Sub(change.LongChange)),
+			balance.QuantityShort())
+		if err := checkQuantityRange(result.CashBalance); err != nil {
+			return result, err
+		}
+	}
+
 	result.Success = true
 	return result, nil
 }
@@ -381,6 +579,322 @@ func (c *BalanceCalculator) convertToDomainBalance(repoBalance *repositories.Bal
 	return builder.Build()
 }
 
+// ReplayTransactionsAsOf re-derives a portfolio's balances as they stood at the end of
+// asOf, replaying only the transactions whose effective date under the calculator's
+// date basis falls on or before that day and the adjustments recorded by then. It returns
+// the number of transactions replayed.
+func (c *BalanceCalculator) ReplayTransactionsAsOf(portfolioID models.PortfolioID, transactions []*models.Transaction, adjustments []*repositories.BalanceAdjustment, asOf time.Time) ([]*models.Balance, int, error) {
+	cutoff := asOf.UTC().Truncate(24 * time.Hour)
+
+	replay := newBalanceReplay(c, portfolioID)
+	replayed := 0
+	for _, event := range c.replayOrder(transactions, adjustments) {
+		if event.date.After(cutoff) {
+			break
+		}
+		if _, _, err := replay.apply(event); err != nil {
+			return nil, 0, err
+		}
+		if event.transaction != nil {
+			replayed++
+		}
+	}
+	return replay.balances(), replayed, nil
+}
+
+// LedgerEntry is a transaction or a manual adjustment of a portfolio ledger with the balances it
+// left behind
+type LedgerEntry struct {
+	// Transaction is nil for an adjustment
+	Transaction *models.Transaction
+	// Adjustment is nil for a transaction
+	Adjustment *repositories.BalanceAdjustment
+	// EffectiveDate is the date the transaction counts from under the calculator's date basis, or
+	// the date the adjustment was recorded
+	EffectiveDate time.Time
+	// SecurityBalance and CashBalance are the balances after the entry; either is nil when the
+	// entry does not affect it
+	SecurityBalance *models.Balance
+	CashBalance     *models.Balance
+}
+
+// ReplayLedger replays a portfolio's transactions in order of their effective date, with its
+// adjustments at the dates they were recorded, and returns its balances as they stood before
+// from, followed by an entry for every transaction and adjustment from from through to. Entries
+// before from only build the opening balances and those after to are not replayed, so the
+// entries of a window match the same range of a ledger covering all dates.
+func (c *BalanceCalculator) ReplayLedger(portfolioID models.PortfolioID, transactions []*models.Transaction, adjustments []*repositories.BalanceAdjustment, from, to time.Time) ([]*models.Balance, []LedgerEntry, error) {
+	var opening []*models.Balance
+	entries := make([]LedgerEntry, 0)
+
+	err := c.ReplayLedgerEntries(portfolioID, transactions, adjustments, from, to,
+		func(balances []*models.Balance) error {
+			opening = balances
+			return nil
+		},
+		func(entry LedgerEntry) error {
+			entries = append(entries, entry)
+			return nil
+		})
+	if err != nil {
+		return nil, nil, err
+	}
+	return opening, entries, nil
+}
+
+// ReplayLedgerEntries replays the same window as ReplayLedger without collecting it: the opening
+// balances are passed to opening once, before the first entry, and every entry to emit as soon as
+// it is replayed. It stops at the first error of the replay, opening or emit.
+func (c *BalanceCalculator) ReplayLedgerEntries(portfolioID models.PortfolioID, transactions []*models.Transaction, adjustments []*repositories.BalanceAdjustment, from, to time.Time, opening func([]*models.Balance) error, emit func(LedgerEntry) error) error {
+	from = from.UTC().Truncate(24 * time.Hour)
+	to = to.UTC().Truncate(24 * time.Hour)
+
+	replay := newBalanceReplay(c, portfolioID)
+	opened := false
+
+	for _, event := range c.replayOrder(transactions, adjustments) {
+		if event.date.After(to) {
+			break
+		}
+		if !opened && !event.date.Before(from) {
+			opened = true
+			if err := opening(replay.balances()); err != nil {
+				return err
+			}
+		}
+
+		securityBalance, cashBalance, err := replay.apply(event)
+		if err != nil {
+			return err
+		}
+		if !event.date.Before(from) {
+			err := emit(LedgerEntry{
+				Transaction:     event.transaction,
+				Adjustment:      event.adjustment,
+				EffectiveDate:   event.date,
+				SecurityBalance: securityBalance,
+				CashBalance:     cashBalance,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !opened {
+		return opening(replay.balances())
+	}
+	return nil
+}
+
+// ReplayTransactions re-derives a portfolio's balances from scratch by applying
+// the given transactions in chronological order of their effective date, with the
+// adjustments at the dates they were recorded. No repository access is performed; the
+// returned balances carry no IDs or versions and must be reconciled with the persisted
+// balances by the caller.
+func (c *BalanceCalculator) ReplayTransactions(portfolioID models.PortfolioID, transactions []*models.Transaction, adjustments []*repositories.BalanceAdjustment) ([]*models.Balance, error) {
+	replay := newBalanceReplay(c, portfolioID)
+	for _, event := range c.replayOrder(transactions, adjustments) {
+		if _, _, err := replay.apply(event); err != nil {
+			return nil, err
+		}
+	}
+
+	return replay.balances(), nil
+}
+
+// replayEvent is a transaction or an adjustment at its place in a replay
+type replayEvent struct {
+	date        time.Time
+	transaction *models.Transaction
+	adjustment  *repositories.BalanceAdjustment
+}
+
+// replayOrder merges the transactions, in order of their effective date, with the adjustments
+// in the order they were recorded. An adjustment follows the transactions effective on the day
+// it was recorded.
+func (c *BalanceCalculator) replayOrder(transactions []*models.Transaction, adjustments []*repositories.BalanceAdjustment) []replayEvent {
+	ordered := make([]*models.Transaction, len(transactions))
+	copy(ordered, transactions)
+	models.SortTransactionsByEffectiveDate(ordered, c.dateBasis)
+
+	recorded := make([]*repositories.BalanceAdjustment, len(adjustments))
+	copy(recorded, adjustments)
+	sort.SliceStable(recorded, func(i, j int) bool {
+		if !recorded[i].CreatedAt.Equal(recorded[j].CreatedAt) {
+			return recorded[i].CreatedAt.Before(recorded[j].CreatedAt)
+		}
+		return recorded[i].ID < recorded[j].ID
+	})
+
+	events := make([]replayEvent, 0, len(ordered)+len(recorded))
+	next := 0
+	for _, transaction := range ordered {
+		effective := transaction.EffectiveDate(c.dateBasis)
+		for ; next < len(recorded) && adjustmentDate(recorded[next]).Before(effective); next++ {
+			events = append(events, replayEvent{date: adjustmentDate(recorded[next]), adjustment: recorded[next]})
+		}
+		events = append(events, replayEvent{date: effective, transaction: transaction})
+	}
+	for ; next < len(recorded); next++ {
+		events = append(events, replayEvent{date: adjustmentDate(recorded[next]), adjustment: recorded[next]})
+	}
+	return events
+}
+
+// adjustmentDate returns the day an adjustment counts from in a replay, the UTC date it was recorded
+func adjustmentDate(adjustment *repositories.BalanceAdjustment) time.Time {
+	return adjustment.CreatedAt.UTC().Truncate(24 * time.Hour)
+}
+
+// balanceReplay holds a portfolio's balances while its transactions are replayed in order
+type balanceReplay struct {
+	calculator  *BalanceCalculator
+	portfolioID models.PortfolioID
+
+	cash          *models.Balance
+	securities    map[string]*models.Balance
+	securityOrder []string
+}
+
+func newBalanceReplay(calculator *BalanceCalculator, portfolioID models.PortfolioID) *balanceReplay {
+	return &balanceReplay{
+		calculator:  calculator,
+		portfolioID: portfolioID,
+		securities:  make(map[string]*models.Balance),
+	}
+}
+
+// apply applies the next event and returns the security and cash balances it changed; either
+// is nil when the event does not affect it
+func (r *balanceReplay) apply(event replayEvent) (*models.Balance, *models.Balance, error) {
+	if event.adjustment != nil {
+		return r.adjust(event.adjustment)
+	}
+	return r.applyTransaction(event.transaction)
+}
+
+// adjust applies the deltas of a manual adjustment to its balance, creating the balance the way
+// the adjustment did when the portfolio had none
+func (r *balanceReplay) adjust(adjustment *repositories.BalanceAdjustment) (*models.Balance, *models.Balance, error) {
+	if adjustment.PortfolioID != r.portfolioID.String() {
+		return nil, nil, fmt.Errorf("adjustment %d belongs to portfolio %s, not %s",
+			adjustment.ID, adjustment.PortfolioID, r.portfolioID.String())
+	}
+
+	current := r.cash
+	if adjustment.SecurityID != nil {
+		current = r.securities[*adjustment.SecurityID]
+	}
+
+	long, short := decimal.Zero, decimal.Zero
+	if current != nil {
+		long, short = current.QuantityLong().Value(), current.QuantityShort().Value()
+	}
+	long = long.Add(adjustment.QuantityLongDelta)
+	short = short.Add(adjustment.QuantityShortDelta)
+
+	var updated *models.Balance
+	var err error
+	if current == nil {
+		updated, err = models.NewBalanceBuilder().
+			WithPortfolioID(adjustment.PortfolioID).
+			WithSecurityID(adjustment.SecurityID).
+			WithQuantityLong(long).
+			WithQuantityShort(short).
+			Build()
+	} else {
+		updated = current.UpdateQuantities(models.NewQuantity(long), models.NewQuantity(short))
+	}
+	if err == nil {
+		err = checkQuantityRange(updated)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to replay adjustment %d: %w", adjustment.ID, err)
+	}
+
+	if adjustment.SecurityID == nil {
+		r.cash = updated
+		return nil, updated, nil
+	}
+	if current == nil {
+		r.securityOrder = append(r.securityOrder, *adjustment.SecurityID)
+	}
+	r.securities[*adjustment.SecurityID] = updated
+	return updated, nil, nil
+}
+
+// applyTransaction applies the next transaction and returns the security and cash balances it
+// changed; either is nil when the transaction does not affect it
+func (r *balanceReplay) applyTransaction(transaction *models.Transaction) (*models.Balance, *models.Balance, error) {
+	c := r.calculator
+	if !transaction.PortfolioID().Equals(r.portfolioID) {
+		return nil, nil, fmt.Errorf("transaction %d belongs to portfolio %s, not %s",
+			transaction.ID(), transaction.PortfolioID().String(), r.portfolioID.String())
+	}
+
+	impact := transaction.GetBalanceImpact()
+
+	var securityBalance, cashBalance *models.Balance
+	if transaction.IsSecurityTransaction() {
+		key := transaction.SecurityID().String()
+		current, exists := r.securities[key]
+
+		var updated *models.Balance
+		var err error
+		if !exists {
+			updated, err = c.createNewSecurityBalance(transaction, impact)
+			r.securityOrder = append(r.securityOrder, key)
+		} else {
+			updated, err = current.ApplyTransactionImpact(transaction)
+		}
+		if err == nil {
+			err = checkQuantityRange(updated)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to replay transaction %d on security balance: %w", transaction.ID(), err)
+		}
+		r.securities[key] = updated
+		securityBalance = updated
+	}
+
+	if impact.Cash != models.ImpactNone {
+		var updated *models.Balance
+		var err error
+		switch {
+		case r.cash == nil:
+			updated, err = c.createNewCashBalance(transaction, impact, r.portfolioID)
+		case transaction.IsCashTransaction():
+			updated, err = r.cash.ApplyTransactionImpact(transaction)
+		default:
+			updated, err = c.applyCashImpactFromSecurityTransaction(r.cash, transaction, impact)
+		}
+		if err == nil {
+			err = checkQuantityRange(updated)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to replay transaction %d on cash balance: %w", transaction.ID(), err)
+		}
+		r.cash = updated
+		cashBalance = updated
+	}
+
+	return securityBalance, cashBalance, nil
+}
+
+// balances returns the replayed balances, cash first and then securities in the order they
+// were first traded
+func (r *balanceReplay) balances() []*models.Balance {
+	balances := make([]*models.Balance, 0, len(r.securities)+1)
+	if r.cash != nil {
+		balances = append(balances, r.cash)
+	}
+	for _, key := range r.securityOrder {
+		balances = append(balances, r.securities[key])
+	}
+	return balances
+}
+
 // ValidateBalanceConstraints validates that balance operations don't violate constraints
 func (c *BalanceCalculator) ValidateBalanceConstraints(ctx context.Context, transaction *models.Transaction, balanceResult *BalanceCalculationResult) error {
 	// Validate security balance constraints
@@ -411,7 +925,10 @@ func (c *BalanceCalculator) validateSecurityBalanceConstraints(balance *models.B
 func (c *BalanceCalculator) validateCashBalanceConstraints(balance *models.Balance) error {
 	// Cash balances should not have short positions
 	if !balance.QuantityShort().IsZero() {
-		return fmt.Errorf("cash balances cannot have short positions")
+		return &InsufficientPositionError{
+			PortfolioID: balance.PortfolioID().String(),
+			Reason:      "cash balances cannot have short positions",
+		}
 	}
 
 	// Additional constraint: could check for negative cash if overdrafts are not allowed
@@ -421,3 +938,27 @@ func (c *BalanceCalculator) validateCashBalanceConstraints(balance *models.Balan
 
 	return nil
 }
+
+// checkQuantityRange returns a CalculationOverflowError when a quantity of the balance is too
+// large to be stored
+func checkQuantityRange(balance *models.Balance) error {
+	quantities := []struct {
+		field string
+		value decimal.Decimal
+	}{
+		{"quantityLong", balance.QuantityLong().Value()},
+		{"quantityShort", balance.QuantityShort().Value()},
+	}
+
+	for _, quantity := range quantities {
+		if quantity.value.Abs().GreaterThanOrEqual(maxBalanceQuantity) {
+			return &CalculationOverflowError{
+				PortfolioID: balance.PortfolioID().String(),
+				SecurityID:  balance.SecurityID().Value(),
+				Field:       quantity.field,
+				Value:       quantity.value,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,458 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+const (
+	testPortfolioID = "PORTFOLIO123456789012345"
+	testSecurityID  = "SECURITY1234567890123456"
+)
+
+func buildReplayTransaction(t *testing.T, id int64, txnType string, quantity, price int64, date time.Time) *models.Transaction {
+	t.Helper()
+
+	builder := models.NewTransactionBuilder().
+		WithID(id).
+		WithPortfolioID(testPortfolioID).
+		WithSourceID(fmt.Sprintf("SOURCE%03d", id)).
+		WithTransactionType(txnType).
+		WithStatus("PROC").
+		WithQuantity(decimal.NewFromInt(quantity)).
+		WithPrice(decimal.NewFromInt(price)).
+		WithTransactionDate(date).
+		WithTimestamps(date, date)
+
+	if txnType != "DEP" && txnType != "WD" {
+		builder = builder.WithSecurityIDFromString(testSecurityID)
+	}
+
+	transaction, err := builder.Build()
+	require.NoError(t, err)
+	return transaction
+}
+
+func TestBalanceCalculator_ReplayTransactions(t *testing.T) {
+	calculator := NewBalanceCalculator(nil, logger.NewNoop())
+	portfolioID, err := models.NewPortfolioID(testPortfolioID)
+	require.NoError(t, err)
+
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	ordered := []*models.Transaction{
+		buildReplayTransaction(t, 1, "DEP", 10000, 1, day(1)),
+		buildReplayTransaction(t, 2, "BUY", 100, 50, day(2)),
+		buildReplayTransaction(t, 3, "SELL", 40, 60, day(3)),
+		buildReplayTransaction(t, 4, "SHORT", 10, 55, day(4)),
+		buildReplayTransaction(t, 5, "WD", 500, 1, day(5)),
+	}
+
+	// Same transactions ingested out of order
+	outOfOrder := []*models.Transaction{ordered[3], ordered[1], ordered[4], ordered[0], ordered[2]}
+
+	t.Run("Out-of-order ingestion matches ordered replay", func(t *testing.T) {
+		expected, err := calculator.ReplayTransactions(portfolioID, ordered, nil)
+		require.NoError(t, err)
+
+		actual, err := calculator.ReplayTransactions(portfolioID, outOfOrder, nil)
+		require.NoError(t, err)
+
+		require.Len(t, actual, len(expected))
+		for i := range expected {
+			assert.True(t, expected[i].SecurityID().Equals(actual[i].SecurityID()))
+			assert.True(t, expected[i].QuantityLong().Value().Equal(actual[i].QuantityLong().Value()))
+			assert.True(t, expected[i].QuantityShort().Value().Equal(actual[i].QuantityShort().Value()))
+		}
+	})
+
+	t.Run("Replayed balances", func(t *testing.T) {
+		balances, err := calculator.ReplayTransactions(portfolioID, outOfOrder, nil)
+		require.NoError(t, err)
+		require.Len(t, balances, 2)
+
+		// Cash: 10000 - 5000 + 2400 + 550 - 500
+		cash := balances[0]
+		assert.True(t, cash.IsCashBalance())
+		assert.True(t, decimal.NewFromInt(7450).Equal(cash.QuantityLong().Value()))
+		assert.True(t, cash.QuantityShort().IsZero())
+
+		security := balances[1]
+		assert.Equal(t, testSecurityID, security.SecurityID().String())
+		assert.True(t, decimal.NewFromInt(60).Equal(security.QuantityLong().Value()))
+		assert.True(t, decimal.NewFromInt(10).Equal(security.QuantityShort().Value()))
+	})
+
+	t.Run("Does not reorder the caller's slice", func(t *testing.T) {
+		input := []*models.Transaction{ordered[2], ordered[0]}
+		_, err := calculator.ReplayTransactions(portfolioID, input, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), input[0].ID())
+	})
+
+	t.Run("Rejects transactions from another portfolio", func(t *testing.T) {
+		other, err := models.NewPortfolioID("OTHERPORTFOLIO1234567890")
+		require.NoError(t, err)
+
+		_, err = calculator.ReplayTransactions(other, ordered, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("No transactions yields no balances", func(t *testing.T) {
+		balances, err := calculator.ReplayTransactions(portfolioID, nil, nil)
+		require.NoError(t, err)
+		assert.Empty(t, balances)
+	})
+}
+
+func TestBalanceCalculator_ReplayLedger(t *testing.T) {
+	calculator := NewBalanceCalculator(nil, logger.NewNoop())
+	portfolioID, err := models.NewPortfolioID(testPortfolioID)
+	require.NoError(t, err)
+
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	transactions := []*models.Transaction{
+		buildReplayTransaction(t, 6, "WD", 500, 1, day(8)),
+		buildReplayTransaction(t, 2, "BUY", 100, 50, day(2)),
+		buildReplayTransaction(t, 4, "SHORT", 10, 55, day(5)),
+		buildReplayTransaction(t, 1, "DEP", 10000, 1, day(1)),
+		buildReplayTransaction(t, 3, "SELL", 40, 60, day(3)),
+		buildReplayTransaction(t, 5, "DEP", 250, 1, day(5)),
+	}
+
+	full, fullEntries, err := calculator.ReplayLedger(portfolioID, transactions, nil, day(1), day(31))
+	require.NoError(t, err)
+	assert.Empty(t, full)
+	require.Len(t, fullEntries, 6)
+
+	// Every balance a transaction leaves behind is an independent snapshot
+	assert.True(t, decimal.NewFromInt(10000).Equal(fullEntries[0].CashBalance.QuantityLong().Value()))
+	assert.Nil(t, fullEntries[0].SecurityBalance)
+	assert.True(t, decimal.NewFromInt(100).Equal(fullEntries[1].SecurityBalance.QuantityLong().Value()))
+	assert.True(t, decimal.NewFromInt(5000).Equal(fullEntries[1].CashBalance.QuantityLong().Value()))
+
+	quantities := func(balance *models.Balance) string {
+		if balance == nil {
+			return ""
+		}
+		return balance.QuantityLong().Value().String() + "/" + balance.QuantityShort().Value().String()
+	}
+
+	windows := []struct {
+		name     string
+		from, to time.Time
+		ids      []int64
+	}{
+		{name: "middle of the ledger", from: day(3), to: day(5), ids: []int64{3, 5, 4}},
+		{name: "single day", from: day(5), to: day(5), ids: []int64{5, 4}},
+		{name: "no transactions in the window", from: day(6), to: day(7)},
+		{name: "after the last transaction", from: day(9), to: day(31)},
+		{name: "before the first transaction", from: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC), to: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, window := range windows {
+		t.Run("Window matches the full ledger: "+window.name, func(t *testing.T) {
+			opening, entries, err := calculator.ReplayLedger(portfolioID, transactions, nil, window.from, window.to)
+			require.NoError(t, err)
+
+			var overlapping []LedgerEntry
+			var before []*models.Transaction
+			for _, entry := range fullEntries {
+				switch {
+				case entry.EffectiveDate.Before(window.from):
+					before = append(before, entry.Transaction)
+				case !entry.EffectiveDate.After(window.to):
+					overlapping = append(overlapping, entry)
+				}
+			}
+
+			ids := make([]int64, 0, len(entries))
+			require.Len(t, entries, len(overlapping))
+			for i, entry := range entries {
+				ids = append(ids, entry.Transaction.ID())
+				assert.Equal(t, overlapping[i].Transaction.ID(), entry.Transaction.ID())
+				assert.Equal(t, overlapping[i].EffectiveDate, entry.EffectiveDate)
+				assert.Equal(t, quantities(overlapping[i].SecurityBalance), quantities(entry.SecurityBalance))
+				assert.Equal(t, quantities(overlapping[i].CashBalance), quantities(entry.CashBalance))
+			}
+			if window.ids != nil {
+				assert.Equal(t, window.ids, ids)
+			}
+
+			// The opening balances are those the transactions before the window leave behind
+			expected, err := calculator.ReplayTransactions(portfolioID, before, nil)
+			require.NoError(t, err)
+			require.Len(t, opening, len(expected))
+			for i := range expected {
+				assert.True(t, expected[i].SecurityID().Equals(opening[i].SecurityID()))
+				assert.Equal(t, quantities(expected[i]), quantities(opening[i]))
+			}
+		})
+	}
+
+	t.Run("Entries are emitted as they are replayed", func(t *testing.T) {
+		stop := errors.New("client went away")
+		var events []string
+
+		err := calculator.ReplayLedgerEntries(portfolioID, transactions, nil, day(3), day(31),
+			func(balances []*models.Balance) error {
+				events = append(events, "opening")
+				return nil
+			},
+			func(entry LedgerEntry) error {
+				events = append(events, strconv.FormatInt(entry.Transaction.ID(), 10))
+				if entry.Transaction.ID() == 5 {
+					return stop
+				}
+				return nil
+			})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, []string{"opening", "3", "5"}, events)
+	})
+}
+
+func TestBalanceCalculator_ReplayAdjustments(t *testing.T) {
+	calculator := NewBalanceCalculator(nil, logger.NewNoop())
+	portfolioID, err := models.NewPortfolioID(testPortfolioID)
+	require.NoError(t, err)
+
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	securityID := testSecurityID
+	transactions := []*models.Transaction{
+		buildReplayTransaction(t, 1, "DEP", 10000, 1, day(1)),
+		buildReplayTransaction(t, 2, "BUY", 100, 50, day(3)),
+	}
+	// Recorded during day 1 after the deposit and on day 4 after the buy
+	adjustments := []*repositories.BalanceAdjustment{
+		{ID: 2, PortfolioID: testPortfolioID, SecurityID: &securityID, QuantityLongDelta: decimal.NewFromInt(-5), QuantityShortDelta: decimal.Zero, CreatedAt: day(4).Add(9 * time.Hour)},
+		{ID: 1, PortfolioID: testPortfolioID, QuantityLongDelta: decimal.NewFromInt(-200), QuantityShortDelta: decimal.Zero, CreatedAt: day(1).Add(9 * time.Hour)},
+	}
+
+	t.Run("Adjustments are part of the replayed balances", func(t *testing.T) {
+		balances, err := calculator.ReplayTransactions(portfolioID, transactions, adjustments)
+		require.NoError(t, err)
+		require.Len(t, balances, 2)
+		assert.Equal(t, "4800", balances[0].QuantityLong().Value().String())
+		assert.Equal(t, "95", balances[1].QuantityLong().Value().String())
+	})
+
+	t.Run("An adjustment creates the balance it adjusts", func(t *testing.T) {
+		balances, err := calculator.ReplayTransactions(portfolioID, nil, adjustments[:1])
+		require.NoError(t, err)
+		require.Len(t, balances, 1)
+		assert.Equal(t, securityID, *balances[0].SecurityID().Value())
+		assert.Equal(t, "-5", balances[0].QuantityLong().Value().String())
+	})
+
+	t.Run("As-of balances leave out later adjustments", func(t *testing.T) {
+		balances, replayed, err := calculator.ReplayTransactionsAsOf(portfolioID, transactions, adjustments, day(3))
+		require.NoError(t, err)
+		assert.Equal(t, 2, replayed, "only transactions are counted")
+		require.Len(t, balances, 2)
+		assert.Equal(t, "4800", balances[0].QuantityLong().Value().String())
+		assert.Equal(t, "100", balances[1].QuantityLong().Value().String())
+	})
+
+	t.Run("Ledger lists adjustments after the transactions of their day", func(t *testing.T) {
+		opening, entries, err := calculator.ReplayLedger(portfolioID, transactions, adjustments, day(2), day(31))
+		require.NoError(t, err)
+		require.Len(t, opening, 1)
+		assert.Equal(t, "9800", opening[0].QuantityLong().Value().String())
+
+		require.Len(t, entries, 2)
+		assert.Equal(t, int64(2), entries[0].Transaction.ID())
+		assert.Equal(t, "4800", entries[0].CashBalance.QuantityLong().Value().String())
+		assert.Nil(t, entries[1].Transaction)
+		assert.Equal(t, int64(2), entries[1].Adjustment.ID)
+		assert.Equal(t, day(4), entries[1].EffectiveDate)
+		assert.Nil(t, entries[1].CashBalance)
+		assert.Equal(t, "95", entries[1].SecurityBalance.QuantityLong().Value().String())
+	})
+}
+
+func TestBalanceCalculator_ReplayTransactionsAsOf(t *testing.T) {
+	portfolioID, err := models.NewPortfolioID(testPortfolioID)
+	require.NoError(t, err)
+
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	// The buy trades on day 2 and settles on day 4
+	settles := day(4)
+	buy, err := models.NewTransactionBuilder().
+		WithID(2).
+		WithPortfolioID(testPortfolioID).
+		WithSecurityIDFromString(testSecurityID).
+		WithSourceID("SOURCE002").
+		WithTransactionType("BUY").
+		WithStatus("PROC").
+		WithQuantity(decimal.NewFromInt(100)).
+		WithPrice(decimal.NewFromInt(50)).
+		WithTransactionDate(day(2)).
+		WithSettlementDate(&settles).
+		WithTimestamps(day(2), day(2)).
+		Build()
+	require.NoError(t, err)
+
+	transactions := []*models.Transaction{
+		buildReplayTransaction(t, 1, "DEP", 10000, 1, day(1)),
+		buy,
+	}
+
+	positions := func(balances []*models.Balance) (cash, security decimal.Decimal) {
+		for _, balance := range balances {
+			if balance.IsCashBalance() {
+				cash = balance.QuantityLong().Value()
+			} else {
+				security = balance.QuantityLong().Value()
+			}
+		}
+		return cash, security
+	}
+
+	t.Run("Trade basis counts the buy from its trade date", func(t *testing.T) {
+		calculator := NewBalanceCalculator(nil, logger.NewNoop())
+
+		balances, replayed, err := calculator.ReplayTransactionsAsOf(portfolioID, transactions, nil, day(3))
+		require.NoError(t, err)
+		assert.Equal(t, 2, replayed)
+
+		cash, security := positions(balances)
+		assert.True(t, decimal.NewFromInt(5000).Equal(cash), "cash: %s", cash)
+		assert.True(t, decimal.NewFromInt(100).Equal(security), "security: %s", security)
+	})
+
+	t.Run("Settlement basis leaves the buy out until it settles", func(t *testing.T) {
+		calculator := NewBalanceCalculator(nil, logger.NewNoop()).WithDateBasis(models.DateBasisSettlement)
+
+		balances, replayed, err := calculator.ReplayTransactionsAsOf(portfolioID, transactions, nil, day(3))
+		require.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+
+		cash, security := positions(balances)
+		assert.True(t, decimal.NewFromInt(10000).Equal(cash), "cash: %s", cash)
+		assert.True(t, security.IsZero(), "security: %s", security)
+		assert.Len(t, balances, 1, "no security balance before settlement")
+	})
+
+	t.Run("Both bases agree once the buy has settled", func(t *testing.T) {
+		for _, basis := range []models.DateBasis{models.DateBasisTrade, models.DateBasisSettlement} {
+			calculator := NewBalanceCalculator(nil, logger.NewNoop()).WithDateBasis(basis)
+
+			balances, replayed, err := calculator.ReplayTransactionsAsOf(portfolioID, transactions, nil, day(4))
+			require.NoError(t, err)
+			assert.Equal(t, 2, replayed, basis)
+
+			cash, security := positions(balances)
+			assert.True(t, decimal.NewFromInt(5000).Equal(cash), "%s cash: %s", basis, cash)
+			assert.True(t, decimal.NewFromInt(100).Equal(security), "%s security: %s", basis, security)
+		}
+	})
+}
+
+func TestBalanceCalculator_TypedErrors(t *testing.T) {
+	ctx := context.Background()
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	securityID := testSecurityID
+
+	t.Run("Reversal without the security balance is a missing balance", func(t *testing.T) {
+		calculator := NewBalanceCalculator(&memoryBalanceRepo{}, logger.NewNoop())
+
+		_, err := calculator.ReverseTransactionFromBalances(ctx, buildReplayTransaction(t, 1, "BUY", 10, 50, date))
+		var missing *MissingBalanceError
+		require.ErrorAs(t, err, &missing)
+		assert.Equal(t, testPortfolioID, missing.PortfolioID)
+		require.NotNil(t, missing.SecurityID)
+		assert.Equal(t, testSecurityID, *missing.SecurityID)
+		assert.True(t, repositories.IsNotFoundError(err), "the repository error is kept")
+		assert.True(t, IsBalanceCalculationError(err))
+	})
+
+	t.Run("Reversal without the cash balance is a missing balance", func(t *testing.T) {
+		calculator := NewBalanceCalculator(&memoryBalanceRepo{}, logger.NewNoop())
+
+		_, err := calculator.ReverseTransactionFromBalances(ctx, buildReplayTransaction(t, 1, "DEP", 100, 1, date))
+		var missing *MissingBalanceError
+		require.ErrorAs(t, err, &missing)
+		assert.Nil(t, missing.SecurityID)
+	})
+
+	t.Run("Short cash position is an insufficient position", func(t *testing.T) {
+		calculator := NewBalanceCalculator(nil, logger.NewNoop())
+		cash, err := models.NewBalanceBuilder().
+			WithPortfolioID(testPortfolioID).
+			WithQuantityLong(decimal.NewFromInt(100)).
+			Build()
+		require.NoError(t, err)
+
+		transaction := buildReplayTransaction(t, 1, "DEP", 100, 1, date)
+		err = calculator.ValidateBalanceConstraints(ctx, transaction, &BalanceCalculationResult{
+			CashBalance: cash.UpdateQuantities(cash.QuantityLong(), models.NewQuantity(decimal.NewFromInt(5))),
+		})
+		var insufficient *InsufficientPositionError
+		require.ErrorAs(t, err, &insufficient)
+		assert.Equal(t, testPortfolioID, insufficient.PortfolioID)
+		assert.Nil(t, insufficient.SecurityID)
+	})
+
+	t.Run("Applying past the storable quantity overflows", func(t *testing.T) {
+		calculator := NewBalanceCalculator(&memoryBalanceRepo{balances: []*repositories.Balance{
+			{ID: 1, PortfolioID: testPortfolioID, SecurityID: &securityID, QuantityLong: decimal.RequireFromString("9999999995"), Version: 1},
+		}}, logger.NewNoop())
+
+		result, err := calculator.ApplyTransactionToBalances(ctx, buildReplayTransaction(t, 1, "BUY", 10, 1, date))
+		var overflow *CalculationOverflowError
+		require.ErrorAs(t, err, &overflow)
+		assert.Equal(t, "quantityLong", overflow.Field)
+		assert.Equal(t, "10000000005", overflow.Value.String())
+		require.NotNil(t, overflow.SecurityID)
+		assert.Equal(t, testSecurityID, *overflow.SecurityID)
+		assert.False(t, result.Success)
+	})
+
+	t.Run("Quantities up to the storable maximum apply", func(t *testing.T) {
+		calculator := NewBalanceCalculator(&memoryBalanceRepo{balances: []*repositories.Balance{
+			{ID: 1, PortfolioID: testPortfolioID, SecurityID: &securityID, QuantityLong: decimal.RequireFromString("9999999989"), Version: 1},
+		}}, logger.NewNoop())
+
+		result, err := calculator.ApplyTransactionToBalances(ctx, buildReplayTransaction(t, 1, "BUY", 10, 1, date))
+		require.NoError(t, err)
+		assert.Equal(t, "9999999999", result.SecurityBalance.QuantityLong().Value().String())
+	})
+
+	t.Run("Replaying past the storable quantity overflows", func(t *testing.T) {
+		calculator := NewBalanceCalculator(nil, logger.NewNoop())
+		portfolioID, err := models.NewPortfolioID(testPortfolioID)
+		require.NoError(t, err)
+
+		_, err = calculator.ReplayTransactions(portfolioID, []*models.Transaction{
+			buildReplayTransaction(t, 1, "DEP", 6000000000, 1, date),
+			buildReplayTransaction(t, 2, "DEP", 6000000000, 1, date.AddDate(0, 0, 1)),
+		}, nil)
+		var overflow *CalculationOverflowError
+		require.ErrorAs(t, err, &overflow)
+		assert.Nil(t, overflow.SecurityID)
+		assert.Equal(t, "12000000000", overflow.Value.String())
+	})
+}
@@ -2,9 +2,13 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
@@ -16,6 +20,7 @@ type ProcessingResult struct {
 	Status           models.TransactionStatus  `json:"status"`
 	Success          bool                      `json:"success"`
 	ErrorMessage     string                    `json:"errorMessage,omitempty"`
+	Retryable        bool                      `json:"retryable,omitempty"`
 	BalanceChanges   *BalanceCalculationResult `json:"balanceChanges,omitempty"`
 	ProcessingTime   time.Duration             `json:"processingTime"`
 	ValidationErrors []ValidationError         `json:"validationErrors,omitempty"`
@@ -39,6 +44,117 @@ type ProcessingSummary struct {
 	ErrorCategories   map[string]int    `json:"errorCategories"`
 }
 
+// RecomputeResult represents the outcome of re-deriving a portfolio's balances
+type RecomputeResult struct {
+	PortfolioID          string            `json:"portfolioId"`
+	TransactionsReplayed int               `json:"transactionsReplayed"`
+	BalancesCreated      int               `json:"balancesCreated"`
+	BalancesUpdated      int               `json:"balancesUpdated"`
+	BalancesZeroed       int               `json:"balancesZeroed"`
+	Balances             []*models.Balance `json:"balances"`
+	ProcessingTime       time.Duration     `json:"processingTime"`
+}
+
+// AsOfBalancesResult represents a portfolio's balances re-derived as of a past date
+type AsOfBalancesResult struct {
+	PortfolioID          string            `json:"portfolioId"`
+	AsOf                 time.Time         `json:"asOf"`
+	DateBasis            models.DateBasis  `json:"dateBasis"`
+	TransactionsReplayed int               `json:"transactionsReplayed"`
+	Balances             []*models.Balance `json:"balances"`
+}
+
+// LedgerResult holds a window of a portfolio's ledger re-derived from its processed transactions
+type LedgerResult struct {
+	PortfolioID string
+	From        time.Time
+	To          time.Time
+	DateBasis   models.DateBasis
+	// OpeningBalances are the portfolio's balances before From
+	OpeningBalances []*models.Balance
+	Entries         []LedgerEntry
+	// TransactionsReplayed counts the transactions replayed for the opening balances and entries
+	TransactionsReplayed int
+}
+
+// DiscrepancyKind classifies a difference between stored and recomputed balances
+type DiscrepancyKind string
+
+const (
+	DiscrepancyMismatch   DiscrepancyKind = "MISMATCH"   // stored quantities differ from the replay
+	DiscrepancyMissing    DiscrepancyKind = "MISSING"    // replay produces a non-zero balance that is not stored
+	DiscrepancyUnexpected DiscrepancyKind = "UNEXPECTED" // non-zero stored balance without supporting transactions
+)
+
+// BalanceDiscrepancy describes a stored balance that drifted from its processed transactions
+type BalanceDiscrepancy struct {
+	Kind                  DiscrepancyKind `json:"kind"`
+	BalanceID             int64           `json:"balanceId,omitempty"`
+	SecurityID            *string         `json:"securityId,omitempty"`
+	ExpectedQuantityLong  decimal.Decimal `json:"expectedQuantityLong"`
+	ExpectedQuantityShort decimal.Decimal `json:"expectedQuantityShort"`
+	StoredQuantityLong    decimal.Decimal `json:"storedQuantityLong"`
+	StoredQuantityShort   decimal.Decimal `json:"storedQuantityShort"`
+}
+
+// ConsistencyReport represents the outcome of comparing stored balances with a replay of processed transactions
+type ConsistencyReport struct {
+	PortfolioID          string               `json:"portfolioId"`
+	TransactionsReplayed int                  `json:"transactionsReplayed"`
+	BalancesChecked      int                  `json:"balancesChecked"`
+	Discrepancies        []BalanceDiscrepancy `json:"discrepancies"`
+	ProcessingTime       time.Duration        `json:"processingTime"`
+}
+
+// IsConsistent returns true if no discrepancies were found
+func (r *ConsistencyReport) IsConsistent() bool {
+	return len(r.Discrepancies) == 0
+}
+
+// BalanceChangeLogLevel controls whether and at which level applied balance changes are logged
+type BalanceChangeLogLevel string
+
+const (
+	BalanceChangeLogOff   BalanceChangeLogLevel = "off"
+	BalanceChangeLogDebug BalanceChangeLogLevel = "debug"
+	BalanceChangeLogInfo  BalanceChangeLogLevel = "info"
+)
+
+// IsValid checks if the balance change log level is valid
+func (l BalanceChangeLogLevel) IsValid() bool {
+	switch l {
+	case BalanceChangeLogOff, BalanceChangeLogDebug, BalanceChangeLogInfo:
+		return true
+	}
+	return false
+}
+
+// ImpactState selects the balances a transaction's balance impact is computed against
+type ImpactState string
+
+const (
+	// ImpactStateProcessing replays the processed transactions that preceded the transaction
+	ImpactStateProcessing ImpactState = "processing"
+	// ImpactStateCurrent uses the stored balances as they are now
+	ImpactStateCurrent ImpactState = "current"
+)
+
+// IsValid checks if the impact state is valid
+func (s ImpactState) IsValid() bool {
+	switch s {
+	case ImpactStateProcessing, ImpactStateCurrent:
+		return true
+	}
+	return false
+}
+
+// ErrTransactionNotProcessed is returned when the balances at processing time are requested
+// for a transaction that has not been processed
+var ErrTransactionNotProcessed = errors.New("transaction has not been processed")
+
+// recomputePageSize is the number of transactions loaded per query during recompute
+const recomputePageSize = 1000
+
 // TransactionProcessor orchestrates transaction processing
 type TransactionProcessor struct {
 	transactionRepo repositories.TransactionRepository
@@ -46,6 +162,20 @@ type TransactionProcessor struct {
 	validator       *TransactionValidator
 	calculator      *BalanceCalculator
 	logger          logger.Logger
+
+	balanceChangeLogLevel BalanceChangeLogLevel
+	allowForcedReprocess  bool
+	storeNotionalAmounts  bool
+
+	// adjustmentRepo supplies the manual adjustments replayed with the transactions; nil replays
+	// transactions only
+	adjustmentRepo repositories.BalanceAdjustmentRepository
+
+	// unitOfWork makes the writes of processing atomic; nil writes them one at a time
+	unitOfWork repositories.UnitOfWork
+
+	// batch holds processed transactions until their balances are written; nil outside a BalanceBatch
+	batch *BalanceBatch
 }
 
 // NewTransactionProcessor creates a new transaction processor
@@ -62,9 +192,61 @@ func NewTransactionProcessor(
 		validator:       validator,
 		calculator:      calculator,
 		logger:          logger,
+
+		balanceChangeLogLevel: BalanceChangeLogOff,
 	}
 }
 
+// WithBalanceChangeLogLevel enables a structured log entry for every balance change applied.
+// Logging is off by default to avoid an entry per balance write in production.
+func (p *TransactionProcessor) WithBalanceChangeLogLevel(level BalanceChangeLogLevel) *TransactionProcessor {
+	p.balanceChangeLogLevel = level
+	return p
+}
+
+// WithForcedReprocessing allows processing PROC transactions again. Their balance impact is
+// reversed before they are applied, so a forced reprocess never counts a transaction twice; both
+// are written in the unit of work set with WithUnitOfWork, without which a forced reprocess is
+// refused. Forced reprocessing is off by default.
+func (p *TransactionProcessor) WithForcedReprocessing(allowed bool) *TransactionProcessor {
+	p.allowForcedReprocess = allowed
+	return p
+}
+
+// WithNotionalAmounts stores the notional amount the calculator computes on every transaction
+// it processes, for reporting queries that aggregate trade value. Off by default.
+func (p *TransactionProcessor) WithNotionalAmounts(enabled bool) *TransactionProcessor {
+	p.storeNotionalAmounts = enabled
+	return p
+}
+
+// WithBalanceAdjustments replays a portfolio's manual balance adjustments together with its
+// processed transactions wherever balances are re-derived, so recompute, the consistency check,
+// the as-of balances, the ledger and the impact at processing time account for them
+func (p *TransactionProcessor) WithBalanceAdjustments(adjustmentRepo repositories.BalanceAdjustmentRepository) *TransactionProcessor {
+	p.adjustmentRepo = adjustmentRepo
+	return p
+}
+
+// WithUnitOfWork writes the balance changes and the status of processing in one unit of work,
+// so a failure never leaves part of a transaction's impact in the balances. Without one the
+// writes are made one at a time.
+func (p *TransactionProcessor) WithUnitOfWork(unitOfWork repositories.UnitOfWork) *TransactionProcessor {
+	p.unitOfWork = unitOfWork
+	return p
+}
+
+// CanProcess reports whether the transaction's status may move to PROC under the processor's
+// transition rules (see models.TransactionStatus.CanTransitionToProc)
+func (p *TransactionProcessor) CanProcess(transaction *models.Transaction) bool {
+	return transaction.Status().CanTransitionToProc(p.allowForcedReprocess)
+}
+
+// DateBasis returns the date basis the processor replays transactions under
+func (p *TransactionProcessor) DateBasis() models.DateBasis {
+	return p.calculator.DateBasis()
+}
+
 // ProcessTransaction processes a single transaction through the complete workflow
 func (p *TransactionProcessor) ProcessTransaction(ctx context.Context, transaction *models.Transaction) (*ProcessingResult, error) {
 	startTime := time.Now()
@@ -80,21 +262,49 @@ func (p *TransactionProcessor) ProcessTransaction(ctx context.Context, transacti
 		logger.String("sourceId", transaction.SourceID().String()),
 		logger.String("type", transaction.TransactionType().String()))
 
-	// Step 1: Validate transaction for processing
-	validationResult := p.validator.ValidateTransactionForProcessing(ctx, transaction)
+	// Step 0: Check the status transition. A transaction that may not be processed keeps its
+	// status; a forced reprocess of a PROC transaction still holds its earlier balance impact.
+	if !p.CanProcess(transaction) {
+		result.ErrorMessage = fmt.Sprintf("Transaction cannot be processed in status %s", transaction.Status())
+		result.ProcessingTime = time.Since(startTime)
+
+		p.logger.Warn("Transaction cannot be processed in current status",
+			logger.Int64("transactionId", transaction.ID()),
+			logger.String("status", transaction.Status().String()))
+
+		return result, nil
+	}
+	forced := transaction.Status() == models.TransactionStatusProc
+
+	// Step 1: Validate transaction for processing. A forced reprocess is validated as the
+	// unprocessed transaction it becomes once its impact is reversed.
+	candidate := transaction
+	if forced {
+		candidate = transaction.SetStatus(models.TransactionStatusNew, nil)
+	}
+	validationResult := p.validator.ValidateTransactionForProcessing(ctx, candidate)
 	if !validationResult.IsValid() {
 		result.ValidationErrors = validationResult.Errors
 		result.ErrorMessage = "Transaction validation failed"
-		result.Status = models.TransactionStatusError
 		result.ProcessingTime = time.Since(startTime)
 
 		p.logger.Warn("Transaction validation failed",
 			logger.Int64("transactionId", transaction.ID()),
 			logger.Int("errorCount", len(validationResult.Errors)))
 
+		if forced {
+			return result, nil
+		}
+		result.Status = models.TransactionStatusError
 		return result, p.updateTransactionStatus(ctx, transaction, models.TransactionStatusError, &result.ErrorMessage)
 	}
 
+	// Step 1a: A forced reprocess reverses the earlier impact and applies the transaction again
+	// as one unit of work
+	if forced {
+		return p.reprocess(ctx, transaction, result, startTime)
+	}
+
 	// Step 2: Calculate balance impacts
 	balanceResult, err := p.calculator.ApplyTransactionToBalances(ctx, transaction)
 	if err != nil {
@@ -106,7 +316,11 @@ func (p *TransactionProcessor) ProcessTransaction(ctx context.Context, transacti
 			logger.Int64("transactionId", transaction.ID()),
 			logger.Err(err))
 
-		return result, p.updateTransactionStatus(ctx, transaction, models.TransactionStatusError, &result.ErrorMessage)
+		if isFatalCalculationError(err) {
+			result.Status = models.TransactionStatusFatal
+			return result, p.updateTransactionStatus(ctx, transaction, models.TransactionStatusFatal, &result.ErrorMessage)
+		}
+		return result, p.recordProcessingError(ctx, transaction, result, err)
 	}
 
 	// Step 3: Validate balance constraints
@@ -122,45 +336,143 @@ func (p *TransactionProcessor) ProcessTransaction(ctx context.Context, transacti
 		return result, p.updateTransactionStatus(ctx, transaction, models.TransactionStatusFatal, &result.ErrorMessage)
 	}
 
-	// Step 4: Persist balance changes (within a transaction)
-	if err := p.persistBalanceChanges(ctx, transaction, balanceResult); err != nil {
-		result.ErrorMessage = fmt.Sprintf("Failed to persist balance changes: %v", err)
+	// Step 4: Persist balance changes. Within a balance batch they are held in memory and the
+	// transaction waits for Commit to write them together with its status.
+	if p.batch != nil {
+		if _, err := p.persistBalanceChanges(ctx, transaction, balanceResult); err != nil {
+			result.ErrorMessage = fmt.Sprintf("Failed to persist balance changes: %v", err)
+			result.Status = models.TransactionStatusError
+			result.ProcessingTime = time.Since(startTime)
+
+			p.logger.Error("Failed to persist balance changes",
+				logger.Int64("transactionId", transaction.ID()),
+				logger.Err(err))
+
+			return result, p.recordProcessingError(ctx, transaction, result, err)
+		}
+		p.batch.hold(transaction, result, balanceResult)
+	} else if completed, err := p.persistAndComplete(ctx, transaction, balanceResult, result); !completed {
 		result.Status = models.TransactionStatusError
 		result.ProcessingTime = time.Since(startTime)
+		return result, err
+	}
 
-		p.logger.Error("Failed to persist balance changes",
-			logger.Int64("transactionId", transaction.ID()),
-			logger.Err(err))
+	// Success!
+	result.Success = true
+	result.Status = models.TransactionStatusProc
+	result.BalanceChanges = balanceResult
+	result.ProcessingTime = time.Since(startTime)
 
-		return result, p.updateTransactionStatus(ctx, transaction, models.TransactionStatusError, &result.ErrorMessage)
-	}
+	p.logger.Info("Transaction processed successfully",
+		logger.Int64("transactionId", transaction.ID()),
+		logger.String("duration", result.ProcessingTime.String()))
 
-	// Step 5: Update transaction status to processed
-	if err := p.updateTransactionStatus(ctx, transaction, models.TransactionStatusProc, nil); err != nil {
-		result.ErrorMessage = fmt.Sprintf("Failed to update transaction status: %v", err)
-		result.Status = models.TransactionStatusError
+	return result, nil
+}
+
+// reprocess processes a PROC transaction again. The reversal of its earlier impact, the new
+// impact and its status are written in one unit of work, so a reprocess that fails at any step
+// leaves the transaction in PROC with the impact it had.
+func (p *TransactionProcessor) reprocess(ctx context.Context, transaction *models.Transaction, result *ProcessingResult, startTime time.Time) (*ProcessingResult, error) {
+	if p.unitOfWork == nil {
+		result.ErrorMessage = "Forced reprocessing requires a unit of work"
 		result.ProcessingTime = time.Since(startTime)
+		return result, nil
+	}
+
+	var balanceResult *BalanceCalculationResult
+	infrastructure := false
+	err := p.atomically(ctx, func(ctx context.Context) error {
+		reversal, err := p.calculator.ReverseTransactionFromBalances(ctx, transaction)
+		if err != nil {
+			result.ErrorMessage = fmt.Sprintf("Failed to calculate balance reversal: %v", err)
+			return err
+		}
+		if _, err := p.persistBalanceChanges(ctx, transaction, reversal); err != nil {
+			result.ErrorMessage = fmt.Sprintf("Failed to persist balance reversal: %v", err)
+			infrastructure = true
+			return err
+		}
+
+		if balanceResult, err = p.calculator.ApplyTransactionToBalances(ctx, transaction); err != nil {
+			result.ErrorMessage = fmt.Sprintf("Failed to calculate balance impacts: %v", err)
+			return err
+		}
+		if err := p.calculator.ValidateBalanceConstraints(ctx, transaction, balanceResult); err != nil {
+			result.ErrorMessage = fmt.Sprintf("Balance constraint violation: %v", err)
+			return err
+		}
+		if _, err := p.persistBalanceChanges(ctx, transaction, balanceResult); err != nil {
+			result.ErrorMessage = fmt.Sprintf("Failed to persist balance changes: %v", err)
+			infrastructure = true
+			return err
+		}
+		if err := p.completeTransaction(ctx, transaction, balanceResult); err != nil {
+			result.ErrorMessage = fmt.Sprintf("Failed to update transaction status: %v", err)
+			infrastructure = true
+			return err
+		}
+		return nil
+	})
+	result.ProcessingTime = time.Since(startTime)
+
+	if err != nil {
+		result.Status = models.TransactionStatusProc
 
-		p.logger.Error("Failed to update transaction status",
+		p.logger.Error("Forced reprocessing failed; the earlier impact is kept",
 			logger.Int64("transactionId", transaction.ID()),
+			logger.String("reason", result.ErrorMessage),
 			logger.Err(err))
 
-		return result, err
+		if infrastructure {
+			return result, err
+		}
+		return result, nil
 	}
 
-	// Success!
 	result.Success = true
 	result.Status = models.TransactionStatusProc
 	result.BalanceChanges = balanceResult
-	result.ProcessingTime = time.Since(startTime)
 
-	p.logger.Info("Transaction processed successfully",
+	p.logger.Info("Transaction reprocessed successfully",
 		logger.Int64("transactionId", transaction.ID()),
 		logger.String("duration", result.ProcessingTime.String()))
 
 	return result, nil
 }
 
+// persistAndComplete writes the balance changes of a transaction and moves it to PROC in one
+// unit of work (steps 4 and 5). When a write fails the unit is rolled back and the transaction is
+// set to ERROR, flagged for automatic reprocessing only if none of its writes were kept. It
+// reports whether the transaction was completed; the error is that of recording the failure.
+func (p *TransactionProcessor) persistAndComplete(ctx context.Context, transaction *models.Transaction, balanceResult *BalanceCalculationResult, result *ProcessingResult) (bool, error) {
+	written := 0
+	err := p.atomically(ctx, func(ctx context.Context) error {
+		var err error
+		if written, err = p.persistBalanceChanges(ctx, transaction, balanceResult); err != nil {
+			result.ErrorMessage = fmt.Sprintf("Failed to persist balance changes: %v", err)
+			return err
+		}
+		if err := p.completeTransaction(ctx, transaction, balanceResult); err != nil {
+			result.ErrorMessage = fmt.Sprintf("Failed to update transaction status: %v", err)
+			return err
+		}
+		return nil
+	})
+	if err == nil {
+		return true, nil
+	}
+
+	partial := p.unitOfWork == nil && written > 0
+	p.logger.Error("Failed to persist transaction processing",
+		logger.Int64("transactionId", transaction.ID()),
+		logger.String("reason", result.ErrorMessage),
+		logger.Bool("partiallyWritten", partial),
+		logger.Err(err))
+
+	return false, p.recordPersistError(ctx, transaction, result, err, partial)
+}
+
 // ProcessTransactionBatch processes multiple transactions
 func (p *TransactionProcessor) ProcessTransactionBatch(ctx context.Context, transactions []*models.Transaction) (*BatchProcessingResult, error) {
 	startTime := time.Now()
@@ -251,10 +563,462 @@ func (p *TransactionProcessor) ReprocessFailedTransactions(ctx context.Context,
 	return p.ProcessTransactionBatch(ctx, domainTransactions)
 }
 
-// persistBalanceChanges persists the calculated balance changes
-func (p *TransactionProcessor) persistBalanceChanges(ctx context.Context, transaction *models.Transaction, balanceResult *BalanceCalculationResult) error {
-	// This would ideally be done in a database transaction for atomicity
-	// For now, we'll persist changes individually
+// RecomputePortfolioBalances re-derives all balances of a portfolio by replaying its
+// processed transactions strictly ordered by effective date, type and creation time,
+// independent of the order in which they were originally processed, together with its
+// manual balance adjustments. Persisted balances that are not produced by the replay are
+// reset to zero.
+//
+// The portfolio's balances are locked before its transactions are loaded, and every write
+// happens in the same database transaction: processing that changes a balance meanwhile waits
+// for the recompute, and a recompute that fails leaves the balances unchanged.
+func (p *TransactionProcessor) RecomputePortfolioBalances(ctx context.Context, portfolioID string) (*RecomputeResult, error) {
+	startTime := time.Now()
+
+	domainPortfolioID, err := models.NewPortfolioID(portfolioID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid portfolio ID: %w", err)
+	}
+
+	p.logger.Info("Starting portfolio balance recompute",
+		logger.String("portfolioId", portfolioID))
+
+	var result *RecomputeResult
+	err = p.balanceRepo.RebuildPortfolioBalances(ctx, portfolioID, func(ctx context.Context, rebuild repositories.PortfolioBalanceRebuild) error {
+		var recomputeErr error
+		result, recomputeErr = p.recomputeLocked(ctx, domainPortfolioID, rebuild)
+		return recomputeErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.ProcessingTime = time.Since(startTime)
+
+	p.logger.Info("Portfolio balance recompute completed",
+		logger.String("portfolioId", portfolioID),
+		logger.Int("transactionsReplayed", result.TransactionsReplayed),
+		logger.Int("balancesCreated", result.BalancesCreated),
+		logger.Int("balancesUpdated", result.BalancesUpdated),
+		logger.Int("balancesZeroed", result.BalancesZeroed),
+		logger.String("duration", result.ProcessingTime.String()))
+
+	return result, nil
+}
+
+// recomputeLocked replays a portfolio's processed transactions and writes the resulting
+// balances through rebuild, which holds the portfolio's balances locked
+func (p *TransactionProcessor) recomputeLocked(ctx context.Context, portfolioID models.PortfolioID, rebuild repositories.PortfolioBalanceRebuild) (*RecomputeResult, error) {
+	transactions, err := p.listProcessedTransactions(ctx, rebuild.ListTransactions, portfolioID.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustments, err := rebuild.ListAdjustments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance adjustments: %w", err)
+	}
+
+	recomputed, err := p.calculator.ReplayTransactions(portfolioID, transactions, adjustments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay transactions: %w", err)
+	}
+
+	existingByKey := make(map[string]*repositories.Balance, len(rebuild.Balances()))
+	for _, balance := range rebuild.Balances() {
+		existingByKey[balanceKey(balance.SecurityID)] = balance
+	}
+
+	result := &RecomputeResult{
+		PortfolioID:          portfolioID.String(),
+		TransactionsReplayed: len(transactions),
+		Balances:             make([]*models.Balance, 0, len(recomputed)),
+	}
+
+	for _, balance := range recomputed {
+		repoBalance := p.convertToRepositoryBalance(balance)
+		repoBalance.LastUpdated = time.Now().UTC()
+
+		key := balanceKey(repoBalance.SecurityID)
+		if current, ok := existingByKey[key]; ok {
+			delete(existingByKey, key)
+			if err := rebuild.UpdateQuantities(ctx, current.ID, repoBalance.QuantityLong, repoBalance.QuantityShort, current.Version); err != nil {
+				return nil, fmt.Errorf("failed to update balance %d: %w", current.ID, err)
+			}
+			repoBalance.ID = current.ID
+			repoBalance.Version = current.Version + 1
+			repoBalance.CreatedAt = current.CreatedAt
+			result.BalancesUpdated++
+		} else {
+			if err := rebuild.Create(ctx, repoBalance); err != nil {
+				return nil, fmt.Errorf("failed to create balance: %w", err)
+			}
+			result.BalancesCreated++
+		}
+
+		domainBalance, err := p.calculator.convertToDomainBalance(repoBalance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert balance: %w", err)
+		}
+		result.Balances = append(result.Balances, domainBalance)
+	}
+
+	// Balances with no supporting processed transactions are reset to zero
+	for _, stale := range existingByKey {
+		if stale.QuantityLong.IsZero() && stale.QuantityShort.IsZero() {
+			continue
+		}
+		if err := rebuild.UpdateQuantities(ctx, stale.ID, decimal.Zero, decimal.Zero, stale.Version); err != nil {
+			return nil, fmt.Errorf("failed to reset balance %d: %w", stale.ID, err)
+		}
+		result.BalancesZeroed++
+	}
+
+	return result, nil
+}
+
+// CheckPortfolioConsistency replays a portfolio's processed transactions and adjustments the same way as
+// RecomputePortfolioBalances and reports stored balances that differ from the result. It never
+// modifies balances, so it is safe to run against production data.
+func (p *TransactionProcessor) CheckPortfolioConsistency(ctx context.Context, portfolioID string) (*ConsistencyReport, error) {
+	startTime := time.Now()
+
+	domainPortfolioID, err := models.NewPortfolioID(portfolioID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid portfolio ID: %w", err)
+	}
+
+	transactions, err := p.loadProcessedTransactions(ctx, portfolioID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustments, err := p.loadAdjustments(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
+	recomputed, err := p.calculator.ReplayTransactions(domainPortfolioID, transactions, adjustments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay transactions: %w", err)
+	}
+
+	stored, err := p.balanceRepo.GetBalancesByPortfolio(ctx, portfolioID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing balances: %w", err)
+	}
+
+	storedByKey := make(map[string]*repositories.Balance, len(stored))
+	for _, balance := range stored {
+		storedByKey[balanceKey(balance.SecurityID)] = balance
+	}
+
+	report := &ConsistencyReport{
+		PortfolioID:          portfolioID,
+		TransactionsReplayed: len(transactions),
+		BalancesChecked:      len(stored),
+		Discrepancies:        make([]BalanceDiscrepancy, 0),
+	}
+
+	for _, balance := range recomputed {
+		expected := p.convertToRepositoryBalance(balance)
+
+		key := balanceKey(expected.SecurityID)
+		current, ok := storedByKey[key]
+		if !ok {
+			if !expected.QuantityLong.IsZero() || !expected.QuantityShort.IsZero() {
+				report.Discrepancies = append(report.Discrepancies, BalanceDiscrepancy{
+					Kind:                  DiscrepancyMissing,
+					SecurityID:            expected.SecurityID,
+					ExpectedQuantityLong:  expected.QuantityLong,
+					ExpectedQuantityShort: expected.QuantityShort,
+					StoredQuantityLong:    decimal.Zero,
+					StoredQuantityShort:   decimal.Zero,
+				})
+			}
+			continue
+		}
+		delete(storedByKey, key)
+
+		if !current.QuantityLong.Equal(expected.QuantityLong) || !current.QuantityShort.Equal(expected.QuantityShort) {
+			report.Discrepancies = append(report.Discrepancies, BalanceDiscrepancy{
+				Kind:                  DiscrepancyMismatch,
+				BalanceID:             current.ID,
+				SecurityID:            current.SecurityID,
+				ExpectedQuantityLong:  expected.QuantityLong,
+				ExpectedQuantityShort: expected.QuantityShort,
+				StoredQuantityLong:    current.QuantityLong,
+				StoredQuantityShort:   current.QuantityShort,
+			})
+		}
+	}
+
+	// Stored balances with no supporting processed transactions should be zero
+	for _, stale := range stored {
+		if _, ok := storedByKey[balanceKey(stale.SecurityID)]; !ok {
+			continue
+		}
+		if stale.QuantityLong.IsZero() && stale.QuantityShort.IsZero() {
+			continue
+		}
+		report.Discrepancies = append(report.Discrepancies, BalanceDiscrepancy{
+			Kind:                  DiscrepancyUnexpected,
+			BalanceID:             stale.ID,
+			SecurityID:            stale.SecurityID,
+			ExpectedQuantityLong:  decimal.Zero,
+			ExpectedQuantityShort: decimal.Zero,
+			StoredQuantityLong:    stale.QuantityLong,
+			StoredQuantityShort:   stale.QuantityShort,
+		})
+	}
+
+	report.ProcessingTime = time.Since(startTime)
+
+	if !report.IsConsistent() {
+		p.logger.Warn("Portfolio balance drift detected",
+			logger.String("portfolioId", portfolioID),
+			logger.Int("discrepancies", len(report.Discrepancies)),
+			logger.Int("transactionsReplayed", report.TransactionsReplayed))
+	}
+
+	return report, nil
+}
+
+// GetPortfolioBalancesAsOf re-derives a portfolio's balances as they stood at the end of
+// asOf by replaying the processed transactions effective by then under the calculator's
+// date basis and the adjustments recorded by then. Stored balances are neither read nor modified.
+func (p *TransactionProcessor) GetPortfolioBalancesAsOf(ctx context.Context, portfolioID string, asOf time.Time) (*AsOfBalancesResult, error) {
+	domainPortfolioID, err := models.NewPortfolioID(portfolioID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid portfolio ID: %w", err)
+	}
+
+	// A transaction never settles before it trades, so anything effective by asOf under
+	// either basis has traded by then
+	cutoff := asOf.UTC().Truncate(24 * time.Hour)
+	transactions, err := p.loadProcessedTransactions(ctx, portfolioID, &cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustments, err := p.loadAdjustments(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances, replayed, err := p.calculator.ReplayTransactionsAsOf(domainPortfolioID, transactions, adjustments, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay transactions: %w", err)
+	}
+
+	return &AsOfBalancesResult{
+		PortfolioID:          portfolioID,
+		AsOf:                 cutoff,
+		DateBasis:            p.calculator.DateBasis(),
+		TransactionsReplayed: replayed,
+		Balances:             balances,
+	}, nil
+}
+
+// GetPortfolioLedger re-derives the window from through to of a portfolio's ledger: its balances
+// before from and every processed transaction effective and every adjustment recorded in the
+// window with the balances it left behind. Entries before the window are replayed for the opening
+// balances; stored balances are neither read nor modified.
+func (p *TransactionProcessor) GetPortfolioLedger(ctx context.Context, portfolioID string, from, to time.Time) (*LedgerResult, error) {
+	var opening []*models.Balance
+	entries := make([]LedgerEntry, 0)
+
+	result, err := p.StreamPortfolioLedger(ctx, portfolioID, from, to,
+		func(balances []*models.Balance) error {
+			opening = balances
+			return nil
+		},
+		func(entry LedgerEntry) error {
+			entries = append(entries, entry)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	result.OpeningBalances = opening
+	result.Entries = entries
+	return result, nil
+}
+
+// StreamPortfolioLedger replays the same window as GetPortfolioLedger, passing the opening
+// balances to opening and each entry to emit as it is replayed, so the window is never held in
+// memory. The returned result carries neither. It stops at the first error of the replay,
+// opening or emit.
+func (p *TransactionProcessor) StreamPortfolioLedger(ctx context.Context, portfolioID string, from, to time.Time, opening func([]*models.Balance) error, emit func(LedgerEntry) error) (*LedgerResult, error) {
+	domainPortfolioID, err := models.NewPortfolioID(portfolioID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid portfolio ID: %w", err)
+	}
+
+	from = from.UTC().Truncate(24 * time.Hour)
+	to = to.UTC().Truncate(24 * time.Hour)
+	if to.Before(from) {
+		return nil, fmt.Errorf("ledger window ends on %s before it starts on %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+
+	// A transaction never settles before it trades, so anything effective by to under either
+	// basis has traded by then
+	transactions, err := p.loadProcessedTransactions(ctx, portfolioID, &to)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustments, err := p.loadAdjustments(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.calculator.ReplayLedgerEntries(domainPortfolioID, transactions, adjustments, from, to, opening, emit); err != nil {
+		return nil, fmt.Errorf("failed to replay transactions: %w", err)
+	}
+
+	replayed := 0
+	for _, transaction := range transactions {
+		if !transaction.EffectiveDate(p.calculator.DateBasis()).After(to) {
+			replayed++
+		}
+	}
+
+	return &LedgerResult{
+		PortfolioID:          portfolioID,
+		From:                 from,
+		To:                   to,
+		DateBasis:            p.calculator.DateBasis(),
+		TransactionsReplayed: replayed,
+	}, nil
+}
+
+// GetTransactionBalanceImpact computes how a transaction affects its portfolio's balances.
+// ImpactStateProcessing computes it against the balances it was processed against, re-derived
+// by replaying the processed transactions and adjustments that precede it in replay order; ImpactStateCurrent
+// computes it against the stored balances. Stored balances are never modified.
+func (p *TransactionProcessor) GetTransactionBalanceImpact(ctx context.Context, transaction *models.Transaction, state ImpactState) (*BalanceImpactSummary, error) {
+	switch state {
+	case ImpactStateCurrent:
+		return p.calculator.CalculateBalanceImpact(ctx, transaction)
+	case ImpactStateProcessing:
+	default:
+		return nil, fmt.Errorf("invalid impact state: %s", state)
+	}
+
+	if !transaction.IsProcessed() {
+		return nil, ErrTransactionNotProcessed
+	}
+
+	// Transactions preceding this one are effective by its effective date, and a transaction
+	// never settles before it trades, so they have all traded by then
+	basis := p.calculator.DateBasis()
+	cutoff := transaction.EffectiveDate(basis)
+	transactions, err := p.loadProcessedTransactions(ctx, transaction.PortfolioID().String(), &cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	models.SortTransactionsByEffectiveDate(transactions, basis)
+	preceding := make([]*models.Transaction, 0, len(transactions))
+	for _, candidate := range transactions {
+		if candidate.ID() == transaction.ID() {
+			break
+		}
+		preceding = append(preceding, candidate)
+	}
+
+	// Adjustments recorded before the transaction's effective date precede it in replay order
+	adjustments, err := p.loadAdjustments(ctx, transaction.PortfolioID().String())
+	if err != nil {
+		return nil, err
+	}
+	precedingAdjustments := make([]*repositories.BalanceAdjustment, 0, len(adjustments))
+	for _, adjustment := range adjustments {
+		if adjustmentDate(adjustment).Before(cutoff) {
+			precedingAdjustments = append(precedingAdjustments, adjustment)
+		}
+	}
+
+	balances, err := p.calculator.ReplayTransactions(transaction.PortfolioID(), preceding, precedingAdjustments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay transactions: %w", err)
+	}
+
+	return p.calculator.CalculateBalanceImpactAgainst(transaction, balances), nil
+}
+
+// loadProcessedTransactions loads all PROC transactions of a portfolio in replay order,
+// limited to those traded on or before tradedThrough when it is set
+func (p *TransactionProcessor) loadProcessedTransactions(ctx context.Context, portfolioID string, tradedThrough *time.Time) ([]*models.Transaction, error) {
+	return p.listProcessedTransactions(ctx, p.transactionRepo.List, portfolioID, tradedThrough)
+}
+
+// loadAdjustments loads a portfolio's manual balance adjustments in the order they were recorded
+func (p *TransactionProcessor) loadAdjustments(ctx context.Context, portfolioID string) ([]*repositories.BalanceAdjustment, error) {
+	if p.adjustmentRepo == nil {
+		return nil, nil
+	}
+
+	adjustments, err := p.adjustmentRepo.ListByPortfolio(ctx, portfolioID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance adjustments: %w", err)
+	}
+	return adjustments, nil
+}
+
+// listProcessedTransactions is loadProcessedTransactions reading the pages with list
+func (p *TransactionProcessor) listProcessedTransactions(
+	ctx context.Context,
+	list func(ctx context.Context, filter repositories.TransactionFilter) ([]*repositories.Transaction, error),
+	portfolioID string,
+	tradedThrough *time.Time,
+) ([]*models.Transaction, error) {
+	transactions := make([]*models.Transaction, 0)
+
+	for offset := 0; ; offset += recomputePageSize {
+		page, err := list(ctx, repositories.TransactionFilter{
+			PortfolioID:       &portfolioID,
+			Statuses:          []string{models.TransactionStatusProc.String()},
+			TransactionDateTo: tradedThrough,
+			Limit:             recomputePageSize,
+			Offset:            offset,
+			SortBy:            []string{"transaction_date", "transaction_type", "created_at", "id"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get processed transactions: %w", err)
+		}
+
+		for _, repoTxn := range page {
+			domainTxn, err := p.convertToDomainTransaction(repoTxn)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert transaction %d: %w", repoTxn.ID, err)
+			}
+			transactions = append(transactions, domainTxn)
+		}
+
+		if len(page) < recomputePageSize {
+			break
+		}
+	}
+
+	return transactions, nil
+}
+
+// balanceKey returns a map key identifying a balance within a portfolio
+func balanceKey(securityID *string) string {
+	if securityID == nil {
+		return ""
+	}
+	return *securityID
+}
+
+// persistBalanceChanges persists the calculated balance changes, security balance first. It
+// returns the number of balances written, which is less than the number changed when a write
+// fails; callers that need the writes to be atomic run it in the unit of work.
+func (p *TransactionProcessor) persistBalanceChanges(ctx context.Context, transaction *models.Transaction, balanceResult *BalanceCalculationResult) (int, error) {
+	written := 0
 
 	// Persist security balance changes
 	if balanceResult.SecurityBalance != nil {
@@ -264,20 +1028,23 @@ func (p *TransactionProcessor) persistBalanceChanges(ctx context.Context, transa
 		existing, err := p.balanceRepo.GetByPortfolioAndSecurity(ctx,
 			repoBalance.PortfolioID, repoBalance.SecurityID)
 		if err != nil && !repositories.IsNotFoundError(err) {
-			return fmt.Errorf("failed to check existing security balance: %w", err)
+			return written, fmt.Errorf("failed to check existing security balance: %w", err)
 		}
 
 		if existing != nil {
 			repoBalance.ID = existing.ID
 			repoBalance.Version = existing.Version
 			if err := p.balanceRepo.Update(ctx, repoBalance); err != nil {
-				return fmt.Errorf("failed to update security balance: %w", err)
+				return written, fmt.Errorf("failed to update security balance: %w", err)
 			}
 		} else {
 			if err := p.balanceRepo.Create(ctx, repoBalance); err != nil {
-				return fmt.Errorf("failed to create security balance: %w", err)
+				return written, fmt.Errorf("failed to create security balance: %w", err)
 			}
 		}
+
+		written++
+		p.logBalanceChange(transaction.ID(), existing, repoBalance)
 	}
 
 	// Persist cash balance changes
@@ -287,22 +1054,78 @@ func (p *TransactionProcessor) persistBalanceChanges(ctx context.Context, transa
 		// Check if cash balance exists
 		existing, err := p.balanceRepo.GetCashBalance(ctx, repoBalance.PortfolioID)
 		if err != nil && !repositories.IsNotFoundError(err) {
-			return fmt.Errorf("failed to check existing cash balance: %w", err)
+			return written, fmt.Errorf("failed to check existing cash balance: %w", err)
 		}
 
 		if existing != nil {
 			repoBalance.ID = existing.ID
 			repoBalance.Version = existing.Version
 			if err := p.balanceRepo.Update(ctx, repoBalance); err != nil {
-				return fmt.Errorf("failed to update cash balance: %w", err)
+				return written, fmt.Errorf("failed to update cash balance: %w", err)
 			}
 		} else {
 			if err := p.balanceRepo.Create(ctx, repoBalance); err != nil {
-				return fmt.Errorf("failed to create cash balance: %w", err)
+				return written, fmt.Errorf("failed to create cash balance: %w", err)
 			}
 		}
+
+		written++
+		p.logBalanceChange(transaction.ID(), existing, repoBalance)
+	}
+
+	return written, nil
+}
+
+// logBalanceChange records a balance change with before and after quantities at the configured level
+func (p *TransactionProcessor) logBalanceChange(transactionID int64, before, after *repositories.Balance) {
+	if p.balanceChangeLogLevel != BalanceChangeLogDebug && p.balanceChangeLogLevel != BalanceChangeLogInfo {
+		return
+	}
+
+	longBefore, shortBefore := decimal.Zero, decimal.Zero
+	if before != nil {
+		longBefore, shortBefore = before.QuantityLong, before.QuantityShort
+	}
+
+	securityID := ""
+	if after.SecurityID != nil {
+		securityID = *after.SecurityID
 	}
 
+	fields := []zap.Field{
+		logger.Int64("transactionId", transactionID),
+		logger.String("portfolioId", after.PortfolioID),
+		logger.String("securityId", securityID),
+		logger.Bool("cash", after.SecurityID == nil),
+		logger.String("longBefore", longBefore.String()),
+		logger.String("longAfter", after.QuantityLong.String()),
+		logger.String("shortBefore", shortBefore.String()),
+		logger.String("shortAfter", after.QuantityShort.String()),
+		logger.Bool("created", before == nil),
+	}
+
+	if p.balanceChangeLogLevel == BalanceChangeLogInfo {
+		p.logger.Info("Balance change applied", fields...)
+	} else {
+		p.logger.Debug("Balance change applied", fields...)
+	}
+}
+
+// completeTransaction moves a transaction whose balance changes are persisted to PROC and
+// stores its notional amount. The transaction is processed whether or not the amount is stored;
+// a missing amount is filled in by the notional amount backfill.
+func (p *TransactionProcessor) completeTransaction(ctx context.Context, transaction *models.Transaction, balanceResult *BalanceCalculationResult) error {
+	if err := p.updateTransactionStatus(ctx, transaction, models.TransactionStatusProc, nil); err != nil {
+		return err
+	}
+
+	if p.storeNotionalAmounts {
+		if err := p.transactionRepo.SetNotionalAmount(ctx, transaction.ID(), balanceResult.NotionalAmount); err != nil {
+			p.logger.Warn("Failed to store transaction notional amount",
+				logger.Int64("transactionId", transaction.ID()),
+				logger.Err(err))
+		}
+	}
 	return nil
 }
 
@@ -311,6 +1134,42 @@ func (p *TransactionProcessor) updateTransactionStatus(ctx context.Context, tran
 	return p.transactionRepo.UpdateStatus(ctx, transaction.ID(), status.String(), errorMessage, transaction.Version())
 }
 
+// recordProcessingError sets the transaction to ERROR, flagging it for automatic
+// reprocessing when the underlying cause is transient
+func (p *TransactionProcessor) recordProcessingError(ctx context.Context, transaction *models.Transaction, result *ProcessingResult, cause error) error {
+	if IsBalanceCalculationError(cause) || !repositories.IsTransientError(cause) {
+		return p.updateTransactionStatus(ctx, transaction, models.TransactionStatusError, &result.ErrorMessage)
+	}
+
+	result.Retryable = true
+	return p.transactionRepo.MarkRetryableError(ctx, transaction.ID(), &result.ErrorMessage, transaction.Version())
+}
+
+// recordPersistError sets a transaction whose writes failed to ERROR. When some of its writes
+// were kept it is not flagged for automatic reprocessing, which would apply them twice.
+func (p *TransactionProcessor) recordPersistError(ctx context.Context, transaction *models.Transaction, result *ProcessingResult, cause error, partial bool) error {
+	if partial {
+		return p.updateTransactionStatus(ctx, transaction, models.TransactionStatusError, &result.ErrorMessage)
+	}
+	return p.recordProcessingError(ctx, transaction, result, cause)
+}
+
+// atomically runs fn in the processor's unit of work, or directly when it has none
+func (p *TransactionProcessor) atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.unitOfWork == nil {
+		return fn(ctx)
+	}
+	return p.unitOfWork.Atomically(ctx, fn)
+}
+
+// isFatalCalculationError reports whether a balance calculation failed in a way that processing
+// the transaction again cannot resolve
+func isFatalCalculationError(err error) bool {
+	var insufficient *InsufficientPositionError
+	var overflow *CalculationOverflowError
+	return errors.As(err, &insufficient) || errors.As(err, &overflow)
+}
+
 // updateBatchSummary updates the batch processing summary
 func (p *TransactionProcessor) updateBatchSummary(summary *ProcessingSummary, transaction *models.Transaction, result *ProcessingResult) {
 	// Update status counts
@@ -342,6 +1201,7 @@ func (p *TransactionProcessor) convertToDomainTransaction(repoTxn *repositories.
 		WithQuantity(repoTxn.Quantity).
 		WithPrice(repoTxn.Price).
 		WithTransactionDate(repoTxn.TransactionDate).
+		WithSettlementDate(repoTxn.SettlementDate).
 		WithReprocessingAttempts(repoTxn.ReprocessingAttempts).
 		WithVersion(repoTxn.Version).
 		WithTimestamps(repoTxn.CreatedAt, repoTxn.UpdatedAt)
@@ -0,0 +1,1040 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// memoryBalanceRepo keeps balances in memory for the lookups and writes used by processing
+type memoryBalanceRepo struct {
+	repositories.BalanceRepository
+
+	balances []*repositories.Balance
+}
+
+func (r *memoryBalanceRepo) find(portfolioID string, securityID *string) *repositories.Balance {
+	for _, balance := range r.balances {
+		if balance.PortfolioID == portfolioID && balanceKey(balance.SecurityID) == balanceKey(securityID) {
+			clone := *balance
+			return &clone
+		}
+	}
+	return nil
+}
+
+func (r *memoryBalanceRepo) GetByPortfolioAndSecurity(ctx context.Context, portfolioID string, securityID *string) (*repositories.Balance, error) {
+	if balance := r.find(portfolioID, securityID); balance != nil {
+		return balance, nil
+	}
+	return nil, repositories.NewNotFoundError("balance", portfolioID)
+}
+
+func (r *memoryBalanceRepo) GetCashBalance(ctx context.Context, portfolioID string) (*repositories.Balance, error) {
+	return r.GetByPortfolioAndSecurity(ctx, portfolioID, nil)
+}
+
+func (r *memoryBalanceRepo) Create(ctx context.Context, balance *repositories.Balance) error {
+	balance.ID = int64(len(r.balances) + 1)
+	clone := *balance
+	r.balances = append(r.balances, &clone)
+	return nil
+}
+
+func (r *memoryBalanceRepo) Update(ctx context.Context, balance *repositories.Balance) error {
+	for i, existing := range r.balances {
+		if existing.ID == balance.ID {
+			clone := *balance
+			r.balances[i] = &clone
+			return nil
+		}
+	}
+	return repositories.NewNotFoundError("balance", balance.ID)
+}
+
+func TestTransactionProcessor_BalanceChangeLogging(t *testing.T) {
+	ctx := context.Background()
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	newFixture := func(level BalanceChangeLogLevel) (*TransactionProcessor, *observer.ObservedLogs) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		lg := logger.NewFromZap(zap.New(core))
+
+		balanceRepo := &memoryBalanceRepo{
+			balances: []*repositories.Balance{{
+				ID:            1,
+				PortfolioID:   testPortfolioID,
+				QuantityLong:  decimal.NewFromInt(1000),
+				QuantityShort: decimal.Zero,
+				Version:       1,
+			}},
+		}
+		calculator := NewBalanceCalculator(balanceRepo, lg)
+		processor := NewTransactionProcessor(nil, balanceRepo, nil, calculator, lg).
+			WithBalanceChangeLogLevel(level)
+		return processor, logs
+	}
+
+	applyBuy := func(t *testing.T, processor *TransactionProcessor) {
+		t.Helper()
+		transaction := buildReplayTransaction(t, 42, "BUY", 10, 50, date)
+		balanceResult, err := processor.calculator.ApplyTransactionToBalances(ctx, transaction)
+		require.NoError(t, err)
+		_, err = processor.persistBalanceChanges(ctx, transaction, balanceResult)
+		require.NoError(t, err)
+	}
+
+	t.Run("Logs before and after quantities for each balance", func(t *testing.T) {
+		processor, logs := newFixture(BalanceChangeLogInfo)
+		applyBuy(t, processor)
+
+		entries := logs.FilterMessage("Balance change applied").All()
+		require.Len(t, entries, 2)
+
+		security := entries[0]
+		assert.Equal(t, zapcore.InfoLevel, security.Level)
+		assert.Equal(t, map[string]interface{}{
+			"transactionId": int64(42),
+			"portfolioId":   testPortfolioID,
+			"securityId":    testSecurityID,
+			"cash":          false,
+			"longBefore":    "0",
+			"longAfter":     "10",
+			"shortBefore":   "0",
+			"shortAfter":    "0",
+			"created":       true,
+		}, security.ContextMap())
+
+		cash := entries[1].ContextMap()
+		assert.Equal(t, int64(42), cash["transactionId"])
+		assert.Equal(t, "", cash["securityId"])
+		assert.Equal(t, true, cash["cash"])
+		assert.Equal(t, "1000", cash["longBefore"])
+		assert.Equal(t, "500", cash["longAfter"])
+		assert.Equal(t, false, cash["created"])
+	})
+
+	t.Run("Debug level", func(t *testing.T) {
+		processor, logs := newFixture(BalanceChangeLogDebug)
+		applyBuy(t, processor)
+
+		entries := logs.FilterMessage("Balance change applied").All()
+		require.Len(t, entries, 2)
+		assert.Equal(t, zapcore.DebugLevel, entries[0].Level)
+	})
+
+	t.Run("Off logs nothing", func(t *testing.T) {
+		processor, logs := newFixture(BalanceChangeLogOff)
+		applyBuy(t, processor)
+
+		assert.Zero(t, logs.FilterMessage("Balance change applied").Len())
+	})
+}
+
+func (r *memoryBalanceRepo) GetBalancesByPortfolio(ctx context.Context, portfolioID string) ([]*repositories.Balance, error) {
+	var result []*repositories.Balance
+	for _, balance := range r.balances {
+		if balance.PortfolioID == portfolioID {
+			clone := *balance
+			result = append(result, &clone)
+		}
+	}
+	return result, nil
+}
+
+// processedTransactionRepo lists a fixed set of processed transactions
+type processedTransactionRepo struct {
+	repositories.TransactionRepository
+
+	transactions []*repositories.Transaction
+}
+
+func (r *processedTransactionRepo) List(ctx context.Context, filter repositories.TransactionFilter) ([]*repositories.Transaction, error) {
+	if filter.Offset >= len(r.transactions) {
+		return nil, nil
+	}
+	return r.transactions[filter.Offset:], nil
+}
+
+// listedAdjustmentRepo lists a fixed set of balance adjustments
+type listedAdjustmentRepo struct {
+	repositories.BalanceAdjustmentRepository
+
+	adjustments []*repositories.BalanceAdjustment
+}
+
+func (r *listedAdjustmentRepo) ListByPortfolio(ctx context.Context, portfolioID string) ([]*repositories.BalanceAdjustment, error) {
+	return r.adjustments, nil
+}
+
+func TestTransactionProcessor_CheckPortfolioConsistency(t *testing.T) {
+	ctx := context.Background()
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	// DEP 10000 then BUY 100 @ 50: cash 5000, security long 100
+	securityID := testSecurityID
+	transactions := []*repositories.Transaction{
+		{ID: 1, PortfolioID: testPortfolioID, SourceID: "SOURCE001", Status: "PROC", TransactionType: "DEP",
+			Quantity: decimal.NewFromInt(10000), Price: decimal.NewFromInt(1), TransactionDate: day(1), Version: 1, CreatedAt: day(1), UpdatedAt: day(1)},
+		{ID: 2, PortfolioID: testPortfolioID, SecurityID: &securityID, SourceID: "SOURCE002", Status: "PROC", TransactionType: "BUY",
+			Quantity: decimal.NewFromInt(100), Price: decimal.NewFromInt(50), TransactionDate: day(2), Version: 1, CreatedAt: day(2), UpdatedAt: day(2)},
+	}
+
+	newFixture := func(securityLong int64, extra ...*repositories.Balance) (*TransactionProcessor, *memoryBalanceRepo) {
+		balanceRepo := &memoryBalanceRepo{
+			balances: append([]*repositories.Balance{
+				{ID: 1, PortfolioID: testPortfolioID, QuantityLong: decimal.NewFromInt(5000), QuantityShort: decimal.Zero, Version: 2},
+				{ID: 2, PortfolioID: testPortfolioID, SecurityID: &securityID, QuantityLong: decimal.NewFromInt(securityLong), QuantityShort: decimal.Zero, Version: 1},
+			}, extra...),
+		}
+		lg := logger.NewNoop()
+		processor := NewTransactionProcessor(&processedTransactionRepo{transactions: transactions}, balanceRepo, nil,
+			NewBalanceCalculator(balanceRepo, lg), lg)
+		return processor, balanceRepo
+	}
+
+	t.Run("Consistent balances", func(t *testing.T) {
+		processor, _ := newFixture(100)
+
+		report, err := processor.CheckPortfolioConsistency(ctx, testPortfolioID)
+		require.NoError(t, err)
+		assert.True(t, report.IsConsistent())
+		assert.Equal(t, 2, report.TransactionsReplayed)
+		assert.Equal(t, 2, report.BalancesChecked)
+	})
+
+	t.Run("Detects drift without modifying balances", func(t *testing.T) {
+		otherSecurity := "OTHERSEC1234567890123456"
+		processor, balanceRepo := newFixture(90, &repositories.Balance{
+			ID: 3, PortfolioID: testPortfolioID, SecurityID: &otherSecurity,
+			QuantityLong: decimal.NewFromInt(7), QuantityShort: decimal.Zero, Version: 1,
+		})
+
+		report, err := processor.CheckPortfolioConsistency(ctx, testPortfolioID)
+		require.NoError(t, err)
+		require.Len(t, report.Discrepancies, 2)
+
+		mismatch := report.Discrepancies[0]
+		assert.Equal(t, DiscrepancyMismatch, mismatch.Kind)
+		assert.Equal(t, int64(2), mismatch.BalanceID)
+		assert.True(t, decimal.NewFromInt(100).Equal(mismatch.ExpectedQuantityLong))
+		assert.True(t, decimal.NewFromInt(90).Equal(mismatch.StoredQuantityLong))
+
+		unexpected := report.Discrepancies[1]
+		assert.Equal(t, DiscrepancyUnexpected, unexpected.Kind)
+		assert.Equal(t, int64(3), unexpected.BalanceID)
+		assert.True(t, unexpected.ExpectedQuantityLong.IsZero())
+
+		stored := balanceRepo.find(testPortfolioID, &securityID)
+		assert.True(t, decimal.NewFromInt(90).Equal(stored.QuantityLong))
+		assert.Equal(t, 1, stored.Version)
+	})
+
+	t.Run("Reports balances missing from storage", func(t *testing.T) {
+		processor, balanceRepo := newFixture(100)
+		balanceRepo.balances = balanceRepo.balances[:1]
+
+		report, err := processor.CheckPortfolioConsistency(ctx, testPortfolioID)
+		require.NoError(t, err)
+		require.Len(t, report.Discrepancies, 1)
+		assert.Equal(t, DiscrepancyMissing, report.Discrepancies[0].Kind)
+		assert.Equal(t, testSecurityID, *report.Discrepancies[0].SecurityID)
+	})
+
+	t.Run("Balances holding a manual adjustment are consistent", func(t *testing.T) {
+		processor, _ := newFixture(90)
+		processor.WithBalanceAdjustments(&listedAdjustmentRepo{adjustments: []*repositories.BalanceAdjustment{
+			{ID: 1, PortfolioID: testPortfolioID, SecurityID: &securityID, QuantityLongDelta: decimal.NewFromInt(-10), QuantityShortDelta: decimal.Zero, CreatedAt: day(3)},
+		}})
+
+		report, err := processor.CheckPortfolioConsistency(ctx, testPortfolioID)
+		require.NoError(t, err)
+		assert.True(t, report.IsConsistent(), "the adjustment is replayed with the transactions")
+	})
+}
+
+// stagedRebuildRepo runs rebuilds against a copy of its balances that replaces them only when
+// the rebuild succeeds, the way a database transaction commits or rolls back
+type stagedRebuildRepo struct {
+	*memoryBalanceRepo
+
+	transactions []*repositories.Transaction
+	adjustments  []*repositories.BalanceAdjustment
+	// failUpdate makes updating the balance with this ID fail
+	failUpdate int64
+	rebuilds   int
+}
+
+func (r *stagedRebuildRepo) RebuildPortfolioBalances(ctx context.Context, portfolioID string, rebuild func(ctx context.Context, rebuild repositories.PortfolioBalanceRebuild) error) error {
+	r.rebuilds++
+	staged := &stagedRebuild{repo: r}
+	for _, balance := range r.balances {
+		clone := *balance
+		staged.balances = append(staged.balances, &clone)
+	}
+	if err := rebuild(ctx, staged); err != nil {
+		return err
+	}
+	r.balances = staged.balances
+	return nil
+}
+
+// stagedRebuild holds the balance writes of one rebuild until it ends
+type stagedRebuild struct {
+	repo     *stagedRebuildRepo
+	balances []*repositories.Balance
+}
+
+func (b *stagedRebuild) Balances() []*repositories.Balance {
+	return b.balances
+}
+
+func (b *stagedRebuild) ListTransactions(ctx context.Context, filter repositories.TransactionFilter) ([]*repositories.Transaction, error) {
+	if filter.Offset >= len(b.repo.transactions) {
+		return nil, nil
+	}
+	return b.repo.transactions[filter.Offset:], nil
+}
+
+func (b *stagedRebuild) ListAdjustments(ctx context.Context) ([]*repositories.BalanceAdjustment, error) {
+	return b.repo.adjustments, nil
+}
+
+func (b *stagedRebuild) Create(ctx context.Context, balance *repositories.Balance) error {
+	balance.ID = int64(len(b.balances) + 1)
+	clone := *balance
+	b.balances = append(b.balances, &clone)
+	return nil
+}
+
+func (b *stagedRebuild) UpdateQuantities(ctx context.Context, id int64, quantityLong, quantityShort decimal.Decimal, version int) error {
+	if id == b.repo.failUpdate {
+		return errors.New("write failed")
+	}
+	for _, balance := range b.balances {
+		if balance.ID == id {
+			balance.QuantityLong = quantityLong
+			balance.QuantityShort = quantityShort
+			balance.Version = version + 1
+			return nil
+		}
+	}
+	return repositories.NewNotFoundError("balance", id)
+}
+
+// failingListTransactionRepo fails every list, so transactions can only be read in a rebuild
+type failingListTransactionRepo struct {
+	repositories.TransactionRepository
+}
+
+func (r *failingListTransactionRepo) List(ctx context.Context, filter repositories.TransactionFilter) ([]*repositories.Transaction, error) {
+	return nil, errors.New("listed outside the rebuild")
+}
+
+func TestTransactionProcessor_RecomputePortfolioBalances(t *testing.T) {
+	ctx := context.Background()
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	// DEP 10000 then BUY 100 @ 50: cash 5000, security long 100
+	securityID := testSecurityID
+	otherSecurity := "OTHERSEC1234567890123456"
+	transactions := []*repositories.Transaction{
+		{ID: 1, PortfolioID: testPortfolioID, SourceID: "SOURCE001", Status: "PROC", TransactionType: "DEP",
+			Quantity: decimal.NewFromInt(10000), Price: decimal.NewFromInt(1), TransactionDate: day(1), Version: 1, CreatedAt: day(1), UpdatedAt: day(1)},
+		{ID: 2, PortfolioID: testPortfolioID, SecurityID: &securityID, SourceID: "SOURCE002", Status: "PROC", TransactionType: "BUY",
+			Quantity: decimal.NewFromInt(100), Price: decimal.NewFromInt(50), TransactionDate: day(2), Version: 1, CreatedAt: day(2), UpdatedAt: day(2)},
+	}
+
+	// The cash balance drifted, the security balance is missing and another security has no
+	// supporting transactions
+	newFixture := func() (*TransactionProcessor, *stagedRebuildRepo) {
+		balanceRepo := &stagedRebuildRepo{
+			memoryBalanceRepo: &memoryBalanceRepo{balances: []*repositories.Balance{
+				{ID: 1, PortfolioID: testPortfolioID, QuantityLong: decimal.NewFromInt(4000), QuantityShort: decimal.Zero, Version: 2},
+				{ID: 2, PortfolioID: testPortfolioID, SecurityID: &otherSecurity, QuantityLong: decimal.NewFromInt(7), QuantityShort: decimal.Zero, Version: 1},
+			}},
+			transactions: transactions,
+		}
+		lg := logger.NewNoop()
+		processor := NewTransactionProcessor(&failingListTransactionRepo{}, balanceRepo, nil,
+			NewBalanceCalculator(balanceRepo, lg), lg)
+		return processor, balanceRepo
+	}
+
+	t.Run("Balances are rebuilt in one rebuild", func(t *testing.T) {
+		processor, balanceRepo := newFixture()
+
+		result, err := processor.RecomputePortfolioBalances(ctx, testPortfolioID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, balanceRepo.rebuilds)
+		assert.Equal(t, 2, result.TransactionsReplayed)
+		assert.Equal(t, 1, result.BalancesUpdated)
+		assert.Equal(t, 1, result.BalancesCreated)
+		assert.Equal(t, 1, result.BalancesZeroed)
+
+		assert.True(t, decimal.NewFromInt(5000).Equal(balanceRepo.find(testPortfolioID, nil).QuantityLong))
+		assert.True(t, decimal.NewFromInt(100).Equal(balanceRepo.find(testPortfolioID, &securityID).QuantityLong))
+		assert.True(t, balanceRepo.find(testPortfolioID, &otherSecurity).QuantityLong.IsZero())
+	})
+
+	t.Run("Manual adjustments survive the rebuild", func(t *testing.T) {
+		processor, balanceRepo := newFixture()
+		balanceRepo.adjustments = []*repositories.BalanceAdjustment{
+			{ID: 1, PortfolioID: testPortfolioID, QuantityLongDelta: decimal.NewFromInt(-250), QuantityShortDelta: decimal.Zero, CreatedAt: day(5)},
+			{ID: 2, PortfolioID: testPortfolioID, SecurityID: &otherSecurity, QuantityLongDelta: decimal.NewFromInt(7), QuantityShortDelta: decimal.Zero, CreatedAt: day(5)},
+		}
+
+		result, err := processor.RecomputePortfolioBalances(ctx, testPortfolioID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.BalancesZeroed)
+
+		assert.True(t, decimal.NewFromInt(4750).Equal(balanceRepo.find(testPortfolioID, nil).QuantityLong))
+		assert.True(t, decimal.NewFromInt(7).Equal(balanceRepo.find(testPortfolioID, &otherSecurity).QuantityLong))
+	})
+
+	t.Run("A failed write leaves every balance unchanged", func(t *testing.T) {
+		processor, balanceRepo := newFixture()
+		balanceRepo.failUpdate = 2
+
+		_, err := processor.RecomputePortfolioBalances(ctx, testPortfolioID)
+		require.Error(t, err)
+
+		require.Len(t, balanceRepo.balances, 2)
+		cash := balanceRepo.find(testPortfolioID, nil)
+		assert.True(t, decimal.NewFromInt(4000).Equal(cash.QuantityLong))
+		assert.Equal(t, 2, cash.Version)
+		assert.Nil(t, balanceRepo.find(testPortfolioID, &securityID))
+		assert.True(t, decimal.NewFromInt(7).Equal(balanceRepo.find(testPortfolioID, &otherSecurity).QuantityLong))
+	})
+}
+
+func TestTransactionProcessor_GetTransactionBalanceImpact(t *testing.T) {
+	ctx := context.Background()
+	day := func(d int) time.Time {
+		return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	// DEP 10000, BUY 100 @ 50, DEP 2000, SELL 40 @ 60: cash 9400, security long 60
+	securityID := testSecurityID
+	transactions := []*repositories.Transaction{
+		{ID: 1, PortfolioID: testPortfolioID, SourceID: "SOURCE001", Status: "PROC", TransactionType: "DEP",
+			Quantity: decimal.NewFromInt(10000), Price: decimal.NewFromInt(1), TransactionDate: day(1), Version: 1, CreatedAt: day(1), UpdatedAt: day(1)},
+		{ID: 2, PortfolioID: testPortfolioID, SecurityID: &securityID, SourceID: "SOURCE002", Status: "PROC", TransactionType: "BUY",
+			Quantity: decimal.NewFromInt(100), Price: decimal.NewFromInt(50), TransactionDate: day(2), Version: 1, CreatedAt: day(2), UpdatedAt: day(2)},
+		{ID: 3, PortfolioID: testPortfolioID, SourceID: "SOURCE003", Status: "PROC", TransactionType: "DEP",
+			Quantity: decimal.NewFromInt(2000), Price: decimal.NewFromInt(1), TransactionDate: day(3), Version: 1, CreatedAt: day(3), UpdatedAt: day(3)},
+		{ID: 4, PortfolioID: testPortfolioID, SecurityID: &securityID, SourceID: "SOURCE004", Status: "PROC", TransactionType: "SELL",
+			Quantity: decimal.NewFromInt(40), Price: decimal.NewFromInt(60), TransactionDate: day(4), Version: 1, CreatedAt: day(4), UpdatedAt: day(4)},
+	}
+
+	balanceRepo := &memoryBalanceRepo{
+		balances: []*repositories.Balance{
+			{ID: 1, PortfolioID: testPortfolioID, QuantityLong: decimal.NewFromInt(9400), QuantityShort: decimal.Zero, Version: 4},
+			{ID: 2, PortfolioID: testPortfolioID, SecurityID: &securityID, QuantityLong: decimal.NewFromInt(60), QuantityShort: decimal.Zero, Version: 2},
+		},
+	}
+	lg := logger.NewNoop()
+	processor := NewTransactionProcessor(&processedTransactionRepo{transactions: transactions}, balanceRepo, nil,
+		NewBalanceCalculator(balanceRepo, lg), lg)
+
+	load := func(t *testing.T, index int) *models.Transaction {
+		transaction, err := processor.convertToDomainTransaction(transactions[index])
+		require.NoError(t, err)
+		return transaction
+	}
+	assertDecimal := func(t *testing.T, expected int64, actual decimal.Decimal) {
+		t.Helper()
+		assert.True(t, decimal.NewFromInt(expected).Equal(actual), "expected %d, got %s", expected, actual)
+	}
+
+	t.Run("Security transaction at processing time", func(t *testing.T) {
+		summary, err := processor.GetTransactionBalanceImpact(ctx, load(t, 1), ImpactStateProcessing)
+		require.NoError(t, err)
+
+		require.NotNil(t, summary.SecurityImpact)
+		assertDecimal(t, 100, summary.SecurityImpact.LongChange)
+		assertDecimal(t, 100, summary.SecurityImpact.ResultingLong)
+		require.NotNil(t, summary.CashImpact)
+		assertDecimal(t, -5000, summary.CashImpact.LongChange)
+		assertDecimal(t, 5000, summary.CashImpact.ResultingLong)
+	})
+
+	t.Run("Cash transaction at processing time", func(t *testing.T) {
+		summary, err := processor.GetTransactionBalanceImpact(ctx, load(t, 2), ImpactStateProcessing)
+		require.NoError(t, err)
+
+		assert.Nil(t, summary.SecurityImpact)
+		assert.Nil(t, summary.SecurityID)
+		require.NotNil(t, summary.CashImpact)
+		assertDecimal(t, 2000, summary.CashImpact.LongChange)
+		assertDecimal(t, 7000, summary.CashImpact.ResultingLong)
+	})
+
+	t.Run("Security transaction against current balances", func(t *testing.T) {
+		summary, err := processor.GetTransactionBalanceImpact(ctx, load(t, 1), ImpactStateCurrent)
+		require.NoError(t, err)
+
+		assertDecimal(t, 160, summary.SecurityImpact.ResultingLong)
+		assertDecimal(t, 4400, summary.CashImpact.ResultingLong)
+	})
+
+	t.Run("Cash transaction against current balances", func(t *testing.T) {
+		summary, err := processor.GetTransactionBalanceImpact(ctx, load(t, 2), ImpactStateCurrent)
+		require.NoError(t, err)
+
+		assert.Nil(t, summary.SecurityImpact)
+		assertDecimal(t, 11400, summary.CashImpact.ResultingLong)
+	})
+
+	t.Run("Adjustments recorded before the transaction count at processing time", func(t *testing.T) {
+		adjusted := NewTransactionProcessor(&processedTransactionRepo{transactions: transactions}, balanceRepo, nil,
+			NewBalanceCalculator(balanceRepo, lg), lg).
+			WithBalanceAdjustments(&listedAdjustmentRepo{adjustments: []*repositories.BalanceAdjustment{
+				{ID: 1, PortfolioID: testPortfolioID, QuantityLongDelta: decimal.NewFromInt(500), QuantityShortDelta: decimal.Zero, CreatedAt: day(2).Add(time.Hour)},
+				{ID: 2, PortfolioID: testPortfolioID, QuantityLongDelta: decimal.NewFromInt(300), QuantityShortDelta: decimal.Zero, CreatedAt: day(3).Add(time.Hour)},
+			}})
+
+		summary, err := adjusted.GetTransactionBalanceImpact(ctx, load(t, 2), ImpactStateProcessing)
+		require.NoError(t, err)
+		// Only the adjustment of the day before is included
+		assertDecimal(t, 7500, summary.CashImpact.ResultingLong)
+	})
+
+	t.Run("Unprocessed transaction has no processing state", func(t *testing.T) {
+		pending := *transactions[3]
+		pending.ID = 5
+		pending.Status = "NEW"
+		transaction, err := processor.convertToDomainTransaction(&pending)
+		require.NoError(t, err)
+
+		_, err = processor.GetTransactionBalanceImpact(ctx, transaction, ImpactStateProcessing)
+		assert.ErrorIs(t, err, ErrTransactionNotProcessed)
+
+		summary, err := processor.GetTransactionBalanceImpact(ctx, transaction, ImpactStateCurrent)
+		require.NoError(t, err)
+		assertDecimal(t, 20, summary.SecurityImpact.ResultingLong)
+	})
+}
+
+// statusRecordingTransactionRepo records the status updates made by processing
+type statusRecordingTransactionRepo struct {
+	repositories.TransactionRepository
+
+	statuses []string
+	notional map[int64]decimal.Decimal
+}
+
+func (r *statusRecordingTransactionRepo) UpdateStatus(ctx context.Context, id int64, status string, errorMessage *string, version int) error {
+	r.statuses = append(r.statuses, status)
+	return nil
+}
+
+func (r *statusRecordingTransactionRepo) SetNotionalAmount(ctx context.Context, id int64, amount decimal.Decimal) error {
+	if r.notional == nil {
+		r.notional = make(map[int64]decimal.Decimal)
+	}
+	r.notional[id] = amount
+	return nil
+}
+
+func (r *statusRecordingTransactionRepo) MarkRetryableError(ctx context.Context, id int64, errorMessage *string, version int) error {
+	r.statuses = append(r.statuses, models.TransactionStatusError.String())
+	return nil
+}
+
+func TestTransactionProcessor_StatusTransitions(t *testing.T) {
+	ctx := context.Background()
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	securityID := testSecurityID
+
+	// The balances already hold the impact of a processed BUY of 10 at 50 from 1000 cash
+	newFixture := func(allowForced bool) (*TransactionProcessor, *statusRecordingTransactionRepo, *memoryBalanceRepo) {
+		lg := logger.NewNoop()
+		transactionRepo := &statusRecordingTransactionRepo{}
+		balanceRepo := &memoryBalanceRepo{
+			balances: []*repositories.Balance{
+				{ID: 1, PortfolioID: testPortfolioID, QuantityLong: decimal.NewFromInt(500), QuantityShort: decimal.Zero, Version: 2},
+				{ID: 2, PortfolioID: testPortfolioID, SecurityID: &securityID, QuantityLong: decimal.NewFromInt(10), QuantityShort: decimal.Zero, Version: 1},
+			},
+		}
+		processor := NewTransactionProcessor(transactionRepo, balanceRepo,
+			NewTransactionValidator(nil, nil, lg), NewBalanceCalculator(balanceRepo, lg), lg).
+			WithForcedReprocessing(allowForced).
+			WithUnitOfWork(&rollbackUnitOfWork{balances: balanceRepo, transactions: transactionRepo})
+		return processor, transactionRepo, balanceRepo
+	}
+
+	buy := func(t *testing.T, status models.TransactionStatus) *models.Transaction {
+		t.Helper()
+		return buildReplayTransaction(t, 7, "BUY", 10, 50, date).SetStatus(status, nil)
+	}
+
+	quantities := func(balanceRepo *memoryBalanceRepo) (cash, security string) {
+		return balanceRepo.find(testPortfolioID, nil).QuantityLong.String(),
+			balanceRepo.find(testPortfolioID, &securityID).QuantityLong.String()
+	}
+
+	for _, status := range []models.TransactionStatus{models.TransactionStatusNew, models.TransactionStatusError} {
+		t.Run(status.String()+" to PROC applies the impact", func(t *testing.T) {
+			processor, transactionRepo, balanceRepo := newFixture(false)
+
+			result, err := processor.ProcessTransaction(ctx, buy(t, status))
+			require.NoError(t, err)
+			assert.True(t, result.Success)
+			assert.Equal(t, []string{"PROC"}, transactionRepo.statuses)
+
+			cash, security := quantities(balanceRepo)
+			assert.Equal(t, "0", cash)
+			assert.Equal(t, "20", security)
+		})
+	}
+
+	for _, status := range []models.TransactionStatus{models.TransactionStatusProc, models.TransactionStatusFatal, models.TransactionStatusDead} {
+		t.Run(status.String()+" is not processed by default", func(t *testing.T) {
+			processor, transactionRepo, balanceRepo := newFixture(false)
+
+			result, err := processor.ProcessTransaction(ctx, buy(t, status))
+			require.NoError(t, err)
+			assert.False(t, result.Success)
+			assert.Equal(t, status, result.Status)
+			assert.Empty(t, transactionRepo.statuses, "status is left unchanged")
+
+			cash, security := quantities(balanceRepo)
+			assert.Equal(t, "500", cash)
+			assert.Equal(t, "10", security)
+		})
+	}
+
+	t.Run("Forced PROC to PROC reverses the earlier impact first", func(t *testing.T) {
+		processor, transactionRepo, balanceRepo := newFixture(true)
+
+		result, err := processor.ProcessTransaction(ctx, buy(t, models.TransactionStatusProc))
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Equal(t, []string{"PROC"}, transactionRepo.statuses)
+
+		cash, security := quantities(balanceRepo)
+		assert.Equal(t, "500", cash, "the impact is counted once")
+		assert.Equal(t, "10", security)
+	})
+
+	t.Run("Forced reprocess whose reversal fails to persist stays PROC with its impact", func(t *testing.T) {
+		_, transactionRepo, memoryRepo := newFixture(true)
+		balanceRepo := &countingBalanceRepo{memoryBalanceRepo: memoryRepo, failFromWrite: 2}
+		lg := logger.NewNoop()
+		processor := NewTransactionProcessor(transactionRepo, balanceRepo,
+			NewTransactionValidator(nil, nil, lg), NewBalanceCalculator(balanceRepo, lg), lg).
+			WithForcedReprocessing(true).
+			WithUnitOfWork(&rollbackUnitOfWork{balances: memoryRepo, transactions: transactionRepo})
+
+		result, err := processor.ProcessTransaction(ctx, buy(t, models.TransactionStatusProc))
+		require.Error(t, err)
+		assert.False(t, result.Success)
+		assert.False(t, result.Retryable)
+		assert.Equal(t, models.TransactionStatusProc, result.Status)
+		assert.Contains(t, result.ErrorMessage, "balance reversal")
+		assert.Equal(t, 2, balanceRepo.writes, "the security reversal was written before the cash reversal failed")
+		assert.Empty(t, transactionRepo.statuses, "the transaction is not set to ERROR")
+
+		cash, security := quantities(memoryRepo)
+		assert.Equal(t, "500", cash, "the earlier impact is kept whole")
+		assert.Equal(t, "10", security)
+	})
+
+	t.Run("Forced reprocess that fails after the reversal keeps the earlier impact", func(t *testing.T) {
+		_, transactionRepo, memoryRepo := newFixture(true)
+		balanceRepo := &countingBalanceRepo{memoryBalanceRepo: memoryRepo, failFromWrite: 3}
+		lg := logger.NewNoop()
+		processor := NewTransactionProcessor(transactionRepo, balanceRepo,
+			NewTransactionValidator(nil, nil, lg), NewBalanceCalculator(balanceRepo, lg), lg).
+			WithForcedReprocessing(true).
+			WithUnitOfWork(&rollbackUnitOfWork{balances: memoryRepo, transactions: transactionRepo})
+
+		result, err := processor.ProcessTransaction(ctx, buy(t, models.TransactionStatusProc))
+		require.Error(t, err)
+		assert.Equal(t, models.TransactionStatusProc, result.Status)
+		assert.Contains(t, result.ErrorMessage, "persist balance changes")
+		assert.Empty(t, transactionRepo.statuses)
+
+		cash, security := quantities(memoryRepo)
+		assert.Equal(t, "500", cash)
+		assert.Equal(t, "10", security)
+	})
+
+	t.Run("Forced reprocessing without a unit of work is refused", func(t *testing.T) {
+		processor, transactionRepo, balanceRepo := newFixture(true)
+		processor.WithUnitOfWork(nil)
+
+		result, err := processor.ProcessTransaction(ctx, buy(t, models.TransactionStatusProc))
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Equal(t, models.TransactionStatusProc, result.Status)
+		assert.Empty(t, transactionRepo.statuses)
+
+		cash, security := quantities(balanceRepo)
+		assert.Equal(t, "500", cash)
+		assert.Equal(t, "10", security)
+	})
+
+	t.Run("Forced reprocessing never applies to FATAL or DEAD", func(t *testing.T) {
+		processor, transactionRepo, _ := newFixture(true)
+
+		for _, status := range []models.TransactionStatus{models.TransactionStatusFatal, models.TransactionStatusDead} {
+			result, err := processor.ProcessTransaction(ctx, buy(t, status))
+			require.NoError(t, err)
+			assert.False(t, result.Success)
+		}
+		assert.Empty(t, transactionRepo.statuses)
+	})
+
+	t.Run("Forced reprocess without balances to reverse stays PROC", func(t *testing.T) {
+		processor, transactionRepo, balanceRepo := newFixture(true)
+		balanceRepo.balances = nil
+
+		result, err := processor.ProcessTransaction(ctx, buy(t, models.TransactionStatusProc))
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Equal(t, models.TransactionStatusProc, result.Status)
+		assert.Contains(t, result.ErrorMessage, "balance reversal")
+		assert.Empty(t, transactionRepo.statuses)
+		assert.Empty(t, balanceRepo.balances)
+	})
+
+	t.Run("Notional amounts are not stored by default", func(t *testing.T) {
+		processor, transactionRepo, _ := newFixture(false)
+
+		result, err := processor.ProcessTransaction(ctx, buy(t, models.TransactionStatusNew))
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Empty(t, transactionRepo.notional)
+	})
+
+	t.Run("Processing stores the calculated notional amount", func(t *testing.T) {
+		processor, transactionRepo, _ := newFixture(false)
+		processor.WithNotionalAmounts(true)
+
+		result, err := processor.ProcessTransaction(ctx, buy(t, models.TransactionStatusNew))
+		require.NoError(t, err)
+		assert.True(t, result.Success)
+		assert.Equal(t, "500", result.BalanceChanges.NotionalAmount.String())
+		require.Contains(t, transactionRepo.notional, int64(7))
+		assert.Equal(t, "500", transactionRepo.notional[7].String())
+	})
+
+	t.Run("Failed processing stores no notional amount", func(t *testing.T) {
+		processor, transactionRepo, balanceRepo := newFixture(true)
+		processor.WithNotionalAmounts(true)
+		balanceRepo.balances = nil
+
+		result, err := processor.ProcessTransaction(ctx, buy(t, models.TransactionStatusProc))
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.Empty(t, transactionRepo.notional)
+	})
+
+	t.Run("Overflowing balance is FATAL and not retryable", func(t *testing.T) {
+		processor, transactionRepo, balanceRepo := newFixture(false)
+		balanceRepo.balances[1].QuantityLong = decimal.RequireFromString("9999999995")
+
+		result, err := processor.ProcessTransaction(ctx, buy(t, models.TransactionStatusNew))
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.False(t, result.Retryable)
+		assert.Equal(t, models.TransactionStatusFatal, result.Status)
+		assert.Equal(t, []string{"FATAL"}, transactionRepo.statuses)
+
+		_, security := quantities(balanceRepo)
+		assert.Equal(t, "9999999995", security, "balances are left unchanged")
+	})
+}
+
+func TestTransactionProcessor_PersistFailures(t *testing.T) {
+	ctx := context.Background()
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	securityID := testSecurityID
+
+	// The security balance is written, then the cash balance write fails
+	newFixture := func(withUnitOfWork bool) (*TransactionProcessor, *statusRecordingTransactionRepo, *countingBalanceRepo) {
+		lg := logger.NewNoop()
+		transactionRepo := &statusRecordingTransactionRepo{}
+		balanceRepo := &countingBalanceRepo{
+			memoryBalanceRepo: &memoryBalanceRepo{balances: []*repositories.Balance{
+				{ID: 1, PortfolioID: testPortfolioID, QuantityLong: decimal.NewFromInt(1000), QuantityShort: decimal.Zero, Version: 2},
+				{ID: 2, PortfolioID: testPortfolioID, SecurityID: &securityID, QuantityLong: decimal.NewFromInt(10), QuantityShort: decimal.Zero, Version: 1},
+			}},
+			failFromWrite: 2,
+			writeErr:      repositories.NewConnectionError("update", errors.New("connection reset")),
+		}
+		processor := NewTransactionProcessor(transactionRepo, balanceRepo,
+			NewTransactionValidator(nil, nil, lg), NewBalanceCalculator(balanceRepo, lg), lg)
+		if withUnitOfWork {
+			processor.WithUnitOfWork(&rollbackUnitOfWork{balances: balanceRepo.memoryBalanceRepo, transactions: transactionRepo})
+		}
+		return processor, transactionRepo, balanceRepo
+	}
+
+	buy := func(t *testing.T) *models.Transaction {
+		return buildReplayTransaction(t, 7, "BUY", 10, 50, date).SetStatus(models.TransactionStatusNew, nil)
+	}
+
+	t.Run("Partial persist is rolled back and retryable", func(t *testing.T) {
+		processor, transactionRepo, balanceRepo := newFixture(true)
+
+		result, err := processor.ProcessTransaction(ctx, buy(t))
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.True(t, result.Retryable)
+		assert.Equal(t, models.TransactionStatusError, result.Status)
+		assert.Equal(t, []string{"ERROR"}, transactionRepo.statuses)
+
+		assert.Equal(t, "1000", balanceRepo.find(testPortfolioID, nil).QuantityLong.String())
+		assert.Equal(t, "10", balanceRepo.find(testPortfolioID, &securityID).QuantityLong.String(), "the security write was rolled back")
+	})
+
+	t.Run("Partial persist without a unit of work is not retryable", func(t *testing.T) {
+		processor, transactionRepo, balanceRepo := newFixture(false)
+
+		result, err := processor.ProcessTransaction(ctx, buy(t))
+		require.NoError(t, err)
+		assert.False(t, result.Success)
+		assert.False(t, result.Retryable, "a retry would apply the kept security write again")
+		assert.Equal(t, []string{"ERROR"}, transactionRepo.statuses)
+		assert.Equal(t, "20", balanceRepo.find(testPortfolioID, &securityID).QuantityLong.String())
+	})
+
+	t.Run("Persist that wrote nothing stays retryable without a unit of work", func(t *testing.T) {
+		processor, transactionRepo, balanceRepo := newFixture(false)
+		balanceRepo.failFromWrite = 1
+
+		result, err := processor.ProcessTransaction(ctx, buy(t))
+		require.NoError(t, err)
+		assert.True(t, result.Retryable)
+		assert.Equal(t, []string{"ERROR"}, transactionRepo.statuses)
+	})
+}
+
+// countingBalanceRepo counts the balance reads and writes made against a memoryBalanceRepo
+type countingBalanceRepo struct {
+	*memoryBalanceRepo
+
+	reads, writes int
+	failWrites    bool
+	// failFromWrite fails every write from that write on; zero never fails
+	failFromWrite int
+	// writeErr is the error of a failed write; nil fails with a plain error
+	writeErr error
+}
+
+func (r *countingBalanceRepo) writeError() error {
+	r.writes++
+	if !r.failWrites && (r.failFromWrite == 0 || r.writes < r.failFromWrite) {
+		return nil
+	}
+	if r.writeErr != nil {
+		return r.writeErr
+	}
+	return errors.New("connection reset")
+}
+
+func (r *countingBalanceRepo) GetByPortfolioAndSecurity(ctx context.Context, portfolioID string, securityID *string) (*repositories.Balance, error) {
+	r.reads++
+	return r.memoryBalanceRepo.GetByPortfolioAndSecurity(ctx, portfolioID, securityID)
+}
+
+func (r *countingBalanceRepo) GetCashBalance(ctx context.Context, portfolioID string) (*repositories.Balance, error) {
+	r.reads++
+	return r.memoryBalanceRepo.GetCashBalance(ctx, portfolioID)
+}
+
+func (r *countingBalanceRepo) Create(ctx context.Context, balance *repositories.Balance) error {
+	if err := r.writeError(); err != nil {
+		return err
+	}
+	return r.memoryBalanceRepo.Create(ctx, balance)
+}
+
+func (r *countingBalanceRepo) Update(ctx context.Context, balance *repositories.Balance) error {
+	if err := r.writeError(); err != nil {
+		return err
+	}
+	return r.memoryBalanceRepo.Update(ctx, balance)
+}
+
+// rollbackUnitOfWork undoes the balance and status writes of a failed unit like a database rollback
+type rollbackUnitOfWork struct {
+	balances     *memoryBalanceRepo
+	transactions *statusRecordingTransactionRepo
+}
+
+func (u *rollbackUnitOfWork) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	balances := append([]*repositories.Balance(nil), u.balances.balances...)
+	statuses := len(u.transactions.statuses)
+
+	if err := fn(ctx); err != nil {
+		u.balances.balances = balances
+		u.transactions.statuses = u.transactions.statuses[:statuses]
+		return err
+	}
+	return nil
+}
+
+func TestTransactionProcessor_BalanceBatch(t *testing.T) {
+	ctx := context.Background()
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	securityID := testSecurityID
+	const buys = 50
+
+	newFixture := func() (*TransactionProcessor, *statusRecordingTransactionRepo, *countingBalanceRepo) {
+		lg := logger.NewNoop()
+		transactionRepo := &statusRecordingTransactionRepo{}
+		balanceRepo := &countingBalanceRepo{memoryBalanceRepo: &memoryBalanceRepo{
+			balances: []*repositories.Balance{
+				{ID: 1, PortfolioID: testPortfolioID, QuantityLong: decimal.NewFromInt(1000), QuantityShort: decimal.Zero, Version: 2},
+			},
+		}}
+		processor := NewTransactionProcessor(transactionRepo, balanceRepo,
+			NewTransactionValidator(nil, nil, lg), NewBalanceCalculator(balanceRepo, lg), lg)
+		return processor, transactionRepo, balanceRepo
+	}
+
+	batchOfBuys := func(t *testing.T) []*models.Transaction {
+		transactions := make([]*models.Transaction, buys)
+		for i := range transactions {
+			transactions[i] = buildReplayTransaction(t, int64(i+1), "BUY", 2, 5, date).SetStatus(models.TransactionStatusNew, nil)
+		}
+		return transactions
+	}
+
+	quantities := func(balanceRepo *countingBalanceRepo) (cash, security string) {
+		cashBalance := balanceRepo.find(testPortfolioID, nil)
+		securityBalance := balanceRepo.find(testPortfolioID, &securityID)
+		if securityBalance == nil {
+			return cashBalance.QuantityLong.String(), "none"
+		}
+		return cashBalance.QuantityLong.String(), securityBalance.QuantityLong.String()
+	}
+
+	t.Run("Repetitive batch reads and writes each balance once", func(t *testing.T) {
+		processor, _, single := newFixture()
+		for _, transaction := range batchOfBuys(t) {
+			result, err := processor.ProcessTransaction(ctx, transaction)
+			require.NoError(t, err)
+			require.True(t, result.Success)
+		}
+
+		processor, transactionRepo, batched := newFixture()
+		batch := processor.NewBalanceBatch()
+		for _, transaction := range batchOfBuys(t) {
+			result, err := batch.ProcessTransaction(ctx, transaction)
+			require.NoError(t, err)
+			require.True(t, result.Success)
+		}
+		assert.Empty(t, transactionRepo.statuses, "transactions wait for the balances to be written")
+		assert.Equal(t, 0, batched.writes)
+
+		require.NoError(t, batch.Commit(ctx))
+		assert.Len(t, transactionRepo.statuses, buys)
+
+		cash, security := quantities(batched)
+		assert.Equal(t, "500", cash)
+		assert.Equal(t, "100", security)
+		singleCash, singleSecurity := quantities(single)
+		assert.Equal(t, singleCash, cash, "the batch ends with the balances of processing one at a time")
+		assert.Equal(t, singleSecurity, security)
+
+		assert.Equal(t, 2, batched.reads, "one read per balance")
+		assert.Equal(t, 2, batched.writes, "one write per balance")
+		assert.Equal(t, 4*buys, single.reads)
+		assert.Equal(t, 2*buys, single.writes)
+	})
+
+	t.Run("Failed balance writes leave the transactions in ERROR", func(t *testing.T) {
+		processor, transactionRepo, balanceRepo := newFixture()
+		balanceRepo.failWrites = true
+		batch := processor.NewBalanceBatch()
+
+		var results []*ProcessingResult
+		for _, transaction := range batchOfBuys(t)[:3] {
+			result, err := batch.ProcessTransaction(ctx, transaction)
+			require.NoError(t, err)
+			results = append(results, result)
+		}
+
+		err := batch.Commit(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "connection reset")
+		assert.Equal(t, []string{"ERROR", "ERROR", "ERROR"}, transactionRepo.statuses)
+		for _, result := range results {
+			assert.False(t, result.Success)
+			assert.Equal(t, models.TransactionStatusError, result.Status)
+			assert.Nil(t, result.BalanceChanges)
+		}
+
+		cash, security := quantities(balanceRepo)
+		assert.Equal(t, "1000", cash)
+		assert.Equal(t, "none", security)
+	})
+
+	t.Run("Partially written batch is rolled back before its transactions are set to ERROR", func(t *testing.T) {
+		processor, transactionRepo, balanceRepo := newFixture()
+		processor.WithUnitOfWork(&rollbackUnitOfWork{balances: balanceRepo.memoryBalanceRepo, transactions: transactionRepo})
+		balanceRepo.failFromWrite = 2
+		balanceRepo.writeErr = repositories.NewConnectionError("update", errors.New("connection reset"))
+		batch := processor.NewBalanceBatch()
+
+		var results []*ProcessingResult
+		for _, transaction := range batchOfBuys(t)[:3] {
+			result, err := batch.ProcessTransaction(ctx, transaction)
+			require.NoError(t, err)
+			results = append(results, result)
+		}
+
+		require.Error(t, batch.Commit(ctx))
+		assert.Equal(t, 2, balanceRepo.writes, "the first balance was written before the second failed")
+		assert.Equal(t, []string{"ERROR", "ERROR", "ERROR"}, transactionRepo.statuses)
+		for _, result := range results {
+			assert.True(t, result.Retryable, "nothing was kept, so the transactions can be processed again")
+		}
+
+		cash, security := quantities(balanceRepo)
+		assert.Equal(t, "1000", cash, "no balance keeps an impact a retry would apply again")
+		assert.Equal(t, "none", security)
+	})
+
+	t.Run("Partial write without a unit of work is not retryable", func(t *testing.T) {
+		processor, transactionRepo, balanceRepo := newFixture()
+		balanceRepo.failFromWrite = 2
+		balanceRepo.writeErr = repositories.NewConnectionError("update", errors.New("connection reset"))
+		batch := processor.NewBalanceBatch()
+
+		var results []*ProcessingResult
+		for _, transaction := range batchOfBuys(t)[:3] {
+			result, err := batch.ProcessTransaction(ctx, transaction)
+			require.NoError(t, err)
+			results = append(results, result)
+		}
+
+		require.Error(t, batch.Commit(ctx))
+		assert.Equal(t, []string{"ERROR", "ERROR", "ERROR"}, transactionRepo.statuses)
+		for _, result := range results {
+			assert.False(t, result.Success)
+			assert.False(t, result.Retryable)
+		}
+	})
+}
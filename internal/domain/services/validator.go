@@ -3,6 +3,9 @@ package services
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
@@ -26,6 +29,12 @@ func (v ValidationError) Error() string {
 type ValidationResult struct {
 	Valid  bool              `json:"valid"`
 	Errors []ValidationError `json:"errors,omitempty"`
+	// UnknownReferences lists the portfolio and security the reference services do not know or
+	// could not look up, when the unknown reference policy accepts the transaction anyway
+	UnknownReferences []ValidationError `json:"unknownReferences,omitempty"`
+	// Quarantine is set when the transaction is valid but must be stored in QUAR instead of
+	// being processed, because of its unknown references
+	Quarantine bool `json:"quarantine,omitempty"`
 }
 
 // IsValid returns true if the validation passed
@@ -46,11 +55,67 @@ func (r ValidationResult) FirstError() *ValidationError {
 	return nil
 }
 
+// UnknownReferencePolicy decides what happens to a transaction whose portfolio or security the
+// reference services do not know
+type UnknownReferencePolicy string
+
+const (
+	UnknownReferenceOff        UnknownReferencePolicy = "off"        // References are not looked up
+	UnknownReferenceReject     UnknownReferencePolicy = "reject"     // The transaction fails validation
+	UnknownReferenceWarn       UnknownReferencePolicy = "warn"       // A warning is logged and the transaction is processed
+	UnknownReferenceQuarantine UnknownReferencePolicy = "quarantine" // The transaction is stored in QUAR and not processed
+)
+
+// IsValid checks if the unknown reference policy is valid
+func (p UnknownReferencePolicy) IsValid() bool {
+	switch p {
+	case UnknownReferenceOff, UnknownReferenceReject, UnknownReferenceWarn, UnknownReferenceQuarantine:
+		return true
+	}
+	return false
+}
+
+// ReferenceChecker reports whether the portfolios and securities transactions refer to exist.
+// An error means existence could not be determined.
+type ReferenceChecker interface {
+	PortfolioExists(ctx context.Context, portfolioID string) (bool, error)
+	SecurityExists(ctx context.Context, securityID string) (bool, error)
+}
+
+// DailyLimitExceededError reports a transaction beyond the number of transactions its portfolio
+// may book for one transaction date. It is a limit on the rate of booking rather than a fault
+// of the transaction, which can be submitted again for another date or once the limit is raised.
+type DailyLimitExceededError struct {
+	PortfolioID     string
+	TransactionDate time.Time
+	Limit           int
+}
+
+// Error implements the error interface
+func (e *DailyLimitExceededError) Error() string {
+	return fmt.Sprintf("portfolio %s has reached its limit of %d transactions for %s",
+		e.PortfolioID, e.Limit, e.TransactionDate.Format("20060102"))
+}
+
 // TransactionValidator provides validation services for transactions
 type TransactionValidator struct {
 	transactionRepo repositories.TransactionRepository
 	balanceRepo     repositories.BalanceRepository
 	logger          logger.Logger
+
+	// maxFutureDays limits how far ahead of today a transaction date may be; negative means unlimited
+	maxFutureDays int
+	// sourceIDPattern is the format source IDs must match; nil accepts any source ID
+	sourceIDPattern *regexp.Regexp
+	// sourceIDPatternText is sourceIDPattern as configured, which error messages show
+	sourceIDPatternText string
+	// referenceChecker looks up portfolios and securities under referencePolicy; nil skips the lookups
+	referenceChecker ReferenceChecker
+	referencePolicy  UnknownReferencePolicy
+	// dailyLimit is how many transactions a portfolio may book per transaction date; 0 is unlimited
+	dailyLimit int
+	// dailyLimitOverrides replaces dailyLimit for the portfolios it holds, keyed by upper-case ID
+	dailyLimitOverrides map[string]int
 }
 
 // NewTransactionValidator creates a new transaction validator
@@ -63,11 +128,126 @@ func NewTransactionValidator(
 		transactionRepo: transactionRepo,
 		balanceRepo:     balanceRepo,
 		logger:          logger,
+		maxFutureDays:   -1,
+		referencePolicy: UnknownReferenceOff,
 	}
 }
 
+// WithMaxFutureDays rejects transaction dates more than days after today (UTC).
+// A negative value allows any future date.
+func (v *TransactionValidator) WithMaxFutureDays(days int) *TransactionValidator {
+	v.maxFutureDays = days
+	return v
+}
+
+// WithSourceIDPattern rejects source IDs that pattern does not match, naming the pattern as
+// configured in the error; pattern may be anchored or otherwise rewritten from it. A nil pattern
+// accepts any source ID.
+func (v *TransactionValidator) WithSourceIDPattern(pattern *regexp.Regexp, configured string) *TransactionValidator {
+	v.sourceIDPattern = pattern
+	v.sourceIDPatternText = configured
+	return v
+}
+
+// WithUnknownReferencePolicy looks up the portfolio and security of every transaction validated
+// for creation with checker and applies policy to those it does not know. References are not
+// looked up by default, or when checker is nil.
+func (v *TransactionValidator) WithUnknownReferencePolicy(checker ReferenceChecker, policy UnknownReferencePolicy) *TransactionValidator {
+	v.referenceChecker = checker
+	v.referencePolicy = policy
+	return v
+}
+
+// WithDailyTransactionLimit caps how many transactions a portfolio may book for one transaction
+// date. overrides sets the limit of individual portfolios, matched case-insensitively; a limit
+// of 0, the default, leaves the portfolio unlimited.
+func (v *TransactionValidator) WithDailyTransactionLimit(limit int, overrides map[string]int) *TransactionValidator {
+	v.dailyLimit = limit
+	v.dailyLimitOverrides = make(map[string]int, len(overrides))
+	for portfolioID, portfolioLimit := range overrides {
+		v.dailyLimitOverrides[strings.ToUpper(strings.TrimSpace(portfolioID))] = portfolioLimit
+	}
+	return v
+}
+
+// DailyTransactionLimit returns how many transactions the portfolio may book per transaction
+// date; 0 means unlimited
+func (v *TransactionValidator) DailyTransactionLimit(portfolioID string) int {
+	if limit, ok := v.dailyLimitOverrides[strings.ToUpper(portfolioID)]; ok {
+		return limit
+	}
+	return v.dailyLimit
+}
+
+// CheckDailyLimit returns a *DailyLimitExceededError when the transaction's portfolio already
+// has as many transactions for its transaction date as its daily limit allows. pending counts
+// transactions of the same portfolio and date about to be stored alongside it, such as earlier
+// records of the same batch. A count that fails is logged and the transaction allowed, like a
+// failed source ID lookup.
+func (v *TransactionValidator) CheckDailyLimit(ctx context.Context, transaction *models.Transaction, pending int) error {
+	portfolioID := transaction.PortfolioID().String()
+	limit := v.DailyTransactionLimit(portfolioID)
+	if limit <= 0 {
+		return nil
+	}
+
+	transactionDate := transaction.TransactionDate()
+	booked, err := v.transactionRepo.Count(ctx, repositories.TransactionFilter{
+		PortfolioID:     &portfolioID,
+		TransactionDate: &transactionDate,
+	})
+	if err != nil {
+		v.logger.Error("Failed to count transactions for daily limit",
+			logger.String("portfolioId", portfolioID),
+			logger.Err(err))
+		return nil
+	}
+
+	if booked+int64(pending) >= int64(limit) {
+		v.logger.Warn("Portfolio daily transaction limit reached",
+			logger.String("portfolioId", portfolioID),
+			logger.String("transactionDate", transactionDate.Format("20060102")),
+			logger.Int("limit", limit))
+		return &DailyLimitExceededError{PortfolioID: portfolioID, TransactionDate: transactionDate, Limit: limit}
+	}
+	return nil
+}
+
+// UnknownReferencePolicy returns the policy applied to unknown portfolios and securities
+func (v *TransactionValidator) UnknownReferencePolicy() UnknownReferencePolicy {
+	return v.referencePolicy
+}
+
 // ValidateTransaction performs comprehensive validation of a transaction
 func (v *TransactionValidator) ValidateTransaction(ctx context.Context, transaction *models.Transaction) ValidationResult {
+	result := v.ValidateTransactionRules(ctx, transaction)
+
+	// Source ID uniqueness validation
+	if errs := v.validateSourceIDUniqueness(ctx, transaction); len(errs) > 0 {
+		result.Errors = append(result.Errors, errs...)
+	}
+
+	// Reference validation, only for transactions that are otherwise valid
+	if len(result.Errors) == 0 {
+		v.applyReferencePolicy(ctx, transaction, &result)
+	}
+
+	// Set overall validity
+	result.Valid = len(result.Errors) == 0
+
+	if !result.Valid {
+		v.logger.Warn("Transaction validation failed",
+			logger.String("transactionType", transaction.TransactionType().String()),
+			logger.String("portfolioId", transaction.PortfolioID().String()),
+			logger.Int("errorCount", len(result.Errors)))
+	}
+
+	return result
+}
+
+// ValidateTransactionRules validates a transaction's fields and business rules without
+// repository lookups, so it is cheap enough for interactive validation
+func (v *TransactionValidator) ValidateTransactionRules(ctx context.Context, transaction *models.Transaction) ValidationResult {
 	result := ValidationResult{Valid: true, Errors: []ValidationError{}}
 
 	// Basic field validation
@@ -80,6 +260,11 @@ func (v *TransactionValidator) ValidateTransaction(ctx context.Context, transact
 		result.Errors = append(result.Errors, errs...)
 	}
 
+	// Transaction date validation
+	if errs := v.validateTransactionDate(transaction, time.Now()); len(errs) > 0 {
+		result.Errors = append(result.Errors, errs...)
+	}
+
 	// Portfolio validation
 	if errs := v.validatePortfolio(ctx, transaction); len(errs) > 0 {
 		result.Errors = append(result.Errors, errs...)
@@ -92,21 +277,7 @@ func (v *TransactionValidator) ValidateTransaction(ctx context.Context, transact
 		}
 	}
 
-	// Source ID uniqueness validation
-	if errs := v.validateSourceIDUniqueness(ctx, transaction); len(errs) > 0 {
-		result.Errors = append(result.Errors, errs...)
-	}
-
-	// Set overall validity
 	result.Valid = len(result.Errors) == 0
-
-	if !result.Valid {
-		v.logger.Warn("Transaction validation failed",
-			logger.String("transactionType", transaction.TransactionType().String()),
-			logger.String("portfolioId", transaction.PortfolioID().String()),
-			logger.Int("errorCount", len(result.Errors)))
-	}
-
 	return result
 }
 
@@ -143,6 +314,13 @@ func (v *TransactionValidator) validateBasicFields(transaction *models.Transacti
 			Message: "source ID is required",
 			Code:    "REQUIRED",
 		})
+	} else if v.sourceIDPattern != nil && !v.sourceIDPattern.MatchString(transaction.SourceID().String()) {
+		errors = append(errors, ValidationError{
+			Field:   "sourceId",
+			Value:   transaction.SourceID().String(),
+			Message: fmt.Sprintf("source ID must match the pattern %s", v.sourceIDPatternText),
+			Code:    "INVALID_FORMAT",
+		})
 	}
 
 	// Transaction type validation
@@ -233,6 +411,27 @@ func (v *TransactionValidator) validateBusinessRules(ctx context.Context, transa
 	return errors
 }
 
+// validateTransactionDate enforces the configured grace for future transaction dates
+func (v *TransactionValidator) validateTransactionDate(transaction *models.Transaction, now time.Time) []ValidationError {
+	var errors []ValidationError
+
+	if v.maxFutureDays < 0 {
+		return errors
+	}
+
+	latest := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, v.maxFutureDays)
+	if transaction.TransactionDate().After(latest) {
+		errors = append(errors, ValidationError{
+			Field:   "transactionDate",
+			Value:   transaction.TransactionDate().Format("20060102"),
+			Message: fmt.Sprintf("transaction date cannot be more than %d days in the future (latest allowed %s)", v.maxFutureDays, latest.Format("20060102")),
+			Code:    "FUTURE_TRANSACTION_DATE",
+		})
+	}
+
+	return errors
+}
+
 // validatePortfolio validates that the portfolio exists and is accessible
 func (v *TransactionValidator) validatePortfolio(ctx context.Context, transaction *models.Transaction) []ValidationError {
 	var errors []ValidationError
@@ -275,6 +474,93 @@ func (v *TransactionValidator) validateSecurity(ctx context.Context, transaction
 	return errors
 }
 
+// applyReferencePolicy looks up the transaction's references and rejects, warns about or
+// quarantines the transaction when any is unknown
+func (v *TransactionValidator) applyReferencePolicy(ctx context.Context, transaction *models.Transaction, result *ValidationResult) {
+	if v.referenceChecker == nil || v.referencePolicy == UnknownReferenceOff {
+		return
+	}
+
+	unknown := v.UnknownReferences(ctx, transaction)
+	if len(unknown) == 0 {
+		return
+	}
+
+	switch v.referencePolicy {
+	case UnknownReferenceReject:
+		result.Errors = append(result.Errors, unknown...)
+	case UnknownReferenceWarn:
+		result.UnknownReferences = unknown
+		v.logger.Warn("Transaction refers to unknown portfolio or security",
+			logger.String("sourceId", transaction.SourceID().String()),
+			logger.String("portfolioId", transaction.PortfolioID().String()),
+			logger.String("reference", unknown[0].Field))
+	case UnknownReferenceQuarantine:
+		result.UnknownReferences = unknown
+		result.Quarantine = true
+	}
+}
+
+// UnknownReferences returns an error for the transaction's portfolio and security when the
+// reference checker does not know them. A reference whose lookup fails is reported with the
+// REFERENCE_LOOKUP_FAILED code, so the policy rejects or quarantines the transaction instead of
+// treating an unchecked reference as known.
+func (v *TransactionValidator) UnknownReferences(ctx context.Context, transaction *models.Transaction) []ValidationError {
+	if v.referenceChecker == nil {
+		return nil
+	}
+
+	var errors []ValidationError
+
+	portfolioID := transaction.PortfolioID().String()
+	exists, err := v.referenceChecker.PortfolioExists(ctx, portfolioID)
+	if err != nil {
+		v.logger.Warn("Failed to look up portfolio",
+			logger.String("portfolioId", portfolioID),
+			logger.Err(err))
+		errors = append(errors, referenceLookupFailed("portfolioId", portfolioID, err))
+	} else if !exists {
+		errors = append(errors, ValidationError{
+			Field:   "portfolioId",
+			Value:   portfolioID,
+			Message: "portfolio is not known to the portfolio service",
+			Code:    "UNKNOWN_PORTFOLIO",
+		})
+	}
+
+	if transaction.IsCashTransaction() {
+		return errors
+	}
+
+	securityID := transaction.SecurityID().String()
+	exists, err = v.referenceChecker.SecurityExists(ctx, securityID)
+	if err != nil {
+		v.logger.Warn("Failed to look up security",
+			logger.String("securityId", securityID),
+			logger.Err(err))
+		errors = append(errors, referenceLookupFailed("securityId", securityID, err))
+	} else if !exists {
+		errors = append(errors, ValidationError{
+			Field:   "securityId",
+			Value:   securityID,
+			Message: "security is not known to the security service",
+			Code:    "UNKNOWN_SECURITY",
+		})
+	}
+
+	return errors
+}
+
+// referenceLookupFailed reports a reference that could not be looked up
+func referenceLookupFailed(field, value string, err error) ValidationError {
+	return ValidationError{
+		Field:   field,
+		Value:   value,
+		Message: fmt.Sprintf("reference lookup failed: %v", err),
+		Code:    "REFERENCE_LOOKUP_FAILED",
+	}
+}
+
 // validateSourceIDUniqueness ensures the source ID is unique
 func (v *TransactionValidator) validateSourceIDUniqueness(ctx context.Context, transaction *models.Transaction) []ValidationError {
 	var errors []ValidationError
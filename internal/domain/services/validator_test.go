@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+func TestTransactionValidator_ValidateTransactionDate(t *testing.T) {
+	now := time.Date(2024, 6, 10, 15, 30, 0, 0, time.UTC)
+	today := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		maxFutureDays int
+		date          time.Time
+		wantErr       bool
+	}{
+		{name: "unlimited by default allows far future", maxFutureDays: -1, date: today.AddDate(1, 0, 0)},
+		{name: "today with zero grace", maxFutureDays: 0, date: today},
+		{name: "tomorrow with zero grace", maxFutureDays: 0, date: today.AddDate(0, 0, 1), wantErr: true},
+		{name: "within grace", maxFutureDays: 2, date: today.AddDate(0, 0, 1)},
+		{name: "at grace boundary", maxFutureDays: 2, date: today.AddDate(0, 0, 2)},
+		{name: "beyond grace", maxFutureDays: 2, date: today.AddDate(0, 0, 3), wantErr: true},
+		{name: "past date", maxFutureDays: 0, date: today.AddDate(0, 0, -30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewTransactionValidator(nil, nil, logger.NewNoop())
+			if tt.maxFutureDays >= 0 {
+				validator.WithMaxFutureDays(tt.maxFutureDays)
+			}
+
+			transaction := buildReplayTransaction(t, 1, "DEP", 100, 1, tt.date)
+			errs := validator.validateTransactionDate(transaction, now)
+
+			if !tt.wantErr {
+				assert.Empty(t, errs)
+				return
+			}
+
+			require.Len(t, errs, 1)
+			assert.Equal(t, "transactionDate", errs[0].Field)
+			assert.Equal(t, "FUTURE_TRANSACTION_DATE", errs[0].Code)
+			assert.Equal(t, tt.date.Format("20060102"), errs[0].Value)
+			assert.Contains(t, errs[0].Message, today.AddDate(0, 0, tt.maxFutureDays).Format("20060102"))
+		})
+	}
+}
+
+func TestTransactionValidator_SourceIDPattern(t *testing.T) {
+	pattern := regexp.MustCompile(`^(?:SYS-\d{8}-\d+)$`)
+
+	tests := []struct {
+		name     string
+		pattern  *regexp.Regexp
+		sourceID string
+		wantErr  bool
+	}{
+		{name: "any source ID without a pattern", sourceID: "free form 1"},
+		{name: "conforming source ID", pattern: pattern, sourceID: "SYS-20240610-42"},
+		{name: "wrong prefix", pattern: pattern, sourceID: "ABC-20240610-42", wantErr: true},
+		{name: "short date", pattern: pattern, sourceID: "SYS-2024061-42", wantErr: true},
+		{name: "trailing characters", pattern: pattern, sourceID: "SYS-20240610-42x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewTransactionValidator(nil, nil, logger.NewNoop())
+			if tt.pattern != nil {
+				validator.WithSourceIDPattern(tt.pattern, `SYS-\d{8}-\d+`)
+			}
+
+			transaction, err := models.NewTransactionBuilder().
+				WithPortfolioID(testPortfolioID).
+				WithSourceID(tt.sourceID).
+				WithTransactionType("DEP").
+				WithQuantity(decimal.NewFromInt(100)).
+				WithPrice(decimal.NewFromInt(1)).
+				WithTransactionDate(time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)).
+				Build()
+			require.NoError(t, err)
+
+			var sourceIDErrors []ValidationError
+			for _, validationErr := range validator.validateBasicFields(transaction) {
+				if validationErr.Field == "sourceId" {
+					sourceIDErrors = append(sourceIDErrors, validationErr)
+				}
+			}
+
+			if !tt.wantErr {
+				assert.Empty(t, sourceIDErrors)
+				return
+			}
+
+			require.Len(t, sourceIDErrors, 1)
+			assert.Equal(t, "INVALID_FORMAT", sourceIDErrors[0].Code)
+			assert.Equal(t, tt.sourceID, sourceIDErrors[0].Value)
+			assert.Equal(t, `source ID must match the pattern SYS-\d{8}-\d+`, sourceIDErrors[0].Message)
+		})
+	}
+}
+
+// stubReferenceChecker knows the references listed in it
+type stubReferenceChecker struct {
+	portfolios map[string]bool
+	securities map[string]bool
+	err        error
+}
+
+func (c *stubReferenceChecker) PortfolioExists(ctx context.Context, portfolioID string) (bool, error) {
+	return c.portfolios[portfolioID], c.err
+}
+
+func (c *stubReferenceChecker) SecurityExists(ctx context.Context, securityID string) (bool, error) {
+	return c.securities[securityID], c.err
+}
+
+func TestTransactionValidator_UnknownReferencePolicy(t *testing.T) {
+	date := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	known := &stubReferenceChecker{
+		portfolios: map[string]bool{testPortfolioID: true},
+		securities: map[string]bool{testSecurityID: true},
+	}
+	unknownSecurity := &stubReferenceChecker{portfolios: map[string]bool{testPortfolioID: true}}
+
+	tests := []struct {
+		name           string
+		policy         UnknownReferencePolicy
+		checker        *stubReferenceChecker
+		txnType        string
+		wantErrors     []string
+		wantUnknown    []string
+		wantQuarantine bool
+	}{
+		{name: "off does not look up references", policy: UnknownReferenceOff, checker: &stubReferenceChecker{}, txnType: "BUY"},
+		{name: "known references pass", policy: UnknownReferenceReject, checker: known, txnType: "BUY"},
+		{name: "reject unknown security", policy: UnknownReferenceReject, checker: unknownSecurity, txnType: "BUY", wantErrors: []string{"UNKNOWN_SECURITY"}},
+		{name: "reject unknown portfolio and security", policy: UnknownReferenceReject, checker: &stubReferenceChecker{}, txnType: "BUY", wantErrors: []string{"UNKNOWN_PORTFOLIO", "UNKNOWN_SECURITY"}},
+		{name: "cash transactions have no security to look up", policy: UnknownReferenceReject, checker: unknownSecurity, txnType: "DEP"},
+		{name: "warn keeps the transaction valid", policy: UnknownReferenceWarn, checker: unknownSecurity, txnType: "BUY", wantUnknown: []string{"UNKNOWN_SECURITY"}},
+		{name: "quarantine", policy: UnknownReferenceQuarantine, checker: &stubReferenceChecker{}, txnType: "SELL", wantUnknown: []string{"UNKNOWN_PORTFOLIO", "UNKNOWN_SECURITY"}, wantQuarantine: true},
+		{name: "failed lookups are quarantined", policy: UnknownReferenceQuarantine, checker: &stubReferenceChecker{err: errors.New("unavailable")}, txnType: "BUY", wantUnknown: []string{"REFERENCE_LOOKUP_FAILED", "REFERENCE_LOOKUP_FAILED"}, wantQuarantine: true},
+		{name: "failed lookups are rejected", policy: UnknownReferenceReject, checker: &stubReferenceChecker{err: errors.New("unavailable")}, txnType: "DEP", wantErrors: []string{"REFERENCE_LOOKUP_FAILED"}},
+	}
+
+	codes := func(errs []ValidationError) []string {
+		var result []string
+		for _, validationErr := range errs {
+			result = append(result, validationErr.Code)
+		}
+		return result
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewTransactionValidator(nil, nil, logger.NewNoop()).
+				WithUnknownReferencePolicy(tt.checker, tt.policy)
+
+			result := &ValidationResult{Valid: true}
+			validator.applyReferencePolicy(context.Background(), buildReplayTransaction(t, 1, tt.txnType, 10, 1, date), result)
+
+			assert.Equal(t, tt.wantErrors, codes(result.Errors))
+			assert.Equal(t, tt.wantUnknown, codes(result.UnknownReferences))
+			assert.Equal(t, tt.wantQuarantine, result.Quarantine)
+		})
+	}
+}
+
+// countingTransactionRepo reports a fixed number of stored transactions for any filter
+type countingTransactionRepo struct {
+	repositories.TransactionRepository
+
+	count   int64
+	filters []repositories.TransactionFilter
+}
+
+func (r *countingTransactionRepo) Count(ctx context.Context, filter repositories.TransactionFilter) (int64, error) {
+	r.filters = append(r.filters, filter)
+	return r.count, nil
+}
+
+func TestTransactionValidator_CheckDailyLimit(t *testing.T) {
+	date := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		limit     int
+		overrides map[string]int
+		booked    int64
+		pending   int
+		wantLimit int
+	}{
+		{name: "unlimited by default", booked: 1000},
+		{name: "below the limit", limit: 3, booked: 2},
+		{name: "at the limit", limit: 3, booked: 3, wantLimit: 3},
+		{name: "batch records count toward the limit", limit: 3, booked: 1, pending: 2, wantLimit: 3},
+		{name: "override raises the limit", limit: 3, overrides: map[string]int{testPortfolioID: 5}, booked: 4},
+		{name: "override lowers the limit", limit: 3, overrides: map[string]int{testPortfolioID: 1}, booked: 1, wantLimit: 1},
+		{name: "override matches case-insensitively", limit: 3, overrides: map[string]int{strings.ToLower(testPortfolioID): 1}, booked: 1, wantLimit: 1},
+		{name: "override of 0 is unlimited", limit: 3, overrides: map[string]int{testPortfolioID: 0}, booked: 1000},
+		{name: "other portfolio overrides do not apply", limit: 3, overrides: map[string]int{"PORTFOLIO000000000000000": 10}, booked: 3, wantLimit: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &countingTransactionRepo{count: tt.booked}
+			validator := NewTransactionValidator(repo, nil, logger.NewNoop()).
+				WithDailyTransactionLimit(tt.limit, tt.overrides)
+
+			err := validator.CheckDailyLimit(context.Background(), buildReplayTransaction(t, 0, "DEP", 10, 1, date), tt.pending)
+
+			if tt.wantLimit == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var limitErr *DailyLimitExceededError
+			require.ErrorAs(t, err, &limitErr)
+			assert.Equal(t, testPortfolioID, limitErr.PortfolioID)
+			assert.Equal(t, tt.wantLimit, limitErr.Limit)
+			assert.True(t, date.Equal(limitErr.TransactionDate))
+
+			require.Len(t, repo.filters, 1)
+			assert.Equal(t, testPortfolioID, *repo.filters[0].PortfolioID)
+			assert.True(t, date.Equal(*repo.filters[0].TransactionDate))
+		})
+	}
+}
@@ -13,11 +13,13 @@ import (
 type CacheAsideService struct {
 	cache      Cache
 	keyService *CacheKeyService
+	operations *operationRunner
 	logger     logger.Logger
 }
 
-// NewCacheAsideService creates a new cache-aside service
-func NewCacheAsideService(cache Cache, keyPrefix string, lg logger.Logger) *CacheAsideService {
+// NewCacheAsideService creates a new cache-aside service. Each cache call is bounded and
+// retried according to options.
+func NewCacheAsideService(cache Cache, keyPrefix string, options OperationOptions, lg logger.Logger) *CacheAsideService {
 	if lg == nil {
 		lg = logger.NewDevelopment()
 	}
@@ -25,14 +27,21 @@ func NewCacheAsideService(cache Cache, keyPrefix string, lg logger.Logger) *Cach
 	return &CacheAsideService{
 		cache:      cache,
 		keyService: NewCacheKeyService(keyPrefix),
+		operations: newOperationRunner(options, lg),
 		logger:     lg,
 	}
 }
 
-// GetOrSet retrieves from cache or sets from provider function
+// GetOrSet retrieves from cache or sets from provider function. A failing or slow cache never
+// fails the request: the value is served from the provider instead.
 func (cas *CacheAsideService) GetOrSet(ctx context.Context, key string, provider func() (interface{}, error), ttl time.Duration) (interface{}, error) {
 	// Try to get from cache first
-	data, err := cas.cache.Get(ctx, key)
+	var data []byte
+	err := cas.operations.run(ctx, "get", func(ctx context.Context) error {
+		var getErr error
+		data, getErr = cas.cache.Get(ctx, key)
+		return getErr
+	})
 	if err == nil {
 		// Cache hit - deserialize and return
 		var result interface{}
@@ -49,9 +58,14 @@ func (cas *CacheAsideService) GetOrSet(ctx context.Context, key string, provider
 	}
 
 	// Cache miss or error - get from provider
-	cas.logger.Debug("Cache miss",
-		logger.String("key", key),
-		logger.Err(err))
+	if err != nil && !IsKeyNotFoundError(err) {
+		cas.logger.Warn("Cache read failed, falling back to source",
+			logger.String("key", key),
+			logger.Err(err))
+	} else {
+		cas.logger.Debug("Cache miss",
+			logger.String("key", key))
+	}
 
 	value, err := provider()
 	if err != nil {
@@ -76,17 +90,30 @@ func (cas *CacheAsideService) Set(ctx context.Context, key string, value interfa
 		return fmt.Errorf("failed to marshal value: %w", err)
 	}
 
-	return cas.cache.Set(ctx, key, data, ttl)
+	return cas.operations.run(ctx, "set", func(ctx context.Context) error {
+		return cas.cache.Set(ctx, key, data, ttl)
+	})
 }
 
 // Delete removes a value from cache
 func (cas *CacheAsideService) Delete(ctx context.Context, key string) error {
-	return cas.cache.Delete(ctx, key)
+	return cas.operations.run(ctx, "delete", func(ctx context.Context) error {
+		return cas.cache.Delete(ctx, key)
+	})
+}
+
+// DeleteMultiple removes several values from cache
+func (cas *CacheAsideService) DeleteMultiple(ctx context.Context, keys []string) error {
+	return cas.operations.run(ctx, "delete_multiple", func(ctx context.Context) error {
+		return cas.cache.DeleteMultiple(ctx, keys)
+	})
 }
 
 // InvalidatePattern removes all keys matching a pattern
 func (cas *CacheAsideService) InvalidatePattern(ctx context.Context, pattern string) error {
-	return cas.cache.DeletePattern(ctx, pattern)
+	return cas.operations.run(ctx, "delete_pattern", func(ctx context.Context) error {
+		return cas.cache.DeletePattern(ctx, pattern)
+	})
 }
 
 // TransactionCacheAside provides cache-aside operations for transactions
@@ -95,9 +122,9 @@ type TransactionCacheAside struct {
 }
 
 // NewTransactionCacheAside creates a new transaction cache-aside service
-func NewTransactionCacheAside(cache Cache, keyPrefix string, lg logger.Logger) *TransactionCacheAside {
+func NewTransactionCacheAside(cache Cache, keyPrefix string, options OperationOptions, lg logger.Logger) *TransactionCacheAside {
 	return &TransactionCacheAside{
-		CacheAsideService: NewCacheAsideService(cache, keyPrefix, lg),
+		CacheAsideService: NewCacheAsideService(cache, keyPrefix, options, lg),
 	}
 }
 
@@ -130,7 +157,7 @@ func (tca *TransactionCacheAside) InvalidateTransaction(ctx context.Context, id
 		tca.keyService.Builder().TransactionStats(),
 	}
 
-	return tca.cache.DeleteMultiple(ctx, keys)
+	return tca.DeleteMultiple(ctx, keys)
 }
 
 // InvalidateTransactionsByPortfolio removes portfolio-related transaction cache entries
@@ -145,9 +172,9 @@ type BalanceCacheAside struct {
 }
 
 // NewBalanceCacheAside creates a new balance cache-aside service
-func NewBalanceCacheAside(cache Cache, keyPrefix string, lg logger.Logger) *BalanceCacheAside {
+func NewBalanceCacheAside(cache Cache, keyPrefix string, options OperationOptions, lg logger.Logger) *BalanceCacheAside {
 	return &BalanceCacheAside{
-		CacheAsideService: NewCacheAsideService(cache, keyPrefix, lg),
+		CacheAsideService: NewCacheAsideService(cache, keyPrefix, options, lg),
 	}
 }
 
@@ -208,7 +235,7 @@ func (bca *BalanceCacheAside) InvalidateBalance(ctx context.Context, id int64, p
 		keys = append(keys, bca.keyService.Builder().CashBalance(portfolioID))
 	}
 
-	return bca.cache.DeleteMultiple(ctx, keys)
+	return bca.DeleteMultiple(ctx, keys)
 }
 
 // InvalidatePortfolioBalances removes all balance cache entries for a portfolio
@@ -223,9 +250,9 @@ type ExternalServiceCacheAside struct {
 }
 
 // NewExternalServiceCacheAside creates a new external service cache-aside service
-func NewExternalServiceCacheAside(cache Cache, keyPrefix string, lg logger.Logger) *ExternalServiceCacheAside {
+func NewExternalServiceCacheAside(cache Cache, keyPrefix string, options OperationOptions, lg logger.Logger) *ExternalServiceCacheAside {
 	return &ExternalServiceCacheAside{
-		CacheAsideService: NewCacheAsideService(cache, keyPrefix, lg),
+		CacheAsideService: NewCacheAsideService(cache, keyPrefix, options, lg),
 	}
 }
 
@@ -265,15 +292,15 @@ type CacheAsideManager struct {
 }
 
 // NewCacheAsideManager creates a new cache-aside manager
-func NewCacheAsideManager(cache Cache, keyPrefix string, lg logger.Logger) *CacheAsideManager {
+func NewCacheAsideManager(cache Cache, keyPrefix string, options OperationOptions, lg logger.Logger) *CacheAsideManager {
 	if lg == nil {
 		lg = logger.NewDevelopment()
 	}
 
 	return &CacheAsideManager{
-		Transaction:     NewTransactionCacheAside(cache, keyPrefix, lg),
-		Balance:         NewBalanceCacheAside(cache, keyPrefix, lg),
-		ExternalService: NewExternalServiceCacheAside(cache, keyPrefix, lg),
+		Transaction:     NewTransactionCacheAside(cache, keyPrefix, options, lg),
+		Balance:         NewBalanceCacheAside(cache, keyPrefix, options, lg),
+		ExternalService: NewExternalServiceCacheAside(cache, keyPrefix, options, lg),
 		cache:           cache,
 		logger:          lg,
 	}
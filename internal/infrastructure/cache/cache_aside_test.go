@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// slowCache blocks every call until the caller's context is done
+type slowCache struct {
+	Cache
+
+	gets atomic.Int32
+}
+
+func (c *slowCache) Get(ctx context.Context, key string) ([]byte, error) {
+	c.gets.Add(1)
+	<-ctx.Done()
+	return nil, NewCacheError("get", key, ctx.Err())
+}
+
+func (c *slowCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	<-ctx.Done()
+	return NewCacheError("set", key, ctx.Err())
+}
+
+// failingCache fails its first reads as if the cache were still connecting
+type failingCache struct {
+	*MemoryCache
+
+	failures atomic.Int32
+}
+
+func (c *failingCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if c.failures.Add(-1) >= 0 {
+		return nil, NewCacheError("get", key, ErrCacheNotReady)
+	}
+	return c.MemoryCache.Get(ctx, key)
+}
+
+func newTestCacheAside(t *testing.T, c Cache, options OperationOptions) (*CacheAsideService, *sdkmetric.ManualReader) {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	options.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	return NewCacheAsideService(c, "test", options, logger.NewNoop()), reader
+}
+
+// cacheErrors returns the number of recorded cache errors for an operation and reason
+func cacheErrors(t *testing.T, reader *sdkmetric.ManualReader, operation, reason string) int64 {
+	t.Helper()
+
+	var collected metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &collected))
+	for _, scope := range collected.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != "cache_errors_total" {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			require.True(t, ok)
+			for _, point := range sum.DataPoints {
+				op, _ := point.Attributes.Value(attribute.Key("operation"))
+				r, _ := point.Attributes.Value(attribute.Key("reason"))
+				if op.AsString() == operation && r.AsString() == reason {
+					return point.Value
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestCacheAsideService_SlowCacheFallsBackToProvider(t *testing.T) {
+	slow := &slowCache{}
+	service, reader := newTestCacheAside(t, slow, OperationOptions{
+		Timeout:    20 * time.Millisecond,
+		MaxRetries: 1,
+	})
+
+	start := time.Now()
+	value, err := service.GetOrSet(context.Background(), "balance:1", func() (interface{}, error) {
+		return "from-source", nil
+	}, time.Minute)
+	elapsed := time.Since(start)
+
+	require.NoError(t, err)
+	assert.Equal(t, "from-source", value)
+	assert.Less(t, elapsed, time.Second, "a slow cache must not stall the request")
+	assert.Equal(t, int32(2), slow.gets.Load(), "a timed out read is retried once")
+	assert.Equal(t, int64(2), cacheErrors(t, reader, "get", "timeout"))
+	assert.Equal(t, int64(2), cacheErrors(t, reader, "set", "timeout"))
+}
+
+func TestCacheAsideService_RetriesTransientReadErrors(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryCache(MemoryCacheOptions{MaxEntries: 10, Logger: logger.NewNoop()})
+	defer memory.Close()
+
+	flaky := &failingCache{MemoryCache: memory}
+	service, reader := newTestCacheAside(t, flaky, OperationOptions{Timeout: time.Second, MaxRetries: 1})
+	require.NoError(t, service.Set(ctx, "balance:2", "cached", time.Minute))
+
+	flaky.failures.Store(1)
+	value, err := service.GetOrSet(ctx, "balance:2", func() (interface{}, error) {
+		t.Fatal("provider must not be called when the retry hits the cache")
+		return nil, nil
+	}, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "cached", value)
+	assert.Equal(t, int64(1), cacheErrors(t, reader, "get", "error"))
+
+	// Without retries the request is served from the provider
+	flaky.failures.Store(5)
+	noRetry, _ := newTestCacheAside(t, flaky, OperationOptions{Timeout: time.Second})
+	value, err = noRetry.GetOrSet(ctx, "balance:2", func() (interface{}, error) {
+		return "from-source", nil
+	}, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "from-source", value)
+}
@@ -34,6 +34,15 @@ type Config struct {
 	// DefaultTTL is the default time-to-live for cache entries
 	DefaultTTL time.Duration `mapstructure:"default_ttl" json:"default_ttl"`
 
+	// OperationTimeout bounds each attempt of a cache-aside call; zero uses 250ms
+	OperationTimeout time.Duration `mapstructure:"operation_timeout" json:"operation_timeout"`
+
+	// OperationRetries is the number of retries of a cache-aside call after a transient failure
+	OperationRetries int `mapstructure:"operation_retries" json:"operation_retries"`
+
+	// RetryBackoff is the pause between cache-aside call attempts
+	RetryBackoff time.Duration `mapstructure:"retry_backoff" json:"retry_backoff"`
+
 	// Redis specific configuration
 	Redis RedisConfig `mapstructure:"redis" json:"redis"`
 
@@ -85,6 +94,18 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("default TTL cannot be negative")
 	}
 
+	if c.OperationTimeout < 0 {
+		return fmt.Errorf("operation timeout cannot be negative")
+	}
+
+	if c.OperationRetries < 0 {
+		return fmt.Errorf("operation retries cannot be negative")
+	}
+
+	if c.RetryBackoff < 0 {
+		return fmt.Errorf("retry backoff cannot be negative")
+	}
+
 	return nil
 }
 
@@ -109,6 +130,20 @@ func (c *Config) SetDefaults() {
 	c.Memory.SetDefaults()
 }
 
+// OperationOptions returns the per-call bounds applied by the cache-aside services
+func (c *Config) OperationOptions() OperationOptions {
+	timeout := c.OperationTimeout
+	if timeout == 0 {
+		timeout = 250 * time.Millisecond
+	}
+
+	return OperationOptions{
+		Timeout:      timeout,
+		MaxRetries:   c.OperationRetries,
+		RetryBackoff: c.RetryBackoff,
+	}
+}
+
 // Validate validates the Redis configuration
 func (rc *RedisConfig) Validate() error {
 	if rc.Address == "" {
@@ -300,7 +335,7 @@ func NewCacheManager(config Config, lg logger.Logger) (*CacheManager, error) {
 		return nil, fmt.Errorf("failed to create cache: %w", err)
 	}
 
-	cacheAside := NewCacheAsideManager(cache, config.KeyPrefix, lg)
+	cacheAside := NewCacheAsideManager(cache, config.KeyPrefix, config.OperationOptions(), lg)
 
 	return &CacheManager{
 		cache:      cache,
@@ -320,6 +355,11 @@ func (cm *CacheManager) CacheAside() *CacheAsideManager {
 	return cm.cacheAside
 }
 
+// ResponseCache returns a response cache on the managed cache storing entries for ttl
+func (cm *CacheManager) ResponseCache(ttl time.Duration) *ResponseCache {
+	return NewResponseCache(cm.cache, cm.config.KeyPrefix, ttl, cm.config.OperationOptions(), cm.logger)
+}
+
 // IsEnabled returns whether caching is enabled
 func (cm *CacheManager) IsEnabled() bool {
 	return cm.config.Enabled
@@ -93,6 +93,11 @@ func (kb *KeyBuilder) Security(securityID string) string {
 	return kb.buildKey("external", "security", securityID)
 }
 
+// Response cache keys
+func (kb *KeyBuilder) Response(name, variant string) string {
+	return kb.buildKey("response", name, variant)
+}
+
 // Session and processing cache keys
 func (kb *KeyBuilder) ProcessingLock(portfolioID string) string {
 	return kb.buildKey("lock", "processing", portfolioID)
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// cacheMeterName is the instrumentation scope of the cache operation metrics
+const cacheMeterName = "globeco-portfolio-accounting-service/cache"
+
+// OperationOptions bounds the individual cache calls made by the cache-aside services
+type OperationOptions struct {
+	// Timeout bounds each attempt of a cache operation; zero leaves it to the caller's context
+	Timeout time.Duration
+
+	// MaxRetries is the number of additional attempts made after a transient failure
+	MaxRetries int
+
+	// RetryBackoff is the pause between attempts
+	RetryBackoff time.Duration
+
+	// MeterProvider records cache error metrics; nil uses the global provider
+	MeterProvider metric.MeterProvider
+}
+
+// operationRunner executes cache operations with a per-attempt timeout and a small retry
+type operationRunner struct {
+	options OperationOptions
+	errors  metric.Int64Counter
+	logger  logger.Logger
+}
+
+// newOperationRunner creates a runner for the given options. The error counter is left nil
+// and skipped if it fails to initialize.
+func newOperationRunner(options OperationOptions, lg logger.Logger) *operationRunner {
+	provider := options.MeterProvider
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+
+	counter, err := provider.Meter(cacheMeterName).Int64Counter(
+		"cache_errors_total",
+		metric.WithDescription("Total number of failed cache operation attempts by operation and reason"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		lg.Warn("Failed to create cache error counter", logger.Err(err))
+	}
+
+	return &operationRunner{
+		options: options,
+		errors:  counter,
+		logger:  lg,
+	}
+}
+
+// run executes fn, retrying transient failures while the caller's context is still live.
+// A missing key is a normal outcome and is neither retried nor counted as an error.
+func (r *operationRunner) run(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt <= r.options.MaxRetries; attempt++ {
+		if attempt > 0 && !r.wait(ctx) {
+			return err
+		}
+
+		err = r.attempt(ctx, fn)
+		if err == nil || IsKeyNotFoundError(err) {
+			return err
+		}
+
+		r.recordError(ctx, operation, err)
+		if ctx.Err() != nil || !isTransientCacheError(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// attempt runs a single try of fn bounded by the operation timeout
+func (r *operationRunner) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if r.options.Timeout <= 0 {
+		return fn(ctx)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, r.options.Timeout)
+	defer cancel()
+	return fn(attemptCtx)
+}
+
+// wait pauses for the retry backoff and reports whether the caller's context is still live
+func (r *operationRunner) wait(ctx context.Context) bool {
+	if r.options.RetryBackoff <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(r.options.RetryBackoff)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// recordError counts a failed attempt
+func (r *operationRunner) recordError(ctx context.Context, operation string, err error) {
+	if r.errors == nil {
+		return
+	}
+
+	reason := "error"
+	if errors.Is(err, context.DeadlineExceeded) {
+		reason = "timeout"
+	}
+
+	// The caller may already be cancelled; the metric is still worth recording
+	r.errors.Add(context.WithoutCancel(ctx), 1, metric.WithAttributes(
+		attribute.String("operation", operation),
+		attribute.String("reason", reason),
+	))
+}
+
+// isTransientCacheError reports whether a failed cache call may succeed if retried
+func isTransientCacheError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrCacheNotReady) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
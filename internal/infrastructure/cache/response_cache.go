@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// Response cache lookup results recorded by response_cache_lookups_total
+const (
+	ResponseCacheHit     = "hit"
+	ResponseCacheMiss    = "miss"
+	ResponseCacheRefresh = "refresh"
+)
+
+// ResponseCache keeps the encoded responses of expensive, slowly changing reads for a short TTL.
+// Like the cache-aside services, a failing or slow cache never fails the request: the response is
+// computed instead.
+type ResponseCache struct {
+	cache      Cache
+	keys       *KeyBuilder
+	ttl        time.Duration
+	operations *operationRunner
+	lookups    metric.Int64Counter
+	logger     logger.Logger
+}
+
+// NewResponseCache creates a response cache storing entries for ttl. Each cache call is bounded
+// and retried according to options.
+func NewResponseCache(cache Cache, keyPrefix string, ttl time.Duration, options OperationOptions, lg logger.Logger) *ResponseCache {
+	if lg == nil {
+		lg = logger.NewDevelopment()
+	}
+
+	provider := options.MeterProvider
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+	lookups, err := provider.Meter(cacheMeterName).Int64Counter(
+		"response_cache_lookups_total",
+		metric.WithDescription("Total number of response cache lookups by response and result"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		lg.Warn("Failed to create response cache lookup counter", logger.Err(err))
+	}
+
+	return &ResponseCache{
+		cache:      cache,
+		keys:       NewKeyBuilder(keyPrefix),
+		ttl:        ttl,
+		operations: newOperationRunner(options, lg),
+		lookups:    lookups,
+		logger:     lg,
+	}
+}
+
+// TTL returns how long a stored response is served
+func (rc *ResponseCache) TTL() time.Duration {
+	return rc.ttl
+}
+
+// Get returns the stored response of name for variant, e.g. a hash of the request filter. With
+// refresh the stored response is skipped so the caller recomputes and replaces it.
+func (rc *ResponseCache) Get(ctx context.Context, name, variant string, refresh bool) ([]byte, bool) {
+	if refresh {
+		rc.record(ctx, name, ResponseCacheRefresh)
+		return nil, false
+	}
+
+	key := rc.keys.Response(name, variant)
+	var data []byte
+	err := rc.operations.run(ctx, "get", func(ctx context.Context) error {
+		var getErr error
+		data, getErr = rc.cache.Get(ctx, key)
+		return getErr
+	})
+	if err != nil {
+		if !IsKeyNotFoundError(err) {
+			rc.logger.Warn("Response cache read failed, computing the response",
+				logger.String("key", key),
+				logger.Err(err))
+		}
+		rc.record(ctx, name, ResponseCacheMiss)
+		return nil, false
+	}
+
+	rc.record(ctx, name, ResponseCacheHit)
+	return data, true
+}
+
+// Set stores the response of name for variant. A failure is logged and otherwise ignored.
+func (rc *ResponseCache) Set(ctx context.Context, name, variant string, data []byte) {
+	key := rc.keys.Response(name, variant)
+	err := rc.operations.run(ctx, "set", func(ctx context.Context) error {
+		return rc.cache.Set(ctx, key, data, rc.ttl)
+	})
+	if err != nil {
+		rc.logger.Warn("Failed to cache response",
+			logger.String("key", key),
+			logger.Err(err))
+	}
+}
+
+// record counts a lookup
+func (rc *ResponseCache) record(ctx context.Context, name, result string) {
+	if rc.lookups == nil {
+		return
+	}
+	rc.lookups.Add(context.WithoutCancel(ctx), 1, metric.WithAttributes(
+		attribute.String("response", name),
+		attribute.String("result", result),
+	))
+}
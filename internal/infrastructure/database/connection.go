@@ -21,6 +21,9 @@ type DB struct {
 	*sqlx.DB
 	config config.DatabaseConfig
 	logger logger.Logger
+
+	// writeLimiter bounds the concurrent transactions of WithTransaction; nil allows any number
+	writeLimiter *TransactionLimiter
 }
 
 // Connection represents a database connection with transaction support
@@ -60,6 +63,7 @@ func NewConnection(cfg config.DatabaseConfig, log logger.Logger) (*DB, error) {
 		logger.Int("port", cfg.Port),
 		logger.String("database", cfg.Database),
 		logger.String("user", cfg.User),
+		logger.Duration("statementTimeout", cfg.StatementTimeout),
 	)
 
 	// Open database connection
@@ -84,11 +88,31 @@ func NewConnection(cfg config.DatabaseConfig, log logger.Logger) (*DB, error) {
 
 	log.Info("Successfully connected to database")
 
+	writeLimit := WriteTransactionLimit(cfg.MaxOpenConns, cfg.MaxWriteTransactionsFraction)
+	if writeLimit > 0 {
+		log.Info("Limiting concurrent write transactions",
+			logger.Int("limit", writeLimit),
+			logger.Int("maxOpenConns", cfg.MaxOpenConns))
+	}
+
+	return &DB{
+		DB:           db,
+		config:       cfg,
+		logger:       log,
+		writeLimiter: NewTransactionLimiter(writeLimit),
+	}, nil
+}
+
+// NewFromSQLX wraps an already open connection. The connection is used as it is: no write
+// transaction limit applies and migrations are not configured.
+func NewFromSQLX(db *sqlx.DB, log logger.Logger) *DB {
+	if log == nil {
+		log = logger.NewDevelopment()
+	}
 	return &DB{
 		DB:     db,
-		config: cfg,
 		logger: log,
-	}, nil
+	}
 }
 
 // RunMigrations runs database migrations
@@ -142,8 +166,19 @@ func (db *DB) RunMigrations() error {
 	return nil
 }
 
-// WithTransaction executes a function within a database transaction
+// WithTransaction executes a function within a database transaction. When write transactions
+// are limited, it first waits in line for a slot until ctx is done.
 func (db *DB) WithTransaction(ctx context.Context, fn func(*sqlx.Tx) error) error {
+	// Within RunInTransaction the work joins the enclosing transaction, which commits it
+	if tx, ok := txFromContext(ctx); ok {
+		return fn(tx)
+	}
+
+	if err := db.writeLimiter.Acquire(ctx); err != nil {
+		return fmt.Errorf("failed to begin transaction: waiting for a write transaction slot: %w", err)
+	}
+	defer db.writeLimiter.Release()
+
 	tx, err := db.DB.BeginTxx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -181,6 +216,12 @@ func (db *DB) GetStats() sql.DBStats {
 	return db.DB.Stats()
 }
 
+// WriteTransactionStats returns the usage of the write transaction limit; the limit is zero
+// when write transactions are not limited
+func (db *DB) WriteTransactionStats() TransactionLimiterStats {
+	return db.writeLimiter.Stats()
+}
+
 // HealthCheck performs a health check on the database
 func (db *DB) HealthCheck(ctx context.Context) error {
 	// Test basic connectivity
@@ -212,7 +253,7 @@ func (db *DB) Close() error {
 // GetContext wraps sqlx.GetContext with logging
 func (db *DB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	start := time.Now()
-	err := db.DB.GetContext(ctx, dest, query, args...)
+	err := sqlx.GetContext(ctx, db.ext(ctx), dest, query, args...)
 	duration := time.Since(start)
 
 	if err != nil {
@@ -223,6 +264,8 @@ func (db *DB) GetContext(ctx context.Context, dest interface{}, query string, ar
 				logger.String("query", query),
 				logger.Duration("duration", duration),
 			)
+		} else if ctx.Err() != nil {
+			db.logQueryCanceled(ctx, query, duration)
 		} else {
 			db.logger.Error("Database query failed",
 				logger.String("query", query),
@@ -243,10 +286,12 @@ func (db *DB) GetContext(ctx context.Context, dest interface{}, query string, ar
 // SelectContext wraps sqlx.SelectContext with logging
 func (db *DB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	start := time.Now()
-	err := db.DB.SelectContext(ctx, dest, query, args...)
+	err := sqlx.SelectContext(ctx, db.ext(ctx), dest, query, args...)
 	duration := time.Since(start)
 
-	if err != nil {
+	if err != nil && ctx.Err() != nil {
+		db.logQueryCanceled(ctx, query, duration)
+	} else if err != nil {
 		db.logger.Error("Database query failed",
 			logger.String("query", query),
 			logger.Duration("duration", duration),
@@ -262,10 +307,20 @@ func (db *DB) SelectContext(ctx context.Context, dest interface{}, query string,
 	return err
 }
 
+// logQueryCanceled logs a query abandoned because its context ended, usually because the client
+// went away; it is not a database failure, so it is not logged as an error
+func (db *DB) logQueryCanceled(ctx context.Context, query string, duration time.Duration) {
+	db.logger.Debug("Database query canceled",
+		logger.String("query", query),
+		logger.Duration("duration", duration),
+		logger.Err(ctx.Err()),
+	)
+}
+
 // ExecContext wraps sqlx.ExecContext with logging
 func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	start := time.Now()
-	result, err := db.DB.ExecContext(ctx, query, args...)
+	result, err := db.ext(ctx).ExecContext(ctx, query, args...)
 	duration := time.Since(start)
 
 	if err != nil {
@@ -287,7 +342,7 @@ func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}
 // NamedExecContext wraps sqlx.NamedExecContext with logging
 func (db *DB) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
 	start := time.Now()
-	result, err := db.DB.NamedExecContext(ctx, query, arg)
+	result, err := sqlx.NamedExecContext(ctx, db.ext(ctx), query, arg)
 	duration := time.Since(start)
 
 	if err != nil {
@@ -0,0 +1,301 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// BalanceAdjustmentRepository implements the repositories.BalanceAdjustmentRepository interface for PostgreSQL
+type BalanceAdjustmentRepository struct {
+	db       *database.DB
+	balances *BalanceRepository
+	logger   logger.Logger
+}
+
+// NewBalanceAdjustmentRepository creates a new PostgreSQL balance adjustment repository. Balances
+// are read and written with the cash representation of the given balance repository.
+func NewBalanceAdjustmentRepository(db *database.DB, balances *BalanceRepository, logger logger.Logger) *BalanceAdjustmentRepository {
+	return &BalanceAdjustmentRepository{
+		db:       db,
+		balances: balances,
+		logger:   logger,
+	}
+}
+
+// Apply records an adjustment and applies it to its balance atomically
+func (r *BalanceAdjustmentRepository) Apply(ctx context.Context, adjustment *repositories.BalanceAdjustment, expectedVersion *int) (*repositories.Balance, bool, error) {
+	if replayed, balance, err := r.replay(ctx, adjustment); err != nil || replayed {
+		return balance, false, err
+	}
+
+	var balance *repositories.Balance
+	err := r.db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		var err error
+		balance, err = r.applyInTx(ctx, tx, adjustment, expectedVersion)
+		return err
+	})
+
+	if err != nil {
+		// A concurrent request recorded the same key first
+		if repositories.IsDuplicateKeyError(err) {
+			if replayed, balance, replayErr := r.replay(ctx, adjustment); replayErr != nil || replayed {
+				return balance, false, replayErr
+			}
+		}
+		return nil, false, err
+	}
+
+	r.logger.Info("Balance adjustment applied",
+		logger.Int64("id", adjustment.ID),
+		logger.String("adjustmentKey", adjustment.AdjustmentKey),
+		logger.Int64("balanceId", adjustment.BalanceID),
+		logger.String("operator", adjustment.Operator))
+
+	return balance, true, nil
+}
+
+// replay loads an already recorded adjustment with the same key together with its current balance
+func (r *BalanceAdjustmentRepository) replay(ctx context.Context, adjustment *repositories.BalanceAdjustment) (bool, *repositories.Balance, error) {
+	existing, err := r.GetByKey(ctx, adjustment.AdjustmentKey)
+	if err != nil {
+		if repositories.IsNotFoundError(err) {
+			return false, nil, nil
+		}
+		return false, nil, err
+	}
+
+	balance, err := r.balances.GetByID(ctx, existing.BalanceID)
+	if err != nil {
+		return false, nil, err
+	}
+
+	*adjustment = *existing
+	return true, balance, nil
+}
+
+// applyInTx updates or creates the balance and records the adjustment within tx
+func (r *BalanceAdjustmentRepository) applyInTx(ctx context.Context, tx *sqlx.Tx, adjustment *repositories.BalanceAdjustment, expectedVersion *int) (*repositories.Balance, error) {
+	var current repositories.Balance
+	var args []interface{}
+	query := `
+		SELECT id, portfolio_id, security_id, quantity_long, quantity_short,
+			   last_updated, version, created_at
+		FROM balances
+		WHERE portfolio_id = $1 AND `
+	if adjustment.SecurityID == nil {
+		query += r.balances.cashCondition()
+		args = []interface{}{adjustment.PortfolioID}
+	} else {
+		query += "security_id = $2"
+		args = []interface{}{adjustment.PortfolioID, *adjustment.SecurityID}
+	}
+
+	err := tx.GetContext(ctx, &current, query, args...)
+	exists := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return nil, repositories.NewRepositoryError("get", "balance", err)
+	}
+
+	if expectedVersion != nil {
+		actualVersion := 0
+		if exists {
+			actualVersion = current.Version
+		}
+		if *expectedVersion != actualVersion {
+			return nil, repositories.NewOptimisticLockError("balance", current.ID, *expectedVersion, actualVersion)
+		}
+	}
+
+	balance := &repositories.Balance{
+		ID:            current.ID,
+		PortfolioID:   adjustment.PortfolioID,
+		SecurityID:    adjustment.SecurityID,
+		QuantityLong:  decimal.Zero,
+		QuantityShort: decimal.Zero,
+	}
+	if exists {
+		balance.QuantityLong = current.QuantityLong
+		balance.QuantityShort = current.QuantityShort
+	}
+
+	adjustment.QuantityLongBefore = balance.QuantityLong
+	adjustment.QuantityShortBefore = balance.QuantityShort
+	balance.QuantityLong = balance.QuantityLong.Add(adjustment.QuantityLongDelta)
+	balance.QuantityShort = balance.QuantityShort.Add(adjustment.QuantityShortDelta)
+	adjustment.QuantityLongAfter = balance.QuantityLong
+	adjustment.QuantityShortAfter = balance.QuantityShort
+
+	if exists {
+		updateQuery := `
+			UPDATE balances SET
+				quantity_long = $1,
+				quantity_short = $2,
+				version = version + 1,
+				last_updated = CURRENT_TIMESTAMP
+			WHERE id = $3 AND version = $4
+			RETURNING version, last_updated, created_at`
+
+		row := tx.QueryRowxContext(ctx, updateQuery, balance.QuantityLong, balance.QuantityShort, current.ID, current.Version)
+		if err := row.Scan(&balance.Version, &balance.LastUpdated, &balance.CreatedAt); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, repositories.NewOptimisticLockError("balance", current.ID, current.Version, current.Version+1)
+			}
+			return nil, repositories.NewRepositoryError("update", "balance", err)
+		}
+	} else {
+		insertQuery := `
+			INSERT INTO balances (
+				portfolio_id, security_id, quantity_long, quantity_short, version
+			) VALUES (
+				$1, $2, $3, $4, 1
+			) RETURNING id, version, last_updated, created_at`
+
+		stored := r.balances.toStorage(balance)
+		row := tx.QueryRowxContext(ctx, insertQuery, stored.PortfolioID, stored.SecurityID, stored.QuantityLong, stored.QuantityShort)
+		if err := row.Scan(&balance.ID, &balance.Version, &balance.LastUpdated, &balance.CreatedAt); err != nil {
+			if isDuplicateKeyError(err) {
+				// The balance was created concurrently; the caller may retry
+				return nil, repositories.NewOptimisticLockError("balance", fmt.Sprintf("%s-%v", balance.PortfolioID, balance.SecurityID), 0, 1)
+			}
+			return nil, repositories.NewRepositoryError("create", "balance", err)
+		}
+	}
+
+	adjustment.BalanceID = balance.ID
+	adjustment.BalanceVersion = balance.Version
+
+	insertAdjustment := `
+		INSERT INTO balance_adjustments (
+			adjustment_key, balance_id, portfolio_id, security_id,
+			quantity_long_delta, quantity_short_delta,
+			quantity_long_before, quantity_short_before,
+			quantity_long_after, quantity_short_after,
+			balance_version, reason, operator
+		) VALUES (
+			:adjustment_key, :balance_id, :portfolio_id, :security_id,
+			:quantity_long_delta, :quantity_short_delta,
+			:quantity_long_before, :quantity_short_before,
+			:quantity_long_after, :quantity_short_after,
+			:balance_version, :reason, :operator
+		) RETURNING id, created_at`
+
+	rows, err := sqlx.NamedQueryContext(ctx, tx, insertAdjustment, adjustment)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return nil, repositories.NewDuplicateKeyError("balance_adjustment", "adjustment_key", adjustment.AdjustmentKey)
+		}
+		return nil, repositories.NewRepositoryError("create", "balance_adjustment", err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		if err := rows.Scan(&adjustment.ID, &adjustment.CreatedAt); err != nil {
+			return nil, repositories.NewRepositoryError("scan", "balance_adjustment", err)
+		}
+	}
+
+	return balance, nil
+}
+
+// balanceAdjustmentColumns are the columns every adjustment query selects
+const balanceAdjustmentColumns = `
+		SELECT id, adjustment_key, balance_id, portfolio_id, security_id,
+			   quantity_long_delta, quantity_short_delta,
+			   quantity_long_before, quantity_short_before,
+			   quantity_long_after, quantity_short_after,
+			   balance_version, reason, operator, created_at
+		FROM balance_adjustments`
+
+// portfolioAdjustmentsQuery lists a portfolio's adjustments in the order they were recorded
+const portfolioAdjustmentsQuery = balanceAdjustmentColumns + `
+		WHERE portfolio_id = $1
+		ORDER BY created_at, id`
+
+// GetByKey retrieves an adjustment by its idempotency key
+func (r *BalanceAdjustmentRepository) GetByKey(ctx context.Context, adjustmentKey string) (*repositories.BalanceAdjustment, error) {
+	query := balanceAdjustmentColumns + `
+		WHERE adjustment_key = $1`
+
+	var adjustment repositories.BalanceAdjustment
+	err := r.db.GetContext(ctx, &adjustment, query, adjustmentKey)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, repositories.NewNotFoundError("balance_adjustment", adjustmentKey)
+		}
+		return nil, repositories.NewRepositoryError("get", "balance_adjustment", err)
+	}
+
+	return &adjustment, nil
+}
+
+// ListByPortfolio returns a portfolio's adjustments in the order they were recorded
+func (r *BalanceAdjustmentRepository) ListByPortfolio(ctx context.Context, portfolioID string) ([]*repositories.BalanceAdjustment, error) {
+	var adjustments []*repositories.BalanceAdjustment
+	if err := r.db.SelectContext(ctx, &adjustments, portfolioAdjustmentsQuery, portfolioID); err != nil {
+		return nil, queryError(ctx, "list", "balance_adjustment", err)
+	}
+	return adjustments, nil
+}
+
+// ReleaseKeysBefore clears the idempotency key of up to limit adjustments recorded before cutoff
+func (r *BalanceAdjustmentRepository) ReleaseKeysBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	if limit <= 0 {
+		return 0, fmt.Errorf("limit must be positive: %d", limit)
+	}
+
+	query := `
+		UPDATE balance_adjustments SET adjustment_key = NULL
+		WHERE id IN (
+			SELECT id FROM balance_adjustments
+			WHERE adjustment_key IS NOT NULL AND created_at < $1
+			ORDER BY created_at, id
+			LIMIT $2
+		)`
+
+	result, err := r.db.ExecContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return 0, repositories.NewRepositoryError("release_keys", "balance_adjustment", err)
+	}
+	released, err := result.RowsAffected()
+	if err != nil {
+		return 0, repositories.NewRepositoryError("release_keys", "balance_adjustment", err)
+	}
+	return released, nil
+}
+
+// ReleaseKey clears the idempotency key of the adjustment recorded with it before cutoff
+func (r *BalanceAdjustmentRepository) ReleaseKey(ctx context.Context, adjustmentKey string, cutoff time.Time) (bool, error) {
+	query := `
+		UPDATE balance_adjustments SET adjustment_key = NULL
+		WHERE adjustment_key = $1 AND created_at < $2`
+
+	result, err := r.db.ExecContext(ctx, query, adjustmentKey, cutoff)
+	if err != nil {
+		return false, repositories.NewRepositoryError("release_key", "balance_adjustment", err)
+	}
+	released, err := result.RowsAffected()
+	if err != nil {
+		return false, repositories.NewRepositoryError("release_key", "balance_adjustment", err)
+	}
+	return released > 0, nil
+}
+
+// CountKeys returns the number of adjustments still holding their idempotency key
+func (r *BalanceAdjustmentRepository) CountKeys(ctx context.Context) (int64, error) {
+	var count int64
+	query := `SELECT COUNT(*) FROM balance_adjustments WHERE adjustment_key IS NOT NULL`
+	if err := r.db.GetContext(ctx, &count, query); err != nil {
+		return 0, repositories.NewRepositoryError("count_keys", "balance_adjustment", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,131 @@
+package postgresql
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// RebuildPortfolioBalances locks the portfolio's balances with SELECT ... FOR UPDATE and runs
+// rebuild in the same database transaction, so balance writes of concurrent processing wait
+// until the rebuild is committed or rolled back
+func (r *BalanceRepository) RebuildPortfolioBalances(ctx context.Context, portfolioID string, rebuild func(ctx context.Context, rebuild repositories.PortfolioBalanceRebuild) error) error {
+	return r.db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		query := `
+			SELECT id, portfolio_id, security_id, quantity_long, quantity_short,
+				   last_updated, version, created_at
+			FROM balances
+			WHERE portfolio_id = $1
+			ORDER BY ` + r.cashFirstOrder() + `, created_at
+			FOR UPDATE`
+
+		var balances []*repositories.Balance
+		if err := tx.SelectContext(ctx, &balances, query, portfolioID); err != nil {
+			return queryError(ctx, "lock_portfolio", "balance", err)
+		}
+		r.fromStorage(balances...)
+
+		return rebuild(ctx, &portfolioBalanceRebuild{
+			repo:         r,
+			transactions: NewTransactionRepository(r.db, r.logger),
+			tx:           tx,
+			portfolioID:  portfolioID,
+			balances:     balances,
+		})
+	})
+}
+
+// portfolioBalanceRebuild runs the reads and writes of a portfolio rebuild on its transaction
+type portfolioBalanceRebuild struct {
+	repo         *BalanceRepository
+	transactions *TransactionRepository
+	tx           *sqlx.Tx
+	portfolioID  string
+	balances     []*repositories.Balance
+}
+
+// Balances returns the balances locked when the rebuild started
+func (b *portfolioBalanceRebuild) Balances() []*repositories.Balance {
+	return b.balances
+}
+
+// ListTransactions lists transactions within the rebuild's transaction
+func (b *portfolioBalanceRebuild) ListTransactions(ctx context.Context, filter repositories.TransactionFilter) ([]*repositories.Transaction, error) {
+	query, args, err := b.transactions.buildListQuery(filter)
+	if err != nil {
+		return nil, repositories.NewRepositoryError("build_query", "transaction", err)
+	}
+
+	var transactions []*repositories.Transaction
+	if err := b.tx.SelectContext(ctx, &transactions, query, args...); err != nil {
+		return nil, queryError(ctx, "list", "transaction", err)
+	}
+	return transactions, nil
+}
+
+// ListAdjustments lists the portfolio's adjustments within the rebuild's transaction
+func (b *portfolioBalanceRebuild) ListAdjustments(ctx context.Context) ([]*repositories.BalanceAdjustment, error) {
+	var adjustments []*repositories.BalanceAdjustment
+	if err := b.tx.SelectContext(ctx, &adjustments, portfolioAdjustmentsQuery, b.portfolioID); err != nil {
+		return nil, queryError(ctx, "list", "balance_adjustment", err)
+	}
+	return adjustments, nil
+}
+
+// Create creates a balance within the rebuild's transaction
+func (b *portfolioBalanceRebuild) Create(ctx context.Context, balance *repositories.Balance) error {
+	query := `
+		INSERT INTO balances (
+			portfolio_id, security_id, quantity_long, quantity_short, version
+		) VALUES (
+			:portfolio_id, :security_id, :quantity_long, :quantity_short, :version
+		) RETURNING id, last_updated, created_at`
+
+	rows, err := sqlx.NamedQueryContext(ctx, b.tx, query, b.repo.toStorage(balance))
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return repositories.NewBalanceExistsError(balance.PortfolioID, balance.SecurityID)
+		}
+		return repositories.NewRepositoryError("create", "balance", err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		if err := rows.Scan(&balance.ID, &balance.LastUpdated, &balance.CreatedAt); err != nil {
+			return repositories.NewRepositoryError("scan", "balance", err)
+		}
+	}
+	return rows.Err()
+}
+
+// UpdateQuantities updates a locked balance within the rebuild's transaction
+func (b *portfolioBalanceRebuild) UpdateQuantities(ctx context.Context, id int64, quantityLong, quantityShort decimal.Decimal, version int) error {
+	query := `
+		UPDATE balances SET
+			quantity_long = $1,
+			quantity_short = $2,
+			version = version + 1,
+			last_updated = CURRENT_TIMESTAMP
+		WHERE id = $3 AND version = $4`
+
+	result, err := b.tx.ExecContext(ctx, query, quantityLong, quantityShort, id, version)
+	if err != nil {
+		return repositories.NewRepositoryError("update_quantities", "balance", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return repositories.NewRepositoryError("check_rows", "balance", err)
+	}
+	if rowsAffected == 0 {
+		return b.repo.lockConflict(ctx, "rebuild", id, version, version+1)
+	}
+
+	b.repo.logger.Debug("Balance quantities rebuilt",
+		logger.Int64("id", id))
+	return nil
+}
@@ -10,24 +10,107 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database"
 	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
 )
 
+// balanceMeterName is the instrumentation scope of the balance repository metrics
+const balanceMeterName = "globeco-portfolio-accounting-service/balances"
+
 // BalanceRepository implements the repositories.BalanceRepository interface for PostgreSQL
 type BalanceRepository struct {
 	db     *database.DB
 	logger logger.Logger
+	cash   repositories.CashSecurityID
+
+	maxListFilterSize int
+
+	// lockConflicts counts optimistic lock conflicts by operation; nil if it failed to initialize
+	lockConflicts metric.Int64Counter
 }
 
 // NewBalanceRepository creates a new PostgreSQL balance repository
 func NewBalanceRepository(db *database.DB, logger logger.Logger) *BalanceRepository {
-	return &BalanceRepository{
+	r := &BalanceRepository{
 		db:     db,
 		logger: logger,
 	}
+	return r.WithMeterProvider(nil)
+}
+
+// WithMeterProvider sets the provider that records optimistic lock conflicts; nil uses the global
+// provider
+func (r *BalanceRepository) WithMeterProvider(provider metric.MeterProvider) *BalanceRepository {
+	if provider == nil {
+		provider = otel.GetMeterProvider()
+	}
+
+	counter, err := provider.Meter(balanceMeterName).Int64Counter(
+		"balance_optimistic_lock_conflicts_total",
+		metric.WithDescription("Total number of balance writes rejected by an optimistic lock conflict by operation"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		r.logger.Warn("Failed to create balance lock conflict counter", logger.Err(err))
+	}
+	r.lockConflicts = counter
+	return r
+}
+
+// WithCashSecurityID sets how cash balances store their security ID (NULL by default)
+func (r *BalanceRepository) WithCashSecurityID(cash repositories.CashSecurityID) *BalanceRepository {
+	r.cash = cash
+	return r
+}
+
+// WithMaxListFilterSize caps the values of the IDs, PortfolioIDs and SecurityIDs filters of List
+// and Count (unlimited by default)
+func (r *BalanceRepository) WithMaxListFilterSize(max int) *BalanceRepository {
+	r.maxListFilterSize = max
+	return r
+}
+
+// toStorage returns a copy of the balance with its security ID in stored form
+func (r *BalanceRepository) toStorage(balance *repositories.Balance) *repositories.Balance {
+	stored := *balance
+	stored.SecurityID = r.cash.ToStorage(balance.SecurityID)
+	return &stored
+}
+
+// fromStorage converts stored security IDs back to their domain form
+func (r *BalanceRepository) fromStorage(balances ...*repositories.Balance) {
+	for _, balance := range balances {
+		balance.SecurityID = r.cash.FromStorage(balance.SecurityID)
+	}
+}
+
+// cashCondition returns the SQL condition matching cash balances
+func (r *BalanceRepository) cashCondition() string {
+	if !r.cash.UsesSentinel() {
+		return "security_id IS NULL"
+	}
+	return "security_id = " + pq.QuoteLiteral(r.cash.Sentinel())
+}
+
+// securityCondition returns the SQL condition matching non-cash balances
+func (r *BalanceRepository) securityCondition() string {
+	if !r.cash.UsesSentinel() {
+		return "security_id IS NOT NULL"
+	}
+	return "security_id <> " + pq.QuoteLiteral(r.cash.Sentinel())
+}
+
+// cashFirstOrder returns the ORDER BY expression listing cash before securities
+func (r *BalanceRepository) cashFirstOrder() string {
+	if !r.cash.UsesSentinel() {
+		return "security_id NULLS FIRST"
+	}
+	return fmt.Sprintf("(%s) DESC, security_id", r.cashCondition())
 }
 
 // Create creates a new balance
@@ -39,10 +122,10 @@ func (r *BalanceRepository) Create(ctx context.Context, balance *repositories.Ba
 			:portfolio_id, :security_id, :quantity_long, :quantity_short, :version
 		) RETURNING id, last_updated, created_at`
 
-	rows, err := r.db.NamedQueryContext(ctx, query, balance)
+	rows, err := r.db.NamedQueryContext(ctx, query, r.toStorage(balance))
 	if err != nil {
 		if isDuplicateKeyError(err) {
-			return repositories.NewDuplicateKeyError("balance", "portfolio_security", fmt.Sprintf("%s-%v", balance.PortfolioID, balance.SecurityID))
+			return repositories.NewBalanceExistsError(balance.PortfolioID, balance.SecurityID)
 		}
 		return repositories.NewRepositoryError("create", "balance", err)
 	}
@@ -63,13 +146,21 @@ func (r *BalanceRepository) Create(ctx context.Context, balance *repositories.Ba
 
 // CreateOrUpdate creates a new balance or updates existing one
 func (r *BalanceRepository) CreateOrUpdate(ctx context.Context, balance *repositories.Balance) error {
+	stored := r.toStorage(balance)
+
+	// The unique indexes are partial, so the conflict target must repeat the matching predicate
+	conflictTarget := "(portfolio_id, security_id) WHERE security_id IS NOT NULL"
+	if stored.SecurityID == nil {
+		conflictTarget = "(portfolio_id) WHERE security_id IS NULL"
+	}
+
 	query := `
 		INSERT INTO balances (
 			portfolio_id, security_id, quantity_long, quantity_short, version
 		) VALUES (
 			:portfolio_id, :security_id, :quantity_long, :quantity_short, :version
 		)
-		ON CONFLICT (portfolio_id, security_id)
+		ON CONFLICT ` + conflictTarget + `
 		DO UPDATE SET
 			quantity_long = EXCLUDED.quantity_long,
 			quantity_short = EXCLUDED.quantity_short,
@@ -77,7 +168,7 @@ func (r *BalanceRepository) CreateOrUpdate(ctx context.Context, balance *reposit
 			last_updated = CURRENT_TIMESTAMP
 		RETURNING id, last_updated, created_at`
 
-	rows, err := r.db.NamedQueryContext(ctx, query, balance)
+	rows, err := r.db.NamedQueryContext(ctx, query, stored)
 	if err != nil {
 		return repositories.NewRepositoryError("create_or_update", "balance", err)
 	}
@@ -114,6 +205,7 @@ func (r *BalanceRepository) GetByID(ctx context.Context, id int64) (*repositorie
 		return nil, repositories.NewRepositoryError("get", "balance", err)
 	}
 
+	r.fromStorage(&balance)
 	return &balance, nil
 }
 
@@ -127,7 +219,7 @@ func (r *BalanceRepository) GetByPortfolioAndSecurity(ctx context.Context, portf
 			SELECT id, portfolio_id, security_id, quantity_long, quantity_short,
 				   last_updated, version, created_at
 			FROM balances
-			WHERE portfolio_id = $1 AND security_id IS NULL`
+			WHERE portfolio_id = $1 AND ` + r.cashCondition()
 		args = []interface{}{portfolioID}
 	} else {
 		query = `
@@ -148,11 +240,16 @@ func (r *BalanceRepository) GetByPortfolioAndSecurity(ctx context.Context, portf
 		return nil, repositories.NewRepositoryError("get", "balance", err)
 	}
 
+	r.fromStorage(&balance)
 	return &balance, nil
 }
 
 // List retrieves balances based on filter criteria
 func (r *BalanceRepository) List(ctx context.Context, filter repositories.BalanceFilter) ([]*repositories.Balance, error) {
+	if err := r.checkListFilterSizes(filter); err != nil {
+		return nil, err
+	}
+
 	query, args, err := r.buildListQuery(filter)
 	if err != nil {
 		return nil, repositories.NewRepositoryError("build_query", "balance", err)
@@ -162,14 +259,19 @@ func (r *BalanceRepository) List(ctx context.Context, filter repositories.Balanc
 	err = r.db.SelectContext(ctx, &balances, query, args...)
 
 	if err != nil {
-		return nil, repositories.NewRepositoryError("list", "balance", err)
+		return nil, queryError(ctx, "list", "balance", err)
 	}
 
+	r.fromStorage(balances...)
 	return balances, nil
 }
 
 // Count counts balances based on filter criteria
 func (r *BalanceRepository) Count(ctx context.Context, filter repositories.BalanceFilter) (int64, error) {
+	if err := r.checkListFilterSizes(filter); err != nil {
+		return 0, err
+	}
+
 	query, args, err := r.buildCountQuery(filter)
 	if err != nil {
 		return 0, repositories.NewRepositoryError("build_query", "balance", err)
@@ -179,12 +281,20 @@ func (r *BalanceRepository) Count(ctx context.Context, filter repositories.Balan
 	err = r.db.GetContext(ctx, &count, query, args...)
 
 	if err != nil {
-		return 0, repositories.NewRepositoryError("count", "balance", err)
+		return 0, queryError(ctx, "count", "balance", err)
 	}
 
 	return count, nil
 }
 
+// lockConflict records an optimistic lock conflict of the operation and returns its error
+func (r *BalanceRepository) lockConflict(ctx context.Context, operation string, id interface{}, expectedVersion, actualVersion int) error {
+	if r.lockConflicts != nil {
+		r.lockConflicts.Add(ctx, 1, metric.WithAttributes(attribute.String("operation", operation)))
+	}
+	return repositories.NewOptimisticLockError("balance", id, expectedVersion, actualVersion)
+}
+
 // Update updates an existing balance with optimistic locking
 func (r *BalanceRepository) Update(ctx context.Context, balance *repositories.Balance) error {
 	query := `
@@ -201,10 +311,10 @@ func (r *BalanceRepository) Update(ctx context.Context, balance *repositories.Ba
 	originalVersion := balance.Version
 
 	// Create a copy of the balance for the query with the original version
-	queryBalance := *balance
+	queryBalance := r.toStorage(balance)
 	queryBalance.Version = originalVersion
 
-	rows, err := r.db.NamedQueryContext(ctx, query, &queryBalance)
+	rows, err := r.db.NamedQueryContext(ctx, query, queryBalance)
 	if err != nil {
 		if isDuplicateKeyError(err) {
 			return repositories.NewDuplicateKeyError("balance", "portfolio_security", fmt.Sprintf("%s-%v", balance.PortfolioID, balance.SecurityID))
@@ -215,7 +325,7 @@ func (r *BalanceRepository) Update(ctx context.Context, balance *repositories.Ba
 
 	if !rows.Next() {
 		// No rows affected means version mismatch (optimistic locking failure)
-		return repositories.NewOptimisticLockError("balance", balance.ID, originalVersion, originalVersion)
+		return r.lockConflict(ctx, "update", balance.ID, originalVersion, originalVersion)
 	}
 
 	if err := rows.Scan(&balance.Version, &balance.LastUpdated); err != nil {
@@ -250,7 +360,7 @@ func (r *BalanceRepository) UpdateQuantities(ctx context.Context, id int64, quan
 	}
 
 	if rowsAffected == 0 {
-		return repositories.NewOptimisticLockError("balance", id, version, version+1)
+		return r.lockConflict(ctx, "update_quantities", id, version, version+1)
 	}
 
 	r.logger.Info("Balance quantities updated",
@@ -286,7 +396,7 @@ func (r *BalanceRepository) UpdateMultipleBalances(ctx context.Context, updates
 			}
 
 			if rowsAffected == 0 {
-				return repositories.NewOptimisticLockError("balance", update.ID, update.Version, update.Version+1)
+				return r.lockConflict(ctx, "update_batch", update.ID, update.Version, update.Version+1)
 			}
 		}
 
@@ -301,7 +411,7 @@ func (r *BalanceRepository) UpdateMultipleBalances(ctx context.Context, updates
 func (r *BalanceRepository) GetBalancesByPortfolio(ctx context.Context, portfolioID string) ([]*repositories.Balance, error) {
 	filter := repositories.BalanceFilter{
 		PortfolioID: &portfolioID,
-		SortBy:      []string{"security_id NULLS FIRST", "created_at"},
+		SortBy:      []string{r.cashFirstOrder(), "created_at"},
 	}
 
 	return r.List(ctx, filter)
@@ -323,6 +433,70 @@ func (r *BalanceRepository) GetZeroBalances(ctx context.Context, limit int) ([]*
 	return r.List(ctx, filter)
 }
 
+// DeleteZeroBalancesBefore deletes up to limit security balances with zero quantities that were
+// last updated before cutoff, oldest first, and returns how many it deleted. Cash balances are
+// kept, and so are balances the balance_adjustments ledger references, whose rows would
+// otherwise violate its foreign key. The conditions are repeated on the deleted rows so that a
+// balance updated or adjusted concurrently is left alone.
+func (r *BalanceRepository) DeleteZeroBalancesBefore(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	if limit <= 0 {
+		return 0, fmt.Errorf("limit must be positive: %d", limit)
+	}
+
+	conditions := "b.quantity_long = 0 AND b.quantity_short = 0 AND b.last_updated < $1 AND " + r.securityCondition() + `
+			AND NOT EXISTS (SELECT 1 FROM balance_adjustments a WHERE a.balance_id = b.id)`
+	query := `
+		DELETE FROM balances b
+		WHERE b.id IN (
+			SELECT b.id FROM balances b
+			WHERE ` + conditions + `
+			ORDER BY b.last_updated, b.id
+			LIMIT $2
+		) AND ` + conditions
+
+	result, err := r.db.ExecContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return 0, repositories.NewRepositoryError("delete_zero_balances", "balance", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, repositories.NewRepositoryError("delete_zero_balances", "balance", err)
+	}
+	return deleted, nil
+}
+
+// positionRankingExpressions maps each position ranking to its ORDER BY expression; each
+// has a matching index so the top-N query reads only the first rows of the index
+var positionRankingExpressions = map[repositories.PositionRanking]string{
+	repositories.PositionRankingLong:     "quantity_long",
+	repositories.PositionRankingShort:    "quantity_short",
+	repositories.PositionRankingAbsolute: "ABS(quantity_long - quantity_short)",
+}
+
+// GetTopPositions retrieves the largest security positions across all portfolios
+func (r *BalanceRepository) GetTopPositions(ctx context.Context, by repositories.PositionRanking, limit int) ([]*repositories.Balance, error) {
+	expression, ok := positionRankingExpressions[by]
+	if !ok {
+		return nil, repositories.NewRepositoryError("top_positions", "balance", fmt.Errorf("invalid position ranking: %q", by))
+	}
+
+	query := `
+		SELECT id, portfolio_id, security_id, quantity_long, quantity_short,
+			   last_updated, version, created_at
+		FROM balances
+		WHERE ` + r.securityCondition() + `
+		ORDER BY ` + expression + ` DESC, id
+		LIMIT $1`
+
+	var balances []*repositories.Balance
+	if err := r.db.SelectContext(ctx, &balances, query, limit); err != nil {
+		return nil, repositories.NewRepositoryError("top_positions", "balance", err)
+	}
+
+	r.fromStorage(balances...)
+	return balances, nil
+}
+
 // GetBalanceStats retrieves balance statistics
 func (r *BalanceRepository) GetBalanceStats(ctx context.Context) (*repositories.BalanceStats, error) {
 	stats := &repositories.BalanceStats{}
@@ -338,12 +512,12 @@ func (r *BalanceRepository) GetBalanceStats(ctx context.Context) (*repositories.
 	}
 
 	// Get total securities
-	if err := r.db.GetContext(ctx, &stats.TotalSecurities, "SELECT COUNT(DISTINCT security_id) FROM balances WHERE security_id IS NOT NULL"); err != nil {
+	if err := r.db.GetContext(ctx, &stats.TotalSecurities, "SELECT COUNT(DISTINCT security_id) FROM balances WHERE "+r.securityCondition()); err != nil {
 		return nil, repositories.NewRepositoryError("get_stats", "balance", err)
 	}
 
 	// Get cash balances count
-	if err := r.db.GetContext(ctx, &stats.CashBalances, "SELECT COUNT(*) FROM balances WHERE security_id IS NULL"); err != nil {
+	if err := r.db.GetContext(ctx, &stats.CashBalances, "SELECT COUNT(*) FROM balances WHERE "+r.cashCondition()); err != nil {
 		return nil, repositories.NewRepositoryError("get_stats", "balance", err)
 	}
 
@@ -365,49 +539,83 @@ func (r *BalanceRepository) GetBalanceStats(ctx context.Context) (*repositories.
 	return stats, nil
 }
 
-// GetPortfolioSummary retrieves a summary of a portfolio's balances
+// GetPortfolioSummary retrieves a summary of a portfolio's balances with the grouped query of
+// GetPortfolioSummaries, in one round trip. A portfolio without balances gets a zeroed summary.
 func (r *BalanceRepository) GetPortfolioSummary(ctx context.Context, portfolioID string) (*repositories.PortfolioSummary, error) {
-	summary := &repositories.PortfolioSummary{
-		PortfolioID: portfolioID,
+	summaries, err := r.GetPortfolioSummaries(ctx, []string{portfolioID}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(summaries) == 0 {
+		return &repositories.PortfolioSummary{
+			PortfolioID: portfolioID,
+			CashBalance: decimal.Zero,
+		}, nil
 	}
+	return summaries[0], nil
+}
+
+// GetPortfolioSummaries aggregates the summaries of several portfolios with a single grouped query
+func (r *BalanceRepository) GetPortfolioSummaries(ctx context.Context, portfolioIDs []string, limit, offset int) ([]*repositories.PortfolioSummary, error) {
+	query := `
+		SELECT portfolio_id,
+			   COUNT(*) AS total_positions,
+			   COALESCE(SUM(quantity_long) FILTER (WHERE ` + r.cashCondition() + `), 0) AS cash_balance,
+			   COUNT(*) FILTER (WHERE quantity_long > 0) AS long_positions,
+			   COUNT(*) FILTER (WHERE quantity_short > 0) AS short_positions,
+			   MAX(last_updated) AS last_updated
+		FROM balances`
 
-	// Get total positions count
-	query := "SELECT COUNT(*) FROM balances WHERE portfolio_id = $1"
-	if err := r.db.GetContext(ctx, &summary.TotalPositions, query, portfolioID); err != nil {
-		return nil, repositories.NewRepositoryError("get_summary", "balance", err)
+	var args []interface{}
+	if len(portfolioIDs) > 0 {
+		query += " WHERE portfolio_id = ANY($1)"
+		args = append(args, pq.Array(portfolioIDs))
 	}
+	query += " GROUP BY portfolio_id ORDER BY portfolio_id"
 
-	// Get cash balance
-	cashQuery := "SELECT COALESCE(quantity_long, 0) FROM balances WHERE portfolio_id = $1 AND security_id IS NULL"
-	if err := r.db.GetContext(ctx, &summary.CashBalance, cashQuery, portfolioID); err != nil {
-		if err != sql.ErrNoRows {
-			return nil, repositories.NewRepositoryError("get_summary", "balance", err)
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+		if offset > 0 {
+			query += fmt.Sprintf(" OFFSET %d", offset)
 		}
-		summary.CashBalance = decimal.Zero
 	}
 
-	// Get long positions count
-	longQuery := "SELECT COUNT(*) FROM balances WHERE portfolio_id = $1 AND quantity_long > 0"
-	if err := r.db.GetContext(ctx, &summary.LongPositions, longQuery, portfolioID); err != nil {
-		return nil, repositories.NewRepositoryError("get_summary", "balance", err)
+	var rows []struct {
+		PortfolioID    string          `db:"portfolio_id"`
+		TotalPositions int             `db:"total_positions"`
+		CashBalance    decimal.Decimal `db:"cash_balance"`
+		LongPositions  int             `db:"long_positions"`
+		ShortPositions int             `db:"short_positions"`
+		LastUpdated    sql.NullTime    `db:"last_updated"`
 	}
-
-	// Get short positions count
-	shortQuery := "SELECT COUNT(*) FROM balances WHERE portfolio_id = $1 AND quantity_short > 0"
-	if err := r.db.GetContext(ctx, &summary.ShortPositions, shortQuery, portfolioID); err != nil {
-		return nil, repositories.NewRepositoryError("get_summary", "balance", err)
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, repositories.NewRepositoryError("get_summaries", "balance", err)
 	}
 
-	// Get last updated time
-	lastUpdatedQuery := "SELECT MAX(last_updated) FROM balances WHERE portfolio_id = $1"
-	if err := r.db.GetContext(ctx, &summary.LastUpdated, lastUpdatedQuery, portfolioID); err != nil {
-		if err != sql.ErrNoRows {
-			return nil, repositories.NewRepositoryError("get_summary", "balance", err)
+	summaries := make([]*repositories.PortfolioSummary, len(rows))
+	for i, row := range rows {
+		summaries[i] = &repositories.PortfolioSummary{
+			PortfolioID:    row.PortfolioID,
+			TotalPositions: row.TotalPositions,
+			CashBalance:    row.CashBalance,
+			LongPositions:  row.LongPositions,
+			ShortPositions: row.ShortPositions,
+			LastUpdated:    row.LastUpdated.Time,
 		}
-		summary.LastUpdated = time.Time{}
 	}
 
-	return summary, nil
+	return summaries, nil
+}
+
+// checkListFilterSizes rejects a filter whose ID lists exceed the configured maximum
+func (r *BalanceRepository) checkListFilterSizes(filter repositories.BalanceFilter) error {
+	if err := checkListFilterSize("balance", "ids", len(filter.IDs), r.maxListFilterSize); err != nil {
+		return err
+	}
+	if err := checkListFilterSize("balance", "portfolio_ids", len(filter.PortfolioIDs), r.maxListFilterSize); err != nil {
+		return err
+	}
+	return checkListFilterSize("balance", "security_ids", len(filter.SecurityIDs), r.maxListFilterSize)
 }
 
 // buildListQuery builds the SELECT query for listing balances
@@ -475,21 +683,12 @@ func (r *BalanceRepository) buildWhereClause(filter repositories.BalanceFilter)
 		argIndex++
 	}
 
-	// Cash vs security filters
-	if filter.CashOnly {
-		conditions = append(conditions, "security_id IS NULL")
-	}
-
-	if filter.SecuritiesOnly {
-		conditions = append(conditions, "security_id IS NOT NULL")
-	}
-
-	if filter.IncludeCash != nil && !*filter.IncludeCash {
-		conditions = append(conditions, "security_id IS NOT NULL")
-	}
-
-	if filter.OnlyCash != nil && *filter.OnlyCash {
-		conditions = append(conditions, "security_id IS NULL")
+	// Cash vs security filter
+	switch filter.Scope {
+	case repositories.BalanceScopeCashOnly:
+		conditions = append(conditions, r.cashCondition())
+	case repositories.BalanceScopeSecuritiesOnly:
+		conditions = append(conditions, r.securityCondition())
 	}
 
 	// Date filters
@@ -530,6 +729,19 @@ func (r *BalanceRepository) buildWhereClause(filter repositories.BalanceFilter)
 		argIndex++
 	}
 
+	// Magnitude filters
+	if filter.QuantityLongAbsMin != nil {
+		conditions = append(conditions, fmt.Sprintf("ABS(quantity_long) >= $%d", argIndex))
+		args = append(args, *filter.QuantityLongAbsMin)
+		argIndex++
+	}
+
+	if filter.QuantityShortAbsMin != nil {
+		conditions = append(conditions, fmt.Sprintf("ABS(quantity_short) >= $%d", argIndex))
+		args = append(args, *filter.QuantityShortAbsMin)
+		argIndex++
+	}
+
 	// Zero balance filters
 	if filter.ExcludeZeroBalances {
 		conditions = append(conditions, "(quantity_long != 0 OR quantity_short != 0)")
@@ -539,6 +751,10 @@ func (r *BalanceRepository) buildWhereClause(filter repositories.BalanceFilter)
 		conditions = append(conditions, "quantity_long = 0 AND quantity_short = 0")
 	}
 
+	if filter.ExcludeAdjusted {
+		conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM balance_adjustments a WHERE a.balance_id = balances.id)")
+	}
+
 	// Collection filters (IN clauses)
 	if len(filter.IDs) > 0 {
 		conditions = append(conditions, fmt.Sprintf("id = ANY($%d)", argIndex))
@@ -568,5 +784,5 @@ func (r *BalanceRepository) buildOrderBy(filter repositories.BalanceFilter) stri
 	}
 
 	// Default sorting: cash first, then by security ID, then by creation date
-	return "security_id NULLS FIRST, created_at DESC"
+	return r.cashFirstOrder() + ", created_at DESC"
 }
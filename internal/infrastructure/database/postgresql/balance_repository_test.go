@@ -0,0 +1,223 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// staleVersionDriver is a database driver on which every conditional write matches no row, as
+// if another writer had already moved the version on
+type staleVersionDriver struct{}
+
+func (d staleVersionDriver) Open(string) (driver.Conn, error)             { return d, nil }
+func (d staleVersionDriver) Connect(context.Context) (driver.Conn, error) { return d, nil }
+func (d staleVersionDriver) Driver() driver.Driver                        { return d }
+func (d staleVersionDriver) Prepare(string) (driver.Stmt, error)          { return d, nil }
+func (d staleVersionDriver) Begin() (driver.Tx, error)                    { return d, nil }
+func (d staleVersionDriver) Close() error                                 { return nil }
+func (d staleVersionDriver) Commit() error                                { return nil }
+func (d staleVersionDriver) Rollback() error                              { return nil }
+func (d staleVersionDriver) NumInput() int                                { return -1 }
+func (d staleVersionDriver) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+func (d staleVersionDriver) Query([]driver.Value) (driver.Rows, error) {
+	return staleVersionRows{}, nil
+}
+
+// staleVersionRows is the empty result of an UPDATE ... RETURNING that matched no row
+type staleVersionRows struct{}
+
+func (staleVersionRows) Columns() []string         { return []string{"version", "last_updated"} }
+func (staleVersionRows) Close() error              { return nil }
+func (staleVersionRows) Next([]driver.Value) error { return io.EOF }
+
+// lockConflicts returns the number of recorded optimistic lock conflicts for an operation
+func lockConflicts(t *testing.T, reader *sdkmetric.ManualReader, operation string) int64 {
+	t.Helper()
+
+	var collected metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &collected))
+	for _, scope := range collected.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name != "balance_optimistic_lock_conflicts_total" {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			require.True(t, ok)
+			for _, point := range sum.DataPoints {
+				op, _ := point.Attributes.Value(attribute.Key("operation"))
+				if op.AsString() == operation {
+					return point.Value
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestBalanceRepository_CountsOptimisticLockConflicts(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	db := database.NewFromSQLX(sqlx.NewDb(sql.OpenDB(staleVersionDriver{}), "postgres"), logger.NewNoop())
+	repo := NewBalanceRepository(db, logger.NewNoop()).
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	ctx := context.Background()
+
+	err := repo.Update(ctx, &repositories.Balance{ID: 1, PortfolioID: "PORTFOLIO000000000000001", Version: 3})
+	assert.True(t, repositories.IsOptimisticLockError(err))
+
+	err = repo.UpdateQuantities(ctx, 1, decimal.NewFromInt(10), decimal.Zero, 3)
+	assert.True(t, repositories.IsOptimisticLockError(err))
+	err = repo.UpdateQuantities(ctx, 2, decimal.NewFromInt(10), decimal.Zero, 5)
+	assert.True(t, repositories.IsOptimisticLockError(err))
+
+	err = repo.UpdateMultipleBalances(ctx, []repositories.BalanceUpdate{{ID: 1, QuantityLong: decimal.NewFromInt(10), Version: 3}})
+	assert.True(t, repositories.IsOptimisticLockError(err))
+
+	assert.Equal(t, int64(1), lockConflicts(t, reader, "update"))
+	assert.Equal(t, int64(2), lockConflicts(t, reader, "update_quantities"))
+	assert.Equal(t, int64(1), lockConflicts(t, reader, "update_batch"))
+}
+
+func TestBalanceRepository_BuildWhereClause_Scope(t *testing.T) {
+	repo := &BalanceRepository{}
+
+	tests := []struct {
+		name     string
+		scope    repositories.BalanceScope
+		expected string
+	}{
+		{name: "Unset scope", scope: "", expected: ""},
+		{name: "All balances", scope: repositories.BalanceScopeAll, expected: ""},
+		{name: "Cash only", scope: repositories.BalanceScopeCashOnly, expected: "security_id IS NULL"},
+		{name: "Securities only", scope: repositories.BalanceScopeSecuritiesOnly, expected: "security_id IS NOT NULL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := repo.buildWhereClause(repositories.BalanceFilter{Scope: tt.scope})
+			assert.Equal(t, tt.expected, where)
+			assert.Empty(t, args)
+		})
+	}
+}
+
+func TestBalanceRepository_BuildWhereClause_AbsoluteQuantity(t *testing.T) {
+	repo := &BalanceRepository{}
+	minLong := decimal.NewFromInt(10)
+	minShort := decimal.RequireFromString("0.5")
+
+	where, args := repo.buildWhereClause(repositories.BalanceFilter{QuantityLongAbsMin: &minLong})
+	assert.Equal(t, "ABS(quantity_long) >= $1", where)
+	assert.Equal(t, []interface{}{minLong}, args)
+
+	portfolioID := "PORTFOLIO000000000000001"
+	where, args = repo.buildWhereClause(repositories.BalanceFilter{
+		PortfolioID:         &portfolioID,
+		QuantityLongAbsMin:  &minLong,
+		QuantityShortAbsMin: &minShort,
+	})
+	assert.Equal(t, "portfolio_id = $1 AND ABS(quantity_long) >= $2 AND ABS(quantity_short) >= $3", where)
+	assert.Equal(t, []interface{}{portfolioID, minLong, minShort}, args)
+}
+
+func TestBalanceRepository_BuildWhereClause_ExcludeAdjusted(t *testing.T) {
+	repo := &BalanceRepository{}
+
+	where, args := repo.buildWhereClause(repositories.BalanceFilter{OnlyZeroBalances: true, ExcludeAdjusted: true})
+	assert.Equal(t, "quantity_long = 0 AND quantity_short = 0 AND "+
+		"NOT EXISTS (SELECT 1 FROM balance_adjustments a WHERE a.balance_id = balances.id)", where)
+	assert.Empty(t, args)
+}
+
+func TestBalanceRepository_CashRepresentation(t *testing.T) {
+	const sentinel = "CASH00000000000000000000"
+	securityID := "SECURITY1234567890123456"
+
+	sentinelCash, err := repositories.NewCashSecurityID(sentinel)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		cash           repositories.CashSecurityID
+		storedCash     *string
+		cashOnly       string
+		securitiesOnly string
+		defaultOrder   string
+	}{
+		{
+			name:           "NULL",
+			cash:           repositories.CashSecurityID{},
+			storedCash:     nil,
+			cashOnly:       "security_id IS NULL",
+			securitiesOnly: "security_id IS NOT NULL",
+			defaultOrder:   "security_id NULLS FIRST, created_at DESC",
+		},
+		{
+			name:           "Sentinel",
+			cash:           sentinelCash,
+			storedCash:     stringPtr(sentinel),
+			cashOnly:       "security_id = '" + sentinel + "'",
+			securitiesOnly: "security_id <> '" + sentinel + "'",
+			defaultOrder:   "(security_id = '" + sentinel + "') DESC, security_id, created_at DESC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := (&BalanceRepository{}).WithCashSecurityID(tt.cash)
+
+			where, _ := repo.buildWhereClause(repositories.BalanceFilter{Scope: repositories.BalanceScopeCashOnly})
+			assert.Equal(t, tt.cashOnly, where)
+
+			where, _ = repo.buildWhereClause(repositories.BalanceFilter{Scope: repositories.BalanceScopeSecuritiesOnly})
+			assert.Equal(t, tt.securitiesOnly, where)
+
+			assert.Equal(t, tt.defaultOrder, repo.buildOrderBy(repositories.BalanceFilter{}))
+
+			// Cash round-trips through storage as nil
+			cash := &repositories.Balance{PortfolioID: "PORTFOLIO123456789012345"}
+			stored := repo.toStorage(cash)
+			assert.Equal(t, tt.storedCash, stored.SecurityID)
+			assert.Nil(t, cash.SecurityID)
+			repo.fromStorage(stored)
+			assert.Nil(t, stored.SecurityID)
+
+			// Security balances are stored unchanged
+			security := &repositories.Balance{PortfolioID: "PORTFOLIO123456789012345", SecurityID: &securityID}
+			stored = repo.toStorage(security)
+			require.NotNil(t, stored.SecurityID)
+			assert.Equal(t, securityID, *stored.SecurityID)
+			repo.fromStorage(stored)
+			require.NotNil(t, stored.SecurityID)
+			assert.Equal(t, securityID, *stored.SecurityID)
+		})
+	}
+}
+
+func TestNewCashSecurityID(t *testing.T) {
+	cash, err := repositories.NewCashSecurityID("")
+	require.NoError(t, err)
+	assert.False(t, cash.UsesSentinel())
+
+	_, err = repositories.NewCashSecurityID("CASH")
+	assert.Error(t, err)
+
+	_, err = repositories.NewCashSecurityID("CASH0000000000000000000'")
+	assert.Error(t, err)
+}
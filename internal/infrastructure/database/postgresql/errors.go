@@ -0,0 +1,59 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/lib/pq"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+)
+
+// queryError wraps a failed query. A query that failed because ctx was canceled or timed out,
+// e.g. when the client disconnected, is reported as a canceled query rather than a database
+// failure, whichever error the driver returned for it.
+func queryError(ctx context.Context, operation, entity string, err error) *repositories.RepositoryError {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return repositories.NewQueryCanceledError(operation, entity, ctxErr, err)
+	}
+	return repositoryError(operation, entity, err)
+}
+
+// repositoryError wraps a failed statement. A statement that failed because the database could
+// not be reached is reported as a connection failure, so callers can tell an outage from an
+// error of the statement itself.
+func repositoryError(operation, entity string, err error) *repositories.RepositoryError {
+	if isConnectionFailure(err) {
+		return repositories.NewConnectionError(operation, err)
+	}
+	return repositories.NewRepositoryError(operation, entity, err)
+}
+
+// isConnectionFailure reports whether err shows the database could not be reached: a refused,
+// dropped or timed out network connection, a connection the driver gave up on, a PostgreSQL
+// connection exception (class 08) or a server shutting down or not yet accepting connections
+// (57P01-57P03)
+func isConnectionFailure(err error) bool {
+	if repositories.IsConnectionError(err) {
+		return false // already classified
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "57P01", "57P02", "57P03":
+			return true
+		}
+		return pqErr.Code.Class() == "08"
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
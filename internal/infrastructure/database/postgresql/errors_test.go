@@ -0,0 +1,130 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+func TestQueryError(t *testing.T) {
+	driverErr := errors.New("pq: canceling statement due to user request")
+
+	t.Run("Live context is a repository failure", func(t *testing.T) {
+		err := queryError(context.Background(), "list", "transaction", driverErr)
+		assert.False(t, repositories.IsQueryCanceledError(err))
+		assert.ErrorIs(t, err, driverErr)
+	})
+
+	t.Run("Canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := queryError(ctx, "list", "transaction", driverErr)
+		assert.True(t, repositories.IsQueryCanceledError(err))
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.False(t, repositories.IsTransientError(err))
+	})
+
+	t.Run("Expired context", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		err := queryError(ctx, "count", "balance", driverErr)
+		assert.True(t, repositories.IsQueryCanceledError(err))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestRepositories_ListCountCanceledMidQuery(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires a PostgreSQL container")
+	}
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	testDB, err := database.NewTestDatabase(ctx)
+	require.NoError(t, err)
+	defer testDB.Close(ctx)
+
+	transactionRepo := NewTransactionRepository(testDB.DB, logger.NewNoop())
+	balanceRepo := NewBalanceRepository(testDB.DB, logger.NewNoop())
+
+	// Hold exclusive locks so the queries block until their contexts end
+	lock, err := testDB.DB.BeginTxx(ctx, nil)
+	require.NoError(t, err)
+	defer lock.Rollback()
+	_, err = lock.ExecContext(ctx, "LOCK TABLE transactions, balances IN ACCESS EXCLUSIVE MODE")
+	require.NoError(t, err)
+
+	t.Run("Client disconnects during list", func(t *testing.T) {
+		queryCtx, cancel := context.WithCancel(ctx)
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		_, err := transactionRepo.List(queryCtx, repositories.TransactionFilter{})
+		require.Error(t, err)
+		assert.True(t, repositories.IsQueryCanceledError(err))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Request times out during count", func(t *testing.T) {
+		queryCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		_, err := balanceRepo.Count(queryCtx, repositories.BalanceFilter{})
+		require.Error(t, err)
+		assert.True(t, repositories.IsQueryCanceledError(err))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestRepositoryError_ConnectionFailures(t *testing.T) {
+	connectionFailures := map[string]error{
+		"connection refused": &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED},
+		"bad connection":     fmt.Errorf("write: %w", driver.ErrBadConn),
+		"connection failure": &pq.Error{Code: "08006", Message: "connection failure"},
+		"admin shutdown":     &pq.Error{Code: "57P01", Message: "terminating connection due to administrator command"},
+		"starting up":        &pq.Error{Code: "57P03", Message: "the database system is starting up"},
+	}
+	for name, driverErr := range connectionFailures {
+		t.Run(name, func(t *testing.T) {
+			err := repositoryError("create", "transaction", driverErr)
+			assert.True(t, repositories.IsConnectionError(err))
+			assert.True(t, repositories.IsTransientError(err))
+
+			err = queryError(context.Background(), "list", "transaction", driverErr)
+			assert.True(t, repositories.IsConnectionError(err))
+		})
+	}
+
+	statementFailures := map[string]error{
+		"check violation":   &pq.Error{Code: "23514", Message: "new row violates check constraint"},
+		"query canceled":    &pq.Error{Code: "57014", Message: "canceling statement due to user request"},
+		"plain driver text": errors.New("pq: invalid input syntax"),
+	}
+	for name, driverErr := range statementFailures {
+		t.Run(name, func(t *testing.T) {
+			err := repositoryError("create", "transaction", driverErr)
+			assert.False(t, repositories.IsConnectionError(err))
+			assert.ErrorIs(t, err, driverErr)
+		})
+	}
+
+	t.Run("Already classified", func(t *testing.T) {
+		err := repositories.NewConnectionError("create", errors.New("refused"))
+		assert.False(t, isConnectionFailure(err))
+	})
+}
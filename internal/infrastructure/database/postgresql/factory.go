@@ -30,6 +30,16 @@ func (f *RepositoryFactory) BalanceRepository() repositories.BalanceRepository {
 	return NewBalanceRepository(f.db, f.logger)
 }
 
+// BalanceAdjustmentRepository creates a new PostgreSQL balance adjustment repository
+func (f *RepositoryFactory) BalanceAdjustmentRepository() repositories.BalanceAdjustmentRepository {
+	return NewBalanceAdjustmentRepository(f.db, NewBalanceRepository(f.db, f.logger), f.logger)
+}
+
+// UnitOfWork creates a unit of work spanning the repositories of this factory
+func (f *RepositoryFactory) UnitOfWork() repositories.UnitOfWork {
+	return NewUnitOfWork(f.db)
+}
+
 // CreateAllRepositories creates all repository instances
 func (f *RepositoryFactory) CreateAllRepositories() (repositories.TransactionRepository, repositories.BalanceRepository) {
 	return f.TransactionRepository(), f.BalanceRepository()
@@ -37,8 +47,10 @@ func (f *RepositoryFactory) CreateAllRepositories() (repositories.TransactionRep
 
 // RepositoryContainer holds all repository instances
 type RepositoryContainer struct {
-	TransactionRepo repositories.TransactionRepository
-	BalanceRepo     repositories.BalanceRepository
+	TransactionRepo       repositories.TransactionRepository
+	BalanceRepo           repositories.BalanceRepository
+	BalanceAdjustmentRepo repositories.BalanceAdjustmentRepository
+	UnitOfWork            repositories.UnitOfWork
 }
 
 // NewRepositoryContainer creates a new repository container with all repositories
@@ -46,7 +58,9 @@ func NewRepositoryContainer(db *database.DB, logger logger.Logger) *RepositoryCo
 	factory := NewRepositoryFactory(db, logger)
 
 	return &RepositoryContainer{
-		TransactionRepo: factory.TransactionRepository(),
-		BalanceRepo:     factory.BalanceRepository(),
+		TransactionRepo:       factory.TransactionRepository(),
+		BalanceRepo:           factory.BalanceRepository(),
+		BalanceAdjustmentRepo: factory.BalanceAdjustmentRepository(),
+		UnitOfWork:            factory.UnitOfWork(),
 	}
 }
@@ -0,0 +1,14 @@
+package postgresql
+
+import (
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+)
+
+// checkListFilterSize rejects an ID list filter with more than max values; a max of zero or
+// less disables the check
+func checkListFilterSize(entity, field string, size, max int) error {
+	if max > 0 && size > max {
+		return repositories.NewListFilterTooLargeError(entity, field, size, max)
+	}
+	return nil
+}
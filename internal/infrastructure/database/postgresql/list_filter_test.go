@@ -0,0 +1,57 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+func portfolioIDs(n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("PORTFOLIO%015d", i)
+	}
+	return ids
+}
+
+func TestListFilterSizeCap(t *testing.T) {
+	transactionRepo := NewTransactionRepository(nil, logger.NewNoop()).WithMaxListFilterSize(3)
+	balanceRepo := NewBalanceRepository(nil, logger.NewNoop()).WithMaxListFilterSize(3)
+
+	t.Run("At the cap", func(t *testing.T) {
+		assert.NoError(t, transactionRepo.checkListFilterSizes(repositories.TransactionFilter{
+			IDs: []int64{1, 2, 3}, PortfolioIDs: portfolioIDs(3), SecurityIDs: portfolioIDs(3),
+		}))
+		assert.NoError(t, balanceRepo.checkListFilterSizes(repositories.BalanceFilter{
+			IDs: []int64{1, 2, 3}, PortfolioIDs: portfolioIDs(3), SecurityIDs: portfolioIDs(3),
+		}))
+	})
+
+	t.Run("One over the cap", func(t *testing.T) {
+		_, err := transactionRepo.List(context.Background(), repositories.TransactionFilter{PortfolioIDs: portfolioIDs(4)})
+		require.Error(t, err)
+		assert.True(t, repositories.IsListFilterTooLargeError(err))
+		assert.ErrorIs(t, err, repositories.ErrInvalidFilter)
+		assert.Contains(t, err.Error(), "portfolio_ids has 4 values, at most 3 are allowed")
+
+		_, err = transactionRepo.Count(context.Background(), repositories.TransactionFilter{IDs: []int64{1, 2, 3, 4}})
+		assert.True(t, repositories.IsListFilterTooLargeError(err))
+
+		_, err = balanceRepo.List(context.Background(), repositories.BalanceFilter{SecurityIDs: portfolioIDs(4)})
+		assert.True(t, repositories.IsListFilterTooLargeError(err))
+
+		_, err = balanceRepo.Count(context.Background(), repositories.BalanceFilter{PortfolioIDs: portfolioIDs(4)})
+		assert.True(t, repositories.IsListFilterTooLargeError(err))
+	})
+
+	t.Run("Zero disables the cap", func(t *testing.T) {
+		unlimited := NewTransactionRepository(nil, logger.NewNoop())
+		assert.NoError(t, unlimited.checkListFilterSizes(repositories.TransactionFilter{PortfolioIDs: portfolioIDs(5000)}))
+	})
+}
@@ -5,9 +5,11 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
 
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database"
@@ -18,6 +20,8 @@ import (
 type TransactionRepository struct {
 	db     *database.DB
 	logger logger.Logger
+
+	maxListFilterSize int
 }
 
 // NewTransactionRepository creates a new PostgreSQL transaction repository
@@ -28,23 +32,92 @@ func NewTransactionRepository(db *database.DB, logger logger.Logger) *Transactio
 	}
 }
 
+// WithMaxListFilterSize caps the values of the IDs, PortfolioIDs and SecurityIDs filters of List
+// and Count (unlimited by default)
+func (r *TransactionRepository) WithMaxListFilterSize(max int) *TransactionRepository {
+	r.maxListFilterSize = max
+	return r
+}
+
 // Create creates a new transaction
 func (r *TransactionRepository) Create(ctx context.Context, transaction *repositories.Transaction) error {
+	if err := r.insert(ctx, r.db, transaction); err != nil {
+		return err
+	}
+
+	r.logger.Info("Transaction created",
+		logger.Int64("id", transaction.ID),
+		logger.String("sourceId", transaction.SourceID),
+		logger.String("portfolioId", transaction.PortfolioID))
+
+	return nil
+}
+
+// CreateWithinDailyLimit creates a transaction unless its portfolio already has limit
+// transactions for its transaction date. A transaction-scoped advisory lock on the portfolio and
+// date serializes the count and insert of concurrent creates for the same day.
+func (r *TransactionRepository) CreateWithinDailyLimit(ctx context.Context, transaction *repositories.Transaction, limit int) error {
+	transactionDate := transaction.TransactionDate.Format("20060102")
+
+	err := r.db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			`SELECT pg_advisory_xact_lock(hashtext($1), $2)`,
+			transaction.PortfolioID, dailyLimitLockKey(transaction.TransactionDate)); err != nil {
+			return queryError(ctx, "lock_daily_limit", "transaction", err)
+		}
+
+		var booked int64
+		if err := tx.GetContext(ctx, &booked,
+			`SELECT COUNT(*) FROM transactions WHERE portfolio_id = $1 AND transaction_date = $2`,
+			transaction.PortfolioID, transaction.TransactionDate); err != nil {
+			return queryError(ctx, "count_daily_limit", "transaction", err)
+		}
+		if booked >= int64(limit) {
+			return repositories.NewDailyLimitReachedError(transaction.PortfolioID, transactionDate, limit)
+		}
+
+		return r.insert(ctx, tx, transaction)
+	})
+	if err != nil {
+		if isConnectionFailure(err) {
+			// Beginning or committing the transaction failed
+			return repositories.NewConnectionError("create", err)
+		}
+		return err
+	}
+
+	r.logger.Info("Transaction created",
+		logger.Int64("id", transaction.ID),
+		logger.String("sourceId", transaction.SourceID),
+		logger.String("portfolioId", transaction.PortfolioID))
+
+	return nil
+}
+
+// dailyLimitLockKey is the second advisory lock key of a portfolio's daily limit: the
+// transaction date as YYYYMMDD
+func dailyLimitLockKey(transactionDate time.Time) int32 {
+	year, month, day := transactionDate.Date()
+	return int32(year*10000 + int(month)*100 + day)
+}
+
+// insert stores a transaction with ext and sets its generated ID and timestamps
+func (r *TransactionRepository) insert(ctx context.Context, ext sqlx.ExtContext, transaction *repositories.Transaction) error {
 	query := `
 		INSERT INTO transactions (
 			portfolio_id, security_id, source_id, status, transaction_type,
-			quantity, price, transaction_date, reprocessing_attempts, version
+			quantity, price, transaction_date, settlement_date, reprocessing_attempts, version
 		) VALUES (
 			:portfolio_id, :security_id, :source_id, :status, :transaction_type,
-			:quantity, :price, :transaction_date, :reprocessing_attempts, :version
+			:quantity, :price, :transaction_date, :settlement_date, :reprocessing_attempts, :version
 		) RETURNING id, created_at, updated_at`
 
-	rows, err := r.db.NamedQueryContext(ctx, query, transaction)
+	rows, err := sqlx.NamedQueryContext(ctx, ext, query, transaction)
 	if err != nil {
 		if isDuplicateKeyError(err) {
 			return repositories.NewDuplicateKeyError("transaction", "source_id", transaction.SourceID)
 		}
-		return repositories.NewRepositoryError("create", "transaction", err)
+		return repositoryError("create", "transaction", err)
 	}
 	defer rows.Close()
 
@@ -53,12 +126,9 @@ func (r *TransactionRepository) Create(ctx context.Context, transaction *reposit
 			return repositories.NewRepositoryError("scan", "transaction", err)
 		}
 	}
-
-	r.logger.Info("Transaction created",
-		logger.Int64("id", transaction.ID),
-		logger.String("sourceId", transaction.SourceID),
-		logger.String("portfolioId", transaction.PortfolioID))
-
+	if err := rows.Err(); err != nil {
+		return repositoryError("create", "transaction", err)
+	}
 	return nil
 }
 
@@ -72,24 +142,24 @@ func (r *TransactionRepository) CreateBatch(ctx context.Context, transactions []
 		query := `
 			INSERT INTO transactions (
 				portfolio_id, security_id, source_id, status, transaction_type,
-				quantity, price, transaction_date, reprocessing_attempts, version
+				quantity, price, transaction_date, settlement_date, reprocessing_attempts, version
 			) VALUES (
-				$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+				$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
 			) RETURNING id, created_at, updated_at`
 
 		for _, transaction := range transactions {
 			err := tx.QueryRowxContext(ctx, query,
 				transaction.PortfolioID, transaction.SecurityID, transaction.SourceID,
 				transaction.Status, transaction.TransactionType, transaction.Quantity,
-				transaction.Price, transaction.TransactionDate, transaction.ReprocessingAttempts,
-				transaction.Version,
+				transaction.Price, transaction.TransactionDate, transaction.SettlementDate,
+				transaction.ReprocessingAttempts, transaction.Version,
 			).Scan(&transaction.ID, &transaction.CreatedAt, &transaction.UpdatedAt)
 
 			if err != nil {
 				if isDuplicateKeyError(err) {
 					return repositories.NewDuplicateKeyError("transaction", "source_id", transaction.SourceID)
 				}
-				return repositories.NewRepositoryError("create_batch", "transaction", err)
+				return repositoryError("create_batch", "transaction", err)
 			}
 		}
 
@@ -104,8 +174,8 @@ func (r *TransactionRepository) CreateBatch(ctx context.Context, transactions []
 func (r *TransactionRepository) GetByID(ctx context.Context, id int64) (*repositories.Transaction, error) {
 	query := `
 		SELECT id, portfolio_id, security_id, source_id, status, transaction_type,
-			   quantity, price, transaction_date, reprocessing_attempts, version,
-			   created_at, updated_at
+			   quantity, price, transaction_date, settlement_date, reprocessing_attempts, error_message, error_retryable,
+			   version, created_at, updated_at
 		FROM transactions
 		WHERE id = $1`
 
@@ -126,8 +196,8 @@ func (r *TransactionRepository) GetByID(ctx context.Context, id int64) (*reposit
 func (r *TransactionRepository) GetBySourceID(ctx context.Context, sourceID string) (*repositories.Transaction, error) {
 	query := `
 		SELECT id, portfolio_id, security_id, source_id, status, transaction_type,
-			   quantity, price, transaction_date, reprocessing_attempts, version,
-			   created_at, updated_at
+			   quantity, price, transaction_date, settlement_date, reprocessing_attempts, error_message, error_retryable,
+			   version, created_at, updated_at
 		FROM transactions
 		WHERE source_id = $1`
 
@@ -144,8 +214,36 @@ func (r *TransactionRepository) GetBySourceID(ctx context.Context, sourceID stri
 	return &transaction, nil
 }
 
+// GetBySourceIDs retrieves the transactions with any of the given source IDs
+func (r *TransactionRepository) GetBySourceIDs(ctx context.Context, sourceIDs []string) ([]*repositories.Transaction, error) {
+	if len(sourceIDs) == 0 {
+		return []*repositories.Transaction{}, nil
+	}
+	if err := checkListFilterSize("transaction", "source_ids", len(sourceIDs), r.maxListFilterSize); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, portfolio_id, security_id, source_id, status, transaction_type,
+			   quantity, price, transaction_date, settlement_date, reprocessing_attempts, error_message, error_retryable,
+			   version, created_at, updated_at
+		FROM transactions
+		WHERE source_id = ANY($1)`
+
+	var transactions []*repositories.Transaction
+	if err := r.db.SelectContext(ctx, &transactions, query, pq.Array(sourceIDs)); err != nil {
+		return nil, queryError(ctx, "get_by_source_ids", "transaction", err)
+	}
+
+	return transactions, nil
+}
+
 // List retrieves transactions based on filter criteria
 func (r *TransactionRepository) List(ctx context.Context, filter repositories.TransactionFilter) ([]*repositories.Transaction, error) {
+	if err := r.checkListFilterSizes(filter); err != nil {
+		return nil, err
+	}
+
 	query, args, err := r.buildListQuery(filter)
 	if err != nil {
 		return nil, repositories.NewRepositoryError("build_query", "transaction", err)
@@ -155,7 +253,7 @@ func (r *TransactionRepository) List(ctx context.Context, filter repositories.Tr
 	err = r.db.SelectContext(ctx, &transactions, query, args...)
 
 	if err != nil {
-		return nil, repositories.NewRepositoryError("list", "transaction", err)
+		return nil, queryError(ctx, "list", "transaction", err)
 	}
 
 	return transactions, nil
@@ -163,6 +261,10 @@ func (r *TransactionRepository) List(ctx context.Context, filter repositories.Tr
 
 // Count counts transactions based on filter criteria
 func (r *TransactionRepository) Count(ctx context.Context, filter repositories.TransactionFilter) (int64, error) {
+	if err := r.checkListFilterSizes(filter); err != nil {
+		return 0, err
+	}
+
 	query, args, err := r.buildCountQuery(filter)
 	if err != nil {
 		return 0, repositories.NewRepositoryError("build_query", "transaction", err)
@@ -172,7 +274,7 @@ func (r *TransactionRepository) Count(ctx context.Context, filter repositories.T
 	err = r.db.GetContext(ctx, &count, query, args...)
 
 	if err != nil {
-		return 0, repositories.NewRepositoryError("count", "transaction", err)
+		return 0, queryError(ctx, "count", "transaction", err)
 	}
 
 	return count, nil
@@ -190,6 +292,7 @@ func (r *TransactionRepository) Update(ctx context.Context, transaction *reposit
 			quantity = :quantity,
 			price = :price,
 			transaction_date = :transaction_date,
+			settlement_date = :settlement_date,
 			reprocessing_attempts = :reprocessing_attempts,
 			version = version + 1,
 			updated_at = CURRENT_TIMESTAMP
@@ -231,22 +334,14 @@ func (r *TransactionRepository) UpdateStatus(ctx context.Context, id int64, stat
 		UPDATE transactions SET
 			status = $1,
 			error_message = $2,
+			error_retryable = FALSE,
 			version = version + 1,
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = $3 AND version = $4`
 
-	result, err := r.db.ExecContext(ctx, query, status, errorMessage, id, version)
-	if err != nil {
-		return repositories.NewRepositoryError("update_status", "transaction", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return repositories.NewRepositoryError("check_rows", "transaction", err)
-	}
-
-	if rowsAffected == 0 {
-		return repositories.NewOptimisticLockError("transaction", id, version, version+1)
+	if err := r.updateWithEvent(ctx, "update_status", repositories.TransactionEventStatusChange, id, version,
+		query, status, errorMessage, id, version); err != nil {
+		return err
 	}
 
 	r.logger.Info("Transaction status updated",
@@ -256,27 +351,84 @@ func (r *TransactionRepository) UpdateStatus(ctx context.Context, id int64, stat
 	return nil
 }
 
-// IncrementReprocessingAttempts increments the reprocessing attempts counter
-func (r *TransactionRepository) IncrementReprocessingAttempts(ctx context.Context, id int64, version int) error {
+// MarkRetryableError sets a transaction to ERROR with a transient, automatically retryable error
+func (r *TransactionRepository) MarkRetryableError(ctx context.Context, id int64, errorMessage *string, version int) error {
 	query := `
 		UPDATE transactions SET
-			reprocessing_attempts = reprocessing_attempts + 1,
+			status = 'ERROR',
+			error_message = $1,
+			error_retryable = TRUE,
 			version = version + 1,
 			updated_at = CURRENT_TIMESTAMP
-		WHERE id = $1 AND version = $2`
+		WHERE id = $2 AND version = $3`
+
+	if err := r.updateWithEvent(ctx, "mark_retryable", repositories.TransactionEventRetryableError, id, version,
+		query, errorMessage, id, version); err != nil {
+		return err
+	}
 
-	result, err := r.db.ExecContext(ctx, query, id, version)
+	r.logger.Info("Transaction marked for automatic reprocessing",
+		logger.Int64("id", id))
+
+	return nil
+}
+
+// SetNotionalAmount stores the notional amount computed at processing
+func (r *TransactionRepository) SetNotionalAmount(ctx context.Context, id int64, amount decimal.Decimal) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE transactions SET notional_amount = $1 WHERE id = $2`, amount, id)
+	if err != nil {
+		return repositories.NewRepositoryError("set_notional_amount", "transaction", err)
+	}
+	affected, err := result.RowsAffected()
 	if err != nil {
-		return repositories.NewRepositoryError("increment_attempts", "transaction", err)
+		return repositories.NewRepositoryError("set_notional_amount", "transaction", err)
+	}
+	if affected == 0 {
+		return repositories.NewNotFoundError("transaction", id)
+	}
+	return nil
+}
+
+// BackfillNotionalAmounts stores the notional amount of up to limit processed transactions
+// that have none, oldest first. The amount is computed like the balance calculator does:
+// the unsigned quantity * price rounded to 8 decimal places.
+func (r *TransactionRepository) BackfillNotionalAmounts(ctx context.Context, limit int) (int64, error) {
+	if limit <= 0 {
+		return 0, fmt.Errorf("limit must be positive: %d", limit)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	query := `
+		UPDATE transactions SET notional_amount = ROUND(quantity * price, 8)
+		WHERE id IN (
+			SELECT id FROM transactions
+			WHERE status = 'PROC' AND notional_amount IS NULL
+			ORDER BY id
+			LIMIT $1
+		)`
+
+	result, err := r.db.ExecContext(ctx, query, limit)
+	if err != nil {
+		return 0, repositories.NewRepositoryError("backfill_notional_amounts", "transaction", err)
+	}
+	updated, err := result.RowsAffected()
 	if err != nil {
-		return repositories.NewRepositoryError("check_rows", "transaction", err)
+		return 0, repositories.NewRepositoryError("backfill_notional_amounts", "transaction", err)
 	}
+	return updated, nil
+}
 
-	if rowsAffected == 0 {
-		return repositories.NewOptimisticLockError("transaction", id, version, version+1)
+// IncrementReprocessingAttempts increments the reprocessing attempts counter
+func (r *TransactionRepository) IncrementReprocessingAttempts(ctx context.Context, id int64, version int) error {
+	query := `
+		UPDATE transactions SET
+			reprocessing_attempts = reprocessing_attempts + 1,
+			version = version + 1,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND version = $2`
+
+	if err := r.updateWithEvent(ctx, "increment_attempts", repositories.TransactionEventReprocessingAttempt, id, version,
+		query, id, version); err != nil {
+		return err
 	}
 
 	r.logger.Info("Transaction reprocessing attempts incremented",
@@ -285,6 +437,69 @@ func (r *TransactionRepository) IncrementReprocessingAttempts(ctx context.Contex
 	return nil
 }
 
+// updateWithEvent runs a versioned single-transaction update and appends the resulting audit
+// event in the same database transaction
+func (r *TransactionRepository) updateWithEvent(ctx context.Context, operation string, eventType repositories.TransactionEventType, id int64, version int, updateQuery string, args ...interface{}) error {
+	return r.db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		var oldStatus string
+		err := tx.GetContext(ctx, &oldStatus,
+			`SELECT RTRIM(status) FROM transactions WHERE id = $1 AND version = $2 FOR UPDATE`, id, version)
+		if err == sql.ErrNoRows {
+			return repositories.NewOptimisticLockError("transaction", id, version, version+1)
+		}
+		if err != nil {
+			return repositories.NewRepositoryError(operation, "transaction", err)
+		}
+
+		event := repositories.TransactionEvent{
+			TransactionID: id,
+			EventType:     eventType,
+			OldStatus:     &oldStatus,
+		}
+		returning := updateQuery + `
+		RETURNING RTRIM(status), reprocessing_attempts, error_message, error_retryable, version`
+		err = tx.QueryRowxContext(ctx, returning, args...).Scan(
+			&event.NewStatus, &event.ReprocessingAttempts, &event.ErrorMessage, &event.ErrorRetryable, &event.Version)
+		if err == sql.ErrNoRows {
+			return repositories.NewOptimisticLockError("transaction", id, version, version+1)
+		}
+		if err != nil {
+			return repositories.NewRepositoryError(operation, "transaction", err)
+		}
+
+		insertEvent := `
+			INSERT INTO transaction_events (
+				transaction_id, event_type, old_status, new_status,
+				reprocessing_attempts, error_message, error_retryable, version
+			) VALUES (
+				:transaction_id, :event_type, :old_status, :new_status,
+				:reprocessing_attempts, :error_message, :error_retryable, :version
+			)`
+		if _, err := tx.NamedExecContext(ctx, insertEvent, &event); err != nil {
+			return repositories.NewRepositoryError("record_event", "transaction_event", err)
+		}
+
+		return nil
+	})
+}
+
+// GetHistory retrieves the audit events of a transaction, oldest first
+func (r *TransactionRepository) GetHistory(ctx context.Context, transactionID int64) ([]*repositories.TransactionEvent, error) {
+	query := `
+		SELECT id, transaction_id, event_type, old_status, new_status,
+			   reprocessing_attempts, error_message, error_retryable, version, created_at
+		FROM transaction_events
+		WHERE transaction_id = $1
+		ORDER BY id`
+
+	var events []*repositories.TransactionEvent
+	if err := r.db.SelectContext(ctx, &events, query, transactionID); err != nil {
+		return nil, repositories.NewRepositoryError("get_history", "transaction_event", err)
+	}
+
+	return events, nil
+}
+
 // GetNewTransactions retrieves transactions with NEW status
 func (r *TransactionRepository) GetNewTransactions(ctx context.Context, limit int) ([]*repositories.Transaction, error) {
 	filter := repositories.TransactionFilter{
@@ -320,6 +535,89 @@ func (r *TransactionRepository) GetTransactionsByStatus(ctx context.Context, sta
 	return r.List(ctx, filter)
 }
 
+// GetLatestTransactionPerPortfolio retrieves the most recently created transaction of each
+// portfolio. Transactions created at the same instant are ordered by ID.
+func (r *TransactionRepository) GetLatestTransactionPerPortfolio(ctx context.Context, portfolioIDs []string) ([]*repositories.Transaction, error) {
+	if len(portfolioIDs) == 0 {
+		return []*repositories.Transaction{}, nil
+	}
+	if err := checkListFilterSize("transaction", "portfolio_ids", len(portfolioIDs), r.maxListFilterSize); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT DISTINCT ON (portfolio_id)
+			   id, portfolio_id, security_id, source_id, status, transaction_type,
+			   quantity, price, transaction_date, settlement_date, reprocessing_attempts, error_message, error_retryable,
+			   version, created_at, updated_at
+		FROM transactions
+		WHERE portfolio_id = ANY($1)
+		ORDER BY portfolio_id, created_at DESC, id DESC`
+
+	var transactions []*repositories.Transaction
+	if err := r.db.SelectContext(ctx, &transactions, query, pq.Array(portfolioIDs)); err != nil {
+		return nil, queryError(ctx, "get_latest_per_portfolio", "transaction", err)
+	}
+
+	return transactions, nil
+}
+
+// GetLatestPrices retrieves the latest positive processed price per security in a portfolio
+func (r *TransactionRepository) GetLatestPrices(ctx context.Context, portfolioID string) (map[string]decimal.Decimal, error) {
+	query := `
+		SELECT DISTINCT ON (security_id) security_id, price
+		FROM transactions
+		WHERE portfolio_id = $1
+		  AND security_id IS NOT NULL
+		  AND status = 'PROC'
+		  AND price > 0
+		ORDER BY security_id, transaction_date DESC, id DESC`
+
+	var rows []struct {
+		SecurityID string          `db:"security_id"`
+		Price      decimal.Decimal `db:"price"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, portfolioID); err != nil {
+		return nil, repositories.NewRepositoryError("get_latest_prices", "transaction", err)
+	}
+
+	prices := make(map[string]decimal.Decimal, len(rows))
+	for _, row := range rows {
+		prices[row.SecurityID] = row.Price
+	}
+	return prices, nil
+}
+
+// GetLatestPricesForSecurities retrieves the latest positive processed price of each security
+// across all portfolios
+func (r *TransactionRepository) GetLatestPricesForSecurities(ctx context.Context, securityIDs []string) (map[string]decimal.Decimal, error) {
+	if len(securityIDs) == 0 {
+		return map[string]decimal.Decimal{}, nil
+	}
+
+	query := `
+		SELECT DISTINCT ON (security_id) security_id, price
+		FROM transactions
+		WHERE security_id = ANY($1)
+		  AND status = 'PROC'
+		  AND price > 0
+		ORDER BY security_id, transaction_date DESC, id DESC`
+
+	var rows []struct {
+		SecurityID string          `db:"security_id"`
+		Price      decimal.Decimal `db:"price"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, query, pq.Array(securityIDs)); err != nil {
+		return nil, repositories.NewRepositoryError("get_latest_prices", "transaction", err)
+	}
+
+	prices := make(map[string]decimal.Decimal, len(rows))
+	for _, row := range rows {
+		prices[row.SecurityID] = row.Price
+	}
+	return prices, nil
+}
+
 // UpdateTransactionsStatus updates the status of multiple transactions
 func (r *TransactionRepository) UpdateTransactionsStatus(ctx context.Context, ids []int64, status string, errorMessage *string) error {
 	if len(ids) == 0 {
@@ -331,6 +629,7 @@ func (r *TransactionRepository) UpdateTransactionsStatus(ctx context.Context, id
 			UPDATE transactions SET
 				status = $1,
 				error_message = $2,
+				error_retryable = FALSE,
 				version = version + 1,
 				updated_at = CURRENT_TIMESTAMP
 			WHERE id = ANY($3)`
@@ -348,6 +647,75 @@ func (r *TransactionRepository) UpdateTransactionsStatus(ctx context.Context, id
 	})
 }
 
+// CountTransactionsOlderThan counts the transactions DeleteTransactionsOlderThan would delete
+func (r *TransactionRepository) CountTransactionsOlderThan(ctx context.Context, cutoff time.Time, statuses []string) (int64, error) {
+	whereClause, args := retentionWhereClause(cutoff, statuses)
+
+	var count int64
+	if err := r.db.GetContext(ctx, &count, "SELECT COUNT(*) FROM transactions WHERE "+whereClause, args...); err != nil {
+		return 0, repositories.NewRepositoryError("count_older_than", "transaction", err)
+	}
+	return count, nil
+}
+
+// DeleteTransactionsOlderThan deletes transactions older than cutoff, batchSize rows per
+// statement so that no single statement holds locks on a large part of the table. Batches
+// already deleted stay deleted if a later batch fails or ctx is cancelled.
+func (r *TransactionRepository) DeleteTransactionsOlderThan(ctx context.Context, cutoff time.Time, statuses []string, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("batch size must be positive: %d", batchSize)
+	}
+
+	whereClause, args := retentionWhereClause(cutoff, statuses)
+	query := fmt.Sprintf(`
+		DELETE FROM transactions
+		WHERE id IN (
+			SELECT id FROM transactions
+			WHERE %s
+			ORDER BY id
+			LIMIT $%d
+		)`, whereClause, len(args)+1)
+	args = append(args, batchSize)
+
+	var deleted int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+
+		result, err := r.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return deleted, repositories.NewRepositoryError("delete_older_than", "transaction", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, repositories.NewRepositoryError("delete_older_than", "transaction", err)
+		}
+		deleted += affected
+
+		if affected < int64(batchSize) {
+			break
+		}
+	}
+
+	r.logger.Info("Transactions older than cutoff deleted",
+		logger.String("cutoff", cutoff.Format("2006-01-02")),
+		logger.Int64("deleted", deleted))
+
+	return deleted, nil
+}
+
+// retentionWhereClause builds the condition selecting transactions older than cutoff
+func retentionWhereClause(cutoff time.Time, statuses []string) (string, []interface{}) {
+	conditions := []string{"transaction_date < $1"}
+	args := []interface{}{cutoff}
+	if len(statuses) > 0 {
+		conditions = append(conditions, "status = ANY($2)")
+		args = append(args, pq.Array(statuses))
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
 // GetTransactionStats retrieves transaction statistics
 func (r *TransactionRepository) GetTransactionStats(ctx context.Context) (*repositories.TransactionStats, error) {
 	stats := &repositories.TransactionStats{
@@ -415,12 +783,23 @@ func (r *TransactionRepository) GetTransactionStats(ctx context.Context) (*repos
 	return stats, nil
 }
 
+// checkListFilterSizes rejects a filter whose ID lists exceed the configured maximum
+func (r *TransactionRepository) checkListFilterSizes(filter repositories.TransactionFilter) error {
+	if err := checkListFilterSize("transaction", "ids", len(filter.IDs), r.maxListFilterSize); err != nil {
+		return err
+	}
+	if err := checkListFilterSize("transaction", "portfolio_ids", len(filter.PortfolioIDs), r.maxListFilterSize); err != nil {
+		return err
+	}
+	return checkListFilterSize("transaction", "security_ids", len(filter.SecurityIDs), r.maxListFilterSize)
+}
+
 // buildListQuery builds the SELECT query for listing transactions
 func (r *TransactionRepository) buildListQuery(filter repositories.TransactionFilter) (string, []interface{}, error) {
 	query := `
 		SELECT id, portfolio_id, security_id, source_id, status, transaction_type,
-			   quantity, price, transaction_date, reprocessing_attempts, version,
-			   created_at, updated_at
+			   quantity, price, transaction_date, settlement_date, reprocessing_attempts, error_message, error_retryable,
+			   version, created_at, updated_at
 		FROM transactions`
 
 	whereClause, args := r.buildWhereClause(filter)
@@ -469,6 +848,12 @@ func (r *TransactionRepository) buildWhereClause(filter repositories.Transaction
 		argIndex++
 	}
 
+	if filter.AfterID != nil {
+		conditions = append(conditions, fmt.Sprintf("id > $%d", argIndex))
+		args = append(args, *filter.AfterID)
+		argIndex++
+	}
+
 	if filter.PortfolioID != nil {
 		conditions = append(conditions, fmt.Sprintf("portfolio_id = $%d", argIndex))
 		args = append(args, *filter.PortfolioID)
@@ -500,6 +885,12 @@ func (r *TransactionRepository) buildWhereClause(filter repositories.Transaction
 		argIndex++
 	}
 
+	if filter.Retryable != nil {
+		conditions = append(conditions, fmt.Sprintf("error_retryable = $%d", argIndex))
+		args = append(args, *filter.Retryable)
+		argIndex++
+	}
+
 	// Date filters
 	if filter.TransactionDate != nil {
 		conditions = append(conditions, fmt.Sprintf("transaction_date = $%d", argIndex))
@@ -0,0 +1,176 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+func TestTransactionRepository_HistoryAcrossReprocessCycle(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires a PostgreSQL container")
+	}
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	testDB, err := database.NewTestDatabase(ctx)
+	require.NoError(t, err)
+	defer testDB.Close(ctx)
+
+	repo := NewTransactionRepository(testDB.DB, logger.NewNoop())
+
+	securityID := "SECURITY1234567890123456"
+	txn := &repositories.Transaction{
+		PortfolioID:     "PORTFOLIO123456789012345",
+		SecurityID:      &securityID,
+		SourceID:        "HISTORY-1",
+		Status:          "NEW",
+		TransactionType: "BUY",
+		Quantity:        decimal.NewFromInt(10),
+		Price:           decimal.NewFromInt(5),
+		TransactionDate: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, repo.Create(ctx, txn))
+
+	history, err := repo.GetHistory(ctx, txn.ID)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	// Transient failure, one reprocessing attempt, then success
+	transient := "balance version conflict"
+	require.NoError(t, repo.MarkRetryableError(ctx, txn.ID, &transient, 1))
+	require.NoError(t, repo.IncrementReprocessingAttempts(ctx, txn.ID, 2))
+	require.NoError(t, repo.UpdateStatus(ctx, txn.ID, "PROC", nil, 3))
+
+	// A stale version changes nothing and records nothing
+	err = repo.UpdateStatus(ctx, txn.ID, "FATAL", nil, 3)
+	assert.True(t, repositories.IsOptimisticLockError(err))
+
+	history, err = repo.GetHistory(ctx, txn.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+
+	assert.Equal(t, repositories.TransactionEventRetryableError, history[0].EventType)
+	require.NotNil(t, history[0].OldStatus)
+	assert.Equal(t, "NEW", *history[0].OldStatus)
+	assert.Equal(t, "ERROR", history[0].NewStatus)
+	assert.True(t, history[0].ErrorRetryable)
+	require.NotNil(t, history[0].ErrorMessage)
+	assert.Equal(t, transient, *history[0].ErrorMessage)
+	assert.Equal(t, 2, history[0].Version)
+
+	assert.Equal(t, repositories.TransactionEventReprocessingAttempt, history[1].EventType)
+	assert.Equal(t, "ERROR", *history[1].OldStatus)
+	assert.Equal(t, "ERROR", history[1].NewStatus)
+	assert.Equal(t, 1, history[1].ReprocessingAttempts)
+	assert.Equal(t, 3, history[1].Version)
+
+	assert.Equal(t, repositories.TransactionEventStatusChange, history[2].EventType)
+	assert.Equal(t, "ERROR", *history[2].OldStatus)
+	assert.Equal(t, "PROC", history[2].NewStatus)
+	assert.Equal(t, 1, history[2].ReprocessingAttempts)
+	assert.Nil(t, history[2].ErrorMessage)
+	assert.False(t, history[2].ErrorRetryable)
+	assert.Equal(t, 4, history[2].Version)
+}
+
+func TestTransactionRepository_ReadsErrorMessage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires a PostgreSQL container")
+	}
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	testDB, err := database.NewTestDatabase(ctx)
+	require.NoError(t, err)
+	defer testDB.Close(ctx)
+
+	repo := NewTransactionRepository(testDB.DB, logger.NewNoop())
+
+	txn := &repositories.Transaction{
+		PortfolioID:     "PORTFOLIO123456789012345",
+		SourceID:        "ERROR-MESSAGE-1",
+		Status:          "NEW",
+		TransactionType: "DEP",
+		Quantity:        decimal.NewFromInt(10),
+		Price:           decimal.NewFromInt(1),
+		TransactionDate: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, repo.Create(ctx, txn))
+
+	cause := "balance version conflict"
+	require.NoError(t, repo.MarkRetryableError(ctx, txn.ID, &cause, 1))
+
+	byID, err := repo.GetByID(ctx, txn.ID)
+	require.NoError(t, err)
+	require.NotNil(t, byID.ErrorMessage)
+	assert.Equal(t, cause, *byID.ErrorMessage)
+
+	bySourceID, err := repo.GetBySourceID(ctx, txn.SourceID)
+	require.NoError(t, err)
+	require.NotNil(t, bySourceID.ErrorMessage)
+	assert.Equal(t, cause, *bySourceID.ErrorMessage)
+
+	status := "ERROR"
+	listed, err := repo.List(ctx, repositories.TransactionFilter{Status: &status, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	require.NotNil(t, listed[0].ErrorMessage)
+	assert.Equal(t, cause, *listed[0].ErrorMessage)
+}
+
+func TestTransactionRepository_CreateWithinDailyLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("requires a PostgreSQL container")
+	}
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	ctx := context.Background()
+	testDB, err := database.NewTestDatabase(ctx)
+	require.NoError(t, err)
+	defer testDB.Close(ctx)
+
+	repo := NewTransactionRepository(testDB.DB, logger.NewNoop())
+
+	// Concurrent creates for one portfolio and day never book more than the limit
+	const limit = 2
+	errs := make([]error, 6)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = repo.CreateWithinDailyLimit(ctx, &repositories.Transaction{
+				PortfolioID:     "PORTFOLIO123456789012345",
+				SourceID:        fmt.Sprintf("DAILY-LIMIT-%d", i),
+				Status:          "NEW",
+				TransactionType: "DEP",
+				Quantity:        decimal.NewFromInt(10),
+				Price:           decimal.NewFromInt(1),
+				TransactionDate: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			}, limit)
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.True(t, repositories.IsDailyLimitReachedError(err), err)
+	}
+	assert.Equal(t, limit, created)
+}
@@ -0,0 +1,23 @@
+package postgresql
+
+import (
+	"context"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database"
+)
+
+// UnitOfWork implements repositories.UnitOfWork with PostgreSQL transactions
+type UnitOfWork struct {
+	db *database.DB
+}
+
+// NewUnitOfWork creates a unit of work whose transactions the repositories on db take part in
+func NewUnitOfWork(db *database.DB) repositories.UnitOfWork {
+	return &UnitOfWork{db: db}
+}
+
+// Atomically runs fn in one database transaction
+func (u *UnitOfWork) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	return u.db.RunInTransaction(ctx, fn)
+}
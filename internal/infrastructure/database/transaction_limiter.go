@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// TransactionLimiter bounds the number of write transactions open at the same time. Every open
+// transaction holds a pooled connection, so without a bound many concurrent large batches can
+// take the whole pool and starve reads, or deadlock waiting for a connection while holding one.
+// Transactions beyond the limit wait in line for a slot.
+type TransactionLimiter struct {
+	slots   chan struct{}
+	waiting atomic.Int64
+}
+
+// TransactionLimiterStats reports the usage of a transaction limiter
+type TransactionLimiterStats struct {
+	Limit   int `json:"limit"`
+	InUse   int `json:"in_use"`
+	Waiting int `json:"waiting"`
+}
+
+// NewTransactionLimiter creates a limiter allowing limit concurrent transactions. A limit below
+// one returns nil, which allows any number.
+func NewTransactionLimiter(limit int) *TransactionLimiter {
+	if limit < 1 {
+		return nil
+	}
+	return &TransactionLimiter{slots: make(chan struct{}, limit)}
+}
+
+// WriteTransactionLimit returns the number of concurrent write transactions a fraction of a pool
+// of maxOpenConns connections allows, at least one. It returns 0, no limit, when the fraction is
+// not positive or the pool is unbounded.
+func WriteTransactionLimit(maxOpenConns int, fraction float64) int {
+	if fraction <= 0 || maxOpenConns <= 0 {
+		return 0
+	}
+	limit := int(float64(maxOpenConns) * fraction)
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// Acquire waits for a free slot until ctx is done. Every successful Acquire must be followed by
+// a Release. A nil limiter never waits.
+func (l *TransactionLimiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (l *TransactionLimiter) Release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// Stats returns the current usage. A nil limiter reports a zero limit.
+func (l *TransactionLimiter) Stats() TransactionLimiterStats {
+	if l == nil {
+		return TransactionLimiterStats{}
+	}
+	return TransactionLimiterStats{
+		Limit:   cap(l.slots),
+		InUse:   len(l.slots),
+		Waiting: int(l.waiting.Load()),
+	}
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionLimiter_BoundsConcurrentTransactions(t *testing.T) {
+	const limit = 3
+	limiter := NewTransactionLimiter(limit)
+
+	var active, maxActive atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, limiter.Acquire(context.Background()))
+			defer limiter.Release()
+
+			current := active.Add(1)
+			for {
+				seen := maxActive.Load()
+				if current <= seen || maxActive.CompareAndSwap(seen, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			active.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(limit), maxActive.Load(), "transactions beyond the limit wait for a slot")
+	assert.Equal(t, TransactionLimiterStats{Limit: limit}, limiter.Stats())
+}
+
+func TestTransactionLimiter_QueuedTransactions(t *testing.T) {
+	limiter := NewTransactionLimiter(1)
+	require.NoError(t, limiter.Acquire(context.Background()))
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- limiter.Acquire(context.Background()) }()
+
+	require.Eventually(t, func() bool { return limiter.Stats().Waiting == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, TransactionLimiterStats{Limit: 1, InUse: 1, Waiting: 1}, limiter.Stats())
+
+	// A waiting transaction gives up when its context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, limiter.Acquire(ctx), context.DeadlineExceeded)
+
+	// Releasing the slot admits the queued transaction
+	limiter.Release()
+	require.NoError(t, <-acquired)
+	assert.Equal(t, TransactionLimiterStats{Limit: 1, InUse: 1}, limiter.Stats())
+	limiter.Release()
+}
+
+func TestTransactionLimiter_Unlimited(t *testing.T) {
+	limiter := NewTransactionLimiter(0)
+	assert.Nil(t, limiter)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, limiter.Acquire(context.Background()))
+	}
+	limiter.Release()
+	assert.Equal(t, TransactionLimiterStats{}, limiter.Stats())
+}
+
+func TestWriteTransactionLimit(t *testing.T) {
+	assert.Equal(t, 12, WriteTransactionLimit(25, 0.5))
+	assert.Equal(t, 25, WriteTransactionLimit(25, 1))
+	assert.Equal(t, 1, WriteTransactionLimit(4, 0.1), "at least one transaction is allowed")
+	assert.Zero(t, WriteTransactionLimit(25, 0))
+	assert.Zero(t, WriteTransactionLimit(0, 0.5), "an unbounded pool is not limited")
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// txContextKey is the context key of the database transaction RunInTransaction runs its function in
+type txContextKey struct{}
+
+// RunInTransaction runs fn in one database transaction, committed when fn returns nil and
+// rolled back otherwise. Queries made through the DB with the context fn receives, including
+// those of WithTransaction, run in that transaction; when ctx already belongs to a transaction,
+// fn joins it and the outermost call commits.
+func (db *DB) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := txFromContext(ctx); ok {
+		return fn(ctx)
+	}
+	return db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+		return fn(context.WithValue(ctx, txContextKey{}, tx))
+	})
+}
+
+// txFromContext returns the database transaction ctx belongs to, if any
+func txFromContext(ctx context.Context) (*sqlx.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*sqlx.Tx)
+	return tx, ok
+}
+
+// ext returns the transaction ctx belongs to, or the connection pool outside a transaction
+func (db *DB) ext(ctx context.Context) sqlx.ExtContext {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx
+	}
+	return db.DB
+}
+
+// The query methods below, like the logged ones of connection.go, shadow those of the embedded
+// sqlx.DB so repositories take part in the transaction of RunInTransaction without passing it
+// around.
+
+// QueryContext runs a query in the transaction of ctx, if any
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return db.ext(ctx).QueryContext(ctx, query, args...)
+}
+
+// QueryxContext runs a query in the transaction of ctx, if any
+func (db *DB) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	return db.ext(ctx).QueryxContext(ctx, query, args...)
+}
+
+// QueryRowxContext runs a query returning one row in the transaction of ctx, if any
+func (db *DB) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	return db.ext(ctx).QueryRowxContext(ctx, query, args...)
+}
+
+// NamedQueryContext runs a query with named parameters in the transaction of ctx, if any
+func (db *DB) NamedQueryContext(ctx context.Context, query string, arg interface{}) (*sqlx.Rows, error) {
+	return sqlx.NamedQueryContext(ctx, db.ext(ctx), query, arg)
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// recordingConn is a database driver connection that records the statements and transaction
+// boundaries it sees
+type recordingConn struct {
+	events []string
+	inTx   bool
+}
+
+func (c *recordingConn) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *recordingConn) Driver() driver.Driver                        { return nil }
+func (c *recordingConn) Prepare(string) (driver.Stmt, error)          { return c, nil }
+func (c *recordingConn) Close() error                                 { return nil }
+func (c *recordingConn) NumInput() int                                { return -1 }
+func (c *recordingConn) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	c.events = append(c.events, "begin")
+	c.inTx = true
+	return c, nil
+}
+
+func (c *recordingConn) Commit() error {
+	c.events = append(c.events, "commit")
+	c.inTx = false
+	return nil
+}
+
+func (c *recordingConn) Rollback() error {
+	c.events = append(c.events, "rollback")
+	c.inTx = false
+	return nil
+}
+
+func (c *recordingConn) Exec([]driver.Value) (driver.Result, error) {
+	if c.inTx {
+		c.events = append(c.events, "exec in transaction")
+	} else {
+		c.events = append(c.events, "exec")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func newRecordingDB() (*DB, *recordingConn) {
+	conn := &recordingConn{}
+	sqlDB := sql.OpenDB(conn)
+	sqlDB.SetMaxOpenConns(1)
+	return &DB{DB: sqlx.NewDb(sqlDB, "postgres"), logger: logger.NewNoop()}, conn
+}
+
+func TestDB_RunInTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Statements and nested transactions join the unit", func(t *testing.T) {
+		db, conn := newRecordingDB()
+
+		err := db.RunInTransaction(ctx, func(ctx context.Context) error {
+			if _, err := db.ExecContext(ctx, "UPDATE balances SET version = version + 1"); err != nil {
+				return err
+			}
+			return db.WithTransaction(ctx, func(tx *sqlx.Tx) error {
+				_, err := tx.ExecContext(ctx, "UPDATE transactions SET status = 'PROC'")
+				return err
+			})
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"begin", "exec in transaction", "exec in transaction", "commit"}, conn.events)
+	})
+
+	t.Run("Failed unit is rolled back", func(t *testing.T) {
+		db, conn := newRecordingDB()
+		failure := errors.New("status update failed")
+
+		err := db.RunInTransaction(ctx, func(ctx context.Context) error {
+			if _, err := db.ExecContext(ctx, "UPDATE balances SET version = version + 1"); err != nil {
+				return err
+			}
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+		assert.Equal(t, []string{"begin", "exec in transaction", "rollback"}, conn.events)
+	})
+
+	t.Run("Statements outside a unit use the pool", func(t *testing.T) {
+		db, conn := newRecordingDB()
+
+		_, err := db.ExecContext(ctx, "UPDATE balances SET version = version + 1")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"exec"}, conn.events)
+	})
+}
@@ -0,0 +1,30 @@
+package external
+
+import (
+	"context"
+	"time"
+)
+
+// callTimeoutKey is the context key for per-call timeout overrides
+type callTimeoutKey struct{}
+
+// WithCallTimeout returns a context that overrides the configured call timeout for client calls
+// made with it. The timeout bounds a whole call including retries and never extends a deadline
+// already set on ctx. A non-positive timeout disables the configured call timeout.
+func WithCallTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, callTimeoutKey{}, timeout)
+}
+
+// withCallDeadline derives the context for a single client call. The caller's context is always
+// the parent, so cancelling the originating request cancels pending upstream calls.
+func withCallDeadline(ctx context.Context, configured time.Duration) (context.Context, context.CancelFunc) {
+	timeout := configured
+	if override, ok := ctx.Value(callTimeoutKey{}).(time.Duration); ok {
+		timeout = override
+	}
+
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -2,6 +2,7 @@ package external
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -113,7 +114,10 @@ func (cb *CircuitBreaker) Execute(ctx context.Context, fn func() error) error {
 	// Execute the function
 	err := fn()
 
-	// Record the result
+	// Record the result. A cancelled caller says nothing about the health of the service.
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return err
+	}
 	if err != nil {
 		cb.recordFailure()
 	} else {
@@ -14,6 +14,11 @@ type ClientConfig struct {
 	MaxIdleConnsPerHost int           `mapstructure:"max_idle_conns_per_host" json:"max_idle_conns_per_host"`
 	IdleConnTimeout     time.Duration `mapstructure:"idle_conn_timeout" json:"idle_conn_timeout"`
 
+	// CallTimeout bounds a single client call including retries; zero leaves calls bounded only
+	// by the caller's context and the per-attempt Timeout. Callers may override it per call
+	// with WithCallTimeout.
+	CallTimeout time.Duration `mapstructure:"call_timeout" json:"call_timeout"`
+
 	// Health check endpoint
 	HealthEndpoint string `mapstructure:"health_endpoint" json:"health_endpoint"`
 
@@ -0,0 +1,107 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// blockingUpstream holds every request until the caller goes away and records what it saw
+type blockingUpstream struct {
+	requests  atomic.Int32
+	cancelled atomic.Int32
+	started   chan struct{}
+}
+
+func newBlockingUpstream(t *testing.T) (*blockingUpstream, *httptest.Server) {
+	upstream := &blockingUpstream{started: make(chan struct{}, 10)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream.requests.Add(1)
+		upstream.started <- struct{}{}
+
+		select {
+		case <-r.Context().Done():
+			upstream.cancelled.Add(1)
+		case <-time.After(5 * time.Second):
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return upstream, server
+}
+
+func newTestPortfolioClient(baseURL string, callTimeout time.Duration) *portfolioClient {
+	return NewPortfolioClient(PortfolioServiceConfig{
+		ClientConfig: ClientConfig{
+			BaseURL:     baseURL,
+			Timeout:     10 * time.Second,
+			CallTimeout: callTimeout,
+			Retry: RetryConfig{
+				MaxAttempts:     3,
+				InitialInterval: 10 * time.Millisecond,
+				MaxInterval:     20 * time.Millisecond,
+				BackoffFactor:   2,
+			},
+		},
+	}, nil, logger.NewNoop()).(*portfolioClient)
+}
+
+func TestPortfolioClient_CancelledContextStopsUpstreamCall(t *testing.T) {
+	upstream, server := newBlockingUpstream(t)
+	client := newTestPortfolioClient(server.URL, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-upstream.started
+		cancel()
+	}()
+
+	start := time.Now()
+	_, err := client.GetPortfolio(ctx, "PORTFOLIO123456789012345")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// The in-flight request was aborted and not retried
+	assert.Eventually(t, func() bool { return upstream.cancelled.Load() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), upstream.requests.Load())
+
+	// A caller going away does not count against the upstream service
+	stats := client.circuitBreaker.GetStats()
+	assert.Equal(t, CircuitBreakerStateClosed, stats.State)
+	assert.Equal(t, uint32(0), stats.FailureCount)
+}
+
+func TestPortfolioClient_AlreadyCancelledContextMakesNoCall(t *testing.T) {
+	upstream, server := newBlockingUpstream(t)
+	client := newTestPortfolioClient(server.URL, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.GetPortfolio(ctx, "PORTFOLIO123456789012345")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(0), upstream.requests.Load())
+}
+
+func TestPortfolioClient_CallTimeoutOverride(t *testing.T) {
+	upstream, server := newBlockingUpstream(t)
+	client := newTestPortfolioClient(server.URL, 5*time.Second)
+
+	ctx := WithCallTimeout(context.Background(), 50*time.Millisecond)
+
+	start := time.Now()
+	_, err := client.GetPortfolio(ctx, "PORTFOLIO123456789012345")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Eventually(t, func() bool { return upstream.cancelled.Load() == 1 }, time.Second, 10*time.Millisecond)
+}
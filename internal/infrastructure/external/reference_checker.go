@@ -0,0 +1,64 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ReferenceChecker looks up transaction references in the portfolio and security services
+type ReferenceChecker struct {
+	portfolioClient PortfolioClient
+	securityClient  SecurityClient
+	lookupTimeout   time.Duration
+}
+
+// NewReferenceChecker creates a reference checker backed by the portfolio and security clients
+func NewReferenceChecker(portfolioClient PortfolioClient, securityClient SecurityClient) *ReferenceChecker {
+	return &ReferenceChecker{
+		portfolioClient: portfolioClient,
+		securityClient:  securityClient,
+	}
+}
+
+// WithLookupTimeout bounds each lookup, retries included, in place of the clients' configured
+// call timeout. Lookups run while a transaction is validated, so a slow service must not hold
+// the request for as long as other client calls may take. Zero keeps the clients' call timeout.
+func (c *ReferenceChecker) WithLookupTimeout(timeout time.Duration) *ReferenceChecker {
+	c.lookupTimeout = timeout
+	return c
+}
+
+// PortfolioExists reports whether the portfolio service knows the portfolio
+func (c *ReferenceChecker) PortfolioExists(ctx context.Context, portfolioID string) (bool, error) {
+	_, err := c.portfolioClient.GetPortfolio(c.lookupContext(ctx), portfolioID)
+	return existence(err)
+}
+
+// SecurityExists reports whether the security service knows the security
+func (c *ReferenceChecker) SecurityExists(ctx context.Context, securityID string) (bool, error) {
+	_, err := c.securityClient.GetSecurity(c.lookupContext(ctx), securityID)
+	return existence(err)
+}
+
+// lookupContext applies the lookup timeout to the context of a client call
+func (c *ReferenceChecker) lookupContext(ctx context.Context) context.Context {
+	if c.lookupTimeout <= 0 {
+		return ctx
+	}
+	return WithCallTimeout(ctx, c.lookupTimeout)
+}
+
+// existence turns the error of a lookup into whether the resource exists; only a not found
+// response means it does not
+func existence(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+
+	var serviceErr *ServiceError
+	if errors.As(err, &serviceErr) && serviceErr.IsNotFound() {
+		return false, nil
+	}
+	return false, err
+}
@@ -0,0 +1,54 @@
+package external
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferenceChecker_PortfolioExists(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/KNOWN"):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"portfolioId":"KNOWN","name":"Known"}`))
+		case strings.HasSuffix(r.URL.Path, "/UNKNOWN"):
+			http.NotFound(w, r)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	checker := NewReferenceChecker(newTestPortfolioClient(server.URL, 0), nil)
+	ctx := context.Background()
+
+	exists, err := checker.PortfolioExists(ctx, "KNOWN")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = checker.PortfolioExists(ctx, "UNKNOWN")
+	require.NoError(t, err, "a not found response is an answer, not a failure")
+	assert.False(t, exists)
+
+	_, err = checker.PortfolioExists(ctx, "BROKEN")
+	assert.Error(t, err, "an unavailable service does not say whether the portfolio exists")
+}
+
+func TestReferenceChecker_LookupTimeout(t *testing.T) {
+	upstream, server := newBlockingUpstream(t)
+	checker := NewReferenceChecker(newTestPortfolioClient(server.URL, 5*time.Second), nil).
+		WithLookupTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	_, err := checker.PortfolioExists(context.Background(), "SLOW")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second, "the slow lookup is cut off by the lookup timeout")
+	assert.Eventually(t, func() bool { return upstream.cancelled.Load() >= 1 }, time.Second, 10*time.Millisecond)
+}
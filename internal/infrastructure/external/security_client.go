@@ -169,6 +169,14 @@ func (c *securityClient) Health(ctx context.Context) error {
 
 // executeRequest executes HTTP request with circuit breaker and retry logic
 func (c *securityClient) executeRequest(ctx context.Context, method, url string, body io.Reader, result interface{}, operation string) error {
+	// Don't start upstream calls for an already cancelled request
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ctx, cancel := withCallDeadline(ctx, c.config.CallTimeout)
+	defer cancel()
+
 	// Execute with circuit breaker
 	return c.circuitBreaker.Execute(ctx, func() error {
 		// Execute with retry
@@ -0,0 +1,11 @@
+-- Revert automatic reprocessing support
+DROP INDEX IF EXISTS idx_transactions_retryable;
+
+UPDATE transactions SET status = 'FATAL' WHERE status = 'DEAD';
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_status;
+ALTER TABLE transactions ADD CONSTRAINT chk_status CHECK (status IN ('NEW', 'PROC', 'ERROR', 'FATAL'));
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS error_retryable;
+
+COMMENT ON COLUMN transactions.status IS 'Processing status: NEW, PROC, ERROR, FATAL';
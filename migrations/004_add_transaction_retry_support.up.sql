@@ -0,0 +1,16 @@
+-- Support automatic reprocessing of transiently failed transactions
+
+-- Flag set when a transaction failed for a transient reason and may be retried automatically
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS error_retryable BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- DEAD marks transactions whose automatic reprocessing attempts are exhausted
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_status;
+ALTER TABLE transactions ADD CONSTRAINT chk_status CHECK (status IN ('NEW', 'PROC', 'ERROR', 'FATAL', 'DEAD'));
+
+-- Partial index for the background reprocessor
+CREATE INDEX IF NOT EXISTS idx_transactions_retryable
+ON transactions (updated_at) WHERE status = 'ERROR' AND error_retryable = TRUE;
+
+COMMENT ON COLUMN transactions.status IS 'Processing status: NEW, PROC, ERROR, FATAL, DEAD';
+COMMENT ON COLUMN transactions.error_retryable IS 'True when the last processing error is transient and may be retried automatically';
+COMMENT ON INDEX idx_transactions_retryable IS 'Partial index for reprocessing transiently failed transactions';
@@ -0,0 +1,2 @@
+-- Drop balance adjustments ledger
+DROP TABLE IF EXISTS balance_adjustments;
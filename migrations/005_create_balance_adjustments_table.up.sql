@@ -0,0 +1,43 @@
+-- Ledger of manual balance adjustments, applied atomically with the balance they correct
+CREATE TABLE IF NOT EXISTS balance_adjustments (
+    id SERIAL PRIMARY KEY,
+    adjustment_key VARCHAR(100) NOT NULL,
+    balance_id INTEGER NOT NULL REFERENCES balances(id),
+    portfolio_id CHAR(24) NOT NULL,
+    security_id CHAR(24),
+    quantity_long_delta DECIMAL(18,8) NOT NULL DEFAULT 0,
+    quantity_short_delta DECIMAL(18,8) NOT NULL DEFAULT 0,
+    quantity_long_before DECIMAL(18,8) NOT NULL,
+    quantity_short_before DECIMAL(18,8) NOT NULL,
+    quantity_long_after DECIMAL(18,8) NOT NULL,
+    quantity_short_after DECIMAL(18,8) NOT NULL,
+    balance_version INTEGER NOT NULL,
+    reason VARCHAR(500) NOT NULL,
+    operator VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    -- Constraints
+    CONSTRAINT chk_adjustment_key_not_empty CHECK (char_length(trim(adjustment_key)) > 0),
+    CONSTRAINT chk_adjustment_portfolio_id_length CHECK (char_length(portfolio_id) = 24),
+    CONSTRAINT chk_adjustment_security_id_length CHECK (security_id IS NULL OR char_length(security_id) = 24),
+    CONSTRAINT chk_adjustment_delta_not_zero CHECK (quantity_long_delta <> 0 OR quantity_short_delta <> 0),
+    CONSTRAINT chk_adjustment_reason_not_empty CHECK (char_length(trim(reason)) > 0),
+    CONSTRAINT chk_adjustment_operator_not_empty CHECK (char_length(trim(operator)) > 0)
+);
+
+-- Adjustment keys make adjustments idempotent
+CREATE UNIQUE INDEX IF NOT EXISTS balance_adjustments_key_ndx
+ON balance_adjustments (adjustment_key);
+
+CREATE INDEX IF NOT EXISTS idx_balance_adjustments_balance_id
+ON balance_adjustments (balance_id);
+
+CREATE INDEX IF NOT EXISTS idx_balance_adjustments_portfolio_id
+ON balance_adjustments (portfolio_id, created_at);
+
+COMMENT ON TABLE balance_adjustments IS 'Auditable manual balance corrections, distinct from transactions';
+COMMENT ON COLUMN balance_adjustments.adjustment_key IS 'Client supplied idempotency key';
+COMMENT ON COLUMN balance_adjustments.security_id IS 'Security identifier (24 characters), NULL for cash';
+COMMENT ON COLUMN balance_adjustments.balance_version IS 'Balance version after the adjustment was applied';
+COMMENT ON COLUMN balance_adjustments.operator IS 'Person or system that requested the adjustment';
+COMMENT ON INDEX balance_adjustments_key_ndx IS 'Unique index on adjustment_key for adjustment idempotency';
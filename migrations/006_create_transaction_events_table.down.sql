@@ -0,0 +1,2 @@
+-- Drop transaction audit trail
+DROP TABLE IF EXISTS transaction_events;
@@ -0,0 +1,25 @@
+-- Audit trail of transaction status changes and reprocessing attempts
+CREATE TABLE IF NOT EXISTS transaction_events (
+    id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    event_type VARCHAR(30) NOT NULL,
+    old_status VARCHAR(5),
+    new_status VARCHAR(5) NOT NULL,
+    reprocessing_attempts INTEGER NOT NULL DEFAULT 0,
+    error_message TEXT,
+    error_retryable BOOLEAN NOT NULL DEFAULT FALSE,
+    version INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    -- Constraints
+    CONSTRAINT chk_event_type CHECK (event_type IN ('STATUS_CHANGE', 'RETRYABLE_ERROR', 'REPROCESSING_ATTEMPT'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_events_transaction_id
+ON transaction_events (transaction_id, id);
+
+COMMENT ON TABLE transaction_events IS 'Append-only history of transaction status changes';
+COMMENT ON COLUMN transaction_events.old_status IS 'Status before the change';
+COMMENT ON COLUMN transaction_events.new_status IS 'Status after the change';
+COMMENT ON COLUMN transaction_events.reprocessing_attempts IS 'Reprocessing attempts after the change';
+COMMENT ON COLUMN transaction_events.version IS 'Transaction version after the change';
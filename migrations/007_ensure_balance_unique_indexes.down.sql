@@ -0,0 +1,2 @@
+-- The balance unique indexes belong to 003_create_indexes; rolling this migration back keeps them
+SELECT 1;
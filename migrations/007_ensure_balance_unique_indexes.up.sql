@@ -0,0 +1,26 @@
+-- Ensure the unique indexes that keep one balance per portfolio-security pair and one cash
+-- balance per portfolio are present and usable. Concurrent balance creation relies on them to
+-- reject the second insert. An index left invalid or created without UNIQUE is rebuilt.
+DO $$
+DECLARE
+    idx RECORD;
+BEGIN
+    FOR idx IN
+        SELECT c.relname
+        FROM pg_index i
+        JOIN pg_class c ON c.oid = i.indexrelid
+        WHERE c.relname IN ('balances_portfolio_security_ndx', 'balances_portfolio_cash_ndx')
+          AND (NOT i.indisunique OR NOT i.indisvalid)
+    LOOP
+        EXECUTE format('DROP INDEX %I', idx.relname);
+    END LOOP;
+END $$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS balances_portfolio_security_ndx
+ON balances (portfolio_id, security_id) WHERE security_id IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS balances_portfolio_cash_ndx
+ON balances (portfolio_id) WHERE security_id IS NULL;
+
+COMMENT ON INDEX balances_portfolio_security_ndx IS 'Unique index enforcing one balance per portfolio-security pair (non-null securities only)';
+COMMENT ON INDEX balances_portfolio_cash_ndx IS 'Unique index enforcing one cash balance per portfolio (security_id IS NULL)';
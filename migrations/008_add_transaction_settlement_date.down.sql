@@ -0,0 +1,4 @@
+-- Revert transaction settlement dates
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_settlement_date;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS settlement_date;
@@ -0,0 +1,9 @@
+-- Optional settlement date for transactions that settle after they trade
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS settlement_date DATE;
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_settlement_date;
+ALTER TABLE transactions ADD CONSTRAINT chk_settlement_date
+CHECK (settlement_date IS NULL OR settlement_date >= transaction_date);
+
+COMMENT ON COLUMN transactions.settlement_date IS 'Settlement date; NULL means the transaction settles on its transaction date';
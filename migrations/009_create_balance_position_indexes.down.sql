@@ -0,0 +1,4 @@
+-- Drop top-N position indexes
+DROP INDEX IF EXISTS idx_balances_quantity_absolute;
+DROP INDEX IF EXISTS idx_balances_quantity_short;
+DROP INDEX IF EXISTS idx_balances_quantity_long;
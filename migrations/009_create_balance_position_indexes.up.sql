@@ -0,0 +1,13 @@
+-- Indexes serving top-N position queries (GET /api/v1/positions/top), one per ranking.
+-- The trailing id matches the query's tie-breaker so ORDER BY ... LIMIT reads the index in order.
+
+CREATE INDEX IF NOT EXISTS idx_balances_quantity_long
+ON balances (quantity_long DESC, id);
+
+CREATE INDEX IF NOT EXISTS idx_balances_quantity_short
+ON balances (quantity_short DESC, id);
+
+CREATE INDEX IF NOT EXISTS idx_balances_quantity_absolute
+ON balances ((ABS(quantity_long - quantity_short)) DESC, id);
+
+COMMENT ON INDEX idx_balances_quantity_absolute IS 'Index for ranking positions by absolute net quantity';
@@ -0,0 +1,4 @@
+-- Revert stored transaction notional amounts
+DROP INDEX IF EXISTS idx_transactions_notional_by_type_date;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS notional_amount;
@@ -0,0 +1,10 @@
+-- Stored notional amount (quantity * price) of processed transactions, for aggregating trade value
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS notional_amount DECIMAL(28,8);
+
+-- Serves per-type totals over a date range, e.g. the BUY notional of a day, from the index alone
+CREATE INDEX IF NOT EXISTS idx_transactions_notional_by_type_date
+ON transactions (transaction_type, transaction_date) INCLUDE (notional_amount)
+WHERE status = 'PROC';
+
+COMMENT ON COLUMN transactions.notional_amount IS 'Unsigned quantity * price computed at processing; NULL until processed or backfilled';
@@ -0,0 +1,8 @@
+-- Revert releasable adjustment keys; released keys get a placeholder unique to their entry
+DROP INDEX IF EXISTS idx_balance_adjustments_key_created_at;
+
+UPDATE balance_adjustments SET adjustment_key = 'released-' || id WHERE adjustment_key IS NULL;
+
+ALTER TABLE balance_adjustments ALTER COLUMN adjustment_key SET NOT NULL;
+
+COMMENT ON COLUMN balance_adjustments.adjustment_key IS 'Client supplied idempotency key';
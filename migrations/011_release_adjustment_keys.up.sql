@@ -0,0 +1,11 @@
+-- Adjustment keys past their maximum age are released so they can be used again; the ledger
+-- entry is kept with a NULL key
+
+ALTER TABLE balance_adjustments ALTER COLUMN adjustment_key DROP NOT NULL;
+
+-- Serves the oldest-first scan of adjustments still holding their key
+CREATE INDEX IF NOT EXISTS idx_balance_adjustments_key_created_at
+ON balance_adjustments (created_at, id)
+WHERE adjustment_key IS NOT NULL;
+
+COMMENT ON COLUMN balance_adjustments.adjustment_key IS 'Client supplied idempotency key; NULL once released after its maximum age';
@@ -0,0 +1,10 @@
+-- Revert the quarantined status
+DROP INDEX IF EXISTS idx_transactions_quarantined;
+
+UPDATE transactions SET status = 'ERROR', error_message = 'quarantine released by migration rollback'
+WHERE status = 'QUAR';
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_status;
+ALTER TABLE transactions ADD CONSTRAINT chk_status CHECK (status IN ('NEW', 'PROC', 'ERROR', 'FATAL', 'DEAD'));
+
+COMMENT ON COLUMN transactions.status IS 'Processing status: NEW, PROC, ERROR, FATAL, DEAD';
@@ -0,0 +1,10 @@
+-- QUAR holds transactions whose portfolio or security is not registered yet
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_status;
+ALTER TABLE transactions ADD CONSTRAINT chk_status CHECK (status IN ('NEW', 'PROC', 'ERROR', 'FATAL', 'DEAD', 'QUAR'));
+
+-- Partial index for releasing quarantined transactions
+CREATE INDEX IF NOT EXISTS idx_transactions_quarantined
+ON transactions (created_at) WHERE status = 'QUAR';
+
+COMMENT ON COLUMN transactions.status IS 'Processing status: NEW, PROC, ERROR, FATAL, DEAD, QUAR';
+COMMENT ON INDEX idx_transactions_quarantined IS 'Partial index for releasing quarantined transactions';
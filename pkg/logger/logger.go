@@ -88,6 +88,11 @@ func NewNoop() Logger {
 	return &zapLogger{logger: zap.NewNop()}
 }
 
+// NewFromZap wraps an existing zap logger
+func NewFromZap(logger *zap.Logger) Logger {
+	return &zapLogger{logger: logger}
+}
+
 // Debug logs a debug message
 func (l *zapLogger) Debug(msg string, fields ...zap.Field) {
 	l.logger.Debug(msg, fields...)
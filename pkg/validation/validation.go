@@ -214,7 +214,7 @@ func (v *Validator) TransactionType(field, value string) *Validator {
 
 // TransactionStatus validates transaction status
 func (v *Validator) TransactionStatus(field, value string) *Validator {
-	allowed := []string{"NEW", "PROC", "ERROR", "FATAL"}
+	allowed := []string{"NEW", "PROC", "ERROR", "FATAL", "DEAD", "QUAR"}
 	return v.Required(field, value).OneOf(field, value, allowed)
 }
 
@@ -317,7 +317,7 @@ func TestAPIIntegration_TransactionEndpoints(t *testing.T) {
 				SourceID:        "buy-test-source-001",
 				TransactionType: "BUY",
 				Quantity:        quantity,
-				Price:           price,
+				Price:           pricePtr(price),
 				TransactionDate: "20250101",
 			},
 		}
@@ -413,7 +413,7 @@ func TestAPIIntegration_TransactionEndpoints(t *testing.T) {
 				SourceID:        "setup-buy-002",
 				TransactionType: "BUY",
 				Quantity:        buyQuantity,
-				Price:           buyPrice,
+				Price:           pricePtr(buyPrice),
 				TransactionDate: "20250101",
 			},
 		}
@@ -436,7 +436,7 @@ func TestAPIIntegration_TransactionEndpoints(t *testing.T) {
 				SourceID:        "sell-test-002",
 				TransactionType: "SELL",
 				Quantity:        sellQuantity,
-				Price:           sellPrice,
+				Price:           pricePtr(sellPrice),
 				TransactionDate: "20250102",
 			},
 		}
@@ -493,7 +493,7 @@ func TestAPIIntegration_TransactionEndpoints(t *testing.T) {
 				SourceID:        "short-test-003",
 				TransactionType: "SHORT",
 				Quantity:        quantity,
-				Price:           price,
+				Price:           pricePtr(price),
 				TransactionDate: "20250101",
 			},
 		}
@@ -548,7 +548,7 @@ func TestAPIIntegration_TransactionEndpoints(t *testing.T) {
 				SourceID:        "deposit-test-004",
 				TransactionType: "DEP",
 				Quantity:        depositAmount,
-				Price:           decimal.NewFromFloat(1.0), // Cash price is always 1.0
+				Price:           pricePtr(decimal.NewFromFloat(1.0)), // Cash price is always 1.0
 				TransactionDate: "20250101",
 			},
 		}
@@ -604,7 +604,7 @@ func TestAPIIntegration_TransactionEndpoints(t *testing.T) {
 					SourceID:        "withdrawal-test-004",
 					TransactionType: "WD",
 					Quantity:        withdrawalAmount,
-					Price:           decimal.NewFromFloat(1.0),
+					Price:           pricePtr(decimal.NewFromFloat(1.0)),
 					TransactionDate: "20250102",
 				},
 			}
@@ -662,7 +662,7 @@ func TestAPIIntegration_TransactionEndpoints(t *testing.T) {
 				SourceID:        "transfer-in-005",
 				TransactionType: "IN",
 				Quantity:        quantity,
-				Price:           decimal.NewFromFloat(0.0), // IN/OUT don't have meaningful prices
+				Price:           pricePtr(decimal.NewFromFloat(0.0)), // IN/OUT don't have meaningful prices
 				TransactionDate: "20250101",
 			},
 		}
@@ -905,3 +905,8 @@ func TestAPIIntegration_ErrorHandling(t *testing.T) {
 			"Should return error response for invalid payload")
 	})
 }
+
+// pricePtr returns a posted transaction price
+func pricePtr(price decimal.Decimal) *decimal.Decimal {
+	return &price
+}
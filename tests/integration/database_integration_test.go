@@ -2,12 +2,15 @@ package integration
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,7 +18,12 @@ import (
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
 
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/config"
 	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/models"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database/postgresql"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
 )
 
 type IntegrationTestSuite struct {
@@ -24,7 +32,7 @@ type IntegrationTestSuite struct {
 	db                *sqlx.DB
 }
 
-func setupIntegrationTestSuite(t *testing.T) *IntegrationTestSuite {
+func setupIntegrationTestSuite(t testing.TB) *IntegrationTestSuite {
 	ctx := context.Background()
 
 	// Start PostgreSQL container
@@ -59,7 +67,7 @@ func setupIntegrationTestSuite(t *testing.T) *IntegrationTestSuite {
 	}
 }
 
-func (suite *IntegrationTestSuite) teardown(t *testing.T) {
+func (suite *IntegrationTestSuite) teardown(t testing.TB) {
 	if suite.db != nil {
 		suite.db.Close()
 	}
@@ -85,6 +93,7 @@ func runMigrations(db *sqlx.DB) error {
 			reprocessing_attempts INTEGER DEFAULT 0,
 			version INTEGER NOT NULL DEFAULT 1,
 			error_message TEXT,
+			notional_amount DECIMAL(28,8),
 			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
 		)
@@ -111,6 +120,20 @@ func runMigrations(db *sqlx.DB) error {
 		return err
 	}
 
+	// Create balance adjustments table; its foreign key keeps adjusted balances from being deleted
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS balance_adjustments (
+			id SERIAL PRIMARY KEY,
+			adjustment_key VARCHAR(100) NOT NULL,
+			balance_id INTEGER NOT NULL REFERENCES balances(id),
+			reason VARCHAR(500) NOT NULL DEFAULT 'test',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
 	// Create indexes
 	_, err = db.Exec(`
 		CREATE UNIQUE INDEX IF NOT EXISTS transaction_source_ndx ON transactions (source_id);
@@ -285,3 +308,444 @@ func TestDatabaseIntegration_BasicOperations(t *testing.T) {
 		assert.True(t, expectedNotional.Equal(notionalAmount.Value()))
 	})
 }
+
+func TestDatabaseIntegration_ConcurrentBalanceCreate(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	suite := setupIntegrationTestSuite(t)
+	defer suite.teardown(t)
+
+	// Apply the migration that guarantees the balance unique indexes
+	migration, err := os.ReadFile("../../migrations/007_ensure_balance_unique_indexes.up.sql")
+	require.NoError(t, err)
+	_, err = suite.db.Exec(string(migration))
+	require.NoError(t, err)
+
+	repo := postgresql.NewBalanceRepository(&database.DB{DB: suite.db}, logger.NewNoop())
+	portfolioID := "PORTFOLIO000000000000009"
+	securityID := "SECURITY0000000000000009"
+
+	tests := []struct {
+		name       string
+		securityID *string
+	}{
+		{name: "Security balance", securityID: &securityID},
+		{name: "Cash balance", securityID: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const attempts = 2
+			start := make(chan struct{})
+			results := make(chan error, attempts)
+
+			var wg sync.WaitGroup
+			for i := 0; i < attempts; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					results <- repo.Create(suite.ctx, &repositories.Balance{
+						PortfolioID:  portfolioID,
+						SecurityID:   tt.securityID,
+						QuantityLong: decimal.NewFromInt(100),
+						Version:      1,
+					})
+				}()
+			}
+			close(start)
+			wg.Wait()
+			close(results)
+
+			succeeded := 0
+			for err := range results {
+				if err == nil {
+					succeeded++
+					continue
+				}
+				assert.True(t, repositories.IsBalanceExistsError(err), "unexpected error: %v", err)
+				assert.True(t, repositories.IsDuplicateKeyError(err))
+			}
+			assert.Equal(t, 1, succeeded, "exactly one create must win")
+
+			// The losing writer can retry with CreateOrUpdate
+			require.NoError(t, repo.CreateOrUpdate(suite.ctx, &repositories.Balance{
+				PortfolioID:  portfolioID,
+				SecurityID:   tt.securityID,
+				QuantityLong: decimal.NewFromInt(250),
+				Version:      1,
+			}))
+
+			var count int
+			err := suite.db.Get(&count,
+				"SELECT COUNT(*) FROM balances WHERE portfolio_id = $1 AND security_id IS NOT DISTINCT FROM $2",
+				portfolioID, tt.securityID)
+			require.NoError(t, err)
+			assert.Equal(t, 1, count)
+		})
+	}
+}
+
+func TestDatabaseIntegration_DeleteTransactionsOlderThan(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	suite := setupIntegrationTestSuite(t)
+	defer suite.teardown(t)
+
+	repo := postgresql.NewTransactionRepository(&database.DB{DB: suite.db}, logger.NewNoop())
+	portfolioID := "PORTFOLIO000000000000010"
+
+	// Five old processed, one old failed and one recent processed transaction
+	insert := func(sourceID, status, date string) {
+		_, err := suite.db.Exec(`
+			INSERT INTO transactions (portfolio_id, source_id, status, transaction_type, quantity, price, transaction_date)
+			VALUES ($1, $2, $3, 'DEP', 100, 1, $4)`, portfolioID, sourceID, status, date)
+		require.NoError(t, err)
+	}
+	for _, sourceID := range []string{"OLD-1", "OLD-2", "OLD-3", "OLD-4", "OLD-5"} {
+		insert(sourceID, "PROC", "2020-01-15")
+	}
+	insert("OLD-FATAL", "FATAL", "2020-01-15")
+	insert("RECENT", "PROC", "2024-06-01")
+
+	cutoff := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	statuses := []string{"PROC"}
+
+	matched, err := repo.CountTransactionsOlderThan(suite.ctx, cutoff, statuses)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), matched, "counting deletes nothing")
+
+	// A batch size below the match count needs several statements
+	deleted, err := repo.DeleteTransactionsOlderThan(suite.ctx, cutoff, statuses, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), deleted)
+
+	var remaining []string
+	require.NoError(t, suite.db.Select(&remaining, "SELECT source_id FROM transactions WHERE portfolio_id = $1 ORDER BY source_id", portfolioID))
+	assert.Equal(t, []string{"OLD-FATAL", "RECENT"}, remaining)
+
+	matched, err = repo.CountTransactionsOlderThan(suite.ctx, cutoff, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), matched, "without statuses every old transaction is selected")
+}
+
+func TestDatabaseIntegration_DeleteZeroBalancesBefore(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	suite := setupIntegrationTestSuite(t)
+	defer suite.teardown(t)
+
+	repo := postgresql.NewBalanceRepository(&database.DB{DB: suite.db}, logger.NewNoop())
+	portfolioID := "PORTFOLIO000000000000012"
+
+	insert := func(securityID *string, quantity string, lastUpdated string) int64 {
+		var id int64
+		require.NoError(t, suite.db.Get(&id, `
+			INSERT INTO balances (portfolio_id, security_id, quantity_long, last_updated)
+			VALUES ($1, $2, $3, $4) RETURNING id`, portfolioID, securityID, quantity, lastUpdated))
+		return id
+	}
+	security := func(n int) *string {
+		securityID := fmt.Sprintf("SECURITY%016d", n)
+		return &securityID
+	}
+
+	// The adjusted balance is the oldest, so it would be the first candidate of every pass
+	adjusted := insert(security(1), "0", "2020-01-01")
+	_, err := suite.db.Exec("INSERT INTO balance_adjustments (adjustment_key, balance_id) VALUES ('ADJ-1', $1)", adjusted)
+	require.NoError(t, err)
+	insert(security(2), "0", "2020-02-01")
+	insert(security(3), "0", "2020-03-01")
+	insert(security(4), "10", "2020-01-01")
+	insert(security(5), "0", "2024-06-01")
+	insert(nil, "0", "2020-01-01")
+
+	cutoff := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	deleted, err := repo.DeleteZeroBalancesBefore(suite.ctx, cutoff, 1)
+	require.NoError(t, err, "the adjusted balance is skipped rather than failing the foreign key")
+	assert.Equal(t, int64(1), deleted)
+	deleted, err = repo.DeleteZeroBalancesBefore(suite.ctx, cutoff, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	var remaining []int64
+	require.NoError(t, suite.db.Select(&remaining, "SELECT id FROM balances WHERE portfolio_id = $1 ORDER BY id", portfolioID))
+	assert.Len(t, remaining, 4)
+	assert.Contains(t, remaining, adjusted)
+}
+
+func TestDatabaseIntegration_NotionalAmounts(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	suite := setupIntegrationTestSuite(t)
+	defer suite.teardown(t)
+
+	repo := postgresql.NewTransactionRepository(&database.DB{DB: suite.db}, logger.NewNoop())
+	portfolioID := "PORTFOLIO000000000000011"
+
+	insert := func(sourceID, status, quantity, price string) int64 {
+		var id int64
+		require.NoError(t, suite.db.Get(&id, `
+			INSERT INTO transactions (portfolio_id, source_id, status, transaction_type, quantity, price)
+			VALUES ($1, $2, $3, 'BUY', $4, $5) RETURNING id`, portfolioID, sourceID, status, quantity, price))
+		return id
+	}
+	stored := insert("STORED", "PROC", "10", "50")
+	insert("PROC-1", "PROC", "3", "0.12345678")
+	insert("PROC-2", "PROC", "100", "25.5")
+	insert("PROC-3", "PROC", "7", "2")
+	insert("NEW-1", "NEW", "1", "1")
+
+	// Processing stores the amount without changing the version
+	require.NoError(t, repo.SetNotionalAmount(suite.ctx, stored, decimal.NewFromInt(500)))
+	var version int
+	require.NoError(t, suite.db.Get(&version, "SELECT version FROM transactions WHERE id = $1", stored))
+	assert.Equal(t, 1, version)
+
+	err := repo.SetNotionalAmount(suite.ctx, 999999, decimal.NewFromInt(1))
+	assert.True(t, repositories.IsNotFoundError(err))
+
+	// A batch size below the missing count needs several calls
+	updated, err := repo.BackfillNotionalAmounts(suite.ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), updated)
+	updated, err = repo.BackfillNotionalAmounts(suite.ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), updated)
+
+	var amounts []struct {
+		SourceID string           `db:"source_id"`
+		Notional *decimal.Decimal `db:"notional_amount"`
+	}
+	require.NoError(t, suite.db.Select(&amounts,
+		"SELECT source_id, notional_amount FROM transactions WHERE portfolio_id = $1 ORDER BY id", portfolioID))
+	require.Len(t, amounts, 5)
+	for i, expected := range []string{"500", "0.37037034", "2550", "14"} {
+		require.NotNil(t, amounts[i].Notional, amounts[i].SourceID)
+		assert.Equal(t, expected, amounts[i].Notional.String(), amounts[i].SourceID)
+	}
+	assert.Nil(t, amounts[4].Notional, "unprocessed transactions are not backfilled")
+}
+
+func TestDatabaseIntegration_GetLatestTransactionPerPortfolio(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	suite := setupIntegrationTestSuite(t)
+	defer suite.teardown(t)
+
+	// Apply the migrations that add the columns transactions are read with
+	for _, name := range []string{"004_add_transaction_retry_support", "008_add_transaction_settlement_date"} {
+		migration, err := os.ReadFile("../../migrations/" + name + ".up.sql")
+		require.NoError(t, err)
+		_, err = suite.db.Exec(string(migration))
+		require.NoError(t, err)
+	}
+
+	repo := postgresql.NewTransactionRepository(&database.DB{DB: suite.db}, logger.NewNoop())
+	portfolio1 := "PORTFOLIO000000000000021"
+	portfolio2 := "PORTFOLIO000000000000022"
+	portfolio3 := "PORTFOLIO000000000000023"
+	base := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	insert := func(portfolioID, sourceID, status string, createdAt time.Time) {
+		_, err := suite.db.Exec(`
+			INSERT INTO transactions (portfolio_id, source_id, status, transaction_type, quantity, price, created_at, updated_at)
+			VALUES ($1, $2, $3, 'DEP', 100, 1, $4, $4)`, portfolioID, sourceID, status, createdAt)
+		require.NoError(t, err)
+	}
+	insert(portfolio1, "P1-OLDEST", "PROC", base)
+	insert(portfolio1, "P1-LATEST", "NEW", base.Add(2*time.Hour))
+	insert(portfolio1, "P1-MIDDLE", "PROC", base.Add(time.Hour))
+	insert(portfolio2, "P2-FIRST", "PROC", base.Add(3*time.Hour))
+	insert(portfolio2, "P2-SAME-INSTANT", "ERROR", base.Add(3*time.Hour))
+	insert(portfolio3, "P3-NOT-REQUESTED", "PROC", base.Add(4*time.Hour))
+
+	latest, err := repo.GetLatestTransactionPerPortfolio(suite.ctx,
+		[]string{portfolio2, portfolio1, "PORTFOLIO000000000000099"})
+	require.NoError(t, err)
+
+	var sourceIDs []string
+	for _, txn := range latest {
+		sourceIDs = append(sourceIDs, txn.SourceID)
+	}
+	assert.Equal(t, []string{"P1-LATEST", "P2-SAME-INSTANT"}, sourceIDs,
+		"one transaction per requested portfolio, ordered by portfolio, with ties broken by ID")
+
+	latest, err = repo.GetLatestTransactionPerPortfolio(suite.ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, latest)
+}
+
+func TestDatabaseIntegration_GetTopPositions(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	suite := setupIntegrationTestSuite(t)
+	defer suite.teardown(t)
+
+	// Apply the migration that adds the top position indexes
+	migration, err := os.ReadFile("../../migrations/009_create_balance_position_indexes.up.sql")
+	require.NoError(t, err)
+	_, err = suite.db.Exec(string(migration))
+	require.NoError(t, err)
+
+	repo := postgresql.NewBalanceRepository(&database.DB{DB: suite.db}, logger.NewNoop())
+	security1 := "SECURITY0000000000000011"
+	security2 := "SECURITY0000000000000012"
+	security3 := "SECURITY0000000000000013"
+
+	positions := []struct {
+		portfolioID string
+		securityID  *string
+		long, short int64
+	}{
+		{portfolioID: "PORTFOLIO000000000000011", securityID: &security1, long: 500, short: 0},
+		{portfolioID: "PORTFOLIO000000000000011", securityID: &security2, long: 100, short: 900},
+		{portfolioID: "PORTFOLIO000000000000012", securityID: &security1, long: 700, short: 600},
+		{portfolioID: "PORTFOLIO000000000000012", securityID: &security3, long: 300, short: 50},
+		// Cash is never a position, however large
+		{portfolioID: "PORTFOLIO000000000000012", securityID: nil, long: 1000000, short: 0},
+	}
+	for _, position := range positions {
+		require.NoError(t, repo.Create(suite.ctx, &repositories.Balance{
+			PortfolioID:   position.portfolioID,
+			SecurityID:    position.securityID,
+			QuantityLong:  decimal.NewFromInt(position.long),
+			QuantityShort: decimal.NewFromInt(position.short),
+			Version:       1,
+		}))
+	}
+
+	tests := []struct {
+		by       repositories.PositionRanking
+		limit    int
+		expected []int64 // ranking quantity of each returned position
+	}{
+		{by: repositories.PositionRankingLong, limit: 3, expected: []int64{700, 500, 300}},
+		{by: repositories.PositionRankingShort, limit: 2, expected: []int64{900, 600}},
+		{by: repositories.PositionRankingAbsolute, limit: 10, expected: []int64{800, 500, 250, 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.by.String(), func(t *testing.T) {
+			balances, err := repo.GetTopPositions(suite.ctx, tt.by, tt.limit)
+			require.NoError(t, err)
+			require.Len(t, balances, len(tt.expected))
+
+			for i, balance := range balances {
+				require.NotNil(t, balance.SecurityID)
+
+				quantity := balance.QuantityLong
+				switch tt.by {
+				case repositories.PositionRankingShort:
+					quantity = balance.QuantityShort
+				case repositories.PositionRankingAbsolute:
+					quantity = balance.QuantityLong.Sub(balance.QuantityShort).Abs()
+				}
+				assert.True(t, decimal.NewFromInt(tt.expected[i]).Equal(quantity),
+					"position %d: expected %d, got %s", i, tt.expected[i], quantity)
+			}
+		})
+	}
+
+	_, err = repo.GetTopPositions(suite.ctx, repositories.PositionRanking("gross"), 10)
+	assert.Error(t, err)
+}
+
+func TestDatabaseIntegration_GetPortfolioSummaries(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	suite := setupIntegrationTestSuite(t)
+	defer suite.teardown(t)
+
+	repo := postgresql.NewBalanceRepository(&database.DB{DB: suite.db}, logger.NewNoop())
+	portfolio1 := "PORTFOLIO000000000000021"
+	portfolio2 := "PORTFOLIO000000000000022"
+	security1 := "SECURITY0000000000000021"
+	security2 := "SECURITY0000000000000022"
+
+	balances := []struct {
+		portfolioID string
+		securityID  *string
+		long, short int64
+	}{
+		{portfolioID: portfolio1, securityID: nil, long: 1500, short: 0},
+		{portfolioID: portfolio1, securityID: &security1, long: 100, short: 0},
+		{portfolioID: portfolio1, securityID: &security2, long: 0, short: 40},
+		{portfolioID: portfolio2, securityID: &security1, long: 25, short: 0},
+	}
+	for _, balance := range balances {
+		require.NoError(t, repo.Create(suite.ctx, &repositories.Balance{
+			PortfolioID:   balance.portfolioID,
+			SecurityID:    balance.securityID,
+			QuantityLong:  decimal.NewFromInt(balance.long),
+			QuantityShort: decimal.NewFromInt(balance.short),
+			Version:       1,
+		}))
+	}
+
+	summaries, err := repo.GetPortfolioSummaries(suite.ctx, []string{portfolio2, portfolio1, "PORTFOLIO000000000000029"}, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, summaries, 2, "portfolios without balances are omitted")
+
+	assert.Equal(t, portfolio1, summaries[0].PortfolioID)
+	assert.Equal(t, 3, summaries[0].TotalPositions)
+	assert.True(t, decimal.NewFromInt(1500).Equal(summaries[0].CashBalance))
+	assert.Equal(t, 2, summaries[0].LongPositions)
+	assert.Equal(t, 1, summaries[0].ShortPositions)
+	assert.False(t, summaries[0].LastUpdated.IsZero())
+
+	assert.Equal(t, portfolio2, summaries[1].PortfolioID)
+	assert.Equal(t, 1, summaries[1].TotalPositions)
+	assert.True(t, summaries[1].CashBalance.IsZero())
+
+	// Without portfolio IDs every portfolio is paged in ID order
+	page, err := repo.GetPortfolioSummaries(suite.ctx, nil, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, portfolio2, page[0].PortfolioID)
+}
+
+func TestDatabaseIntegration_StatementTimeout(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	suite := setupIntegrationTestSuite(t)
+	defer suite.teardown(t)
+
+	host, err := suite.postgresContainer.Host(suite.ctx)
+	require.NoError(t, err)
+	port, err := suite.postgresContainer.MappedPort(suite.ctx, "5432")
+	require.NoError(t, err)
+
+	db, err := database.NewConnection(config.DatabaseConfig{
+		Host:             host,
+		Port:             port.Int(),
+		User:             "testuser",
+		Password:         "testpass",
+		Database:         "testdb",
+		SSLMode:          "disable",
+		MaxOpenConns:     2,
+		MaxIdleConns:     2,
+		StatementTimeout: 200 * time.Millisecond,
+	}, logger.NewNoop())
+	require.NoError(t, err)
+	defer db.Close()
+
+	var timeout string
+	require.NoError(t, db.GetContext(suite.ctx, &timeout, "SHOW statement_timeout"))
+	assert.Equal(t, "200ms", timeout)
+
+	// The context never expires, so only the server can end the statement
+	started := time.Now()
+	_, err = db.ExecContext(context.Background(), "SELECT pg_sleep(10)")
+	require.Error(t, err)
+	assert.Less(t, time.Since(started), 5*time.Second)
+
+	var pqErr *pq.Error
+	require.ErrorAs(t, err, &pqErr)
+	assert.Equal(t, pq.ErrorCode("57014"), pqErr.Code, "query_canceled")
+
+	// The connection stays usable for statements within the limit
+	var one int
+	require.NoError(t, db.GetContext(suite.ctx, &one, "SELECT 1"))
+	assert.Equal(t, 1, one)
+}
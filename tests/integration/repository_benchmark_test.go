@@ -0,0 +1,239 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/domain/repositories"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database"
+	"github.com/kasbench/globeco-portfolio-accounting-service/internal/infrastructure/database/postgresql"
+	"github.com/kasbench/globeco-portfolio-accounting-service/pkg/logger"
+)
+
+// Seeded data set of the repository benchmarks
+const (
+	benchmarkPortfolios             = 200
+	benchmarkSecuritiesPerPortfolio = 50
+	benchmarkTransactions           = 50000
+	benchmarkSummaryPortfolios      = 100
+)
+
+// skipIfDockerUnavailable skips a benchmark when no container provider is running
+func skipIfDockerUnavailable(b *testing.B) {
+	b.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			b.Skipf("Docker is not running: %v", r)
+		}
+	}()
+
+	provider, err := testcontainers.ProviderDocker.GetProvider()
+	if err != nil {
+		b.Skipf("Docker is not running: %s", err)
+	}
+	if err := provider.Health(context.Background()); err != nil {
+		b.Skipf("Docker is not running: %s", err)
+	}
+}
+
+// applyMigrations runs migration files on top of the integration schema
+func applyMigrations(tb testing.TB, db *sqlx.DB, names ...string) {
+	tb.Helper()
+	for _, name := range names {
+		migration, err := os.ReadFile("../../migrations/" + name)
+		require.NoError(tb, err)
+		_, err = db.Exec(string(migration))
+		require.NoError(tb, err, name)
+	}
+}
+
+// seedBenchmarkData fills the tables with a cash balance and security positions for every
+// portfolio, a tenth of them zero, and transactions spread over portfolios, securities and statuses
+func seedBenchmarkData(tb testing.TB, db *sqlx.DB) {
+	tb.Helper()
+
+	_, err := db.Exec(`
+		INSERT INTO balances (portfolio_id, security_id, quantity_long, quantity_short)
+		SELECT 'PORTFOLIO' || LPAD(p::text, 15, '0'), NULL, 10000 + p, 0
+		FROM generate_series(1, $1) AS p`, benchmarkPortfolios)
+	require.NoError(tb, err)
+
+	_, err = db.Exec(`
+		INSERT INTO balances (portfolio_id, security_id, quantity_long, quantity_short)
+		SELECT 'PORTFOLIO' || LPAD(p::text, 15, '0'),
+			   'SECURITY' || LPAD(s::text, 16, '0'),
+			   CASE WHEN s % 10 = 0 THEN 0 ELSE s * 10 END,
+			   CASE WHEN s % 7 = 0 THEN s ELSE 0 END
+		FROM generate_series(1, $1) AS p, generate_series(1, $2) AS s`,
+		benchmarkPortfolios, benchmarkSecuritiesPerPortfolio)
+	require.NoError(tb, err)
+
+	_, err = db.Exec(`
+		INSERT INTO transactions (portfolio_id, security_id, source_id, status, transaction_type, quantity, price, transaction_date)
+		SELECT 'PORTFOLIO' || LPAD((t % $2 + 1)::text, 15, '0'),
+			   'SECURITY' || LPAD((t % $3 + 1)::text, 16, '0'),
+			   'BENCH-' || t,
+			   (ARRAY['NEW', 'PROC', 'PROC', 'PROC', 'ERROR'])[t % 5 + 1],
+			   'BUY',
+			   t % 1000 + 1,
+			   50.25,
+			   DATE '2024-01-01' + (t % 365)
+		FROM generate_series(1, $1) AS t`,
+		benchmarkTransactions, benchmarkPortfolios, benchmarkSecuritiesPerPortfolio)
+	require.NoError(tb, err)
+
+	_, err = db.Exec("ANALYZE balances; ANALYZE transactions")
+	require.NoError(tb, err)
+}
+
+// benchmarkPortfolioID returns the ID of the nth seeded portfolio, starting at 1
+func benchmarkPortfolioID(n int) string {
+	return fmt.Sprintf("PORTFOLIO%015d", n)
+}
+
+// perQueryPortfolioSummary is the former GetPortfolioSummary, which issued one query per
+// figure. It is kept here as the baseline of the summary benchmarks.
+func perQueryPortfolioSummary(ctx context.Context, db *sqlx.DB, portfolioID string) (*repositories.PortfolioSummary, error) {
+	summary := &repositories.PortfolioSummary{PortfolioID: portfolioID}
+
+	if err := db.GetContext(ctx, &summary.TotalPositions, "SELECT COUNT(*) FROM balances WHERE portfolio_id = $1", portfolioID); err != nil {
+		return nil, err
+	}
+	err := db.GetContext(ctx, &summary.CashBalance, "SELECT COALESCE(quantity_long, 0) FROM balances WHERE portfolio_id = $1 AND security_id IS NULL", portfolioID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err := db.GetContext(ctx, &summary.LongPositions, "SELECT COUNT(*) FROM balances WHERE portfolio_id = $1 AND quantity_long > 0", portfolioID); err != nil {
+		return nil, err
+	}
+	if err := db.GetContext(ctx, &summary.ShortPositions, "SELECT COUNT(*) FROM balances WHERE portfolio_id = $1 AND quantity_short > 0", portfolioID); err != nil {
+		return nil, err
+	}
+	var lastUpdated sql.NullTime
+	if err := db.GetContext(ctx, &lastUpdated, "SELECT MAX(last_updated) FROM balances WHERE portfolio_id = $1", portfolioID); err != nil {
+		return nil, err
+	}
+	summary.LastUpdated = lastUpdated.Time
+	return summary, nil
+}
+
+// BenchmarkRepositories measures the hot repository paths against one seeded container. The
+// PerQuery/PerPortfolio variants of the summary benchmarks are the baselines the single
+// grouped query replaced; compare them with
+//
+//	go test -tags integration -run '^$' -bench Repositories -benchmem ./tests/integration/
+func BenchmarkRepositories(b *testing.B) {
+	skipIfDockerUnavailable(b)
+
+	suite := setupIntegrationTestSuite(b)
+	defer suite.teardown(b)
+
+	applyMigrations(b, suite.db,
+		"003_create_indexes.up.sql",
+		"004_add_transaction_retry_support.up.sql",
+		"008_add_transaction_settlement_date.up.sql",
+		"009_create_balance_position_indexes.up.sql")
+	seedBenchmarkData(b, suite.db)
+
+	ctx := suite.ctx
+	db := &database.DB{DB: suite.db}
+	transactionRepo := postgresql.NewTransactionRepository(db, logger.NewNoop())
+	balanceRepo := postgresql.NewBalanceRepository(db, logger.NewNoop())
+
+	summaryPortfolioIDs := make([]string, benchmarkSummaryPortfolios)
+	for i := range summaryPortfolioIDs {
+		summaryPortfolioIDs[i] = benchmarkPortfolioID(i + 1)
+	}
+
+	portfolioID, processed := benchmarkPortfolioID(7), "PROC"
+	transactionFilter := repositories.TransactionFilter{
+		PortfolioID: &portfolioID,
+		Status:      &processed,
+		Limit:       50,
+	}
+
+	b.Run("TransactionList", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := transactionRepo.List(ctx, transactionFilter)
+			require.NoError(b, err)
+		}
+	})
+
+	b.Run("TransactionCount", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := transactionRepo.Count(ctx, transactionFilter)
+			require.NoError(b, err)
+		}
+	})
+
+	b.Run("BalanceListByPortfolios", func(b *testing.B) {
+		filter := repositories.BalanceFilter{
+			PortfolioIDs:        summaryPortfolioIDs[:10],
+			ExcludeZeroBalances: true,
+			Limit:               100,
+		}
+		for i := 0; i < b.N; i++ {
+			_, err := balanceRepo.List(ctx, filter)
+			require.NoError(b, err)
+		}
+	})
+
+	b.Run("BalanceStats", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := balanceRepo.GetBalanceStats(ctx)
+			require.NoError(b, err)
+		}
+	})
+
+	b.Run("PortfolioSummary/PerQuery", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := perQueryPortfolioSummary(ctx, suite.db, benchmarkPortfolioID(i%benchmarkPortfolios+1))
+			require.NoError(b, err)
+		}
+	})
+
+	b.Run("PortfolioSummary/Grouped", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := balanceRepo.GetPortfolioSummary(ctx, benchmarkPortfolioID(i%benchmarkPortfolios+1))
+			require.NoError(b, err)
+		}
+	})
+
+	b.Run("PortfolioSummaries/PerPortfolio", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, portfolioID := range summaryPortfolioIDs {
+				_, err := perQueryPortfolioSummary(ctx, suite.db, portfolioID)
+				require.NoError(b, err)
+			}
+		}
+	})
+
+	b.Run("PortfolioSummaries/Grouped", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			summaries, err := balanceRepo.GetPortfolioSummaries(ctx, summaryPortfolioIDs, 0, 0)
+			require.NoError(b, err)
+			require.Len(b, summaries, benchmarkSummaryPortfolios)
+		}
+	})
+
+	// The grouped query must agree with the baseline it replaced
+	expected, err := perQueryPortfolioSummary(ctx, suite.db, benchmarkPortfolioID(3))
+	require.NoError(b, err)
+	actual, err := balanceRepo.GetPortfolioSummary(ctx, benchmarkPortfolioID(3))
+	require.NoError(b, err)
+	require.Equal(b, expected.TotalPositions, actual.TotalPositions)
+	require.Equal(b, expected.LongPositions, actual.LongPositions)
+	require.Equal(b, expected.ShortPositions, actual.ShortPositions)
+	require.True(b, expected.CashBalance.Equal(actual.CashBalance))
+	require.True(b, decimal.NewFromInt(10003).Equal(actual.CashBalance))
+}